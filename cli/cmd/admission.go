@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var admissionCmd = &cobra.Command{
+	Use:   "admission-webhook",
+	Short: "Run a Kubernetes admission webhook that keeps vulnerable images out",
+	Long: `Serves a validating admission webhook that checks the images of Pods, and of
+the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets, Jobs
+and CronJobs, as they are created or updated, and rejects them when an image
+fails the policy: a finding at or above --fail-on, or incomplete results, or
+a deny rule of a --policy file (see 'keystone policy').
+
+Each image is pulled and scanned as by 'keystone image', and its result kept
+by digest for --cache-ttl, so that later Pods of the same image are decided
+at once. An image whose scan takes longer than the API server waits, whose
+timeout is at most 30 seconds, is rejected as still being scanned, and its
+scan goes on: the controller retrying the Pod will find it done. Images are
+pulled anonymously, so private registries can't be scanned yet.
+
+When an object's images can't be read or scanned at all, it is rejected,
+or admitted with a warning with --fail-open. Pods in --exempt-namespace are
+admitted without a check. Warn rules of the policy become warnings to
+kubectl.
+
+The API server only calls webhooks over HTTPS, so --tls-cert and --tls-key
+are required, for a certificate the webhook configuration's caBundle trusts:
+
+  apiVersion: admissionregistration.k8s.io/v1
+  kind: ValidatingWebhookConfiguration
+  metadata: {name: keystone}
+  webhooks:
+    - name: images.keystone.dev
+      admissionReviewVersions: [v1]
+      sideEffects: None
+      timeoutSeconds: 30
+      failurePolicy: Fail
+      clientConfig:
+        service: {namespace: keystone, name: keystone-webhook, path: /validate}
+        caBundle: <base64 PEM>
+      rules:
+        - apiGroups: ["", apps, batch]
+          apiVersions: [v1]
+          operations: [CREATE, UPDATE]
+          resources: [pods, deployments, statefulsets, daemonsets, replicasets, jobs, cronjobs]`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFor(cmd, ".")
+		if admissionTLSCert == "" || admissionTLSKey == "" {
+			usagef("--tls-cert and --tls-key are required: the API server only calls webhooks over HTTPS")
+		}
+		if !scanner.ValidFailOn(admissionFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", admissionFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		w := &admissionWebhook{
+			exempt: map[string]bool{},
+			scans:  map[string]*imageScan{},
+			pulls:  make(chan struct{}, maxImagePulls),
+		}
+		for _, ns := range admissionExempt {
+			w.exempt[ns] = true
+		}
+		var err error
+		if admissionPolicy != "" {
+			if w.policy, err = scanner.LoadPolicy(admissionPolicy); err != nil {
+				usage("Error reading --policy", err)
+			}
+		}
+		w.rules, err = cfg.ignoreRules()
+		if err == nil && admissionIgnoreFile != "" {
+			var fileRules []scanner.IgnoreRule
+			fileRules, err = scanner.LoadIgnoreFile(admissionIgnoreFile, false)
+			w.rules = append(w.rules, fileRules...)
+		}
+		if err != nil {
+			fatal("Error reading ignore rules", err)
+		}
+
+		// Scans outlive the requests that start them, so they get their own
+		// contexts rather than the command's.
+		if w.client, err = httpClient(context.Background()); err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		if w.source, err = (sourceOptions{
+			noCache:     admissionNoCache,
+			concurrency: admissionConcurrency,
+			rateLimit:   admissionRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      admissionOSVURL,
+			client:      w.client,
+		}).open(nil); err != nil {
+			fatal("Error opening OSV source", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, "ok")
+		})
+		mux.HandleFunc("/validate", w.handle)
+		srv := &http.Server{Addr: admissionAddr, Handler: logRequests(mux), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-cmd.Context().Done()
+			logger.Info("Shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}()
+
+		logger.Info("🛡️  Serving the admission webhook on " + admissionAddr)
+		if err := srv.ListenAndServeTLS(admissionTLSCert, admissionTLSKey); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Error serving", err)
+		}
+	},
+}
+
+var (
+	admissionAddr        string
+	admissionTLSCert     string
+	admissionTLSKey      string
+	admissionFailOn      string
+	admissionPolicy      string
+	admissionIgnoreFile  string
+	admissionExempt      []string
+	admissionCacheTTL    time.Duration
+	admissionScanTimeout time.Duration
+	admissionFailOpen    bool
+	admissionNoCache     bool
+	admissionOSVURL      string
+	admissionConcurrency int
+	admissionRateLimit   float64
+)
+
+func init() {
+	rootCmd.AddCommand(admissionCmd)
+
+	admissionCmd.Flags().StringVar(&admissionAddr, "addr", ":8443", "address to listen on")
+	admissionCmd.Flags().StringVar(&admissionTLSCert, "tls-cert", "", "certificate to serve with (required)")
+	admissionCmd.Flags().StringVar(&admissionTLSKey, "tls-key", "", "private key of --tls-cert (required)")
+	admissionCmd.Flags().StringVar(&admissionFailOn, "fail-on", "high", "reject images with a vulnerability at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	admissionCmd.Flags().StringVar(&admissionPolicy, "policy", "", "also reject images violating the deny rules of this policy file")
+	admissionCmd.Flags().StringVar(&admissionIgnoreFile, "ignore-file", "", "suppression rules to apply")
+	admissionCmd.Flags().StringSliceVar(&admissionExempt, "exempt-namespace", []string{"kube-system"}, "namespaces whose Pods are admitted without a check (repeatable)")
+	admissionCmd.Flags().DurationVar(&admissionCacheTTL, "cache-ttl", 6*time.Hour, "how long an image's scan result is reused for")
+	admissionCmd.Flags().DurationVar(&admissionScanTimeout, "scan-timeout", 10*time.Minute, "give up pulling and scanning an image after this long")
+	admissionCmd.Flags().BoolVar(&admissionFailOpen, "fail-open", false, "admit Pods whose images can't be read, pulled or scanned, with a warning")
+	admissionCmd.Flags().IntVarP(&admissionConcurrency, "concurrency", "c", scanner.DefaultConcurrency, "number of parallel OSV requests, across all scans")
+	admissionCmd.Flags().Float64Var(&admissionRateLimit, "rate-limit", scanner.DefaultRateLimit, "maximum OSV requests per second, across all scans (0 = unlimited)")
+	admissionCmd.Flags().StringVar(&admissionOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	admissionCmd.Flags().BoolVar(&admissionNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	addNetworkFlags(admissionCmd)
+}
+
+/********** helpers **********/
+
+// maxImagePulls bounds how many images the webhook pulls and scans at once.
+const maxImagePulls = 4
+
+// admissionWebhook answers AdmissionReviews.
+type admissionWebhook struct {
+	client *http.Client
+	source scanner.Source
+	policy *scanner.Policy
+	rules  []scanner.IgnoreRule
+	exempt map[string]bool
+
+	mu    sync.Mutex
+	scans map[string]*imageScan // by image digest
+	pulls chan struct{}         // of maxImagePulls
+}
+
+// imageScan is the scan of an image, done when done is closed.
+type imageScan struct {
+	done   chan struct{}
+	report *scanner.Report
+	err    error
+	at     time.Time
+}
+
+// admissionReview is the admission.k8s.io/v1 AdmissionReview, as much of it
+// as the webhook reads and writes.
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID       string                `json:"uid"`
+	Kind      struct{ Kind string } `json:"kind"`
+	Namespace string                `json:"namespace"`
+	Name      string                `json:"name"`
+	Object    json.RawMessage       `json:"object"`
+}
+
+type admissionResponse struct {
+	UID      string           `json:"uid"`
+	Allowed  bool             `json:"allowed"`
+	Status   *admissionStatus `json:"status,omitempty"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// podSpec is the part of a Pod's spec that names its images.
+type podSpec struct {
+	Containers          []struct{ Image string } `json:"containers"`
+	InitContainers      []struct{ Image string } `json:"initContainers"`
+	EphemeralContainers []struct{ Image string } `json:"ephemeralContainers"`
+}
+
+func (w *admissionWebhook) handle(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		httpError(rw, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var review admissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, 8<<20)).Decode(&review); err != nil || review.Request == nil {
+		httpError(rw, http.StatusBadRequest, "expected an AdmissionReview with a request")
+		return
+	}
+	req := review.Request
+	resp := &admissionResponse{UID: req.UID, Allowed: true}
+	if !w.exempt[req.Namespace] {
+		// The API server says how long it waits; answer a little sooner.
+		ctx := r.Context()
+		if d, err := time.ParseDuration(r.URL.Query().Get("timeout")); err == nil && d > 2*time.Second {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d-time.Second)
+			defer cancel()
+		}
+		w.review(ctx, req, resp)
+	}
+	review.Request, review.Response = nil, resp
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(review)
+}
+
+// review decides on the object of req, writing the decision to resp.
+func (w *admissionWebhook) review(ctx context.Context, req *admissionRequest, resp *admissionResponse) {
+	object := req.Kind.Kind + " " + req.Namespace + "/" + req.Name
+	images, err := podImages(req.Object)
+	if err != nil {
+		logger.Warn("Can't read the images of "+object, "err", err)
+		if admissionFailOpen {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("keystone couldn't read the images of %s: %v", object, err))
+			return
+		}
+		resp.Allowed = false
+		resp.Status = &admissionStatus{Code: http.StatusForbidden, Message: fmt.Sprintf("keystone: the images of %s couldn't be read: %v", object, err)}
+		return
+	}
+	var denials []string
+	for _, ref := range images {
+		scan, err := w.scan(ctx, ref)
+		if err == nil {
+			denials = append(denials, w.evaluate(ref, scan.report, resp)...)
+			continue
+		}
+		if admissionFailOpen {
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("keystone couldn't check %s: %v", ref, err))
+		} else {
+			denials = append(denials, fmt.Sprintf("%s couldn't be checked: %v", ref, err))
+		}
+	}
+	if len(denials) == 0 {
+		logger.Info("✅ Admitted "+object, "images", len(images))
+		return
+	}
+	logger.Warn("Rejected "+object, "reasons", strings.Join(denials, "; "))
+	resp.Allowed = false
+	resp.Status = &admissionStatus{Code: http.StatusForbidden, Message: "keystone: " + strings.Join(denials, "; ")}
+}
+
+// evaluate returns why the scan of ref fails the policy, adding warn rules'
+// violations to resp's warnings.
+func (w *admissionWebhook) evaluate(ref string, r *scanner.Report, resp *admissionResponse) []string {
+	var denials []string
+	if n := r.Failing(admissionFailOn); n > 0 {
+		denials = append(denials, fmt.Sprintf("%s has %d vulnerability(ies) at or above %s", ref, n, admissionFailOn))
+	} else if r.Partial {
+		denials = append(denials, fmt.Sprintf("%s's results are incomplete, so vulnerabilities at or above %s can't be ruled out", ref, admissionFailOn))
+	}
+	for _, p := range r.Reports() {
+		for _, v := range p.PolicyViolations {
+			msg := fmt.Sprintf("%s violates %q with %s@%s", ref, v.Rule, v.Package, v.Version)
+			if v.ID != "" {
+				msg += " (" + v.ID + ")"
+			}
+			if v.Action == scanner.PolicyDeny {
+				denials = append(denials, msg)
+			} else {
+				resp.Warnings = append(resp.Warnings, "keystone: "+msg)
+			}
+		}
+	}
+	return denials
+}
+
+// scan returns the scan of the image ref, starting it unless a recent one
+// of the same digest is kept or under way, and waiting for it until ctx is
+// done.
+func (w *admissionWebhook) scan(ctx context.Context, ref string) (*imageScan, error) {
+	digest := ref
+	if _, d, ok := strings.Cut(ref, "@"); ok {
+		digest = d
+	} else {
+		var err error
+		if digest, err = scanner.ImageDigest(w.client, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	w.mu.Lock()
+	s := w.scans[digest]
+	if s == nil || s.stale() {
+		s = &imageScan{done: make(chan struct{})}
+		w.scans[digest] = s
+		go w.run(s, ref, digest)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return s, s.err
+	case <-ctx.Done():
+		return nil, errors.New("it is still being scanned; try again shortly")
+	}
+}
+
+// stale reports whether a finished scan is to be done again: after it
+// failed, or after --cache-ttl.
+func (s *imageScan) stale() bool {
+	select {
+	case <-s.done:
+		return s.err != nil || time.Since(s.at) > admissionCacheTTL
+	default:
+		return false
+	}
+}
+
+// run pulls and scans the image ref, evaluating the policy against it.
+func (w *admissionWebhook) run(s *imageScan, ref, digest string) {
+	defer close(s.done)
+	w.pulls <- struct{}{}
+	defer func() { <-w.pulls }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), admissionScanTimeout)
+	defer cancel()
+	start := time.Now()
+	logger.Info("📦 Pulling " + ref)
+	img, err := scanner.PullImage(w.client, ref)
+	if err != nil {
+		s.err = err
+		return
+	}
+	sc := &scanner.Scanner{Source: w.source}
+	if s.report, s.err = scanImageProjects(ctx, sc, ref, imageProjects(sc, img), w.rules); s.err != nil {
+		return
+	}
+	if w.policy != nil {
+		scanner.EvaluatePolicy(s.report, w.policy, nil, time.Now())
+	}
+	s.at = time.Now()
+	logger.Info(fmt.Sprintf("🔎 Scanned %s (%s): %d package(s) in %s", ref, digest, s.report.Scanned, time.Since(start).Round(time.Millisecond)))
+
+	// Forget the scans that have gone stale meanwhile.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for d, other := range w.scans {
+		if other != s && other.stale() {
+			delete(w.scans, d)
+		}
+	}
+}
+
+// podImages lists the images of a Pod, or of the Pod template of a
+// workload, in the order they appear.
+func podImages(object json.RawMessage) ([]string, error) {
+	var obj struct {
+		Spec struct {
+			podSpec
+			Template struct {
+				Spec podSpec `json:"spec"`
+			} `json:"template"`
+			JobTemplate struct {
+				Spec struct {
+					Template struct {
+						Spec podSpec `json:"spec"`
+					} `json:"template"`
+				} `json:"spec"`
+			} `json:"jobTemplate"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(object, &obj); err != nil {
+		return nil, err
+	}
+	var images []string
+	seen := map[string]bool{}
+	for _, spec := range []podSpec{obj.Spec.podSpec, obj.Spec.Template.Spec, obj.Spec.JobTemplate.Spec.Template.Spec} {
+		for _, list := range [][]struct{ Image string }{spec.InitContainers, spec.Containers, spec.EphemeralContainers} {
+			for _, c := range list {
+				if c.Image != "" && !seen[c.Image] {
+					seen[c.Image] = true
+					images = append(images, c.Image)
+				}
+			}
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var attestCmd = &cobra.Command{
+	Use:   "attest <artifact>",
+	Short: "Sign an in-toto attestation that an artifact was scanned",
+	Long: `Signs an in-toto attestation that an artifact was scanned, with the JSON
+report of its scan (--report, from 'keystone scan -o json') as the result,
+so that a deploy gate can check that a scan actually happened for exactly
+that artifact, and what it found, before letting it through.
+
+The artifact is a file, such as a release archive, identified by its SHA-256,
+or a container image pinned to its digest:
+
+  keystone scan -o json > report.json
+  keystone attest dist/app.tar.gz --report report.json --key attest.key -f app.intoto.json
+  keystone attest ghcr.io/acme/api@sha256:4f1c... --report report.json --keyless
+
+The statement's predicate is cosign's vulnerability scan predicate
+(https://cosign.sigstore.dev/attestation/vuln/v1), which policy engines such
+as Kyverno and the Sigstore policy controller understand, and which
+'keystone verify-attestation' checks.
+
+--key signs with an unencrypted ECDSA, RSA or Ed25519 private key in PEM,
+such as one made with:
+
+  openssl ecparam -name prime256v1 -genkey -noout | openssl pkcs8 -topk8 -nocrypt -out attest.key
+  openssl pkey -in attest.key -pubout -out attest.pub
+
+writing the DSSE envelope. A key encrypted by 'cosign generate-key-pair', a
+KMS key URI such as awskms://... or gcpkms://..., and --keyless signing with
+a short-lived Sigstore certificate for the CI job's or your OIDC identity are
+left to cosign (v2.4 or later, on the PATH or at --cosign), which writes a
+Sigstore bundle with the envelope, its certificate and its transparency log
+entry.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+		if attestReport == "" {
+			usagef("--report is required: the JSON report of the artifact's scan, from 'keystone scan -o json'")
+		}
+		if attestKey == "" && !attestKeyless {
+			usagef("Sign with --key or --keyless")
+		}
+
+		subject, err := scanner.ArtifactSubject(args[0])
+		if err != nil {
+			fatal("Error reading the artifact", err)
+		}
+		report, err := scanner.ReadReport(attestReport)
+		if err != nil {
+			fatal("Error reading report", err)
+		}
+		info, err := os.Stat(attestReport)
+		if err != nil {
+			fatal("Error reading report", err)
+		}
+		statement, err := scanner.NewVulnStatement(subject, report, buildVersion(), info.ModTime())
+		if err != nil {
+			fatal("Error building the attestation", err)
+		}
+
+		var out []byte
+		key, err := loadAttestationKey(attestKey)
+		if err != nil {
+			fatal("Error reading signing key", err)
+		}
+		if key != nil {
+			env, err := scanner.SignStatement(statement, key)
+			if err != nil {
+				fatal("Error signing the attestation", err)
+			}
+			out, err = json.Marshal(env)
+			if err != nil {
+				fatal("Error signing the attestation", err)
+			}
+			out = append(out, '\n')
+		} else if out, err = attestWithCosign(cmd.Context(), statement, attestKey); err != nil {
+			fatal("Error signing the attestation with cosign", err)
+		}
+
+		if attestOutput == "" {
+			os.Stdout.Write(out)
+		} else if err := os.WriteFile(attestOutput, out, 0o644); err != nil {
+			fatal("Error writing the attestation", err)
+		}
+		logger.Info(fmt.Sprintf("✍️  Attested that %s (sha256:%s) was scanned: %d vulnerable package(s)",
+			subject.Name, subject.Digest["sha256"], vulnerablePackages(report)))
+	},
+}
+
+var (
+	attestReport  string
+	attestKey     string
+	attestKeyless bool
+	attestOutput  string
+	attestCosign  string
+)
+
+func init() {
+	rootCmd.AddCommand(attestCmd)
+
+	attestCmd.Flags().StringVar(&attestReport, "report", "", "JSON report of the artifact's scan (required)")
+	attestCmd.Flags().StringVar(&attestKey, "key", "", "private key to sign with: a PEM file, a cosign key or a KMS key URI")
+	attestCmd.Flags().BoolVar(&attestKeyless, "keyless", false, "sign with a Sigstore certificate for your OIDC identity, through cosign")
+	attestCmd.MarkFlagsMutuallyExclusive("key", "keyless")
+	attestCmd.Flags().StringVarP(&attestOutput, "output-file", "f", "", "file to write the attestation to (default: stdout)")
+	attestCmd.Flags().StringVar(&attestCosign, "cosign", "cosign", "cosign program to sign with for --keyless, cosign keys and KMS keys")
+}
+
+/********** helpers **********/
+
+// loadAttestationKey reads --key, or returns nil when it's for cosign to
+// sign with: a KMS URI, a password-protected key, or none for --keyless.
+func loadAttestationKey(path string) (crypto.Signer, error) {
+	if path == "" || strings.Contains(path, "://") {
+		return nil, nil
+	}
+	key, err := scanner.LoadAttestationKey(path)
+	if errors.Is(err, scanner.ErrEncryptedKey) {
+		return nil, nil
+	}
+	return key, err
+}
+
+// attestWithCosign has cosign sign statement's predicate about its subject,
+// with key or else keylessly, and returns the Sigstore bundle it writes.
+func attestWithCosign(ctx context.Context, statement *scanner.Statement, key string) ([]byte, error) {
+	if _, err := exec.LookPath(attestCosign); err != nil {
+		return nil, fmt.Errorf("%w (install cosign, or sign with an unencrypted --key)", err)
+	}
+	dir, err := os.MkdirTemp("", "keystone-attest-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	predicate, bundle := filepath.Join(dir, "predicate.json"), filepath.Join(dir, "bundle.json")
+	if err := os.WriteFile(predicate, statement.Predicate, 0o600); err != nil {
+		return nil, err
+	}
+
+	subject := statement.Subject[0]
+	args := []string{"attest-blob", "--yes",
+		"--type", statement.PredicateType,
+		"--predicate", predicate,
+		"--hash", subject.Digest["sha256"],
+		"--new-bundle-format", "--bundle", bundle,
+	}
+	if key != "" {
+		args = append(args, "--key", key)
+	}
+	args = append(args, subject.Name)
+
+	c := exec.CommandContext(ctx, attestCosign, args...)
+	// cosign asks for a key's password, or has you sign in, on the terminal;
+	// stdout is left for the attestation.
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w", attestCosign, err)
+	}
+	return os.ReadFile(bundle)
+}
+
+func vulnerablePackages(r *scanner.Report) int {
+	n := 0
+	for _, p := range r.Reports() {
+		n += len(p.Findings)
+	}
+	return n
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverAuth decides who may call the scanning APIs: holders of an API key
+// from --api-keys, or of an ID token from the --oidc-issuer, each at most
+// at their rate limit. With neither configured, anyone may.
+type serverAuth struct {
+	keys map[[32]byte]*apiKey // by the SHA-256 of the key
+	oidc *oidcVerifier        // nil without --oidc-issuer
+
+	// perMinute is the rate limit of callers without one of their own: OIDC
+	// subjects, and keys the file gives no limit (0 = unlimited).
+	perMinute float64
+
+	mu     sync.Mutex
+	limits map[string]*callerLimit // by caller
+}
+
+// apiKey is a key from --api-keys.
+type apiKey struct {
+	name      string
+	perMinute float64 // < 0: the default
+}
+
+func (a *serverAuth) enabled() bool {
+	return len(a.keys) > 0 || a.oidc != nil
+}
+
+// readAPIKeys reads a --api-keys file. Each line is a caller's name, their
+// key and optionally their rate limit in requests per minute:
+//
+//	# name      key                               requests/minute
+//	ci          3f9c2d7e0b1a4c5d8e6f7a9b0c1d2e3f  600
+//	dashboard   a1b2c3d4e5f60718293a4b5c6d7e8f90
+func readAPIKeys(path string) (map[[32]byte]*apiKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[[32]byte]*apiKey{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: want a name, a key and optionally requests per minute", path, n)
+		}
+		k := &apiKey{name: fields[0], perMinute: -1}
+		if len(fields) == 3 {
+			if k.perMinute, err = strconv.ParseFloat(fields[2], 64); err != nil || k.perMinute < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid rate limit %q", path, n, fields[2])
+			}
+		}
+		sum := sha256.Sum256([]byte(fields[1]))
+		if _, dup := keys[sum]; dup {
+			return nil, fmt.Errorf("%s:%d: key of %s is already in the file", path, n, k.name)
+		}
+		keys[sum] = k
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no keys", path)
+	}
+	return keys, sc.Err()
+}
+
+// require lets a request through to next when it's authenticated and
+// within its caller's rate limit, and otherwise answers 401 or 429, or their
+// gRPC statuses.
+func (a *serverAuth) require(next http.HandlerFunc) http.HandlerFunc {
+	if !a.enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, perMinute, err := a.authenticate(r)
+		if err != nil {
+			logger.Warn("Unauthenticated request", "path", r.URL.Path, "remote", r.RemoteAddr, "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="keystone"`)
+			denyRequest(w, r, http.StatusUnauthorized, grpcUnauthenticated, err.Error())
+			return
+		}
+		if wait, ok := a.allow(caller, perMinute); !ok {
+			logger.Warn("Rate limited", "caller", caller, "path", r.URL.Path)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			denyRequest(w, r, http.StatusTooManyRequests, grpcResourceExhausted, "rate limit exceeded: try again in "+wait.Round(time.Second).String())
+			return
+		}
+		logger.Debug("Authenticated", "caller", caller, "path", r.URL.Path)
+		next(w, r)
+	}
+}
+
+// authenticate returns who sent r, from an API key in the X-API-Key header or
+// a bearer token that is an API key or an ID token, and their rate limit.
+func (a *serverAuth) authenticate(r *http.Request) (string, float64, error) {
+	token := r.Header.Get("X-API-Key")
+	if token == "" {
+		scheme, rest, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if strings.EqualFold(scheme, "Bearer") {
+			token = strings.TrimSpace(rest)
+		}
+	}
+	if token == "" {
+		return "", 0, fmt.Errorf("send an API key or ID token as Authorization: Bearer <token>")
+	}
+
+	if k, ok := a.keys[sha256.Sum256([]byte(token))]; ok {
+		if k.perMinute < 0 {
+			return "key:" + k.name, a.perMinute, nil
+		}
+		return "key:" + k.name, k.perMinute, nil
+	}
+	if a.oidc == nil || strings.Count(token, ".") != 2 {
+		return "", 0, fmt.Errorf("unknown API key")
+	}
+	sub, err := a.oidc.verify(r.Context(), token)
+	if err != nil {
+		return "", 0, err
+	}
+	return "oidc:" + sub, a.perMinute, nil
+}
+
+// allow takes one request from caller's allowance, or returns how long
+// until there is one.
+func (a *serverAuth) allow(caller string, perMinute float64) (time.Duration, bool) {
+	if perMinute <= 0 {
+		return 0, true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := a.limits[caller]
+	if l == nil {
+		if a.limits == nil {
+			a.limits = map[string]*callerLimit{}
+		}
+		l = &callerLimit{tokens: perMinute, last: time.Now()}
+		a.limits[caller] = l
+	}
+	return l.take(perMinute, time.Now())
+}
+
+// callerLimit is a token bucket holding up to a minute's requests, refilled
+// continuously.
+type callerLimit struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l *callerLimit) take(perMinute float64, now time.Time) (time.Duration, bool) {
+	l.tokens = math.Min(perMinute, l.tokens+now.Sub(l.last).Minutes()*perMinute)
+	l.last = now
+	if l.tokens < 1 {
+		return time.Duration((1 - l.tokens) / perMinute * float64(time.Minute)), false
+	}
+	l.tokens--
+	return 0, true
+}
+
+/********** helpers **********/
+
+// denyRequest answers a REST request with status and a JSON error, or a gRPC
+// call with code.
+func denyRequest(w http.ResponseWriter, r *http.Request, status, code int, msg string) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		writeGRPCStatus(w, code, msg)
+		return
+	}
+	httpError(w, status, msg)
+}
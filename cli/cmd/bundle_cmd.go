@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	bundleOutput      string
+	bundleKey         string
+	bundleEcosystems  []string
+	bundleExportURL   string
+	bundleEPSSDataURL string
+	bundleKEVURL      string
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Carry vulnerability data to offline scanners on an isolated network",
+	Long: `Keeps scan --offline up to date on networks without internet access:
+'keystone bundle export', on a machine that has access, packages the OSV
+database, FIRST's EPSS dataset and CISA's KEV catalog into one signed
+archive, and 'keystone bundle import', on the isolated side, checks its
+signature and installs it where scan --offline looks.
+
+Bundles are signed with an Ed25519 key, such as one made with:
+
+  openssl genpkey -algorithm ed25519 -out bundle.key
+  openssl pkey -in bundle.key -pubout -out bundle.pub
+
+The private key stays with export; import only needs the public key.`,
+}
+
+var bundleExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Download the vulnerability data and package it into a signed bundle",
+	Long: `Downloads the OSV database for the selected ecosystems (all supported ones
+by default), FIRST's latest EPSS dataset and CISA's KEV catalog, and writes
+them to a gzipped tar archive (-o) with a manifest of their SHA-256 digests
+signed with --key.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+		if bundleKey == "" {
+			usagef("--key is required: the Ed25519 private key to sign the bundle with")
+		}
+		key, err := scanner.LoadSigningKey(bundleKey)
+		if err != nil {
+			fatal("Error reading signing key", err)
+		}
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		ecosystems := bundleEcosystems
+		if len(ecosystems) == 0 {
+			ecosystems = scanner.Ecosystems()
+		}
+		output := bundleOutput
+		if output == "" {
+			output = "keystone-bundle-" + time.Now().Format("2006-01-02") + ".tar.gz"
+		}
+
+		dir, err := os.MkdirTemp("", "keystone-bundle-")
+		if err != nil {
+			fatal("Error creating a working directory", err)
+		}
+		defer os.RemoveAll(dir)
+		onExit(func() { os.RemoveAll(dir) })
+
+		var names []string
+		for _, eco := range ecosystems {
+			n, err := scanner.DownloadOSVDatabase(client, bundleExportURL, dir, eco)
+			if err != nil {
+				fatal("Error downloading "+eco, err)
+			}
+			logger.Info(fmt.Sprintf("⬇️  %s (%.1f MB)", eco, float64(n)/(1<<20)))
+			names = append(names, eco+".zip")
+		}
+		n, err := scanner.DownloadFile(client, bundleEPSSDataURL, filepath.Join(dir, scanner.BundleEPSSFile))
+		if err == nil {
+			_, err = scanner.LoadEPSSFile(filepath.Join(dir, scanner.BundleEPSSFile))
+		}
+		if err != nil {
+			fatal("Error downloading the EPSS dataset", err)
+		}
+		logger.Info(fmt.Sprintf("⬇️  EPSS (%.1f MB)", float64(n)/(1<<20)))
+		n, err = scanner.DownloadFile(client, bundleKEVURL, filepath.Join(dir, scanner.BundleKEVFile))
+		if err == nil {
+			_, err = scanner.LoadKEVFile(filepath.Join(dir, scanner.BundleKEVFile))
+		}
+		if err != nil {
+			fatal("Error downloading the KEV catalog", err)
+		}
+		logger.Info(fmt.Sprintf("⬇️  KEV (%.1f MB)", float64(n)/(1<<20)))
+		names = append(names, scanner.BundleEPSSFile, scanner.BundleKEVFile)
+
+		f, err := os.Create(output)
+		if err != nil {
+			fatal("Error writing bundle", err)
+		}
+		_, err = scanner.WriteBundle(f, dir, names, key, time.Now())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(output)
+			fatal("Error writing bundle", err)
+		}
+		logger.Info("📦 Bundle written to " + output)
+	},
+}
+
+var bundleImportCmd = &cobra.Command{
+	Use:   "import bundle.tar.gz",
+	Short: "Check a bundle's signature and install it for scan --offline",
+	Long: `Checks the signature of a bundle from 'keystone bundle export' with --key,
+the exporter's public key, and the digest of every file in it, then installs
+its OSV databases where 'keystone db download' would, replacing those of the
+same ecosystems. Nothing is replaced unless the whole bundle checks out.
+
+Its EPSS dataset and KEV catalog are installed beside them, for scan
+--offline --epss and --kev to read.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+		if bundleKey == "" {
+			usagef("--key is required: the public key of the Ed25519 key the bundle was signed with")
+		}
+		key, err := scanner.LoadVerifyKey(bundleKey)
+		if err != nil {
+			fatal("Error reading public key", err)
+		}
+		dir, err := scanner.DBDir()
+		if err != nil {
+			fatal("Error locating database directory", err)
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fatal("Error reading bundle", err)
+		}
+		defer f.Close()
+		m, err := scanner.ReadBundle(f, key, dir)
+		if err != nil {
+			fatal("Error importing bundle", err)
+		}
+		logger.Info(fmt.Sprintf("✅ Imported the bundle of %s into %s: OSV databases for %s, EPSS and KEV",
+			m.Created.Format("2006-01-02 15:04 MST"), dir, strings.Join(m.Ecosystems(), ", ")))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.AddCommand(bundleExportCmd, bundleImportCmd)
+
+	bundleExportCmd.Flags().StringVarP(&bundleOutput, "output", "o", "", "file to write the bundle to (default: keystone-bundle-YYYY-MM-DD.tar.gz)")
+	bundleExportCmd.Flags().StringVar(&bundleKey, "key", "", "Ed25519 private key (PEM) to sign the bundle with")
+	bundleExportCmd.Flags().StringSliceVarP(&bundleEcosystems, "ecosystem", "e", nil, "OSV ecosystem to include (repeatable; default: all supported)")
+	bundleExportCmd.Flags().StringVar(&bundleExportURL, "export-url", scanner.DefaultOSVExportURL, "base URL to download <ecosystem>/all.zip exports from, e.g. an internal mirror")
+	bundleExportCmd.Flags().StringVar(&bundleEPSSDataURL, "epss-data-url", scanner.DefaultEPSSDataURL, "URL of the EPSS dataset (CSV, optionally gzipped) or a mirror")
+	bundleExportCmd.Flags().StringVar(&bundleKEVURL, "kev-url", scanner.DefaultKEVURL, "URL of the KEV catalog feed or a mirror")
+	addNetworkFlags(bundleExportCmd)
+
+	bundleImportCmd.Flags().StringVar(&bundleKey, "key", "", "Ed25519 public key (PEM) the bundle must be signed with")
+}
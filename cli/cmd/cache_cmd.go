@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the local cache of OSV responses",
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete all cached OSV responses",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := scanner.ClearCache()
+		if err != nil {
+			fatal("Error clearing cache", err)
+		}
+		logger.Info("🧹 Cleared OSV cache in " + dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
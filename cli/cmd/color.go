@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+const ansiReset = "\033[0m"
+
+var severityColors = map[string]string{
+	scanner.SeverityCritical: "\033[1;35m", // bold magenta
+	scanner.SeverityHigh:     "\033[1;31m", // bold red
+	scanner.SeverityMedium:   "\033[33m",   // yellow
+	scanner.SeverityLow:      "\033[36m",   // cyan
+	scanner.SeverityUnknown:  "\033[90m",   // grey
+}
+
+// colorize wraps s in the label's color when enabled.
+func colorize(s, label string, enabled bool) string {
+	if !enabled {
+		return s
+	}
+	return severityColors[label] + s + ansiReset
+}
+
+// colorEnabled reports whether f is a terminal that should get ANSI colors,
+// honouring the NO_COLOR convention (https://no-color.org).
+func colorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// Config files set defaults for command-line flags, keyed by flag name:
+//
+//	# keystone.yaml
+//	output: sarif
+//	fail-on: high
+//	ecosystem: [npm, PyPI]
+//	ignore:
+//	  - GHSA-p6mc-m468-83gw
+//	  - lodash@4.17.15 expires=2025-06-30 reason="upgrade blocked by #123"
+//
+// or the same as key = value lines in .keystone.toml. The global file under
+// the user config dir is read first, then the project's; a project value
+// replaces a global one, and a flag given on the command line beats both.
+// "ignore" takes rules in .keystoneignore syntax, applied to every project.
+var (
+	projectConfigNames = []string{"keystone.yaml", "keystone.yml", ".keystone.toml"}
+	globalConfigNames  = []string{"config.yaml", "config.yml", "config.toml"}
+)
+
+// configIgnoreKey holds suppression rules rather than a flag value.
+const configIgnoreKey = "ignore"
+
+// keystoneConfig is the merged contents of the global and project config files.
+type keystoneConfig struct {
+	values map[string][]string // option → value(s)
+	files  map[string]string   // option → file it was read from
+}
+
+// loadConfig reads the global config file and the one in projectDir, if
+// they exist.
+func loadConfig(projectDir string) (*keystoneConfig, error) {
+	cfg := &keystoneConfig{values: map[string][]string{}, files: map[string]string{}}
+
+	type location struct {
+		dir   string
+		names []string
+	}
+	var locations []location
+	if base, err := os.UserConfigDir(); err == nil {
+		locations = append(locations, location{filepath.Join(base, "keystone"), globalConfigNames})
+	}
+	locations = append(locations, location{projectDir, projectConfigNames})
+
+	for _, loc := range locations {
+		for _, name := range loc.names {
+			path := filepath.Join(loc.dir, name)
+			data, err := os.ReadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values, err := parseConfigFile(path, data)
+			if err != nil {
+				return nil, err
+			}
+			logger.Debug("Read config file", "path", path)
+			for k, v := range values {
+				cfg.values[k], cfg.files[k] = v, path
+			}
+			break // only the first config file found in each directory
+		}
+	}
+	return cfg, nil
+}
+
+// apply sets every flag of cmd that the config mentions and the command line
+// didn't. Options no command knows are an error; options for other commands
+// are left alone, so one file can serve scan and fix alike.
+func (cfg *keystoneConfig) apply(cmd *cobra.Command) error {
+	for _, key := range sortedKeys(cfg.values) {
+		if key == configIgnoreKey {
+			continue
+		}
+		flag := cmd.Flags().Lookup(key)
+		if flag == nil {
+			if knownConfigKey(key) {
+				continue
+			}
+			return fmt.Errorf("%s: unknown option %q", cfg.files[key], key)
+		}
+		if flag.Changed || excludedByChangedFlag(cmd, key) {
+			continue
+		}
+		for _, v := range cfg.values[key] {
+			if err := flag.Value.Set(v); err != nil {
+				return fmt.Errorf("%s: %s: %w", cfg.files[key], key, err)
+			}
+		}
+		flag.Changed = true
+	}
+	return nil
+}
+
+// ignoreRules parses the config's "ignore" rules.
+func (cfg *keystoneConfig) ignoreRules() ([]scanner.IgnoreRule, error) {
+	lines := cfg.values[configIgnoreKey]
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return scanner.ParseIgnoreRules(cfg.files[configIgnoreKey], strings.NewReader(strings.Join(lines, "\n")))
+}
+
+// loadConfigFor reads the config for projectDir and applies it to cmd,
+// exiting on error like the commands themselves do. The config can set the
+// logging flags and parser plugins too, so those are set up again
+// afterwards.
+func loadConfigFor(cmd *cobra.Command, projectDir string) *keystoneConfig {
+	cfg, err := loadConfig(projectDir)
+	if err == nil {
+		err = cfg.apply(cmd)
+	}
+	if err != nil {
+		fatal("Error reading config", err)
+	}
+	setupLogging()
+	registerParserPlugins()
+	registerFormatterPlugins()
+	return cfg
+}
+
+/********** helpers **********/
+
+// knownConfigKey reports whether any command has a flag named key.
+func knownConfigKey(key string) bool {
+	for _, c := range rootCmd.Commands() {
+		for _, sub := range append([]*cobra.Command{c}, c.Commands()...) {
+			if sub.Flags().Lookup(key) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// excludedByChangedFlag reports whether the flag called name is in a mutually
+// exclusive group with a flag that's already set, e.g. a configured prod-only
+// when --include-dev was given.
+func excludedByChangedFlag(cmd *cobra.Command, name string) bool {
+	// Annotation cobra's MarkFlagsMutuallyExclusive records groups under.
+	for _, group := range cmd.Flags().Lookup(name).Annotations["cobra_annotation_mutually_exclusive"] {
+		for _, other := range strings.Fields(group) {
+			if f := cmd.Flags().Lookup(other); f != nil && other != name && f.Changed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseConfigFile reads the small subset of YAML (key: value, with block or
+// [inline] lists) or TOML (key = value, with arrays) that config files need.
+func parseConfigFile(path string, data []byte) (map[string][]string, error) {
+	toml := strings.HasSuffix(path, ".toml")
+	sep := ":"
+	if toml {
+		sep = "="
+	}
+
+	values := map[string][]string{}
+	list := "" // key of the block list or multi-line array being read
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if list != "" {
+			switch {
+			case toml && line == "]":
+				list = ""
+				continue
+			case toml:
+				values[list] = append(values[list], configScalar(strings.TrimSuffix(line, ",")))
+				continue
+			case strings.HasPrefix(line, "-"):
+				values[list] = append(values[list], configScalar(strings.TrimSpace(line[1:])))
+				continue
+			}
+			list = "" // a YAML list ends at the next key
+		}
+
+		k, v, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected \"key%s value\"", path, n, sep)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if _, dup := values[k]; dup {
+			return nil, fmt.Errorf("%s:%d: %q is set twice", path, n, k)
+		}
+		switch {
+		case (v == "" && !toml) || (v == "[" && toml):
+			list, values[k] = k, []string{}
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			values[k] = []string{}
+			for _, item := range strings.Split(strings.Trim(v, "[]"), ",") {
+				if item = configScalar(strings.TrimSpace(item)); item != "" {
+					values[k] = append(values[k], item)
+				}
+			}
+		default:
+			values[k] = []string{configScalar(v)}
+		}
+	}
+	if toml && list != "" {
+		return nil, fmt.Errorf("%s: unterminated array %q", path, list)
+	}
+	return values, sc.Err()
+}
+
+// configScalar strips the quotes from a quoted value.
+func configScalar(v string) string {
+	if len(v) < 2 {
+		return v
+	}
+	switch {
+	case v[0] == '"' && v[len(v)-1] == '"':
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+		return v[1 : len(v)-1]
+	case v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1]
+	}
+	return v
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	SerialNumber string          `json:"serialNumber"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cdxComponent `json:"components"`
+	} `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxComponent struct {
+	Type     string       `json:"type"`
+	BOMRef   string       `json:"bom-ref,omitempty"`
+	Group    string       `json:"group,omitempty"`
+	Name     string       `json:"name"`
+	Version  string       `json:"version,omitempty"`
+	PURL     string       `json:"purl,omitempty"`
+	Licenses []cdxLicense `json:"licenses,omitempty"`
+}
+
+// cdxLicense holds either a single license or an SPDX expression.
+type cdxLicense struct {
+	License *struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"license,omitempty"`
+	Expression string `json:"expression,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// writeCycloneDX renders deps as a CycloneDX 1.5 JSON SBOM. Components are
+// keyed by purl; the project itself is the metadata component and depends on
+// the direct dependencies, when the lockfile distinguishes them.
+func writeCycloneDX(w io.Writer, project string, deps []scanner.Package) error {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+	bom.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	bom.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: "keystone"}}
+	bom.Metadata.Component = cdxComponent{Type: "application", BOMRef: "project:" + project, Name: project}
+
+	// requires entries are "name@version"; map them back to component refs.
+	refs := map[string]string{}
+	for _, d := range deps {
+		refs[d.Name+"@"+d.Version] = scanner.PURL(d)
+	}
+
+	root := cdxDependency{Ref: bom.Metadata.Component.BOMRef, DependsOn: []string{}}
+	for _, d := range sbomPackages(deps) {
+		ref := scanner.PURL(d)
+		c := cdxComponent{Type: "library", BOMRef: ref, Name: d.Name, Version: d.Version, PURL: ref}
+		switch {
+		case d.Ecosystem == "Maven":
+			c.Group, c.Name, _ = strings.Cut(d.Name, ":")
+		case d.Ecosystem == "npm" && strings.HasPrefix(d.Name, "@"):
+			c.Group, c.Name, _ = strings.Cut(d.Name, "/")
+		}
+		if d.License != "" {
+			c.Licenses = []cdxLicense{newCDXLicense(d.License)}
+		}
+		bom.Components = append(bom.Components, c)
+
+		dependsOn := []string{}
+		for _, r := range d.Requires {
+			if ref, ok := refs[r]; ok {
+				dependsOn = append(dependsOn, ref)
+			}
+		}
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{Ref: ref, DependsOn: dependsOn})
+		if d.Direct {
+			root.DependsOn = append(root.DependsOn, ref)
+		}
+	}
+	bom.Dependencies = append([]cdxDependency{root}, bom.Dependencies...)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
+
+var spdxLicenseID = regexp.MustCompile(`^[A-Za-z0-9.+-]+$`)
+
+func newCDXLicense(license string) cdxLicense {
+	if strings.Contains(license, " ") {
+		return cdxLicense{Expression: license}
+	}
+	l := cdxLicense{License: &struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	}{}}
+	if spdxLicenseID.MatchString(license) {
+		l.License.ID = license
+	} else {
+		l.License.Name = license
+	}
+	return l
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
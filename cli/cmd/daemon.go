@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Scan directories, repositories and images on cron schedules",
+	Long: `Runs in the background and scans each of its targets on a cron schedule:
+a directory or lockfile on disk, scanned as by 'keystone scan'; a Git
+repository, cloned afresh each time as by 'keystone scan --repo'; or a
+container image, pulled afresh as by 'keystone image', so that a moving tag
+is followed. Targets are given with --target, or as "target" in the config
+file, each a schedule followed by what to scan:
+
+  # keystone.yaml
+  target:
+    - "0 3 * * * ./services/api"
+    - "@hourly repo:https://github.com/acme/web#main"
+    - "30 6 * * 1-5 image:ghcr.io/acme/api:latest"
+  notify:
+    - slack://T000/B000/XXXX
+  notify-on: high
+
+A schedule has cron's five fields (minute, hour, day of the month, month,
+weekday) in local time, with ranges, lists, steps and names, or is one of
+@hourly, @daily, @weekly, @monthly and @yearly, or "@every 6h". A
+repository's branch or tag follows a #.
+
+Every scan is recorded in the scan history (see 'keystone history'), under
+--history-file or the default one, and --notify posts only the findings that
+the target's previous recorded scan didn't have, when any are at or above
+--notify-on; the first scan of a target notifies of all of its findings.
+
+A scan that fails is logged and retried at the next scheduled time; a scan
+still running when its next time comes delays it. --once scans every target
+straight away and exits, such as to try a configuration out.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFor(cmd, ".")
+		if len(daemonTargets) == 0 {
+			usagef(`No targets to scan: give them with --target, or as "target" in the config file`)
+		}
+		var targets []*daemonTarget
+		for _, spec := range daemonTargets {
+			t, err := parseDaemonTarget(spec)
+			if err != nil {
+				usage("Error reading --target", err)
+			}
+			targets = append(targets, t)
+		}
+		if daemonParallel < 1 {
+			usagef("--parallel must be at least 1, not %d", daemonParallel)
+		}
+		if daemonNotifyOn != "" && !scanner.ValidFailOn(daemonNotifyOn) {
+			usagef("Unknown --notify-on level %q (want one of: %s)", daemonNotifyOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+
+		d := &scanDaemon{history: daemonHistoryFile, slots: make(chan struct{}, daemonParallel)}
+		for _, u := range daemonNotify {
+			t, err := parseNotifyURL(u)
+			if err != nil {
+				usage("Error", err)
+			}
+			d.notify = append(d.notify, t)
+		}
+		var err error
+		if d.history == "" {
+			if d.history, err = scanner.HistoryPath(); err != nil {
+				fatal("Error locating history file", err)
+			}
+		}
+		d.rules, err = cfg.ignoreRules()
+		if err == nil && daemonIgnoreFile != "" {
+			var fileRules []scanner.IgnoreRule
+			fileRules, err = scanner.LoadIgnoreFile(daemonIgnoreFile, false)
+			d.rules = append(d.rules, fileRules...)
+		}
+		if err != nil {
+			fatal("Error reading ignore rules", err)
+		}
+		if d.client, err = httpClient(cmd.Context()); err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		if d.source, err = (sourceOptions{
+			noCache:     daemonNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      daemonOSVURL,
+			client:      d.client,
+		}).open(nil); err != nil {
+			fatal("Error opening OSV source", err)
+		}
+
+		ctx := cmd.Context()
+		var wg sync.WaitGroup
+		for _, t := range targets {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if daemonOnce {
+					d.run(ctx, t)
+					return
+				}
+				d.schedule(ctx, t)
+			}()
+		}
+		if !daemonOnce {
+			logger.Info(fmt.Sprintf("⏰ Scanning %d target(s) on schedule; recording to %s", len(targets), d.history))
+		}
+		wg.Wait()
+		if !daemonOnce {
+			logger.Info("Shutting down")
+		}
+	},
+}
+
+var (
+	daemonTargets     []string
+	daemonOnce        bool
+	daemonParallel    int
+	daemonHistoryFile string
+	daemonNotify      []string
+	daemonNotifyOn    string
+	daemonIgnoreFile  string
+	daemonProdOnly    bool
+	daemonNoCache     bool
+	daemonOSVURL      string
+)
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	// A string array, not a slice: schedules like "0 9,17 * * *" have commas.
+	daemonCmd.Flags().StringArrayVar(&daemonTargets, "target", nil, `schedule and what to scan, e.g. "0 3 * * * ./api" (repeatable)`)
+	daemonCmd.Flags().BoolVar(&daemonOnce, "once", false, "scan every target once, now, and exit")
+	daemonCmd.Flags().IntVar(&daemonParallel, "parallel", 2, "number of targets to scan at once")
+	daemonCmd.Flags().StringVar(&daemonHistoryFile, "history-file", "", "record the scans in this history file (default: history.jsonl in keystone's cache directory)")
+	daemonCmd.Flags().StringSliceVar(&daemonNotify, "notify", nil, "post new findings to this slack:// or teams:// webhook URL (repeatable)")
+	daemonCmd.Flags().StringVar(&daemonNotifyOn, "notify-on", "", "only notify when a new finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	daemonCmd.Flags().StringVar(&daemonIgnoreFile, "ignore-file", "", "suppression rules to apply to every target, instead of each directory's .keystoneignore")
+	daemonCmd.Flags().BoolVar(&daemonProdOnly, "prod-only", false, "scan only runtime dependencies")
+	daemonCmd.Flags().BoolVar(&daemonNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	daemonCmd.Flags().StringVar(&daemonOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(daemonCmd)
+}
+
+/********** helpers **********/
+
+// Kinds of daemon targets.
+const (
+	targetPath  = "path"
+	targetRepo  = "repo"
+	targetImage = "image"
+)
+
+// daemonTarget is something the daemon scans, and when.
+type daemonTarget struct {
+	spec     string
+	schedule *cronSchedule
+	kind     string
+	ref      string // path, repository URL or image reference
+	branch   string // of a repository, or "" for its default one
+}
+
+// parseDaemonTarget reads a --target: a schedule, then a path, or a
+// repository URL after "repo:" or an image reference after "image:".
+func parseDaemonTarget(spec string) (*daemonTarget, error) {
+	fields := strings.Fields(spec)
+	n := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		n = 1
+		if fields[0] == "@every" {
+			n = 2
+		}
+	}
+	if len(fields) <= n {
+		return nil, fmt.Errorf("%q: want a schedule and then what to scan", spec)
+	}
+	schedule, err := parseCron(strings.Join(fields[:n], " "))
+	if err != nil {
+		return nil, err
+	}
+	t := &daemonTarget{spec: spec, schedule: schedule, kind: targetPath, ref: strings.Join(fields[n:], " ")}
+	if kind, ref, ok := strings.Cut(t.ref, ":"); ok && (kind == targetPath || kind == targetRepo || kind == targetImage) {
+		t.kind, t.ref = kind, ref
+	}
+	switch t.kind {
+	case targetRepo:
+		t.ref, t.branch, _ = strings.Cut(t.ref, "#")
+	case targetPath:
+		if _, err := os.Stat(t.ref); err != nil {
+			return nil, fmt.Errorf("%q: %w", spec, err)
+		}
+	}
+	if t.ref == "" {
+		return nil, fmt.Errorf("%q: nothing to scan", spec)
+	}
+	return t, nil
+}
+
+// scanDaemon scans targets for the daemon command, sharing its OSV source
+// and history between them.
+type scanDaemon struct {
+	client  *http.Client
+	source  scanner.Source
+	rules   []scanner.IgnoreRule
+	history string
+	notify  []notifyTarget
+	slots   chan struct{} // one per scan that may run at once
+
+	mu sync.Mutex // held while reading and appending to the history
+}
+
+// schedule scans t each time its schedule comes, until ctx is done.
+func (d *scanDaemon) schedule(ctx context.Context, t *daemonTarget) {
+	for {
+		next := t.schedule.next(time.Now())
+		logger.Debug("Next scan", "target", t.ref, "at", next.Format(time.DateTime))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.run(ctx, t)
+	}
+}
+
+// run scans t, records the scan in the history, and notifies of the
+// findings that are new since its previous scan.
+func (d *scanDaemon) run(ctx context.Context, t *daemonTarget) {
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return
+	}
+	defer func() { <-d.slots }()
+
+	start := time.Now()
+	report, err := d.scan(ctx, t)
+	if ctx.Err() != nil {
+		return // nothing incomplete is recorded
+	}
+	if err != nil {
+		logger.Warn("Error scanning "+t.ref, "err", err)
+		return
+	}
+
+	d.mu.Lock()
+	entries, err := scanner.LoadHistory(d.history)
+	if err == nil {
+		err = scanner.RecordHistory(d.history, report, time.Now())
+	}
+	d.mu.Unlock()
+	if err != nil {
+		// Without the previous scan, every finding would look new.
+		logger.Warn("Error recording history", "err", err)
+		return
+	}
+	total := report.VulnCount()
+	scanner.KeepNewFindings(report, entries)
+	logger.Info(fmt.Sprintf("✅ %s: %d vulnerability(ies) in %d package(s), %d new, in %s",
+		t.ref, total, report.Scanned, report.VulnCount(), time.Since(start).Round(time.Millisecond)))
+	if report.Partial {
+		logger.Warn(fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV for %s; results are incomplete.", report.Unfetched(), t.ref))
+	}
+
+	notify := report.VulnCount() > 0
+	if daemonNotifyOn != "" {
+		notify = report.Failing(daemonNotifyOn) > 0
+	}
+	if notify && len(d.notify) > 0 {
+		sendNotifications(d.client, d.notify, buildNotifyMessage(report, true))
+	}
+}
+
+// scan scans t into a report with a project for each of its lockfiles,
+// named so that the same lockfile of another repository or image is kept
+// apart in the history.
+func (d *scanDaemon) scan(ctx context.Context, t *daemonTarget) (*scanner.Report, error) {
+	switch t.kind {
+	case targetImage:
+		logger.Info("📦 Pulling " + t.ref)
+		img, err := scanner.PullImage(d.client, t.ref)
+		if err != nil {
+			return nil, err
+		}
+		sc := &scanner.Scanner{Source: d.source, ProdOnly: daemonProdOnly}
+		report, err := scanImageProjects(ctx, sc, t.ref, imageProjects(sc, img), d.rules)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range report.Projects {
+			p.Source = t.ref + ": " + p.Source
+		}
+		return report, nil
+
+	case targetRepo:
+		dir, err := cloneRepo(t.ref, t.branch)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		paths, err := scanner.DiscoverLockfiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		return d.scanLockfiles(ctx, t.ref, paths, func(path string) string {
+			rel, _ := filepath.Rel(dir, path)
+			return t.ref + ": " + filepath.ToSlash(rel)
+		})
+
+	default:
+		logger.Info("🔎 Scanning " + t.ref)
+		paths := []string{t.ref}
+		if isDir(t.ref) {
+			var err error
+			if paths, err = scanner.DiscoverLockfiles(t.ref); err != nil {
+				return nil, err
+			}
+		}
+		return d.scanLockfiles(ctx, t.ref, paths, func(path string) string { return path })
+	}
+}
+
+// scanLockfiles scans the lockfiles at paths of the target called label,
+// as the scanLockfiles function does.
+func (d *scanDaemon) scanLockfiles(ctx context.Context, label string, paths []string, name func(path string) string) (*scanner.Report, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no supported lockfiles found")
+	}
+	sc := &scanner.Scanner{Source: d.source, ProdOnly: daemonProdOnly}
+	return scanLockfiles(ctx, sc, label, paths, d.rules, daemonIgnoreFile == "", name)
+}
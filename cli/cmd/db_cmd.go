@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbEcosystems []string
+	dbExportURL  string
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the local OSV database used by scan --offline",
+}
+
+var dbDownloadCmd = &cobra.Command{
+	Use:   "download",
+	Short: "Download the OSV database for offline scanning",
+	Long: `Downloads the full OSV export for the selected ecosystems (all supported
+ecosystems by default), so that scan --offline can run without internet access.
+--export-url downloads from a mirror of the OSV bucket instead.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+		dir, err := scanner.DBDir()
+		if err != nil {
+			fatal("Error locating database directory", err)
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+
+		ecosystems := dbEcosystems
+		if len(ecosystems) == 0 {
+			ecosystems = scanner.Ecosystems()
+		}
+
+		failed := false
+		for _, eco := range ecosystems {
+			n, err := scanner.DownloadOSVDatabase(client, dbExportURL, dir, eco)
+			if err != nil {
+				logger.Error("Error downloading "+eco, "err", err)
+				failed = true
+				continue
+			}
+			logger.Info(fmt.Sprintf("⬇️  %s (%.1f MB)", eco, float64(n)/(1<<20)))
+		}
+		if failed {
+			exit(exitIncomplete)
+		}
+		logger.Info("✅ OSV database saved to " + dir)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbDownloadCmd)
+
+	dbDownloadCmd.Flags().StringSliceVarP(&dbEcosystems, "ecosystem", "e", nil, "OSV ecosystem to download (repeatable; default: all supported)")
+	dbDownloadCmd.Flags().StringVar(&dbExportURL, "export-url", scanner.DefaultOSVExportURL, "base URL to download <ecosystem>/all.zip exports from, e.g. an internal mirror")
+	addNetworkFlags(dbDownloadCmd)
+}
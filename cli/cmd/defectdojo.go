@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// defectDojoExporter uploads the report, as SARIF, to DefectDojo's
+// reimport API. Reimporting into the same product, engagement and test
+// each time lets DefectDojo de-duplicate findings across scans and close
+// the ones that have been fixed.
+type defectDojoExporter struct {
+	url         string
+	token       string
+	product     string
+	productType string
+	engagement  string
+	test        string
+}
+
+func (d *defectDojoExporter) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&d.url, "defectdojo-url", "", "base URL of the DefectDojo instance to --export to")
+	cmd.Flags().StringVar(&d.token, "defectdojo-token", "", "DefectDojo API key (default: $DEFECTDOJO_API_KEY)")
+	cmd.Flags().StringVar(&d.product, "defectdojo-product", "", "DefectDojo product to import into, created if missing")
+	cmd.Flags().StringVar(&d.productType, "defectdojo-product-type", "", "product type of the DefectDojo product, needed to create it")
+	cmd.Flags().StringVar(&d.engagement, "defectdojo-engagement", "keystone", "DefectDojo engagement to import into, created if missing")
+	cmd.Flags().StringVar(&d.test, "defectdojo-test", "keystone", "title of the DefectDojo test the findings go in")
+}
+
+func (d *defectDojoExporter) configure() error {
+	if d.token == "" {
+		d.token = os.Getenv("DEFECTDOJO_API_KEY")
+	}
+	switch {
+	case d.url == "":
+		return fmt.Errorf("--export defectdojo needs --defectdojo-url")
+	case d.product == "":
+		return fmt.Errorf("--export defectdojo needs --defectdojo-product")
+	case d.token == "":
+		return fmt.Errorf("--export defectdojo needs an API key, from --defectdojo-token or $DEFECTDOJO_API_KEY")
+	}
+	return nil
+}
+
+func (d *defectDojoExporter) export(client *http.Client, r *scanner.Report) (string, error) {
+	var sarif bytes.Buffer
+	if err := writeSARIFReport(&sarif, r); err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"scan_type":           "SARIF",
+		"product_name":        d.product,
+		"engagement_name":     d.engagement,
+		"test_title":          d.test,
+		"auto_create_context": "true",
+		"close_old_findings":  "true",
+		"active":              "true",
+		"verified":            "false",
+		"minimum_severity":    "Info",
+		"scan_date":           time.Now().Format(time.DateOnly),
+	}
+	if d.productType != "" {
+		fields["product_type_name"] = d.productType
+	}
+	for _, k := range sortedKeys(fields) {
+		form.WriteField(k, fields[k])
+	}
+	part, err := form.CreateFormFile("file", "keystone.sarif")
+	if err != nil {
+		return "", err
+	}
+	part.Write(sarif.Bytes())
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(d.url, "/")+"/api/v2/reimport-scan/", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Token "+d.token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("DefectDojo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("DefectDojo returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var result struct {
+		Test int `json:"test"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	where := fmt.Sprintf("DefectDojo product %q", d.product)
+	if result.Test != 0 {
+		where += fmt.Sprintf(", test %d", result.Test)
+	}
+	return where, nil
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old-lockfile new-lockfile",
+	Short: "Report vulnerabilities introduced or resolved between two lockfiles",
+	Long: `Scans two versions of a lockfile, typically from a pull request's base and
+head, and reports only the advisories the change introduces and the ones it
+resolves, rather than the project's whole backlog.
+
+An advisory that still affects some version of the same package on both sides
+is unchanged, even if the version moved. Ignore rules from the new lockfile's
+directory (or --ignore-file) and its config file apply to both sides.
+
+With --fail-on, diff exits non-zero only for introduced vulnerabilities, which
+makes it suitable as a pull-request gate.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		oldPath, newPath := filepath.Clean(args[0]), filepath.Clean(args[1])
+		cfg := loadConfigFor(cmd, filepath.Dir(newPath))
+
+		if diffOutput != outputText && diffOutput != outputJSON {
+			usagef("Unknown output format %q (want one of: %s, %s)", diffOutput, outputText, outputJSON)
+		}
+		if diffFailOn != "" && !scanner.ValidFailOn(diffFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", diffFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+
+		sc := &scanner.Scanner{ProdOnly: diffProdOnly}
+		var (
+			kinds     [2]string
+			deps      [2][]scanner.Package
+			queryable []scanner.Package
+		)
+		for i, path := range []string{oldPath, newPath} {
+			kind, d, err := loadScanInput(path, false)
+			if err != nil {
+				fatal("Error", err)
+			}
+			kinds[i], deps[i] = kind, sc.Scannable(d)
+			queryable = append(queryable, deps[i]...)
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		sc.Source, err = sourceOptions{
+			offline:     diffOffline,
+			noCache:     diffNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      diffOSVURL,
+			client:      client,
+		}.open(queryable)
+		if err != nil {
+			fatal("Error loading offline database", err)
+		}
+
+		rules, err := cfg.ignoreRules()
+		if err != nil {
+			fatal("Error reading config", err)
+		}
+		ignorePath, optional := diffIgnoreFile, false
+		if ignorePath == "" {
+			ignorePath, optional = filepath.Join(filepath.Dir(newPath), scanner.IgnoreFileName), true
+		}
+		fileRules, err := scanner.LoadIgnoreFile(ignorePath, optional)
+		if err != nil {
+			fatal("Error reading ignore file", err)
+		}
+		rules = append(rules, fileRules...)
+
+		var reports [2]*scanner.Report
+		for i, path := range []string{oldPath, newPath} {
+			if reports[i], err = sc.Scan(cmd.Context(), path, kinds[i], deps[i]); err != nil {
+				fatal("OSV query failed", err)
+			}
+			scanner.ApplyIgnores(reports[i], rules, time.Now())
+		}
+		diff := scanner.DiffReports(reports[0], reports[1])
+
+		if diffOutput == outputJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(diff)
+		} else {
+			color := colorEnabled(os.Stdout)
+			writeTextDiff(os.Stdout, diff, color)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if reports[0].Partial || reports[1].Partial {
+			logger.Warn("Some advisory details couldn't be fetched from OSV; the comparison may be incomplete.")
+		}
+		if diffFailOn != "" {
+			if n := diff.Failing(diffFailOn); n > 0 {
+				failf("%d introduced vulnerability(ies) at or above --fail-on=%s", n, diffFailOn)
+			}
+		}
+	},
+}
+
+var (
+	diffOutput     string
+	diffFailOn     string
+	diffIgnoreFile string
+	diffOffline    bool
+	diffNoCache    bool
+	diffOSVURL     string
+	diffProdOnly   bool
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", outputText, "output format: text, json")
+	diffCmd.Flags().StringVar(&diffFailOn, "fail-on", "", "exit non-zero if any introduced finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	diffCmd.Flags().StringVar(&diffIgnoreFile, "ignore-file", "", "suppression rules to apply (default: "+scanner.IgnoreFileName+" next to the new lockfile, if present)")
+	diffCmd.Flags().BoolVar(&diffProdOnly, "prod-only", false, "compare only runtime dependencies")
+	diffCmd.Flags().BoolVar(&diffOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	diffCmd.Flags().StringVar(&diffOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	diffCmd.MarkFlagsMutuallyExclusive("offline", "osv-url")
+	diffCmd.Flags().BoolVar(&diffNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	addNetworkFlags(diffCmd)
+}
+
+/********** helpers **********/
+
+func writeTextDiff(w io.Writer, d *scanner.Diff, color bool) {
+	fmt.Fprintf(w, "🔀 %s → %s\n", d.Before, d.After)
+	if len(d.Introduced) == 0 && len(d.Resolved) == 0 {
+		fmt.Fprintln(w, "✅ No change in known vulnerabilities.")
+		return
+	}
+	for _, section := range []struct {
+		title    string
+		findings []scanner.Finding
+	}{
+		{"🆕 Introduced", d.Introduced},
+		{"✅ Resolved", d.Resolved},
+	} {
+		if len(section.findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s (%d):\n", section.title, diffVulnCount(section.findings))
+		for _, f := range section.findings {
+			fmt.Fprintf(w, "  • %s@%s\n", f.Package, f.Version)
+			for _, v := range f.Vulns {
+				sev := severityLabel(v)
+				fmt.Fprintf(w, "     %s %s — %s\n", v.ID, colorize("["+sev+"]", sev, color), firstLine(v.Summary))
+			}
+		}
+	}
+}
+
+func diffVulnCount(findings []scanner.Finding) int {
+	n := 0
+	for _, f := range findings {
+		n += len(f.Vulns)
+	}
+	return n
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var dockerfileCmd = &cobra.Command{
+	Use:   "dockerfile [path]",
+	Short: "Check the base images a Dockerfile builds on",
+	Long: `Reads the FROM instructions of a Dockerfile (./Dockerfile by default) and
+checks each base image it builds on, other than its own build stages:
+
+  • images tagged :latest, or not tagged at all, are flagged: what they
+    build on changes from one build to the next
+  • each tag is resolved to the digest its registry serves now, which the
+    image can be pinned to
+  • a newer release of the same line as the tag is reported, such as
+    alpine:3.20 for alpine:3.18, or node:18.20.4-alpine for
+    node:18.17.0-alpine
+
+ARGs declared before the first FROM are substituted; --build-arg overrides
+their defaults as it does for docker build. Registries are asked
+anonymously; --offline only reads the Dockerfile.
+
+With --scan, each base image is also pulled, at the digest it resolved to,
+and its OS packages and lockfiles scanned for vulnerabilities as 'keystone
+image' does.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "Dockerfile"
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if dockerfileOutput != outputText && dockerfileOutput != outputJSON {
+			usagef("Unknown output format %q (want one of: %s, %s)", dockerfileOutput, outputText, outputJSON)
+		}
+		if dockerfileFailOn != "" && !scanner.ValidFailOn(dockerfileFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", dockerfileFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		if dockerfileFailOn != "" {
+			dockerfileScan = true
+		}
+		if dockerfileScan && dockerfileOffline {
+			usagef("--scan pulls the base images, so it can't be used with --offline")
+		}
+		buildArgs := map[string]string{}
+		for _, a := range dockerfileBuildArgs {
+			name, value, ok := strings.Cut(a, "=")
+			if !ok {
+				value, ok = os.LookupEnv(name) // as docker build takes --build-arg NAME
+			}
+			if ok {
+				buildArgs[name] = value
+			}
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		result, err := scanner.CheckDockerfile(path, scanner.DockerfileOptions{
+			Client:    client,
+			BuildArgs: buildArgs,
+			Offline:   dockerfileOffline,
+		})
+		if err != nil {
+			fatal("Error reading Dockerfile", err)
+		}
+		if dockerfileScan {
+			result.Scan = scanBaseImages(cmd, client, result)
+		}
+
+		if dockerfileOutput == outputJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(result)
+		} else {
+			writeTextDockerfile(os.Stdout, result)
+			if result.Scan != nil {
+				fmt.Println()
+				err = writeReport(os.Stdout, result.Scan, outputText)
+			}
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if dockerfileFailOn != "" && result.Scan != nil {
+			if n := result.Scan.Failing(dockerfileFailOn); n > 0 {
+				failf("%d vulnerability(ies) at or above --fail-on=%s", n, dockerfileFailOn)
+			}
+			if result.Scan.Partial {
+				fatalf("Can't confirm nothing is at or above --fail-on=%s with incomplete results", dockerfileFailOn)
+			}
+		}
+	},
+}
+
+var (
+	dockerfileBuildArgs []string
+	dockerfileOffline   bool
+	dockerfileOutput    string
+	dockerfileScan      bool
+	dockerfileFailOn    string
+	dockerfileIgnore    string
+	dockerfileNoCache   bool
+	dockerfileOSVURL    string
+)
+
+func init() {
+	rootCmd.AddCommand(dockerfileCmd)
+
+	dockerfileCmd.Flags().StringArrayVar(&dockerfileBuildArgs, "build-arg", nil, "set a build argument used in FROM: NAME=VALUE, or NAME to take it from the environment (repeatable)")
+	dockerfileCmd.Flags().BoolVar(&dockerfileOffline, "offline", false, "don't ask registries about the base images")
+	dockerfileCmd.Flags().StringVarP(&dockerfileOutput, "output", "o", outputText, "output format: text, json")
+	dockerfileCmd.Flags().BoolVar(&dockerfileScan, "scan", false, "also pull the base images and scan them for vulnerabilities")
+	dockerfileCmd.Flags().StringVar(&dockerfileFailOn, "fail-on", "", "exit non-zero if any finding in the base images is at or above this severity (implies --scan): "+strings.Join(scanner.FailOnLevels, ", "))
+	dockerfileCmd.Flags().StringVar(&dockerfileIgnore, "ignore-file", "", "suppression rules to apply to the scan")
+	dockerfileCmd.Flags().BoolVar(&dockerfileNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	dockerfileCmd.Flags().StringVar(&dockerfileOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(dockerfileCmd)
+}
+
+/********** helpers **********/
+
+// scanBaseImages pulls each of the Dockerfile's base images once and scans
+// them as the image command does, each image's package databases and
+// lockfiles reported as projects named "image:/path".
+func scanBaseImages(cmd *cobra.Command, client *http.Client, result *scanner.DockerfileReport) *scanner.Report {
+	cfg := loadConfigFor(cmd, ".")
+	sc := &scanner.Scanner{}
+	var (
+		projects  []imageProject
+		queryable []scanner.Package
+		pulled    = map[string]bool{}
+	)
+	for _, b := range result.Images {
+		if b.Error != "" || pulled[b.Ref()] {
+			continue
+		}
+		pulled[b.Ref()] = true
+		logger.Info("📦 Pulling " + b.Image)
+		img, err := scanner.PullImage(client, b.Ref())
+		if err != nil {
+			logger.Warn("Skipping "+b.Image, "err", err)
+			continue
+		}
+		for _, p := range imageProjects(sc, img) {
+			p.path = b.Image + ":" + p.path
+			projects = append(projects, p)
+			queryable = append(queryable, p.deps...)
+		}
+	}
+	logger.Info(fmt.Sprintf("🔎 Scanning %d packages in %d project(s) from %d base image(s)", len(queryable), len(projects), len(pulled)))
+
+	var err error
+	sc.Source, err = sourceOptions{
+		noCache:     dockerfileNoCache,
+		concurrency: scanner.DefaultConcurrency,
+		rateLimit:   scanner.DefaultRateLimit,
+		cacheTTL:    scanner.DefaultCacheTTL,
+		osvURL:      dockerfileOSVURL,
+		client:      client,
+	}.open(queryable)
+	if err != nil {
+		fatal("Error opening OSV source", err)
+	}
+	rules, err := cfg.ignoreRules()
+	if err == nil && dockerfileIgnore != "" {
+		var fileRules []scanner.IgnoreRule
+		fileRules, err = scanner.LoadIgnoreFile(dockerfileIgnore, false)
+		rules = append(rules, fileRules...)
+	}
+	if err != nil {
+		fatal("Error reading ignore rules", err)
+	}
+
+	var reports []*scanner.Report
+	for _, p := range projects {
+		r, err := sc.Scan(cmd.Context(), p.path, p.kind, p.deps)
+		if err != nil {
+			fatal("OSV query failed", err)
+		}
+		scanner.ApplyIgnores(r, rules, time.Now())
+		reports = append(reports, r)
+	}
+	report := &scanner.Report{Source: result.Dockerfile, Lockfile: scanner.LockfileDockerfile, Scanned: len(queryable), Projects: reports}
+	report.Partial = report.Unfetched() > 0
+	if report.Partial {
+		logger.Warn(fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV; results are incomplete.", report.Unfetched()))
+	}
+	return report
+}
+
+func writeTextDockerfile(w io.Writer, r *scanner.DockerfileReport) {
+	fmt.Fprintf(w, "🐳 %s (%d base image(s))\n", r.Dockerfile, len(r.Images))
+	for _, b := range r.Images {
+		line := fmt.Sprintf("  line %d: %s", b.Line, b.Image)
+		if b.Stage != "" {
+			line += " AS " + b.Stage
+		}
+		if b.Platform != "" {
+			line += " (" + b.Platform + ")"
+		}
+		fmt.Fprintln(w, line)
+		if b.Latest {
+			fmt.Fprintln(w, "     ⚠️  :latest changes from one build to the next: pin a version tag or digest")
+		}
+		if b.Digest != "" && !b.Pinned {
+			fmt.Fprintf(w, "     📌 resolves to %s\n", b.Digest)
+		}
+		if b.NewerTag != "" {
+			fmt.Fprintf(w, "     ⬆️  newer release: %s\n", b.NewerTag)
+		}
+		if b.Error != "" {
+			fmt.Fprintf(w, "     ❌ %s\n", b.Error)
+		}
+	}
+	if len(r.Images) == 0 {
+		fmt.Fprintln(w, "  No base images other than scratch and its own stages.")
+	}
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// An exporter uploads scan results to a security platform. Each one
+// registers flags of its own on scan, named after it, and runs when
+// selected with --export.
+type exporter interface {
+	// addFlags registers the exporter's flags on cmd.
+	addFlags(cmd *cobra.Command)
+
+	// configure checks the exporter's flags before the scan starts.
+	configure() error
+
+	// export uploads r, returning a description of where it went.
+	export(client *http.Client, r *scanner.Report) (string, error)
+}
+
+// exporters are the platforms --export can name.
+var exporters = map[string]exporter{
+	"defectdojo": &defectDojoExporter{},
+}
+
+// exporterNames lists the names --export accepts, sorted.
+func exporterNames() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
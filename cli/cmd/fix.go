@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [project-dir | package-lock.json]",
+	Short: "Upgrade vulnerable npm packages to their fixed versions",
+	Long: `Scans an npm project's package-lock.json and rewrites it, and package.json
+where needed, so that every vulnerable package is bumped to the lowest version
+that fixes its known advisories.
+
+An upgrade is only made when every package that depends on the vulnerable one
+accepts the fixed version under its declared semver range. Direct dependencies
+whose range excludes the fix get their range in package.json raised; transitive
+ones are skipped with the parent that needs upgrading instead.
+
+Rewritten lockfile entries lose their "resolved" and "integrity" fields; run
+'npm install' afterwards to fill them in and update the fixed packages' own
+dependencies.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		target := "."
+		if len(args) == 1 {
+			target = filepath.Clean(args[0])
+		}
+		dir, lockPath := target, filepath.Join(target, "package-lock.json")
+		if !isDir(target) {
+			dir, lockPath = filepath.Dir(target), target
+		}
+		manifestPath := filepath.Join(dir, "package.json")
+		loadConfigFor(cmd, dir)
+
+		lockData, err := os.ReadFile(lockPath)
+		if err != nil {
+			fatal("Error reading lockfile", err)
+		}
+		kind, deps, err := scanner.ParseLockfile(lockPath, lockData)
+		if err == nil && kind != scanner.LockfileNpm {
+			err = fmt.Errorf("%s is a %s lockfile; keystone fix only supports package-lock.json", lockPath, kind)
+		}
+		if err != nil {
+			fatal("Error parsing lockfile", err)
+		}
+		var lock map[string]any
+		if err := json.Unmarshal(lockData, &lock); err != nil {
+			fatal("Error parsing lockfile", err)
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		sc := &scanner.Scanner{}
+		queryable := sc.Scannable(deps)
+		sc.Source, err = sourceOptions{
+			offline:     fixOffline,
+			noCache:     fixNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      fixOSVURL,
+			client:      client,
+		}.open(queryable)
+		if err != nil {
+			fatal("Error loading offline database", err)
+		}
+		report, err := sc.Scan(cmd.Context(), lockPath, string(kind), queryable)
+		if err != nil {
+			fatal("OSV query failed", err)
+		}
+		findings := report.Findings
+		if len(findings) == 0 {
+			fmt.Println("✅ No known vulnerabilities found; nothing to fix.")
+			return
+		}
+
+		fixes := planNpmFixes(lock, findings)
+		fmt.Printf("🔧 Upgrades for %s:\n", lockPath)
+		var chosen []npmFix
+		for _, f := range fixes {
+			if f.blocked != "" {
+				fmt.Printf("  ⏭️  %s %s: %s\n", f.name, f.from, f.blocked)
+				continue
+			}
+			fmt.Printf("  • %s %s → %s\n", f.name, f.from, f.to)
+			for _, r := range f.ranges {
+				fmt.Printf("      package.json %s: %q → %q\n", r.field, r.from, r.to)
+			}
+			if fixInteractive && !confirm("    Apply this upgrade? [y/N] ") {
+				continue
+			}
+			chosen = append(chosen, f)
+		}
+
+		if fixDryRun {
+			logger.Info("ℹ️  Dry run: no files were changed.")
+			return
+		}
+		if len(chosen) == 0 {
+			logger.Warn("No upgrades to apply.")
+			return
+		}
+
+		if err := applyNpmFixes(lockPath, lockData, manifestPath, chosen); err != nil {
+			fatal("Error applying upgrades", err)
+		}
+		fmt.Printf("✅ Applied %d upgrade(s). Run 'npm install' to refresh resolved URLs and integrity hashes.\n", len(chosen))
+	},
+}
+
+var (
+	fixDryRun      bool
+	fixInteractive bool
+	fixOffline     bool
+	fixNoCache     bool
+	fixOSVURL      string
+)
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+
+	fixCmd.Flags().BoolVar(&fixDryRun, "dry-run", false, "show the planned upgrades without changing any files")
+	fixCmd.Flags().BoolVarP(&fixInteractive, "interactive", "i", false, "ask before applying each upgrade")
+	fixCmd.Flags().BoolVar(&fixOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	fixCmd.Flags().StringVar(&fixOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	fixCmd.MarkFlagsMutuallyExclusive("offline", "osv-url")
+	fixCmd.Flags().BoolVar(&fixNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	addNetworkFlags(fixCmd)
+}
+
+/********** helpers **********/
+
+// npmFix upgrades every installed copy of one vulnerable package version.
+type npmFix struct {
+	name, from, to string
+	keys           []string    // package-lock "packages" keys to bump
+	alias          string      // a name the package is installed under instead
+	ranges         []rangeEdit // package.json ranges that must be raised
+	blocked        string      // why the upgrade can't be made, if it can't
+}
+
+// rangeEdit raises a direct dependency's declared range.
+type rangeEdit struct {
+	field    string // "dependencies", "devDependencies", ...
+	from, to string
+}
+
+// manifestDepFields are the package.json fields a project declares direct
+// dependencies in; only the root's devDependencies are ever installed.
+var manifestDepFields = []string{"dependencies", "devDependencies", "optionalDependencies"}
+
+// simpleRange matches ranges that are a version with at most a ^ or ~, whose
+// operator a raised range should keep.
+var simpleRange = regexp.MustCompile(`^([\^~]?)v?\d+(\.\d+){0,2}$`)
+
+// planNpmFixes works out, for each finding, which lockfile entries to bump and
+// whether every package depending on them accepts the fixed version.
+func planNpmFixes(lock map[string]any, findings []scanner.Finding) []npmFix {
+	packages, _ := lock["packages"].(map[string]any)
+
+	var fixes []npmFix
+	for _, f := range findings {
+		fix := npmFix{name: f.Package, from: f.Version, to: f.FixedIn}
+		for _, k := range sortedKeys(packages) {
+			e, _ := packages[k].(map[string]any)
+			if strings.Contains(k, "node_modules/") && scanner.NpmEntryName(k, e) == f.Package && e["version"] == f.Version {
+				fix.keys = append(fix.keys, k)
+				if alias := scanner.NpmKeyName(k); alias != f.Package {
+					fix.alias = alias
+				}
+			}
+		}
+		switch {
+		case unfetched(f) != "":
+			fix.blocked = "details of " + unfetched(f) + " couldn't be fetched, so its fix is unknown"
+		case fix.to == "":
+			fix.blocked = "no fixed version available"
+		case len(fix.keys) == 0:
+			fix.blocked = "not found in the lockfile's packages"
+		case fix.alias != "":
+			fix.blocked = fmt.Sprintf("installed as the alias %q; change its npm: spec instead", fix.alias)
+		default:
+			fix.blocked = checkRequirers(packages, &fix)
+		}
+		fixes = append(fixes, fix)
+	}
+	return fixes
+}
+
+// checkRequirers checks every package that resolves to one of fix's entries
+// against the fixed version, recording the package.json ranges to raise. It
+// returns why the fix is blocked, or "".
+func checkRequirers(packages map[string]any, fix *npmFix) string {
+	bumped := map[string]bool{}
+	for _, k := range fix.keys {
+		bumped[k] = true
+	}
+
+	for _, from := range sortedKeys(packages) {
+		e, _ := packages[from].(map[string]any)
+		fields := []string{"dependencies", "optionalDependencies", "peerDependencies"}
+		if from == "" {
+			fields = manifestDepFields
+		}
+		for _, field := range fields {
+			specs, _ := e[field].(map[string]any)
+			spec, ok := specs[fix.name].(string)
+			if !ok {
+				continue
+			}
+			if k, ok := scanner.NpmResolve(packages, from, fix.name); !ok || !bumped[k] {
+				continue
+			}
+			satisfied, err := scanner.NpmRangeSatisfies(spec, fix.to)
+			switch {
+			case err != nil:
+				return fmt.Sprintf("can't check %s against %s: %v", requirerName(from, e), fix.to, err)
+			case satisfied:
+				continue
+			case from != "":
+				return fmt.Sprintf("%s requires %q; upgrade it instead", requirerName(from, e), spec)
+			}
+			raised := "^" + fix.to
+			if m := simpleRange.FindStringSubmatch(spec); m != nil {
+				raised = m[1] + fix.to
+			}
+			fix.ranges = append(fix.ranges, rangeEdit{field: field, from: spec, to: raised})
+		}
+	}
+	return ""
+}
+
+// unfetched returns the first of f's advisories whose details are missing.
+func unfetched(f scanner.Finding) string {
+	for _, v := range f.Vulns {
+		if v.Error != "" {
+			return v.ID
+		}
+	}
+	return ""
+}
+
+func requirerName(key string, e map[string]any) string {
+	if key == "" {
+		return "package.json"
+	}
+	ver, _ := e["version"].(string)
+	return scanner.NpmEntryName(key, e) + "@" + ver
+}
+
+// applyNpmFixes rewrites the lockfile and, where ranges were raised, the
+// manifest, keeping their key order and indentation.
+func applyNpmFixes(lockPath string, lockData []byte, manifestPath string, fixes []npmFix) error {
+	lock, err := decodeOrderedJSON(lockData)
+	if err != nil {
+		return fmt.Errorf("%s: %w", lockPath, err)
+	}
+
+	var (
+		manifest     *jsonObject
+		manifestData []byte
+	)
+	for _, f := range fixes {
+		if len(f.ranges) > 0 && manifest == nil {
+			if manifestData, err = os.ReadFile(manifestPath); err != nil {
+				return err
+			}
+			if manifest, err = decodeOrderedJSON(manifestData); err != nil {
+				return fmt.Errorf("%s: %w", manifestPath, err)
+			}
+		}
+	}
+
+	packages := lock.object("packages")
+	for _, f := range fixes {
+		for _, k := range f.keys {
+			bumpLockEntry(packages.object(k), f.to)
+			bumpLockEntry(legacyLockEntry(lock, k), f.to)
+		}
+		for _, r := range f.ranges {
+			for _, obj := range []*jsonObject{manifest, packages.object("")} {
+				if specs := obj.object(r.field); specs != nil {
+					specs.set(f.name, r.to)
+				}
+			}
+		}
+	}
+
+	if err := writeJSONFile(lockPath, lock, lockData); err != nil {
+		return err
+	}
+	if manifest != nil {
+		return writeJSONFile(manifestPath, manifest, manifestData)
+	}
+	return nil
+}
+
+// bumpLockEntry sets an entry's version and drops the fields that described
+// the old tarball.
+func bumpLockEntry(e *jsonObject, version string) {
+	if e == nil {
+		return
+	}
+	e.set("version", version)
+	e.delete("resolved")
+	e.delete("integrity")
+}
+
+// legacyLockEntry finds the lockfile v1/v2 "dependencies" tree entry that
+// mirrors a "packages" key: "node_modules/a/node_modules/b" is
+// dependencies.a.dependencies.b.
+func legacyLockEntry(lock *jsonObject, key string) *jsonObject {
+	e := lock
+	for _, name := range strings.Split(strings.TrimPrefix(key, "node_modules/"), "/node_modules/") {
+		if e = e.object("dependencies"); e == nil {
+			return nil
+		}
+		if e = e.object(name); e == nil {
+			return nil
+		}
+	}
+	return e
+}
+
+func writeJSONFile(path string, o *jsonObject, original []byte) error {
+	var buf bytes.Buffer
+	if err := writeOrderedJSON(&buf, o, original); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// stdin is shared by every prompt so buffered answers aren't lost between them.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on stdin, defaulting to no.
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, _ := stdin.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
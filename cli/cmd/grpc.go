@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// The gRPC API, proto/keystone/v1/scanner.proto, answered over HTTP/2 by the
+// same server as the REST API.
+
+const grpcService = "/keystone.v1.Scanner/"
+
+// gRPC status codes.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcNotFound          = 5
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// grpcReportsKept is how many of the latest scans' reports GetReport can
+// stream.
+const grpcReportsKept = 100
+
+// grpcError is a failed call's status.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...any) error {
+	return &grpcError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// grpcReports keeps the reports of the latest scans by ID, for GetReport.
+type grpcReports struct {
+	mu   sync.Mutex
+	ids  []string // oldest first
+	byID map[string]*scanner.Report
+}
+
+func (g *grpcReports) add(r *scanner.Report) string {
+	var b [16]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.byID == nil {
+		g.byID = map[string]*scanner.Report{}
+	}
+	if len(g.ids) == grpcReportsKept {
+		delete(g.byID, g.ids[0])
+		g.ids = g.ids[1:]
+	}
+	g.ids = append(g.ids, id)
+	g.byID[id] = r
+	return id
+}
+
+func (g *grpcReports) get(id string) *scanner.Report {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.byID[id]
+}
+
+// handleGRPC answers a call of a method of the Scanner service.
+func (s *scanServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		httpError(w, http.StatusUnsupportedMediaType, "gRPC calls are POSTed as application/grpc")
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	err := s.callGRPC(w, r, strings.TrimPrefix(r.URL.Path, grpcService))
+	code, msg := grpcOK, ""
+	if err != nil {
+		var gerr *grpcError
+		if !errors.As(err, &gerr) {
+			gerr = &grpcError{code: grpcInternal, msg: err.Error()}
+		}
+		code, msg = gerr.code, gerr.msg
+		logger.Warn("gRPC call failed", "method", r.URL.Path, "code", code, "err", msg)
+	}
+	writeGRPCStatus(w, code, msg)
+}
+
+func (s *scanServer) callGRPC(w http.ResponseWriter, r *http.Request, method string) error {
+	if method != "ScanLockfile" && method != "ScanSBOM" && method != "GetReport" {
+		return grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path)
+	}
+	msg, err := readGRPCMessage(http.MaxBytesReader(w, r.Body, s.maxBody+5), s.maxBody)
+	if err != nil {
+		return err
+	}
+	fields, err := readProto(msg)
+	if err != nil {
+		return grpcErrorf(grpcInvalidArgument, "%v", err)
+	}
+
+	if method == "GetReport" {
+		var id string
+		for _, f := range fields {
+			if f.num == 1 {
+				id = string(f.bytes)
+			}
+		}
+		report := s.reports.get(id)
+		if report == nil {
+			return grpcErrorf(grpcNotFound, "no report %q: the service keeps the latest %d only", id, grpcReportsKept)
+		}
+		for _, f := range report.Findings {
+			var m protoWriter
+			encodeFinding(&m, f, report.Source)
+			if err := writeGRPCMessage(w, m.buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// ScanSBOMRequest's fields are ScanLockfileRequest's without the
+	// filename, so numbered one lower.
+	var (
+		name     string
+		data     []byte
+		prodOnly bool
+		failOn   string
+		sbom     = method == "ScanSBOM"
+		shift    = 0
+	)
+	if sbom {
+		name, shift = "sbom.json", 1
+	}
+	for _, f := range fields {
+		switch f.num + shift {
+		case 1:
+			name = string(f.bytes)
+		case 2:
+			data = f.bytes
+		case 3:
+			prodOnly = f.n != 0
+		case 4:
+			failOn = string(f.bytes)
+		}
+	}
+	if name == "" {
+		return grpcErrorf(grpcInvalidArgument, "say what the content is with filename")
+	}
+	if failOn != "" && !scanner.ValidFailOn(failOn) {
+		return grpcErrorf(grpcInvalidArgument, "unknown fail_on level %q (want one of: %s)", failOn, strings.Join(scanner.FailOnLevels, ", "))
+	}
+
+	report, status, err := s.scan(r.Context(), filepath.Base(name), data, sbom, prodOnly)
+	if err != nil {
+		code := grpcUnavailable
+		if status == http.StatusUnprocessableEntity {
+			code = grpcInvalidArgument
+		}
+		return grpcErrorf(code, "%v", err)
+	}
+
+	var m protoWriter
+	m.string(1, s.reports.add(report))
+	m.message(2, func(w *protoWriter) { encodeReport(w, report) })
+	m.bool(3, failsThreshold(report, failOn))
+	return writeGRPCMessage(w, m.buf)
+}
+
+/********** helpers **********/
+
+// writeGRPCStatus ends a call with its status, in trailers, or in the
+// headers of a call answered with nothing else.
+func writeGRPCStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", url.PathEscape(msg))
+	}
+}
+
+// readGRPCMessage reads the one length-prefixed message of a unary or
+// server-streaming call.
+func readGRPCMessage(r io.Reader, maxSize int64) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "reading the request: %v", err)
+	}
+	if prefix[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages aren't supported")
+	}
+	size := int64(binary.BigEndian.Uint32(prefix[1:]))
+	if size > maxSize {
+		return nil, grpcErrorf(grpcResourceExhausted, "message of %d bytes is larger than --max-body", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, grpcErrorf(grpcInvalidArgument, "reading the request: %v", err)
+	}
+	return msg, nil
+}
+
+// writeGRPCMessage writes msg, length-prefixed, and sends it on its way.
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(append(prefix[:], msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+func encodeReport(w *protoWriter, r *scanner.Report) {
+	w.string(1, r.Source)
+	w.string(2, r.Lockfile)
+	w.int(3, r.Scanned)
+	for _, f := range r.Findings {
+		w.message(4, func(m *protoWriter) { encodeFinding(m, f, "") })
+	}
+	w.bool(5, r.Partial)
+	w.stringIntMap(6, r.Unchecked)
+}
+
+func encodeFinding(w *protoWriter, f scanner.Finding, source string) {
+	w.string(1, f.Ecosystem)
+	w.string(2, f.Package)
+	w.string(3, f.Version)
+	w.bool(4, f.Dev)
+	for _, v := range f.Vulns {
+		w.message(5, func(m *protoWriter) {
+			m.string(1, v.ID)
+			m.string(2, v.Summary)
+			m.string(3, severityLabel(v))
+			m.double(4, v.Score)
+			m.string(5, v.CVSS)
+			m.strings(6, v.Aliases)
+			m.string(7, v.Fixed)
+			m.strings(8, v.References)
+			m.string(9, v.Error)
+		})
+	}
+	w.strings(6, f.Path)
+	w.string(7, f.FixedIn)
+	w.string(8, source)
+}
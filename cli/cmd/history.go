@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyFile   string
+	historySince  string
+	historySource string
+	historyOutput string
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show how findings changed across recorded scans",
+	Long: `Scans run with --history record their findings, per lockfile, in a history
+file under keystone's cache directory (or --history-file). This command reads
+it back: for each recorded lockfile, or only --source, it compares the latest
+scan with the last one before --since (7 days ago by default) and lists the
+findings that are new and those that were fixed in between.
+
+--since takes a date (2006-01-02) or a duration in days, hours or minutes
+(7d, 36h). 'keystone history vuln ID' shows when an advisory first appeared.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		entries := loadHistory()
+		since, err := scanner.ParseSince(historySince, time.Now())
+		if err != nil {
+			fatal("Error", err)
+		}
+		sources := scanner.HistorySources(entries)
+		if historySource != "" {
+			sources = []string{scanner.HistorySource(historySource)}
+		}
+
+		trends := []scanner.Trend{}
+		for _, s := range sources {
+			if t, ok := scanner.HistoryTrend(entries, s, since); ok {
+				trends = append(trends, t)
+			}
+		}
+		if historySource != "" && len(trends) == 0 {
+			fatalf("No recorded scans of %s", sources[0])
+		}
+
+		if historyOutput == outputJSON {
+			err = writeJSON(os.Stdout, trends)
+		} else {
+			writeTextTrends(os.Stdout, trends, since)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+	},
+}
+
+var historyVulnCmd = &cobra.Command{
+	Use:   "vuln advisory-id",
+	Short: "Show when an advisory first appeared in each recorded project",
+	Long: `Lists every package of every recorded lockfile that an advisory has affected,
+with the first and last scans it was found in and whether the latest scan
+still has it. The advisory can be given by its own ID or an alias, such as
+a CVE.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sightings := scanner.HistorySightings(loadHistory(), args[0])
+
+		var err error
+		if historyOutput == outputJSON {
+			err = writeJSON(os.Stdout, sightings)
+		} else if len(sightings) == 0 {
+			fmt.Printf("%s isn't in any recorded scan.\n", args[0])
+		} else {
+			for _, s := range sightings {
+				state := "fixed, last seen " + s.LastSeen.Local().Format(time.DateTime)
+				if s.Present {
+					state = "still present"
+				}
+				fmt.Printf("  • %s in %s — first seen %s, %s\n", s.Package, s.Source, s.FirstSeen.Local().Format(time.DateTime), state)
+			}
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyVulnCmd)
+
+	historyCmd.PersistentFlags().StringVar(&historyFile, "history-file", "", "history file to read (default: history.jsonl in keystone's cache directory)")
+	historyCmd.PersistentFlags().StringVarP(&historyOutput, "output", "o", outputText, "output format: text, json")
+	historyCmd.Flags().StringVar(&historySince, "since", "7d", "compare with the last scan before this date or this long ago")
+	historyCmd.Flags().StringVar(&historySource, "source", "", "only show this lockfile")
+}
+
+/********** helpers **********/
+
+// loadHistory reads --history-file or the default history file, exiting on
+// error.
+func loadHistory() []scanner.HistoryEntry {
+	if historyOutput != outputText && historyOutput != outputJSON {
+		usagef("Unknown output format %q (want one of: %s, %s)", historyOutput, outputText, outputJSON)
+	}
+	path := historyFile
+	if path == "" {
+		var err error
+		if path, err = scanner.HistoryPath(); err != nil {
+			fatal("Error locating history file", err)
+		}
+	}
+	entries, err := scanner.LoadHistory(path)
+	if err != nil {
+		fatal("Error reading history", err)
+	}
+	return entries
+}
+
+// recordHistory appends r to scan's history file. A history that can't be
+// written is logged rather than failing the scan.
+func recordHistory(r *scanner.Report) {
+	path := scanHistoryFile
+	if path == "" {
+		var err error
+		if path, err = scanner.HistoryPath(); err != nil {
+			logger.Warn("Error locating history file", "err", err)
+			return
+		}
+	}
+	if err := scanner.RecordHistory(path, r, time.Now()); err != nil {
+		logger.Warn("Error recording history", "err", err)
+		return
+	}
+	logger.Debug("Recorded scan history", "path", path)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeTextTrends(w io.Writer, trends []scanner.Trend, since time.Time) {
+	if len(trends) == 0 {
+		fmt.Fprintln(w, "No recorded scans. Run 'keystone scan --history' to record some.")
+		return
+	}
+	for _, t := range trends {
+		fmt.Fprintf(w, "📈 %s\n", filepath.Clean(t.Source))
+		if t.From == nil {
+			fmt.Fprintf(w, "  Scanned once, %s: %d finding(s).\n", t.To.Time.Local().Format(time.DateTime), len(t.To.Findings))
+			continue
+		}
+		when := "since " + t.From.Time.Local().Format(time.DateTime)
+		if t.From.Time.After(since) {
+			when += " (the first recorded scan)"
+		}
+		fmt.Fprintf(w, "  %d new, %d fixed %s; %d finding(s) as of %s.\n",
+			len(t.New), len(t.Fixed), when, len(t.To.Findings), t.To.Time.Local().Format(time.DateTime))
+		for _, f := range t.New {
+			fmt.Fprintf(w, "     + %s@%s %s\n", f.Package, f.Version, f.ID)
+		}
+		for _, f := range t.Fixed {
+			fmt.Fprintf(w, "     - %s@%s %s\n", f.Package, f.Version, f.ID)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// hookMarker identifies the git hooks keystone installed, so that it only
+// ever replaces or removes its own.
+const hookMarker = "# Installed by 'keystone hook install'"
+
+// hookTypes are the git hooks keystone can install.
+var hookTypes = []string{"pre-commit", "pre-push"}
+
+// gitZeroSHA stands for a missing commit in pre-push hook input.
+const gitZeroSHA = "0000000000000000000000000000000000000000"
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Check lockfile changes for vulnerabilities in git hooks",
+	Long: `Installs git hooks that check the lockfiles a commit or push changes, and
+stop it if the change introduces a vulnerability at or above --fail-on.
+
+The check is diff-aware, like 'keystone diff': each changed lockfile is
+compared with its previous version and only newly introduced advisories
+count, so existing findings don't block unrelated work. Lookups go through
+the OSV cache, so repeated checks are quick.
+
+A blocked commit or push can be let through once with --no-verify, or with
+KEYSTONE_SKIP=1 in the environment.`,
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the keystone git hooks in the current repository",
+	Long: `Installs a pre-commit hook (and with --pre-push, a pre-push hook) in the
+current repository's hooks directory, honouring core.hooksPath.
+
+The threshold is baked into the hook from --fail-on; without it, the hook
+uses fail-on from the project's keystone.yaml, or "high". An existing hook
+that keystone didn't install is left alone unless --force is given, in
+which case it is kept as <hook>.orig.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if hookFailOn != "" && !scanner.ValidFailOn(hookFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", hookFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		dir, err := gitHooksDir()
+		if err != nil {
+			fatal("Error locating the git hooks directory", err)
+		}
+		exe := "keystone"
+		if _, err := exec.LookPath(exe); err != nil {
+			if exe, err = os.Executable(); err != nil {
+				fatal("Error locating the keystone executable", err)
+			}
+		}
+
+		hooks := []string{"pre-commit"}
+		if hookPrePush {
+			hooks = append(hooks, "pre-push")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fatal("Error creating the git hooks directory", err)
+		}
+		for _, name := range hooks {
+			path := filepath.Join(dir, name)
+			if old, err := os.ReadFile(path); err == nil && !bytes.Contains(old, []byte(hookMarker)) {
+				if !hookForce {
+					fatalf("%s already exists and wasn't installed by keystone; use --force to replace it (it will be kept as %s.orig)", path, name)
+				}
+				if err := os.Rename(path, path+".orig"); err != nil {
+					fatal("Error keeping the existing hook", err)
+				}
+				logger.Warn(fmt.Sprintf("Moved the existing %s hook to %s.orig", name, path))
+			}
+			if err := os.WriteFile(path, []byte(hookScript(exe, name)), 0o755); err != nil {
+				fatal("Error writing hook", err)
+			}
+			logger.Info("🪝 Installed " + path)
+		}
+	},
+}
+
+var hookUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the keystone git hooks from the current repository",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := gitHooksDir()
+		if err != nil {
+			fatal("Error locating the git hooks directory", err)
+		}
+		removed := 0
+		for _, name := range hookTypes {
+			path := filepath.Join(dir, name)
+			data, err := os.ReadFile(path)
+			if err != nil || !bytes.Contains(data, []byte(hookMarker)) {
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				fatal("Error removing hook", err)
+			}
+			removed++
+			logger.Info("🗑️  Removed " + path)
+			if _, err := os.Stat(path + ".orig"); err == nil {
+				if err := os.Rename(path+".orig", path); err != nil {
+					fatal("Error restoring the previous hook", err)
+				}
+				logger.Info("Restored the previous " + name + " hook")
+			}
+		}
+		if removed == 0 {
+			logger.Warn("No keystone hooks installed in " + dir)
+		}
+	},
+}
+
+var hookRunCmd = &cobra.Command{
+	Use:    "run pre-commit|pre-push [remote]",
+	Short:  "Run the check a keystone git hook makes",
+	Hidden: true, // called by the installed hooks
+	Args:   cobra.RangeArgs(1, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		if os.Getenv("KEYSTONE_SKIP") != "" {
+			return
+		}
+		top, err := git("rev-parse", "--show-toplevel")
+		if err != nil {
+			fatal("Error locating the repository", err)
+		}
+		top = strings.TrimSpace(top)
+		cfg := loadConfigFor(cmd, top)
+		if hookFailOn == "" {
+			hookFailOn = "high"
+		}
+		if !scanner.ValidFailOn(hookFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", hookFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+
+		var changes []lockfileChange
+		switch args[0] {
+		case "pre-commit":
+			changes, err = stagedLockfileChanges()
+		case "pre-push":
+			changes, err = pushedLockfileChanges(args[1:])
+		default:
+			usagef("Unknown hook %q (want one of: %s)", args[0], strings.Join(hookTypes, ", "))
+		}
+		if err != nil {
+			fatal("Error listing changed lockfiles", err)
+		}
+		if len(changes) == 0 {
+			return
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		configRules, err := cfg.ignoreRules()
+		if err != nil {
+			fatal("Error reading config", err)
+		}
+
+		failing := 0
+		color := colorEnabled(os.Stdout)
+		for _, c := range changes {
+			sc := &scanner.Scanner{}
+			var (
+				kinds [2]string
+				deps  [2][]scanner.Package
+				all   []scanner.Package
+			)
+			for i, data := range [][]byte{c.before, c.after} {
+				if data == nil {
+					kinds[i] = c.kind
+					continue
+				}
+				kind, d, err := scanner.ParseLockfile(c.path, data)
+				if err != nil {
+					logger.Warn("Skipping "+c.path, "err", err)
+					continue
+				}
+				kinds[i], deps[i] = string(kind), sc.Scannable(d)
+				all = append(all, deps[i]...)
+			}
+			if sc.Source, err = (sourceOptions{
+				offline:     hookOffline,
+				concurrency: scanner.DefaultConcurrency,
+				rateLimit:   scanner.DefaultRateLimit,
+				cacheTTL:    scanner.DefaultCacheTTL,
+				osvURL:      hookOSVURL,
+				client:      client,
+			}).open(all); err != nil {
+				fatal("Error loading offline database", err)
+			}
+
+			fileRules, err := scanner.LoadIgnoreFile(filepath.Join(top, filepath.Dir(c.path), scanner.IgnoreFileName), true)
+			if err != nil {
+				fatal("Error reading ignore file", err)
+			}
+			rules := append(append([]scanner.IgnoreRule{}, configRules...), fileRules...)
+
+			var reports [2]*scanner.Report
+			for i, rev := range []string{c.beforeRev, c.afterRev} {
+				if reports[i], err = sc.Scan(cmd.Context(), rev+":"+c.path, kinds[i], deps[i]); err != nil {
+					fatal("OSV query failed", err)
+				}
+				scanner.ApplyIgnores(reports[i], rules, time.Now())
+			}
+			diff := scanner.DiffReports(reports[0], reports[1])
+			if len(diff.Introduced) == 0 {
+				continue
+			}
+			writeTextDiff(os.Stdout, diff, color)
+			failing += diff.Failing(hookFailOn)
+		}
+
+		if failing > 0 {
+			logger.Info(fmt.Sprintf("Upgrade the affected packages, ignore the advisories in %s, or bypass this check once with 'git %s --no-verify' or KEYSTONE_SKIP=1.",
+				scanner.IgnoreFileName, strings.TrimPrefix(args[0], "pre-")))
+			failf("%d introduced vulnerability(ies) at or above %s", failing, hookFailOn)
+		}
+	},
+}
+
+var (
+	hookFailOn  string
+	hookPrePush bool
+	hookForce   bool
+	hookOffline bool
+	hookOSVURL  string
+)
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookInstallCmd, hookUninstallCmd, hookRunCmd)
+
+	hookInstallCmd.Flags().StringVar(&hookFailOn, "fail-on", "", "block commits introducing a vulnerability at or above this severity: "+strings.Join(scanner.FailOnLevels, ", ")+" (default: high)")
+	hookInstallCmd.Flags().BoolVar(&hookPrePush, "pre-push", false, "also install a pre-push hook checking the commits being pushed")
+	hookInstallCmd.Flags().BoolVar(&hookForce, "force", false, "replace existing hooks not installed by keystone, keeping them as <hook>.orig")
+	hookInstallCmd.Flags().BoolVar(&hookOffline, "offline", false, "have the hooks match against the database from 'keystone db download'")
+	hookRunCmd.Flags().StringVar(&hookFailOn, "fail-on", "", "fail on introduced vulnerabilities at or above this severity (default: high)")
+	hookRunCmd.Flags().BoolVar(&hookOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	hookRunCmd.Flags().StringVar(&hookOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(hookRunCmd)
+}
+
+/********** helpers **********/
+
+// lockfileChange is a lockfile as it was before and after a commit or push;
+// before is nil for a new lockfile.
+type lockfileChange struct {
+	path                string // relative to the repository root
+	kind                string
+	beforeRev, afterRev string
+	before, after       []byte
+}
+
+// hookScript is the shell script installed as the named hook.
+func hookScript(exe, name string) string {
+	var flags string
+	if hookFailOn != "" {
+		flags += " --fail-on " + hookFailOn
+	}
+	if hookOffline {
+		flags += " --offline"
+	}
+	return fmt.Sprintf(`#!/bin/sh
+%s; remove with 'keystone hook uninstall'.
+# Bypass once with 'git %s --no-verify' or KEYSTONE_SKIP=1.
+[ -n "$KEYSTONE_SKIP" ] && exit 0
+exec %s hook run %s%s "$@"
+`, hookMarker, strings.TrimPrefix(name, "pre-"), shellQuote(exe), name, flags)
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// git runs a git command and returns its output.
+func git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	c := exec.Command("git", args...)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// gitHooksDir returns the current repository's hooks directory.
+func gitHooksDir() (string, error) {
+	out, err := git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(strings.TrimSpace(out))
+}
+
+// gitBlob returns the contents of path at rev ("" for the index), or nil if
+// it doesn't exist there.
+func gitBlob(rev, path string) []byte {
+	out, err := git("show", rev+":"+path)
+	if err != nil {
+		return nil
+	}
+	return []byte(out)
+}
+
+// stagedLockfileChanges returns the lockfiles changed in the index,
+// compared with HEAD.
+func stagedLockfileChanges() ([]lockfileChange, error) {
+	base := "HEAD"
+	if _, err := git("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		base = "" // the first commit: every lockfile is new
+	}
+	args := []string{"diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z"}
+	if base == "" {
+		args = []string{"ls-files", "--cached", "-z"}
+	}
+	out, err := git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var changes []lockfileChange
+	for _, path := range strings.Split(strings.TrimRight(out, "\x00"), "\x00") {
+		kind, ok := scanner.LockfileByName(path)
+		if path == "" || !ok {
+			continue
+		}
+		c := lockfileChange{path: path, kind: string(kind), beforeRev: "HEAD", afterRev: "index", after: gitBlob("", path)}
+		if base != "" {
+			c.before = gitBlob(base, path)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// pushedLockfileChanges returns the lockfiles changed by the commits being
+// pushed, read from pre-push's standard input: a "<local ref> <local sha>
+// <remote ref> <remote sha>" line per ref. A new branch is compared with
+// the remote's default branch, if known.
+func pushedLockfileChanges(args []string) ([]lockfileChange, error) {
+	remote := "origin"
+	if len(args) > 0 {
+		remote = args[0]
+	}
+	var changes []lockfileChange
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 4 || fields[1] == gitZeroSHA {
+			continue // malformed, or deleting a branch
+		}
+		local, base := fields[1], fields[3]
+		if base == gitZeroSHA {
+			out, err := git("merge-base", local, "refs/remotes/"+remote+"/HEAD")
+			if err != nil {
+				continue // nothing to compare a brand new history with
+			}
+			base = strings.TrimSpace(out)
+		}
+		out, err := git("diff", "--name-only", "--diff-filter=ACMR", "-z", base, local)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range strings.Split(strings.TrimRight(out, "\x00"), "\x00") {
+			kind, ok := scanner.LockfileByName(path)
+			if path == "" || !ok || seen[local+path] {
+				continue
+			}
+			seen[local+path] = true
+			changes = append(changes, lockfileChange{
+				path: path, kind: string(kind),
+				beforeRev: base[:min(len(base), 12)], afterRev: local[:min(len(local), 12)],
+				before: gitBlob(base, path), after: gitBlob(local, path),
+			})
+		}
+	}
+	return changes, sc.Err()
+}
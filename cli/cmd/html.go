@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// htmlRow is one vulnerability of one package in the findings table.
+type htmlRow struct {
+	Project, Package, Version, Path string
+	Dev                             bool
+	Vuln                            scanner.Vulnerability
+	Rank                            int // for sorting by severity; higher is worse
+}
+
+// htmlAdvice is the remediation for one vulnerable package.
+type htmlAdvice struct {
+	Package, Version, FixedIn, Via string
+	Vulns                          int
+}
+
+type htmlBar struct {
+	Severity string
+	Count    int
+	Percent  float64
+}
+
+type htmlReport struct {
+	Source         string
+	Generated      string
+	Scanned        int
+	Projects       int
+	Total          int
+	Suppressed     int
+	Unfetched      int
+	KnownExploited int
+	Bars           []htmlBar
+	Rows           []htmlRow
+	Remediation    []htmlAdvice
+	Licenses       []htmlLicense
+}
+
+// htmlLicense is one package whose license the policy doesn't permit.
+type htmlLicense struct {
+	Project string
+	scanner.LicenseViolation
+}
+
+// writeHTMLReport renders the report as a standalone HTML page: a severity
+// chart, a findings table that sorts by any column, and upgrade advice per
+// package. Everything is inline so the file can be attached as-is.
+func writeHTMLReport(w io.Writer, r *scanner.Report) error {
+	data := htmlReport{
+		Source:         r.Source,
+		Generated:      time.Now().UTC().Format("2006-01-02 15:04 MST"),
+		Scanned:        r.Scanned,
+		Projects:       len(r.Reports()),
+		Total:          r.VulnCount(),
+		Unfetched:      r.Unfetched(),
+		KnownExploited: r.KnownExploited(),
+	}
+
+	counts := map[string]int{}
+	for _, p := range r.Reports() {
+		data.Suppressed += len(p.Suppressed)
+		for _, l := range p.LicenseViolations {
+			data.Licenses = append(data.Licenses, htmlLicense{Project: p.Source, LicenseViolation: l})
+		}
+		for _, f := range p.Findings {
+			path := ""
+			if len(f.Path) > 0 {
+				path = strings.Join(f.Path, " → ")
+			}
+			for _, v := range f.Vulns {
+				sev := severityLabel(v)
+				counts[sev]++
+				data.Rows = append(data.Rows, htmlRow{
+					Project: p.Source, Package: f.Package, Version: f.Version, Path: path,
+					Dev: f.Dev, Vuln: v, Rank: len(scanner.SeverityOrder) - severityIndex(sev),
+				})
+			}
+
+			advice := htmlAdvice{Package: f.Package, Version: f.Version, FixedIn: f.FixedIn, Vulns: len(f.Vulns)}
+			if len(f.Path) > 1 {
+				advice.Via = f.Path[0]
+			}
+			data.Remediation = append(data.Remediation, advice)
+		}
+	}
+
+	for _, sev := range scanner.SeverityOrder {
+		bar := htmlBar{Severity: sev, Count: counts[sev]}
+		if data.Total > 0 {
+			bar.Percent = 100 * float64(bar.Count) / float64(data.Total)
+		}
+		data.Bars = append(data.Bars, bar)
+	}
+
+	return htmlTemplate.Execute(w, data)
+}
+
+// severityIndex is sev's position in SeverityOrder, most severe first.
+func severityIndex(sev string) int {
+	for i, s := range scanner.SeverityOrder {
+		if s == sev {
+			return i
+		}
+	}
+	return len(scanner.SeverityOrder) - 1
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"first": firstLine,
+	"epss":  formatEPSS,
+	"score": func(s float64) string {
+		if s == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f", s)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>keystone report: {{.Source}}</title>
+<style>
+body { font: 14px/1.45 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; margin: 2em auto; max-width: 1200px; padding: 0 1em; }
+h1 { font-size: 1.6em; margin-bottom: .2em; }
+h2 { font-size: 1.2em; margin-top: 2em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+.meta { color: #59636e; }
+.warn { background: #fff8c5; border: 1px solid #d4a72c; padding: .6em 1em; border-radius: 6px; }
+.chart { display: grid; grid-template-columns: 7em 1fr 3em; gap: .4em .8em; align-items: center; max-width: 640px; }
+.bar { height: 1.1em; border-radius: 3px; min-width: 2px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: .4em .6em; border-bottom: 1px solid #d0d7de; vertical-align: top; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; white-space: nowrap; }
+th.sorted-asc::after { content: " ▲"; }
+th.sorted-desc::after { content: " ▼"; }
+.sev { font-weight: 600; padding: .1em .5em; border-radius: 1em; color: #fff; white-space: nowrap; }
+.critical { background: #a40e26; } .high { background: #d1242f; } .medium { background: #bf8700; }
+.low { background: #0969da; } .unknown { background: #6e7781; }
+.path, .dev { color: #59636e; font-size: .9em; }
+.kev { color: #a40e26; font-weight: 600; font-size: .85em; white-space: nowrap; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+</style>
+</head>
+<body>
+<h1>Vulnerability report</h1>
+<p class="meta"><code>{{.Source}}</code> · {{.Scanned}} packages in {{.Projects}} lockfile(s) · generated {{.Generated}} by keystone</p>
+{{if .KnownExploited}}<p class="warn">{{.KnownExploited}} vulnerability(ies) are in CISA's Known Exploited Vulnerabilities catalog; remediate these first.</p>{{end}}
+{{if .Unfetched}}<p class="warn">Details of {{.Unfetched}} advisory(ies) couldn't be fetched from OSV; these results are incomplete.</p>{{end}}
+
+<h2>Summary</h2>
+{{if .Total}}
+<div class="chart">
+{{range .Bars}}<span>{{.Severity}}</span><div><div class="bar {{lower .Severity}}" style="width: {{printf "%.1f" .Percent}}%"></div></div><span>{{.Count}}</span>
+{{end}}</div>
+{{else}}<p>No known vulnerabilities found (per OSV).</p>{{end}}
+{{if .Suppressed}}<p class="meta">{{.Suppressed}} finding(s) suppressed by ignore rules.</p>{{end}}
+
+{{if .Rows}}
+<h2>Findings</h2>
+<table id="findings">
+<thead><tr><th data-type="num">Severity</th><th data-type="num">CVSS</th><th data-type="num">EPSS</th><th>Advisory</th><th>Package</th><th>Version</th><th>Fixed in</th><th>Lockfile</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr>
+<td data-sort="{{.Rank}}"><span class="sev {{lower (or .Vuln.Severity "unknown")}}">{{or .Vuln.Severity "UNKNOWN"}}</span>{{if .Vuln.KEV}}<br><span class="kev" title="Listed in CISA's Known Exploited Vulnerabilities catalog on {{.Vuln.KEV.DateAdded}}">known exploited</span>{{end}}</td>
+<td data-sort="{{.Vuln.Score}}"{{if .Vuln.CVSS}} title="{{.Vuln.CVSS}}"{{end}}>{{score .Vuln.Score}}{{if .Vuln.Environmental}}<br><span class="path" title="{{.Vuln.Environmental.Vector}}">{{score .Vuln.Environmental.Score}} in context</span>{{end}}</td>
+<td data-sort="{{if .Vuln.EPSS}}{{.Vuln.EPSS.Score}}{{else}}-1{{end}}">{{if .Vuln.EPSS}}{{epss .Vuln.EPSS.Score}}{{end}}</td>
+<td><a href="https://osv.dev/vulnerability/{{.Vuln.ID}}">{{.Vuln.ID}}</a><br>{{if .Vuln.Error}}<span class="path">details unavailable: {{.Vuln.Error}}</span>{{else}}{{first .Vuln.Summary}}{{end}}</td>
+<td><code>{{.Package}}</code>{{if .Dev}} <span class="dev">(dev)</span>{{end}}{{if .Path}}<br><span class="path">{{.Path}}</span>{{end}}</td>
+<td><code>{{.Version}}</code></td>
+<td>{{if .Vuln.Fixed}}<code>{{.Vuln.Fixed}}</code>{{else}}<span class="path">no fix</span>{{end}}</td>
+<td><code>{{.Project}}</code></td>
+</tr>
+{{end}}</tbody>
+</table>
+
+<h2>Remediation</h2>
+<ul>
+{{range .Remediation}}<li>{{if .FixedIn}}Upgrade <code>{{.Package}}</code> from <code>{{.Version}}</code> to <code>{{.FixedIn}}</code> or later{{else}}No fixed version of <code>{{.Package}}</code> is known; consider replacing it or accepting the risk with an ignore rule{{end}} ({{.Vulns}} advisory(ies)).{{if .Via}} It is pulled in through <code>{{.Via}}</code>, which may need upgrading first.{{end}}</li>
+{{end}}</ul>
+{{end}}
+
+{{if .Licenses}}
+<h2>License policy</h2>
+<p>{{len .Licenses}} package(s) have licenses the policy doesn't permit.</p>
+<table>
+<thead><tr><th>Package</th><th>Version</th><th>License</th><th>Lockfile</th></tr></thead>
+<tbody>
+{{range .Licenses}}<tr><td><code>{{.Package}}</code>{{if .Dev}} <span class="dev">(dev)</span>{{end}}</td><td><code>{{.Version}}</code></td><td>{{.License}}</td><td><code>{{.Project}}</code></td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+<script>
+document.querySelectorAll("#findings th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var tbody = th.closest("table").tBodies[0];
+    var asc = !th.classList.contains("sorted-asc");
+    th.parentNode.querySelectorAll("th").forEach(function (h) { h.classList.remove("sorted-asc", "sorted-desc"); });
+    th.classList.add(asc ? "sorted-asc" : "sorted-desc");
+    var key = function (row) {
+      var cell = row.cells[col];
+      var v = cell.dataset.sort !== undefined ? cell.dataset.sort : cell.textContent.trim();
+      return th.dataset.type === "num" ? parseFloat(v) || 0 : v.toLowerCase();
+    };
+    Array.from(tbody.rows)
+      .sort(function (a, b) { var x = key(a), y = key(b); return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1); })
+      .forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image image-ref | image.tar | rootfs-dir",
+	Short: "Scan a container image's OS packages and lockfiles for vulnerabilities",
+	Long: `Scans a container image: the packages installed by its OS package manager
+(Alpine apk, dpkg on Debian and Ubuntu, or RPM on AlmaLinux, Rocky Linux and
+openSUSE Tumbleweed) and every supported lockfile in its filesystem, each
+reported as a project of its own.
+
+The image is pulled from its registry (anonymously; registries on localhost
+over plain HTTP), or read from a tar archive written by 'docker save' or
+holding an OCI image layout. Only linux/amd64 is scanned from multi-platform
+images. Only the SQLite RPM database of RHEL 9 and later distributions can
+be read; with older ones, only lockfiles are scanned.
+
+Given a directory instead, the OS packages of the root filesystem in it are
+scanned, such as a virtual machine's disk mounted read-only or a base image
+extracted without container tooling; "/" scans the running system. Its
+lockfiles are left to 'keystone scan'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ref := args[0]
+		cfg := loadConfigFor(cmd, ".")
+
+		if !validOutputFormat(imageOutput) {
+			usagef("Unknown output format %q (want one of: %s)", imageOutput, strings.Join(allOutputFormats(), ", "))
+		}
+		if imageFailOn != "" && !scanner.ValidFailOn(imageFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", imageFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+
+		var img *scanner.Image
+		if fi, statErr := os.Stat(ref); statErr == nil && fi.IsDir() {
+			img, err = scanner.ReadRootFS(ref)
+		} else if statErr == nil {
+			img, err = scanner.ReadImageArchive(ref)
+		} else {
+			logger.Info("📦 Pulling " + ref)
+			img, err = scanner.PullImage(client, ref)
+		}
+		if err != nil {
+			fatal("Error reading image", err)
+		}
+
+		sc := &scanner.Scanner{}
+		projects := imageProjects(sc, img)
+		var queryable []scanner.Package
+		for _, p := range projects {
+			queryable = append(queryable, p.deps...)
+		}
+		if len(projects) == 0 {
+			logger.Warn(fmt.Sprintf("No OS packages or supported lockfiles found in %s.", ref))
+			return
+		}
+		logger.Info(fmt.Sprintf("🔎 Scanning %d packages in %d project(s) from image: %s", len(queryable), len(projects), ref))
+
+		sc.Source, err = sourceOptions{
+			noCache:     imageNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      imageOSVURL,
+			client:      client,
+		}.open(queryable)
+		if err != nil {
+			fatal("Error opening OSV source", err)
+		}
+
+		rules, err := cfg.ignoreRules()
+		if err == nil && imageIgnoreFile != "" {
+			var fileRules []scanner.IgnoreRule
+			fileRules, err = scanner.LoadIgnoreFile(imageIgnoreFile, false)
+			rules = append(rules, fileRules...)
+		}
+		if err != nil {
+			fatal("Error reading ignore rules", err)
+		}
+
+		report, err := scanImageProjects(cmd.Context(), sc, ref, projects, rules)
+		if err != nil {
+			fatal("OSV query failed", err)
+		}
+
+		if err := writeReport(os.Stdout, report, imageOutput); err != nil {
+			fatal("Error writing report", err)
+		}
+		if report.Partial {
+			logger.Warn(fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV; results are incomplete.", report.Unfetched()))
+		}
+		if imageOutput != outputText && imageOutput != outputMarkdown {
+			unchecked := report.UncheckedByEcosystem()
+			for _, eco := range sortedKeys(unchecked) {
+				logger.Warn(uncheckedMessage(eco, unchecked[eco]))
+			}
+		}
+		if imageFailOn != "" {
+			if n := report.Failing(imageFailOn); n > 0 {
+				failf("%d vulnerability(ies) at or above --fail-on=%s", n, imageFailOn)
+			}
+			if report.Partial {
+				fatalf("Can't confirm nothing is at or above --fail-on=%s with incomplete results", imageFailOn)
+			}
+		}
+		if imageErrorOnIncomplete && report.Partial {
+			fatalf("Results are incomplete (see the warnings above), which --error-on-incomplete fails")
+		}
+	},
+}
+
+var (
+	imageOutput     string
+	imageFailOn     string
+	imageIgnoreFile string
+	imageNoCache    bool
+	imageOSVURL     string
+
+	imageErrorOnIncomplete bool
+)
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+
+	imageCmd.Flags().StringVarP(&imageOutput, "output", "o", outputText, "output format: "+strings.Join(outputFormats, ", "))
+	imageCmd.Flags().StringVar(&imageFailOn, "fail-on", "", "exit non-zero if any finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	imageCmd.Flags().BoolVar(&imageErrorOnIncomplete, "error-on-incomplete", false, "exit 3 if any advisory's details couldn't be fetched, even with nothing failing")
+	imageCmd.Flags().StringVar(&imageIgnoreFile, "ignore-file", "", "suppression rules to apply")
+	imageCmd.Flags().BoolVar(&imageNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	imageCmd.Flags().StringVar(&imageOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(imageCmd)
+}
+
+/********** helpers **********/
+
+// imageProject is a package database or lockfile found in an image.
+type imageProject struct {
+	path, kind string
+	deps       []scanner.Package
+}
+
+// imageProjects lists the packages of img's OS package manager and of each
+// of its lockfiles, warning about those that can't be read.
+func imageProjects(sc *scanner.Scanner, img *scanner.Image) []imageProject {
+	var projects []imageProject
+	db, osPkgs, err := img.OSPackages()
+	if err != nil {
+		logger.Warn(err.Error())
+	}
+	if db != "" {
+		kind := "dpkg"
+		switch {
+		case strings.Contains(db, "/apk/"):
+			kind = "apk"
+		case strings.Contains(db, "/rpm/"):
+			kind = "rpm"
+		}
+		projects = append(projects, imageProject{db, kind, sc.Scannable(osPkgs)})
+	}
+	for _, name := range img.Lockfiles() {
+		kind, deps, err := scanner.ParseLockfile(name, img.Files[name])
+		if err != nil {
+			logger.Warn("Skipping /"+name, "err", err)
+			continue
+		}
+		projects = append(projects, imageProject{"/" + name, string(kind), sc.Scannable(deps)})
+	}
+	return projects
+}
+
+// scanImageProjects scans the projects of the image ref, applying rules, into
+// one report with a project each.
+func scanImageProjects(ctx context.Context, sc *scanner.Scanner, ref string, projects []imageProject, rules []scanner.IgnoreRule) (*scanner.Report, error) {
+	report := &scanner.Report{Source: ref, Lockfile: scanner.LockfileImage}
+	for _, p := range projects {
+		r, err := sc.Scan(ctx, p.path, p.kind, p.deps)
+		if err != nil {
+			return nil, err
+		}
+		scanner.ApplyIgnores(r, rules, time.Now())
+		report.Projects = append(report.Projects, r)
+		report.Scanned += len(p.deps)
+	}
+	report.Partial = report.Unfetched() > 0
+	return report, nil
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// jiraLabel marks every issue keystone opens, so they can be searched for.
+const jiraLabel = "keystone"
+
+// jiraSearchPage is how many issues each search request asks for.
+const jiraSearchPage = 100
+
+// jiraClient talks to the REST API (v2) of Jira Cloud or Data Center.
+type jiraClient struct {
+	http    *http.Client
+	baseURL string
+	user    string // with token, basic auth as on Jira Cloud
+	token   string // alone, a personal access token as on Data Center
+	project string
+	kind    string // issue type
+}
+
+// newJiraClient builds a client from the --jira flags, taking the
+// credentials not given there from $JIRA_USER and $JIRA_API_TOKEN. Its
+// HTTP client is set once the network flags have been read.
+func newJiraClient() (*jiraClient, error) {
+	j := &jiraClient{
+		baseURL: strings.TrimSuffix(scanJiraURL, "/"),
+		user:    scanJiraUser,
+		token:   scanJiraToken,
+		project: scanJiraProject,
+		kind:    scanJiraIssueType,
+	}
+	if j.user == "" {
+		j.user = os.Getenv("JIRA_USER")
+	}
+	if j.token == "" {
+		j.token = os.Getenv("JIRA_API_TOKEN")
+	}
+	if j.project == "" {
+		return nil, fmt.Errorf("--jira-url needs --jira-project")
+	}
+	if j.token == "" {
+		return nil, fmt.Errorf("--jira-url needs an API token, from --jira-token or $JIRA_API_TOKEN")
+	}
+	return j, nil
+}
+
+// jiraIssueLabel identifies the issue for one advisory of one package, so
+// later scans find it instead of opening another. Like a baseline, it
+// leaves out the version: moving to another affected version isn't new.
+func jiraIssueLabel(f scanner.Finding, v scanner.Vulnerability) string {
+	sum := sha256.Sum256([]byte(f.Ecosystem + "/" + f.Package + "/" + v.ID))
+	return jiraLabel + "-" + hex.EncodeToString(sum[:6])
+}
+
+// openJiraIssues opens an issue for every advisory at or above level that
+// the project has no issue for yet, whatever that issue's status: a closed
+// one means someone already dealt with it. It returns how many it opened
+// and how many were already tracked.
+func (j *jiraClient) openJiraIssues(r *scanner.Report, level string) (opened, tracked int, err error) {
+	existing, err := j.existingLabels()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if !scanner.MeetsThreshold(severityLabel(v), level) {
+					continue
+				}
+				label := jiraIssueLabel(f, v)
+				if existing[label] {
+					tracked++
+					continue
+				}
+				if err := j.createIssue(p, f, v, label); err != nil {
+					return opened, tracked, err
+				}
+				existing[label] = true
+				opened++
+			}
+		}
+	}
+	return opened, tracked, nil
+}
+
+// existingLabels returns the labels of the project's keystone issues.
+func (j *jiraClient) existingLabels() (map[string]bool, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %q", j.project, jiraLabel)
+	out := map[string]bool{}
+	// Jira may return fewer issues per page than asked for.
+	for start := 0; ; {
+		q := url.Values{"jql": {jql}, "fields": {"labels"}, "startAt": {fmt.Sprint(start)}, "maxResults": {fmt.Sprint(jiraSearchPage)}}
+		var page struct {
+			Total  int `json:"total"`
+			Issues []struct {
+				Fields struct {
+					Labels []string `json:"labels"`
+				} `json:"fields"`
+			} `json:"issues"`
+		}
+		if err := j.do(http.MethodGet, "/rest/api/2/search?"+q.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			for _, l := range issue.Fields.Labels {
+				out[l] = true
+			}
+		}
+		start += len(page.Issues)
+		if len(page.Issues) == 0 || start >= page.Total {
+			return out, nil
+		}
+	}
+}
+
+// createIssue opens the issue for advisory v of finding f in project p.
+func (j *jiraClient) createIssue(p *scanner.Report, f scanner.Finding, v scanner.Vulnerability, label string) error {
+	sev := severityLabel(v)
+	summary := fmt.Sprintf("%s@%s: %s [%s]", f.Package, f.Version, advisoryName(v), sev)
+	if s := firstLine(v.Summary); s != "" {
+		summary += " " + s
+	}
+	if r := []rune(summary); len(r) > 250 { // Jira's limit is 255
+		summary = string(r[:250]) + "…"
+	}
+
+	var d strings.Builder
+	fmt.Fprintf(&d, "keystone found *%s* affecting *%s@%s* (%s) in {{%s}}.\n\n", v.ID, f.Package, f.Version, f.Ecosystem, p.Source)
+	fmt.Fprintf(&d, "* Severity: %s", sev)
+	if v.Score > 0 {
+		fmt.Fprintf(&d, " (CVSS %.1f)", v.Score)
+	}
+	d.WriteString("\n")
+	if v.Fixed != "" {
+		fmt.Fprintf(&d, "* Fixed in: %s\n", v.Fixed)
+	} else {
+		d.WriteString("* Fixed in: no fix available\n")
+	}
+	if len(v.Aliases) > 0 {
+		fmt.Fprintf(&d, "* Aliases: %s\n", strings.Join(v.Aliases, ", "))
+	}
+	if len(f.Path) > 0 {
+		fmt.Fprintf(&d, "* Pulled in by: %s\n", strings.Join(f.Path, " → "))
+	}
+	fmt.Fprintf(&d, "* Advisory: https://osv.dev/vulnerability/%s\n", v.ID)
+	if s := strings.TrimSpace(v.Summary); s != "" {
+		fmt.Fprintf(&d, "\n%s\n", s)
+	}
+
+	body := map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": j.project},
+		"issuetype":   map[string]string{"name": j.kind},
+		"summary":     summary,
+		"description": d.String(),
+		"labels":      []string{jiraLabel, label},
+	}}
+	return j.do(http.MethodPost, "/rest/api/2/issue", body, nil)
+}
+
+// do sends a request to the Jira API and decodes the JSON response into out.
+func (j *jiraClient) do(method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, j.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if j.user != "" {
+		req.SetBasicAuth(j.user, j.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.token)
+	}
+
+	resp, err := j.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("Jira request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("Jira returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
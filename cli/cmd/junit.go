@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport renders the report as JUnit XML for CI test-report views:
+// each lockfile is a suite and each scanned package a test case, failing when
+// the package has vulnerabilities or a disallowed license, and skipped when
+// its vulnerabilities were all suppressed.
+func writeJUnitReport(w io.Writer, r *scanner.Report) error {
+	out := junitTestSuites{Name: "keystone"}
+	for _, p := range r.Reports() {
+		suite := junitTestSuite{Name: p.Source}
+
+		findings := map[string]scanner.Finding{}
+		for _, f := range p.Findings {
+			findings[f.Ecosystem+"\x00"+f.Package+"@"+f.Version] = f
+		}
+		suppressed := map[string][]string{}
+		for _, s := range p.Suppressed {
+			key := s.Ecosystem + "\x00" + s.Package + "@" + s.Version
+			suppressed[key] = append(suppressed[key], s.ID)
+		}
+
+		licenses := map[string]scanner.LicenseViolation{}
+		for _, l := range p.LicenseViolations {
+			licenses[l.Ecosystem+"\x00"+l.Package+"@"+l.Version] = l
+		}
+
+		seen := map[string]bool{}
+		for _, d := range p.Packages {
+			key := d.Ecosystem + "\x00" + d.Name + "@" + d.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			tc := junitTestCase{ClassName: d.Ecosystem, Name: d.Name + "@" + d.Version}
+			if f, ok := findings[key]; ok {
+				tc.Failure = newJUnitFailure(f)
+				suite.Failures++
+			} else if l, ok := licenses[key]; ok {
+				tc.Failure = &junitFailure{Message: "license " + l.License + " not permitted by policy", Type: "license", Text: l.License}
+				suite.Failures++
+			} else if ids := suppressed[key]; len(ids) > 0 {
+				tc.Skipped = &junitSkipped{Message: "suppressed: " + strings.Join(ids, ", ")}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+
+		out.Tests += suite.Tests
+		out.Failures += suite.Failures
+		out.Skipped += suite.Skipped
+		out.Suites = append(out.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newJUnitFailure describes a vulnerable package, typed by its worst
+// severity and listing each advisory in the body.
+func newJUnitFailure(f scanner.Finding) *junitFailure {
+	worst := scanner.SeverityUnknown
+	var lines []string
+	for _, v := range f.Vulns {
+		if v.Severity != "" && severityIndex(v.Severity) < severityIndex(worst) {
+			worst = v.Severity
+		}
+		line := fmt.Sprintf("%s [%s] %s", v.ID, v.Severity, firstLine(v.Summary))
+		switch {
+		case v.Error != "":
+			line = fmt.Sprintf("%s: fetching details failed: %s", v.ID, v.Error)
+		case v.Fixed != "":
+			line += " (fixed in " + v.Fixed + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(f.Path) > 0 {
+		lines = append(lines, "Introduced via "+strings.Join(f.Path, " → "))
+	}
+
+	msg := fmt.Sprintf("%d vulnerability(ies), worst %s", len(f.Vulns), worst)
+	for _, v := range f.Vulns {
+		if v.KEV != nil {
+			msg += ", known exploited"
+			break
+		}
+	}
+	if f.FixedIn != "" {
+		msg += "; upgrade to " + f.FixedIn
+	}
+	return &junitFailure{Message: msg, Type: worst, Text: strings.Join(lines, "\n")}
+}
@@ -0,0 +1,427 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var k8sCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "Work with Kubernetes clusters",
+}
+
+var k8sScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scan the images running in a Kubernetes cluster",
+	Long: `Lists the Pods of a cluster, or of one --namespace, and scans each image
+they run once, as 'keystone image' does, however many Pods and namespaces
+run it, then reports per namespace: the images and packages scanned in it
+and its vulnerabilities by severity, the totals and the advisories found in
+the most namespaces (--top), then each image with its findings and where it
+runs.
+
+Pods are listed with kubectl, which must be on the PATH (or at --kubectl),
+so the cluster is reached as kubectl reaches it: from --kubeconfig or
+$KUBECONFIG, with --context or the current one, or from inside the cluster
+with the Pod's service account, which needs to list Pods. An image is
+scanned at the digest its containers run, when their status has it, rather
+than whatever its tag points at now. Images are pulled anonymously, so
+private registries can't be scanned yet; such images are skipped with a
+warning.
+
+  keystone k8s scan --context prod --fail-on critical
+  keystone k8s scan -n payments -o json > payments.json
+
+-o json writes the summary and the images as JSON; sarif, html, junit and
+markdown instead render every finding, with each lockfile's source
+prefixed by its namespace and image.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFor(cmd, ".")
+		if !validOutputFormat(k8sOutput) {
+			usagef("Unknown output format %q (want one of: %s)", k8sOutput, strings.Join(allOutputFormats(), ", "))
+		}
+		if k8sFailOn != "" && !scanner.ValidFailOn(k8sFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", k8sFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		if k8sParallel < 1 {
+			usagef("--parallel must be at least 1, not %d", k8sParallel)
+		}
+		rules, err := cfg.ignoreRules()
+		if err == nil && k8sIgnoreFile != "" {
+			var fileRules []scanner.IgnoreRule
+			fileRules, err = scanner.LoadIgnoreFile(k8sIgnoreFile, false)
+			rules = append(rules, fileRules...)
+		}
+		if err != nil {
+			fatal("Error reading ignore rules", err)
+		}
+
+		pods, err := listPods(cmd.Context())
+		if err != nil {
+			fatal("Error listing Pods", err)
+		}
+		images := clusterImages(pods)
+		if len(images) == 0 {
+			logger.Warn("No Pods found.")
+			return
+		}
+		logger.Info(fmt.Sprintf("☸️  Found %d image(s) in %d Pod(s)", len(images), len(pods)))
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		source, err := sourceOptions{
+			noCache:     k8sNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      k8sOSVURL,
+			client:      client,
+		}.open(nil)
+		if err != nil {
+			fatal("Error opening OSV source", err)
+		}
+
+		scanner.RunPool(k8sParallel, len(images), func(i int) {
+			img := images[i]
+			logger.Info("📦 Pulling " + img.ref)
+			if img.report, img.err = scanClusterImage(cmd.Context(), client, source, img.ref, rules); img.err != nil {
+				logger.Warn(fmt.Sprintf("Skipping an image: %v", img.err))
+			}
+		})
+
+		namespaces, reports := namespaceReports(images)
+		switch k8sOutput {
+		case outputText:
+			writeTextSummary(os.Stdout, scanner.SummarizeReports(namespaces, reports), k8sTop)
+			writeClusterImages(os.Stdout, images)
+		case outputJSON:
+			err = writeJSON(os.Stdout, clusterSummary{
+				Namespaces: scanner.SummarizeReports(namespaces, reports),
+				Images:     images,
+			})
+		default:
+			err = writeReport(os.Stdout, scanner.MergeReports(namespaces, reports), k8sOutput)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		failing, unscanned, partial := 0, 0, false
+		for _, img := range images {
+			if img.err != nil {
+				unscanned++
+				continue
+			}
+			partial = partial || img.report.Partial
+			if k8sFailOn != "" {
+				failing += img.report.Failing(k8sFailOn)
+			}
+		}
+		if unscanned > 0 {
+			logger.Warn(fmt.Sprintf("%d of %d image(s) couldn't be scanned; results are incomplete.", unscanned, len(images)))
+		}
+		if partial {
+			logger.Warn("Details of some advisories couldn't be fetched from OSV; results are incomplete.")
+		}
+		if k8sFailOn != "" {
+			if failing > 0 {
+				failf("%d vulnerability(ies) at or above --fail-on=%s", failing, k8sFailOn)
+			}
+			if unscanned > 0 || partial {
+				fatalf("Can't confirm nothing is at or above --fail-on=%s with incomplete results", k8sFailOn)
+			}
+		}
+	},
+}
+
+var (
+	k8sKubeconfig string
+	k8sContext    string
+	k8sNamespace  string
+	k8sKubectl    string
+	k8sOutput     string
+	k8sTop        int
+	k8sFailOn     string
+	k8sIgnoreFile string
+	k8sParallel   int
+	k8sNoCache    bool
+	k8sOSVURL     string
+)
+
+func init() {
+	rootCmd.AddCommand(k8sCmd)
+	k8sCmd.AddCommand(k8sScanCmd)
+
+	k8sScanCmd.Flags().StringVar(&k8sKubeconfig, "kubeconfig", "", "kubeconfig file to reach the cluster with (default: kubectl's)")
+	k8sScanCmd.Flags().StringVar(&k8sContext, "context", "", "kubeconfig context to use (default: the current one)")
+	k8sScanCmd.Flags().StringVarP(&k8sNamespace, "namespace", "n", "", "scan only this namespace (default: all of them)")
+	k8sScanCmd.Flags().StringVar(&k8sKubectl, "kubectl", "kubectl", "kubectl program to list Pods with")
+	k8sScanCmd.Flags().StringVarP(&k8sOutput, "output", "o", outputText, "output format: "+strings.Join(outputFormats, ", "))
+	k8sScanCmd.Flags().IntVar(&k8sTop, "top", 10, "number of the most widespread advisories to list (0 = all)")
+	k8sScanCmd.Flags().StringVar(&k8sFailOn, "fail-on", "", "exit non-zero if any finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	k8sScanCmd.Flags().StringVar(&k8sIgnoreFile, "ignore-file", "", "suppression rules to apply")
+	k8sScanCmd.Flags().IntVar(&k8sParallel, "parallel", maxImagePulls, "number of images to pull and scan at once")
+	k8sScanCmd.Flags().BoolVar(&k8sNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	k8sScanCmd.Flags().StringVar(&k8sOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(k8sScanCmd)
+}
+
+/********** helpers **********/
+
+// k8sPod is a Pod and the images its containers run.
+type k8sPod struct {
+	Namespace string
+	Name      string
+	Images    []k8sContainerImage
+}
+
+// k8sContainerImage is a container's image as its spec names it, and the
+// digest its status says it runs, if it's known.
+type k8sContainerImage struct {
+	Image  string
+	Pinned string // image@sha256:..., or "" before it's pulled
+}
+
+// listPods lists the Pods of --namespace, or of every namespace, with
+// kubectl.
+func listPods(ctx context.Context) ([]k8sPod, error) {
+	args := []string{"get", "pods", "-o", "json"}
+	if k8sNamespace != "" {
+		args = append(args, "--namespace", k8sNamespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+	if k8sKubeconfig != "" {
+		args = append(args, "--kubeconfig", k8sKubeconfig)
+	}
+	if k8sContext != "" {
+		args = append(args, "--context", k8sContext)
+	}
+	if _, err := exec.LookPath(k8sKubectl); err != nil {
+		return nil, fmt.Errorf("%w (Pods are listed with kubectl)", err)
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, k8sKubectl, args...)
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", k8sKubectl, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", k8sKubectl, err)
+	}
+	return parsePods(stdout.Bytes())
+}
+
+type k8sContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type k8sContainerStatus struct {
+	Name    string `json:"name"`
+	ImageID string `json:"imageID"`
+}
+
+// parsePods reads the Pods of a PodList, as 'kubectl get pods -o json'
+// writes it.
+func parsePods(data []byte) ([]k8sPod, error) {
+	var list struct {
+		Items []struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				InitContainers      []k8sContainer `json:"initContainers"`
+				Containers          []k8sContainer `json:"containers"`
+				EphemeralContainers []k8sContainer `json:"ephemeralContainers"`
+			} `json:"spec"`
+			Status struct {
+				InitContainerStatuses      []k8sContainerStatus `json:"initContainerStatuses"`
+				ContainerStatuses          []k8sContainerStatus `json:"containerStatuses"`
+				EphemeralContainerStatuses []k8sContainerStatus `json:"ephemeralContainerStatuses"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("reading the Pod list: %w", err)
+	}
+	var pods []k8sPod
+	for _, item := range list.Items {
+		pod := k8sPod{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name}
+		imageIDs := map[string]string{}
+		for _, statuses := range [][]k8sContainerStatus{item.Status.InitContainerStatuses, item.Status.ContainerStatuses, item.Status.EphemeralContainerStatuses} {
+			for _, s := range statuses {
+				imageIDs[s.Name] = s.ImageID
+			}
+		}
+		for _, containers := range [][]k8sContainer{item.Spec.InitContainers, item.Spec.Containers, item.Spec.EphemeralContainers} {
+			for _, c := range containers {
+				if c.Image != "" {
+					pod.Images = append(pod.Images, k8sContainerImage{Image: c.Image, Pinned: pinnedImage(imageIDs[c.Name])})
+				}
+			}
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// pinnedImage returns the image reference pinned to a digest in a
+// container's imageID, such as "docker-pullable://nginx@sha256:...", or ""
+// when it has none: the container hasn't started, or the runtime only gives
+// the image's config digest.
+func pinnedImage(imageID string) string {
+	if _, ref, ok := strings.Cut(imageID, "://"); ok {
+		imageID = ref
+	}
+	if !strings.Contains(imageID, "@sha256:") {
+		return ""
+	}
+	return imageID
+}
+
+// clusterImage is an image running in the cluster, and the result of its
+// scan.
+type clusterImage struct {
+	Image      string   `json:"image"`
+	Digest     string   `json:"digest,omitempty"`
+	Namespaces []string `json:"namespaces"`
+	Pods       []string `json:"pods"`
+
+	Summary *scanner.ProjectSummary `json:"summary,omitempty"`
+	Error   string                  `json:"error,omitempty"`
+
+	ref    string // what's pulled: the image pinned to its digest if known
+	report *scanner.Report
+	err    error
+}
+
+// clusterImages de-duplicates the images pods run, by digest where it's
+// known and else by name, listing where each one runs.
+func clusterImages(pods []k8sPod) []*clusterImage {
+	byKey := map[string]*clusterImage{}
+	var images []*clusterImage
+	for _, pod := range pods {
+		for _, ci := range pod.Images {
+			key, ref, digest := ci.Image, ci.Image, ""
+			if ci.Pinned != "" {
+				_, digest, _ = strings.Cut(ci.Pinned, "@")
+				key, ref = digest, ci.Pinned
+			}
+			img := byKey[key]
+			if img == nil {
+				img = &clusterImage{Image: ci.Image, Digest: digest, ref: ref}
+				byKey[key] = img
+				images = append(images, img)
+			}
+			if !slices.Contains(img.Namespaces, pod.Namespace) {
+				img.Namespaces = append(img.Namespaces, pod.Namespace)
+			}
+			if name := pod.Namespace + "/" + pod.Name; !slices.Contains(img.Pods, name) {
+				img.Pods = append(img.Pods, name)
+			}
+		}
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Image < images[j].Image })
+	return images
+}
+
+// scanClusterImage pulls the image ref and scans it, with source.
+func scanClusterImage(ctx context.Context, client *http.Client, source scanner.Source, ref string, rules []scanner.IgnoreRule) (*scanner.Report, error) {
+	img, err := scanner.PullImage(client, ref)
+	if err != nil {
+		return nil, err
+	}
+	sc := &scanner.Scanner{Source: source}
+	return scanImageProjects(ctx, sc, ref, imageProjects(sc, img), rules)
+}
+
+// namespaceReports gathers the reports of the images scanned into a report
+// per namespace, with a project for each lockfile of each image it runs,
+// its source prefixed by the image. Summaries of the images are filled in
+// as they go.
+func namespaceReports(images []*clusterImage) ([]string, []*scanner.Report) {
+	byNamespace := map[string]*scanner.Report{}
+	for _, img := range images {
+		if img.err != nil {
+			img.Error = img.err.Error()
+			continue
+		}
+		summary := scanner.SummarizeReports([]string{img.Image}, []*scanner.Report{img.report}).Projects[0]
+		img.Summary = &summary
+		for _, ns := range img.Namespaces {
+			r := byNamespace[ns]
+			if r == nil {
+				r = &scanner.Report{Source: ns, Lockfile: scanner.LockfileImage}
+				byNamespace[ns] = r
+			}
+			for _, p := range img.report.Reports() {
+				// MergeReports renames the projects it merges, and an image
+				// running in many namespaces is in each of their reports.
+				project := *p
+				project.Source = img.Image + ": " + p.Source
+				r.Projects = append(r.Projects, &project)
+				r.Scanned += p.Scanned
+				r.Partial = r.Partial || p.Partial
+			}
+		}
+	}
+	namespaces := sortedKeys(byNamespace)
+	reports := make([]*scanner.Report, len(namespaces))
+	for i, ns := range namespaces {
+		reports[i] = byNamespace[ns]
+	}
+	return namespaces, reports
+}
+
+// clusterSummary is the JSON output of 'k8s scan'.
+type clusterSummary struct {
+	Namespaces scanner.MergeSummary `json:"namespaces"`
+	Images     []*clusterImage      `json:"images"`
+}
+
+// writeClusterImages lists the images scanned, the most vulnerable first,
+// with their findings and where they run.
+func writeClusterImages(w io.Writer, images []*clusterImage) {
+	sorted := append([]*clusterImage(nil), images...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return imageVulns(sorted[i]) > imageVulns(sorted[j])
+	})
+	fmt.Fprintf(w, "\nImages:\n")
+	for _, img := range sorted {
+		name := img.Image
+		if img.Digest != "" {
+			name += " (" + img.Digest[:len("sha256:")+12] + ")"
+		}
+		fmt.Fprintf(w, "  %s\n", name)
+		if img.err != nil {
+			fmt.Fprintf(w, "    not scanned: %v\n", img.err)
+		} else {
+			fmt.Fprintf(w, "    %s in %d package(s)\n", attestedFindings(img.report), img.report.Scanned)
+		}
+		fmt.Fprintf(w, "    runs in %s\n", strings.Join(img.Pods, ", "))
+	}
+}
+
+func imageVulns(img *clusterImage) int {
+	if img.Summary == nil {
+		return -1
+	}
+	return img.Summary.Vulns
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// Shared by every command that checks licenses; only one command runs.
+var (
+	licenseAllow []string
+	licenseDeny  []string
+)
+
+// addLicenseFlags registers the license policy flags on cmd.
+func addLicenseFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&licenseAllow, "allow-license", nil, "only permit dependencies under these SPDX licenses (repeatable; \"GPL-*\" matches a prefix)")
+	cmd.Flags().StringSliceVar(&licenseDeny, "deny-license", nil, "reject dependencies under these SPDX licenses (repeatable; \"GPL-*\" matches a prefix)")
+}
+
+func licensePolicy() scanner.LicensePolicy {
+	return scanner.LicensePolicy{Allow: licenseAllow, Deny: licenseDeny}
+}
+
+var licenseCmd = &cobra.Command{
+	Use:   "license [path-to-lockfile | directory]",
+	Short: "List dependency licenses and check them against a license policy",
+	Long: `Lists the license of every dependency in a lockfile, or in each lockfile
+under a directory, as recorded by the lockfile itself: package-lock.json and
+composer.lock record licenses, most other formats don't.
+
+With --allow-license or --deny-license, licenses are checked against that
+policy and the command exits non-zero if any dependency's license isn't
+permitted. SPDX expressions are understood, so "MIT OR GPL-3.0" is permitted
+when MIT is. Dependencies without a recorded license aren't checked. The same
+flags on 'keystone scan' add the check to a vulnerability scan, and can be set
+in keystone.yaml like any other flag.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Clean(args[0])
+		dir := path
+		if !isDir(path) {
+			dir = filepath.Dir(path)
+		}
+		loadConfigFor(cmd, dir)
+
+		if licenseOutput != outputText && licenseOutput != outputJSON {
+			usagef("Unknown output format %q (want one of: %s, %s)", licenseOutput, outputText, outputJSON)
+		}
+
+		inputs := []string{path}
+		if isDir(path) {
+			found, err := scanner.DiscoverLockfiles(path)
+			if err != nil {
+				fatal("Error searching for lockfiles", err)
+			}
+			if len(found) == 0 {
+				logger.Warn(fmt.Sprintf("No supported lockfiles found under %s.", path))
+				return
+			}
+			inputs = found
+		}
+
+		sc := &scanner.Scanner{ProdOnly: licenseProdOnly}
+		var reports []*scanner.Report
+		for _, in := range inputs {
+			kind, deps, err := loadScanInput(in, false)
+			if err != nil && len(inputs) > 1 {
+				logger.Warn("Skipping "+in, "err", err)
+				continue
+			}
+			if err != nil {
+				fatal("Error", err)
+			}
+			deps = sc.Scannable(deps)
+			reports = append(reports, &scanner.Report{Source: in, Lockfile: kind, Scanned: len(deps), Packages: deps})
+		}
+		report := &scanner.Report{Source: path, Lockfile: scanner.LockfileDirectory, Projects: reports}
+		policy := licensePolicy()
+		scanner.CheckLicenses(report, policy)
+
+		var err error
+		if licenseOutput == outputJSON {
+			err = writeLicenseJSON(report, policy)
+		} else {
+			writeLicenseText(report, policy)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if n := report.LicenseViolationCount(); n > 0 {
+			failf("%d package(s) with licenses the policy doesn't permit", n)
+		}
+	},
+}
+
+var (
+	licenseOutput   string
+	licenseProdOnly bool
+)
+
+func init() {
+	rootCmd.AddCommand(licenseCmd)
+
+	licenseCmd.Flags().StringVarP(&licenseOutput, "output", "o", outputText, "output format: text, json")
+	licenseCmd.Flags().BoolVar(&licenseProdOnly, "prod-only", false, "leave out development dependencies")
+	addLicenseFlags(licenseCmd)
+}
+
+/********** helpers **********/
+
+// licenseEntry is one package in the license listing.
+type licenseEntry struct {
+	Lockfile  string `json:"lockfile"`
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	License   string `json:"license,omitempty"`
+	Dev       bool   `json:"dev,omitempty"`
+	Permitted bool   `json:"permitted"`
+}
+
+// licenseEntries lists each lockfile's packages once, with whether the
+// policy permits them.
+func licenseEntries(r *scanner.Report, policy scanner.LicensePolicy) []licenseEntry {
+	out := []licenseEntry{}
+	for _, p := range r.Reports() {
+		seen := map[string]bool{}
+		for _, d := range p.Packages {
+			key := d.Ecosystem + "\x00" + d.Name + "@" + d.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, licenseEntry{
+				Lockfile: p.Source, Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version,
+				License: d.License, Dev: d.Dev, Permitted: d.License == "" || policy.Permits(d.License),
+			})
+		}
+	}
+	return out
+}
+
+func writeLicenseJSON(r *scanner.Report, policy scanner.LicensePolicy) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(licenseEntries(r, policy))
+}
+
+func writeLicenseText(r *scanner.Report, policy scanner.LicensePolicy) {
+	entries := licenseEntries(r, policy)
+	multi := len(r.Projects) > 1
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	unknown := 0
+	lockfile := ""
+	for _, e := range entries {
+		if multi && e.Lockfile != lockfile {
+			if lockfile != "" {
+				fmt.Fprintln(tw)
+			}
+			lockfile = e.Lockfile
+			fmt.Fprintf(tw, "📁 %s\n", lockfile)
+		}
+		license := e.License
+		if license == "" {
+			license = "(not recorded)"
+			unknown++
+		}
+		name := e.Package + "@" + e.Version
+		if e.Dev {
+			name += " (dev)"
+		}
+		status := ""
+		if !e.Permitted {
+			status = "❌ not permitted"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", name, license, status)
+	}
+	tw.Flush()
+
+	fmt.Println()
+	fmt.Printf("📦 %d package(s)", len(entries))
+	if unknown > 0 {
+		fmt.Printf(", %d without a recorded license", unknown)
+	}
+	fmt.Println()
+	if !policy.Empty() {
+		if n := r.LicenseViolationCount(); n > 0 {
+			fmt.Printf("⚖️  %d package(s) with licenses the policy doesn't permit: %s\n", n, strings.Join(violationNames(r), ", "))
+		} else {
+			fmt.Println("✅ Every recorded license is permitted by the policy.")
+		}
+	}
+}
+
+func violationNames(r *scanner.Report) []string {
+	var out []string
+	for _, p := range r.Reports() {
+		for _, l := range p.LicenseViolations {
+			out = append(out, l.Package+"@"+l.Version)
+		}
+	}
+	return out
+}
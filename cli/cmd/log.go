@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// Log formats accepted by --log-format.
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logger carries every diagnostic message — progress, warnings and errors —
+// to stderr, so that stdout holds nothing but the report and can be piped.
+// It is also the slog default, which the scanner package logs its debugging
+// detail to.
+var logger = slog.New(newTextHandler(os.Stderr, slog.LevelInfo))
+
+var (
+	logVerbose bool
+	logQuiet   bool
+	logFormat  string
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&logVerbose, "verbose", "v", false, "also log debugging detail, such as each OSV request")
+	rootCmd.PersistentFlags().BoolVarP(&logQuiet, "quiet", "q", false, "log only errors")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logFormatText, "format of the diagnostic output on stderr: text, json")
+	cobra.OnInitialize(setupLogging)
+}
+
+func setupLogging() {
+	level := slog.LevelInfo
+	switch {
+	case logVerbose:
+		level = slog.LevelDebug
+	case logQuiet:
+		level = slog.LevelError
+	}
+	switch logFormat {
+	case logFormatText:
+		logger = slog.New(newTextHandler(os.Stderr, level))
+	case logFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	default:
+		usagef("Unknown --log-format %q (want one of: %s, %s)", logFormat, logFormatText, logFormatJSON)
+	}
+	slog.SetDefault(logger)
+}
+
+// Exit codes, which CI jobs branch on: 1 only ever means the scan finished
+// and found something a threshold fails, so that a job can tell "no
+// vulnerabilities" from "couldn't find out".
+const (
+	exitClean      = 0 // nothing failed
+	exitFindings   = 1 // findings at or above a --fail-on threshold or denied by policy
+	exitUsage      = 2 // unknown flags, bad arguments or flag values
+	exitIncomplete = 3 // the scan failed, or couldn't finish and its results are partial
+)
+
+// exitHooks run before the command exits through fatal and its kin, which
+// skips deferred calls.
+var exitHooks []func()
+
+// onExit registers f, such as removing a temporary directory, to run if the
+// command exits through fatal or fatalf.
+func onExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+// fatal logs msg and err as an error and exits with exitIncomplete.
+func fatal(msg string, err error) {
+	logger.Error(msg, "err", err)
+	exit(exitIncomplete)
+}
+
+// fatalf logs a formatted error and exits with exitIncomplete.
+func fatalf(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+	exit(exitIncomplete)
+}
+
+// usage logs msg and err as an error about how the command was invoked, such
+// as a flag value that doesn't parse, and exits with exitUsage.
+func usage(msg string, err error) {
+	logger.Error(msg, "err", err)
+	exit(exitUsage)
+}
+
+// usagef logs a formatted error about how the command was invoked and exits
+// with exitUsage.
+func usagef(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+	exit(exitUsage)
+}
+
+// failf logs the findings that failed a threshold and exits with
+// exitFindings.
+func failf(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+	exit(exitFindings)
+}
+
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	os.Exit(code)
+}
+
+/********** helpers **********/
+
+// textHandler writes records the way keystone always has: one line each,
+// errors and warnings marked with ❌ and ⚠️, an "err" attribute appended
+// after a colon and any others as key=value pairs.
+type textHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+}
+
+func newTextHandler(w io.Writer, level slog.Level) *textHandler {
+	return &textHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *textHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("❌ ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("⚠️  ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("🐛 ")
+	}
+	b.WriteString(r.Message)
+
+	var errText string
+	write := func(a slog.Attr) bool {
+		if a.Key == "err" {
+			errText = a.Value.String()
+		} else if !a.Equal(slog.Attr{}) {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	if errText != "" {
+		b.WriteString(": " + errText)
+	}
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &h2
+}
+
+// WithGroup is a no-op: keystone doesn't group attributes, and the text
+// format has nowhere to show them.
+func (h *textHandler) WithGroup(string) slog.Handler {
+	return h
+}
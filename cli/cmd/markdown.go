@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// writeMarkdownReport renders the report as a compact Markdown table, meant
+// to be posted as a pull-request comment.
+func writeMarkdownReport(w io.Writer, r *scanner.Report) error {
+	var b strings.Builder
+	b.WriteString("### 🔒 keystone vulnerability scan\n\n")
+
+	total := r.VulnCount()
+	if total == 0 {
+		fmt.Fprintf(&b, "✅ No known vulnerabilities in %d packages.\n", r.Scanned)
+		writeMarkdownUnchecked(&b, r)
+		writeMarkdownLicenses(&b, r)
+		return writeString(w, b.String())
+	}
+
+	counts := map[string]int{}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				counts[severityLabel(v)]++
+			}
+		}
+	}
+	var summary []string
+	for _, sev := range scanner.SeverityOrder {
+		if counts[sev] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[sev], strings.ToLower(sev)))
+		}
+	}
+	fmt.Fprintf(&b, "**%d vulnerabilities** in %d packages (%s).\n\n", total, r.Scanned, strings.Join(summary, ", "))
+	if n := r.KnownExploited(); n > 0 {
+		fmt.Fprintf(&b, "> 🔥 **%d known exploited vulnerability(ies)** (CISA KEV): remediate these first.\n\n", n)
+	}
+	if r.Partial {
+		fmt.Fprintf(&b, "> ⚠️ Details of %d advisory(ies) couldn't be fetched; results are incomplete.\n\n", r.Unfetched())
+	}
+	writeMarkdownUnchecked(&b, r)
+
+	multi := len(r.Projects) > 0
+	if multi {
+		b.WriteString("| Severity | Package | Advisory | Fixed in | Lockfile |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+	} else {
+		b.WriteString("| Severity | Package | Advisory | Fixed in |\n")
+		b.WriteString("|---|---|---|---|\n")
+	}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			pkg := fmt.Sprintf("`%s@%s`", f.Package, f.Version)
+			if f.Dev {
+				pkg += " (dev)"
+			}
+			for _, v := range f.Vulns {
+				sev := severityLabel(v)
+				advisory := fmt.Sprintf("[%s](https://osv.dev/vulnerability/%s)", v.ID, v.ID)
+				if cves := v.CVEs(); len(cves) > 0 && cves[0] != v.ID {
+					advisory += " (" + strings.Join(cves, ", ") + ")"
+				}
+				if s := firstLine(v.Summary); s != "" {
+					advisory += " " + s
+				}
+				fixed := "—"
+				if v.Fixed != "" {
+					fixed = "`" + v.Fixed + "`"
+				}
+				if v.EPSS != nil {
+					sev += " · EPSS " + formatEPSS(v.EPSS.Score)
+				}
+				if v.KEV != nil {
+					sev += " · 🔥 **known exploited**"
+				}
+				row := []string{severityEmoji[severityLabel(v)] + " " + sev, pkg, markdownCell(advisory), fixed}
+				if multi {
+					row = append(row, "`"+p.Source+"`")
+				}
+				fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+			}
+		}
+	}
+
+	if n := suppressedCount(r); n > 0 {
+		fmt.Fprintf(&b, "\n<sub>%d finding(s) suppressed by ignore rules.</sub>\n", n)
+	}
+	writeMarkdownLicenses(&b, r)
+	return writeString(w, b.String())
+}
+
+// writeMarkdownLicenses lists the packages whose licenses the policy doesn't
+// permit, if any.
+func writeMarkdownLicenses(b *strings.Builder, r *scanner.Report) {
+	n := r.LicenseViolationCount()
+	if n == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n**⚖️ %d package(s) with disallowed licenses**\n\n", n)
+	b.WriteString("| Package | License |\n|---|---|\n")
+	for _, p := range r.Reports() {
+		for _, l := range p.LicenseViolations {
+			fmt.Fprintf(b, "| `%s@%s` | %s |\n", l.Package, l.Version, markdownCell(l.License))
+		}
+	}
+}
+
+// writeMarkdownUnchecked notes the packages that weren't checked because
+// OSV doesn't cover their ecosystem.
+func writeMarkdownUnchecked(b *strings.Builder, r *scanner.Report) {
+	unchecked := r.UncheckedByEcosystem()
+	for _, eco := range sortedKeys(unchecked) {
+		fmt.Fprintf(b, "> ⚠️ %s\n\n", uncheckedMessage(eco, unchecked[eco]))
+	}
+}
+
+var severityEmoji = map[string]string{
+	scanner.SeverityCritical: "🟥",
+	scanner.SeverityHigh:     "🟧",
+	scanner.SeverityMedium:   "🟨",
+	scanner.SeverityLow:      "🟦",
+	scanner.SeverityUnknown:  "⬜",
+}
+
+// severityLabel is v's severity, UNKNOWN for advisories whose details
+// couldn't be fetched.
+func severityLabel(v scanner.Vulnerability) string {
+	if v.Severity == "" {
+		return scanner.SeverityUnknown
+	}
+	return v.Severity
+}
+
+// markdownCell keeps text from breaking out of a table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
+
+func suppressedCount(r *scanner.Report) int {
+	n := 0
+	for _, p := range r.Reports() {
+		n += len(p.Suppressed)
+	}
+	return n
+}
+
+func writeString(w io.Writer, s string) error {
+	_, err := io.WriteString(w, s)
+	return err
+}
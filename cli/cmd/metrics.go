@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// scanDurationBuckets are the upper bounds, in seconds, of the histogram of
+// how long scan requests take: from a cached lockfile to a large monorepo
+// SBOM against a slow OSV.
+var scanDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// serverMetrics counts what the scanning service has done, for /metrics to
+// expose in the Prometheus text format.
+type serverMetrics struct {
+	mu sync.Mutex
+
+	scans     map[int]int64 // by HTTP status
+	durations []int64       // per bucket of scanDurationBuckets, then +Inf
+	duration  float64       // total seconds
+	packages  int64
+
+	findings     map[string]int64 // vulnerabilities found by all scans, by severity
+	lastFindings map[string]int64 // those the latest scan found
+
+	osv func() scanner.OSVStats // nil when offline
+}
+
+func newServerMetrics(source scanner.Source) *serverMetrics {
+	m := &serverMetrics{
+		scans:        map[int]int64{},
+		durations:    make([]int64, len(scanDurationBuckets)+1),
+		findings:     map[string]int64{},
+		lastFindings: map[string]int64{},
+	}
+	if c, ok := source.(*scanner.OSVClient); ok {
+		m.osv = c.Stats
+	}
+	return m
+}
+
+// instrument counts the requests next answers and times them.
+func (m *serverMetrics) instrument(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		elapsed := time.Since(start).Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.scans[rec.status]++
+		m.duration += elapsed
+		i := sort.SearchFloat64s(scanDurationBuckets, elapsed)
+		m.durations[i]++
+	}
+}
+
+// observe records what a scan found.
+func (m *serverMetrics) observe(r *scanner.Report) {
+	last := map[string]int64{}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				last[severityLabel(v)]++
+			}
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.packages += int64(r.Scanned)
+	for sev, n := range last {
+		m.findings[sev] += n
+	}
+	m.lastFindings = last
+}
+
+func (m *serverMetrics) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes every metric in the Prometheus text exposition format.
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metricHeader(w, "keystone_scans_total", "counter", "Scan requests answered, by HTTP status.")
+	for _, status := range sortedIntKeys(m.scans) {
+		fmt.Fprintf(w, "keystone_scans_total{code=\"%d\"} %d\n", status, m.scans[status])
+	}
+
+	metricHeader(w, "keystone_scan_duration_seconds", "histogram", "How long scan requests took to answer.")
+	var cumulative int64
+	for i, le := range scanDurationBuckets {
+		cumulative += m.durations[i]
+		fmt.Fprintf(w, "keystone_scan_duration_seconds_bucket{le=\"%s\"} %d\n", formatFloat(le), cumulative)
+	}
+	count := cumulative + m.durations[len(scanDurationBuckets)]
+	fmt.Fprintf(w, "keystone_scan_duration_seconds_bucket{le=\"+Inf\"} %d\n", count)
+	fmt.Fprintf(w, "keystone_scan_duration_seconds_sum %s\n", formatFloat(m.duration))
+	fmt.Fprintf(w, "keystone_scan_duration_seconds_count %d\n", count)
+
+	metricHeader(w, "keystone_scanned_packages_total", "counter", "Packages looked up by scans.")
+	fmt.Fprintf(w, "keystone_scanned_packages_total %d\n", m.packages)
+
+	metricHeader(w, "keystone_findings_total", "counter", "Vulnerabilities found by scans, by severity.")
+	for _, sev := range scanner.SeverityOrder {
+		fmt.Fprintf(w, "keystone_findings_total{severity=\"%s\"} %d\n", strings.ToLower(sev), m.findings[sev])
+	}
+	metricHeader(w, "keystone_last_scan_findings", "gauge", "Vulnerabilities found by the latest scan, by severity.")
+	for _, sev := range scanner.SeverityOrder {
+		fmt.Fprintf(w, "keystone_last_scan_findings{severity=\"%s\"} %d\n", strings.ToLower(sev), m.lastFindings[sev])
+	}
+
+	if m.osv == nil {
+		return
+	}
+	osv := m.osv()
+	metricHeader(w, "keystone_osv_cache_lookups_total", "counter", "Package lookups and advisories answered from the OSV cache (hit) or asked of OSV (miss).")
+	fmt.Fprintf(w, "keystone_osv_cache_lookups_total{result=\"hit\"} %d\n", osv.CacheHits)
+	fmt.Fprintf(w, "keystone_osv_cache_lookups_total{result=\"miss\"} %d\n", osv.CacheMisses)
+	metricHeader(w, "keystone_osv_requests_total", "counter", "HTTP requests sent to OSV, retries included.")
+	fmt.Fprintf(w, "keystone_osv_requests_total %d\n", osv.Requests)
+	metricHeader(w, "keystone_osv_request_failures_total", "counter", "OSV requests that failed with a network error or an error status.")
+	fmt.Fprintf(w, "keystone_osv_request_failures_total %d\n", osv.Failures)
+}
+
+/********** helpers **********/
+
+func metricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedIntKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// Network flags are shared by every command that talks to OSV.
+var (
+	netProxy  string
+	netCACert string
+)
+
+func addNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&netProxy, "proxy", "", "send OSV requests through this proxy URL (default: HTTP_PROXY/HTTPS_PROXY from the environment)")
+	cmd.Flags().StringVar(&netCACert, "ca-cert", "", "PEM file of extra CA certificates to trust, e.g. for a TLS-intercepting proxy")
+}
+
+// httpClient builds the client for OSV requests from the network flags.
+// Requests sent without a context of their own are bound to ctx, so that
+// every lookup a command makes stops on Ctrl-C or --timeout.
+func httpClient(ctx context.Context) (*http.Client, error) {
+	client, err := scanner.NewHTTPClient(scanner.HTTPOptions{Proxy: netProxy, CACert: netCACert})
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = contextTransport{ctx: ctx, base: client.Transport}
+	return client, nil
+}
+
+/********** helpers **********/
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context() == context.Background() {
+		req = req.WithContext(t.ctx)
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// Schemes of --notify URLs, one per chat service.
+const (
+	notifySlack = "slack"
+	notifyTeams = "teams"
+)
+
+// notifyMaxItems is how many findings a notification lists before
+// summarizing the rest as a count.
+const notifyMaxItems = 10
+
+// notifyTarget is a parsed --notify URL: the service and the https URL of
+// its incoming webhook.
+type notifyTarget struct {
+	service string
+	webhook string
+}
+
+// parseNotifyURL reads a --notify URL, which is a webhook URL with its
+// https:// replaced by the service's scheme:
+//
+//	slack://hooks.slack.com/services/T000/B000/XXXX
+//	teams://example.webhook.office.com/webhookb2/…
+//
+// Slack's host may be left out: slack://T000/B000/XXXX.
+func parseNotifyURL(s string) (notifyTarget, error) {
+	scheme, rest, ok := strings.Cut(s, "://")
+	if !ok || rest == "" {
+		return notifyTarget{}, fmt.Errorf("invalid --notify URL %q (want slack://… or teams://…)", s)
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	switch scheme {
+	case notifySlack:
+		if !strings.Contains(host, ".") {
+			rest = "hooks.slack.com/services/" + rest
+		}
+	case notifyTeams:
+		if !strings.Contains(host, ".") {
+			return notifyTarget{}, fmt.Errorf("invalid --notify URL %q: a Teams webhook URL needs its host", s)
+		}
+	default:
+		return notifyTarget{}, fmt.Errorf("unknown --notify service %q (want one of: %s, %s)", scheme, notifySlack, notifyTeams)
+	}
+	return notifyTarget{service: scheme, webhook: "https://" + rest}, nil
+}
+
+// notifyMessage is what a notification says, before being formatted for a
+// service.
+type notifyMessage struct {
+	title string
+	lines []string // one per finding, with **bold** markup
+}
+
+// buildNotifyMessage summarizes r's findings, most severe first. newOnly
+// says they are the ones a baseline doesn't hold.
+func buildNotifyMessage(r *scanner.Report, newOnly bool) notifyMessage {
+	type item struct {
+		rank int
+		line string
+	}
+	var items []item
+	counts := map[string]int{}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				sev := severityLabel(v)
+				counts[sev]++
+				line := fmt.Sprintf("**%s** %s@%s — %s", sev, f.Package, f.Version, advisoryName(v))
+				if s := firstLine(v.Summary); s != "" {
+					line += ": " + s
+				}
+				if v.Fixed != "" {
+					line += " (fixed in " + v.Fixed + ")"
+				}
+				if v.KEV != nil {
+					line += " 🔥 known exploited"
+				}
+				items = append(items, item{severityIndex(sev), line})
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].rank < items[j].rank })
+
+	kind := "vulnerability(ies)"
+	if newOnly {
+		kind = "new vulnerability(ies)"
+	}
+	var bySeverity []string
+	for _, sev := range scanner.SeverityOrder {
+		if counts[sev] > 0 {
+			bySeverity = append(bySeverity, fmt.Sprintf("%d %s", counts[sev], strings.ToLower(sev)))
+		}
+	}
+	m := notifyMessage{title: fmt.Sprintf("🔒 keystone: %d %s in %s (%s)", len(items), kind, r.Source, strings.Join(bySeverity, ", "))}
+	for i, it := range items {
+		if i == notifyMaxItems {
+			m.lines = append(m.lines, fmt.Sprintf("…and %d more", len(items)-notifyMaxItems))
+			break
+		}
+		m.lines = append(m.lines, it.line)
+	}
+	return m
+}
+
+// payload formats m as the JSON body t's webhook expects: Slack mrkdwn
+// text, or a Teams Adaptive Card.
+func (t notifyTarget) payload(m notifyMessage) any {
+	if t.service == notifySlack {
+		var b strings.Builder
+		b.WriteString("*" + m.title + "*")
+		for _, l := range m.lines {
+			b.WriteString("\n• " + strings.ReplaceAll(l, "**", "*"))
+		}
+		return map[string]any{"text": b.String()}
+	}
+
+	var body strings.Builder
+	for _, l := range m.lines {
+		body.WriteString("- " + l + "\n")
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body": []any{
+			map[string]any{"type": "TextBlock", "text": m.title, "weight": "Bolder", "size": "Medium", "wrap": true},
+			map[string]any{"type": "TextBlock", "text": body.String(), "wrap": true},
+		},
+	}
+	return map[string]any{
+		"type":        "message",
+		"attachments": []any{map[string]any{"contentType": "application/vnd.microsoft.card.adaptive", "content": card}},
+	}
+}
+
+// sendNotifications posts m to every target. A webhook that fails is
+// logged, not fatal: the scan's own result matters more than its delivery.
+func sendNotifications(client *http.Client, targets []notifyTarget, m notifyMessage) {
+	for _, t := range targets {
+		body, err := json.Marshal(t.payload(m))
+		if err != nil {
+			logger.Warn("Error building notification", "service", t.service, "err", err)
+			continue
+		}
+		resp, err := client.Post(t.webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			logger.Warn("Error sending notification", "service", t.service, "err", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			logger.Warn("Notification was rejected", "service", t.service, "status", resp.Status)
+			continue
+		}
+		logger.Info(fmt.Sprintf("📣 Notified %s", t.service))
+	}
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // for jwtHashes
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval is how often, at most, a token signed with a key the
+// verifier doesn't know fetches the issuer's keys again, as they rotate.
+const jwksRefreshInterval = time.Minute
+
+// oidcVerifier verifies ID tokens from an OpenID Connect issuer, such as a
+// Kubernetes service account token or a CI job's token.
+type oidcVerifier struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	client   *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey // by key ID
+	fetched time.Time
+}
+
+// newOIDCVerifier looks up issuer's keys from its discovery document.
+func newOIDCVerifier(ctx context.Context, client *http.Client, issuer, audience string) (*oidcVerifier, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%s isn't the discovery document of issuer %s", issuer+"/.well-known/openid-configuration", issuer)
+	}
+	v := &oidcVerifier{issuer: discovery.Issuer, audience: audience, jwksURL: discovery.JWKSURI, client: client}
+	if err := v.refresh(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// verify checks token's signature, issuer, audience and expiry, and returns
+// its subject.
+func (v *oidcVerifier) verify(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed token signature")
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyJWTSignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return "", err
+	}
+
+	var claims struct {
+		Issuer    string          `json:"iss"`
+		Subject   string          `json:"sub"`
+		Audience  json.RawMessage `json:"aud"`
+		Expiry    float64         `json:"exp"`
+		NotBefore float64         `json:"nbf"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return "", err
+	}
+	now := float64(time.Now().Unix())
+	const leeway = 60 // seconds of clock skew
+	switch {
+	case claims.Issuer != v.issuer:
+		return "", fmt.Errorf("token is from issuer %q, not %q", claims.Issuer, v.issuer)
+	case !hasAudience(claims.Audience, v.audience):
+		return "", fmt.Errorf("token isn't for audience %q", v.audience)
+	case claims.Expiry == 0 || now > claims.Expiry+leeway:
+		return "", errors.New("token has expired")
+	case now+leeway < claims.NotBefore:
+		return "", errors.New("token isn't valid yet")
+	case claims.Subject == "":
+		return "", errors.New("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// key returns the issuer's key kid, fetching its keys again if it's new.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	key, ok := v.keys[kid]
+	stale := time.Since(v.fetched) > jwksRefreshInterval
+	v.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if stale {
+		if err := v.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("fetching the issuer's keys: %w", err)
+		}
+		v.mu.Lock()
+		key, ok = v.keys[kid]
+		v.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("token is signed with unknown key %q", kid)
+}
+
+func (v *oidcVerifier) refresh(ctx context.Context) error {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, v.client, v.jwksURL, &jwks); err != nil {
+		return err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, e := decodeBigInt(k.N), decodeBigInt(k.E)
+			if n != nil && e != nil && e.IsInt64() {
+				keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+			}
+		case "EC":
+			curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+			x, y := decodeBigInt(k.X), decodeBigInt(k.Y)
+			if curve := curves[k.Crv]; curve != nil && x != nil && y != nil {
+				keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+			}
+		}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys, v.fetched = keys, time.Now()
+	return nil
+}
+
+/********** helpers **********/
+
+// jwtHashes are the hashes of the JWS signature algorithms verify accepts.
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// verifyJWTSignature checks sig, a JWS signature by alg, of signed.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported token signature algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	var valid bool
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			valid = rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+		case "PS":
+			valid = rsa.VerifyPSS(key, hash, digest, sig, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[:2] == "ES" && len(sig) == 2*size {
+			r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+			valid = ecdsa.Verify(key, digest, r, s)
+		}
+	}
+	if !valid {
+		return errors.New("invalid token signature")
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// hasAudience reports whether a token's aud claim, a string or a list of
+// them, includes audience.
+func hasAudience(aud json.RawMessage, audience string) bool {
+	var list []string
+	if json.Unmarshal(aud, &list) != nil {
+		var one string
+		if json.Unmarshal(aud, &one) != nil {
+			return false
+		}
+		list = []string{one}
+	}
+	for _, a := range list {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeBigInt(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var orgCmd = &cobra.Command{
+	Use:   "org",
+	Short: "Work with every repository of an organization",
+}
+
+var orgScanCmd = &cobra.Command{
+	Use:   "scan (--github-org org | --gitlab-group group | --bitbucket-workspace workspace | --bitbucket-project key)",
+	Short: "Scan the lockfiles of every repository of an organization on GitHub, GitLab or Bitbucket",
+	Long: `Lists the repositories of an organization through its code host's API,
+fetches the lockfiles on each one's default branch, without cloning it, and
+scans them all, then summarises them org-wide as 'keystone report merge'
+does: for each repository, the lockfiles and packages scanned and its
+vulnerabilities by severity, then the totals and the advisories that affect
+the most repositories (--top).
+
+  keystone org scan --github-org acme --fail-on critical
+  keystone org scan --gitlab-group acme/platform -o sarif > platform.sarif
+  keystone org scan --bitbucket-workspace acme
+  keystone org scan --bitbucket-project WEB --bitbucket-url https://bitbucket.example.com
+
+The organization is one of:
+
+  --github-org           a GitHub organization (GitHub Enterprise Server
+                         with --github-url)
+  --gitlab-group         a GitLab group, with its subgroups (self-managed
+                         GitLab with --gitlab-url)
+  --bitbucket-workspace  a Bitbucket Cloud workspace
+  --bitbucket-project    a project on Bitbucket Server or Data Center at
+                         --bitbucket-url
+
+The token (--token, or $GITHUB_TOKEN, $GITLAB_TOKEN or $BITBUCKET_TOKEN)
+needs to read the repositories and their contents; without one, only public
+repositories are seen, within the host's anonymous rate limit. A Bitbucket
+app password is given as "user:password". Archived repositories and forks
+are left out unless --include-archived and --include-forks are given.
+A .keystoneignore beside a lockfile applies to it as in a checkout, unless
+--ignore-file is given.
+
+-o json writes the summary as JSON; sarif, html, junit and markdown instead
+render every finding, with each lockfile's source prefixed by its
+repository.
+
+'keystone scan --repo' and daemon repo: targets clone with git, so work
+with a repository on any host.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfigFor(cmd, ".")
+		var orgs []string
+		for _, name := range []string{"github-org", "gitlab-group", "bitbucket-workspace", "bitbucket-project"} {
+			if cmd.Flags().Lookup(name).Value.String() != "" {
+				orgs = append(orgs, "--"+name)
+			}
+		}
+		switch len(orgs) {
+		case 0:
+			usagef("One of --github-org, --gitlab-group, --bitbucket-workspace or --bitbucket-project is required: the organization whose repositories to scan")
+		case 1:
+		default:
+			usagef("Only one organization can be scanned at once, not %s", strings.Join(orgs, " and "))
+		}
+		if orgBitbucketProject != "" && orgBitbucketURL == "" {
+			usagef("--bitbucket-project needs --bitbucket-url: the Bitbucket Server to read it from")
+		}
+		if !validOutputFormat(orgOutput) {
+			usagef("Unknown output format %q (want one of: %s)", orgOutput, strings.Join(allOutputFormats(), ", "))
+		}
+		if orgFailOn != "" && !scanner.ValidFailOn(orgFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", orgFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		if orgParallel < 1 {
+			usagef("--parallel must be at least 1, not %d", orgParallel)
+		}
+		rules, err := cfg.ignoreRules()
+		if err == nil && orgIgnoreFile != "" {
+			var fileRules []scanner.IgnoreRule
+			fileRules, err = scanner.LoadIgnoreFile(orgIgnoreFile, false)
+			rules = append(rules, fileRules...)
+		}
+		if err != nil {
+			fatal("Error reading ignore rules", err)
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		provider, org, tokenVar := orgProvider(client)
+		if orgToken == "" {
+			logger.Warn(fmt.Sprintf("No --token or $%s: only public repositories can be scanned, within %s's anonymous rate limit", tokenVar, provider.Name()))
+		}
+
+		ctx := cmd.Context()
+		all, err := provider.OrgRepos(ctx, org)
+		if err != nil {
+			fatal("Error listing the organization's repositories", err)
+		}
+		var repos []scanner.HostedRepo
+		for _, r := range all {
+			if (r.Archived && !orgIncludeArchived) || (r.Fork && !orgIncludeForks) {
+				continue
+			}
+			repos = append(repos, r)
+		}
+		if len(repos) == 0 {
+			logger.Warn(fmt.Sprintf("No repositories to scan in %s.", org))
+			return
+		}
+		logger.Info(fmt.Sprintf("🏢 Scanning %d of %d repositories in %s", len(repos), len(all), org))
+
+		source, err := sourceOptions{
+			noCache:     orgNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      orgOSVURL,
+			client:      client,
+		}.open(nil)
+		if err != nil {
+			fatal("Error opening OSV source", err)
+		}
+
+		reports := make([]*scanner.Report, len(repos))
+		errs := make([]error, len(repos))
+		scanner.RunPool(orgParallel, len(repos), func(i int) {
+			sc := &scanner.Scanner{Source: source, ProdOnly: orgProdOnly}
+			reports[i], errs[i] = scanHostedRepo(ctx, provider, sc, repos[i], rules)
+		})
+		if ctx.Err() != nil {
+			fatalf("Scan %s", interruption(ctx))
+		}
+
+		var names []string
+		var scanned []*scanner.Report
+		failed, empty := 0, 0
+		for i, r := range repos {
+			switch {
+			case errs[i] != nil:
+				logger.Warn("Skipping "+r.FullName, "err", errs[i])
+				failed++
+			case reports[i] == nil:
+				empty++
+			default:
+				names = append(names, r.FullName)
+				scanned = append(scanned, reports[i])
+			}
+		}
+		logger.Info(fmt.Sprintf("✅ Scanned %d repositories; %d had no supported lockfiles, %d couldn't be scanned", len(scanned), empty, failed))
+		if len(scanned) == 0 {
+			return
+		}
+
+		switch orgOutput {
+		case outputText:
+			writeTextSummary(os.Stdout, scanner.SummarizeReports(names, scanned), orgTop)
+		case outputJSON:
+			err = writeJSON(os.Stdout, scanner.SummarizeReports(names, scanned))
+		default:
+			err = writeReport(os.Stdout, scanner.MergeReports(names, scanned), orgOutput)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		failing, partial := 0, false
+		for _, r := range scanned {
+			partial = partial || r.Partial
+			if orgFailOn != "" {
+				failing += r.Failing(orgFailOn)
+			}
+		}
+		if partial {
+			logger.Warn("Details of some advisories couldn't be fetched from OSV; results are incomplete.")
+		}
+		if orgFailOn != "" {
+			if failing > 0 {
+				failf("%d vulnerability(ies) at or above --fail-on=%s", failing, orgFailOn)
+			}
+			if failed > 0 || partial {
+				fatalf("Can't confirm nothing is at or above --fail-on=%s with incomplete results", orgFailOn)
+			}
+		}
+	},
+}
+
+var (
+	orgGitHubOrg          string
+	orgGitHubURL          string
+	orgGitLabGroup        string
+	orgGitLabURL          string
+	orgBitbucketWorkspace string
+	orgBitbucketProject   string
+	orgBitbucketURL       string
+	orgToken              string
+	orgIncludeArchived    bool
+	orgIncludeForks       bool
+	orgOutput             string
+	orgTop                int
+	orgFailOn             string
+	orgIgnoreFile         string
+	orgParallel           int
+	orgProdOnly           bool
+	orgNoCache            bool
+	orgOSVURL             string
+)
+
+func init() {
+	rootCmd.AddCommand(orgCmd)
+	orgCmd.AddCommand(orgScanCmd)
+
+	orgScanCmd.Flags().StringVar(&orgGitHubOrg, "github-org", "", "GitHub organization whose repositories to scan")
+	orgScanCmd.Flags().StringVar(&orgGitHubURL, "github-url", scanner.DefaultGitHubAPIURL, "base URL of the GitHub API, e.g. https://github.example.com/api/v3 for GitHub Enterprise Server")
+	orgScanCmd.Flags().StringVar(&orgGitLabGroup, "gitlab-group", "", "GitLab group whose projects, and its subgroups', to scan")
+	orgScanCmd.Flags().StringVar(&orgGitLabURL, "gitlab-url", scanner.DefaultGitLabURL, "URL of the GitLab instance")
+	orgScanCmd.Flags().StringVar(&orgBitbucketWorkspace, "bitbucket-workspace", "", "Bitbucket Cloud workspace whose repositories to scan")
+	orgScanCmd.Flags().StringVar(&orgBitbucketProject, "bitbucket-project", "", "key of the Bitbucket Server project whose repositories to scan")
+	orgScanCmd.Flags().StringVar(&orgBitbucketURL, "bitbucket-url", "", "URL of the Bitbucket Server or Data Center with --bitbucket-project, or of the Bitbucket Cloud API (default "+scanner.DefaultBitbucketURL+")")
+	orgScanCmd.Flags().StringVar(&orgToken, "token", "", "token to read the repositories with (default: $GITHUB_TOKEN, $GITLAB_TOKEN or $BITBUCKET_TOKEN, by host)")
+	orgScanCmd.Flags().BoolVar(&orgIncludeArchived, "include-archived", false, "scan archived repositories too")
+	orgScanCmd.Flags().BoolVar(&orgIncludeForks, "include-forks", false, "scan forks too")
+	orgScanCmd.Flags().StringVarP(&orgOutput, "output", "o", outputText, "output format: "+strings.Join(outputFormats, ", "))
+	orgScanCmd.Flags().IntVar(&orgTop, "top", 10, "number of the most widespread advisories to list (0 = all)")
+	orgScanCmd.Flags().StringVar(&orgFailOn, "fail-on", "", "exit non-zero if any finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	orgScanCmd.Flags().StringVar(&orgIgnoreFile, "ignore-file", "", "suppression rules to apply to every repository, instead of their .keystoneignore files")
+	orgScanCmd.Flags().IntVar(&orgParallel, "parallel", 4, "number of repositories to scan at once")
+	orgScanCmd.Flags().BoolVar(&orgProdOnly, "prod-only", false, "scan only runtime dependencies")
+	orgScanCmd.Flags().BoolVar(&orgNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	orgScanCmd.Flags().StringVar(&orgOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	addNetworkFlags(orgScanCmd)
+}
+
+/********** helpers **********/
+
+// orgProvider returns the code host of the organization given on the command
+// line, the organization's name there, and the environment variable the
+// token defaults to, filling orgToken in from it.
+func orgProvider(client *http.Client) (provider scanner.RepoProvider, org, tokenVar string) {
+	token := func(name string) string {
+		tokenVar = name
+		if orgToken == "" {
+			orgToken = os.Getenv(name)
+		}
+		return orgToken
+	}
+	switch {
+	case orgGitLabGroup != "":
+		return &scanner.GitLab{Client: client, URL: orgGitLabURL, Token: token("GITLAB_TOKEN")}, orgGitLabGroup, tokenVar
+	case orgBitbucketWorkspace != "":
+		return &scanner.Bitbucket{Client: client, URL: orgBitbucketURL, Token: token("BITBUCKET_TOKEN")}, orgBitbucketWorkspace, tokenVar
+	case orgBitbucketProject != "":
+		return &scanner.BitbucketServer{Client: client, URL: orgBitbucketURL, Token: token("BITBUCKET_TOKEN")}, orgBitbucketProject, tokenVar
+	default:
+		return &scanner.GitHub{Client: client, URL: orgGitHubURL, Token: token("GITHUB_TOKEN")}, orgGitHubOrg, tokenVar
+	}
+}
+
+// scanHostedRepo fetches the lockfiles of repo, and the .keystoneignore
+// files beside them, into a temporary directory and scans them. It returns
+// a nil report for a repository without lockfiles.
+func scanHostedRepo(ctx context.Context, provider scanner.RepoProvider, sc *scanner.Scanner, repo scanner.HostedRepo, rules []scanner.IgnoreRule) (*scanner.Report, error) {
+	files, complete, err := provider.RepoFiles(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	if !complete {
+		logger.Warn(fmt.Sprintf("%s has too many files for %s to list them all; some lockfiles may be missed", repo.FullName, provider.Name()))
+	}
+	lockfiles := scanner.SelectLockfiles(files)
+	if len(lockfiles) == 0 {
+		logger.Debug("No lockfiles", "repo", repo.FullName)
+		return nil, nil
+	}
+	fetch := lockfiles
+	if orgIgnoreFile == "" {
+		dirs := map[string]bool{}
+		for _, f := range lockfiles {
+			dirs[path.Dir(f)] = true
+		}
+		for _, f := range files {
+			if path.Base(f) == scanner.IgnoreFileName && dirs[path.Dir(f)] {
+				fetch = append(fetch, f)
+			}
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "keystone-org-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range fetch {
+		data, err := provider.ReadFile(ctx, repo, f)
+		if err != nil {
+			return nil, err
+		}
+		local := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err == nil {
+			err = os.WriteFile(local, data, 0o644)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, len(lockfiles))
+	for i, f := range lockfiles {
+		paths[i] = filepath.Join(dir, filepath.FromSlash(f))
+	}
+	report, err := scanLockfiles(ctx, sc, repo.FullName, paths, rules, orgIgnoreFile == "", func(p string) string {
+		rel, _ := filepath.Rel(dir, p)
+		return filepath.ToSlash(rel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info(fmt.Sprintf("✅ %s: %d package(s) in %d lockfile(s)", repo.FullName, report.Scanned, len(report.Projects)))
+	return report, nil
+}
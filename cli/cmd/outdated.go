@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var outdatedCmd = &cobra.Command{
+	Use:   "outdated [path-to-lockfile | directory]",
+	Short: "List dependencies that are behind their latest release",
+	Long: `Compares every locked dependency in a lockfile, or in each lockfile under a
+directory, with the latest stable release on its package registry, and lists
+those that are behind: how many major versions, and how many days ago the
+locked version was published. A dependency years or majors behind is a risk
+even without a known vulnerability: fixes land in versions it can't take
+without a migration, and nobody may be looking at the old line any more.
+
+  keystone outdated .
+  keystone outdated package-lock.json --max-majors 1 --max-age 730
+
+The registries of npm, PyPI, crates.io, Go modules (through the module
+proxy), RubyGems and Packagist are read; dependencies of other ecosystems
+aren't checked. Each has a flag to point at a mirror instead. Below 1.0.0,
+where every minor release may break, minor versions count as majors.
+
+With --max-majors or --max-age, the command exits non-zero if an outdated
+dependency is further behind than that, so it can gate CI. --all lists
+up-to-date dependencies too.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Clean(args[0])
+		dir := path
+		if !isDir(path) {
+			dir = filepath.Dir(path)
+		}
+		loadConfigFor(cmd, dir)
+
+		if outdatedOutput != outputText && outdatedOutput != outputJSON {
+			usagef("Unknown output format %q (want one of: %s, %s)", outdatedOutput, outputText, outputJSON)
+		}
+		if outdatedMaxMajors < -1 || outdatedMaxAge < -1 {
+			usagef("--max-majors and --max-age can't be negative")
+		}
+
+		inputs := []string{path}
+		if isDir(path) {
+			found, err := scanner.DiscoverLockfiles(path)
+			if err != nil {
+				fatal("Error searching for lockfiles", err)
+			}
+			if len(found) == 0 {
+				logger.Warn(fmt.Sprintf("No supported lockfiles found under %s.", path))
+				return
+			}
+			inputs = found
+		}
+
+		sc := &scanner.Scanner{ProdOnly: outdatedProdOnly}
+		var lockfiles []string
+		var deps [][]scanner.Package
+		var all []scanner.Package
+		for _, in := range inputs {
+			_, d, err := loadScanInput(in, false)
+			if err != nil && len(inputs) > 1 {
+				logger.Warn("Skipping "+in, "err", err)
+				continue
+			}
+			if err != nil {
+				fatal("Error", err)
+			}
+			d = sc.Scannable(d)
+			lockfiles = append(lockfiles, in)
+			deps = append(deps, d)
+			all = append(all, d...)
+		}
+
+		unchecked := map[string]int{}
+		for _, d := range all {
+			if !slices.Contains(scanner.OutdatedEcosystems, d.Ecosystem) {
+				unchecked[d.Ecosystem]++
+			}
+		}
+		for _, eco := range sortedKeys(unchecked) {
+			logger.Info(fmt.Sprintf("%d %s package(s) not checked: keystone can't read %s's registry", unchecked[eco], eco, eco))
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		releases, fetchErr := scanner.FetchLatestReleases(client, outdatedRegistries, all)
+		if ctx := cmd.Context(); ctx.Err() != nil {
+			fatalf("Lookup %s", interruption(ctx))
+		}
+		if fetchErr != nil {
+			logger.Warn("Some packages couldn't be looked up; results are incomplete", "err", fetchErr)
+		}
+
+		now := time.Now()
+		var entries []outdatedEntry
+		for i, in := range lockfiles {
+			var found []outdatedEntry
+			for _, o := range scanner.CheckOutdated(deps[i], releases) {
+				if outdatedAll || o.IsOutdated() {
+					found = append(found, newOutdatedEntry(in, o, now))
+				}
+			}
+			// Those furthest behind first.
+			slices.SortStableFunc(found, func(a, b outdatedEntry) int {
+				if a.MajorsBehind != b.MajorsBehind {
+					return b.MajorsBehind - a.MajorsBehind
+				}
+				return ageOf(b) - ageOf(a)
+			})
+			entries = append(entries, found...)
+		}
+
+		if outdatedOutput == outputJSON {
+			if entries == nil {
+				entries = []outdatedEntry{}
+			}
+			err = writeJSON(os.Stdout, entries)
+		} else {
+			writeOutdatedText(entries, len(lockfiles) > 1)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if outdatedMaxMajors < 0 && outdatedMaxAge < 0 {
+			return
+		}
+		var failing []string
+		for _, e := range entries {
+			if e.Outdated && ((outdatedMaxMajors >= 0 && e.MajorsBehind > outdatedMaxMajors) || (outdatedMaxAge >= 0 && e.AgeDays != nil && *e.AgeDays > outdatedMaxAge)) {
+				failing = append(failing, e.Package+"@"+e.Version)
+			}
+		}
+		if len(failing) > 0 {
+			failf("%d outdated package(s) beyond --max-majors/--max-age: %s", len(failing), strings.Join(failing, ", "))
+		}
+		if fetchErr != nil {
+			fatalf("Can't confirm nothing is beyond --max-majors/--max-age with incomplete results")
+		}
+	},
+}
+
+var (
+	outdatedOutput     string
+	outdatedProdOnly   bool
+	outdatedAll        bool
+	outdatedMaxMajors  int
+	outdatedMaxAge     int
+	outdatedRegistries scanner.Registries
+)
+
+func init() {
+	rootCmd.AddCommand(outdatedCmd)
+
+	outdatedCmd.Flags().StringVarP(&outdatedOutput, "output", "o", outputText, "output format: text, json")
+	outdatedCmd.Flags().BoolVar(&outdatedProdOnly, "prod-only", false, "leave out development dependencies")
+	outdatedCmd.Flags().BoolVar(&outdatedAll, "all", false, "list up-to-date dependencies too")
+	outdatedCmd.Flags().IntVar(&outdatedMaxMajors, "max-majors", -1, "exit non-zero if an outdated dependency is more than this many major versions behind")
+	outdatedCmd.Flags().IntVar(&outdatedMaxAge, "max-age", -1, "exit non-zero if an outdated dependency's locked version was published more than this many days ago")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.Npm, "npm-registry", scanner.DefaultNpmRegistry, "npm registry to read releases from")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.PyPI, "pypi-url", scanner.DefaultPyPIURL, "PyPI, or a mirror serving its JSON API, to read releases from")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.Crates, "crates-url", scanner.DefaultCratesURL, "crates.io, or a mirror serving its API, to read releases from")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.GoProxy, "go-proxy", scanner.DefaultGoProxyURL, "Go module proxy to read releases from")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.RubyGems, "rubygems-url", scanner.DefaultRubyGemsURL, "RubyGems server to read releases from")
+	outdatedCmd.Flags().StringVar(&outdatedRegistries.Packagist, "packagist-url", scanner.DefaultPackagistURL, "Composer repository serving Packagist's metadata to read releases from")
+	addNetworkFlags(outdatedCmd)
+}
+
+/********** helpers **********/
+
+// outdatedEntry is one dependency in the outdated listing.
+type outdatedEntry struct {
+	Lockfile     string     `json:"lockfile"`
+	Ecosystem    string     `json:"ecosystem"`
+	Package      string     `json:"package"`
+	Version      string     `json:"version"`
+	Latest       string     `json:"latest"`
+	Outdated     bool       `json:"outdated"`
+	MajorsBehind int        `json:"majors_behind"`
+	Published    *time.Time `json:"published,omitempty"`
+	AgeDays      *int       `json:"age_days,omitempty"`
+	Direct       bool       `json:"direct,omitempty"`
+	Dev          bool       `json:"dev,omitempty"`
+}
+
+func newOutdatedEntry(lockfile string, o scanner.Outdated, now time.Time) outdatedEntry {
+	e := outdatedEntry{
+		Lockfile: lockfile, Ecosystem: o.Ecosystem, Package: o.Name, Version: o.Version, Latest: o.Latest,
+		Outdated: o.IsOutdated(), MajorsBehind: o.MajorsBehind, Direct: o.Direct, Dev: o.Dev,
+	}
+	if age := o.AgeDays(now); age >= 0 {
+		e.Published, e.AgeDays = &o.Published, &age
+	}
+	return e
+}
+
+// writeOutdatedText lists the entries under their lockfiles.
+func writeOutdatedText(entries []outdatedEntry, multi bool) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	outdated, majors := 0, 0
+	lockfile := ""
+	for _, e := range entries {
+		if multi && e.Lockfile != lockfile {
+			if lockfile != "" {
+				fmt.Fprintln(tw)
+			}
+			lockfile = e.Lockfile
+			fmt.Fprintf(tw, "📁 %s\n", lockfile)
+		}
+		name := e.Package + "@" + e.Version
+		if e.Dev {
+			name += " (dev)"
+		}
+		behind := "up to date"
+		if e.Outdated {
+			outdated++
+			behind = "minor/patch"
+			if e.MajorsBehind > 0 {
+				majors++
+				behind = fmt.Sprintf("%d major(s)", e.MajorsBehind)
+			}
+		}
+		age := "?"
+		if e.AgeDays != nil {
+			age = fmt.Sprintf("%d days", *e.AgeDays)
+		}
+		fmt.Fprintf(tw, "  %s\t→ %s\t%s\t%s old\n", name, e.Latest, behind, age)
+	}
+	tw.Flush()
+
+	if len(entries) > 0 {
+		fmt.Println()
+	}
+	if outdated == 0 {
+		fmt.Println("✅ Every dependency checked is on its latest release.")
+		return
+	}
+	fmt.Printf("📦 %d outdated package(s), %d a major version or more behind\n", outdated, majors)
+}
+
+// ageOf is an entry's age in days for sorting, -1 when unknown.
+func ageOf(e outdatedEntry) int {
+	if e.AgeDays == nil {
+		return -1
+	}
+	return *e.AgeDays
+}
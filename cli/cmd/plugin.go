@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// parserPlugins are the --parser-plugin flags, "PATTERNS=COMMAND" each.
+	parserPlugins []string
+
+	// formatterPlugins are the --formatter-plugin flags, "NAME=COMMAND"
+	// each, and formatters the output formats they add, by name.
+	formatterPlugins []string
+	formatters       = map[string][]string{}
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringArrayVar(&parserPlugins, "parser-plugin", nil, `parse lockfiles whose names match PATTERNS (comma-separated, e.g. "*.deps") with an external program: "PATTERNS=COMMAND" (repeatable)`)
+	rootCmd.PersistentFlags().StringArrayVar(&formatterPlugins, "formatter-plugin", nil, `add an output format NAME, rendered from the JSON report by an external program: "NAME=COMMAND" (repeatable)`)
+	cobra.OnInitialize(registerParserPlugins, registerFormatterPlugins)
+}
+
+// registerParserPlugins adds a lockfile kind for each --parser-plugin, named
+// after its program. Like logging, it runs again once the config is read.
+func registerParserPlugins() {
+	for _, spec := range parserPlugins {
+		patterns, command, ok := strings.Cut(spec, "=")
+		args := strings.Fields(command)
+		if !ok || strings.TrimSpace(patterns) == "" || len(args) == 0 {
+			usagef("Invalid --parser-plugin %q (want PATTERNS=COMMAND, e.g. \"*.deps=./parse-deps\")", spec)
+		}
+		var names []string
+		for _, p := range strings.Split(patterns, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				names = append(names, p)
+			}
+		}
+		kind := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		if err := scanner.RegisterLockfile(scanner.LockfileKind(kind), names, scanner.ExecParser{Command: args}); err != nil {
+			usage("Invalid --parser-plugin", err)
+		}
+	}
+}
+
+// registerFormatterPlugins adds an output format for each --formatter-plugin.
+// Like registerParserPlugins, it runs again once the config is read.
+func registerFormatterPlugins() {
+	for _, spec := range formatterPlugins {
+		name, command, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		args := strings.Fields(command)
+		if !ok || name == "" || len(args) == 0 {
+			usagef("Invalid --formatter-plugin %q (want NAME=COMMAND, e.g. \"confluence=./to-wiki\")", spec)
+		}
+		if contains(outputFormats, name) {
+			usagef("Invalid --formatter-plugin %q: %s is a built-in output format", spec, name)
+		}
+		formatters[name] = args
+	}
+}
+
+// writePluginReport renders r with a formatter plugin: the program is given
+// the report as keystone's JSON output on stdin, and what it writes to
+// stdout is the report. A non-zero exit status fails with what it wrote to
+// stderr.
+func writePluginReport(w io.Writer, r *scanner.Report, command []string) error {
+	var in bytes.Buffer
+	if err := writeJSONReport(&in, r); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	c := exec.Command(command[0], command[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = &in, w, &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", command[0], msg)
+		}
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
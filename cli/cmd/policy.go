@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy [policy-file]",
+	Short: "List the fields policy rules can use, or check a policy file",
+	Long: `Policy files hold rules that 'keystone scan --policy' evaluates against its
+results, one per line:
+
+  action subject "name": expression
+
+The action is deny, which fails the scan, or warn, which only reports. The
+subject is vuln, to evaluate the expression for every advisory affecting a
+package, package, for every scanned package, or maintenance, for every
+package --maintenance finds unmaintained. For example:
+
+  # Block critical advisories left unfixed for a month.
+  deny vuln "stale-critical": severity >= "critical" && age > 30d && fixed
+  # Hold back releases too new to have been vetted.
+  deny package "too-fresh": release_age < 48h
+  warn vuln "dev-only": dev && severity >= "high"
+  warn package "copyleft": license matches "^(A|L)?GPL"
+  deny maintenance "abandoned": archived && direct
+  deny package "weak-practices": direct && scorecard < 4
+
+Expressions combine the fields listed below with numbers, "strings", true,
+false, durations (30d, 48h, 15m) and [lists], using == != < <= > >= in
+matches, && || ! and parentheses. Severities compare by rank. A comparison
+with a field whose value isn't known, such as release_age outside npm, is
+false.
+
+Given a policy file, checks that it parses and lists its rules.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			for _, subject := range []string{scanner.PolicyVuln, scanner.PolicyPackage, scanner.PolicyMaintenance} {
+				fmt.Printf("%s fields:\n", subject)
+				fields := scanner.PolicyFields(subject)
+				for _, name := range sortedKeys(fields) {
+					fmt.Printf("  %-16s %s\n", name, fields[name])
+				}
+			}
+			return
+		}
+
+		policy, err := scanner.LoadPolicy(args[0])
+		if err != nil {
+			fatal("Error reading policy", err)
+		}
+		for _, r := range policy.Rules {
+			fmt.Printf("  %d: %s %s %q: %s\n", r.Line, r.Action, r.Subject, r.Name, r.Expr)
+		}
+		fmt.Printf("✅ %d rule(s) in %s\n", len(policy.Rules), args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// progressRedraw is how often at most the progress bar is redrawn.
+const progressRedraw = 100 * time.Millisecond
+
+// progressBar draws a one-line progress bar on stderr while a scan looks up
+// packages and fetches advisories, with a count, the rate and an estimate of
+// the time left.
+type progressBar struct {
+	mu    sync.Mutex
+	w     io.Writer
+	stage string
+	start time.Time
+	drawn time.Time
+	width int // of the last line drawn, to blank it out
+
+	// looked and total count packages across every lockfile of a monorepo
+	// scan, whose lookups run side by side; finished counts the lockfiles
+	// done.
+	looked, total       int
+	finished, lockfiles int
+}
+
+// newProgressBar returns a bar for a scan of total packages in the given
+// number of lockfiles, or nil, which draws nothing, unless stdout and stderr
+// are both terminals and logging is plain text at the default level:
+// --quiet asks for silence, and the bar would garble debugging or JSON log
+// lines.
+func newProgressBar(total, lockfiles int) *progressBar {
+	if !isTerminal(os.Stdout) || !isTerminal(os.Stderr) || logQuiet || logVerbose || logFormat != logFormatText {
+		return nil
+	}
+	return &progressBar{w: os.Stderr, total: total, lockfiles: lockfiles}
+}
+
+// project returns the scanner.ProgressFunc of one lockfile's lookups. The
+// packages of every lockfile add up on the bar; the advisories fetched for
+// each are only shown when there is just the one.
+func (p *progressBar) project() scanner.ProgressFunc {
+	if p == nil {
+		return nil
+	}
+	last := 0
+	return func(stage string, done, total int) {
+		if stage != scanner.ProgressPackages {
+			if p.lockfiles == 1 {
+				p.update(stage, done, total)
+			}
+			return
+		}
+		p.mu.Lock()
+		p.looked += done - last
+		last = done
+		looked := p.looked
+		p.mu.Unlock()
+		p.update(stage, looked, p.total)
+	}
+}
+
+// update draws the bar at done of total in stage.
+func (p *progressBar) update(stage string, done, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if stage != p.stage {
+		p.stage, p.start = stage, now
+	}
+	if done < total && now.Sub(p.drawn) < progressRedraw {
+		return
+	}
+	p.drawn = now
+
+	const barWidth = 24
+	filled := 0
+	if total > 0 {
+		filled = barWidth * done / total
+	}
+	line := fmt.Sprintf("🔎 %-10s [%s%s] %d/%d", stage, strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), done, total)
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0.5 && done > 0 {
+		rate := float64(done) / elapsed
+		line += fmt.Sprintf(" · %.0f/s", rate)
+		if rate > 0 && done < total {
+			eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+			line += " · ETA " + eta.Round(time.Second).String()
+		}
+	}
+	if p.lockfiles > 1 {
+		line += fmt.Sprintf(" · %d/%d lockfiles", p.finished, p.lockfiles)
+	}
+	fmt.Fprintf(p.w, "\r%-*s", p.width, line)
+	p.width = utf8.RuneCountInString(line) + 1 // 🔎 takes two columns
+}
+
+// finishProject counts a lockfile as done and runs log, which logs that it
+// is, on a line of its own above the bar.
+func (p *progressBar) finishProject(log func()) {
+	if p == nil {
+		log()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finished++
+	p.erase()
+	log()
+}
+
+// clear erases the bar, so that the report starts on a clean line.
+func (p *progressBar) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+}
+
+func (p *progressBar) erase() {
+	if p.width > 0 {
+		fmt.Fprintf(p.w, "\r%s\r", strings.Repeat(" ", p.width))
+		p.width = 0
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The protobuf wire format, as much of it as the gRPC API's messages in
+// proto/keystone/v1/scanner.proto need.
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// protoWriter encodes a message. Fields left at their zero value are
+// omitted, as proto3 does.
+type protoWriter struct {
+	buf []byte
+}
+
+func (w *protoWriter) tag(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType))
+}
+
+func (w *protoWriter) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	w.tag(field, protoBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *protoWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
+
+func (w *protoWriter) strings(field int, list []string) {
+	for _, s := range list {
+		// Unlike a lone string, an empty element still counts.
+		w.tag(field, protoBytes)
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(s)))
+		w.buf = append(w.buf, s...)
+	}
+}
+
+func (w *protoWriter) int(field, n int) {
+	if n == 0 {
+		return
+	}
+	w.tag(field, protoVarint)
+	w.buf = binary.AppendUvarint(w.buf, uint64(int64(n)))
+}
+
+func (w *protoWriter) bool(field int, b bool) {
+	if b {
+		w.tag(field, protoVarint)
+		w.buf = append(w.buf, 1)
+	}
+}
+
+func (w *protoWriter) double(field int, f float64) {
+	if f == 0 {
+		return
+	}
+	w.tag(field, protoFixed64)
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(f))
+}
+
+// message writes the message encode writes as field, even if it's empty.
+func (w *protoWriter) message(field int, encode func(*protoWriter)) {
+	var m protoWriter
+	encode(&m)
+	w.tag(field, protoBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(m.buf)))
+	w.buf = append(w.buf, m.buf...)
+}
+
+// stringIntMap writes a map<string, int32>, in key order.
+func (w *protoWriter) stringIntMap(field int, m map[string]int) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.message(field, func(e *protoWriter) {
+			e.string(1, k)
+			e.int(2, m[k])
+		})
+	}
+}
+
+// protoField is a field read from a message: its varint or fixed value in
+// n, or its bytes.
+type protoField struct {
+	num   int
+	n     uint64
+	bytes []byte
+}
+
+// readProto splits an encoded message into its fields.
+func readProto(data []byte) ([]protoField, error) {
+	var out []protoField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("malformed protobuf: bad field key")
+		}
+		data = data[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case protoVarint:
+			if f.n, n = binary.Uvarint(data); n <= 0 {
+				return nil, errors.New("malformed protobuf: bad varint")
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return nil, errors.New("malformed protobuf: truncated fixed64")
+			}
+			f.n, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return nil, errors.New("malformed protobuf: truncated fixed32")
+			}
+			f.n, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, errors.New("malformed protobuf: truncated field")
+			}
+			f.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return nil, fmt.Errorf("malformed protobuf: unsupported wire type %d", key&7)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
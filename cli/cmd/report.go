@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputSARIF    = "sarif"
+	outputHTML     = "html"
+	outputJUnit    = "junit"
+	outputMarkdown = "markdown"
+)
+
+// outputFormats lists the values accepted by --output.
+var outputFormats = []string{outputText, outputJSON, outputSARIF, outputHTML, outputJUnit, outputMarkdown}
+
+// showDetails adds each advisory's affected versions, aliases, publication
+// date and references to text reports; see scan --details.
+var showDetails bool
+
+// validOutputFormat reports whether format is a built-in output format or
+// one added by a --formatter-plugin.
+func validOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	_, ok := formatters[format]
+	return ok
+}
+
+// allOutputFormats lists the built-in output formats, then those added by
+// plugins.
+func allOutputFormats() []string {
+	return append(append([]string{}, outputFormats...), sortedKeys(formatters)...)
+}
+
+func writeReport(w io.Writer, r *scanner.Report, format string) error {
+	if command, ok := formatters[format]; ok {
+		return writePluginReport(w, r, command)
+	}
+	switch format {
+	case outputJSON:
+		return writeJSONReport(w, r)
+	case outputSARIF:
+		return writeSARIFReport(w, r)
+	case outputHTML:
+		return writeHTMLReport(w, r)
+	case outputJUnit:
+		return writeJUnitReport(w, r)
+	case outputMarkdown:
+		return writeMarkdownReport(w, r)
+	default:
+		color := false
+		if f, ok := w.(*os.File); ok {
+			color = colorEnabled(f)
+		}
+		writeTextReport(w, r, color)
+		return nil
+	}
+}
+
+func writeJSONReport(w io.Writer, r *scanner.Report) error {
+	for _, p := range append([]*scanner.Report{r}, r.Projects...) {
+		if p.Findings == nil {
+			p.Findings = []scanner.Finding{} // "findings": [] rather than null
+		}
+		p.SchemaVersion = "" // a project read from a saved report has one
+	}
+	r.SchemaVersion = scanner.ReportSchemaVersion
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func writeTextReport(w io.Writer, r *scanner.Report, color bool) {
+	counts := map[string]int{}
+	if len(r.Projects) == 0 {
+		writeTextFindings(w, r, counts, color)
+	}
+	for _, p := range r.Projects {
+		fmt.Fprintf(w, "📁 %s (%s, %d packages)\n", p.Source, p.Lockfile, p.Scanned)
+		if p.VulnCount() == 0 && len(p.Suppressed) == 0 && len(p.LicenseViolations) == 0 && len(p.PolicyViolations) == 0 && len(p.SupplyChain) == 0 && len(p.Maintenance) == 0 && len(p.Scorecards) == 0 && len(p.Insights) == 0 {
+			if p.Scanned == 0 && len(p.Unchecked) > 0 {
+				fmt.Fprintln(w, "  ⚠️  Not checked: OSV has no advisories for "+strings.Join(sortedKeys(p.Unchecked), ", "))
+			} else {
+				fmt.Fprintln(w, "  ✅ No known vulnerabilities")
+			}
+			continue
+		}
+		writeTextFindings(w, p, counts, color)
+	}
+
+	if r.VulnCount() == 0 {
+		what := "this lockfile"
+		switch r.Lockfile {
+		case scanner.LockfileDirectory:
+			what = "these lockfiles"
+		case scanner.LockfileImage:
+			what = "this image"
+		case scanner.LockfileDockerfile:
+			what = "these base images"
+		}
+		fmt.Fprintf(w, "✅ No known vulnerabilities found for the packages in %s (per OSV).\n", what)
+		writeTextUnchecked(w, r)
+		return
+	}
+
+	if n := r.KnownExploited(); n > 0 {
+		fmt.Fprintf(w, "\n🔥 %d vulnerability(ies) are in CISA's Known Exploited Vulnerabilities catalog: remediate these first.\n", n)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Severity   Count")
+	fmt.Fprintln(w, "  ────────   ─────")
+	for _, sev := range scanner.SeverityOrder {
+		fmt.Fprintf(w, "  %s   %5d\n", colorize(fmt.Sprintf("%-8s", sev), sev, color), counts[sev])
+	}
+	writeTextWorkspaces(w, r)
+	writeTextUnchecked(w, r)
+}
+
+// writeTextUnchecked notes the packages that weren't checked because OSV
+// doesn't cover their ecosystem.
+func writeTextUnchecked(w io.Writer, r *scanner.Report) {
+	unchecked := r.UncheckedByEcosystem()
+	for _, eco := range sortedKeys(unchecked) {
+		fmt.Fprintf(w, "⚠️  %s\n", uncheckedMessage(eco, unchecked[eco]))
+	}
+}
+
+func uncheckedMessage(ecosystem string, n int) string {
+	return fmt.Sprintf("%d %s package(s) weren't checked: OSV has no advisories for %s.", n, ecosystem, ecosystem)
+}
+
+// writeTextWorkspaces summarises the findings of each workspace of a
+// monorepo's projects.
+func writeTextWorkspaces(w io.Writer, r *scanner.Report) {
+	for _, p := range r.Reports() {
+		if len(p.Workspaces) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n📦 Workspaces of %s:\n", p.Source)
+		width := 0
+		for _, ws := range p.Workspaces {
+			width = max(width, len(ws.Name))
+		}
+		for _, ws := range p.Workspaces {
+			packages, counts := 0, map[string]int{}
+			for _, f := range p.Findings {
+				if !contains(f.Workspaces, ws.Name) {
+					continue
+				}
+				packages++
+				for _, v := range f.Vulns {
+					counts[v.Severity]++
+				}
+			}
+			line := fmt.Sprintf("  %-*s  ", width, ws.Name)
+			if packages == 0 {
+				fmt.Fprintln(w, line+"✅ no known vulnerabilities")
+				continue
+			}
+			var bySeverity []string
+			for _, sev := range scanner.SeverityOrder {
+				if counts[sev] > 0 {
+					bySeverity = append(bySeverity, fmt.Sprintf("%d %s", counts[sev], sev))
+				}
+			}
+			fmt.Fprintf(w, "%s%d vulnerable package(s): %s\n", line, packages, strings.Join(bySeverity, ", "))
+		}
+	}
+}
+
+// advisoryName names v by its ID and, unless it's a CVE itself, the CVEs it
+// is about, which is how ticketing systems usually know it:
+// "GHSA-p6mc-m468-83gw (CVE-2020-8203)".
+func advisoryName(v scanner.Vulnerability) string {
+	cves := v.CVEs()
+	if len(cves) == 0 || cves[0] == v.ID {
+		return v.ID
+	}
+	return v.ID + " (" + strings.Join(cves, ", ") + ")"
+}
+
+// writeTextDetails prints what the full OSV record says about v, beyond
+// the summary line.
+func writeTextDetails(w io.Writer, v scanner.Vulnerability) {
+	if len(v.Affected) > 0 {
+		fmt.Fprintf(w, "       affects:    %s\n", strings.Join(v.Affected, "; "))
+	}
+	if len(v.Aliases) > 0 {
+		fmt.Fprintf(w, "       aliases:    %s\n", strings.Join(v.Aliases, ", "))
+	}
+	if v.Published != "" {
+		published := v.Published
+		if t, err := time.Parse(time.RFC3339, published); err == nil {
+			published = t.Format(time.DateOnly)
+		}
+		fmt.Fprintf(w, "       published:  %s\n", published)
+	}
+	if v.CVSS != "" && v.Environmental == nil {
+		fmt.Fprintf(w, "       cvss:       %s\n", v.CVSS)
+	}
+	for i, ref := range v.References {
+		label := ""
+		if i == 0 {
+			label = "references:"
+		}
+		fmt.Fprintf(w, "       %-11s %s\n", label, ref)
+	}
+}
+
+// formatEPSS renders an EPSS probability as a percentage.
+func formatEPSS(score float64) string {
+	return fmt.Sprintf("%.1f%%", score*100)
+}
+
+// writeTextFindings prints one lockfile's findings and suppressions, tallying
+// vulnerabilities by severity into counts.
+func writeTextFindings(w io.Writer, r *scanner.Report, counts map[string]int, color bool) {
+	for _, f := range r.Findings {
+		line := fmt.Sprintf("  🚨 %s@%s", f.Package, f.Version)
+		if f.Dev {
+			line += " (dev)"
+		}
+		line += fmt.Sprintf(" — %d vuln(s)", len(f.Vulns))
+		if f.FixedIn != "" {
+			line += fmt.Sprintf(" → upgrade to %s", f.FixedIn)
+		}
+		fmt.Fprintln(w, line)
+		if len(f.Path) > 0 {
+			fmt.Fprintf(w, "     ↳ %s\n", strings.Join(f.Path, " → "))
+		}
+		if len(f.Workspaces) > 0 {
+			fmt.Fprintf(w, "     📦 in %s\n", strings.Join(f.Workspaces, ", "))
+		}
+		switch f.Reachability {
+		case scanner.Reachable:
+			fmt.Fprintf(w, "     🎯 reachable: imported through %s\n", f.ImportedFrom)
+		case scanner.NotReachable:
+			fmt.Fprintln(w, "     💤 not reachable: nothing in the project's sources imports it")
+		}
+		for _, v := range f.Vulns {
+			if v.Error != "" {
+				fmt.Fprintf(w, "     ❌ %s → fetching details failed: %s\n", v.ID, v.Error)
+				continue
+			}
+			counts[v.Severity]++
+
+			label := v.Severity
+			if v.Environmental != nil {
+				label += fmt.Sprintf(" %.1f, base %s %.1f", v.Environmental.Score, v.Environmental.BaseSeverity, v.Score)
+			} else if v.Score > 0 {
+				label += fmt.Sprintf(" %.1f", v.Score)
+			}
+			if v.SeveritySource != "" {
+				label += " per " + strings.ToUpper(v.SeveritySource)
+			}
+			if v.EPSS != nil {
+				label += " · EPSS " + formatEPSS(v.EPSS.Score)
+			}
+			// Print ID + short summary (trim to one line)
+			s := firstLine(v.Summary)
+			if len(s) > 110 {
+				s = s[:110] + "…"
+			}
+			if v.Fixed == "" {
+				s += " (no fix available)"
+			}
+			kev := ""
+			if v.KEV != nil {
+				kev = colorize(" 🔥 KNOWN EXPLOITED", scanner.SeverityCritical, color)
+			}
+			fmt.Fprintf(w, "     • %s %s%s — %s\n", advisoryName(v), colorize("["+label+"]", v.Severity, color), kev, s)
+			if v.Environmental != nil {
+				fmt.Fprintf(w, "       %s\n", v.Environmental.Vector)
+			}
+			if showDetails {
+				writeTextDetails(w, v)
+			}
+		}
+	}
+
+	if len(r.Suppressed) > 0 {
+		fmt.Fprintf(w, "  🙈 %d suppressed finding(s):\n", len(r.Suppressed))
+		for _, s := range r.Suppressed {
+			line := fmt.Sprintf("     • %s@%s %s (rule %q", s.Package, s.Version, s.ID, s.Rule.Pattern)
+			if s.Rule.Expires != "" {
+				line += ", expires " + s.Rule.Expires
+			}
+			if s.Rule.Reason != "" {
+				line += ": " + s.Rule.Reason
+			}
+			fmt.Fprintln(w, line+")")
+		}
+	}
+
+	if len(r.LicenseViolations) > 0 {
+		fmt.Fprintf(w, "  ⚖️  %d package(s) with licenses the policy doesn't permit:\n", len(r.LicenseViolations))
+		for _, l := range r.LicenseViolations {
+			dev := ""
+			if l.Dev {
+				dev = " (dev)"
+			}
+			fmt.Fprintf(w, "     • %s@%s%s — %s\n", l.Package, l.Version, dev, l.License)
+		}
+	}
+
+	if len(r.SupplyChain) > 0 {
+		fmt.Fprintf(w, "  🕵️  %d supply-chain warning(s):\n", len(r.SupplyChain))
+		for _, s := range r.SupplyChain {
+			label := colorize("["+s.Severity+"]", s.Severity, color)
+			fmt.Fprintf(w, "     • %s@%s %s %s — %s\n", s.Package, s.Version, label, s.Kind, s.Detail)
+		}
+	}
+
+	if len(r.Maintenance) > 0 {
+		fmt.Fprintf(w, "  🏚️  %d package(s) that look unmaintained:\n", len(r.Maintenance))
+		for _, m := range r.Maintenance {
+			dev := ""
+			if m.Dev {
+				dev = " (dev)"
+			}
+			fmt.Fprintf(w, "     • %s@%s%s — %s\n", m.Package, m.Version, dev, m.Detail)
+		}
+	}
+
+	if len(r.Scorecards) > 0 {
+		fmt.Fprintf(w, "  🛡️  OpenSSF Scorecard of %d direct dependency(ies), lowest first:\n", len(r.Scorecards))
+		for _, s := range r.Scorecards {
+			line := fmt.Sprintf("     • %s@%s %.1f/10 (github.com/%s)", s.Package, s.Version, s.Score, s.Repository)
+			if weak := s.WeakestChecks(3); len(weak) > 0 {
+				line += " — weakest: " + strings.Join(weak, ", ")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(r.Insights) > 0 {
+		fmt.Fprintf(w, "  📊 deps.dev insights on %d direct dependency(ies), least depended on first:\n", len(r.Insights))
+		for _, in := range r.Insights {
+			line := fmt.Sprintf("     • %s@%s — %d dependent(s), %d direct", in.Package, in.Version, in.Dependents, in.DirectDependents)
+			if len(in.Licenses) > 0 {
+				line += "; " + strings.Join(in.Licenses, ", ")
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if len(r.PolicyViolations) > 0 {
+		fmt.Fprintf(w, "  📜 %d policy violation(s):\n", len(r.PolicyViolations))
+		for _, v := range r.PolicyViolations {
+			line := fmt.Sprintf("     • %s %q: %s@%s", v.Action, v.Rule, v.Package, v.Version)
+			if v.ID != "" {
+				line += " " + v.ID
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	if r.Resolution != nil {
+		fmt.Fprintf(w, "  📐 Resolved, not locked: versions are the newest package.json's ranges allowed on %s at scan time\n", r.Resolution.Registry)
+		for _, u := range r.Resolution.Unresolved {
+			fmt.Fprintf(w, "     • %s (not scanned)\n", u)
+		}
+	}
+
+	if r.Drift != nil && len(r.Drift.Packages) > 0 {
+		fmt.Fprintf(w, "  🔀 %d package(s) installed differently from %s:\n", len(r.Drift.Packages), r.Drift.Lockfile)
+		for _, d := range r.Drift.Packages {
+			installed, locked := strings.Join(d.Installed, ", "), strings.Join(d.Locked, ", ")
+			switch {
+			case installed == "":
+				fmt.Fprintf(w, "     • %s: locked at %s but not installed\n", d.Package, locked)
+			case locked == "":
+				fmt.Fprintf(w, "     • %s: %s installed but not in the lockfile\n", d.Package, installed)
+			default:
+				fmt.Fprintf(w, "     • %s: %s installed, %s locked\n", d.Package, installed, locked)
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportMergeOutput string
+	reportMergeTop    int
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Work with saved JSON reports",
+}
+
+var reportMergeCmd = &cobra.Command{
+	Use:   "merge report.json...",
+	Short: "Summarise the JSON reports of many projects in one",
+	Long: `Reads JSON reports written by 'keystone scan -o json', typically one per
+repository or pipeline, and summarises them org-wide: for each project, the
+lockfiles and packages scanned and its vulnerabilities by severity, then the
+totals and the advisories that affect the most projects (--top).
+
+Each project is named after its report's file name, or its path when two
+reports share a name. -o json writes the same summary as JSON; sarif, html,
+junit and markdown instead render every finding of the merged reports, with
+each lockfile's source prefixed by its project's name.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !validOutputFormat(reportMergeOutput) {
+			usagef("Unknown output format %q (want one of: %s)", reportMergeOutput, strings.Join(allOutputFormats(), ", "))
+		}
+		reports := make([]*scanner.Report, len(args))
+		for i, path := range args {
+			r, err := scanner.ReadReport(path)
+			if err != nil {
+				fatal("Error reading report", err)
+			}
+			reports[i] = r
+		}
+		names := reportNames(args)
+
+		var err error
+		switch reportMergeOutput {
+		case outputText:
+			writeTextSummary(os.Stdout, scanner.SummarizeReports(names, reports), reportMergeTop)
+		case outputJSON:
+			err = writeJSON(os.Stdout, scanner.SummarizeReports(names, reports))
+		default:
+			err = writeReport(os.Stdout, scanner.MergeReports(names, reports), reportMergeOutput)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportMergeCmd)
+
+	reportMergeCmd.Flags().StringVarP(&reportMergeOutput, "output", "o", outputText, "output format: "+strings.Join(outputFormats, ", "))
+	reportMergeCmd.Flags().IntVar(&reportMergeTop, "top", 10, "number of the most widespread advisories to list (0 = all)")
+}
+
+/********** helpers **********/
+
+// reportNames names each report file after its base name without the
+// extension, or its whole path without it when that name is taken twice.
+func reportNames(paths []string) []string {
+	base := func(p string) string { return strings.TrimSuffix(filepath.Base(p), filepath.Ext(p)) }
+	seen := map[string]int{}
+	for _, p := range paths {
+		seen[base(p)]++
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = base(p)
+		if seen[names[i]] > 1 {
+			names[i] = strings.TrimSuffix(filepath.Clean(p), filepath.Ext(p))
+		}
+	}
+	return names
+}
+
+func writeTextSummary(w io.Writer, s scanner.MergeSummary, top int) {
+	width := len("Project")
+	for _, p := range s.Projects {
+		width = max(width, len(p.Name))
+	}
+	header := fmt.Sprintf("  %-*s  Lockfiles  Packages  Vulnerable", width, "Project")
+	rule := fmt.Sprintf("  %s  ─────────  ────────  ──────────", strings.Repeat("─", width))
+	for _, sev := range scanner.SeverityOrder {
+		header += fmt.Sprintf("  %8s", sev)
+		rule += "  ────────"
+	}
+	row := func(p scanner.ProjectSummary) {
+		line := fmt.Sprintf("  %-*s  %9d  %8d  %10d", width, p.Name, p.Lockfiles, p.Scanned, p.Vulnerable)
+		for _, sev := range scanner.SeverityOrder {
+			line += fmt.Sprintf("  %8d", p.BySeverity[sev])
+		}
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "🏢 %d project(s), %d vulnerability(ies) in total\n\n", len(s.Projects), s.Totals.Vulns)
+	fmt.Fprintln(w, header)
+	fmt.Fprintln(w, rule)
+	for _, p := range s.Projects {
+		row(p)
+	}
+	fmt.Fprintln(w, rule)
+	row(s.Totals)
+
+	if len(s.Advisories) == 0 {
+		return
+	}
+	advisories := s.Advisories
+	if top > 0 && len(advisories) > top {
+		advisories = advisories[:top]
+	}
+	fmt.Fprintf(w, "\n📣 Most widespread advisories (%d of %d):\n", len(advisories), len(s.Advisories))
+	for _, a := range advisories {
+		line := fmt.Sprintf("  • %s [%s] in %d project(s): %s", a.ID, a.Severity, len(a.Projects), strings.Join(a.Projects, ", "))
+		if summary := firstLine(a.Summary); summary != "" {
+			line += " — " + summary
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -5,12 +5,38 @@ Copyright © 2025 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 
+// version is keystone's release, set when building one with
+// -ldflags "-X github.com/mdfaisal1/keystone/cli/cmd.version=v1.2.3".
+var version string
+
+// buildVersion returns version or, without one, the module version go
+// install recorded: "(devel)" for a build from a checkout.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// rootTimeout bounds how long any command may run (0 = no limit).
+var rootTimeout time.Duration
+
+// cancelTimeout releases the timer of the --timeout context.
+var cancelTimeout context.CancelFunc = func() {}
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -25,15 +51,40 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if rootTimeout > 0 {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), rootTimeout)
+			cmd.SetContext(ctx)
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Commands run with a context that is cancelled on Ctrl-C (or SIGTERM) and
+// when --timeout runs out, so that they can stop what they are doing and
+// report what they have. A second Ctrl-C exits at once.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitUsage) // cobra's errors are about flags and arguments
+	}
+}
+
+// interruption describes why ctx is done, for messages about work cut short.
+func interruption(ctx context.Context) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return "timed out after " + rootTimeout.String()
 	}
+	return "interrupted"
 }
 
 func init() {
@@ -42,6 +93,7 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cli.yaml)")
+	rootCmd.PersistentFlags().DurationVar(&rootTimeout, "timeout", 0, "give up after this long, e.g. 5m, reporting what was done by then (0 = no limit)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifLicenseRule is the rule license policy violations are reported under.
+const sarifLicenseRule = "keystone/license-policy"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations,omitempty"`
+	Results     []sarifResult     `json:"results"`
+}
+
+// sarifInvocation is only emitted for partial scans, to flag them as such.
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications"`
+}
+
+type sarifNotification struct {
+	Level   string       `json:"level"`
+	Message sarifMessage `json:"message"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string         `json:"id"`
+	ShortDescription sarifMessage   `json:"shortDescription"`
+	FullDescription  sarifMessage   `json:"fullDescription"`
+	HelpURI          string         `json:"helpUri,omitempty"`
+	Help             sarifMessage   `json:"help"`
+	Properties       map[string]any `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// writeSARIFReport renders the report as SARIF 2.1.0 for code-scanning tools.
+// Each advisory becomes a rule, and each vulnerable package a result against
+// that rule, located at the lockfile it was found in.
+func writeSARIFReport(w io.Writer, r *scanner.Report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "keystone",
+			InformationURI: "https://github.com/mdfaisal1/keystone",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	ruleSeen := map[string]bool{}
+	for _, p := range r.Reports() {
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(p.Source)
+
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if !ruleSeen[v.ID] {
+					ruleSeen[v.ID] = true
+					run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, newSARIFRule(v))
+				}
+				pkg := f.Package + "@" + f.Version
+				if f.Dev {
+					pkg = "Development dependency " + pkg
+				}
+				msg := fmt.Sprintf("%s is affected by %s: %s", pkg, advisoryName(v), firstLine(v.Summary))
+				if len(f.Path) > 0 {
+					msg += fmt.Sprintf(" Introduced via %s.", strings.Join(f.Path[:len(f.Path)-1], " → "))
+				}
+				if v.Fixed != "" {
+					msg += fmt.Sprintf(" Upgrade to %s or later.", v.Fixed)
+				}
+				if v.KEV != nil {
+					msg += " Known to be exploited in the wild (CISA KEV)."
+				}
+				result := sarifResult{
+					RuleID:    v.ID,
+					Level:     sarifLevel(v.Severity),
+					Message:   sarifMessage{Text: msg},
+					Locations: []sarifLocation{loc},
+				}
+				if len(v.Affected) > 0 {
+					result.Properties = map[string]any{"affected-ranges": v.Affected}
+				}
+				run.Results = append(run.Results, result)
+			}
+		}
+	}
+
+	if r.LicenseViolationCount() > 0 {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               sarifLicenseRule,
+			ShortDescription: sarifMessage{Text: "Dependency license not permitted by policy"},
+			FullDescription:  sarifMessage{Text: "A dependency's license isn't permitted by the project's license policy (--allow-license, --deny-license)."},
+			Help:             sarifMessage{Text: "Replace the dependency, or change the license policy if its license is acceptable."},
+			Properties:       map[string]any{"tags": []string{"license"}},
+		})
+		for _, p := range r.Reports() {
+			var loc sarifLocation
+			loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(p.Source)
+			for _, l := range p.LicenseViolations {
+				run.Results = append(run.Results, sarifResult{
+					RuleID:    sarifLicenseRule,
+					Level:     "error",
+					Message:   sarifMessage{Text: fmt.Sprintf("%s@%s is licensed under %s, which the license policy doesn't permit.", l.Package, l.Version, l.License)},
+					Locations: []sarifLocation{loc},
+				})
+			}
+		}
+	}
+
+	if r.Partial {
+		run.Invocations = []sarifInvocation{{
+			ExecutionSuccessful: false,
+			ToolExecutionNotifications: []sarifNotification{{
+				Level:   "error",
+				Message: sarifMessage{Text: fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV; results are incomplete.", r.Unfetched())},
+			}},
+		}}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}})
+}
+
+func newSARIFRule(v scanner.Vulnerability) sarifRule {
+	summary := firstLine(v.Summary)
+	if summary == "" {
+		summary = v.ID
+	}
+	rule := sarifRule{
+		ID:               v.ID,
+		ShortDescription: sarifMessage{Text: summary},
+		FullDescription:  sarifMessage{Text: summary},
+		HelpURI:          "https://osv.dev/vulnerability/" + v.ID,
+		Help:             sarifMessage{Text: strings.Join(append([]string{summary}, v.References...), "\n")},
+		Properties:       map[string]any{"tags": []string{"security", "vulnerability"}},
+	}
+	if len(v.Aliases) > 0 {
+		rule.Properties["aliases"] = v.Aliases
+	}
+	if v.Published != "" {
+		rule.Properties["published"] = v.Published
+	}
+	if v.CVSS != "" {
+		rule.Properties["cvss-vector"] = v.CVSS
+	}
+	if v.Score > 0 {
+		rule.Properties["security-severity"] = fmt.Sprintf("%.1f", v.Score)
+	} else if score, ok := sarifSecuritySeverity[v.Severity]; ok {
+		rule.Properties["security-severity"] = score
+	}
+	return rule
+}
+
+// sarifSecuritySeverity maps severity labels onto the numeric scale GitHub
+// code scanning uses to bucket alerts, for advisories without a CVSS score.
+var sarifSecuritySeverity = map[string]string{
+	scanner.SeverityCritical: "9.5",
+	scanner.SeverityHigh:     "8.0",
+	scanner.SeverityMedium:   "5.5",
+	scanner.SeverityLow:      "2.0",
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case scanner.SeverityCritical, scanner.SeverityHigh:
+		return "error"
+	case scanner.SeverityLow:
+		return "note"
+	default:
+		return "warning"
+	}
+}
+
+// firstLine returns the first line of s, trimmed.
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.Split(strings.TrimSpace(s), "\n")[0])
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// sbomFormats lists the values accepted by sbom --format.
+var sbomFormats = []string{scanner.SBOMCycloneDX, scanner.SBOMSPDX}
+
+var sbomFormat string
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom [path-to-lockfile]",
+	Short: "Generate a Software Bill of Materials from a lockfile",
+	Long: `Parses a lockfile (any format supported by scan) and writes an SBOM to stdout,
+as CycloneDX 1.5 JSON (default) or SPDX 2.3 JSON.
+
+Components carry package URLs, and licenses and dependency relationships
+where the lockfile records them (package-lock.json, composer.lock).`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lockfilePath := filepath.Clean(args[0])
+
+		data, err := os.ReadFile(lockfilePath)
+		if err != nil {
+			fatal("Error reading lockfile", err)
+		}
+
+		_, deps, err := scanner.ParseLockfile(lockfilePath, data)
+		if err != nil {
+			fatal("Error parsing lockfile", err)
+		}
+
+		// The project is named after the directory holding its lockfile.
+		project := filepath.Base(filepath.Dir(mustAbs(lockfilePath)))
+
+		switch sbomFormat {
+		case scanner.SBOMCycloneDX:
+			err = writeCycloneDX(os.Stdout, project, deps)
+		case scanner.SBOMSPDX:
+			err = writeSPDX(os.Stdout, project, deps)
+		default:
+			usagef("Unknown SBOM format %q (want one of: %s)", sbomFormat, strings.Join(sbomFormats, ", "))
+		}
+		if err != nil {
+			fatal("Error writing SBOM", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sbomCmd)
+
+	sbomCmd.Flags().StringVarP(&sbomFormat, "format", "f", scanner.SBOMCycloneDX, "SBOM format: "+strings.Join(sbomFormats, ", "))
+}
+
+func mustAbs(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// sbomPackages returns the deps that belong in an SBOM: those with a name and
+// version, once per package URL.
+func sbomPackages(deps []scanner.Package) []scanner.Package {
+	out := make([]scanner.Package, 0, len(deps))
+	seen := map[string]bool{}
+	for _, d := range deps {
+		if d.Name == "" || d.Version == "" || seen[scanner.PURL(d)] {
+			continue
+		}
+		seen[scanner.PURL(d)] = true
+		out = append(out, d)
+	}
+	return out
+}
@@ -1,161 +1,1484 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
 	"github.com/spf13/cobra"
 )
 
-type osvQuery struct {
-	Package struct {
-		Ecosystem string `json:"ecosystem"`
-		Name      string `json:"name"`
-	} `json:"package"`
-	Version string `json:"version"`
-}
+var scanCmd = &cobra.Command{
+	Use:   "scan [path-to-lockfile | directory | --sbom path-to-sbom | --installed project-dir | --repo git-url]",
+	Short: "Scan a project lockfile for vulnerabilities using OSV",
+	Long: `Parses a lockfile, queries the OSV batch API for all dependencies, and prints only vulnerable packages.
 
-type osvResp struct {
-	Vulns []struct {
-		ID      string `json:"id"`
-		Summary string `json:"summary"`
-		// (fields trimmed; we only print ID & summary for now)
-	} `json:"vulns"`
-}
+Supported lockfiles:
+  npm    package-lock.json (v1, v2 or v3), yarn.lock (v1 or Berry), pnpm-lock.yaml,
+         bun.lock, bun.lockb (read with bun, which must be installed)
+  Go     go.sum, go.mod
+  PyPI   requirements.txt (pinned), poetry.lock, Pipfile.lock
+  Rust   Cargo.lock
+  Maven  pom.xml, gradle.lockfile
+  Ruby   Gemfile.lock
+  PHP    composer.lock
+  NuGet  packages.lock.json, obj/project.assets.json, or the package references
+         of a .csproj, .fsproj or .vbproj, with versions from Directory.Packages.props
+  Dart   pubspec.lock (Dart and Flutter; OSV's Pub ecosystem)
+  Swift  Package.resolved (SwiftPM; OSV's SwiftURL ecosystem), Podfile.lock
+         (CocoaPods, which OSV has no advisories for: its pods are listed as
+         unchecked, not scanned)
+  Deno   deno.lock (v2 to v4; npm: packages under npm, jsr: ones under JSR), or
+         the exactly pinned imports of deno.json, deno.jsonc or import_map.json
+  GitHub Actions
+         .github/workflows/*.yml: the actions and reusable workflows used, at
+         the release a tag or a SHA's "# v1.2.3" comment names; --supply-chain
+         also warns about those not pinned to a commit SHA
+  Terraform
+         .terraform.lock.hcl: its registry providers, looked up as the Go modules
+         of their repositories, and the modules of the .tf files beside it
+         (which OSV has no advisories for); --supply-chain also warns about
+         modules not pinned to an exact version or commit SHA
 
-var scanCmd = &cobra.Command{
-	Use:   "scan [path-to-package-lock.json]",
-	Short: "Scan a Node.js project (package-lock.json) for vulnerabilities using OSV",
-	Long:  "Parses package-lock.json (v2/v3 style), queries the OSV API per dependency, and prints only vulnerable packages.",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		lockfilePath := filepath.Clean(args[0])
+Other formats, such as an in-house manifest, can be added without
+rebuilding keystone: --parser-plugin "*.deps=./parse-deps" parses files
+named *.deps by running ./parse-deps with the file's path as its argument
+and contents on stdin. It writes their packages to stdout as JSON, with the
+OSV ecosystem of each:
+
+  {"packages": [{"ecosystem": "npm", "name": "lodash", "version": "4.17.15",
+    "direct": true, "dev": false}]}
+
+Likewise --formatter-plugin "wiki=./to-wiki" adds an output format, -o wiki,
+rendered by a program of your own: it is given the report as -o json writes
+it on stdin, and what it writes to stdout is the report.
+
+Given a directory, scan walks it (skipping node_modules, vendor, .git and
+.terraform), scans every supported lockfile it finds and reports the results
+per project. --parallel lockfiles are looked up at a time, sharing the cache,
+connections and the --concurrency limit on OSV requests, and each is logged
+as it finishes.
+
+--repo does the same for a remote Git repository, such as a third-party
+project being audited, without a checkout of your own: it is shallow-cloned
+into a temporary directory, which is removed afterwards, and lockfiles are
+reported by their path in the repository. Settings come from the keystone
+config in the current directory, not the repository's own, though a
+.keystoneignore beside a lockfile still applies. --ref scans a branch or tag
+other than the default one.
+
+Development dependencies are scanned too and labelled "(dev)" in findings;
+--prod-only leaves them out, for pipelines that gate on runtime dependencies.
+Lockfiles that don't record the distinction (yarn.lock, go.sum, Gemfile.lock,
+...) are treated as all-runtime.
+
+Alternatively, --sbom scans the components of an existing CycloneDX or SPDX
+JSON SBOM, identified by their package URLs.
+
+With --installed, the packages actually installed under the project's
+node_modules are scanned instead, read from each one's package.json, for
+projects with no lockfile or one that's out of date. If a package-lock.json,
+npm-shrinkwrap.json, yarn.lock, pnpm-lock.yaml or bun.lock sits beside node_modules,
+packages installed at versions other than the locked ones are listed as drift.
+
+A package.json given in place of a lockfile, for a project with no lockfile
+at all, is resolved against the npm registry (or --npm-registry): each
+dependency range, and those of its dependencies in turn, to the version a
+fresh install would most likely get today. This is best-effort, and the
+report is marked as resolved, not locked.
+
+For an npm, yarn, pnpm or Bun project that declares workspaces, in package.json
+or pnpm-workspace.yaml, each finding names the workspaces that depend on
+the vulnerable package, and the report ends with a summary per workspace.
+--workspace scans just the dependencies of one of them.
+
+Defaults for any of the flags below can be kept in a keystone.yaml (or
+.keystone.toml) in the project directory, or in config.yaml under
+~/.config/keystone for every project, as "flag-name: value" lines. Flags given
+on the command line take precedence. An "ignore" list there holds suppression
+rules in .keystoneignore syntax.
+
+To adopt keystone in a project with existing findings, record them once with
+--write-baseline baseline.json and scan with --baseline baseline.json from
+then on: recorded findings are reported as suppressed, and --fail-on only
+fails on new ones.
+
+--vex applies the statements of OpenVEX or CSAF VEX documents, from a
+vendor or your own triage: advisories declared not_affected or fixed for a
+package, named by package URL, are reported as suppressed with the
+statement's justification. 'keystone vex' writes such a document from the
+decisions in your ignore file.
+
+Advisories come from OSV unless --sources names others, in order of
+preference: "osv", "ghsa" for the GitHub Advisory Database, read through
+GitHub's GraphQL API with $GITHUB_TOKEN, and "deps.dev". With several, as in
+--sources osv,ghsa, a package is affected by the advisories any of them
+reports, each once by ID, with the record of the first source that has it;
+a source that's down is left out with a warning, so the scan goes on with
+the others.
+
+--epss adds each advisory's EPSS score, FIRST's estimate of how likely its
+CVE is to be exploited in the next 30 days, from the EPSS API; --epss-file
+reads them from a downloaded epss_scores-YYYY-MM-DD.csv.gz instead, for
+offline use. --sort epss lists the likeliest exploits first, and
+--fail-on-epss fails the scan on advisories scored at or above a probability.
+
+--kev flags advisories whose CVE is in CISA's Known Exploited
+Vulnerabilities catalog, fetched from CISA or read from a downloaded copy
+with --kev-file; --fail-on-kev fails the scan on any of them.
+
+With --offline, --epss and --kev read the EPSS dataset and KEV catalog that
+'keystone bundle import' installs alongside the OSV database.
+
+--tui opens the findings in an interactive browser instead of printing a
+report: filter them by severity (s) or package name (/), open an advisory's
+details (Enter), and ignore it (i), which adds a rule to the project's
+ignore file (or --ignore-file). The scan's exit status doesn't reflect
+--fail-on and the other gates in this mode.
+
+--allow-license and --deny-license check dependency licenses against a
+policy as well, failing the scan on any that isn't permitted; see
+'keystone license'.
+
+--supply-chain looks for signs of malicious or unmaintained packages beyond
+known advisories, each reported with a severity of its own: names one typo
+away from a popular package (high), npm versions published by someone who
+hadn't published the package before (medium) or less than --new-release-age
+ago (low), npm packages that run install scripts (low), and npm versions
+their maintainers have deprecated (medium), which no longer get security
+fixes. The npm checks ask the registry, so --offline leaves them out.
+--fail-on-supply-chain fails the scan on warnings at or above a severity.
+
+--verify-signatures adds to those checks the npm registry's signature of
+each package version, which must match the lockfile's integrity hash, and
+the Sigstore provenance attestation linking it to the source and build it
+came from. Unsigned versions are a medium warning, those without provenance
+a low one, and a signature or attestation that doesn't verify a high one.
+
+--maintenance reports packages that look unmaintained, and so get no
+security fixes: those with nothing released for more than --stale-years,
+by their registry (npm, PyPI, crates.io, Go, RubyGems and Packagist), and
+those whose GitHub repository is archived ($GITHUB_TOKEN, if set, raises
+GitHub's rate limit). Policy rules about maintenance, such as
+'deny maintenance "abandoned": archived && direct', act on them.
+
+--scorecard looks up the OpenSSF Scorecard of each direct dependency's
+GitHub repository, as its registry links it: a 0-10 score of how well the
+project follows security practices such as code review, branch protection
+and pinned dependencies. Policy rules can set a minimum, such as
+'deny package "weak-practices": direct && scorecard < 4'.
+
+--deps-dev adds deps.dev, Google's Open Source Insights, to the advisory
+sources (see --sources): those it links to a package version that the
+others don't are reported too, with deps.dev's record of them, which names
+no fixed version. It also fills in the licenses lockfiles don't record, for the license
+checks, and lists how many packages depend on each direct dependency, which
+policy rules can use as 'dependents'. --offline leaves it out.
+
+--policy evaluates the rules of a policy file against the results, one per
+line, such as:
+
+  deny vuln "stale-critical": severity >= "critical" && age > 30d && fixed
+  deny package "too-fresh": release_age < 48h
+
+Violations are listed in the report; those of deny rules fail the scan and
+those of warn rules don't. 'keystone policy' lists the fields rules can use
+and checks a policy file.
+
+--notify posts a summary of the findings to a Slack or Microsoft Teams
+channel, given its incoming webhook URL with https:// replaced by slack:// or
+teams://. With --baseline only new findings are reported, which suits scans
+run from cron or CI; nothing is posted when there are none, or none at or
+above --notify-on. The webhooks can be set in the config file instead:
+
+  notify:
+    - slack://hooks.slack.com/services/T000/B000/XXXX
+  notify-on: high
+
+--jira-url and --jira-project open a Jira issue for each advisory at or above
+--jira-severity (high by default), with the package, the version fixing it
+and a link to the advisory. Issues are labelled so that later scans find
+them, open or closed, rather than opening duplicates. Jira Cloud takes an
+account email and API token, from --jira-user and --jira-token or $JIRA_USER
+and $JIRA_API_TOKEN; Data Center takes a personal access token alone.
+
+--webhook POSTs the JSON report, as -o json writes it, to a URL of your own,
+with any --webhook-header added. Given --webhook-secret or
+$KEYSTONE_WEBHOOK_SECRET, the body is signed the way GitHub signs webhooks:
+the X-Keystone-Signature-256 header holds "sha256=" and the hex HMAC-SHA256
+of the body under the secret.
+
+--history records the findings of each lockfile in a history file, so that
+'keystone history' can show what changed between scans and when an advisory
+first appeared.
+
+--export uploads the findings to a vulnerability management platform, each
+configured by flags of its own. --export defectdojo reimports them, as SARIF,
+into the --defectdojo-product and --defectdojo-engagement of the DefectDojo
+at --defectdojo-url, creating them if need be, so that DefectDojo tracks the
+findings across scans and closes those that are fixed. It takes an API key
+from --defectdojo-token or $DEFECTDOJO_API_KEY.
+
+--reachability reads the imports of the project's JavaScript and TypeScript
+sources and marks each npm finding reachable, with the file the imports start
+from, or not reachable. With node_modules installed, imports are followed
+through the installed packages' code, so a transitive dependency is reachable
+only when something the project loads loads it; without, a dependency counts
+as reachable when the direct dependency pulling it in is imported. Analysis is
+per package, not per function, and misses requires of computed names.
+--hide-unreachable suppresses the findings that aren't reachable.
+
+--cvss-env rescores advisories with a CVSS v3 vector for your deployment,
+given CVSS v3.1 environmental metrics: modified base metrics such as MAV:L
+for a service only reachable locally, and the CR, IR and AR security
+requirements. Severity, and so --fail-on, then follows the environmental
+score; the report shows the full vector it was computed from beside the
+base score. It suits the config file, set once per deployment:
+
+  cvss-env: MAV:A/MPR:L/CR:H
+
+Some OSV records carry no CVSS vector, leaving their advisories unscored or
+rated only by the advisory database. --severity-fallback looks those up in
+the GitHub Advisory Database and then the NVD, and scores them with the
+first vector found, so that --fail-on doesn't pass them over. Lookups are
+cached like OSV responses. $GITHUB_TOKEN and $NVD_API_KEY, if set, raise
+the APIs' rate limits; without a key the NVD allows 5 requests in 30 seconds.
+
+--details lists, under each advisory in the text report, the versions it
+affects, its aliases such as CVEs, when it was published and its references,
+from the full OSV record. JSON and SARIF reports always include them.
+
+Advisories are listed with the CVEs they are about. A CVE can stand in for
+an advisory wherever one is named: in .keystoneignore rules, to suppress the
+advisories that are aliases of it, and in --vuln, which reports only the
+given advisories, e.g. to check whether a project is affected by one:
 
-		data, err := os.ReadFile(lockfilePath)
+  keystone scan --vuln CVE-2021-23337 .
+
+Ctrl-C, or --timeout running out, stops the scan without losing its work:
+the lockfiles scanned by then are reported, with the details of advisories
+not yet fetched missing, and the scan exits 3 without sending the report on
+to notifications, webhooks, Jira, exporters or the history. A second Ctrl-C
+exits at once.
+
+Exit status:
+  0  nothing failed
+  1  findings failed a threshold: --fail-on and its kin, the license policy
+     or deny rules
+  2  the command was used wrongly: an unknown flag, a bad argument or value
+  3  the scan failed or didn't finish: OSV couldn't be queried, the scan was
+     interrupted, or a threshold can't be checked against partial results
+
+A scan that finishes with some advisories' details missing, lockfiles it
+couldn't read or package.json ranges it couldn't resolve only warns about
+them, and exits 0 if nothing found fails; --error-on-incomplete makes it
+exit 3, so that CI can tell "no vulnerabilities" from "couldn't tell".
+
+--otlp-endpoint, or the standard $OTEL_EXPORTER_OTLP_ENDPOINT, sends an
+OpenTelemetry trace of the scan to a collector over OTLP/HTTP, with a span
+for parsing each lockfile, looking up its packages, deduplicating and
+fetching their advisories, each enrichment such as EPSS and KEV, and
+rendering the report, so that slow scans in CI can be diagnosed. Headers the
+collector needs come from $OTEL_EXPORTER_OTLP_HEADERS, and a $TRACEPARENT
+set by the CI system makes the scan part of the pipeline's trace.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.MaximumNArgs(1)(cmd, args); err != nil {
+			return err
+		}
+		inputs := len(args)
+		for _, set := range []bool{scanSBOM != "", scanRepo != ""} {
+			if set {
+				inputs++
+			}
+		}
+		if inputs != 1 {
+			return fmt.Errorf("provide one of a lockfile or directory path, --sbom or --repo")
+		}
+		if scanRef != "" && scanRepo == "" {
+			return fmt.Errorf("--ref needs --repo")
+		}
+		if scanInstalled && (len(args) == 0 || !isDir(args[0])) {
+			return fmt.Errorf("--installed needs the project directory holding node_modules")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		scanStarted := time.Now().UTC()
+		projectDir := filepath.Dir(scanSBOM)
+		if scanRepo != "" {
+			projectDir = "."
+		} else if len(args) == 1 {
+			if projectDir = filepath.Clean(args[0]); !isDir(projectDir) {
+				projectDir = filepath.Dir(projectDir)
+			}
+		}
+		cfg := loadConfigFor(cmd, projectDir)
+		configRules, err := cfg.ignoreRules()
 		if err != nil {
-			fmt.Println("❌ Error reading lockfile:", err)
-			os.Exit(1)
+			fatal("Error reading config", err)
 		}
 
-		var lock map[string]any
-		if err := json.Unmarshal(data, &lock); err != nil {
-			fmt.Println("❌ Invalid JSON:", err)
-			os.Exit(1)
+		if !validOutputFormat(scanOutput) {
+			usagef("Unknown output format %q (want one of: %s)", scanOutput, strings.Join(allOutputFormats(), ", "))
+		}
+		if scanProdOnly {
+			scanIncludeDev = false
+		}
+		if scanFailOn != "" && !scanner.ValidFailOn(scanFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", scanFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		if scanFailOnSupplyChain != "" && !scanner.ValidFailOn(scanFailOnSupplyChain) {
+			usagef("Unknown --fail-on-supply-chain level %q (want one of: %s)", scanFailOnSupplyChain, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		if scanFailOnSupplyChain != "" || scanVerifySignatures {
+			scanSupplyChain = true
+		}
+		if scanStaleYears < 1 {
+			usagef("--stale-years must be at least 1, not %d", scanStaleYears)
+		}
+		if scanSort != "" && !contains(scanner.SortOrders, scanSort) {
+			usagef("Unknown --sort order %q (want one of: %s)", scanSort, strings.Join(scanner.SortOrders, ", "))
+		}
+		if scanFailOnEPSS < 0 || scanFailOnEPSS > 1 {
+			usagef("--fail-on-epss is a probability between 0 and 1, not %g", scanFailOnEPSS)
+		}
+		// Offline, EPSS scores and the KEV catalog come from an imported
+		// bundle rather than their APIs.
+		if scanOffline && scanEPSS && scanEPSSFile == "" {
+			scanEPSSFile = offlineDataFile(scanner.BundleEPSSFile, "EPSS scores", "epss-file")
+		}
+		if scanOffline && (scanKEV || scanFailOnKEV) && scanKEVFile == "" {
+			scanKEVFile = offlineDataFile(scanner.BundleKEVFile, "KEV catalog", "kev-file")
+		}
+		if scanParallel < 1 {
+			usagef("--parallel must be at least 1, not %d", scanParallel)
+		}
+		sources := scanAdvisorySources()
+		if scanFailOnEPSS > 0 && !scanEPSS && scanEPSSFile == "" {
+			usagef("--fail-on-epss needs EPSS scores from --epss or --epss-file")
+		}
+		for _, eco := range scanEcosystems {
+			if !validEcosystem(eco) {
+				usagef("Unknown ecosystem %q (want one of: %s)", eco, strings.Join(scanner.Ecosystems(), ", "))
+			}
+		}
+		var notifyTargets []notifyTarget
+		for _, u := range scanNotify {
+			t, err := parseNotifyURL(u)
+			if err != nil {
+				usage("Error", err)
+			}
+			notifyTargets = append(notifyTargets, t)
+		}
+		if scanNotifyOn != "" && !scanner.ValidFailOn(scanNotifyOn) {
+			usagef("Unknown --notify-on level %q (want one of: %s)", scanNotifyOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		webhookHeaders, err := parseWebhookHeaders(scanWebhookHeaders)
+		if err != nil {
+			usage("Error", err)
+		}
+		if len(scanWebhookHeaders) > 0 && scanWebhook == "" {
+			usagef("--webhook-header needs --webhook")
+		}
+		var jira *jiraClient
+		if scanJiraURL != "" {
+			if !scanner.ValidFailOn(scanJiraSeverity) {
+				usagef("Unknown --jira-severity level %q (want one of: %s)", scanJiraSeverity, strings.Join(scanner.FailOnLevels, ", "))
+			}
+			if jira, err = newJiraClient(); err != nil {
+				usage("Error", err)
+			}
+		}
+		var cvssEnv scanner.CVSSEnvironment
+		if scanCVSSEnv != "" {
+			if cvssEnv, err = scanner.ParseCVSSEnvironment(scanCVSSEnv); err != nil {
+				usage("Error", err)
+			}
+		}
+		var exports []exporter
+		for _, name := range scanExport {
+			e, ok := exporters[name]
+			if !ok {
+				usagef("Unknown exporter %q (want one of: %s)", name, strings.Join(exporterNames(), ", "))
+			}
+			if err := e.configure(); err != nil {
+				usage("Error", err)
+			}
+			exports = append(exports, e)
+		}
+		var policy *scanner.Policy
+		if scanPolicy != "" {
+			if policy, err = scanner.LoadPolicy(scanPolicy); err != nil {
+				fatal("Error reading policy", err)
+			}
 		}
 
-		// Extract deps from "packages" block (npm lockfile v2/v3).
-		deps := extractNpmPackages(lock)
-		if len(deps) == 0 {
-			fmt.Println("⚠️  No dependencies found in lockfile (expected npm lockfile v2/v3).")
-			return
+		// A directory is scanned as a monorepo: every lockfile beneath it
+		// becomes a project of its own.
+		var inputs []string
+		root, rootLabel := "", ""
+		if scanRepo != "" {
+			dir, err := cloneRepo(scanRepo, scanRef)
+			if err != nil {
+				fatal("Error cloning "+scanRepo, err)
+			}
+			defer os.RemoveAll(dir)
+			onExit(func() { os.RemoveAll(dir) })
+			found, err := scanner.DiscoverLockfiles(dir)
+			if err != nil {
+				fatal("Error searching for lockfiles", err)
+			}
+			if len(found) == 0 {
+				logger.Warn(fmt.Sprintf("No supported lockfiles found in %s.", scanRepo))
+				return
+			}
+			root, rootLabel, inputs = dir, scanRepo, found
+		} else if scanSBOM != "" {
+			inputs = []string{filepath.Clean(scanSBOM)}
+		} else if path := filepath.Clean(args[0]); scanInstalled {
+			inputs = []string{filepath.Join(path, "node_modules")}
+		} else if isDir(path) {
+			found, err := scanner.DiscoverLockfiles(path)
+			if err != nil {
+				fatal("Error searching for lockfiles", err)
+			}
+			if len(found) == 0 {
+				logger.Warn(fmt.Sprintf("No supported lockfiles found under %s.", path))
+				return
+			}
+			root, rootLabel, inputs = path, path, found
+		} else {
+			inputs = []string{path}
 		}
 
-		fmt.Printf("🔎 Scanning %d packages from: %s\n", len(deps), lockfilePath)
+		type project struct {
+			path, kind string
+			deps       []scanner.Package
+			workspaces []scanner.Workspace
+		}
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
 
-		vulnCount := 0
-		for _, d := range deps {
-			// Skip the root "" entry and empty versions.
-			if d.name == "" || d.version == "" {
+		target := rootLabel
+		if target == "" {
+			target = inputs[0]
+		}
+		ctx, endTrace := startTrace(cmd.Context(), client, scanOTLPEndpoint, "keystone scan", "keystone.target", target)
+		defer endTrace()
+		var (
+			sc         = &scanner.Scanner{ProdOnly: !scanIncludeDev}
+			projects   []project
+			queryable  []scanner.Package
+			skipped    int // lockfiles that couldn't be read
+			drift      *scanner.Drift
+			resolution *scanner.Resolution
+		)
+		for _, path := range inputs {
+			if ctx.Err() != nil {
+				fatalf("Scan %s while reading lockfiles", interruption(ctx))
+			}
+			var (
+				kind string
+				deps []scanner.Package
+				err  error
+			)
+			_, span := scanner.StartSpan(ctx, "parse", "keystone.lockfile", path)
+			if scanInstalled {
+				kind = scanner.LockfileNodeModules
+				if deps, err = scanner.ReadNodeModules(filepath.Dir(path)); err != nil {
+					err = fmt.Errorf("reading installed packages: %w", err)
+				} else if drift, err = installedDrift(filepath.Dir(path), deps); err != nil {
+					logger.Warn("Can't check node_modules against the lockfile", "err", err)
+					err = nil
+				}
+			} else if scanSBOM == "" && filepath.Base(path) == scanner.LockfilePackageJSON {
+				kind = scanner.LockfilePackageJSON
+				if deps, resolution, err = resolvePackageJSON(client, path); err == nil && len(resolution.Unresolved) > 0 {
+					logger.Warn(fmt.Sprintf("%d dependency(ies) of %s couldn't be resolved and aren't scanned", len(resolution.Unresolved), path))
+				}
+			} else {
+				kind, deps, err = loadScanInput(path, scanSBOM != "")
+			}
+			span.SetAttrs("keystone.lockfile.kind", kind, "keystone.packages", len(deps))
+			span.Fail(err)
+			span.Finish()
+			if err != nil && root != "" {
+				// One odd file shouldn't stop the rest of a monorepo scan.
+				logger.Warn("Skipping "+repoRelative(root, path), "err", err)
+				skipped++
 				continue
 			}
+			if err != nil {
+				fatal("Error", err)
+			}
+			if len(deps) == 0 && root == "" && scanOutput == outputText {
+				logger.Warn(fmt.Sprintf("No dependencies found in %s input.", kind))
+				return
+			}
 
-			// Build OSV query
-			var q osvQuery
-			q.Package.Ecosystem = "npm"
-			q.Package.Name = d.name
-			q.Version = d.version
+			var workspaces []scanner.Workspace
+			if isNpmLockfile(kind) {
+				if workspaces, err = scanner.FindWorkspaces(filepath.Dir(path)); err != nil {
+					logger.Warn("Can't read the workspaces of "+repoRelative(root, path), "err", err)
+				}
+			}
+			if scanWorkspace != "" {
+				if deps, err = scanner.WorkspacePackages(deps, workspaces, scanWorkspace); err != nil {
+					logger.Debug("Skipping "+path, "err", err)
+					continue
+				}
+			}
 
-			payload, _ := json.Marshal(q)
-			resp, err := http.Post("https://api.osv.dev/v1/query", "application/json", bytes.NewBuffer(payload))
+			deps = filterEcosystems(sc.Scannable(deps), scanEcosystems)
+			logger.Debug("Parsed "+kind, "path", path, "packages", len(deps), "workspaces", len(workspaces))
+			projects = append(projects, project{path, kind, deps, workspaces})
+			queryable = append(queryable, deps...)
+		}
+
+		if len(projects) == 0 && scanWorkspace != "" {
+			where := rootLabel
+			if root == "" {
+				where = inputs[0]
+			}
+			usagef("No workspace named %q in %s", scanWorkspace, where)
+		}
+		if len(projects) == 0 {
+			fatalf("None of the lockfiles under %s could be parsed", rootLabel)
+		}
+
+		if root != "" {
+			logger.Info(fmt.Sprintf("🔎 Scanning %d packages in %d lockfiles under: %s", len(queryable), len(projects), rootLabel))
+		} else {
+			logger.Info(fmt.Sprintf("🔎 Scanning %d packages from: %s", len(queryable), inputs[0]))
+		}
+
+		bar := newProgressBar(len(queryable), len(projects))
+		sc.Source, err = sourceOptions{
+			offline:     scanOffline,
+			noCache:     scanNoCache,
+			concurrency: scanConcurrency,
+			rateLimit:   scanRateLimit,
+			cacheTTL:    scanCacheTTL,
+			osvURL:      scanOSVURL,
+			client:      client,
+			sources:     sources,
+			ghsaURL:     scanGHSAGraphQLURL,
+			githubToken: os.Getenv("GITHUB_TOKEN"),
+			depsDevURL:  scanDepsDevURL,
+		}.open(queryable)
+		if err != nil {
+			fatal("Error loading offline database", err)
+		}
+
+		var vex []scanner.VEXStatement
+		for _, path := range scanVEX {
+			stmts, err := scanner.LoadVEX(path)
 			if err != nil {
-				fmt.Printf("  ❌ %s@%s → OSV query failed: %v\n", d.name, d.version, err)
-				continue
+				fatal("Error reading VEX document", err)
 			}
-			body, _ := io.ReadAll(resp.Body)
-			_ = resp.Body.Close()
+			vex = append(vex, stmts...)
+		}
 
-			var or osvResp
-			if err := json.Unmarshal(body, &or); err != nil {
-				fmt.Printf("  ❌ %s@%s → bad OSV response: %v\n", d.name, d.version, err)
-				continue
+		var baseline *scanner.Baseline
+		if scanBaseline != "" {
+			if baseline, err = scanner.LoadBaseline(scanBaseline); err != nil {
+				fatal("Error reading baseline", err)
 			}
+		}
 
-			if len(or.Vulns) > 0 {
-				vulnCount += len(or.Vulns)
-				fmt.Printf("  🚨 %s@%s — %d vuln(s)\n", d.name, d.version, len(or.Vulns))
-				for _, v := range or.Vulns {
-					// Print ID + short summary (trim to one line)
-					s := strings.Split(strings.TrimSpace(v.Summary), "\n")[0]
-					if len(s) > 110 {
-						s = s[:110] + "…"
+		// An explicit --ignore-file covers every project; otherwise each
+		// project picks up the .keystoneignore next to its own lockfile.
+		// Rules from the config file apply everywhere. A scan cut short
+		// by Ctrl-C or --timeout reports the lockfiles it got through.
+		var reports []*scanner.Report
+		loadedRules := map[string][]scanner.IgnoreRule{}
+		scanned := make([]*scanner.Report, len(projects))
+		errs := make([]error, len(projects))
+		var finished atomic.Int32
+		scanner.RunPool(scanParallel, len(projects), func(i int) {
+			p, start := projects[i], time.Now()
+			scanned[i], errs[i] = sc.Scan(scanner.WithProgress(ctx, bar.project()), p.path, p.kind, p.deps)
+			if errs[i] != nil || len(projects) == 1 {
+				return
+			}
+			bar.finishProject(func() {
+				logger.Info(fmt.Sprintf("✅ %s: %d package(s) in %s (%d/%d)", repoRelative(root, p.path), scanned[i].Scanned,
+					time.Since(start).Round(time.Millisecond), finished.Add(1), len(projects)))
+			})
+		})
+		bar.clear()
+		for i, p := range projects {
+			r, err := scanned[i], errs[i]
+			if err != nil && ctx.Err() != nil {
+				continue
+			}
+			if err != nil {
+				fatal("Advisory lookup failed", err)
+			}
+			scanner.AttributeWorkspaces(r, p.workspaces)
+			if (scanReachability || scanHideUnreachable) && (isNpmLockfile(p.kind) || p.kind == scanner.LockfileNodeModules || p.kind == scanner.LockfilePackageJSON) {
+				if g, err := scanner.BuildImportGraph(filepath.Dir(p.path)); err != nil {
+					logger.Warn("Can't read the imports of "+repoRelative(root, p.path), "err", err)
+				} else {
+					logger.Debug("Read imports", "path", p.path, "imported", len(g.Imports), "reached", len(g.Reached))
+					scanner.ApplyReachability(r, g)
+					if scanHideUnreachable {
+						scanner.HideUnreachable(r)
 					}
-					fmt.Printf("     • %s — %s\n", v.ID, s)
 				}
 			}
+			if scanWorkspace != "" {
+				var selected []scanner.Workspace
+				for _, ws := range r.Workspaces {
+					if ws.Name == scanWorkspace {
+						selected = append(selected, ws)
+					}
+				}
+				r.Workspaces = selected
+			}
+			reports = append(reports, r)
+
+			expired := scanner.ApplyIgnores(r, configRules, time.Now())
+			if len(reports) == 1 {
+				for _, r := range expired {
+					logger.Warn(fmt.Sprintf("%s: ignore rule %q expired on %s and no longer applies", cfg.files[configIgnoreKey], r.Pattern, r.Expires))
+				}
+			}
+
+			ignorePath, optional := scanIgnoreFile, false
+			if ignorePath == "" {
+				ignorePath, optional = filepath.Join(filepath.Dir(p.path), scanner.IgnoreFileName), true
+			}
+			rules, seen := loadedRules[ignorePath]
+			if !seen {
+				if rules, err = scanner.LoadIgnoreFile(ignorePath, optional); err != nil {
+					fatal("Error reading ignore file", err)
+				}
+				loadedRules[ignorePath] = rules
+				if len(rules) > 0 {
+					logger.Debug("Read ignore file", "path", ignorePath, "rules", len(rules))
+				}
+			}
+			expired = scanner.ApplyIgnores(r, rules, time.Now())
+			scanner.ApplyVEX(r, vex)
+			if baseline != nil {
+				scanner.ApplyBaseline(r, baseline)
+			}
+			if seen {
+				continue // already warned about this file's expired rules
+			}
+			for _, r := range expired {
+				logger.Warn(fmt.Sprintf("%s:%d: ignore rule %q expired on %s and no longer applies", ignorePath, r.Line, r.Pattern, r.Expires))
+			}
+		}
+
+		// Lockfiles in a clone are named by their path in the repository,
+		// not the temporary directory they were scanned in.
+		if scanRepo != "" {
+			for _, r := range reports {
+				r.Source = repoRelative(root, r.Source)
+			}
+		}
+
+		interrupted := ctx.Err() != nil
+		if interrupted && len(reports) == 0 {
+			fatalf("Scan %s before any lockfile was scanned", interruption(ctx))
+		}
+		if interrupted {
+			logger.Warn(fmt.Sprintf("Scan %s; reporting what %d of %d lockfile(s) had found by then", interruption(ctx), len(reports), len(projects)))
 		}
 
-		if vulnCount == 0 {
-			fmt.Println("✅ No known vulnerabilities found for the packages in this lockfile (per OSV).")
+		report := reports[0]
+		report.Drift = drift
+		report.Resolution = resolution
+		if root != "" {
+			scanned := 0
+			for _, r := range reports {
+				scanned += r.Scanned
+			}
+			report = &scanner.Report{Source: rootLabel, Lockfile: scanner.LockfileDirectory, Scanned: scanned, Projects: reports}
+			report.Partial = report.Unfetched() > 0
+		}
+		report.Interrupted = interrupted
+
+		ectx, enrich := scanner.StartSpan(ctx, "enrich")
+		epssMissing := false
+		_, span := scanner.StartSpan(ectx, "epss")
+		if scanEPSSFile != "" {
+			scores, err := scanner.LoadEPSSFile(scanEPSSFile)
+			if err != nil {
+				fatal("Error reading EPSS scores", err)
+			}
+			scanner.ApplyEPSS(report, scores)
+		} else if scanEPSS && !interrupted {
+			if scores, err := scanner.FetchEPSS(client, scanEPSSURL, report.CVEs()); err != nil {
+				logger.Warn("EPSS scores unavailable", "err", err)
+				epssMissing = true
+			} else {
+				scanner.ApplyEPSS(report, scores)
+			}
+		}
+		span.Finish()
+		kevMissing := false
+		_, span = scanner.StartSpan(ectx, "kev")
+		if scanKEVFile != "" {
+			kev, err := scanner.LoadKEVFile(scanKEVFile)
+			if err != nil {
+				fatal("Error reading KEV catalog", err)
+			}
+			scanner.ApplyKEV(report, kev)
+		} else if (scanKEV || scanFailOnKEV) && !interrupted {
+			if kev, err := scanner.FetchKEV(client, scanKEVURL); err != nil {
+				logger.Warn("KEV catalog unavailable", "err", err)
+				kevMissing = true
+			} else {
+				scanner.ApplyKEV(report, kev)
+			}
+		}
+		span.Finish()
+		if scanSeverityFallback && !interrupted {
+			if scanOffline {
+				logger.Warn("The severity fallback needs the GitHub and NVD APIs, so it's skipped with --offline")
+			} else {
+				sctx, span := scanner.StartSpan(ectx, "severity-fallback")
+				fillSeverity(sctx, client, report)
+				span.Finish()
+			}
+		}
+		if len(scanVulns) > 0 {
+			scanner.OnlyVulns(report, scanVulns)
+		}
+		if cvssEnv != nil {
+			scanner.ApplyCVSSEnvironment(report, cvssEnv)
+		}
+		if scanSort != "" {
+			scanner.SortFindings(report, scanSort)
+		}
+
+		if (scanDepsDev || (policy != nil && policy.Uses("dependents"))) && !interrupted {
+			if scanOffline {
+				logger.Warn("Insights need the deps.dev API, so they're skipped with --offline")
+			} else {
+				_, span := scanner.StartSpan(ectx, "deps.dev", "keystone.packages", len(queryable))
+				dd := &scanner.DepsDev{Client: client, URL: scanDepsDevURL}
+				insights, err := dd.Insights(ectx, queryable)
+				if err != nil {
+					logger.Warn("Some deps.dev insights are unavailable", "err", err)
+				}
+				scanner.ApplyInsights(report, insights)
+				warnWithoutDirect(report, "dependent counts")
+				span.Finish()
+			}
+		}
+		if policy := licensePolicy(); !policy.Empty() {
+			scanner.CheckLicenses(report, policy)
+		}
+		var releases map[string]scanner.NpmRelease
+		if (scanSupplyChain || (policy != nil && (policy.Uses("release_age") || policy.Uses("deprecated")))) && !interrupted {
+			if scanOffline {
+				logger.Warn("Release dates need the npm registry, so checks of them are skipped with --offline")
+			} else {
+				_, span := scanner.StartSpan(ectx, "npm-releases", "keystone.packages", len(queryable))
+				if releases, err = scanner.FetchNpmReleases(client, scanNpmRegistry, queryable); err != nil {
+					logger.Warn("Some release dates are unavailable", "err", err)
+				}
+				span.Finish()
+			}
+		}
+		if scanVerifySignatures && releases != nil {
+			if err := scanner.VerifyNpmReleases(client, scanNpmRegistry, queryable, releases); err != nil {
+				logger.Warn("Some signatures or attestations couldn't be checked", "err", err)
+			}
+		}
+		if scanSupplyChain {
+			scanner.CheckSupplyChain(report, releases, scanNewReleaseAge, time.Now())
+		}
+		if (scanMaintenance || (policy != nil && policy.Covers(scanner.PolicyMaintenance))) && !interrupted {
+			if scanOffline {
+				logger.Warn("Maintenance checks need the package registries and GitHub, so they're skipped with --offline")
+			} else {
+				_, span := scanner.StartSpan(ectx, "maintenance", "keystone.packages", len(queryable))
+				if policy != nil && policy.Covers(scanner.PolicyMaintenance) && policy.Uses("direct") {
+					warnWithoutDirect(report, "matches of maintenance rules on direct")
+				}
+				gh := &scanner.GitHub{Client: client, URL: scanGitHubURL, Token: os.Getenv("GITHUB_TOKEN")}
+				info, err := scanner.FetchMaintenance(ectx, client, scanner.Registries{Npm: scanNpmRegistry}, gh, queryable)
+				if err != nil {
+					logger.Warn("Some maintenance data is unavailable", "err", err)
+				}
+				scanner.CheckMaintenance(report, info, time.Duration(scanStaleYears)*365*24*time.Hour, time.Now())
+				span.Finish()
+			}
+		}
+		if (scanScorecard || (policy != nil && policy.Uses("scorecard"))) && !interrupted {
+			if scanOffline {
+				logger.Warn("Scorecards need the package registries and the Scorecard API, so they're skipped with --offline")
+			} else {
+				_, span := scanner.StartSpan(ectx, "scorecard", "keystone.packages", len(queryable))
+				warnWithoutDirect(report, "Scorecards")
+				cards, err := scanner.FetchScorecards(ectx, client, scanScorecardURL, scanner.Registries{Npm: scanNpmRegistry}, queryable)
+				if err != nil {
+					logger.Warn("Some Scorecards are unavailable", "err", err)
+				}
+				scanner.ApplyScorecards(report, cards)
+				span.Finish()
+			}
+		}
+		if policy != nil {
+			scanner.EvaluatePolicy(report, policy, releases, time.Now())
+		}
+		enrich.Finish()
+		scanEnded := time.Now().UTC()
+		report.Started, report.Finished = &scanStarted, &scanEnded
+
+		if scanWriteBaseline != "" && !interrupted {
+			b := scanner.NewBaseline(report)
+			if err := b.WriteFile(scanWriteBaseline); err != nil {
+				fatal("Error writing baseline", err)
+			}
+			logger.Info(fmt.Sprintf("📌 Recorded %d finding(s) in %s", len(b.Findings), scanWriteBaseline))
+		}
+
+		if scanTUI {
+			err := runTUI(report, func(p *scanner.Report) string {
+				if scanIgnoreFile != "" {
+					return scanIgnoreFile
+				}
+				return filepath.Join(filepath.Dir(p.Source), scanner.IgnoreFileName)
+			})
+			if err != nil {
+				fatal("Error running the results browser", err)
+			}
+			return
+		}
+
+		_, span = scanner.StartSpan(ctx, "render", "keystone.format", scanOutput)
+		err = writeReport(os.Stdout, report, scanOutput)
+		span.Fail(err)
+		span.Finish()
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+		// Nothing incomplete is sent on or recorded.
+		if interrupted {
+			fatalf("Scan %s; the report above is incomplete", interruption(ctx))
+		}
+
+		if len(notifyTargets) > 0 {
+			notify := report.VulnCount() > 0
+			if scanNotifyOn != "" {
+				notify = report.Failing(scanNotifyOn) > 0
+			}
+			if notify {
+				sendNotifications(client, notifyTargets, buildNotifyMessage(report, baseline != nil))
+			}
+		}
+
+		if scanHistory || scanHistoryFile != "" {
+			recordHistory(report)
+		}
+		if scanWebhook != "" {
+			postWebhook(client, scanWebhook, webhookHeaders, webhookSecret(), report)
+		}
+		if jira != nil {
+			jira.http = client
+			opened, tracked, err := jira.openJiraIssues(report, scanJiraSeverity)
+			if err != nil {
+				logger.Warn("Error opening Jira issues", "err", err)
+			}
+			if opened > 0 || tracked > 0 {
+				logger.Info(fmt.Sprintf("🎫 Opened %d Jira issue(s) in %s; %d finding(s) already had one", opened, jira.project, tracked))
+			}
+		}
+		for _, e := range exports {
+			where, err := e.export(client, report)
+			if err != nil {
+				logger.Warn("Error exporting the report", "err", err)
+				continue
+			}
+			logger.Info("📤 Exported the report to " + where)
+		}
+
+		// stderr, so machine-readable output on stdout stays valid.
+		if drift != nil && len(drift.Packages) > 0 && scanOutput != outputText {
+			logger.Warn(fmt.Sprintf("%d package(s) in node_modules differ from %s", len(drift.Packages), drift.Lockfile))
+		}
+		if resolution != nil && scanOutput != outputText {
+			logger.Warn("Versions were resolved from package.json ranges against " + resolution.Registry + ", not locked")
+		}
+		if n := report.SupplyChainCount("any"); n > 0 && scanOutput != outputText {
+			logger.Warn(fmt.Sprintf("%d supply-chain warning(s) about packages that may be malicious or unmaintained", n))
+		}
+		if n := report.MaintenanceCount(); n > 0 && scanOutput != outputText {
+			logger.Warn(fmt.Sprintf("%d package(s) that look unmaintained", n))
+		}
+		if report.Partial {
+			logger.Warn(fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV; results are incomplete.", report.Unfetched()))
+		}
+		if scanOutput != outputText && scanOutput != outputMarkdown {
+			unchecked := report.UncheckedByEcosystem()
+			for _, eco := range sortedKeys(unchecked) {
+				logger.Warn(uncheckedMessage(eco, unchecked[eco]))
+			}
+		}
+		// The findings just written to a baseline are accepted, not failures.
+		if scanFailOn != "" && scanWriteBaseline == "" {
+			if n := report.Failing(scanFailOn); n > 0 {
+				failf("%d vulnerability(ies) at or above --fail-on=%s", n, scanFailOn)
+			}
+			// An advisory of unknown severity could be above the threshold.
+			if report.Partial {
+				fatalf("Can't confirm nothing is at or above --fail-on=%s with incomplete results", scanFailOn)
+			}
+		}
+		if scanFailOnEPSS > 0 && scanWriteBaseline == "" {
+			if n := report.FailingEPSS(scanFailOnEPSS); n > 0 {
+				failf("%d vulnerability(ies) with an EPSS score at or above --fail-on-epss=%g", n, scanFailOnEPSS)
+			}
+			if epssMissing {
+				fatalf("Can't confirm nothing is at or above --fail-on-epss=%g without EPSS scores", scanFailOnEPSS)
+			}
+		}
+		if scanFailOnKEV && scanWriteBaseline == "" {
+			if n := report.KnownExploited(); n > 0 {
+				failf("%d known exploited vulnerability(ies) from CISA's KEV catalog", n)
+			}
+			if kevMissing {
+				fatalf("Can't confirm nothing is known exploited without the KEV catalog")
+			}
+		}
+		if n := report.LicenseViolationCount(); n > 0 {
+			failf("%d package(s) with licenses the policy doesn't permit", n)
+		}
+		if scanFailOnSupplyChain != "" {
+			if n := report.SupplyChainCount(scanFailOnSupplyChain); n > 0 {
+				failf("%d supply-chain warning(s) at or above --fail-on-supply-chain=%s", n, scanFailOnSupplyChain)
+			}
+		}
+		if n := report.PolicyDenials(); n > 0 {
+			failf("%d violation(s) of deny rules in %s", n, scanPolicy)
+		}
+		// Checked last: whatever was found stands, however much wasn't.
+		if scanErrorOnIncomplete {
+			unresolved := resolution != nil && len(resolution.Unresolved) > 0
+			if report.Partial || skipped > 0 || unresolved {
+				fatalf("Results are incomplete (see the warnings above), which --error-on-incomplete fails")
+			}
 		}
 	},
 }
 
+var (
+	scanConcurrency int
+	scanParallel    int
+	scanRateLimit   float64
+	scanOutput      string
+	scanSBOM        string
+	scanFailOn      string
+	scanIgnoreFile  string
+	scanNoCache     bool
+	scanCacheTTL    time.Duration
+	scanOffline     bool
+	scanOSVURL      string
+	scanIncludeDev  bool
+	scanProdOnly    bool
+	scanEcosystems  []string
+	scanInstalled   bool
+	scanSort        string
+	scanEPSS        bool
+	scanEPSSFile    string
+	scanEPSSURL     string
+	scanFailOnEPSS  float64
+	scanKEV         bool
+	scanKEVFile     string
+	scanKEVURL      string
+	scanFailOnKEV   bool
+
+	scanErrorOnIncomplete bool
+	scanOTLPEndpoint      string
+
+	scanNpmRegistry string
+	scanWorkspace   string
+	scanPolicy      string
+
+	scanRepo          string
+	scanRef           string
+	scanTUI           bool
+	scanVEX           []string
+	scanBaseline      string
+	scanWriteBaseline string
+
+	scanSupplyChain       bool
+	scanNewReleaseAge     time.Duration
+	scanFailOnSupplyChain string
+	scanMaintenance       bool
+	scanStaleYears        int
+	scanGitHubURL         string
+	scanScorecard         bool
+	scanScorecardURL      string
+	scanDepsDev           bool
+	scanDepsDevURL        string
+	scanSources           []string
+	scanGHSAGraphQLURL    string
+	scanVerifySignatures  bool
+
+	scanNotify   []string
+	scanNotifyOn string
+
+	scanJiraURL       string
+	scanJiraProject   string
+	scanJiraIssueType string
+	scanJiraSeverity  string
+	scanJiraUser      string
+	scanJiraToken     string
+
+	scanWebhook        string
+	scanWebhookHeaders []string
+	scanWebhookSecret  string
+
+	scanHistory     bool
+	scanHistoryFile string
+
+	scanExport []string
+
+	scanReachability    bool
+	scanHideUnreachable bool
+
+	scanCVSSEnv string
+
+	scanVulns []string
+
+	scanSeverityFallback bool
+	scanGHSAURL          string
+	scanNVDURL           string
+)
+
 func init() {
 	rootCmd.AddCommand(scanCmd)
+
+	scanCmd.Flags().IntVarP(&scanConcurrency, "concurrency", "c", scanner.DefaultConcurrency, "number of parallel OSV requests")
+	scanCmd.Flags().IntVar(&scanParallel, "parallel", defaultParallel, "number of a directory's lockfiles to scan at once, sharing --concurrency")
+	scanCmd.Flags().Float64Var(&scanRateLimit, "rate-limit", scanner.DefaultRateLimit, "maximum OSV requests per second (0 = unlimited)")
+	scanCmd.Flags().BoolVar(&scanNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	scanCmd.Flags().DurationVar(&scanCacheTTL, "cache-ttl", scanner.DefaultCacheTTL, "how long cached OSV responses stay valid")
+	scanCmd.Flags().BoolVar(&scanOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	scanCmd.Flags().StringVar(&scanOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	scanCmd.MarkFlagsMutuallyExclusive("offline", "osv-url")
+	scanCmd.Flags().StringSliceVar(&scanSources, "sources", []string{sourceOSV}, "advisory sources to look up, in order of preference: osv, ghsa (needs $GITHUB_TOKEN), deps.dev")
+	scanCmd.Flags().StringVar(&scanGHSAGraphQLURL, "ghsa-graphql-url", scanner.DefaultGitHubGraphQLURL, "GitHub GraphQL API to read the GitHub Advisory Database from with --sources ghsa")
+	scanCmd.Flags().BoolVar(&scanIncludeDev, "include-dev", true, "scan development dependencies as well as runtime ones")
+	scanCmd.Flags().BoolVar(&scanProdOnly, "prod-only", false, "scan only runtime dependencies (same as --include-dev=false)")
+	scanCmd.MarkFlagsMutuallyExclusive("include-dev", "prod-only")
+	scanCmd.Flags().StringSliceVarP(&scanEcosystems, "ecosystem", "e", nil, "only scan packages from this OSV ecosystem (repeatable; default: all)")
+	scanCmd.Flags().StringVar(&scanSBOM, "sbom", "", "scan the components of a CycloneDX or SPDX JSON SBOM instead of a lockfile")
+	scanCmd.Flags().BoolVar(&scanInstalled, "installed", false, "scan the packages installed under the project's node_modules instead of its lockfile")
+	scanCmd.MarkFlagsMutuallyExclusive("sbom", "installed")
+	scanCmd.Flags().StringVar(&scanNpmRegistry, "npm-registry", scanner.DefaultNpmRegistry, "npm registry to resolve a package.json's dependency ranges against")
+	scanCmd.Flags().StringVar(&scanRepo, "repo", "", "shallow-clone this Git repository URL and scan every lockfile in it")
+	scanCmd.Flags().StringVar(&scanRef, "ref", "", "branch or tag of the --repo repository to scan (default: its default branch)")
+	scanCmd.MarkFlagsMutuallyExclusive("repo", "installed")
+	scanCmd.Flags().StringVar(&scanWorkspace, "workspace", "", "only scan the dependencies of this npm, yarn, pnpm or Bun workspace")
+	scanCmd.MarkFlagsMutuallyExclusive("workspace", "sbom")
+	scanCmd.MarkFlagsMutuallyExclusive("workspace", "installed")
+	scanCmd.Flags().StringVar(&scanFailOn, "fail-on", "", "exit non-zero if any finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	scanCmd.Flags().StringVar(&scanIgnoreFile, "ignore-file", "", "suppression rules to apply (default: "+scanner.IgnoreFileName+" next to the scanned file, if present)")
+	scanCmd.Flags().BoolVar(&scanEPSS, "epss", false, "add EPSS exploit-probability scores from the EPSS API")
+	scanCmd.Flags().StringVar(&scanEPSSFile, "epss-file", "", "add EPSS scores from this EPSS dataset (CSV, optionally gzipped) instead of the API")
+	scanCmd.Flags().StringVar(&scanEPSSURL, "epss-url", scanner.DefaultEPSSURL, "base URL of the EPSS API or a compatible mirror")
+	scanCmd.MarkFlagsMutuallyExclusive("epss", "epss-file")
+	scanCmd.Flags().Float64Var(&scanFailOnEPSS, "fail-on-epss", 0, "exit non-zero if any advisory's EPSS score is at or above this probability (0-1)")
+	scanCmd.Flags().BoolVar(&scanKEV, "kev", false, "flag advisories in CISA's Known Exploited Vulnerabilities catalog")
+	scanCmd.Flags().StringVar(&scanKEVFile, "kev-file", "", "read the KEV catalog from this JSON file instead of downloading it")
+	scanCmd.Flags().StringVar(&scanKEVURL, "kev-url", scanner.DefaultKEVURL, "URL of the KEV catalog feed or a mirror")
+	scanCmd.Flags().BoolVar(&scanFailOnKEV, "fail-on-kev", false, "exit non-zero if any advisory is in the KEV catalog (implies --kev)")
+	scanCmd.MarkFlagsMutuallyExclusive("kev", "kev-file")
+	scanCmd.Flags().BoolVar(&scanErrorOnIncomplete, "error-on-incomplete", false, "exit 3 if any advisory, lockfile or dependency couldn't be checked, even with nothing failing")
+	scanCmd.Flags().StringVar(&scanSort, "sort", "", "order findings by: "+strings.Join(scanner.SortOrders, ", ")+" (default: as listed in the lockfile)")
+	scanCmd.Flags().StringSliceVar(&scanNotify, "notify", nil, "post a summary of the findings to this slack:// or teams:// webhook URL (repeatable)")
+	scanCmd.Flags().StringVar(&scanNotifyOn, "notify-on", "", "only notify when a finding is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	scanCmd.Flags().StringVar(&scanJiraURL, "jira-url", "", "open Jira issues for findings on this Jira site, e.g. https://example.atlassian.net")
+	scanCmd.Flags().StringVar(&scanJiraProject, "jira-project", "", "key of the Jira project to open issues in")
+	scanCmd.Flags().StringVar(&scanJiraIssueType, "jira-issue-type", "Bug", "type of the Jira issues to open")
+	scanCmd.Flags().StringVar(&scanJiraSeverity, "jira-severity", "high", "open Jira issues for findings at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	scanCmd.Flags().StringVar(&scanJiraUser, "jira-user", "", "Jira account email, for basic auth with an API token (default: $JIRA_USER)")
+	scanCmd.Flags().StringVar(&scanJiraToken, "jira-token", "", "Jira API token, or a personal access token when no user is given (default: $JIRA_API_TOKEN)")
+	scanCmd.Flags().BoolVar(&scanHistory, "history", false, "record the findings in the scan history that 'keystone history' reads")
+	scanCmd.Flags().StringVar(&scanHistoryFile, "history-file", "", "record the findings in this history file (implies --history)")
+	scanCmd.Flags().StringVar(&scanWebhook, "webhook", "", "POST the JSON report to this URL")
+	scanCmd.Flags().StringArrayVar(&scanWebhookHeaders, "webhook-header", nil, "extra \"Name: value\" header to send with --webhook (repeatable)")
+	scanCmd.Flags().StringVar(&scanWebhookSecret, "webhook-secret", "", "sign --webhook bodies with HMAC-SHA256 using this key (default: $KEYSTONE_WEBHOOK_SECRET)")
+	scanCmd.Flags().BoolVar(&scanReachability, "reachability", false, "mark npm findings reachable or not from the project's JavaScript and TypeScript imports")
+	scanCmd.Flags().BoolVar(&scanHideUnreachable, "hide-unreachable", false, "suppress npm findings that aren't reachable from the project's imports (implies --reachability)")
+	scanCmd.Flags().StringVar(&scanCVSSEnv, "cvss-env", "", "CVSS v3.1 environmental metrics to rescore advisories with, e.g. \"MAV:L/CR:H\"")
+	scanCmd.Flags().BoolVar(&scanSeverityFallback, "severity-fallback", false, "score advisories OSV has no CVSS vector for from the GitHub Advisory Database, then the NVD")
+	scanCmd.Flags().StringVar(&scanGHSAURL, "ghsa-url", scanner.DefaultGHSAURL, "base URL of the GitHub advisories API for --severity-fallback")
+	scanCmd.Flags().StringVar(&scanNVDURL, "nvd-url", scanner.DefaultNVDURL, "URL of the NVD CVE API for --severity-fallback")
+	scanCmd.Flags().StringSliceVar(&scanVulns, "vuln", nil, "only report this advisory, by its ID or an alias such as a CVE (repeatable)")
+	scanCmd.Flags().BoolVar(&showDetails, "details", false, "show each advisory's affected versions, aliases, publication date and references in the text report")
+	scanCmd.Flags().StringSliceVar(&scanExport, "export", nil, "upload the findings to this platform (repeatable): "+strings.Join(exporterNames(), ", "))
+	for _, name := range exporterNames() {
+		exporters[name].addFlags(scanCmd)
+	}
+	scanCmd.Flags().StringSliceVar(&scanVEX, "vex", nil, "suppress findings an OpenVEX or CSAF VEX document declares not_affected or fixed (repeatable)")
+	scanCmd.Flags().StringVar(&scanBaseline, "baseline", "", "only report findings that aren't recorded in this baseline file")
+	scanCmd.Flags().StringVar(&scanWriteBaseline, "write-baseline", "", "record the current findings in this baseline file")
+	scanCmd.MarkFlagsMutuallyExclusive("baseline", "write-baseline")
+	scanCmd.Flags().StringVarP(&scanOutput, "output", "o", outputText, "output format: "+strings.Join(outputFormats, ", "))
+	scanCmd.Flags().BoolVar(&scanTUI, "tui", false, "browse the findings interactively instead of printing a report")
+	scanCmd.MarkFlagsMutuallyExclusive("tui", "output")
+	scanCmd.MarkFlagsMutuallyExclusive("tui", "repo") // nowhere lasting to write ignores
+	addLicenseFlags(scanCmd)
+	scanCmd.Flags().BoolVar(&scanSupplyChain, "supply-chain", false, "also warn about packages that look malicious or unmaintained: typosquats, new releases and publishers, install scripts, deprecated npm versions, actions and Terraform modules that aren't pinned")
+	scanCmd.Flags().DurationVar(&scanNewReleaseAge, "new-release-age", scanner.DefaultNewReleaseAge, "with --supply-chain, warn about npm versions published less than this long ago")
+	scanCmd.Flags().BoolVar(&scanVerifySignatures, "verify-signatures", false, "also verify the registry signatures and provenance attestations of npm packages (implies --supply-chain)")
+	scanCmd.Flags().StringVar(&scanFailOnSupplyChain, "fail-on-supply-chain", "", "exit non-zero if any supply-chain warning is at or above this severity (implies --supply-chain)")
+	scanCmd.Flags().BoolVar(&scanMaintenance, "maintenance", false, "also report packages that look unmaintained: archived on GitHub, or not released for --stale-years")
+	scanCmd.Flags().IntVar(&scanStaleYears, "stale-years", 2, "with --maintenance, report packages with nothing released for more than this many years")
+	scanCmd.Flags().StringVar(&scanGitHubURL, "github-url", scanner.DefaultGitHubAPIURL, "base URL of the GitHub API for --maintenance, e.g. https://github.example.com/api/v3")
+	scanCmd.Flags().BoolVar(&scanScorecard, "scorecard", false, "also look up the OpenSSF Scorecard of each direct dependency's repository")
+	scanCmd.Flags().StringVar(&scanScorecardURL, "scorecard-url", scanner.DefaultScorecardURL, "base URL of the OpenSSF Scorecard API")
+	scanCmd.Flags().BoolVar(&scanDepsDev, "deps-dev", false, "also look up advisories, licenses and dependent counts on deps.dev")
+	scanCmd.Flags().StringVar(&scanDepsDevURL, "deps-dev-url", scanner.DefaultDepsDevURL, "base URL of the deps.dev API")
+	scanCmd.Flags().StringVar(&scanPolicy, "policy", "", "evaluate the rules of this policy file against the results")
+	scanCmd.Flags().StringVar(&scanOTLPEndpoint, "otlp-endpoint", "", "send a trace of the scan to this OTLP/HTTP endpoint, e.g. http://collector:4318/v1/traces (default: $OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)")
+	addNetworkFlags(scanCmd)
 }
 
 /********** helpers **********/
 
-type dep struct {
-	name    string
-	version string
+// defaultParallel is how many lockfiles a directory scan looks up at once.
+// Their requests share --concurrency, so more only helps to keep it busy
+// while small lockfiles finish.
+const defaultParallel = 4
+
+// sourceOptions chooses where vulnerability data comes from.
+type sourceOptions struct {
+	offline     bool
+	noCache     bool
+	concurrency int
+	rateLimit   float64
+	cacheTTL    time.Duration
+	osvURL      string
+	client      *http.Client
+
+	// sources names the advisory sources to look up, in order of
+	// preference, combined when there are several; nil means OSV alone.
+	sources     []string
+	ghsaURL     string
+	githubToken string
+	depsDevURL  string
 }
 
-// extractNpmPackages finds packages in lockfile v2/v3: lock["packages"] is a map
-// where keys are "", "node_modules/lodash", etc. We take the name from the key
-// (strip "node_modules/") and version from the value's "version".
-func extractNpmPackages(lock map[string]any) []dep {
-	packagesAny, ok := lock["packages"]
-	if !ok {
-		return nil
+// Advisory sources --sources can name.
+const (
+	sourceOSV     = "osv"
+	sourceGHSA    = "ghsa"
+	sourceDepsDev = "deps.dev"
+)
+
+var advisorySources = []string{sourceOSV, sourceGHSA, sourceDepsDev}
+
+// open returns the offline database for deps' ecosystems when offline is
+// set, and otherwise a client of each of the sources, combined in a
+// MultiSource when there are several. Only the offline database can fail
+// to open; a broken cache just means querying without it.
+func (o sourceOptions) open(deps []scanner.Package) (scanner.Source, error) {
+	if o.offline {
+		dir, err := scanner.DBDir()
+		if err != nil {
+			return nil, err
+		}
+		return scanner.OpenLocalDB(dir, deps)
 	}
-	packages, ok := packagesAny.(map[string]any)
-	if !ok {
-		return nil
+	var cache *scanner.Cache
+	if !o.noCache {
+		var err error
+		if cache, err = scanner.OpenCache(o.cacheTTL); err != nil {
+			logger.Warn("OSV cache unavailable, querying without it", "err", err)
+		}
 	}
+	names := o.sources
+	if len(names) == 0 {
+		names = []string{sourceOSV}
+	}
+	var sources scanner.MultiSource
+	for _, name := range names {
+		switch name {
+		case sourceOSV:
+			sources = append(sources, scanner.NewOSVClient(scanner.OSVClientOptions{
+				URL:         o.osvURL,
+				HTTPClient:  o.client,
+				Concurrency: o.concurrency,
+				RateLimit:   o.rateLimit,
+				Cache:       cache,
+			}))
+		case sourceGHSA:
+			sources = append(sources, &scanner.GitHubAdvisories{Client: o.client, URL: o.ghsaURL, Token: o.githubToken, Cache: cache})
+		case sourceDepsDev:
+			sources = append(sources, &scanner.DepsDev{Client: o.client, URL: o.depsDevURL, Cache: cache})
+		}
+	}
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	return sources, nil
+}
 
-	out := make([]dep, 0, len(packages))
-	for k, v := range packages {
-		entry, ok := v.(map[string]any)
-		if !ok {
-			continue
+// scanAdvisorySources returns the sources --sources names, with deps.dev
+// added for --deps-dev, exiting on any it doesn't know or can't use.
+func scanAdvisorySources() []string {
+	var names []string
+	for _, name := range scanSources {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !contains(advisorySources, name) {
+			usagef("Unknown advisory source %q (want one of: %s)", name, strings.Join(advisorySources, ", "))
+		}
+		if !contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		usagef("--sources needs at least one of: %s", strings.Join(advisorySources, ", "))
+	}
+	if scanDepsDev && !contains(names, sourceDepsDev) {
+		names = append(names, sourceDepsDev)
+	}
+	if scanOffline && (len(names) > 1 || names[0] != sourceOSV) {
+		usagef("--offline reads the downloaded OSV database only; it can't be combined with --sources %s or --deps-dev", strings.Join(names, ","))
+	}
+	if contains(names, sourceGHSA) && os.Getenv("GITHUB_TOKEN") == "" {
+		usagef("The GitHub Advisory Database needs a token: set $GITHUB_TOKEN to use --sources %s", sourceGHSA)
+	}
+	return names
+}
+
+// warnWithoutDirect warns about each lockfile of r that doesn't record
+// which of its dependencies are direct, and so gets none of what, which is
+// only looked up for direct dependencies.
+func warnWithoutDirect(r *scanner.Report, what string) {
+	for _, source := range r.WithoutDirect() {
+		logger.Warn(fmt.Sprintf("%s doesn't record which dependencies are direct, so it gets no %s", source, what))
+	}
+}
+
+// offlineDataFile returns the path of the named file of an imported bundle,
+// exiting if there is none.
+func offlineDataFile(name, what, flag string) string {
+	dir, err := scanner.DBDir()
+	if err != nil {
+		fatal("Error locating database directory", err)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		fatalf("No %s for --offline; import a bundle with 'keystone bundle import' or give --%s", what, flag)
+	}
+	return path
+}
+
+// fillSeverity scores the advisories of r that OSV has no CVSS vector for
+// from the fallback sources, logging rather than failing when they can't
+// be reached.
+func fillSeverity(ctx context.Context, client *http.Client, r *scanner.Report) {
+	var cache *scanner.Cache
+	if !scanNoCache {
+		var err error
+		if cache, err = scanner.OpenCache(scanCacheTTL); err != nil {
+			logger.Warn("Cache unavailable, looking up severities without it", "err", err)
 		}
-		ver, _ := entry["version"].(string)
+	}
+	n, err := scanner.FillSeverity(ctx, r, scanner.SeverityOptions{
+		Client:      client,
+		Cache:       cache,
+		GHSAURL:     scanGHSAURL,
+		GitHubToken: os.Getenv("GITHUB_TOKEN"),
+		NVDURL:      scanNVDURL,
+		NVDAPIKey:   os.Getenv("NVD_API_KEY"),
+	})
+	if err != nil {
+		logger.Warn("Some severities couldn't be looked up", "err", err)
+	}
+	if n > 0 {
+		logger.Debug("Filled in severities", "advisories", n)
+	}
+}
+
+// cloneRepo shallow-clones the Git repository at url, at ref if given, into a
+// new temporary directory, which the caller removes.
+func cloneRepo(url, ref string) (string, error) {
+	dir, err := os.MkdirTemp("", "keystone-repo-")
+	if err != nil {
+		return "", err
+	}
+	args := []string{"clone", "--quiet", "--depth", "1", "--single-branch", "--no-tags"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	logger.Info("📥 Cloning " + url)
+	if _, err := git(append(args, "--", url, dir)...); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
 
-		// Root package entry has key "" — skip it (no module name)
-		if k == "" {
+// repoRelative names path by its place under root, a clone of --repo, and
+// leaves it as it is otherwise.
+func repoRelative(root, path string) string {
+	if scanRepo == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// resolvePackageJSON resolves the dependencies of the package.json at path
+// against the npm registry.
+func resolvePackageJSON(client *http.Client, path string) ([]scanner.Package, *scanner.Resolution, error) {
+	if scanOffline {
+		return nil, nil, fmt.Errorf("%s: resolving package.json needs the npm registry, so it can't be done --offline", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Info("📐 Resolving the dependencies of " + path + " against " + scanNpmRegistry)
+	deps, res, err := scanner.ResolveNpmManifest(client, scanNpmRegistry, data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolving %s: %w", path, err)
+	}
+	for _, u := range res.Unresolved {
+		logger.Debug("Unresolved dependency: " + u)
+	}
+	return deps, res, nil
+}
+
+// isNpmLockfile reports whether kind is a lockfile of a JavaScript package
+// manager, which can have workspaces.
+func isNpmLockfile(kind string) bool {
+	switch scanner.LockfileKind(kind) {
+	case scanner.LockfileNpm, scanner.LockfileYarn, scanner.LockfilePnpm, scanner.LockfileBun:
+		return true
+	}
+	return false
+}
+
+// loadScanInput reads and parses a lockfile, or an SBOM when sbom is set.
+func loadScanInput(path string, sbom bool) (kind string, deps []scanner.Package, err error) {
+	what := "lockfile"
+	if sbom {
+		what = "SBOM"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading %s: %w", what, err)
+	}
+	if sbom {
+		kind, deps, err = scanner.ParseSBOM(data)
+	} else {
+		var lk scanner.LockfileKind
+		lk, deps, err = scanner.ParseLockfile(path, data)
+		kind = string(lk)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing %s: %w", what, err)
+	}
+	return kind, deps, nil
+}
+
+// scanLockfiles scans the lockfiles at paths into a report called label,
+// with a project for each named by name, applying rules and, with
+// ownIgnores, the .keystoneignore beside each lockfile. Lockfiles that
+// can't be parsed are skipped with a warning.
+func scanLockfiles(ctx context.Context, sc *scanner.Scanner, label string, paths []string, rules []scanner.IgnoreRule, ownIgnores bool, name func(path string) string) (*scanner.Report, error) {
+	report := &scanner.Report{Source: label, Lockfile: scanner.LockfileDirectory}
+	for _, path := range paths {
+		kind, deps, err := loadScanInput(path, false)
+		if err != nil {
+			logger.Warn("Skipping "+name(path), "err", err)
 			continue
 		}
+		r, err := sc.Scan(ctx, path, kind, sc.Scannable(deps))
+		if err != nil {
+			return nil, err
+		}
+		r.Source = name(path)
 
-		name := strings.TrimPrefix(k, "node_modules/")
-		// Scoped packages appear as "node_modules/@scope/pkg" → keep as "@scope/pkg"
-		if strings.HasPrefix(name, "@") && strings.Count(name, "/") >= 1 {
-			parts := strings.SplitN(name, "/", 2)
-			if len(parts) == 2 {
-				name = parts[0] + "/" + parts[1]
+		applied := rules
+		if ownIgnores {
+			own, err := scanner.LoadIgnoreFile(filepath.Join(filepath.Dir(path), scanner.IgnoreFileName), true)
+			if err != nil {
+				logger.Warn("Error reading ignore file", "err", err)
 			}
-		} else if i := strings.Index(name, "/"); i >= 0 && !strings.HasPrefix(name, "@") {
-			// For paths like "node_modules/foo/bar" (rare), keep only the first segment
-			name = name[:i]
+			applied = slices.Concat(rules, own)
 		}
+		scanner.ApplyIgnores(r, applied, time.Now())
+		report.Projects = append(report.Projects, r)
+		report.Scanned += r.Scanned
+	}
+	if len(report.Projects) == 0 {
+		return nil, errors.New("none of its lockfiles could be parsed")
+	}
+	report.Partial = report.Unfetched() > 0
+	return report, nil
+}
 
-		out = append(out, dep{name: name, version: ver})
+// installedDrift compares the packages installed in a project with its npm,
+// yarn or pnpm lockfile. Projects without one have no drift to report.
+func installedDrift(dir string, installed []scanner.Package) (*scanner.Drift, error) {
+	for _, name := range []string{"npm-shrinkwrap.json", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "bun.lock"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		_, locked, err := loadScanInput(path, false)
+		if err != nil {
+			return nil, err
+		}
+		return scanner.CompareInstalled(path, installed, locked), nil
+	}
+	return nil, nil
+}
+
+// filterEcosystems keeps the packages from the given ecosystems, or all of
+// them when none are given.
+func filterEcosystems(deps []scanner.Package, ecosystems []string) []scanner.Package {
+	if len(ecosystems) == 0 {
+		return deps
+	}
+	out := deps[:0]
+	for _, d := range deps {
+		for _, eco := range ecosystems {
+			if strings.EqualFold(d.Ecosystem, eco) {
+				out = append(out, d)
+				break
+			}
+		}
 	}
 	return out
 }
+
+func validEcosystem(name string) bool {
+	for _, eco := range scanner.Ecosystems() {
+		if strings.EqualFold(eco, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
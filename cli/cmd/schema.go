@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the JSON report",
+	Long: `Prints the JSON Schema (draft 2020-12) of the report that -o json writes,
+which --formatter-plugin programs are given and 'keystone report merge'
+reads, for tools that consume it to validate against or generate types from.
+
+Every JSON report carries the version of its schema as schema_version,
+currently ` + scanner.ReportSchemaVersion + `. Fields may be added in any release without
+a new major version, so consumers should ignore the ones they don't know; a
+field is only removed, renamed or given a new meaning in a new major
+version, whose reports older releases of keystone refuse to read.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := writeJSON(os.Stdout, scanner.ReportSchema()); err != nil {
+			fatal("Error writing schema", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
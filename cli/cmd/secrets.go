@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// secretsOutputFormats lists the values accepted by secrets --output.
+var secretsOutputFormats = []string{outputText, outputJSON, outputSARIF}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets [path]",
+	Short: "Scan a source tree for hard-coded credentials",
+	Long: `Searches the files under path (default: the current directory) for hard-coded
+credentials: AWS keys, private keys, GitHub, GitLab, Slack, Stripe, Google and
+npm tokens, JWTs, and passwords or API keys assigned in code. Like scan, it
+skips node_modules, vendor and .git, and lockfiles.
+
+Rules are regular expressions, optionally with a minimum Shannon entropy for
+the matched secret so that placeholders like "changeme" aren't reported.
+--rules adds rules from a JSON file:
+
+  [{"id": "internal-token", "description": "Internal API token",
+    "regex": "itk_([A-Za-z0-9]{32})", "group": 1, "severity": "high",
+    "entropy": 3.5}]
+
+Lines containing "keystone:allow" are never reported. Secrets are redacted in
+the output. As with scan, --fail-on exits non-zero on findings at or above a
+severity.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		root := "."
+		if len(args) == 1 {
+			root = filepath.Clean(args[0])
+		}
+		dir := root
+		if !isDir(root) {
+			dir = filepath.Dir(root)
+		}
+		loadConfigFor(cmd, dir)
+
+		if !contains(secretsOutputFormats, secretsOutput) {
+			usagef("Unknown output format %q (want one of: %s)", secretsOutput, strings.Join(secretsOutputFormats, ", "))
+		}
+		if secretsFailOn != "" && !scanner.ValidFailOn(secretsFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", secretsFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+
+		var rules []scanner.SecretRule
+		if !secretsNoDefaultRules {
+			rules = scanner.DefaultSecretRules()
+		}
+		for _, path := range secretsRules {
+			extra, err := scanner.LoadSecretRules(path)
+			if err != nil {
+				fatal("Error reading secret rules", err)
+			}
+			rules = append(rules, extra...)
+		}
+		if len(rules) == 0 {
+			usagef("No secret rules to apply: --no-default-rules needs --rules")
+		}
+
+		logger.Info("🔑 Scanning for secrets in: " + root)
+		findings, err := scanner.ScanSecrets(root, rules)
+		if err != nil {
+			fatal("Error scanning for secrets", err)
+		}
+
+		switch secretsOutput {
+		case outputJSON:
+			if findings == nil {
+				findings = []scanner.SecretFinding{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(findings)
+		case outputSARIF:
+			err = writeSecretsSARIF(os.Stdout, findings)
+		default:
+			writeSecretsText(os.Stdout, findings, colorEnabled(os.Stdout))
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if secretsFailOn != "" {
+			n := 0
+			for _, f := range findings {
+				if scanner.MeetsThreshold(f.Severity, secretsFailOn) {
+					n++
+				}
+			}
+			if n > 0 {
+				failf("%d secret(s) at or above --fail-on=%s", n, secretsFailOn)
+			}
+		}
+	},
+}
+
+var (
+	secretsOutput         string
+	secretsFailOn         string
+	secretsRules          []string
+	secretsNoDefaultRules bool
+)
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+
+	secretsCmd.Flags().StringVarP(&secretsOutput, "output", "o", outputText, "output format: "+strings.Join(secretsOutputFormats, ", "))
+	secretsCmd.Flags().StringVar(&secretsFailOn, "fail-on", "", "exit non-zero if any secret is at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	secretsCmd.Flags().StringSliceVar(&secretsRules, "rules", nil, "JSON file of extra secret rules (repeatable)")
+	secretsCmd.Flags().BoolVar(&secretsNoDefaultRules, "no-default-rules", false, "apply only the rules from --rules")
+}
+
+/********** helpers **********/
+
+func writeSecretsText(w io.Writer, findings []scanner.SecretFinding, color bool) {
+	if len(findings) == 0 {
+		fmt.Fprintln(w, "✅ No hard-coded secrets found.")
+		return
+	}
+	file := ""
+	counts := map[string]int{}
+	for _, f := range findings {
+		if f.File != file {
+			file = f.File
+			fmt.Fprintf(w, "📄 %s\n", file)
+		}
+		counts[f.Severity]++
+		fmt.Fprintf(w, "  🚨 %d:%d %s %s — %s\n", f.Line, f.Column, colorize("["+f.Severity+"]", f.Severity, color), f.Description, f.Secret)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Severity   Count")
+	fmt.Fprintln(w, "  ────────   ─────")
+	for _, sev := range scanner.SeverityOrder {
+		fmt.Fprintf(w, "  %s   %5d\n", colorize(fmt.Sprintf("%-8s", sev), sev, color), counts[sev])
+	}
+}
+
+// writeSecretsSARIF renders secret findings as SARIF, one rule per secret
+// rule that matched and a result located at each match.
+func writeSecretsSARIF(w io.Writer, findings []scanner.SecretFinding) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "keystone",
+			InformationURI: "https://github.com/mdfaisal1/keystone",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	ruleSeen := map[string]bool{}
+	for _, f := range findings {
+		if !ruleSeen[f.RuleID] {
+			ruleSeen[f.RuleID] = true
+			rule := sarifRule{
+				ID:               "secret/" + f.RuleID,
+				ShortDescription: sarifMessage{Text: f.Description},
+				FullDescription:  sarifMessage{Text: f.Description + " committed to the source tree."},
+				Help:             sarifMessage{Text: "Revoke the credential, remove it from the code and its history, and load it from the environment or a secret store instead."},
+				Properties:       map[string]any{"tags": []string{"security", "secret"}},
+			}
+			if score, ok := sarifSecuritySeverity[f.Severity]; ok {
+				rule.Properties["security-severity"] = score
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(f.File)
+		loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Line, StartColumn: f.Column}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "secret/" + f.RuleID,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: fmt.Sprintf("%s found: %s", f.Description, f.Secret)},
+			Locations: []sarifLocation{loc},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}})
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
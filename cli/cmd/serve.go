@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run keystone as a scanning service with a REST and gRPC API",
+	Long: `Serves a REST API for scanning, so that a platform team can run keystone
+once as a shared service rather than installing the CLI everywhere.
+
+  POST /v1/scan?filename=package-lock.json    scan the lockfile in the body
+  POST /v1/scan?sbom=true                     scan the CycloneDX or SPDX SBOM in the body
+  GET  /healthz                               report that the service is up
+  GET  /metrics                               metrics for Prometheus to scrape
+
+The lockfile type is recognised from filename, as on the command line. The
+body can also be a multipart form with the file in a field named "lockfile"
+or "sbom", as sent by:
+
+  curl -F lockfile=@package-lock.json http://localhost:8080/v1/scan
+
+The response is the report, in JSON unless format asks for another of the
+output formats. Other query parameters: prod_only=true leaves development
+dependencies out, and fail_on=high answers 422 Unprocessable Entity, still
+with the report, when a finding is at or above that severity. Errors are
+JSON objects with an "error" message.
+
+Every request shares one OSV client, with its cache, concurrency and rate
+limit, or the offline database with --offline. The server stops gracefully
+on SIGINT or SIGTERM.
+
+/metrics counts scan requests by status and times them, and counts the
+packages scanned and the vulnerabilities found by severity, with a gauge of
+those the latest scan found. Unless --offline, it also counts the OSV
+cache's hits and misses and the requests sent to OSV and how many failed,
+from which to chart the cache hit rate and OSV error rate.
+
+With --tls-cert and --tls-key, the server listens with TLS and also answers
+the gRPC service keystone.v1.Scanner, over HTTP/2 on the same address, for
+callers that want typed clients and no JSON to parse:
+
+  ScanLockfile    scan a lockfile, recognised by its file name
+  ScanSBOM        scan a CycloneDX or SPDX SBOM
+  GetReport       stream the findings of one of the latest 100 scans
+
+Generate clients from proto/keystone/v1/scanner.proto with protoc or buf.
+Parse errors answer INVALID_ARGUMENT and OSV failures UNAVAILABLE. The
+scan metrics count gRPC scans' packages and findings, but not the calls.
+
+Authentication: with --api-keys or --oidc-issuer, scans need a token, sent
+as "Authorization: Bearer <token>" (gRPC metadata "authorization") or an
+X-API-Key header. The --api-keys file has a line per caller with their name,
+key and optionally their own rate limit in requests per minute:
+
+  # name      key                                 requests/minute
+  ci          <output of: openssl rand -hex 32>   600
+  dashboard   <another key>
+
+--oidc-issuer accepts ID tokens that issuer signed for --oidc-audience, such
+as Kubernetes service account or CI job tokens, fetching its keys from its
+discovery document. Callers without their own limit get --client-rate-limit,
+per key or token subject. Requests without a valid token answer 401
+(UNAUTHENTICATED), and those over the limit 429 (RESOURCE_EXHAUSTED) with a
+Retry-After header. /healthz and /metrics stay open, for probes and scrapers.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+
+		// In-flight requests finish on shutdown, so they get their own
+		// contexts rather than the command's.
+		client, err := httpClient(context.Background())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		auth := &serverAuth{perMinute: serveClientRateLimit}
+		if serveAPIKeys != "" {
+			if auth.keys, err = readAPIKeys(serveAPIKeys); err != nil {
+				usage("Error reading --api-keys", err)
+			}
+		}
+		if serveOIDCIssuer != "" {
+			if serveOIDCAudience == "" {
+				usagef("--oidc-issuer needs --oidc-audience, the audience its tokens are for")
+			}
+			if auth.oidc, err = newOIDCVerifier(cmd.Context(), client, serveOIDCIssuer, serveOIDCAudience); err != nil {
+				fatal("Error looking up the OIDC issuer", err)
+			}
+		}
+		if !auth.enabled() {
+			logger.Warn("Serving without authentication: anyone who can reach the server can scan (see --api-keys and --oidc-issuer)")
+		}
+
+		s := &scanServer{
+			opts: sourceOptions{
+				offline:     serveOffline,
+				noCache:     serveNoCache,
+				concurrency: serveConcurrency,
+				rateLimit:   serveRateLimit,
+				cacheTTL:    scanner.DefaultCacheTTL,
+				osvURL:      serveOSVURL,
+				client:      client,
+			},
+			maxBody: serveMaxBody << 20,
+			reports: &grpcReports{},
+			auth:    auth,
+		}
+		// The offline database is opened per request, for the ecosystems
+		// each one needs; the OSV client is shared.
+		if !serveOffline {
+			if s.source, err = s.opts.open(nil); err != nil {
+				fatal("Error opening OSV source", err)
+			}
+		}
+		s.metrics = newServerMetrics(s.source)
+
+		srv := &http.Server{Addr: serveAddr, Handler: s.routes(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-cmd.Context().Done()
+			logger.Info("Shutting down")
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			srv.Shutdown(ctx)
+		}()
+
+		logger.Info("🌐 Serving the keystone API on " + serveAddr)
+		if serveTLSCert != "" {
+			err = srv.ListenAndServeTLS(serveTLSCert, serveTLSKey)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Error serving", err)
+		}
+	},
+}
+
+var (
+	serveAddr        string
+	serveOffline     bool
+	serveNoCache     bool
+	serveOSVURL      string
+	serveConcurrency int
+	serveRateLimit   float64
+	serveMaxBody     int64
+	serveTLSCert     string
+	serveTLSKey      string
+
+	serveAPIKeys         string
+	serveOIDCIssuer      string
+	serveOIDCAudience    string
+	serveClientRateLimit float64
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", ":8080", "address to listen on")
+	serveCmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "certificate to serve TLS with, which the gRPC API needs")
+	serveCmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "private key of --tls-cert")
+	serveCmd.MarkFlagsRequiredTogether("tls-cert", "tls-key")
+	serveCmd.Flags().StringVar(&serveAPIKeys, "api-keys", "", "file of API keys that may call the API, one \"name key [requests/minute]\" per line")
+	serveCmd.Flags().StringVar(&serveOIDCIssuer, "oidc-issuer", "", "accept ID tokens from this OpenID Connect issuer")
+	serveCmd.Flags().StringVar(&serveOIDCAudience, "oidc-audience", "", "audience the --oidc-issuer's tokens must be for")
+	serveCmd.Flags().Float64Var(&serveClientRateLimit, "client-rate-limit", 60, "requests per minute for each caller without a limit of their own (0 = unlimited)")
+	serveCmd.Flags().Int64Var(&serveMaxBody, "max-body", 20, "largest lockfile or SBOM accepted, in MB")
+	serveCmd.Flags().IntVarP(&serveConcurrency, "concurrency", "c", scanner.DefaultConcurrency, "number of parallel OSV requests, across all scans")
+	serveCmd.Flags().Float64Var(&serveRateLimit, "rate-limit", scanner.DefaultRateLimit, "maximum OSV requests per second, across all scans (0 = unlimited)")
+	serveCmd.Flags().BoolVar(&serveOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	serveCmd.Flags().StringVar(&serveOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	serveCmd.MarkFlagsMutuallyExclusive("offline", "osv-url")
+	serveCmd.Flags().BoolVar(&serveNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	addNetworkFlags(serveCmd)
+}
+
+/********** helpers **********/
+
+// reportContentTypes are the media types of the output formats.
+var reportContentTypes = map[string]string{
+	outputText:     "text/plain; charset=utf-8",
+	outputJSON:     "application/json",
+	outputSARIF:    "application/sarif+json",
+	outputHTML:     "text/html; charset=utf-8",
+	outputJUnit:    "application/xml",
+	outputMarkdown: "text/markdown; charset=utf-8",
+}
+
+// scanServer answers the REST and gRPC APIs.
+type scanServer struct {
+	opts    sourceOptions
+	source  scanner.Source // shared; nil when offline
+	maxBody int64
+	metrics *serverMetrics
+	reports *grpcReports
+	auth    *serverAuth
+}
+
+func (s *scanServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/v1/scan", s.metrics.instrument(s.auth.require(s.handleScan)))
+	mux.HandleFunc("/metrics", s.metrics.handle)
+	mux.HandleFunc(grpcService, s.auth.require(s.handleGRPC))
+	return logRequests(mux)
+}
+
+func (s *scanServer) handleScan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		httpError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = outputJSON
+	}
+	if !validOutputFormat(format) {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q (want one of: %s)", format, strings.Join(allOutputFormats(), ", ")))
+		return
+	}
+	failOn := q.Get("fail_on")
+	if failOn != "" && !scanner.ValidFailOn(failOn) {
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("unknown fail_on level %q (want one of: %s)", failOn, strings.Join(scanner.FailOnLevels, ", ")))
+		return
+	}
+	prodOnly, _ := strconv.ParseBool(q.Get("prod_only"))
+	sbom, _ := strconv.ParseBool(q.Get("sbom"))
+
+	name, data, sbom, err := readScanUpload(w, r, s.maxBody, q.Get("filename"), sbom)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		httpError(w, status, err.Error())
+		return
+	}
+
+	report, status, err := s.scan(r.Context(), name, data, sbom, prodOnly)
+	if err != nil {
+		httpError(w, status, err.Error())
+		return
+	}
+
+	if failsThreshold(report, failOn) {
+		status = http.StatusUnprocessableEntity
+	}
+	// Formats from plugins are left to content sniffing.
+	if ct, ok := reportContentTypes[format]; ok {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(status)
+	if err := writeReport(w, report, format); err != nil {
+		logger.Warn("Error writing report", "err", err)
+	}
+}
+
+// scan scans the lockfile or SBOM in data, named name, for both APIs. It
+// fails with the HTTP status to answer with.
+func (s *scanServer) scan(ctx context.Context, name string, data []byte, sbom, prodOnly bool) (*scanner.Report, int, error) {
+	var (
+		kind string
+		deps []scanner.Package
+		err  error
+	)
+	if sbom {
+		kind, deps, err = scanner.ParseSBOM(data)
+	} else {
+		var lk scanner.LockfileKind
+		lk, deps, err = scanner.ParseLockfile(name, data)
+		kind = string(lk)
+	}
+	if err != nil {
+		return nil, http.StatusUnprocessableEntity, err
+	}
+
+	sc := &scanner.Scanner{Source: s.source, ProdOnly: prodOnly}
+	deps = sc.Scannable(deps)
+	if sc.Source == nil {
+		if sc.Source, err = s.opts.open(deps); err != nil {
+			return nil, http.StatusServiceUnavailable, err
+		}
+	}
+	report, err := sc.Scan(ctx, name, kind, deps)
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("OSV query failed: %w", err)
+	}
+	s.metrics.observe(report)
+	return report, http.StatusOK, nil
+}
+
+// failsThreshold reports whether a finding of r is at or above failOn, or
+// can't be ruled out, with results incomplete.
+func failsThreshold(r *scanner.Report, failOn string) bool {
+	return failOn != "" && (r.Failing(failOn) > 0 || r.Partial)
+}
+
+// readScanUpload returns the file posted to /v1/scan, from a multipart form
+// or the raw body, with its base name.
+func readScanUpload(w http.ResponseWriter, r *http.Request, maxBody int64, name string, sbom bool) (string, []byte, bool, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxBody); err != nil {
+			return "", nil, false, err
+		}
+		for _, field := range []string{"lockfile", "sbom"} {
+			f, hdr, err := r.FormFile(field)
+			if err != nil {
+				continue
+			}
+			defer f.Close()
+			data, err := io.ReadAll(f)
+			if name == "" {
+				name = hdr.Filename
+			}
+			return filepath.Base(name), data, sbom || field == "sbom", err
+		}
+		return "", nil, false, errors.New(`multipart form has no "lockfile" or "sbom" file`)
+	}
+	if name == "" && !sbom {
+		return "", nil, false, errors.New("say what the body is with ?filename=<lockfile name> or ?sbom=true")
+	}
+	data, err := io.ReadAll(r.Body)
+	if name == "" {
+		name = "sbom.json"
+	}
+	return filepath.Base(name), data, sbom, err
+}
+
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// statusRecorder remembers the status a handler answered with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController flush gRPC's streamed messages.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests logs each request with its status and duration.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.Info(r.Method+" "+r.URL.Path, "status", rec.status, "duration", time.Since(start).Round(time.Millisecond), "remote", r.RemoteAddr)
+	})
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+// writeSPDX renders deps as an SPDX 2.3 JSON document. The document describes
+// a package for the project itself, which DEPENDS_ON its direct
+// dependencies; dependency edges from the lockfile are kept as well.
+func writeSPDX(w io.Writer, project string, deps []scanner.Package) error {
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              project,
+		DocumentNamespace: "https://github.com/mdfaisal1/keystone/spdx/" + url.PathEscape(project) + "-" + newUUID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: keystone"},
+		},
+	}
+
+	const projectID = "SPDXRef-Project"
+	doc.Packages = append(doc.Packages, spdxPackage{
+		Name:             project,
+		SPDXID:           projectID,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+	})
+	doc.Relationships = append(doc.Relationships, spdxRelationship{doc.SPDXID, "DESCRIBES", projectID})
+
+	pkgs := sbomPackages(deps)
+	ids := map[string]string{} // "name@version" → SPDXID
+	for i, d := range pkgs {
+		ids[d.Name+"@"+d.Version] = fmt.Sprintf("SPDXRef-scanner.Package-%d", i+1)
+	}
+
+	for _, d := range pkgs {
+		id := ids[d.Name+"@"+d.Version]
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             d.Name,
+			SPDXID:           id,
+			VersionInfo:      d.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxLicense(d.License),
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  scanner.PURL(d),
+			}},
+		})
+		if d.Direct {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{projectID, "DEPENDS_ON", id})
+		}
+		for _, r := range d.Requires {
+			if to, ok := ids[r]; ok {
+				doc.Relationships = append(doc.Relationships, spdxRelationship{id, "DEPENDS_ON", to})
+			}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// spdxLicense returns license if it looks like an SPDX license identifier or
+// expression, and NOASSERTION otherwise (e.g. "SEE LICENSE IN LICENSE.md").
+func spdxLicense(license string) string {
+	if spdxLicenseID.MatchString(license) {
+		return license
+	}
+	for _, op := range []string{" OR ", " AND ", " WITH "} {
+		if strings.Contains(license, op) {
+			return license
+		}
+	}
+	return spdxNoAssertion
+}
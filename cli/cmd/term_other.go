@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoRawMode = errors.New("the interactive browser isn't supported on this platform")
+
+func makeRaw(*os.File) (func(), error) {
+	return nil, errNoRawMode
+}
+
+func terminalSize(*os.File) (int, int, error) {
+	return 0, 0, errNoRawMode
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// otlpExportTimeout bounds how long sending the trace may hold up the end of
+// a scan.
+const otlpExportTimeout = 10 * time.Second
+
+// startTrace starts the root span of a command's trace when it is to be
+// exported: to endpoint, or else where the standard OTEL_EXPORTER_OTLP_*
+// variables say. The trace continues one from $TRACEPARENT, if set.
+//
+// The returned func, for the command to defer, ends the trace and sends it;
+// it also runs if the command exits through fatal. With nowhere to send the
+// trace, ctx is returned unchanged and the func does nothing.
+func startTrace(ctx context.Context, client *http.Client, endpoint, name string, attrs ...any) (context.Context, func()) {
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	}
+	if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return ctx, func() {}
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "keystone"
+	}
+
+	t := scanner.NewTracer(os.Getenv("TRACEPARENT"))
+	ctx, root := scanner.StartSpan(scanner.WithTracer(ctx, t), name, attrs...)
+	var once sync.Once
+	export := func() {
+		once.Do(func() {
+			root.Finish()
+			ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+			defer cancel()
+			if err := scanner.ExportOTLP(ctx, client, endpoint, service, otlpHeaders(), t); err != nil {
+				logger.Warn("Error exporting the trace", "err", err)
+				return
+			}
+			logger.Debug("Exported the trace", "endpoint", endpoint, "spans", len(t.Spans()))
+		})
+	}
+	onExit(export)
+	return ctx, export
+}
+
+/********** helpers **********/
+
+// otlpHeaders reads $OTEL_EXPORTER_OTLP_HEADERS: comma-separated
+// name=value pairs, such as an API key the collector wants.
+func otlpHeaders() map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// tuiMinSeverities are the --fail-on style thresholds the severity filter
+// cycles through, "" showing everything.
+var tuiMinSeverities = []string{"", scanner.SeverityCritical, scanner.SeverityHigh, scanner.SeverityMedium, scanner.SeverityLow}
+
+// tuiRow is one advisory affecting one package in the browser's list.
+type tuiRow struct {
+	project *scanner.Report
+	finding scanner.Finding
+	vuln    scanner.Vulnerability
+	ignored string // the ignore file the advisory was added to, if any
+}
+
+// tui is a full-screen browser of a report's findings, drawn with ANSI
+// escapes on a terminal in raw mode.
+type tui struct {
+	report     *scanner.Report
+	rows       []tuiRow
+	visible    []int // indexes into rows that pass the filters
+	cursor     int   // index into visible
+	offset     int   // first visible row on screen
+	details    bool
+	minSev     int // index into tuiMinSeverities
+	pkgFilter  string
+	input      string // text being typed at a prompt
+	prompt     string // "filter" or "reason", while typing
+	status     string
+	ignorePath func(project *scanner.Report) string
+	width      int
+	height     int
+	color      bool
+}
+
+// runTUI browses r on the terminal until the user quits. ignorePath names
+// the ignore file that advisories marked ignored in a project are added to.
+func runTUI(r *scanner.Report, ignorePath func(project *scanner.Report) string) error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return fmt.Errorf("--tui needs an interactive terminal")
+	}
+	t := &tui{report: r, ignorePath: ignorePath, color: colorEnabled(os.Stdout)}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				t.rows = append(t.rows, tuiRow{project: p, finding: f, vuln: v})
+			}
+		}
+	}
+	t.filter()
+
+	restore, err := makeRaw(os.Stdin)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	// Alternate screen, cursor hidden; both undone on the way out.
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	in := bufio.NewReader(os.Stdin)
+	buf := make([]byte, 16)
+	for {
+		t.draw()
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+		if !t.key(string(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+// filter recomputes the visible rows, keeping the cursor on the same row
+// where it's still shown.
+func (t *tui) filter() {
+	current := -1
+	if t.cursor < len(t.visible) {
+		current = t.visible[t.cursor]
+	}
+	t.visible, t.cursor = t.visible[:0], 0
+	for i, row := range t.rows {
+		if min := tuiMinSeverities[t.minSev]; min != "" && !scanner.MeetsThreshold(row.vuln.Severity, min) {
+			continue
+		}
+		if t.pkgFilter != "" && !strings.Contains(strings.ToLower(row.finding.Package), strings.ToLower(t.pkgFilter)) {
+			continue
+		}
+		if i == current {
+			t.cursor = len(t.visible)
+		}
+		t.visible = append(t.visible, i)
+	}
+}
+
+// key handles one key press, returning false to quit.
+func (t *tui) key(k string) bool {
+	if t.prompt != "" {
+		return t.promptKey(k)
+	}
+	t.status = ""
+	page := max(t.listHeight()-1, 1)
+	switch k {
+	case "q", "\x03", "\x04": // q, Ctrl-C, Ctrl-D
+		return false
+	case "\x1b":
+		t.details = false
+	case "j", "\x1b[B", "\x1bOB":
+		t.move(1)
+	case "k", "\x1b[A", "\x1bOA":
+		t.move(-1)
+	case " ", "\x1b[6~":
+		t.move(page)
+	case "b", "\x1b[5~":
+		t.move(-page)
+	case "g", "\x1b[H":
+		t.move(-len(t.visible))
+	case "G", "\x1b[F":
+		t.move(len(t.visible))
+	case "\r", "\n", "l", "\x1b[C":
+		t.details = !t.details
+	case "s":
+		t.minSev = (t.minSev + 1) % len(tuiMinSeverities)
+		t.filter()
+	case "/":
+		t.prompt, t.input = "filter", t.pkgFilter
+	case "i":
+		if len(t.visible) > 0 && t.rows[t.visible[t.cursor]].ignored == "" {
+			t.prompt, t.input = "reason", ""
+		}
+	}
+	return true
+}
+
+// promptKey edits the text typed at a prompt; Enter accepts it and Escape
+// abandons it.
+func (t *tui) promptKey(k string) bool {
+	switch {
+	case k == "\r" || k == "\n":
+		prompt := t.prompt
+		t.prompt = ""
+		if prompt == "filter" {
+			t.pkgFilter = t.input
+			t.filter()
+		} else {
+			t.ignore(t.input)
+		}
+	case k == "\x1b" || k == "\x03":
+		t.prompt = ""
+	case k == "\x7f" || k == "\b":
+		if _, size := utf8.DecodeLastRuneInString(t.input); size > 0 {
+			t.input = t.input[:len(t.input)-size]
+		}
+	case !strings.HasPrefix(k, "\x1b") && utf8.ValidString(k) && k >= " ":
+		t.input += k
+	}
+	return true
+}
+
+func (t *tui) move(n int) {
+	t.cursor = max(0, min(t.cursor+n, len(t.visible)-1))
+}
+
+// ignore adds a rule for the selected advisory to its project's ignore file
+// and marks every row it covers.
+func (t *tui) ignore(reason string) {
+	row := t.rows[t.visible[t.cursor]]
+	path := t.ignorePath(row.project)
+	line := row.vuln.ID
+	if reason = strings.TrimSpace(strings.ReplaceAll(reason, `"`, "'")); reason != "" {
+		line += ` reason="` + reason + `"`
+	}
+	if err := appendLine(path, line); err != nil {
+		t.status = "❌ " + err.Error()
+		return
+	}
+	for i := range t.rows {
+		if t.rows[i].vuln.ID == row.vuln.ID && t.ignorePath(t.rows[i].project) == path {
+			t.rows[i].ignored = path
+		}
+	}
+	t.status = fmt.Sprintf("🙈 Added %s to %s", row.vuln.ID, path)
+}
+
+// listHeight is the number of lines the list gets: the screen less the
+// header, the footer and, when open, the details pane.
+func (t *tui) listHeight() int {
+	h := t.height - 3
+	if t.details {
+		h -= t.height / 2
+	}
+	return max(h, 1)
+}
+
+func (t *tui) draw() {
+	t.width, t.height = 80, 24
+	if w, h, err := terminalSize(os.Stdout); err == nil && w > 0 && h > 0 {
+		t.width, t.height = w, h
+	}
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+
+	// Header: what's shown out of what.
+	head := fmt.Sprintf("keystone · %s · %d of %d finding(s)", t.report.Source, len(t.visible), len(t.rows))
+	if min := tuiMinSeverities[t.minSev]; min != "" {
+		head += " · severity ≥ " + min
+	}
+	if t.pkgFilter != "" {
+		head += fmt.Sprintf(" · package ~ %q", t.pkgFilter)
+	}
+	t.line(&b, "\033[1m"+t.fit(head)+ansiReset)
+
+	// The list, scrolled to keep the cursor in view.
+	height := t.listHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	} else if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+	for i := t.offset; i < t.offset+height; i++ {
+		if i >= len(t.visible) {
+			if i == 0 {
+				t.line(&b, "  No findings match the filters.")
+			} else {
+				t.line(&b, "")
+			}
+			continue
+		}
+		t.line(&b, t.rowLine(t.rows[t.visible[i]], i == t.cursor))
+	}
+
+	if t.details && len(t.visible) > 0 {
+		lines := t.detailLines(t.rows[t.visible[t.cursor]])
+		t.line(&b, strings.Repeat("─", t.width))
+		for i := 0; i < t.height/2-1; i++ {
+			if i < len(lines) {
+				t.line(&b, t.fit(lines[i]))
+			} else {
+				t.line(&b, "")
+			}
+		}
+	}
+
+	// Footer: a prompt, a status message or the keys.
+	switch {
+	case t.prompt == "filter":
+		b.WriteString(t.fit("Package filter: " + t.input + "▏"))
+	case t.prompt == "reason":
+		b.WriteString(t.fit("Reason for ignoring (optional): " + t.input + "▏"))
+	case t.status != "":
+		b.WriteString(t.fit(t.status))
+	default:
+		b.WriteString("\033[2m" + t.fit("↑/↓ move · enter details · s severity · / filter package · i ignore · q quit") + ansiReset)
+	}
+	fmt.Print(b.String())
+}
+
+func (t *tui) rowLine(row tuiRow, selected bool) string {
+	sev := severityLabel(row.vuln)
+	label := sev
+	if row.vuln.Score > 0 {
+		label += fmt.Sprintf(" %.1f", row.vuln.Score)
+	}
+	pkg := row.finding.Package + "@" + row.finding.Version
+	mark := "  " // the emoji below take two columns
+	switch {
+	case row.ignored != "":
+		mark = "🙈"
+	case row.vuln.KEV != nil:
+		mark = "🔥"
+	}
+	text := fmt.Sprintf("%s %-30s %-20s %s", mark, pkg, row.vuln.ID, firstLine(row.vuln.Summary))
+	cursor := "  "
+	if selected {
+		cursor = "▶ "
+	}
+	line := cursor + colorize(fmt.Sprintf("%-13s", "["+label+"]"), sev, t.color) + truncate(text, t.width-15)
+	if selected && t.color {
+		line = "\033[7m" + line + ansiReset
+	}
+	if row.ignored != "" && t.color && !selected {
+		line = "\033[2m" + line + ansiReset
+	}
+	return line
+}
+
+func (t *tui) detailLines(row tuiRow) []string {
+	v, f := row.vuln, row.finding
+	lines := []string{
+		fmt.Sprintf("%s  %s", v.ID, strings.Join(v.Aliases, ", ")),
+		firstLine(v.Summary),
+		"",
+		fmt.Sprintf("Package:   %s %s@%s", f.Ecosystem, f.Package, f.Version),
+	}
+	if len(t.report.Projects) > 0 {
+		lines = append(lines, "Lockfile:  "+row.project.Source)
+	}
+	if f.Dev {
+		lines = append(lines, "Scope:     development dependency")
+	}
+	if len(f.Path) > 0 {
+		lines = append(lines, "Via:       "+strings.Join(f.Path, " → "))
+	}
+	sev := severityLabel(v)
+	if v.Score > 0 {
+		sev += fmt.Sprintf(" %.1f", v.Score)
+	}
+	if v.CVSS != "" {
+		sev += "  " + v.CVSS
+	}
+	lines = append(lines, "Severity:  "+sev)
+	if v.EPSS != nil {
+		lines = append(lines, fmt.Sprintf("EPSS:      %s (percentile %.0f)", formatEPSS(v.EPSS.Score), v.EPSS.Percentile*100))
+	}
+	if v.KEV != nil {
+		lines = append(lines, fmt.Sprintf("KEV:       known exploited, listed %s", v.KEV.DateAdded))
+	}
+	switch {
+	case v.Fixed != "":
+		lines = append(lines, "Fixed in:  "+v.Fixed)
+	case v.Error != "":
+		lines = append(lines, "Error:     "+v.Error)
+	default:
+		lines = append(lines, "Fixed in:  no fix released")
+	}
+	if row.ignored != "" {
+		lines = append(lines, "Ignored:   in "+row.ignored)
+	}
+	if len(v.References) > 0 {
+		lines = append(lines, "", "References:")
+		for _, ref := range v.References {
+			lines = append(lines, "  "+ref)
+		}
+	}
+	return lines
+}
+
+// line writes s and moves to the start of the next line; in raw mode "\n"
+// alone doesn't return the cursor.
+func (t *tui) line(b *strings.Builder, s string) {
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+func (t *tui) fit(s string) string {
+	return truncate(s, t.width)
+}
+
+// truncate cuts s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
+
+// appendLine adds line to the end of the file at path, creating it (and
+// starting on a fresh line) as needed.
+func appendLine(path string, line string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		line = "\n" + line
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [project-dir]",
+	Short: "Check that node_modules holds exactly what package-lock.json locked",
+	Long: `Compares the packages installed under a project's node_modules with the
+archives package-lock.json (or npm-shrinkwrap.json) locks, to catch tampering
+after install and poisoned caches or mirrors.
+
+Each package's archive is taken from npm's cache (--npm-cache, by default
+$npm_config_cache or ~/.npm) or else downloaded from the lockfile's resolved
+URL, and must match the lockfile's integrity hash. Its files must then be
+installed unchanged, and, unless the package has an install script that may
+build files of its own, nothing else may be installed beside them. With
+--offline, packages whose archives aren't cached are left unchecked.
+
+Exits non-zero if any package differs. The v1 lockfiles of npm 6 and earlier
+don't record where packages are installed and can't be verified.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if verifyOutput != outputText && verifyOutput != outputJSON {
+			usagef("Unknown output format %q (want one of: %s, %s)", verifyOutput, outputText, outputJSON)
+		}
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		result, err := scanner.VerifyInstall(dir, scanner.VerifyOptions{
+			Client:   client,
+			CacheDir: verifyNpmCache,
+			Offline:  verifyOffline,
+		})
+		if err != nil {
+			fatal("Error verifying node_modules", err)
+		}
+
+		if verifyOutput == outputJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(result)
+		} else {
+			writeTextVerification(os.Stdout, result)
+		}
+		if err != nil {
+			fatal("Error writing report", err)
+		}
+
+		if len(result.Problems) > 0 {
+			failf("%d difference(s) between node_modules and %s", len(result.Problems), result.Lockfile)
+		}
+	},
+}
+
+var (
+	verifyNpmCache string
+	verifyOffline  bool
+	verifyOutput   string
+)
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().StringVar(&verifyNpmCache, "npm-cache", "", "npm's cache directory to find package archives in (default: $npm_config_cache or ~/.npm)")
+	verifyCmd.Flags().BoolVar(&verifyOffline, "offline", false, "never download archives missing from npm's cache")
+	verifyCmd.Flags().StringVarP(&verifyOutput, "output", "o", outputText, "output format: text, json")
+	addNetworkFlags(verifyCmd)
+}
+
+/********** helpers **********/
+
+func writeTextVerification(w io.Writer, v *scanner.InstallVerification) {
+	fmt.Fprintf(w, "🔏 %s\n", v.Lockfile)
+	for _, p := range v.Problems {
+		where := p.Path
+		if p.File != "" {
+			where += "/" + p.File
+		}
+		line := fmt.Sprintf("  ❌ %s@%s %s: %s", p.Package, p.Version, p.Kind, where)
+		if p.Detail != "" {
+			line += " — " + p.Detail
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(v.Unchecked) > 0 {
+		fmt.Fprintf(w, "  ⚪ %d package(s) not checked:\n", len(v.Unchecked))
+		for _, u := range v.Unchecked {
+			fmt.Fprintf(w, "     • %s\n", u)
+		}
+	}
+	if len(v.Problems) == 0 {
+		fmt.Fprintf(w, "✅ %d package(s) installed exactly as locked.\n", v.Verified)
+	} else {
+		fmt.Fprintf(w, "%d package(s) installed exactly as locked.\n", v.Verified)
+	}
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var verifyAttestationCmd = &cobra.Command{
+	Use:   "verify-attestation <artifact> <attestation>",
+	Short: "Check an artifact's scan attestation before deploying it",
+	Long: `Checks an attestation from 'keystone attest' for a deploy gate: that it is
+signed by a trusted key or identity, that it is about exactly this artifact,
+and that the scan it attests to passes the gate's policy. The artifact is
+given as to attest: a file, or a container image pinned to its digest.
+
+  keystone verify-attestation dist/app.tar.gz app.intoto.json --key attest.pub --fail-on high
+  keystone verify-attestation ghcr.io/acme/api@sha256:4f1c... api.bundle.json \
+      --certificate-identity https://github.com/acme/api/.github/workflows/release.yml@refs/heads/main \
+      --certificate-oidc-issuer https://token.actions.githubusercontent.com --max-age 168h
+
+--key checks the signature of a DSSE envelope from 'attest --key', or of a
+Sigstore bundle signed with a cosign key, against an ECDSA, RSA or Ed25519
+public key in PEM. A bundle signed --keyless is checked by cosign (on the
+PATH or at --cosign): its certificate's chain to Sigstore's root, its
+transparency log entry, and that it was issued to --certificate-identity by
+--certificate-oidc-issuer.
+
+The attested scan then fails the check with a finding at or above --fail-on,
+or with incomplete results; with a deny rule of a --policy file (see
+'keystone policy'), whose package rules don't apply since reports don't keep
+every package; or when it's older than --max-age.
+
+Exits 0 when the artifact may be deployed, 1 when it may not, for any of
+the reasons above, and 3 when the check couldn't be made.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		loadConfigFor(cmd, ".")
+		if verifyAttKey == "" && verifyAttIdentity == "" {
+			usagef("Verify with --key, or with --certificate-identity and --certificate-oidc-issuer for a keyless attestation")
+		}
+		if verifyAttIdentity != "" && verifyAttIssuer == "" {
+			usagef("--certificate-identity needs --certificate-oidc-issuer")
+		}
+		if verifyAttFailOn != "" && !scanner.ValidFailOn(verifyAttFailOn) {
+			usagef("Unknown --fail-on level %q (want one of: %s)", verifyAttFailOn, strings.Join(scanner.FailOnLevels, ", "))
+		}
+		var policy *scanner.Policy
+		if verifyAttPolicy != "" {
+			var err error
+			if policy, err = scanner.LoadPolicy(verifyAttPolicy); err != nil {
+				usage("Error reading --policy", err)
+			}
+		}
+
+		artifact, path := args[0], args[1]
+		subject, err := scanner.ArtifactSubject(artifact)
+		if err != nil {
+			fatal("Error reading the artifact", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fatal("Error reading the attestation", err)
+		}
+		env, bundle, err := scanner.ReadAttestation(data)
+		if err != nil {
+			fatal("Error reading the attestation", fmt.Errorf("%s: %w", path, err))
+		}
+
+		if verifyAttKey != "" {
+			pub, err := scanner.LoadAttestationPublicKey(verifyAttKey)
+			if err != nil {
+				fatal("Error reading the public key", err)
+			}
+			if err := env.Verify(pub); err != nil {
+				failf("Signature check failed: %v", err)
+			}
+			logger.Info("🔏 Signed by " + verifyAttKey)
+		} else {
+			if !bundle {
+				failf("%s isn't a Sigstore bundle, so it can't be checked keylessly (verify it with --key)", path)
+			}
+			if err := verifyWithCosign(cmd.Context(), artifact, subject, path); err != nil {
+				failf("Signature check failed: %v", err)
+			}
+			logger.Info("🔏 Signed by " + verifyAttIdentity + ", issued by " + verifyAttIssuer)
+		}
+
+		statement, err := env.Statement()
+		if err != nil {
+			failf("%v", err)
+		}
+		if !statement.About(subject) {
+			var others []string
+			for _, s := range statement.Subject {
+				others = append(others, s.Name)
+			}
+			failf("The attestation is about %s, not %s (sha256:%s)", strings.Join(others, ", "), subject.Name, subject.Digest["sha256"])
+		}
+		predicate, err := statement.VulnPredicate()
+		if err != nil {
+			failf("%v", err)
+		}
+		report, scanned := predicate.Scanner.Result, predicate.Metadata.ScanFinishedOn
+		if scanned == nil {
+			logger.Info(fmt.Sprintf("📦 %s (sha256:%s) was scanned: %s", subject.Name, subject.Digest["sha256"], attestedFindings(report)))
+			if verifyAttMaxAge > 0 {
+				failf("The attestation doesn't say when the scan was, so it can't be checked against --max-age %s", verifyAttMaxAge)
+			}
+		} else {
+			logger.Info(fmt.Sprintf("📦 %s (sha256:%s) was scanned on %s: %s",
+				subject.Name, subject.Digest["sha256"], scanned.Local().Format(time.DateTime), attestedFindings(report)))
+		}
+
+		if verifyAttMaxAge > 0 && scanned != nil && time.Since(*scanned) > verifyAttMaxAge {
+			failf("The scan is %s old, older than --max-age %s", time.Since(*scanned).Round(time.Minute), verifyAttMaxAge)
+		}
+		if failsThreshold(report, verifyAttFailOn) {
+			if n := report.Failing(verifyAttFailOn); n > 0 {
+				failf("%d finding(s) at or above %s severity", n, verifyAttFailOn)
+			}
+			failf("The scan's results are incomplete, so findings at or above %s can't be ruled out", verifyAttFailOn)
+		}
+		if policy != nil {
+			scanner.EvaluatePolicy(report, policy, nil, time.Now())
+			for _, p := range report.Reports() {
+				for _, v := range p.PolicyViolations {
+					logger.Warn(fmt.Sprintf("%s %q: %s %s@%s %s", v.Action, v.Rule, v.Ecosystem, v.Package, v.Version, v.ID))
+				}
+			}
+			if n := report.PolicyDenials(); n > 0 {
+				failf("%d violation(s) of deny rules in %s", n, verifyAttPolicy)
+			}
+		}
+		logger.Info("✅ " + subject.Name + " passes")
+	},
+}
+
+var (
+	verifyAttKey      string
+	verifyAttIdentity string
+	verifyAttIssuer   string
+	verifyAttFailOn   string
+	verifyAttPolicy   string
+	verifyAttMaxAge   time.Duration
+	verifyAttCosign   string
+)
+
+func init() {
+	rootCmd.AddCommand(verifyAttestationCmd)
+
+	verifyAttestationCmd.Flags().StringVar(&verifyAttKey, "key", "", "public key in PEM the attestation must be signed with")
+	verifyAttestationCmd.Flags().StringVar(&verifyAttIdentity, "certificate-identity", "", "identity a keyless attestation's certificate must be issued to, e.g. a workflow URL or an email")
+	verifyAttestationCmd.Flags().StringVar(&verifyAttIssuer, "certificate-oidc-issuer", "", "OIDC issuer that must have vouched for --certificate-identity")
+	verifyAttestationCmd.MarkFlagsMutuallyExclusive("key", "certificate-identity")
+	verifyAttestationCmd.Flags().StringVar(&verifyAttFailOn, "fail-on", "", "fail when the attested scan found a vulnerability at or above this severity: "+strings.Join(scanner.FailOnLevels, ", "))
+	verifyAttestationCmd.Flags().StringVar(&verifyAttPolicy, "policy", "", "fail on violations of the deny rules of this policy file")
+	verifyAttestationCmd.Flags().DurationVar(&verifyAttMaxAge, "max-age", 0, "fail when the scan is older than this, e.g. 168h (0 = any age)")
+	verifyAttestationCmd.Flags().StringVar(&verifyAttCosign, "cosign", "cosign", "cosign program to check keyless attestations with")
+}
+
+/********** helpers **********/
+
+// verifyWithCosign has cosign check a keyless attestation's bundle: its
+// certificate, its transparency log entry, its signature, and that it's
+// about artifact.
+func verifyWithCosign(ctx context.Context, artifact string, subject scanner.Subject, bundle string) error {
+	if _, err := exec.LookPath(verifyAttCosign); err != nil {
+		return fmt.Errorf("%w (keyless attestations are checked with cosign)", err)
+	}
+	args := []string{"verify-blob-attestation",
+		"--bundle", bundle, "--new-bundle-format",
+		"--type", scanner.VulnPredicateType,
+		"--certificate-identity", verifyAttIdentity,
+		"--certificate-oidc-issuer", verifyAttIssuer,
+	}
+	if _, err := os.Stat(artifact); err == nil {
+		args = append(args, artifact)
+	} else {
+		args = append(args, "--digest", subject.Digest["sha256"], "--digestAlg", "sha256")
+	}
+	c := exec.CommandContext(ctx, verifyAttCosign, args...)
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s: %w", verifyAttCosign, err)
+	}
+	return nil
+}
+
+// attestedFindings summarises an attested scan's findings by severity.
+func attestedFindings(r *scanner.Report) string {
+	counts := map[string]int{}
+	total := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				counts[severityLabel(v)]++
+				total++
+			}
+		}
+	}
+	if total == 0 {
+		return "no vulnerabilities"
+	}
+	var parts []string
+	for _, sev := range scanner.SeverityOrder {
+		if counts[sev] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[sev], strings.ToLower(sev)))
+		}
+	}
+	return fmt.Sprintf("%d vulnerability(ies): %s", total, strings.Join(parts, ", "))
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+var vexCmd = &cobra.Command{
+	Use:   "vex lockfile",
+	Short: "Write an OpenVEX document from the triage decisions in the ignore file",
+	Long: `Scans a lockfile and writes an OpenVEX document to stdout recording the
+triage decisions behind its suppressed findings, one statement per advisory
+and package, so that they can be shared with the project's users or fed to
+other scanners.
+
+Findings suppressed by an ignore rule (from the .keystoneignore next to the
+lockfile, --ignore-file, or the config file) are stated not_affected. A rule
+whose reason is one of OpenVEX's justifications, such as
+vulnerable_code_not_in_execute_path, gives that justification; any other
+reason becomes the statement's impact statement:
+
+  GHSA-p6mc-m468-83gw reason="vulnerable_code_not_in_execute_path"
+
+Findings accepted by --baseline weren't triaged, so they are stated affected.
+
+The document can be read back with 'keystone scan --vex'.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Clean(args[0])
+		cfg := loadConfigFor(cmd, filepath.Dir(path))
+
+		sc := &scanner.Scanner{ProdOnly: vexProdOnly}
+		kind, deps, err := loadScanInput(path, false)
+		if err != nil {
+			fatal("Error", err)
+		}
+		deps = sc.Scannable(deps)
+
+		client, err := httpClient(cmd.Context())
+		if err != nil {
+			fatal("Error configuring HTTP client", err)
+		}
+		sc.Source, err = sourceOptions{
+			offline:     vexOffline,
+			noCache:     vexNoCache,
+			concurrency: scanner.DefaultConcurrency,
+			rateLimit:   scanner.DefaultRateLimit,
+			cacheTTL:    scanner.DefaultCacheTTL,
+			osvURL:      vexOSVURL,
+			client:      client,
+		}.open(deps)
+		if err != nil {
+			fatal("Error loading offline database", err)
+		}
+
+		rules, err := cfg.ignoreRules()
+		if err != nil {
+			fatal("Error reading config", err)
+		}
+		ignorePath, optional := vexIgnoreFile, false
+		if ignorePath == "" {
+			ignorePath, optional = filepath.Join(filepath.Dir(path), scanner.IgnoreFileName), true
+		}
+		fileRules, err := scanner.LoadIgnoreFile(ignorePath, optional)
+		if err != nil {
+			fatal("Error reading ignore file", err)
+		}
+		rules = append(rules, fileRules...)
+
+		var baseline *scanner.Baseline
+		if vexBaseline != "" {
+			if baseline, err = scanner.LoadBaseline(vexBaseline); err != nil {
+				fatal("Error reading baseline", err)
+			}
+		}
+
+		r, err := sc.Scan(cmd.Context(), path, kind, deps)
+		if err != nil {
+			fatal("OSV query failed", err)
+		}
+		for _, rule := range scanner.ApplyIgnores(r, rules, time.Now()) {
+			logger.Warn(fmt.Sprintf("Ignore rule %q expired on %s; its finding is left out", rule.Pattern, rule.Expires))
+		}
+		if baseline != nil {
+			scanner.ApplyBaseline(r, baseline)
+		}
+
+		doc := scanner.NewOpenVEX(vexAuthor, time.Now())
+		scanner.VEXFromSuppressions(r, doc)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			fatal("Error writing VEX document", err)
+		}
+
+		if r.Partial {
+			logger.Warn(fmt.Sprintf("Details of %d advisory(ies) couldn't be fetched from OSV; results are incomplete.", r.Unfetched()))
+		}
+		if len(doc.Statements) == 0 {
+			logger.Warn("No suppressed findings to record; add rules to " + ignorePath)
+		}
+	},
+}
+
+var (
+	vexAuthor     string
+	vexIgnoreFile string
+	vexBaseline   string
+	vexProdOnly   bool
+	vexOffline    bool
+	vexNoCache    bool
+	vexOSVURL     string
+)
+
+func init() {
+	rootCmd.AddCommand(vexCmd)
+
+	vexCmd.Flags().StringVar(&vexAuthor, "author", "Unknown Author", "author of the VEX document, as a name or e-mail address")
+	vexCmd.Flags().StringVar(&vexIgnoreFile, "ignore-file", "", "suppression rules to record (default: "+scanner.IgnoreFileName+" next to the lockfile, if present)")
+	vexCmd.Flags().StringVar(&vexBaseline, "baseline", "", "also record the findings in this baseline file, as affected")
+	vexCmd.Flags().BoolVar(&vexProdOnly, "prod-only", false, "consider only runtime dependencies")
+	vexCmd.Flags().BoolVar(&vexOffline, "offline", false, "match against the database from 'keystone db download' instead of the OSV API")
+	vexCmd.Flags().StringVar(&vexOSVURL, "osv-url", scanner.DefaultOSVURL, "base URL of the OSV API or a compatible mirror")
+	vexCmd.MarkFlagsMutuallyExclusive("offline", "osv-url")
+	vexCmd.Flags().BoolVar(&vexNoCache, "no-cache", false, "always query OSV instead of using cached responses")
+	addNetworkFlags(vexCmd)
+}
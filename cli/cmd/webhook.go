@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mdfaisal1/keystone/cli/pkg/scanner"
+)
+
+// webhookSignatureHeader carries the HMAC of a webhook's body, in the form
+// GitHub's webhooks use: "sha256=" and the hex digest.
+const webhookSignatureHeader = "X-Keystone-Signature-256"
+
+// parseWebhookHeaders reads --webhook-header values, each "Name: value".
+func parseWebhookHeaders(list []string) (http.Header, error) {
+	h := http.Header{}
+	for _, s := range list {
+		name, value, ok := strings.Cut(s, ":")
+		if name = strings.TrimSpace(name); !ok || name == "" {
+			return nil, fmt.Errorf("invalid --webhook-header %q (want \"Name: value\")", s)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// webhookSecret returns the key to sign webhook bodies with, from
+// --webhook-secret or $KEYSTONE_WEBHOOK_SECRET, or "" to leave them unsigned.
+func webhookSecret() string {
+	if scanWebhookSecret != "" {
+		return scanWebhookSecret
+	}
+	return os.Getenv("KEYSTONE_WEBHOOK_SECRET")
+}
+
+// signWebhookBody returns the webhookSignatureHeader value for body.
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postWebhook sends the JSON report to url with the extra headers, signed
+// when secret is set. Like notifications, a failed delivery is logged
+// rather than failing the scan.
+func postWebhook(client *http.Client, url string, headers http.Header, secret string, r *scanner.Report) {
+	var body bytes.Buffer
+	if err := writeJSONReport(&body, r); err != nil {
+		logger.Warn("Error building webhook body", "err", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		logger.Warn("Error sending webhook", "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "keystone")
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	if secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(secret, body.Bytes()))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Warn("Error sending webhook", "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger.Warn("Webhook was rejected", "status", resp.Status)
+		return
+	}
+	logger.Info("📤 Sent the report to the webhook")
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// actionsCommitSHA is a full commit SHA, the only kind of action reference
+// that can't be moved to different code.
+var actionsCommitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// actionsReleaseTag is a tag naming one release, "v4.1.1" or "4.1.1".
+var actionsReleaseTag = regexp.MustCompile(`^v?\d+\.\d+\.\d+([-+][0-9A-Za-z.-]+)?$`)
+
+// isActionsWorkflow reports whether path is a GitHub Actions workflow:
+// a YAML file in a repository's .github/workflows directory.
+func isActionsWorkflow(path string) bool {
+	ext := filepath.Ext(path)
+	dir := filepath.Dir(path)
+	return (ext == ".yml" || ext == ".yaml") &&
+		filepath.Base(dir) == "workflows" && filepath.Base(filepath.Dir(dir)) == ".github"
+}
+
+// extractActionsWorkflow reads the actions and reusable workflows a GitHub
+// Actions workflow uses, from its "uses: owner/repo[/path]@ref" lines:
+//
+//	steps:
+//	  - uses: actions/checkout@v4
+//	  - uses: actions/setup-node@60edb5dd545a775178f52524783378180af0d1f8 # v4.0.2
+//
+// The package is the action's repository, and its version the release the
+// ref names: a tag such as v4.0.2, or the version in the comment Dependabot
+// and Renovate leave after a commit SHA. Other refs, such as v4, a branch
+// or a bare SHA, are kept as written. Every ref but a full commit SHA can be
+// moved to different code by whoever controls the repository, and is marked
+// Unpinned. Local actions ("./…") and Docker images are skipped.
+func extractActionsWorkflow(data []byte) []Package {
+	var out []Package
+	index := map[string]int{} // name@version → index in out
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		line = strings.TrimSpace(strings.TrimPrefix(line, "- "))
+		value, ok := strings.CutPrefix(line, "uses:")
+		if !ok {
+			continue
+		}
+		value, comment, _ := strings.Cut(value, "#")
+		value = unquote(value)
+		if strings.HasPrefix(value, "./") || strings.HasPrefix(value, "docker://") {
+			continue
+		}
+		path, ref, ok := strings.Cut(value, "@")
+		parts := strings.Split(path, "/")
+		if !ok || ref == "" || len(parts) < 2 || parts[0] == "" || parts[1] == "" || strings.Contains(ref, "${{") {
+			continue
+		}
+		name := parts[0] + "/" + parts[1]
+
+		pinned := actionsCommitSHA.MatchString(ref)
+		version := ref
+		switch comment = strings.TrimSpace(comment); {
+		case actionsReleaseTag.MatchString(ref):
+			version = strings.TrimPrefix(ref, "v")
+		case pinned && actionsReleaseTag.MatchString(strings.TrimPrefix(comment, "tag=")):
+			version = strings.TrimPrefix(strings.TrimPrefix(comment, "tag="), "v")
+		}
+
+		if i, ok := index[name+"@"+version]; ok {
+			out[i].Unpinned = out[i].Unpinned || !pinned
+			continue
+		}
+		index[name+"@"+version] = len(out)
+		out = append(out, Package{Ecosystem: "GitHub Actions", Name: name, Version: version, Direct: true, Unpinned: !pinned})
+	}
+	return out
+}
@@ -0,0 +1,323 @@
+package scanner
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The in-toto attestation of a scan: a statement that an artifact was
+// scanned, with the report as its predicate, signed in a DSSE envelope.
+const (
+	InTotoStatementType = "https://in-toto.io/Statement/v1"
+	InTotoPayloadType   = "application/vnd.in-toto+json"
+
+	// VulnPredicateType is cosign's predicate type for vulnerability scan
+	// results, which policy engines such as Kyverno and the Sigstore policy
+	// controller understand.
+	VulnPredicateType = "https://cosign.sigstore.dev/attestation/vuln/v1"
+)
+
+// ScannerURI identifies keystone as the scanner in attestations.
+const ScannerURI = "https://github.com/mdfaisal1/keystone"
+
+// Statement is an in-toto statement about the artifacts in Subject.
+type Statement struct {
+	Type          string          `json:"_type"`
+	Subject       []Subject       `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// Subject is an artifact, identified by its digests (e.g. "sha256": hex).
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// VulnPredicate is the predicate of VulnPredicateType.
+type VulnPredicate struct {
+	Invocation struct {
+		Parameters any    `json:"parameters"`
+		URI        string `json:"uri"`
+		EventID    string `json:"event_id"`
+		BuilderID  string `json:"builder.id"`
+	} `json:"invocation"`
+	Scanner struct {
+		URI     string `json:"uri"`
+		Version string `json:"version"`
+		DB      struct {
+			URI     string `json:"uri"`
+			Version string `json:"version"`
+		} `json:"db"`
+		Result *Report `json:"result"`
+	} `json:"scanner"`
+	Metadata struct {
+		ScanStartedOn  *time.Time `json:"scanStartedOn,omitempty"`
+		ScanFinishedOn *time.Time `json:"scanFinishedOn,omitempty"`
+	} `json:"metadata"`
+}
+
+// NewVulnStatement returns the statement that subject was scanned, with
+// report r, by version of keystone. The scan's times are r's; a report that
+// doesn't record them, from an older keystone, has only written, when the
+// report was written, as when the scan finished at the latest.
+func NewVulnStatement(subject Subject, r *Report, version string, written time.Time) (*Statement, error) {
+	var p VulnPredicate
+	p.Scanner.URI = ScannerURI
+	p.Scanner.Version = version
+	p.Scanner.DB.URI = DefaultOSVURL
+	p.Scanner.Result = r
+	p.Metadata.ScanStartedOn = r.Started
+	p.Metadata.ScanFinishedOn = r.Finished
+	if r.Finished == nil {
+		written = written.UTC()
+		p.Metadata.ScanFinishedOn = &written
+	}
+	predicate, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{
+		Type:          InTotoStatementType,
+		Subject:       []Subject{subject},
+		PredicateType: VulnPredicateType,
+		Predicate:     predicate,
+	}, nil
+}
+
+// digestRefRe matches an image reference pinned to a digest, such as
+// "ghcr.io/acme/api@sha256:...".
+var digestRefRe = regexp.MustCompile(`^(.+)@sha256:([0-9a-f]{64})$`)
+
+// ArtifactSubject returns the subject for artifact: an image reference
+// pinned to its digest, or a file, by its base name and SHA-256.
+func ArtifactSubject(artifact string) (Subject, error) {
+	if m := digestRefRe.FindStringSubmatch(artifact); m != nil {
+		return Subject{Name: m[1], Digest: map[string]string{"sha256": m[2]}}, nil
+	}
+	f, err := os.Open(artifact)
+	if err != nil {
+		if strings.Contains(artifact, "@") || strings.Contains(artifact, ":") {
+			return Subject{}, fmt.Errorf("%s isn't a file or an image pinned with @sha256:<digest>", artifact)
+		}
+		return Subject{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return Subject{}, err
+	}
+	return Subject{Name: filepath.Base(artifact), Digest: map[string]string{"sha256": hex.EncodeToString(h.Sum(nil))}}, nil
+}
+
+// Envelope is a DSSE envelope, the signed form of a statement.
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     []byte              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a signature of an Envelope. KeyID is the SHA-256 of
+// the public key's PKIX encoding, in hex.
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   []byte `json:"sig"`
+}
+
+// PAE is DSSE's pre-authentication encoding of a payload, which is what is
+// signed.
+func PAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// SignStatement signs s with key in a DSSE envelope.
+func SignStatement(s *Statement, key crypto.Signer) (*Envelope, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	msg := PAE(InTotoPayloadType, payload)
+	var sig []byte
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		sig, err = key.Sign(rand.Reader, msg, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(msg)
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		PayloadType: InTotoPayloadType,
+		Payload:     payload,
+		Signatures:  []EnvelopeSignature{{KeyID: keyID, Sig: sig}},
+	}, nil
+}
+
+// KeyID returns the key ID of a public key in envelope signatures.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ErrEncryptedKey is returned by LoadAttestationKey for a key encrypted
+// with a password, as cosign generates them.
+var ErrEncryptedKey = errors.New("the key is encrypted")
+
+// LoadAttestationKey reads an unencrypted ECDSA, RSA or Ed25519 private key
+// from a PEM file: PKCS #8, or SEC 1 or PKCS #1 as openssl writes them.
+func LoadAttestationKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, fmt.Errorf("%s: %w", path, ErrEncryptedKey)
+	}
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+}
+
+// ReadAttestation reads a DSSE envelope, as attest writes when signing with
+// a key, or the envelope in a Sigstore bundle, as cosign writes. bundle is
+// set for the latter.
+func ReadAttestation(data []byte) (env *Envelope, bundle bool, err error) {
+	var doc struct {
+		Envelope
+		DSSEEnvelope *Envelope `json:"dsseEnvelope"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false, err
+	}
+	if doc.DSSEEnvelope != nil {
+		return doc.DSSEEnvelope, true, nil
+	}
+	if doc.PayloadType == "" || len(doc.Signatures) == 0 {
+		return nil, false, errors.New("not a DSSE envelope or Sigstore bundle")
+	}
+	return &doc.Envelope, false, nil
+}
+
+// Verify checks that one of e's signatures is by pub.
+func (e *Envelope) Verify(pub crypto.PublicKey) error {
+	msg := PAE(e.PayloadType, e.Payload)
+	digest := sha256.Sum256(msg)
+	for _, s := range e.Signatures {
+		var ok bool
+		switch pub := pub.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(pub, digest[:], s.Sig)
+		case *rsa.PublicKey:
+			ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], s.Sig) == nil ||
+				rsa.VerifyPSS(pub, crypto.SHA256, digest[:], s.Sig, nil) == nil
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(pub, msg, s.Sig)
+		default:
+			return fmt.Errorf("unsupported public key type %T", pub)
+		}
+		if ok {
+			return nil
+		}
+	}
+	return errors.New("no signature of the attestation is by the key")
+}
+
+// Statement returns the in-toto statement e signs.
+func (e *Envelope) Statement() (*Statement, error) {
+	if e.PayloadType != InTotoPayloadType {
+		return nil, fmt.Errorf("the attestation's payload is %s, not an in-toto statement", e.PayloadType)
+	}
+	var s Statement
+	if err := json.Unmarshal(e.Payload, &s); err != nil {
+		return nil, fmt.Errorf("the attestation's statement can't be read: %w", err)
+	}
+	if !strings.HasPrefix(s.Type, "https://in-toto.io/Statement/") {
+		return nil, fmt.Errorf("the attestation's payload is a %q, not an in-toto statement", s.Type)
+	}
+	return &s, nil
+}
+
+// About reports whether subject, by its SHA-256, is one of s's subjects.
+func (s *Statement) About(subject Subject) bool {
+	for _, sub := range s.Subject {
+		if d := sub.Digest["sha256"]; d != "" && strings.EqualFold(d, subject.Digest["sha256"]) {
+			return true
+		}
+	}
+	return false
+}
+
+// VulnPredicate returns s's predicate, with the report of the scan it
+// attests to.
+func (s *Statement) VulnPredicate() (*VulnPredicate, error) {
+	if s.PredicateType != VulnPredicateType {
+		return nil, fmt.Errorf("the attestation's predicate is a %s, not a vulnerability scan", s.PredicateType)
+	}
+	var p VulnPredicate
+	if err := json.Unmarshal(s.Predicate, &p); err != nil {
+		return nil, fmt.Errorf("the attestation's predicate can't be read: %w", err)
+	}
+	if p.Scanner.Result == nil || p.Scanner.Result.Lockfile == "" {
+		return nil, fmt.Errorf("the attestation's scan result isn't a keystone report (scanner %s)", p.Scanner.URI)
+	}
+	if err := checkSchemaVersion(p.Scanner.Result.SchemaVersion); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadAttestationPublicKey reads an ECDSA, RSA or Ed25519 public key from a
+// PEM file, as "openssl pkey -pubout" and cosign write them.
+func LoadAttestationPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Baseline records a project's accepted findings, so that later scans only
+// report vulnerabilities that are new since it was written. Entries match by
+// package and advisory, not version: moving to another still-affected version
+// doesn't make an old advisory new.
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// BaselineEntry is one accepted advisory for one package.
+type BaselineEntry struct {
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	ID        string `json:"id"`
+}
+
+const baselineVersion = 1
+
+// baselineReason is the reason given for findings a baseline suppresses.
+const baselineReason = "recorded in baseline"
+
+// NewBaseline records every finding in r.
+func NewBaseline(r *Report) *Baseline {
+	b := &Baseline{Version: baselineVersion, Findings: []BaselineEntry{}}
+	seen := map[BaselineEntry]bool{}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				e := BaselineEntry{Ecosystem: f.Ecosystem, Package: f.Package, ID: v.ID}
+				if !seen[e] {
+					seen[e] = true
+					b.Findings = append(b.Findings, e)
+				}
+			}
+		}
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		x, y := b.Findings[i], b.Findings[j]
+		if x.Ecosystem != y.Ecosystem {
+			return x.Ecosystem < y.Ecosystem
+		}
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		return x.ID < y.ID
+	})
+	return b
+}
+
+// LoadBaseline reads a baseline written by WriteFile.
+func LoadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d", path, b.Version)
+	}
+	return &b, nil
+}
+
+// WriteFile saves the baseline as indented JSON, so that changes to it
+// review well.
+func (b *Baseline) WriteFile(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// ApplyBaseline moves the findings recorded in b from r.Findings into
+// r.Suppressed, like ApplyIgnores does for ignore rules.
+func ApplyBaseline(r *Report, b *Baseline) {
+	known := map[BaselineEntry]bool{}
+	for _, e := range b.Findings {
+		known[e] = true
+	}
+
+	kept := r.Findings[:0]
+	for _, f := range r.Findings {
+		vulns := f.Vulns[:0]
+		for _, v := range f.Vulns {
+			if !known[BaselineEntry{Ecosystem: f.Ecosystem, Package: f.Package, ID: v.ID}] {
+				vulns = append(vulns, v)
+				continue
+			}
+			r.Suppressed = append(r.Suppressed, Suppression{
+				Ecosystem: f.Ecosystem,
+				Package:   f.Package,
+				Version:   f.Version,
+				ID:        v.ID,
+				Severity:  v.Severity,
+				Rule:      IgnoreRule{Pattern: v.ID, Reason: baselineReason},
+			})
+		}
+		if len(vulns) > 0 {
+			f.Vulns = vulns
+			kept = append(kept, f)
+		}
+	}
+	r.Findings = kept
+}
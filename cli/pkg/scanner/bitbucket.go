@@ -0,0 +1,192 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultBitbucketURL is Bitbucket Cloud's API.
+const DefaultBitbucketURL = "https://api.bitbucket.org/2.0"
+
+// Bitbucket reads a workspace's repositories through the Bitbucket Cloud
+// API.
+type Bitbucket struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // DefaultBitbucketURL if empty
+
+	// Token is an access token, or "user:app-password".
+	Token string
+}
+
+func (b *Bitbucket) Name() string { return "Bitbucket" }
+
+// OrgRepos lists the repositories of the workspace org.
+func (b *Bitbucket) OrgRepos(ctx context.Context, org string) ([]HostedRepo, error) {
+	api := b.api()
+	var repos []HostedRepo
+	next := "/repositories/" + url.PathEscape(org) + "?pagelen=100"
+	for next != "" {
+		var page struct {
+			Values []struct {
+				FullName   string `json:"full_name"`
+				MainBranch *struct {
+					Name string `json:"name"`
+				} `json:"mainbranch"`
+				Parent *struct {
+					FullName string `json:"full_name"`
+				} `json:"parent"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if _, err := api.getJSON(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Values {
+			repo := HostedRepo{FullName: r.FullName, Fork: r.Parent != nil}
+			if r.MainBranch != nil {
+				repo.DefaultBranch = r.MainBranch.Name
+			}
+			repos = append(repos, repo)
+		}
+		next = page.Next
+	}
+	return repos, nil
+}
+
+// bitbucketMaxDepth is how deep RepoFiles lists a repository's directories.
+const bitbucketMaxDepth = 32
+
+// RepoFiles lists the files on repo's main branch from its source listing.
+func (b *Bitbucket) RepoFiles(ctx context.Context, repo HostedRepo) ([]string, bool, error) {
+	if repo.DefaultBranch == "" {
+		return nil, true, nil // an empty repository
+	}
+	api := b.api()
+	var files []string
+	next := fmt.Sprintf("/repositories/%s/src/%s/?pagelen=100&max_depth=%d", repo.FullName, url.PathEscape(repo.DefaultBranch), bitbucketMaxDepth)
+	for next != "" {
+		var page struct {
+			Values []struct {
+				Path string `json:"path"`
+				Type string `json:"type"`
+			} `json:"values"`
+			Next string `json:"next"`
+		}
+		if _, err := api.getJSON(ctx, next, &page); err != nil {
+			return nil, false, err
+		}
+		for _, e := range page.Values {
+			if e.Type == "commit_file" {
+				files = append(files, e.Path)
+			}
+		}
+		next = page.Next
+	}
+	return files, true, nil
+}
+
+// ReadFile fetches the file at path on repo's main branch.
+func (b *Bitbucket) ReadFile(ctx context.Context, repo HostedRepo, path string) ([]byte, error) {
+	resp, err := b.api().get(ctx, "/repositories/"+repo.FullName+"/src/"+url.PathEscape(repo.DefaultBranch)+"/"+pathEscape(path), "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *Bitbucket) api() *hostAPI {
+	base := b.URL
+	if base == "" {
+		base = DefaultBitbucketURL
+	}
+	return &hostAPI{name: b.Name(), client: b.Client, base: strings.TrimSuffix(base, "/"), auth: tokenAuth(b.Token)}
+}
+
+// BitbucketServer reads a project's repositories through the REST API of
+// Bitbucket Server or Data Center.
+type BitbucketServer struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // the server's, e.g. https://bitbucket.example.com
+
+	// Token is an HTTP access token, or "user:password".
+	Token string
+}
+
+func (b *BitbucketServer) Name() string { return "Bitbucket Server" }
+
+// OrgRepos lists the repositories of the project with the key org. Their
+// FullName is "KEY/slug".
+func (b *BitbucketServer) OrgRepos(ctx context.Context, org string) ([]HostedRepo, error) {
+	api := b.api()
+	var repos []HostedRepo
+	for start, last := 0, false; !last; {
+		var page struct {
+			Values []struct {
+				Slug    string `json:"slug"`
+				Project struct {
+					Key string `json:"key"`
+				} `json:"project"`
+				Archived bool      `json:"archived"`
+				Origin   *struct{} `json:"origin"`
+			} `json:"values"`
+			IsLastPage    bool `json:"isLastPage"`
+			NextPageStart int  `json:"nextPageStart"`
+		}
+		if _, err := api.getJSON(ctx, fmt.Sprintf("/projects/%s/repos?limit=100&start=%d", url.PathEscape(org), start), &page); err != nil {
+			return nil, err
+		}
+		for _, r := range page.Values {
+			// The default branch is left for the server to read.
+			repos = append(repos, HostedRepo{FullName: r.Project.Key + "/" + r.Slug, Archived: r.Archived, Fork: r.Origin != nil})
+		}
+		start, last = page.NextPageStart, page.IsLastPage || len(page.Values) == 0
+	}
+	return repos, nil
+}
+
+// RepoFiles lists the files on repo's default branch.
+func (b *BitbucketServer) RepoFiles(ctx context.Context, repo HostedRepo) ([]string, bool, error) {
+	api := b.api()
+	var files []string
+	for start, last := 0, false; !last; {
+		var page struct {
+			Values        []string `json:"values"`
+			IsLastPage    bool     `json:"isLastPage"`
+			NextPageStart int      `json:"nextPageStart"`
+		}
+		_, err := api.getJSON(ctx, fmt.Sprintf("%s/files?limit=1000&start=%d", b.repoPath(repo), start), &page)
+		if isStatus(err, http.StatusNotFound) && start == 0 {
+			return nil, true, nil // an empty repository has no default branch yet
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		files = append(files, page.Values...)
+		start, last = page.NextPageStart, page.IsLastPage || len(page.Values) == 0
+	}
+	return files, true, nil
+}
+
+// ReadFile fetches the file at path on repo's default branch.
+func (b *BitbucketServer) ReadFile(ctx context.Context, repo HostedRepo, path string) ([]byte, error) {
+	resp, err := b.api().get(ctx, b.repoPath(repo)+"/raw/"+pathEscape(path), "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (b *BitbucketServer) repoPath(repo HostedRepo) string {
+	key, slug, _ := strings.Cut(repo.FullName, "/")
+	return "/projects/" + url.PathEscape(key) + "/repos/" + url.PathEscape(slug)
+}
+
+func (b *BitbucketServer) api() *hostAPI {
+	return &hostAPI{name: b.Name(), client: b.Client, base: strings.TrimSuffix(b.URL, "/") + "/rest/api/1.0", auth: tokenAuth(b.Token)}
+}
@@ -0,0 +1,268 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// bunBinaryHeader starts every binary bun.lockb.
+const bunBinaryHeader = "#!/usr/bin/env bun\nbun-lockfile-format-v0\n"
+
+// bunLock is the text bun.lock of Bun 1.2 and later.
+type bunLock struct {
+	Workspaces map[string]bunWorkspace      `json:"workspaces"`
+	Packages   map[string][]json.RawMessage `json:"packages"`
+}
+
+type bunWorkspace struct {
+	Name                 string            `json:"name"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+}
+
+// extractBunLock reads a text bun.lock, which is JSON with trailing commas:
+//
+//	"workspaces": { "": { "dependencies": { "express": "^4.18.0" } } },
+//	"packages": {
+//	  "express": ["express@4.18.2", "", { "dependencies": { "debug": "2.6.9" } }, "sha512-…"],
+//	  "express/debug": ["debug@2.6.9", "", { … }, "sha512-…"],
+//	}
+//
+// Each key of "packages" is where the package is installed, as a path of
+// package names like npm's node_modules paths, and its value starts with
+// its "name@version". Dependencies are resolved from the nearest enclosing
+// path outwards, the way Node does. Workspace, git, file and link packages
+// are skipped since OSV has nothing to say about them. Packages only the
+// workspaces' devDependencies lead to are marked Dev.
+func extractBunLock(data []byte) ([]Package, error) {
+	var lock bunLock
+	if err := json.Unmarshal(stripTrailingCommas(stripJSComments(data)), &lock); err != nil {
+		return nil, fmt.Errorf("invalid bun.lock: %w", err)
+	}
+
+	type entry struct {
+		id        string // "name@version"
+		deps      []string
+		integrity string
+	}
+	entries := map[string]entry{}
+	for key, fields := range lock.Packages {
+		var id string
+		if len(fields) == 0 || json.Unmarshal(fields[0], &id) != nil {
+			continue
+		}
+		name, version := splitBunID(id)
+		if name == "" || version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+		e := entry{id: name + "@" + version}
+		if len(fields) > 2 {
+			var info struct {
+				Dependencies         map[string]string `json:"dependencies"`
+				OptionalDependencies map[string]string `json:"optionalDependencies"`
+				PeerDependencies     map[string]string `json:"peerDependencies"`
+			}
+			json.Unmarshal(fields[2], &info)
+			for _, m := range []map[string]string{info.Dependencies, info.OptionalDependencies, info.PeerDependencies} {
+				e.deps = append(e.deps, sortedKeys(m)...)
+			}
+		}
+		if len(fields) > 3 {
+			json.Unmarshal(fields[3], &e.integrity)
+		}
+		entries[key] = e
+	}
+
+	// resolve finds the key a package installed at from loads name from.
+	resolve := func(from, name string) (string, bool) {
+		for dir := from; ; dir = bunKeyParent(dir) {
+			key := name
+			if dir != "" {
+				key = dir + "/" + name
+			}
+			if _, ok := entries[key]; ok {
+				return key, true
+			}
+			if dir == "" {
+				return "", false
+			}
+		}
+	}
+
+	// Walk from the workspaces' runtime dependencies first, so that what
+	// they reach isn't marked Dev, then from their devDependencies.
+	runtime := map[string]bool{}
+	reached := map[string]bool{}
+	direct := map[string]bool{}
+	walk := func(roots []string, mark map[string]bool) {
+		queue := roots
+		for len(queue) > 0 {
+			key := queue[0]
+			queue = queue[1:]
+			if mark[key] {
+				continue
+			}
+			mark[key] = true
+			for _, dep := range entries[key].deps {
+				if k, ok := resolve(key, dep); ok && !mark[k] {
+					queue = append(queue, k)
+				}
+			}
+		}
+	}
+	var runtimeRoots, devRoots []string
+	for _, ws := range sortedKeys(lock.Workspaces) {
+		w := lock.Workspaces[ws]
+		for _, group := range []struct {
+			deps map[string]string
+			dev  bool
+		}{{w.Dependencies, false}, {w.OptionalDependencies, false}, {w.PeerDependencies, false}, {w.DevDependencies, true}} {
+			for _, name := range sortedKeys(group.deps) {
+				// A workspace's own copy, if it needs a different version
+				// from the root's, is installed under its name.
+				k, ok := resolve("", name)
+				if ws != "" && w.Name != "" {
+					k, ok = resolve(w.Name, name)
+				}
+				if !ok {
+					continue
+				}
+				direct[entries[k].id] = true
+				if group.dev {
+					devRoots = append(devRoots, k)
+				} else {
+					runtimeRoots = append(runtimeRoots, k)
+				}
+			}
+		}
+	}
+	walk(runtimeRoots, runtime)
+	walk(devRoots, reached)
+
+	var out []Package
+	seen := map[string]int{} // name@version → index in out
+	for _, key := range sortedKeys(entries) {
+		e := entries[key]
+		dev := !runtime[key] && reached[key]
+		if i, ok := seen[e.id]; ok {
+			out[i].Dev = out[i].Dev && dev
+			continue
+		}
+		var requires []string
+		for _, dep := range e.deps {
+			if k, ok := resolve(key, dep); ok {
+				requires = append(requires, entries[k].id)
+			}
+		}
+		name, version := splitBunID(e.id)
+		seen[e.id] = len(out)
+		out = append(out, Package{
+			Ecosystem: "npm",
+			Name:      name,
+			Version:   version,
+			Direct:    direct[e.id],
+			Dev:       dev,
+			Requires:  requires,
+			Integrity: e.integrity,
+		})
+	}
+	return out, nil
+}
+
+// extractBunLockb reads a binary bun.lockb. Its format is Bun's internal
+// one, so Bun itself is asked to print it as a yarn.lock, which it does when
+// run on the file.
+func extractBunLockb(data []byte) ([]Package, error) {
+	bun, err := exec.LookPath("bun")
+	if err != nil {
+		return nil, errors.New("bun.lockb is a binary lockfile only Bun can read: install bun, or save a text bun.lock with 'bun install --save-text-lockfile'")
+	}
+	dir, err := os.MkdirTemp("", "keystone-bun-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "bun.lockb")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return nil, err
+	}
+	var stderr bytes.Buffer
+	c := exec.Command(bun, path)
+	c.Dir, c.Stderr = dir, &stderr
+	yarnLock, err := c.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("bun couldn't read bun.lockb: %s", msg)
+		}
+		return nil, fmt.Errorf("bun couldn't read bun.lockb: %w", err)
+	}
+	return extractYarnPackages(yarnLock), nil
+}
+
+// splitBunID splits "name@version", where a scoped name starts with "@".
+func splitBunID(id string) (name, version string) {
+	i := strings.LastIndex(id, "@")
+	if i <= 0 {
+		return "", ""
+	}
+	return id[:i], id[i+1:]
+}
+
+// bunKeyParent drops the last package name from a bun.lock package key,
+// keeping scoped names whole: "a/@s/b/c" → "a/@s/b", "a/@s/b" → "a".
+func bunKeyParent(key string) string {
+	parts := strings.Split(key, "/")
+	var names []string
+	for i := 0; i < len(parts); i++ {
+		if strings.HasPrefix(parts[i], "@") && i+1 < len(parts) {
+			names = append(names, parts[i]+"/"+parts[i+1])
+			i++
+			continue
+		}
+		names = append(names, parts[i])
+	}
+	if len(names) <= 1 {
+		return ""
+	}
+	return strings.Join(names[:len(names)-1], "/")
+}
+
+// stripTrailingCommas removes the commas before a closing } or ] that JSON
+// doesn't allow, leaving string literals alone.
+func stripTrailingCommas(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if c == '"' {
+			j := i + 1
+			for ; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+			end := min(j+1, len(src))
+			out = append(out, src[i:end]...)
+			i = end - 1
+			continue
+		}
+		if c == ',' {
+			j := i + 1
+			for j < len(src) && (src[j] == ' ' || src[j] == '\t' || src[j] == '\n' || src[j] == '\r') {
+				j++
+			}
+			if j < len(src) && (src[j] == '}' || src[j] == ']') {
+				continue
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
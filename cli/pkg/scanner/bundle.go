@@ -0,0 +1,307 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultEPSSDataURL serves FIRST's latest daily EPSS dataset.
+const DefaultEPSSDataURL = "https://epss.cyentia.com/epss_scores-current.csv.gz"
+
+// Names of the EPSS dataset and KEV catalog in a bundle and, once it is
+// imported, in DBDir beside the OSV databases.
+const (
+	BundleEPSSFile = "epss_scores.csv.gz"
+	BundleKEVFile  = "kev.json"
+)
+
+// Members of a bundle archive that aren't data files.
+const (
+	bundleManifestName  = "manifest.json"
+	bundleSignatureName = "manifest.sig"
+)
+
+// maxBundleManifestSize bounds what is read of a bundle before its
+// signature is checked.
+const maxBundleManifestSize = 1 << 20
+
+// BundleManifest describes the data files of an air-gapped bundle: the
+// OSV databases, one <ecosystem>.zip each, and optionally the EPSS dataset
+// and KEV catalog. The manifest is signed, and lists each file's digest, so
+// the signature covers the whole bundle.
+type BundleManifest struct {
+	Created time.Time    `json:"created"`
+	Files   []BundleFile `json:"files"`
+}
+
+// BundleFile is one data file of a bundle.
+type BundleFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Ecosystems lists the ecosystems m has an OSV database for.
+func (m *BundleManifest) Ecosystems() []string {
+	var out []string
+	for _, f := range m.Files {
+		if eco, ok := strings.CutSuffix(f.Name, ".zip"); ok {
+			out = append(out, eco)
+		}
+	}
+	return out
+}
+
+// DownloadFile saves what url serves to path, replacing any previous copy
+// only once the download has completed. A nil client means
+// http.DefaultClient.
+func DownloadFile(client *http.Client, url, path string) (int64, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+	return writeFileAtomic(path, resp.Body)
+}
+
+// WriteBundle writes the named files of dir to w as a gzipped tar archive,
+// after a manifest of them signed with key.
+func WriteBundle(w io.Writer, dir string, names []string, key ed25519.PrivateKey, created time.Time) (*BundleManifest, error) {
+	m := &BundleManifest{Created: created.UTC()}
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, BundleFile{Name: name, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))})
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	add := func(name string, size int64, r io.Reader) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: m.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.Copy(tw, r)
+		return err
+	}
+	sig := ed25519.Sign(key, manifest)
+	if err := add(bundleManifestName, int64(len(manifest)), bytes.NewReader(manifest)); err != nil {
+		return nil, err
+	}
+	if err := add(bundleSignatureName, int64(len(sig)), bytes.NewReader(sig)); err != nil {
+		return nil, err
+	}
+	for _, bf := range m.Files {
+		f, err := os.Open(filepath.Join(dir, bf.Name))
+		if err != nil {
+			return nil, err
+		}
+		err = add(bf.Name, bf.Size, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// ReadBundle checks the bundle read from r against key and installs its
+// files in dir. Nothing in dir is replaced unless the manifest's signature
+// verifies and every file matches its digest, and then all files are.
+func ReadBundle(r io.Reader, key ed25519.PublicKey, dir string) (*BundleManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a keystone bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	next := func(want string) ([]byte, error) {
+		hdr, err := tr.Next()
+		if err != nil || hdr.Name != want {
+			return nil, fmt.Errorf("not a keystone bundle: %s missing", want)
+		}
+		if hdr.Size > maxBundleManifestSize {
+			return nil, fmt.Errorf("not a keystone bundle: %s too large", want)
+		}
+		return io.ReadAll(tr)
+	}
+	manifest, err := next(bundleManifestName)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := next(bundleSignatureName)
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(key, manifest, sig) {
+		return nil, errors.New("the bundle's signature doesn't verify with the given key")
+	}
+	var m BundleManifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("bad bundle manifest: %w", err)
+	}
+	want := map[string]BundleFile{}
+	for _, f := range m.Files {
+		if f.Name != path.Base(f.Name) || f.Name == "." || f.Name == ".." {
+			return nil, fmt.Errorf("bad bundle manifest: invalid file name %q", f.Name)
+		}
+		want[f.Name] = f
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	staged := map[string]string{} // file name → temporary copy in dir
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp) // no-op once renamed
+		}
+	}()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %w", err)
+		}
+		bf, ok := want[hdr.Name]
+		if !ok || staged[bf.Name] != "" {
+			return nil, fmt.Errorf("bundle holds %s, which its manifest doesn't list", hdr.Name)
+		}
+		tmp, err := os.CreateTemp(dir, ".import-*")
+		if err != nil {
+			return nil, err
+		}
+		staged[bf.Name] = tmp.Name()
+		h := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(tr, bf.Size+1))
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s from bundle: %w", bf.Name, err)
+		}
+		if n != bf.Size || hex.EncodeToString(h.Sum(nil)) != bf.SHA256 {
+			return nil, fmt.Errorf("%s doesn't match the bundle's manifest", bf.Name)
+		}
+	}
+	for _, f := range m.Files {
+		if staged[f.Name] == "" {
+			return nil, fmt.Errorf("bundle is missing %s", f.Name)
+		}
+	}
+	for _, f := range m.Files {
+		if err := os.Rename(staged[f.Name], filepath.Join(dir, f.Name)); err != nil {
+			return nil, err
+		}
+	}
+	return &m, nil
+}
+
+// LoadSigningKey reads an Ed25519 private key from a PEM file in PKCS #8
+// form, as "openssl genpkey -algorithm ed25519" writes.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 private key", path)
+	}
+	return k, nil
+}
+
+// LoadVerifyKey reads an Ed25519 public key from a PEM file, as
+// "openssl pkey -pubout" writes.
+func LoadVerifyKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 public key", path)
+	}
+	return k, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+// writeFileAtomic writes r to path through a temporary file beside it, so
+// that path is either its old self or complete.
+func writeFileAtomic(path string, r io.Reader) (int64, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache stores OSV responses on disk so repeated scans of unchanged
+// dependencies don't hit the network. Entries live in
+// <user cache dir>/keystone/<bucket>/<sha256 of key>.json and expire ttl
+// after they were written. Keys include the API's URL, so mirrors don't share
+// entries. A nil cache is valid and caches nothing.
+type Cache struct {
+	dir string
+	ttl time.Duration
+}
+
+const (
+	cacheBucketQueries = "queries" // vulnerability IDs per ecosystem/name/version
+	cacheBucketVulns   = "vulns"   // full advisory records per ID
+)
+
+// CacheDir returns the directory keystone caches into.
+func CacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "keystone"), nil
+}
+
+// OpenCache returns the cache in CacheDir, whose entries last ttl.
+func OpenCache(ttl time.Duration) (*Cache, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, ttl: ttl}, nil
+}
+
+// ClearCache deletes every cached OSV response, returning the cache
+// directory it cleared.
+func ClearCache() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	for _, bucket := range []string{cacheBucketQueries, cacheBucketVulns, cacheBucketSeverity} {
+		if err := os.RemoveAll(filepath.Join(dir, bucket)); err != nil {
+			return dir, err
+		}
+	}
+	return dir, nil
+}
+
+func (c *Cache) path(bucket, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, bucket, hex.EncodeToString(sum[:])+".json")
+}
+
+// get returns the cached value for key, if present and fresh.
+func (c *Cache) get(bucket, key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	p := c.path(bucket, key)
+	fi, err := os.Stat(p)
+	if err != nil || time.Since(fi.ModTime()) > c.ttl {
+		return nil, false
+	}
+	data, err := os.ReadFile(p)
+	return data, err == nil
+}
+
+// put stores data under key. Failures are ignored: the cache is only an
+// optimisation and a read-only cache dir shouldn't break scans.
+func (c *Cache) put(bucket, key string, data []byte) {
+	if c == nil {
+		return
+	}
+	p := c.path(bucket, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return
+	}
+	// Write-then-rename so concurrent scans never see a partial entry.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), p) != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+func queryCacheKey(api string, d Package) string {
+	return api + "\x00" + d.Ecosystem + "\x00" + d.Name + "\x00" + d.Version
+}
+
+func (c *Cache) queryIDs(api string, d Package) ([]string, bool) {
+	data, ok := c.get(cacheBucketQueries, queryCacheKey(api, d))
+	if !ok {
+		return nil, false
+	}
+	var ids []string
+	return ids, json.Unmarshal(data, &ids) == nil
+}
+
+func (c *Cache) putQueryIDs(api string, d Package, ids []string) {
+	if ids == nil {
+		ids = []string{}
+	}
+	if data, err := json.Marshal(ids); err == nil {
+		c.put(cacheBucketQueries, queryCacheKey(api, d), data)
+	}
+}
@@ -0,0 +1,73 @@
+package scanner
+
+import "strings"
+
+// extractCargoPackages reads the [[package]] tables of a Cargo.lock.
+//
+// Workspace members and path dependencies have no "source" and are skipped,
+// as they are local code rather than published crates; what the workspace
+// members depend on are the direct dependencies. A crate locked at several
+// versions appears once per version and each is queried separately.
+func extractCargoPackages(data []byte) []Package {
+	var (
+		out     []Package
+		needs   [][]string
+		members [][]string
+		seen    = map[string]bool{}
+		locked  = map[string][]string{} // name → versions
+	)
+	for _, pkg := range tomlArrayTables(data, "package") {
+		name, version := pkg["name"], pkg["version"]
+		if name == "" || version == "" {
+			continue
+		}
+		var deps []string
+		if pkg["dependencies"] != "" {
+			deps = strings.Split(pkg["dependencies"], "\n")
+		}
+		if pkg["source"] == "" {
+			members = append(members, deps)
+			continue
+		}
+		if id := name + "@" + version; !seen[id] {
+			seen[id] = true
+			locked[name] = append(locked[name], version)
+			out = append(out, Package{Ecosystem: "crates.io", Name: name, Version: version})
+			needs = append(needs, deps)
+		}
+	}
+
+	// Entries are "name", or "name version" when several versions are locked,
+	// optionally followed by the source in parentheses.
+	resolve := func(entry string) (string, bool) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			return "", false
+		}
+		versions := locked[fields[0]]
+		switch {
+		case len(fields) > 1:
+			return fields[0] + "@" + fields[1], seen[fields[0]+"@"+fields[1]]
+		case len(versions) == 1:
+			return fields[0] + "@" + versions[0], true
+		}
+		return "", false
+	}
+	direct := map[string]bool{}
+	for _, deps := range members {
+		for _, e := range deps {
+			if id, ok := resolve(e); ok {
+				direct[id] = true
+			}
+		}
+	}
+	for i := range out {
+		for _, e := range needs[i] {
+			if id, ok := resolve(e); ok {
+				out[i].Requires = append(out[i].Requires, id)
+			}
+		}
+		out[i].Direct = direct[out[i].Name+"@"+out[i].Version]
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractPodfileLock reads the PODS section of a CocoaPods Podfile.lock:
+//
+//	PODS:
+//	  - Alamofire (5.4.0)
+//	  - Firebase/Core (8.0.0):
+//	    - FirebaseAnalytics (= 8.0.0)
+//
+// Subspecs such as Firebase/Core are reported as their pod, Firebase, once
+// per version. The pods listed under DEPENDENCIES, the Podfile's own, are
+// the direct ones.
+func extractPodfileLock(data []byte) []Package {
+	var (
+		out     []Package
+		needs   [][]string
+		section string
+		seen    = map[string]int{} // name@version → index in out
+		locked  = map[string]string{}
+		direct  = map[string]bool{}
+		current = -1
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \r")
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		entry, ok := strings.CutPrefix(strings.TrimSpace(line), "- ")
+		if !ok {
+			continue
+		}
+		name, version := podNameVersion(unquote(strings.TrimSuffix(entry, ":")))
+		switch {
+		case section == "PODS" && indent == 2:
+			current = -1
+			if version == "" {
+				continue
+			}
+			locked[name] = version
+			id := name + "@" + version
+			i, ok := seen[id]
+			if !ok {
+				i = len(out)
+				seen[id] = i
+				out = append(out, Package{Ecosystem: "CocoaPods", Name: name, Version: version})
+				needs = append(needs, nil)
+			}
+			current = i
+		case section == "PODS" && indent > 2 && current >= 0:
+			needs[current] = append(needs[current], name)
+		case section == "DEPENDENCIES" && indent == 2:
+			direct[name] = true
+		}
+	}
+
+	for i := range out {
+		for _, name := range needs[i] {
+			if v, ok := locked[name]; ok && name != out[i].Name {
+				if r := name + "@" + v; !containsString(out[i].Requires, r) {
+					out[i].Requires = append(out[i].Requires, r)
+				}
+			}
+		}
+		out[i].Direct = direct[out[i].Name]
+	}
+	return out
+}
+
+// podNameVersion splits a Podfile.lock entry such as "Firebase/Core (8.0.0)"
+// into the pod's name, without any subspec, and the version in parentheses,
+// which for a requirement like "(~> 5.4)" is a constraint rather than a
+// version and is dropped.
+func podNameVersion(entry string) (name, version string) {
+	name, rest, _ := strings.Cut(entry, " (")
+	name, _, _ = strings.Cut(strings.TrimSpace(name), "/")
+	version = strings.TrimSuffix(rest, ")")
+	if strings.ContainsAny(version, "=<>~ ") {
+		version = ""
+	}
+	return name, version
+}
@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type composerLock struct {
+	Packages    []composerPackage `json:"packages"`
+	PackagesDev []composerPackage `json:"packages-dev"`
+}
+
+type composerPackage struct {
+	Name    string            `json:"name"`
+	Version string            `json:"version"`
+	License []string          `json:"license"`
+	Require map[string]string `json:"require"`
+}
+
+// extractComposerLock reads the "packages" and "packages-dev" arrays of a
+// composer.lock, the latter marked as dev dependencies. Branch checkouts ("dev-main") have no release to look up and
+// are skipped; the "v" prefix common in tags is dropped.
+func extractComposerLock(data []byte) ([]Package, error) {
+	var lock composerLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	all := append(lock.Packages, lock.PackagesDev...)
+	prod := len(lock.Packages)
+
+	// Each package is locked at exactly one version, so requirements can be
+	// resolved by name alone. Platform requirements (php, ext-*) won't match.
+	locked := map[string]string{}
+	for _, p := range all {
+		locked[p.Name] = strings.TrimPrefix(p.Version, "v")
+	}
+
+	out := make([]Package, 0, len(all))
+	for i, p := range all {
+		if p.Name == "" || p.Version == "" || strings.HasPrefix(p.Version, "dev-") {
+			continue
+		}
+		d := Package{
+			Ecosystem: "Packagist",
+			Name:      p.Name,
+			Version:   locked[p.Name],
+			License:   strings.Join(p.License, " OR "),
+			Dev:       i >= prod,
+		}
+		for _, name := range sortedKeys(p.Require) {
+			if v, ok := locked[name]; ok {
+				d.Requires = append(d.Requires, name+"@"+v)
+			}
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// cvssBaseScore computes the base score of a CVSS v2 or v3.x vector string,
+// e.g. "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H" → 9.8. It returns the
+// CVSS major version alongside the score so callers can pick the right
+// qualitative scale.
+func cvssBaseScore(vector string) (score float64, version int, err error) {
+	metrics, version, err := parseCVSSVector(vector)
+	if err != nil {
+		return 0, 0, err
+	}
+	if version == 3 {
+		score, err = cvss3Score(metrics)
+	} else {
+		score, err = cvss2Score(metrics)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w in %q", err, vector)
+	}
+	return score, version, nil
+}
+
+// parseCVSSVector splits a CVSS v2 or v3.x vector string into its metrics.
+func parseCVSSVector(vector string) (metrics map[string]string, version int, err error) {
+	metrics = map[string]string{}
+	parts := strings.Split(strings.TrimSpace(vector), "/")
+	if strings.HasPrefix(parts[0], "CVSS:") {
+		switch {
+		case strings.HasPrefix(parts[0], "CVSS:3"):
+			version = 3
+		default:
+			return nil, 0, fmt.Errorf("unsupported CVSS version in %q", vector)
+		}
+		parts = parts[1:]
+	} else {
+		version = 2 // v2 vectors carry no prefix
+	}
+	for _, p := range parts {
+		k, v, ok := strings.Cut(p, ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("malformed CVSS vector %q", vector)
+		}
+		metrics[k] = v
+	}
+	return metrics, version, nil
+}
+
+// weight looks up a metric's value in a weight table.
+func weight(metrics map[string]string, metric string, table map[string]float64) (float64, error) {
+	w, ok := table[metrics[metric]]
+	if !ok {
+		return 0, fmt.Errorf("missing or invalid %s metric", metric)
+	}
+	return w, nil
+}
+
+var (
+	cvss3AV  = map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}
+	cvss3AC  = map[string]float64{"L": 0.77, "H": 0.44}
+	cvss3PRU = map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	cvss3PRC = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5} // scope changed
+	cvss3UI  = map[string]float64{"N": 0.85, "R": 0.62}
+	cvss3CIA = map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+)
+
+// cvss3Score implements the CVSS v3.1 base score equations.
+func cvss3Score(m map[string]string) (float64, error) {
+	changed := m["S"] == "C"
+	if m["S"] != "U" && !changed {
+		return 0, fmt.Errorf("missing or invalid S metric")
+	}
+	pr := cvss3PRU
+	if changed {
+		pr = cvss3PRC
+	}
+
+	var w [7]float64
+	for i, x := range []struct {
+		metric string
+		table  map[string]float64
+	}{{"AV", cvss3AV}, {"AC", cvss3AC}, {"PR", pr}, {"UI", cvss3UI}, {"C", cvss3CIA}, {"I", cvss3CIA}, {"A", cvss3CIA}} {
+		var err error
+		if w[i], err = weight(m, x.metric, x.table); err != nil {
+			return 0, err
+		}
+	}
+
+	iss := 1 - (1-w[4])*(1-w[5])*(1-w[6])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w[0] * w[1] * w[2] * w[3]
+	if changed {
+		return cvss3Roundup(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return cvss3Roundup(math.Min(impact+exploitability, 10)), nil
+}
+
+// cvss3Roundup rounds up to one decimal place, avoiding floating-point
+// artefacts as specified in CVSS v3.1 Appendix A.
+func cvss3Roundup(x float64) float64 {
+	i := int(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
+
+var (
+	cvss2AV  = map[string]float64{"L": 0.395, "A": 0.646, "N": 1.0}
+	cvss2AC  = map[string]float64{"H": 0.35, "M": 0.61, "L": 0.71}
+	cvss2Au  = map[string]float64{"M": 0.45, "S": 0.56, "N": 0.704}
+	cvss2CIA = map[string]float64{"N": 0, "P": 0.275, "C": 0.660}
+)
+
+// cvss2Score implements the CVSS v2 base score equation.
+func cvss2Score(m map[string]string) (float64, error) {
+	var w [6]float64
+	for i, x := range []struct {
+		metric string
+		table  map[string]float64
+	}{{"AV", cvss2AV}, {"AC", cvss2AC}, {"Au", cvss2Au}, {"C", cvss2CIA}, {"I", cvss2CIA}, {"A", cvss2CIA}} {
+		var err error
+		if w[i], err = weight(m, x.metric, x.table); err != nil {
+			return 0, err
+		}
+	}
+
+	impact := 10.41 * (1 - (1-w[3])*(1-w[4])*(1-w[5]))
+	exploitability := 20 * w[0] * w[1] * w[2]
+	f := 1.176
+	if impact == 0 {
+		f = 0
+	}
+	return math.Round(((0.6*impact)+(0.4*exploitability)-1.5)*f*10) / 10, nil
+}
+
+// cvss3EnvMetrics lists the CVSS v3.1 environmental metrics in the order
+// vectors give them, with the values each takes; "X" (not defined) falls
+// back to the base metric or, for the requirements, to no adjustment.
+var cvss3EnvMetrics = []struct {
+	name   string
+	values string
+}{
+	{"CR", "XLMH"}, {"IR", "XLMH"}, {"AR", "XLMH"},
+	{"MAV", "XNALP"}, {"MAC", "XLH"}, {"MPR", "XNLH"}, {"MUI", "XNR"}, {"MS", "XUC"},
+	{"MC", "XNLH"}, {"MI", "XNLH"}, {"MA", "XNLH"},
+}
+
+var cvss3Requirement = map[string]float64{"X": 1, "L": 0.5, "M": 1, "H": 1.5}
+
+// CVSSEnvironment holds CVSS v3.1 environmental metrics describing where
+// a deployment differs from the worst case a base score assumes, such as
+// "MAV:L" for a service only reachable locally or "CR:H" for one whose
+// confidentiality matters most.
+type CVSSEnvironment map[string]string
+
+// ParseCVSSEnvironment reads environmental metrics written the way a CVSS
+// vector writes them, e.g. "MAV:N/MAC:H/CR:H".
+func ParseCVSSEnvironment(s string) (CVSSEnvironment, error) {
+	env := CVSSEnvironment{}
+	for _, part := range strings.Split(strings.Trim(strings.TrimSpace(s), "/"), "/") {
+		k, v, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid CVSS environmental metric %q (want e.g. MAV:N)", part)
+		}
+		valid := ""
+		for _, m := range cvss3EnvMetrics {
+			if m.name == k {
+				valid = m.values
+			}
+		}
+		switch {
+		case valid == "":
+			return nil, fmt.Errorf("unknown CVSS environmental metric %q", k)
+		case len(v) != 1 || !strings.Contains(valid, v):
+			return nil, fmt.Errorf("invalid value %q for CVSS metric %s (want one of %s)", v, k, strings.Join(strings.Split(valid, ""), ", "))
+		}
+		env[k] = v
+	}
+	return env, nil
+}
+
+// String writes env in vector form, leaving out undefined metrics.
+func (env CVSSEnvironment) String() string {
+	var parts []string
+	for _, m := range cvss3EnvMetrics {
+		if v := env[m.name]; v != "" && v != "X" {
+			parts = append(parts, m.name+":"+v)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// cvss3EnvironmentalScore implements the CVSS v3.1 environmental score
+// equations for a base vector's metrics and env. Temporal metrics aren't
+// taken into account.
+func cvss3EnvironmentalScore(base map[string]string, env CVSSEnvironment) (float64, error) {
+	m := map[string]string{}
+	for k, v := range base {
+		m[k] = v
+	}
+	for _, k := range []string{"AV", "AC", "PR", "UI", "S", "C", "I", "A"} {
+		if v := env["M"+k]; v != "" && v != "X" {
+			m[k] = v
+		}
+	}
+	changed := m["S"] == "C"
+	if m["S"] != "U" && !changed {
+		return 0, fmt.Errorf("missing or invalid S metric")
+	}
+	pr := cvss3PRU
+	if changed {
+		pr = cvss3PRC
+	}
+
+	var w [7]float64
+	for i, x := range []struct {
+		metric string
+		table  map[string]float64
+	}{{"AV", cvss3AV}, {"AC", cvss3AC}, {"PR", pr}, {"UI", cvss3UI}, {"C", cvss3CIA}, {"I", cvss3CIA}, {"A", cvss3CIA}} {
+		var err error
+		if w[i], err = weight(m, x.metric, x.table); err != nil {
+			return 0, err
+		}
+	}
+	req := func(k string) float64 {
+		if r, ok := cvss3Requirement[env[k]]; ok {
+			return r
+		}
+		return 1
+	}
+
+	miss := math.Min(1-(1-req("CR")*w[4])*(1-req("IR")*w[5])*(1-req("AR")*w[6]), 0.915)
+	impact := 6.42 * miss
+	if changed {
+		impact = 7.52*(miss-0.029) - 3.25*math.Pow(miss*0.9731-0.02, 13)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * w[0] * w[1] * w[2] * w[3]
+	if changed {
+		return cvss3Roundup(cvss3Roundup(math.Min(1.08*(impact+exploitability), 10))), nil
+	}
+	return cvss3Roundup(cvss3Roundup(math.Min(impact+exploitability, 10))), nil
+}
+
+// ApplyCVSSEnvironment rescores each of r's advisories that has a CVSS v3
+// vector under env, on top of any environmental metrics the vector carries
+// itself, and rates its severity by the environmental score. The base
+// score stays in Score; advisories with only a v2 vector are left as they
+// are.
+func ApplyCVSSEnvironment(r *Report, env CVSSEnvironment) {
+	for _, p := range r.Reports() {
+		for i := range p.Findings {
+			for j := range p.Findings[i].Vulns {
+				v := &p.Findings[i].Vulns[j]
+				metrics, version, err := parseCVSSVector(v.CVSS)
+				if err != nil || version != 3 {
+					continue
+				}
+				merged := CVSSEnvironment{}
+				for _, m := range cvss3EnvMetrics {
+					if val := metrics[m.name]; val != "" {
+						merged[m.name] = val
+					}
+				}
+				for k, val := range env {
+					merged[k] = val
+				}
+				score, err := cvss3EnvironmentalScore(metrics, merged)
+				if err != nil {
+					continue
+				}
+
+				vector := strings.SplitN(v.CVSS, "/", 2)[0]
+				for _, k := range []string{"AV", "AC", "PR", "UI", "S", "C", "I", "A"} {
+					vector += "/" + k + ":" + metrics[k]
+				}
+				if s := merged.String(); s != "" {
+					vector += "/" + s
+				}
+				v.Environmental = &CVSSEnvironmental{Score: score, Vector: vector, BaseSeverity: v.Severity}
+				// CVSS rates 0 as none; LOW keeps it apart from UNKNOWN,
+				// which here means unscored.
+				v.Severity = severityFromScore(math.Max(score, 0.1), 3)
+			}
+		}
+	}
+}
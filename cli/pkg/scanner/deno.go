@@ -0,0 +1,251 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// denoLock is a deno.lock of any version: v2 keeps npm packages under "npm",
+// v3 everything under "packages", and v4 and later "specifiers", "jsr" and
+// "npm" at the top level.
+type denoLock struct {
+	Version    string                 `json:"version"`
+	Specifiers map[string]string      `json:"specifiers"`
+	JSR        map[string]denoPackage `json:"jsr"`
+	NPM        json.RawMessage        `json:"npm"`
+	Packages   *struct {
+		Specifiers map[string]string      `json:"specifiers"`
+		JSR        map[string]denoPackage `json:"jsr"`
+		NPM        map[string]denoPackage `json:"npm"`
+	} `json:"packages"`
+	Workspace denoWorkspace `json:"workspace"`
+}
+
+type denoPackage struct {
+	Integrity string `json:"integrity"`
+	// Dependencies is a list of specifiers, or for npm packages in v2 and
+	// v3 lockfiles a map of names to "name@version".
+	Dependencies json.RawMessage `json:"dependencies"`
+}
+
+type denoWorkspace struct {
+	Dependencies []string `json:"dependencies"`
+	PackageJSON  struct {
+		Dependencies []string `json:"dependencies"`
+	} `json:"packageJson"`
+	Members map[string]denoWorkspace `json:"members"`
+}
+
+// specifiers lists the workspace's and its members' dependencies.
+func (w denoWorkspace) specifiers() []string {
+	out := append(append([]string(nil), w.Dependencies...), w.PackageJSON.Dependencies...)
+	for _, name := range sortedKeys(w.Members) {
+		out = append(out, w.Members[name].specifiers()...)
+	}
+	return out
+}
+
+// extractDeno reads a deno.lock, or, for a project without one, the import
+// map of a deno.json, deno.jsonc or import_map.json.
+func extractDeno(data []byte) ([]Package, error) {
+	data = stripTrailingCommas(stripJSComments(data))
+	var probe struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	// A lockfile's version is a bare number; a deno.json can have the
+	// version of the package it publishes.
+	if _, err := strconv.Atoi(probe.Version); err != nil {
+		return extractDenoImports(data)
+	}
+	return extractDenoLock(data)
+}
+
+// extractDenoLock reads the npm: and jsr: packages of a deno.lock, reporting
+// jsr: ones under OSV's JSR ecosystem. Remote https: modules are skipped
+// since they have no ecosystem. The workspace's dependencies, as written in
+// its import map and package.json, are the direct ones; lockfiles too old to
+// record them treat whatever no locked package depends on as direct.
+func extractDenoLock(data []byte) ([]Package, error) {
+	var lock denoLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid deno.lock: %w", err)
+	}
+
+	// Normalise every version's layout to specifier → "npm:name@version" or
+	// "jsr:name@version", and locked packages keyed the same way.
+	specifiers := map[string]string{}
+	jsrPackages := lock.JSR
+	npmPackages := map[string]denoPackage{}
+	switch {
+	case lock.Packages != nil: // v3
+		specifiers = lock.Packages.Specifiers
+		jsrPackages, npmPackages = lock.Packages.JSR, lock.Packages.NPM
+	case lock.Version == "2":
+		var npm struct {
+			Specifiers map[string]string      `json:"specifiers"`
+			Packages   map[string]denoPackage `json:"packages"`
+		}
+		json.Unmarshal(lock.NPM, &npm)
+		for spec, id := range npm.Specifiers {
+			specifiers["npm:"+spec] = "npm:" + id
+		}
+		npmPackages = npm.Packages
+	default: // v4 and later
+		json.Unmarshal(lock.NPM, &npmPackages)
+		for spec, version := range lock.Specifiers {
+			scheme, rest, _ := strings.Cut(spec, ":")
+			name, _ := splitDenoID(rest)
+			specifiers[spec] = scheme + ":" + name + "@" + version
+		}
+	}
+
+	type entry struct {
+		pkg  Package
+		deps json.RawMessage
+	}
+	entries := map[string]*entry{}
+	byName := map[string][]string{} // "npm:name" → ids
+	add := func(scheme, ecosystem string, pkgs map[string]denoPackage) {
+		for _, key := range sortedKeys(pkgs) {
+			name, version := splitDenoID(key)
+			if name == "" || version == "" {
+				continue
+			}
+			id := scheme + ":" + name + "@" + version
+			if entries[id] != nil {
+				continue // the same version with other peer dependencies
+			}
+			p := pkgs[key]
+			entries[id] = &entry{
+				pkg:  Package{Ecosystem: ecosystem, Name: name, Version: version, Integrity: p.Integrity},
+				deps: p.Dependencies,
+			}
+			byName[scheme+":"+name] = append(byName[scheme+":"+name], id)
+		}
+	}
+	add("jsr", "JSR", jsrPackages)
+	add("npm", "npm", npmPackages)
+
+	// resolve finds the locked package a specifier, or an npm package's
+	// dependency entry, stands for.
+	resolve := func(spec string) (string, bool) {
+		if id, ok := specifiers[spec]; ok {
+			spec = id
+		}
+		scheme, rest, _ := strings.Cut(spec, ":")
+		name, version := splitDenoID(rest)
+		if version == "" {
+			if ids := byName[scheme+":"+name]; len(ids) == 1 {
+				return ids[0], true
+			}
+			return "", false
+		}
+		id := scheme + ":" + name + "@" + version
+		return id, entries[id] != nil
+	}
+
+	required := map[string]bool{}
+	for _, e := range entries {
+		var specs []string
+		if json.Unmarshal(e.deps, &specs) != nil {
+			var m map[string]string // v2 and v3 npm: name → "name@version"
+			json.Unmarshal(e.deps, &m)
+			for _, name := range sortedKeys(m) {
+				specs = append(specs, m[name])
+			}
+		}
+		for _, spec := range specs {
+			if !strings.HasPrefix(spec, "npm:") && !strings.HasPrefix(spec, "jsr:") {
+				spec = "npm:" + spec // npm packages' dependencies are npm packages
+			}
+			if id, ok := resolve(spec); ok {
+				e.pkg.Requires = append(e.pkg.Requires, entries[id].pkg.Name+"@"+entries[id].pkg.Version)
+				required[id] = true
+			}
+		}
+	}
+
+	direct := lock.Workspace.specifiers()
+	if len(direct) == 0 {
+		for _, spec := range sortedKeys(specifiers) {
+			if id, ok := resolve(spec); ok && !required[id] {
+				direct = append(direct, spec)
+			}
+		}
+	}
+	for _, spec := range direct {
+		if id, ok := resolve(spec); ok {
+			entries[id].pkg.Direct = true
+		}
+	}
+
+	out := make([]Package, 0, len(entries))
+	for _, id := range sortedKeys(entries) {
+		out = append(out, entries[id].pkg)
+	}
+	return out, nil
+}
+
+// extractDenoImports reads the npm: and jsr: specifiers of an import map,
+// in its "imports" and "scopes". Only those pinned to an exact version can
+// be scanned; ranges such as "npm:chalk@^5" are left to deno.lock.
+func extractDenoImports(data []byte) ([]Package, error) {
+	var m struct {
+		Imports map[string]string            `json:"imports"`
+		Scopes  map[string]map[string]string `json:"scopes"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid import map: %w", err)
+	}
+	targets := []map[string]string{m.Imports}
+	for _, scope := range sortedKeys(m.Scopes) {
+		targets = append(targets, m.Scopes[scope])
+	}
+
+	var out []Package
+	seen := map[string]bool{}
+	for _, imports := range targets {
+		for _, key := range sortedKeys(imports) {
+			scheme, rest, ok := strings.Cut(imports[key], ":")
+			if !ok || (scheme != "npm" && scheme != "jsr") {
+				continue
+			}
+			// "npm:preact@10.19.2/hooks" imports a path within the package.
+			rest = strings.TrimPrefix(rest, "/")
+			name, version := splitDenoID(rest)
+			version, _, _ = strings.Cut(version, "/")
+			exact := strings.Count(version, ".") >= 2
+			if _, ok := parseSemver(version); !ok || !exact || seen[scheme+":"+name+"@"+version] {
+				continue
+			}
+			seen[scheme+":"+name+"@"+version] = true
+			eco := "npm"
+			if scheme == "jsr" {
+				eco = "JSR"
+			}
+			out = append(out, Package{Ecosystem: eco, Name: name, Version: version, Direct: true})
+		}
+	}
+	return out, nil
+}
+
+// splitDenoID splits "name@version", where a scoped name starts with "@"
+// and an npm version may carry a "_peer@version" suffix, which is dropped.
+func splitDenoID(id string) (name, version string) {
+	start := 0
+	if strings.HasPrefix(id, "@") {
+		start = 1
+	}
+	i := strings.Index(id[start:], "@")
+	if i < 0 {
+		return id, ""
+	}
+	i += start
+	version, _, _ = strings.Cut(id[i+1:], "_")
+	return id[:i], version
+}
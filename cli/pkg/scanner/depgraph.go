@@ -0,0 +1,60 @@
+package scanner
+
+// dependencyPaths finds, for every dependency reachable in the lockfile's
+// graph, the shortest chain of "name@version" IDs leading to it from a
+// direct dependency, like `npm ls` shows. The chain ends with the dependency
+// itself, so a direct dependency's chain has length one.
+//
+// Lockfiles that don't mark direct dependencies are walked from the packages
+// nothing else requires. Dependencies with no recorded edges get no path.
+func dependencyPaths(deps []Package) map[string][]string {
+	byID := map[string]Package{}
+	required := map[string]bool{}
+	anyDirect, anyEdges := false, false
+	for _, d := range deps {
+		byID[d.Name+"@"+d.Version] = d
+		anyDirect = anyDirect || d.Direct
+		for _, r := range d.Requires {
+			required[r] = true
+			anyEdges = true
+		}
+	}
+	if !anyDirect && !anyEdges {
+		return nil
+	}
+
+	// Breadth-first from all roots at once, so the first visit is the
+	// shortest path.
+	parent := map[string]string{}
+	var queue []string
+	for _, d := range deps {
+		id := d.Name + "@" + d.Version
+		if _, seen := parent[id]; seen {
+			continue
+		}
+		if d.Direct || (!anyDirect && !required[id]) {
+			parent[id] = ""
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, r := range byID[id].Requires {
+			if _, seen := parent[r]; !seen {
+				parent[r] = id
+				queue = append(queue, r)
+			}
+		}
+	}
+
+	paths := make(map[string][]string, len(parent))
+	for id := range parent {
+		var path []string
+		for p := id; p != ""; p = parent[p] {
+			path = append([]string{p}, path...)
+		}
+		paths[id] = path
+	}
+	return paths
+}
@@ -0,0 +1,305 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultDepsDevURL is the public API of deps.dev, Google's Open Source
+// Insights.
+const DefaultDepsDevURL = "https://api.deps.dev"
+
+// depsDevSystems maps the OSV ecosystems deps.dev covers to its names for
+// them.
+var depsDevSystems = map[string]string{
+	"npm":       "NPM",
+	"PyPI":      "PYPI",
+	"Go":        "GO",
+	"crates.io": "CARGO",
+	"Maven":     "MAVEN",
+	"NuGet":     "NUGET",
+	"RubyGems":  "RUBYGEMS",
+}
+
+// DepsDev talks to the deps.dev API. It is a Source of the advisories
+// deps.dev links to package versions, which are mostly GitHub's and OSV's
+// own, but whose records have no affected ranges, so no fixed version: it
+// is best combined with OSV in a MultiSource, after it. It also knows
+// packages' licenses and how many others depend on them; see Insights.
+type DepsDev struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // "" means DefaultDepsDevURL
+	Cache  *Cache       // may be nil
+
+	// versions holds the version documents fetched so far, by
+	// "ecosystem/name@version", shared by QueryBatch and Insights.
+	versions sync.Map
+}
+
+// depsDevVersion is the part of a deps.dev version document keystone uses.
+type depsDevVersion struct {
+	Licenses     []string `json:"licenses"`
+	AdvisoryKeys []struct {
+		ID string `json:"id"`
+	} `json:"advisoryKeys"`
+}
+
+func (c *DepsDev) api() *hostAPI {
+	return &hostAPI{name: "deps.dev", client: c.Client, base: registryURL(c.URL, DefaultDepsDevURL)}
+}
+
+// versionPath returns the API path of d's version, or "" if deps.dev doesn't
+// cover its ecosystem.
+func versionPath(d Package) string {
+	system, ok := depsDevSystems[d.Ecosystem]
+	if !ok {
+		return ""
+	}
+	version := d.Version
+	if d.Ecosystem == "Go" && !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return "/systems/" + system + "/packages/" + url.PathEscape(d.Name) + "/versions/" + url.PathEscape(version)
+}
+
+// version returns deps.dev's document for d, nil if it doesn't know the
+// version.
+func (c *DepsDev) version(ctx context.Context, d Package) (*depsDevVersion, error) {
+	key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+	if v, ok := c.versions.Load(key); ok {
+		return v.(*depsDevVersion), nil
+	}
+	path := versionPath(d)
+	if path == "" {
+		return nil, nil
+	}
+	var v depsDevVersion
+	if _, err := c.api().getJSON(ctx, "/v3"+path, &v); err != nil {
+		if isStatus(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	c.versions.Store(key, &v)
+	return &v, nil
+}
+
+// QueryBatch returns the IDs of the advisories deps.dev links to each dep,
+// none for packages it doesn't know.
+func (c *DepsDev) QueryBatch(ctx context.Context, deps []Package) ([][]string, error) {
+	base := registryURL(c.URL, DefaultDepsDevURL)
+	ids := make([][]string, len(deps))
+	errs := make([]error, len(deps))
+	done := newProgressCounter(progressFor(ctx, nil), ProgressPackages, len(deps))
+	RunPool(npmResolveConcurrency, len(deps), func(i int) {
+		defer done.add(1)
+		if cached, ok := c.Cache.queryIDs(base, deps[i]); ok {
+			ids[i] = cached
+			return
+		}
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		v, err := c.version(ctx, deps[i])
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		if v != nil {
+			for _, a := range v.AdvisoryKeys {
+				ids[i] = append(ids[i], a.ID)
+			}
+		}
+		c.Cache.putQueryIDs(base, deps[i], ids[i])
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// FetchVulns returns deps.dev's records of ids in OSV's form: a title,
+// aliases, a CVSS v3 vector and a link, without affected ranges.
+func (c *DepsDev) FetchVulns(ctx context.Context, ids []string) (map[string]OSVVuln, map[string]error) {
+	api := c.api()
+	vulns := make([]OSVVuln, len(ids))
+	errs := make([]error, len(ids))
+	done := newProgressCounter(progressFor(ctx, nil), ProgressAdvisories, len(ids))
+	RunPool(npmResolveConcurrency, len(ids), func(i int) {
+		defer done.add(1)
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		key := api.base + "\x00" + ids[i]
+		body, ok := c.Cache.get(cacheBucketVulns, key)
+		if !ok {
+			resp, err := api.get(ctx, "/v3/advisories/"+url.PathEscape(ids[i]), "application/json")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+		}
+		var a struct {
+			URL         string   `json:"url"`
+			Title       string   `json:"title"`
+			Aliases     []string `json:"aliases"`
+			CVSS3Vector string   `json:"cvss3Vector"`
+		}
+		if err := json.Unmarshal(body, &a); err != nil {
+			errs[i] = fmt.Errorf("deps.dev: bad response for %s: %w", ids[i], err)
+			return
+		}
+		if !ok {
+			c.Cache.put(cacheBucketVulns, key, body)
+		}
+		v := OSVVuln{ID: ids[i], Summary: a.Title, Aliases: a.Aliases}
+		if a.CVSS3Vector != "" {
+			v.Severity = append(v.Severity, struct {
+				Type  string `json:"type"`
+				Score string `json:"score"`
+			}{"CVSS_V3", a.CVSS3Vector})
+		}
+		if a.URL != "" {
+			v.References = append(v.References, struct {
+				Type string `json:"type"`
+				URL  string `json:"url"`
+			}{"ADVISORY", a.URL})
+		}
+		vulns[i] = v
+	})
+
+	found := make(map[string]OSVVuln, len(ids))
+	failed := map[string]error{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			failed[id] = errs[i]
+			continue
+		}
+		found[id] = vulns[i]
+	}
+	return found, failed
+}
+
+// Insight is what deps.dev knows of a package version beyond its
+// advisories: its licenses and, for direct dependencies, how widely it's
+// used.
+type Insight struct {
+	Ecosystem        string   `json:"ecosystem"`
+	Package          string   `json:"package"`
+	Version          string   `json:"version"`
+	Licenses         []string `json:"licenses,omitempty"`
+	Dependents       int      `json:"dependents"`        // packages depending on the version, directly or not
+	DirectDependents int      `json:"direct_dependents"` // those declaring it themselves
+}
+
+// Insights looks up the licenses of deps and, for the direct ones, their
+// dependent counts, keyed "ecosystem/name@version". Packages deps.dev
+// doesn't know are left out; the error is the first failure, the other
+// packages being looked up regardless.
+func (c *DepsDev) Insights(ctx context.Context, deps []Package) (map[string]*Insight, error) {
+	api := c.api()
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, npmResolveConcurrency)
+		out      = map[string]*Insight{}
+		firstErr error
+	)
+	// A package direct in one lockfile and not in another gets its counts
+	// whichever comes first.
+	direct := map[string]bool{}
+	for _, d := range deps {
+		if d.Direct {
+			direct[d.Ecosystem+"/"+d.Name+"@"+d.Version] = true
+		}
+	}
+	seen := map[string]bool{}
+	for _, d := range deps {
+		key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+		if seen[key] || versionPath(d) == "" {
+			continue
+		}
+		seen[key] = true
+		d.Direct = direct[key]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d Package, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			v, err := c.version(ctx, d)
+			var counts struct {
+				DependentCount       int `json:"dependentCount"`
+				DirectDependentCount int `json:"directDependentCount"`
+			}
+			if err == nil && v != nil && d.Direct {
+				if _, err = api.getJSON(ctx, "/v3alpha"+versionPath(d)+":dependents", &counts); isStatus(err, http.StatusNotFound) {
+					err = nil
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if v != nil {
+				out[key] = &Insight{
+					Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version, Licenses: v.Licenses,
+					Dependents: counts.DependentCount, DirectDependents: counts.DirectDependentCount,
+				}
+			}
+		}(d, key)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// ApplyInsights fills in, on each of r's reports, the licenses its
+// lockfile doesn't record from insights, from Insights, so that license
+// checks cover those packages too, and records the insights of its direct
+// dependencies, least depended on first.
+func ApplyInsights(r *Report, insights map[string]*Insight) {
+	for _, rep := range r.Reports() {
+		rep.Insights = nil
+		seen := map[string]bool{}
+		for i, d := range rep.Packages {
+			key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+			in := insights[key]
+			if in == nil {
+				continue
+			}
+			if d.License == "" && len(in.Licenses) > 0 {
+				rep.Packages[i].License = strings.Join(in.Licenses, " AND ")
+			}
+			if d.Direct && !seen[key] {
+				seen[key] = true
+				rep.Insights = append(rep.Insights, *in)
+			}
+		}
+		sort.SliceStable(rep.Insights, func(i, j int) bool { return rep.Insights[i].Dependents < rep.Insights[j].Dependents })
+	}
+}
+
+// dependentsByPackage indexes the dependent counts recorded on r by
+// "ecosystem/name@version".
+func dependentsByPackage(r *Report) map[string]int {
+	out := map[string]int{}
+	for _, in := range r.Insights {
+		out[in.Ecosystem+"/"+in.Package+"@"+in.Version] = in.Dependents
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package scanner
+
+// Diff is the change in vulnerabilities between two scans of a project.
+type Diff struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+
+	// Introduced holds the findings of the later scan, narrowed to the
+	// advisories the earlier one didn't report for that package.
+	Introduced []Finding `json:"introduced"`
+
+	// Resolved holds the findings of the earlier scan, narrowed to the
+	// advisories that no longer affect that package.
+	Resolved []Finding `json:"resolved"`
+}
+
+// DiffReports compares two reports. An advisory counts as unchanged while it
+// affects any version of the same package, so upgrading to a release that's
+// still vulnerable neither introduces nor resolves it.
+func DiffReports(before, after *Report) *Diff {
+	return &Diff{
+		Before:     before.Source,
+		After:      after.Source,
+		Introduced: subtractFindings(after, before),
+		Resolved:   subtractFindings(before, after),
+	}
+}
+
+// subtractFindings returns a's findings less the advisories b reports for the
+// same package.
+func subtractFindings(a, b *Report) []Finding {
+	known := map[string]bool{}
+	for _, p := range b.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				known[f.Ecosystem+"\x00"+f.Package+"\x00"+v.ID] = true
+			}
+		}
+	}
+
+	out := []Finding{}
+	for _, p := range a.Reports() {
+		for _, f := range p.Findings {
+			var vulns []Vulnerability
+			for _, v := range f.Vulns {
+				if !known[f.Ecosystem+"\x00"+f.Package+"\x00"+v.ID] {
+					vulns = append(vulns, v)
+				}
+			}
+			if len(vulns) > 0 {
+				f.Vulns = vulns
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// Failing counts the introduced vulnerabilities at or above the --fail-on
+// level.
+func (d *Diff) Failing(level string) int {
+	return (&Report{Findings: d.Introduced}).Failing(level)
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import "testing"
+
+// Maintenance risks, Scorecards and deps.dev insights all go by Direct, so
+// the lockfiles that record it must set it.
+func TestParsersMarkDirectDependencies(t *testing.T) {
+	tests := []struct {
+		path, data string
+		want       map[string]bool // name@version → direct
+	}{
+		{
+			path: "go.mod",
+			data: `module example.com/app
+
+go 1.22
+
+require (
+	github.com/BurntSushi/toml v0.3.1
+	golang.org/x/text v0.3.7 // indirect
+)
+
+require github.com/pkg/errors v0.9.1
+`,
+			want: map[string]bool{
+				"github.com/BurntSushi/toml@0.3.1": true,
+				"golang.org/x/text@0.3.7":          false,
+				"github.com/pkg/errors@0.9.1":      true,
+			},
+		},
+		{
+			path: "pnpm-lock.yaml",
+			data: `lockfileVersion: '9.0'
+
+importers:
+
+  .:
+    dependencies:
+      lodash:
+        specifier: ^4.17.15
+        version: 4.17.15
+      sw:
+        specifier: npm:string-width@4.2.3
+        version: string-width@4.2.3
+    devDependencies:
+      typescript:
+        specifier: ^5.0.0
+        version: 5.0.4
+
+packages:
+
+  lodash@4.17.15:
+    resolution: {integrity: sha512-a}
+
+  string-width@4.2.3:
+    resolution: {integrity: sha512-b}
+
+  typescript@5.0.4:
+    resolution: {integrity: sha512-c}
+
+  minimist@1.2.8:
+    resolution: {integrity: sha512-d}
+`,
+			want: map[string]bool{
+				"lodash@4.17.15":     true,
+				"string-width@4.2.3": true,
+				"typescript@5.0.4":   true,
+				"minimist@1.2.8":     false,
+			},
+		},
+		{
+			path: "pnpm-lock.yaml",
+			data: `lockfileVersion: 5.4
+
+specifiers:
+  lodash: ^4.17.15
+  react-dom: ^17.0.2
+
+dependencies:
+  lodash: 4.17.15
+  react-dom: 17.0.2_react@17.0.2
+
+packages:
+
+  /lodash/4.17.15:
+    resolution: {integrity: sha512-a}
+    dev: false
+
+  /react-dom/17.0.2_react@17.0.2:
+    resolution: {integrity: sha512-b}
+    dev: false
+
+  /minimist/1.2.8:
+    resolution: {integrity: sha512-c}
+    dev: false
+`,
+			want: map[string]bool{
+				"lodash@4.17.15":   true,
+				"react-dom@17.0.2": true,
+				"minimist@1.2.8":   false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		_, pkgs, err := ParseLockfile(tt.path, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		got := map[string]bool{}
+		for _, p := range pkgs {
+			got[p.Name+"@"+p.Version] = p.Direct
+		}
+		for id, direct := range tt.want {
+			if d, ok := got[id]; !ok {
+				t.Errorf("%s: %s missing", tt.path, id)
+			} else if d != direct {
+				t.Errorf("%s: %s direct = %v, want %v", tt.path, id, d, direct)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// skippedDirs are never descended into when discovering lockfiles: they hold
+// installed or vendored copies of dependencies, not projects of their own.
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	".git":         true,
+	".terraform":   true,
+}
+
+// DiscoverLockfiles walks root and returns every supported lockfile beneath
+// it, in lexical order. Where a directory has both go.sum and go.mod, only
+// go.sum is kept since it lists the full module graph. Likewise bun.lock
+// shadows bun.lockb, which Bun prefers it to, deno.lock shadows the import
+// maps it locks, and a .NET project's packages.lock.json or restored
+// obj/project.assets.json shadows its project file.
+func DiscoverLockfiles(root string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if _, ok := LockfileByName(path); ok {
+			found = append(found, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dropShadowedLockfiles(found), nil
+}
+
+// SelectLockfiles returns the supported lockfiles among the files of a
+// repository, given by their slash-separated paths from its root, as
+// DiscoverLockfiles would find them in a checkout of it: outside the
+// directories it skips, and without those shadowed by another.
+func SelectLockfiles(files []string) []string {
+	var found []string
+outer:
+	for _, f := range files {
+		dirs := strings.Split(f, "/")
+		for _, d := range dirs[:len(dirs)-1] {
+			if skippedDirs[d] {
+				continue outer
+			}
+		}
+		if _, ok := LockfileByName(f); ok {
+			found = append(found, filepath.FromSlash(f))
+		}
+	}
+	sort.Strings(found)
+	found = dropShadowedLockfiles(found)
+	for i, f := range found {
+		found[i] = filepath.ToSlash(f)
+	}
+	return found
+}
+
+// shadowingLockfiles maps lockfile name patterns to the paths, relative to
+// their directory, of the lockfiles that make them redundant when present.
+var shadowingLockfiles = []struct {
+	pattern string
+	by      []string
+}{
+	{"go.mod", []string{"go.sum"}},
+	{"bun.lockb", []string{"bun.lock"}},
+	{"deno.json", []string{"deno.lock"}},
+	{"deno.jsonc", []string{"deno.lock"}},
+	{"import_map.json", []string{"deno.lock"}},
+	{"*.csproj", []string{"packages.lock.json", "obj/project.assets.json"}},
+	{"*.fsproj", []string{"packages.lock.json", "obj/project.assets.json"}},
+	{"*.vbproj", []string{"packages.lock.json", "obj/project.assets.json"}},
+	{"project.assets.json", []string{"../packages.lock.json"}},
+}
+
+// dropShadowedLockfiles removes the lockfiles that have one shadowing them
+// beside them.
+func dropShadowedLockfiles(found []string) []string {
+	present := map[string]bool{}
+	for _, p := range found {
+		present[p] = true
+	}
+	shadowed := func(p string) bool {
+		for _, s := range shadowingLockfiles {
+			if ok, _ := filepath.Match(s.pattern, filepath.Base(p)); !ok {
+				continue
+			}
+			for _, other := range s.by {
+				if present[filepath.Join(filepath.Dir(p), other)] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	out := found[:0]
+	for _, p := range found {
+		if !shadowed(p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
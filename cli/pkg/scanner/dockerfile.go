@@ -0,0 +1,277 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LockfileDockerfile is the Lockfile type of the report of a Dockerfile's
+// base images' scan.
+const LockfileDockerfile = "dockerfile"
+
+// BaseImage is an image a Dockerfile builds FROM, and what its registry
+// says about it.
+type BaseImage struct {
+	Line     int    `json:"line"`
+	Stage    string `json:"stage,omitempty"` // the AS name of its build stage
+	Image    string `json:"image"`           // with build arguments substituted
+	Platform string `json:"platform,omitempty"`
+
+	// Pinned is set when the image is referenced by digest, and Latest when
+	// it's the :latest tag, whether written or implied by a missing tag.
+	Pinned bool `json:"pinned"`
+	Latest bool `json:"latest,omitempty"`
+
+	// Digest is the manifest the reference currently resolves to, and
+	// NewerTag the newest later release of the same line as its tag:
+	// "3.20" for alpine:3.18, "18.20.4-alpine" for node:18.17.0-alpine.
+	Digest   string `json:"digest,omitempty"`
+	NewerTag string `json:"newer_tag,omitempty"`
+
+	// Error is why the image couldn't be resolved.
+	Error string `json:"error,omitempty"`
+}
+
+// Ref returns the reference to pull the image by: its digest once resolved.
+func (b BaseImage) Ref() string {
+	if b.Digest == "" || b.Pinned {
+		return b.Image
+	}
+	name, _ := splitImageTag(b.Image)
+	return name + "@" + b.Digest
+}
+
+// DockerfileReport is what CheckDockerfile found.
+type DockerfileReport struct {
+	Dockerfile string      `json:"dockerfile"`
+	Images     []BaseImage `json:"images"`
+
+	// Scan is the vulnerability scan of the images, if they were scanned.
+	Scan *Report `json:"scan,omitempty"`
+}
+
+// DockerfileOptions configure CheckDockerfile.
+type DockerfileOptions struct {
+	Client    *http.Client      // nil means http.DefaultClient
+	BuildArgs map[string]string // override the ARG defaults, as --build-arg does
+	Offline   bool              // don't ask registries about the images
+}
+
+// CheckDockerfile reads the base images of the Dockerfile at path and, unless
+// offline, resolves each to the digest its registry serves and looks for a
+// newer tag of the same line.
+func CheckDockerfile(path string, opts DockerfileOptions) (*DockerfileReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	images, err := ParseDockerfile(data, opts.BuildArgs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !opts.Offline {
+		tags := map[string][]string{} // repository → tags
+		for i := range images {
+			b := &images[i]
+			if b.Error != "" {
+				continue
+			}
+			if b.Pinned {
+				_, b.Digest, _ = strings.Cut(b.Image, "@")
+				continue
+			}
+			if b.Digest, err = ImageDigest(opts.Client, b.Image); err != nil {
+				b.Error = err.Error()
+				continue
+			}
+			name, tag := splitImageTag(b.Image)
+			if tag == "" {
+				continue
+			}
+			list, ok := tags[name]
+			if !ok {
+				if list, err = ImageTags(opts.Client, name); err != nil {
+					b.Error = err.Error()
+				}
+				tags[name] = list
+			}
+			b.NewerTag = NewerImageTag(tag, list)
+		}
+	}
+	return &DockerfileReport{Dockerfile: path, Images: images}, nil
+}
+
+// dockerfileVar is a $NAME, ${NAME} or ${NAME:-default} reference.
+var dockerfileVar = regexp.MustCompile(`\$(?:\{([A-Za-z_][A-Za-z0-9_]*)(?::?-([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// ParseDockerfile returns the images a Dockerfile's FROM instructions build
+// on. References to earlier build stages and to scratch are skipped. ARGs
+// declared before the first FROM are substituted, with buildArgs overriding
+// their defaults; an image naming one with no value gets an Error.
+func ParseDockerfile(data []byte, buildArgs map[string]string) ([]BaseImage, error) {
+	args := map[string]string{}
+	stages := map[string]bool{} // lower-cased names of the stages so far
+	seenFrom := false
+	var out []BaseImage
+	for _, in := range dockerfileInstructions(data) {
+		fields := strings.Fields(in.text)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			if seenFrom {
+				continue // a build stage's own argument, not usable in FROM
+			}
+			for _, f := range fields[1:] {
+				name, value, _ := strings.Cut(f, "=")
+				if v, ok := buildArgs[name]; ok {
+					value = v
+				}
+				args[name] = unquote(value)
+			}
+		case "FROM":
+			seenFrom = true
+			b := BaseImage{Line: in.line}
+			var rest []string
+			for _, f := range fields[1:] {
+				if p, ok := strings.CutPrefix(f, "--platform="); ok {
+					b.Platform = p
+					continue
+				}
+				rest = append(rest, f)
+			}
+			if len(rest) == 0 {
+				return nil, fmt.Errorf("line %d: FROM without an image", in.line)
+			}
+			if len(rest) >= 3 && strings.EqualFold(rest[1], "AS") {
+				b.Stage = rest[2]
+			}
+			var missing []string
+			b.Image = dockerfileVar.ReplaceAllStringFunc(rest[0], func(ref string) string {
+				m := dockerfileVar.FindStringSubmatch(ref)
+				name := m[1] + m[3]
+				if v := args[name]; v != "" {
+					return v
+				}
+				if m[2] != "" {
+					return m[2]
+				}
+				missing = append(missing, name)
+				return ref
+			})
+			builtOnStage := stages[strings.ToLower(b.Image)] || strings.EqualFold(b.Image, "scratch")
+			if b.Stage != "" {
+				stages[strings.ToLower(b.Stage)] = true
+			}
+			if builtOnStage {
+				continue
+			}
+			if len(missing) > 0 {
+				b.Error = fmt.Sprintf("the build argument %s has no value; give it one with --build-arg", missing[0])
+			} else {
+				_, tag := splitImageTag(b.Image)
+				b.Pinned = strings.Contains(b.Image, "@")
+				b.Latest = !b.Pinned && (tag == "" || tag == "latest")
+			}
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+type dockerfileInstruction struct {
+	line int
+	text string
+}
+
+// dockerfileInstructions joins a Dockerfile's continuation lines and drops
+// its comments, keeping the line each instruction starts on.
+func dockerfileInstructions(data []byte) []dockerfileInstruction {
+	var (
+		out     []dockerfileInstruction
+		current *dockerfileInstruction
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current == nil {
+			if line == "" {
+				continue
+			}
+			out = append(out, dockerfileInstruction{line: n})
+			current = &out[len(out)-1]
+		}
+		text, continued := strings.CutSuffix(line, "\\")
+		current.text += " " + text
+		if !continued {
+			current = nil
+		}
+	}
+	return out
+}
+
+// splitImageTag splits an image reference into its name and tag. A
+// reference by digest has no tag to speak of.
+func splitImageTag(ref string) (name, tag string) {
+	if name, _, ok := strings.Cut(ref, "@"); ok {
+		if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+			name = name[:i]
+		}
+		return name, ""
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// imageTagVersion matches a tag that starts with a version: the numbers,
+// then whatever variant follows them ("-alpine", "-slim-bookworm").
+var imageTagVersion = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)(.*)$`)
+
+// NewerImageTag returns the newest of tags that is a later release of the
+// same line as tag: one with as many version numbers and the same variant
+// after them. It returns "" if tag is the newest, or doesn't start with a
+// version, as "bookworm" and "lts" don't.
+func NewerImageTag(tag string, tags []string) string {
+	m := imageTagVersion.FindStringSubmatch(tag)
+	if m == nil {
+		return ""
+	}
+	current := strings.Split(m[1], ".")
+	best, bestParts := "", current
+	for _, t := range tags {
+		c := imageTagVersion.FindStringSubmatch(t)
+		if c == nil || c[2] != m[2] {
+			continue
+		}
+		parts := strings.Split(c[1], ".")
+		if len(parts) == len(current) && compareNumbers(parts, bestParts) > 0 {
+			best, bestParts = t, parts
+		}
+	}
+	return best
+}
+
+// compareNumbers orders two equally long lists of decimal numbers.
+func compareNumbers(a, b []string) int {
+	for i := range a {
+		x, _ := strconv.ParseUint(a[i], 10, 64)
+		y, _ := strconv.ParseUint(b[i], 10, 64)
+		if c := cmpUint(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultEPSSURL is FIRST's EPSS API.
+const DefaultEPSSURL = "https://api.first.org/data/v1/epss"
+
+// epssBatchSize keeps EPSS API request URLs well under common length limits.
+const epssBatchSize = 100
+
+// EPSSScore is the Exploit Prediction Scoring System's estimate for one CVE:
+// the probability of exploitation in the next 30 days, and how that ranks
+// among all scored CVEs.
+type EPSSScore struct {
+	Score      float64 `json:"score"`
+	Percentile float64 `json:"percentile"`
+}
+
+// EPSSScores maps CVE IDs to their scores.
+type EPSSScores map[string]EPSSScore
+
+// FetchEPSS looks up cves in the EPSS API at apiURL (DefaultEPSSURL if
+// empty). CVEs EPSS hasn't scored are absent from the result. A nil client
+// means http.DefaultClient.
+func FetchEPSS(client *http.Client, apiURL string, cves []string) (EPSSScores, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if apiURL == "" {
+		apiURL = DefaultEPSSURL
+	}
+	out := EPSSScores{}
+	for start := 0; start < len(cves); start += epssBatchSize {
+		batch := cves[start:min(start+epssBatchSize, len(cves))]
+		resp, err := client.Get(apiURL + "?" + url.Values{"cve": {strings.Join(batch, ",")}}.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("EPSS request failed: %w", err)
+		}
+		var page struct {
+			Data []struct {
+				CVE        string `json:"cve"`
+				EPSS       string `json:"epss"`
+				Percentile string `json:"percentile"`
+			} `json:"data"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("EPSS API returned %s", resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("bad EPSS response: %w", err)
+		}
+		for _, d := range page.Data {
+			score, err1 := strconv.ParseFloat(d.EPSS, 64)
+			pct, err2 := strconv.ParseFloat(d.Percentile, 64)
+			if err1 == nil && err2 == nil {
+				out[d.CVE] = EPSSScore{Score: score, Percentile: pct}
+			}
+		}
+	}
+	return out, nil
+}
+
+// LoadEPSSFile reads FIRST's daily EPSS dataset, epss_scores-YYYY-MM-DD.csv,
+// optionally gzipped as published: a "#model_version:..." comment, a
+// "cve,epss,percentile" header, then one row per CVE.
+func LoadEPSSFile(path string) (EPSSScores, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	out := EPSSScores{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "cve,") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: want cve,epss,percentile", path, n)
+		}
+		score, err1 := strconv.ParseFloat(fields[1], 64)
+		pct, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%s:%d: bad score", path, n)
+		}
+		out[fields[0]] = EPSSScore{Score: score, Percentile: pct}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return out, nil
+}
+
+// CVEs returns the CVE IDs of every vulnerability in r, from their IDs or
+// aliases, sorted and without duplicates.
+func (r *Report) CVEs() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				for _, id := range v.CVEs() {
+					if !seen[id] {
+						seen[id] = true
+						out = append(out, id)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CVEs returns the CVE IDs of v: its own ID, if a CVE, and its CVE aliases.
+func (v Vulnerability) CVEs() []string {
+	var out []string
+	for _, id := range append([]string{v.ID}, v.Aliases...) {
+		if strings.HasPrefix(id, "CVE-") {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// ApplyEPSS sets the EPSS score of every vulnerability in r with a scored
+// CVE. An advisory covering several CVEs takes the highest score.
+func ApplyEPSS(r *Report, scores EPSSScores) {
+	for _, p := range r.Reports() {
+		for i := range p.Findings {
+			for j := range p.Findings[i].Vulns {
+				v := &p.Findings[i].Vulns[j]
+				for _, id := range v.CVEs() {
+					if s, ok := scores[id]; ok && (v.EPSS == nil || s.Score > v.EPSS.Score) {
+						s := s
+						v.EPSS = &s
+					}
+				}
+			}
+		}
+	}
+}
+
+// FailingEPSS counts the vulnerabilities whose EPSS score is at least
+// threshold.
+func (r *Report) FailingEPSS(threshold float64) int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if v.EPSS != nil && v.EPSS.Score >= threshold {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// Sort orders used by SortFindings.
+const (
+	SortPackage  = "package"
+	SortSeverity = "severity"
+	SortEPSS     = "epss"
+)
+
+// SortOrders lists the orders SortFindings accepts.
+var SortOrders = []string{SortPackage, SortSeverity, SortEPSS}
+
+// SortFindings reorders the findings of each of r's reports, and the
+// vulnerabilities within each finding: by package name, by severity (worst
+// first), or by EPSS score (most likely to be exploited first). Ties keep
+// their order.
+func SortFindings(r *Report, by string) {
+	vulnLess := func(a, b Vulnerability) bool {
+		switch by {
+		case SortSeverity:
+			if ra, rb := severityRank(a.Severity), severityRank(b.Severity); ra != rb {
+				return ra > rb
+			}
+			return a.Score > b.Score
+		case SortEPSS:
+			return epssOf(a) > epssOf(b)
+		}
+		return false
+	}
+	worst := func(f Finding) Vulnerability {
+		w := f.Vulns[0]
+		for _, v := range f.Vulns[1:] {
+			if vulnLess(v, w) {
+				w = v
+			}
+		}
+		return w
+	}
+
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			sort.SliceStable(f.Vulns, func(i, j int) bool { return vulnLess(f.Vulns[i], f.Vulns[j]) })
+		}
+		sort.SliceStable(p.Findings, func(i, j int) bool {
+			a, b := p.Findings[i], p.Findings[j]
+			if by == SortPackage || len(a.Vulns) == 0 || len(b.Vulns) == 0 {
+				return a.Package < b.Package
+			}
+			return vulnLess(worst(a), worst(b))
+		})
+	}
+}
+
+func epssOf(v Vulnerability) float64 {
+	if v.EPSS == nil {
+		return -1 // unscored sorts after a score of 0
+	}
+	return v.EPSS.Score
+}
@@ -0,0 +1,83 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractGemfileLock reads the specs of the GEM section of a Gemfile.lock:
+//
+//	GEM
+//	  remote: https://rubygems.org/
+//	  specs:
+//	    nokogiri (1.10.4-x86_64-linux)
+//	      mini_portile2 (~> 2.4.0)
+//
+// Gems at four spaces are locked specs; deeper lines are their requirements.
+// GIT and PATH sections aren't published to RubyGems and are ignored, and
+// platform suffixes are dropped from versions. Gems listed under DEPENDENCIES
+// are the project's direct dependencies.
+func extractGemfileLock(data []byte) []Package {
+	var (
+		out     []Package
+		seen    = map[string]bool{}
+		section string
+		needs   [][]string // per gem in out: names of the gems it requires
+		kept    bool       // whether the spec above was added to out
+		direct  = map[string]bool{}
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] != ' ' {
+			section = strings.TrimSpace(line)
+			continue
+		}
+		name, rest, hasVersion := strings.Cut(strings.TrimSpace(line), " (")
+		if section == "DEPENDENCIES" {
+			direct[strings.TrimSuffix(name, "!")] = true
+			continue
+		}
+		if section != "GEM" || !strings.HasPrefix(line, "    ") {
+			continue
+		}
+		if strings.HasPrefix(line, "      ") {
+			// A requirement of the spec above; platform variants of a gem
+			// already seen repeat its requirements.
+			if kept {
+				needs[len(needs)-1] = append(needs[len(needs)-1], name)
+			}
+			continue
+		}
+		kept = false
+		if !hasVersion {
+			continue
+		}
+		version, _, _ := strings.Cut(strings.TrimSuffix(rest, ")"), "-")
+		if id := name + "@" + version; !seen[id] {
+			seen[id], kept = true, true
+			out = append(out, Package{Ecosystem: "RubyGems", Name: name, Version: version})
+			needs = append(needs, nil)
+		}
+	}
+
+	// Bundler locks one version per gem, so requirements resolve by name.
+	locked := map[string]string{}
+	for _, d := range out {
+		locked[d.Name] = d.Version
+	}
+	for i := range out {
+		for _, n := range needs[i] {
+			if v, ok := locked[n]; ok {
+				out[i].Requires = append(out[i].Requires, n+"@"+v)
+			}
+		}
+		out[i].Direct = direct[out[i].Name]
+	}
+	return out
+}
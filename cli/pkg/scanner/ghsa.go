@@ -0,0 +1,429 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultGitHubGraphQLURL is the GitHub GraphQL API; GitHub Enterprise
+// Server's is https://<host>/api/graphql.
+const DefaultGitHubGraphQLURL = "https://api.github.com/graphql"
+
+// ghsaEcosystems maps OSV ecosystems to the GitHub Advisory Database's.
+var ghsaEcosystems = map[string]string{
+	"npm":            "NPM",
+	"PyPI":           "PIP",
+	"Go":             "GO",
+	"crates.io":      "RUST",
+	"Maven":          "MAVEN",
+	"NuGet":          "NUGET",
+	"RubyGems":       "RUBYGEMS",
+	"Packagist":      "COMPOSER",
+	"Pub":            "PUB",
+	"Hex":            "ERLANG",
+	"SwiftURL":       "SWIFT",
+	"GitHub Actions": "ACTIONS",
+}
+
+// ghsaConcurrency bounds the GraphQL requests in flight, well below what
+// trips GitHub's secondary rate limits.
+const ghsaConcurrency = 4
+
+// GitHubAdvisories is a Source reading the GitHub Advisory Database through
+// the GitHub GraphQL API, which needs a token. It looks up every advisory of
+// each package and matches the versions itself, so its records come with
+// affected ranges and fixed versions, as OSV's do.
+type GitHubAdvisories struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // DefaultGitHubGraphQLURL if empty
+	Token  string
+	Cache  *Cache // may be nil
+
+	mu        sync.Mutex
+	records   map[string]*OSVVuln // by GHSA ID
+	byPackage map[ghsaPackage][]string
+}
+
+type ghsaPackage struct{ ecosystem, name string }
+
+const ghsaAdvisoryFields = `ghsaId summary publishedAt updatedAt withdrawnAt severity
+identifiers { type value } cvss { vectorString } cvssSeverities { cvssV3 { vectorString } } references { url }`
+
+const ghsaVulnerabilityFields = `package { ecosystem name } vulnerableVersionRange firstPatchedVersion { identifier }`
+
+const ghsaPackageQuery = `query($ecosystem: SecurityAdvisoryEcosystem!, $package: String!, $after: String) {
+  securityVulnerabilities(ecosystem: $ecosystem, package: $package, first: 100, after: $after) {
+    nodes { ` + ghsaVulnerabilityFields + ` advisory { ` + ghsaAdvisoryFields + ` } }
+    pageInfo { hasNextPage endCursor }
+  }
+}`
+
+const ghsaAdvisoryQuery = `query($id: String!) {
+  securityAdvisory(ghsaId: $id) {
+    ` + ghsaAdvisoryFields + `
+    vulnerabilities(first: 100) { nodes { ` + ghsaVulnerabilityFields + ` } }
+  }
+}`
+
+type ghsaVulnerability struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	VulnerableVersionRange string `json:"vulnerableVersionRange"`
+	FirstPatchedVersion    *struct {
+		Identifier string `json:"identifier"`
+	} `json:"firstPatchedVersion"`
+}
+
+type ghsaAdvisory struct {
+	GHSAID      string  `json:"ghsaId"`
+	Summary     string  `json:"summary"`
+	PublishedAt string  `json:"publishedAt"`
+	UpdatedAt   string  `json:"updatedAt"`
+	WithdrawnAt *string `json:"withdrawnAt"`
+	Severity    string  `json:"severity"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+	CVSS struct {
+		VectorString string `json:"vectorString"`
+	} `json:"cvss"`
+	CVSSSeverities struct {
+		CVSSV3 struct {
+			VectorString string `json:"vectorString"`
+		} `json:"cvssV3"`
+	} `json:"cvssSeverities"`
+	References []struct {
+		URL string `json:"url"`
+	} `json:"references"`
+}
+
+func (g *GitHubAdvisories) endpoint() string {
+	return registryURL(g.URL, DefaultGitHubGraphQLURL)
+}
+
+// QueryBatch returns the IDs of the advisories affecting each dep, none for
+// ecosystems the database doesn't cover. Withdrawn advisories are left out.
+func (g *GitHubAdvisories) QueryBatch(ctx context.Context, deps []Package) ([][]string, error) {
+	base := g.endpoint()
+	ids := make([][]string, len(deps))
+	answered := make([]bool, len(deps))
+	var pkgs []ghsaPackage
+	waiting := map[ghsaPackage]int{} // deps left to answer per package
+	for i, d := range deps {
+		if cached, ok := g.Cache.queryIDs(base, d); ok {
+			ids[i], answered[i] = cached, true
+			continue
+		}
+		if _, ok := ghsaEcosystems[d.Ecosystem]; !ok {
+			answered[i] = true
+			continue
+		}
+		p := ghsaPackage{d.Ecosystem, d.Name}
+		if waiting[p] == 0 {
+			pkgs = append(pkgs, p)
+		}
+		waiting[p]++
+	}
+
+	done := newProgressCounter(progressFor(ctx, nil), ProgressPackages, len(deps))
+	done.add(len(deps) - sumValues(waiting))
+	errs := make([]error, len(pkgs))
+	RunPool(ghsaConcurrency, len(pkgs), func(i int) {
+		defer done.add(waiting[pkgs[i]])
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		errs[i] = g.fetchPackage(ctx, pkgs[i])
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	touched := map[string]bool{}
+	for i, d := range deps {
+		if answered[i] {
+			continue
+		}
+		for _, id := range g.byPackage[ghsaPackage{d.Ecosystem, d.Name}] {
+			if g.records[id].affects(d) && !containsString(ids[i], id) {
+				ids[i] = append(ids[i], id)
+				touched[id] = true
+			}
+		}
+		g.Cache.putQueryIDs(base, d, ids[i])
+	}
+	for id := range touched {
+		if data, err := json.Marshal(g.records[id]); err == nil {
+			g.Cache.put(cacheBucketVulns, base+"\x00"+id, data)
+		}
+	}
+	return ids, nil
+}
+
+// fetchPackage records every advisory of p, following the pages.
+func (g *GitHubAdvisories) fetchPackage(ctx context.Context, p ghsaPackage) error {
+	vars := map[string]any{"ecosystem": ghsaEcosystems[p.ecosystem], "package": p.name}
+	for {
+		var page struct {
+			SecurityVulnerabilities struct {
+				Nodes []struct {
+					ghsaVulnerability
+					Advisory ghsaAdvisory `json:"advisory"`
+				} `json:"nodes"`
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+			} `json:"securityVulnerabilities"`
+		}
+		if err := g.graphql(ctx, ghsaPackageQuery, vars, &page); err != nil {
+			return err
+		}
+		g.mu.Lock()
+		if g.records == nil {
+			g.records, g.byPackage = map[string]*OSVVuln{}, map[ghsaPackage][]string{}
+		}
+		for _, n := range page.SecurityVulnerabilities.Nodes {
+			if n.Advisory.WithdrawnAt != nil {
+				continue
+			}
+			id := n.Advisory.GHSAID
+			rec := g.records[id]
+			if rec == nil {
+				rec = n.Advisory.osv()
+				g.records[id] = rec
+			}
+			rec.Affected = append(rec.Affected, n.ghsaVulnerability.affected())
+			if !containsString(g.byPackage[p], id) {
+				g.byPackage[p] = append(g.byPackage[p], id)
+			}
+		}
+		g.mu.Unlock()
+		if !page.SecurityVulnerabilities.PageInfo.HasNextPage {
+			return nil
+		}
+		vars["after"] = page.SecurityVulnerabilities.PageInfo.EndCursor
+	}
+}
+
+// FetchVulns returns the records of ids: those QueryBatch found, those
+// cached, and the rest looked up by ID. IDs other than GHSA ones fail.
+func (g *GitHubAdvisories) FetchVulns(ctx context.Context, ids []string) (map[string]OSVVuln, map[string]error) {
+	base := g.endpoint()
+	vulns := make([]OSVVuln, len(ids))
+	errs := make([]error, len(ids))
+	done := newProgressCounter(progressFor(ctx, nil), ProgressAdvisories, len(ids))
+	RunPool(ghsaConcurrency, len(ids), func(i int) {
+		defer done.add(1)
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		g.mu.Lock()
+		rec := g.records[ids[i]]
+		if rec != nil {
+			vulns[i] = *rec
+		}
+		g.mu.Unlock()
+		if rec != nil {
+			return
+		}
+		if data, ok := g.Cache.get(cacheBucketVulns, base+"\x00"+ids[i]); ok && json.Unmarshal(data, &vulns[i]) == nil {
+			return
+		}
+		if !strings.HasPrefix(ids[i], "GHSA-") {
+			errs[i] = fmt.Errorf("GitHub Advisory Database: %s: %w", ids[i], errNoRecord)
+			return
+		}
+		var doc struct {
+			SecurityAdvisory *struct {
+				ghsaAdvisory
+				Vulnerabilities struct {
+					Nodes []ghsaVulnerability `json:"nodes"`
+				} `json:"vulnerabilities"`
+			} `json:"securityAdvisory"`
+		}
+		if errs[i] = g.graphql(ctx, ghsaAdvisoryQuery, map[string]any{"id": ids[i]}, &doc); errs[i] != nil {
+			return
+		}
+		if doc.SecurityAdvisory == nil {
+			errs[i] = fmt.Errorf("GitHub Advisory Database: %s: %w", ids[i], errNoRecord)
+			return
+		}
+		v := doc.SecurityAdvisory.osv()
+		for _, n := range doc.SecurityAdvisory.Vulnerabilities.Nodes {
+			v.Affected = append(v.Affected, n.affected())
+		}
+		vulns[i] = *v
+		if data, err := json.Marshal(v); err == nil {
+			g.Cache.put(cacheBucketVulns, base+"\x00"+ids[i], data)
+		}
+	})
+
+	found := make(map[string]OSVVuln, len(ids))
+	failed := map[string]error{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			failed[id] = errs[i]
+			continue
+		}
+		found[id] = vulns[i]
+	}
+	return found, failed
+}
+
+// graphql runs query with vars and decodes its data into out.
+func (g *GitHubAdvisories) graphql(ctx context.Context, query string, vars map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return fmt.Errorf("GitHub Advisory Database: API rate limit exceeded")
+		}
+		return fmt.Errorf("GitHub Advisory Database: %w", apiStatus(resp.StatusCode))
+	}
+	var doc struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("GitHub Advisory Database: bad response: %w", err)
+	}
+	if len(doc.Errors) > 0 {
+		return fmt.Errorf("GitHub Advisory Database: %s", doc.Errors[0].Message)
+	}
+	if err := json.Unmarshal(doc.Data, out); err != nil {
+		return fmt.Errorf("GitHub Advisory Database: bad response: %w", err)
+	}
+	return nil
+}
+
+// osv converts a to an OSV record, without its affected packages.
+func (a ghsaAdvisory) osv() *OSVVuln {
+	v := &OSVVuln{ID: a.GHSAID, Summary: a.Summary, Published: a.PublishedAt, Modified: a.UpdatedAt}
+	v.DatabaseSpecific.Severity = a.Severity
+	for _, id := range a.Identifiers {
+		if id.Value != a.GHSAID {
+			v.Aliases = append(v.Aliases, id.Value)
+		}
+	}
+	vector := a.CVSSSeverities.CVSSV3.VectorString
+	if vector == "" {
+		vector = a.CVSS.VectorString
+	}
+	if vector != "" {
+		v.Severity = append(v.Severity, struct {
+			Type  string `json:"type"`
+			Score string `json:"score"`
+		}{"CVSS_V3", vector})
+	}
+	for _, r := range a.References {
+		v.References = append(v.References, struct {
+			Type string `json:"type"`
+			URL  string `json:"url"`
+		}{"WEB", r.URL})
+	}
+	return v
+}
+
+// affected converts a vulnerable version range such as ">= 4.0.0, < 4.17.21"
+// to an OSV affected package with one ECOSYSTEM range, or the one version
+// "= 1.2.3" names.
+func (n ghsaVulnerability) affected() OSVAffected {
+	var a OSVAffected
+	a.Package.Name = n.Package.Name
+	for eco, ghsa := range ghsaEcosystems {
+		if ghsa == n.Package.Ecosystem {
+			a.Package.Ecosystem = eco
+		}
+	}
+	events := []OSVEvent{{Introduced: "0"}}
+	for _, c := range strings.Split(n.VulnerableVersionRange, ",") {
+		op, ver := splitConstraint(strings.TrimSpace(c))
+		ver = strings.TrimPrefix(ver, goPrefix(a.Package.Ecosystem))
+		switch op {
+		case "=":
+			a.Versions = append(a.Versions, ver)
+			return a
+		case ">=", ">":
+			// The database has no exclusive lower bounds in practice; ">"
+			// is taken as ">=", erring towards reporting.
+			events[0].Introduced = ver
+		case "<":
+			events = append(events, OSVEvent{Fixed: ver})
+		case "<=":
+			// The first patched version, where there is one, says where a
+			// fix is to be had, which a last affected one doesn't.
+			if n.FirstPatchedVersion != nil && n.FirstPatchedVersion.Identifier != "" {
+				events = append(events, OSVEvent{Fixed: strings.TrimPrefix(n.FirstPatchedVersion.Identifier, goPrefix(a.Package.Ecosystem))})
+			} else {
+				events = append(events, OSVEvent{LastAffected: ver})
+			}
+		}
+	}
+	a.Ranges = append(a.Ranges, struct {
+		Type   string     `json:"type"`
+		Events []OSVEvent `json:"events"`
+	}{"ECOSYSTEM", events})
+	return a
+}
+
+// splitConstraint splits a constraint such as "<= 1.2.3" into its operator
+// and version.
+func splitConstraint(c string) (op, version string) {
+	for _, op := range []string{">=", "<=", "=", ">", "<"} {
+		if rest, ok := strings.CutPrefix(c, op); ok {
+			return op, strings.TrimSpace(rest)
+		}
+	}
+	return "", c
+}
+
+// goPrefix is the "v" the database puts before Go module versions and
+// keystone doesn't, or "" for other ecosystems.
+func goPrefix(ecosystem string) string {
+	if ecosystem == "Go" {
+		return "v"
+	}
+	return ""
+}
+
+func sumValues(m map[ghsaPackage]int) int {
+	n := 0
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
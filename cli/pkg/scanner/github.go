@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitHubAPIURL is the GitHub REST API; GitHub Enterprise Server's is
+// https://<host>/api/v3.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// GitHub reads an organization's repositories through the GitHub REST API.
+type GitHub struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // DefaultGitHubAPIURL if empty
+	Token  string       // needed for private repositories; raises the rate limit
+}
+
+func (g *GitHub) Name() string { return "GitHub" }
+
+// OrgRepos lists the repositories of org that the token can see.
+func (g *GitHub) OrgRepos(ctx context.Context, org string) ([]HostedRepo, error) {
+	api := g.api()
+	var repos []HostedRepo
+	next := "/orgs/" + url.PathEscape(org) + "/repos?per_page=100&type=all"
+	for next != "" {
+		var page []struct {
+			FullName      string `json:"full_name"`
+			DefaultBranch string `json:"default_branch"`
+			Archived      bool   `json:"archived"`
+			Fork          bool   `json:"fork"`
+		}
+		resp, err := api.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range page {
+			repos = append(repos, HostedRepo{FullName: r.FullName, DefaultBranch: r.DefaultBranch, Archived: r.Archived, Fork: r.Fork})
+		}
+		next = nextLink(resp.Header.Get("Link"))
+	}
+	return repos, nil
+}
+
+// Repo looks up the repository fullName, such as "acme/web".
+func (g *GitHub) Repo(ctx context.Context, fullName string) (HostedRepo, error) {
+	var r struct {
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+		Archived      bool   `json:"archived"`
+		Fork          bool   `json:"fork"`
+	}
+	if _, err := g.api().getJSON(ctx, "/repos/"+fullName, &r); err != nil {
+		return HostedRepo{}, err
+	}
+	return HostedRepo{FullName: r.FullName, DefaultBranch: r.DefaultBranch, Archived: r.Archived, Fork: r.Fork}, nil
+}
+
+// RepoFiles lists the files on repo's default branch from its Git tree,
+// which GitHub truncates for very large repositories.
+func (g *GitHub) RepoFiles(ctx context.Context, repo HostedRepo) ([]string, bool, error) {
+	var tree struct {
+		Tree []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+	}
+	_, err := g.api().getJSON(ctx, "/repos/"+repo.FullName+"/git/trees/"+url.PathEscape(repo.DefaultBranch)+"?recursive=1", &tree)
+	if isStatus(err, http.StatusConflict) {
+		return nil, true, nil // an empty repository
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var files []string
+	for _, e := range tree.Tree {
+		if e.Type == "blob" {
+			files = append(files, e.Path)
+		}
+	}
+	return files, !tree.Truncated, nil
+}
+
+// ReadFile fetches the file at path on repo's default branch.
+func (g *GitHub) ReadFile(ctx context.Context, repo HostedRepo, path string) ([]byte, error) {
+	resp, err := g.api().get(ctx, "/repos/"+repo.FullName+"/contents/"+pathEscape(path)+"?ref="+url.QueryEscape(repo.DefaultBranch), "application/vnd.github.raw+json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (g *GitHub) api() *hostAPI {
+	base := g.URL
+	if base == "" {
+		base = DefaultGitHubAPIURL
+	}
+	auth := tokenAuth(g.Token)
+	return &hostAPI{name: g.Name(), client: g.Client, base: strings.TrimSuffix(base, "/"), auth: func(req *http.Request) {
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		auth(req)
+	}}
+}
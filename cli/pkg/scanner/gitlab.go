@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultGitLabURL is GitLab.com; a self-managed instance is given by its
+// own URL.
+const DefaultGitLabURL = "https://gitlab.com"
+
+// GitLab reads a group's projects through the GitLab REST API.
+type GitLab struct {
+	Client *http.Client // nil means http.DefaultClient
+	URL    string       // DefaultGitLabURL if empty
+	Token  string       // a personal, group or project access token with read_api
+}
+
+func (g *GitLab) Name() string { return "GitLab" }
+
+// OrgRepos lists the projects of the group org, and of its subgroups.
+func (g *GitLab) OrgRepos(ctx context.Context, org string) ([]HostedRepo, error) {
+	api := g.api()
+	var repos []HostedRepo
+	next := "/groups/" + url.PathEscape(org) + "/projects?include_subgroups=true&per_page=100"
+	for next != "" {
+		var page []struct {
+			Path          string `json:"path_with_namespace"`
+			DefaultBranch string `json:"default_branch"`
+			Archived      bool   `json:"archived"`
+			ForkedFrom    *struct {
+				ID int `json:"id"`
+			} `json:"forked_from_project"`
+		}
+		resp, err := api.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page {
+			repos = append(repos, HostedRepo{FullName: p.Path, DefaultBranch: p.DefaultBranch, Archived: p.Archived, Fork: p.ForkedFrom != nil})
+		}
+		next = nextLink(resp.Header.Get("Link"))
+	}
+	return repos, nil
+}
+
+// RepoFiles lists the files on repo's default branch from its repository
+// tree.
+func (g *GitLab) RepoFiles(ctx context.Context, repo HostedRepo) ([]string, bool, error) {
+	if repo.DefaultBranch == "" {
+		return nil, true, nil // an empty repository
+	}
+	api := g.api()
+	var files []string
+	next := "/projects/" + url.PathEscape(repo.FullName) + "/repository/tree?recursive=true&per_page=100&ref=" + url.QueryEscape(repo.DefaultBranch)
+	for next != "" {
+		var page []struct {
+			Path string `json:"path"`
+			Type string `json:"type"`
+		}
+		resp, err := api.getJSON(ctx, next, &page)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range page {
+			if e.Type == "blob" {
+				files = append(files, e.Path)
+			}
+		}
+		next = nextLink(resp.Header.Get("Link"))
+	}
+	return files, true, nil
+}
+
+// ReadFile fetches the file at path on repo's default branch.
+func (g *GitLab) ReadFile(ctx context.Context, repo HostedRepo, path string) ([]byte, error) {
+	resp, err := g.api().get(ctx, "/projects/"+url.PathEscape(repo.FullName)+"/repository/files/"+url.PathEscape(path)+"/raw?ref="+url.QueryEscape(repo.DefaultBranch), "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (g *GitLab) api() *hostAPI {
+	base := g.URL
+	if base == "" {
+		base = DefaultGitLabURL
+	}
+	return &hostAPI{name: g.Name(), client: g.Client, base: strings.TrimSuffix(base, "/") + "/api/v4", auth: func(req *http.Request) {
+		if g.Token != "" {
+			req.Header.Set("PRIVATE-TOKEN", g.Token)
+		}
+	}}
+}
@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractGoSum lists the modules recorded in a go.sum file.
+//
+// Every module appears twice: once with a hash of its full contents and once
+// with a "/go.mod" suffix for just its go.mod. Modules that only have the
+// latter were consulted during version selection but never downloaded, so
+// they are not part of the build and are skipped.
+func extractGoSum(data []byte) []Package {
+	var out []Package
+	seen := map[string]bool{}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		id := fields[0] + "@" + fields[1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, goDep(fields[0], fields[1]))
+	}
+	return out
+}
+
+// extractGoMod lists the modules required by a go.mod file, applying any
+// replace directives. Modules replaced by a local directory are dropped since
+// there is no published version to look up. Requirements without an
+// "// indirect" comment are the module's direct dependencies.
+func extractGoMod(data []byte) []Package {
+	type modVer struct {
+		path, version string
+		indirect      bool
+	}
+
+	var (
+		requires []modVer
+		replaces = map[string]modVer{} // "path" or "path@version" → target
+		block    string                // directive of the enclosing ( ... ) block
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, comment := sc.Text(), ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = line[:i], line[i+2:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directive := block
+		switch {
+		case fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			directive, fields = fields[0], fields[1:]
+		}
+
+		switch directive {
+		case "require":
+			if len(fields) >= 2 {
+				indirect := strings.HasPrefix(strings.TrimSpace(comment), "indirect")
+				requires = append(requires, modVer{fields[0], fields[1], indirect})
+			}
+		case "replace":
+			// "old [version] => new [version]"
+			arrow := -1
+			for i, f := range fields {
+				if f == "=>" {
+					arrow = i
+				}
+			}
+			if arrow < 1 || arrow == len(fields)-1 {
+				continue
+			}
+			from := fields[0]
+			if arrow == 2 {
+				from += "@" + fields[1]
+			}
+			to := modVer{path: fields[arrow+1]}
+			if len(fields) > arrow+2 {
+				to.version = fields[arrow+2]
+			}
+			replaces[from] = to
+		}
+	}
+
+	out := make([]Package, 0, len(requires))
+	for _, r := range requires {
+		direct := !r.indirect
+		if to, ok := replaces[r.path+"@"+r.version]; ok {
+			r = to
+		} else if to, ok := replaces[r.path]; ok {
+			r = to
+		}
+		if r.version == "" {
+			continue // replaced by a local directory
+		}
+		d := goDep(r.path, r.version)
+		d.Direct = direct
+		out = append(out, d)
+	}
+	return out
+}
+
+// goDep builds a Go dependency. OSV expects Go versions without the leading "v".
+func goDep(path, version string) Package {
+	return Package{Ecosystem: "Go", Name: path, Version: strings.TrimPrefix(version, "v")}
+}
@@ -0,0 +1,292 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HistoryEntry records the findings of one scan of one lockfile.
+type HistoryEntry struct {
+	Time     time.Time        `json:"time"`
+	Source   string           `json:"source"`
+	Scanned  int              `json:"packages_scanned"`
+	Findings []HistoryFinding `json:"findings"`
+}
+
+// HistoryFinding is one advisory affecting one package in a HistoryEntry.
+type HistoryFinding struct {
+	Ecosystem string   `json:"ecosystem"`
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Severity  string   `json:"severity,omitempty"`
+}
+
+// key identifies the finding across scans. Like a baseline entry, it leaves
+// out the version: an advisory still affecting the package after an upgrade
+// is the same finding.
+func (f HistoryFinding) key() string {
+	return f.Ecosystem + "/" + f.Package + "/" + f.ID
+}
+
+// HistoryPath returns the file scan history is kept in, under CacheDir. It
+// holds one JSON entry per line, so recording a scan appends to it rather
+// than rewriting it.
+func HistoryPath() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// RecordHistory appends an entry for each of r's lockfiles to the history
+// file at path, stamped with now, under the names HistorySource gives them.
+func RecordHistory(path string, r *Report, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, p := range r.Reports() {
+		e := HistoryEntry{Time: now.UTC(), Source: HistorySource(p.Source), Scanned: p.Scanned, Findings: []HistoryFinding{}}
+		for _, fd := range p.Findings {
+			for _, v := range fd.Vulns {
+				e.Findings = append(e.Findings, HistoryFinding{
+					Ecosystem: fd.Ecosystem, Package: fd.Package, Version: fd.Version, ID: v.ID, Aliases: v.Aliases, Severity: v.Severity,
+				})
+			}
+		}
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// HistorySource returns the name the history keeps a lockfile's scans
+// under: a lockfile on disk by its absolute path, so that scans run from
+// different directories line up, and one named after the repository or
+// image it was found in as it is.
+func HistorySource(source string) string {
+	if _, err := os.Stat(source); err != nil {
+		return source
+	}
+	if abs, err := filepath.Abs(source); err == nil {
+		return abs
+	}
+	return source
+}
+
+// LoadHistory reads the history file at path, oldest entry first. A
+// missing file is an empty history.
+func LoadHistory(path string) ([]HistoryEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []HistoryEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		out = append(out, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// HistorySources returns the sources the history has entries for, sorted.
+func HistorySources(entries []HistoryEntry) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range entries {
+		if !seen[e.Source] {
+			seen[e.Source] = true
+			out = append(out, e.Source)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Trend compares the latest scan of a source with the last one before a
+// point in time.
+type Trend struct {
+	Source string        `json:"source"`
+	From   *HistoryEntry `json:"from,omitempty"` // nil when the source wasn't scanned before then
+	To     HistoryEntry  `json:"to"`
+
+	New   []HistoryFinding `json:"new"`   // in To but not From
+	Fixed []HistoryFinding `json:"fixed"` // in From but not To
+}
+
+// HistoryTrend works out the Trend of source since since. When the source
+// had no scan before since, its first scan is the starting point. It returns
+// false when the history has no entry for source.
+func HistoryTrend(entries []HistoryEntry, source string, since time.Time) (Trend, bool) {
+	var own []HistoryEntry
+	for _, e := range entries {
+		if e.Source == source {
+			own = append(own, e)
+		}
+	}
+	if len(own) == 0 {
+		return Trend{}, false
+	}
+	t := Trend{Source: source, To: own[len(own)-1]}
+	from := own[0]
+	for _, e := range own {
+		if e.Time.After(since) {
+			break
+		}
+		from = e
+	}
+	if len(own) > 1 {
+		t.From = &from
+		t.New = findingsMissing(t.To.Findings, from.Findings)
+		t.Fixed = findingsMissing(from.Findings, t.To.Findings)
+	} else {
+		t.New = findingsMissing(t.To.Findings, nil)
+	}
+	return t, true
+}
+
+// KeepNewFindings removes from r the findings that the latest scan of the
+// same lockfile in the history already had, leaving those that are new
+// since. A lockfile the history has no scan of keeps all of its findings.
+func KeepNewFindings(r *Report, entries []HistoryEntry) {
+	latest := map[string]HistoryEntry{}
+	for _, e := range entries {
+		latest[e.Source] = e
+	}
+	for _, p := range r.Reports() {
+		e, ok := latest[HistorySource(p.Source)]
+		if !ok {
+			continue
+		}
+		known := map[string]bool{}
+		for _, f := range e.Findings {
+			known[f.key()] = true
+		}
+		kept := p.Findings[:0]
+		for _, f := range p.Findings {
+			vulns := f.Vulns[:0]
+			for _, v := range f.Vulns {
+				if !known[HistoryFinding{Ecosystem: f.Ecosystem, Package: f.Package, ID: v.ID}.key()] {
+					vulns = append(vulns, v)
+				}
+			}
+			if len(vulns) > 0 {
+				f.Vulns = vulns
+				kept = append(kept, f)
+			}
+		}
+		p.Findings = kept
+	}
+}
+
+// findingsMissing returns the findings of a whose keys b doesn't have, once
+// per key.
+func findingsMissing(a, b []HistoryFinding) []HistoryFinding {
+	have := map[string]bool{}
+	for _, f := range b {
+		have[f.key()] = true
+	}
+	out := []HistoryFinding{}
+	for _, f := range a {
+		if !have[f.key()] {
+			have[f.key()] = true
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Sighting is when an advisory was found in one package of one source.
+type Sighting struct {
+	Source    string    `json:"source"`
+	Ecosystem string    `json:"ecosystem"`
+	Package   string    `json:"package"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Present is set when the latest scan of the source still has it.
+	Present bool `json:"present"`
+}
+
+// HistorySightings returns when the advisory id, or one with id as an
+// alias such as a CVE, appeared in each package of each source, ordered by
+// first appearance.
+func HistorySightings(entries []HistoryEntry, id string) []Sighting {
+	latest := map[string]time.Time{}
+	for _, e := range entries {
+		latest[e.Source] = e.Time
+	}
+	byKey := map[string]*Sighting{}
+	var order []string
+	for _, e := range entries {
+		for _, f := range e.Findings {
+			if f.ID != id && !containsString(f.Aliases, id) {
+				continue
+			}
+			k := e.Source + "\x00" + f.key()
+			s, ok := byKey[k]
+			if !ok {
+				s = &Sighting{Source: e.Source, Ecosystem: f.Ecosystem, Package: f.Package, FirstSeen: e.Time}
+				byKey[k] = s
+				order = append(order, k)
+			}
+			s.LastSeen = e.Time
+		}
+	}
+	out := make([]Sighting, 0, len(order))
+	for _, k := range order {
+		s := *byKey[k]
+		s.Present = s.LastSeen.Equal(latest[s.Source])
+		out = append(out, s)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].FirstSeen.Before(out[j].FirstSeen) })
+	return out
+}
+
+// ParseSince reads a point in time given as a date (2006-01-02) or as a
+// duration before now in days, hours or minutes (7d, 36h, 90m).
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if m := policyDuration.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.ParseFloat(m[1], 64)
+		unit := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour, "m": time.Minute, "s": time.Second}[m[2]]
+		return now.Add(-time.Duration(n * float64(unit))), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want a date like 2006-01-02 or a duration like 7d)", s)
+}
@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HTTPOptions configures the client used for OSV requests and database
+// downloads.
+type HTTPOptions struct {
+	// Proxy is the URL of the proxy to send all requests through. When empty,
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY from the environment apply.
+	Proxy string
+
+	// CACert is a PEM file of extra certificate authorities to trust on top
+	// of the system pool, e.g. for a proxy that intercepts TLS.
+	CACert string
+}
+
+// NewHTTPClient builds an http.Client from opts.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	// Scans keep many requests to the one API in flight; keep all their
+	// connections open for the next, rather than the default two.
+	transport.MaxIdleConnsPerHost = transport.MaxIdleConns
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if opts.CACert != "" {
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", opts.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
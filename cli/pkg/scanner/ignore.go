@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// IgnoreFileName is the suppression file picked up next to a scanned lockfile.
+const IgnoreFileName = ".keystoneignore"
+
+// IgnoreRule is one line of a .keystoneignore file:
+//
+//	# comment
+//	GHSA-p6mc-m468-83gw                    suppress this advisory everywhere
+//	CVE-2020-8203                           suppress the advisories with this alias
+//	lodash                                  suppress every advisory for lodash
+//	lodash@4.17.15 expires=2025-06-30 reason="upgrade blocked by #123"
+//
+// An expired rule no longer suppresses anything.
+type IgnoreRule struct {
+	Pattern string `json:"pattern"`
+	Expires string `json:"expires,omitempty"` // YYYY-MM-DD, as written
+	Reason  string `json:"reason,omitempty"`
+	Line    int    `json:"-"`
+
+	until time.Time // end of the expiry day; zero if the rule never expires
+}
+
+// Suppression records a finding that an ignore rule hid from the report.
+type Suppression struct {
+	Ecosystem string     `json:"ecosystem"`
+	Package   string     `json:"package"`
+	Version   string     `json:"version"`
+	ID        string     `json:"id"`
+	Severity  string     `json:"severity,omitempty"`
+	Rule      IgnoreRule `json:"rule"`
+}
+
+// vulnIDPattern recognises advisory IDs (GHSA-…, CVE-…, PYSEC-…, RUSTSEC-…)
+// as opposed to package names.
+var vulnIDPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]*-[A-Za-z0-9-]+$`)
+
+func (r IgnoreRule) expired(now time.Time) bool {
+	return !r.until.IsZero() && now.After(r.until)
+}
+
+func (r IgnoreRule) matches(f Finding, v Vulnerability) bool {
+	switch {
+	case vulnIDPattern.MatchString(r.Pattern):
+		return r.Pattern == v.ID || containsString(v.Aliases, r.Pattern)
+	case strings.LastIndex(r.Pattern, "@") > 0:
+		return r.Pattern == f.Package+"@"+f.Version
+	default:
+		return r.Pattern == f.Package
+	}
+}
+
+// LoadIgnoreFile reads the rules in path. A missing file is not an error
+// when optional is set, so projects without one scan as before.
+func LoadIgnoreFile(path string, optional bool) ([]IgnoreRule, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && optional {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseIgnoreRules(path, f)
+}
+
+// ParseIgnoreRules reads rules in .keystoneignore syntax from r. name is
+// only used in error messages.
+func ParseIgnoreRules(name string, r io.Reader) ([]IgnoreRule, error) {
+	var rules []IgnoreRule
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitQuoted(line)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s:%d: no pattern", name, n)
+		}
+		rule := IgnoreRule{Pattern: fields[0], Line: n}
+		for _, field := range fields[1:] {
+			k, v, _ := strings.Cut(field, "=")
+			switch k {
+			case "expires":
+				day, err := time.Parse("2006-01-02", v)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: bad expiry date %q (want YYYY-MM-DD)", name, n, v)
+				}
+				// The rule holds for the whole of its expiry day.
+				rule.Expires, rule.until = v, day.Add(24*time.Hour-time.Nanosecond)
+			case "reason":
+				rule.Reason = v
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown option %q", name, n, field)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, sc.Err()
+}
+
+// splitQuoted splits s on whitespace, keeping double-quoted runs together
+// and dropping the quotes: `a reason="two words"` → [a, reason=two words].
+func splitQuoted(s string) []string {
+	var (
+		out    []string
+		cur    strings.Builder
+		quoted bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+// ApplyIgnores moves findings matched by a live rule from r.Findings into
+// r.Suppressed, dropping findings left with no vulnerabilities. It returns
+// the rules that have expired so the caller can warn about them.
+func ApplyIgnores(r *Report, rules []IgnoreRule, now time.Time) (expired []IgnoreRule) {
+	var live []IgnoreRule
+	for _, rule := range rules {
+		if rule.expired(now) {
+			expired = append(expired, rule)
+		} else {
+			live = append(live, rule)
+		}
+	}
+	if len(live) == 0 {
+		return expired
+	}
+
+	kept := r.Findings[:0]
+	for _, f := range r.Findings {
+		vulns := f.Vulns[:0]
+	vulnLoop:
+		for _, v := range f.Vulns {
+			for _, rule := range live {
+				if rule.matches(f, v) {
+					r.Suppressed = append(r.Suppressed, Suppression{
+						Ecosystem: f.Ecosystem,
+						Package:   f.Package,
+						Version:   f.Version,
+						ID:        v.ID,
+						Severity:  v.Severity,
+						Rule:      rule,
+					})
+					continue vulnLoop
+				}
+			}
+			vulns = append(vulns, v)
+		}
+		if len(vulns) > 0 {
+			f.Vulns = vulns
+			kept = append(kept, f)
+		}
+	}
+	r.Findings = kept
+	return expired
+}
@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	rules, err := ParseIgnoreRules(".keystoneignore", strings.NewReader(`# accepted risks
+GHSA-xxxx-yyyy-zzzz expires=2030-01-31 reason="no fix yet"
+lodash@4.17.15
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].Pattern != "GHSA-xxxx-yyyy-zzzz" || rules[0].Reason != "no fix yet" || rules[0].Expires != "2030-01-31" || rules[1].Pattern != "lodash@4.17.15" || rules[1].Line != 3 {
+		t.Errorf("got %+v", rules)
+	}
+
+	for _, bad := range []string{`""`, `GHSA-1 expires=tomorrow`, `GHSA-1 until=2030-01-31`} {
+		if _, err := ParseIgnoreRules(".keystoneignore", strings.NewReader(bad)); err == nil {
+			t.Errorf("%s: no error", bad)
+		}
+	}
+}
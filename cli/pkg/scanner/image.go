@@ -0,0 +1,376 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Image is the flattened filesystem of a container image, narrowed to the
+// files keystone reads packages from: OS package databases, os-release and
+// language lockfiles.
+type Image struct {
+	Ref   string
+	Files map[string][]byte // slash-separated path without the leading "/"
+}
+
+// LockfileImage is the Lockfile type of an image scan's report.
+const LockfileImage = "image"
+
+// imageOSFiles are the OS files kept from an image's layers.
+var imageOSFiles = map[string]bool{
+	"etc/os-release":          true,
+	"usr/lib/os-release":      true,
+	"etc/alpine-release":      true,
+	apkInstalledPath:          true,
+	dpkgStatusPath:            true,
+	"var/lib/rpm/Packages":    true,
+	"var/lib/rpm/Packages.db": true,
+	rpmSQLiteLegacyPath:       true,
+	rpmSQLitePath:             true,
+}
+
+// imageSkippedDirs hold copies of third-party packages rather than projects,
+// on top of the directories DiscoverLockfiles skips.
+var imageSkippedDirs = map[string]bool{
+	"proc":          true,
+	"sys":           true,
+	"site-packages": true,
+	"dist-packages": true,
+}
+
+// wantedImageFile reports whether an image file is worth keeping.
+func wantedImageFile(name string) bool {
+	if imageOSFiles[name] || strings.HasPrefix(name, dpkgStatusDir) {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if skippedDirs[dir] || imageSkippedDirs[dir] {
+			return false
+		}
+	}
+	_, ok := LockfileByName(name)
+	return ok
+}
+
+// layerOpener returns the uncompressed-or-gzipped tar stream of one layer.
+type layerOpener func() (io.ReadCloser, error)
+
+// flattenLayers applies layers in order, honouring whiteouts, and returns the
+// wanted files of the result.
+func flattenLayers(layers []layerOpener) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for i, open := range layers {
+		rc, err := open()
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		err = applyLayer(files, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("layer %d: %w", i+1, err)
+		}
+	}
+	return files, nil
+}
+
+func applyLayer(files map[string][]byte, r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else if magic, _ := br.Peek(4); string(magic) == "\x28\xb5\x2f\xfd" {
+		return errors.New("zstd-compressed layers aren't supported")
+	} else {
+		r = br
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		dir, base := path.Split(name)
+
+		// Whiteouts delete what earlier layers put there.
+		if base == ".wh..wh..opq" {
+			deletePrefix(files, dir)
+			continue
+		}
+		if strings.HasPrefix(base, ".wh.") {
+			target := dir + strings.TrimPrefix(base, ".wh.")
+			delete(files, target)
+			deletePrefix(files, target+"/")
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !wantedImageFile(name) {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+	}
+}
+
+func deletePrefix(files map[string][]byte, prefix string) {
+	for name := range files {
+		if strings.HasPrefix(name, prefix) {
+			delete(files, name)
+		}
+	}
+}
+
+// ReadImageArchive reads an image saved as a tar archive, either by
+// 'docker save' (docker-archive) or as an OCI image layout.
+func ReadImageArchive(archive string) (*Image, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// entry finds one file in the archive. Archives can be large, so entries
+	// are streamed rather than loaded up front.
+	entry := func(name string) (io.Reader, error) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(f)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: no %s in archive", archive, name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if path.Clean(hdr.Name) == name {
+				return tr, nil
+			}
+		}
+	}
+	readJSON := func(name string, v any) error {
+		r, err := entry(name)
+		if err != nil {
+			return err
+		}
+		return json.NewDecoder(r).Decode(v)
+	}
+
+	var layers []string
+	var manifest []struct {
+		Layers []string `json:"Layers"`
+	}
+	if err := readJSON("manifest.json", &manifest); err == nil && len(manifest) > 0 {
+		layers = manifest[0].Layers
+	} else {
+		var index ociDocument
+		if err := readJSON("index.json", &index); err != nil {
+			return nil, fmt.Errorf("%s is neither a docker-archive nor an OCI layout: %w", archive, err)
+		}
+		if len(index.Manifests) == 0 {
+			return nil, fmt.Errorf("%s: image index lists no manifests", archive)
+		}
+		m, err := resolveOCIManifest(index, func(digest string, v any) error {
+			return readJSON(ociBlobPath(digest), v)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", archive, err)
+		}
+		for _, l := range m.Layers {
+			layers = append(layers, ociBlobPath(l.Digest))
+		}
+	}
+
+	var openers []layerOpener
+	for _, name := range layers {
+		name := path.Clean(name)
+		openers = append(openers, func() (io.ReadCloser, error) {
+			r, err := entry(name)
+			return io.NopCloser(r), err
+		})
+	}
+	files, err := flattenLayers(openers)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{Ref: archive, Files: files}, nil
+}
+
+// ReadRootFS reads the OS package database of an unpacked root filesystem,
+// such as a virtual machine's mounted disk or a base image extracted to a
+// directory. Symbolic links are resolved within dir, as they would be with
+// it as the root. Lockfiles aren't looked for; 'keystone scan' finds those.
+func ReadRootFS(dir string) (*Image, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	files := map[string][]byte{}
+	read := func(name string) error {
+		p, err := resolveInRoot(dir, name)
+		if err != nil {
+			return nil // missing, or a dangling or looping link
+		}
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[name] = data
+		return nil
+	}
+	for _, name := range sortedKeys(imageOSFiles) {
+		if err := read(name); err != nil {
+			return nil, err
+		}
+	}
+	if p, err := resolveInRoot(dir, strings.TrimSuffix(dpkgStatusDir, "/")); err == nil {
+		entries, _ := os.ReadDir(p)
+		for _, e := range entries {
+			if err := read(dpkgStatusDir + e.Name()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &Image{Ref: dir, Files: files}, nil
+}
+
+// resolveInRoot returns the host path of name, a slash-separated path in the
+// filesystem rooted at root, following symbolic links one component at a
+// time so that absolute and "../" targets stay within root.
+func resolveInRoot(root, name string) (string, error) {
+	rest := strings.Split(name, "/")
+	var resolved []string
+	for links := 0; len(rest) > 0; {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+		p := filepath.Join(root, filepath.FromSlash(path.Join(append(resolved, part)...)))
+		fi, err := os.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+		if links++; links > 40 {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", name)
+		}
+		target, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(target, "/") {
+			resolved = nil
+		}
+		rest = append(strings.Split(filepath.ToSlash(target), "/"), rest...)
+	}
+	return filepath.Join(root, filepath.FromSlash(path.Join(resolved...))), nil
+}
+
+// Lockfiles returns the paths of the image's language lockfiles, in lexical
+// order.
+func (img *Image) Lockfiles() []string {
+	var found []string
+	for name := range img.Files {
+		if imageOSFiles[name] || strings.HasPrefix(name, dpkgStatusDir) {
+			continue
+		}
+		found = append(found, name)
+	}
+	sort.Strings(found)
+	return dropShadowedLockfiles(found)
+}
+
+/********** OCI manifests **********/
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ociDocument is an OCI image index or manifest, or their Docker
+// equivalents (manifest list, v2 manifest): indexes list Manifests, image
+// manifests list Layers.
+type ociDocument struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+func ociBlobPath(digest string) string {
+	algo, hex, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algo, hex)
+}
+
+// resolveOCIManifest walks down from doc, if it's an index, to the image
+// manifest for linux/amd64 (or the first platform listed), fetching documents
+// by digest.
+func resolveOCIManifest(doc ociDocument, fetch func(digest string, v any) error) (*ociDocument, error) {
+	for depth := 0; len(doc.Manifests) > 0; depth++ {
+		if depth == 4 {
+			return nil, errors.New("image indexes nested too deeply")
+		}
+		desc, err := pickPlatform(doc.Manifests)
+		if err != nil {
+			return nil, err
+		}
+		doc = ociDocument{}
+		if err := fetch(desc.Digest, &doc); err != nil {
+			return nil, err
+		}
+	}
+	return &doc, nil
+}
+
+func pickPlatform(manifests []ociDescriptor) (ociDescriptor, error) {
+	if len(manifests) == 0 {
+		return ociDescriptor{}, errors.New("image index lists no manifests")
+	}
+	for _, m := range manifests {
+		if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+			return m, nil
+		}
+	}
+	return manifests[0], nil
+}
@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// DefaultKEVURL is CISA's Known Exploited Vulnerabilities catalog feed.
+const DefaultKEVURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+// KEVEntry is a CVE listed in CISA's Known Exploited Vulnerabilities
+// catalog: there is evidence of it being exploited in the wild.
+type KEVEntry struct {
+	CVE            string `json:"cve"`
+	DateAdded      string `json:"date_added"`
+	DueDate        string `json:"due_date,omitempty"` // remediation deadline for US federal agencies
+	RequiredAction string `json:"required_action,omitempty"`
+	Ransomware     bool   `json:"known_ransomware_use,omitempty"`
+}
+
+// KEVCatalog maps CVE IDs to their KEV entries.
+type KEVCatalog map[string]KEVEntry
+
+// ParseKEV reads the catalog in CISA's JSON feed format.
+func ParseKEV(r io.Reader) (KEVCatalog, error) {
+	var feed struct {
+		Vulnerabilities []struct {
+			CVE            string `json:"cveID"`
+			DateAdded      string `json:"dateAdded"`
+			DueDate        string `json:"dueDate"`
+			RequiredAction string `json:"requiredAction"`
+			Ransomware     string `json:"knownRansomwareCampaignUse"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("bad KEV catalog: %w", err)
+	}
+	out := KEVCatalog{}
+	for _, v := range feed.Vulnerabilities {
+		out[v.CVE] = KEVEntry{
+			CVE: v.CVE, DateAdded: v.DateAdded, DueDate: v.DueDate,
+			RequiredAction: v.RequiredAction, Ransomware: v.Ransomware == "Known",
+		}
+	}
+	return out, nil
+}
+
+// FetchKEV downloads the catalog from feedURL (DefaultKEVURL if empty). A
+// nil client means http.DefaultClient.
+func FetchKEV(client *http.Client, feedURL string) (KEVCatalog, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if feedURL == "" {
+		feedURL = DefaultKEVURL
+	}
+	resp, err := client.Get(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("KEV catalog request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("KEV catalog download returned %s", resp.Status)
+	}
+	return ParseKEV(resp.Body)
+}
+
+// LoadKEVFile reads a downloaded copy of the catalog.
+func LoadKEVFile(path string) (KEVCatalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	kev, err := ParseKEV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return kev, nil
+}
+
+// ApplyKEV marks every vulnerability in r whose CVE is in the catalog.
+func ApplyKEV(r *Report, kev KEVCatalog) {
+	for _, p := range r.Reports() {
+		for i := range p.Findings {
+			for j := range p.Findings[i].Vulns {
+				v := &p.Findings[i].Vulns[j]
+				for _, id := range v.CVEs() {
+					if e, ok := kev[id]; ok {
+						v.KEV = &e
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// KnownExploited counts the vulnerabilities in r listed in the KEV catalog.
+func (r *Report) KnownExploited() int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if v.KEV != nil {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,165 @@
+package scanner
+
+import (
+	"sort"
+	"strings"
+)
+
+// LicensePolicy decides which dependency licenses are acceptable. Entries
+// are SPDX license identifiers, matched case-insensitively; a trailing "*"
+// matches any identifier with that prefix, so "GPL-*" covers every GPL
+// version. "-only" suffixes are ignored, making "GPL-3.0" and "GPL-3.0-only"
+// the same license.
+//
+// A license is permitted when it isn't denied and, if Allow is non-empty, is
+// allowed. An SPDX expression is permitted when the licenses it requires are:
+// one side of an OR, or both sides of an AND.
+type LicensePolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// Empty reports whether p has no rules and so permits everything.
+func (p LicensePolicy) Empty() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// Permits reports whether the license expression is acceptable.
+func (p LicensePolicy) Permits(expr string) bool {
+	toks := licenseTokens(expr)
+	if len(toks) == 0 {
+		return true
+	}
+	ps := &licenseParser{toks: toks, permits: p.permitsID}
+	ok := ps.or()
+	if ps.pos != len(toks) {
+		// Not a valid expression, so take it as a single free-text license.
+		return p.permitsID(expr)
+	}
+	return ok
+}
+
+func (p LicensePolicy) permitsID(id string) bool {
+	if matchLicense(p.Deny, id) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchLicense(p.Allow, id)
+}
+
+func matchLicense(patterns []string, id string) bool {
+	id = normalizeLicense(id)
+	for _, pat := range patterns {
+		pat = normalizeLicense(pat)
+		if prefix, ok := strings.CutSuffix(pat, "*"); ok {
+			if strings.HasPrefix(id, prefix) {
+				return true
+			}
+		} else if id == pat {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeLicense(id string) string {
+	return strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(id)), "-ONLY")
+}
+
+func licenseTokens(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+// licenseParser evaluates an SPDX license expression, where AND binds
+// tighter than OR, against a policy. "WITH exception" clauses are ignored.
+type licenseParser struct {
+	toks    []string
+	pos     int
+	permits func(id string) bool
+}
+
+func (ps *licenseParser) peek(op string) bool {
+	return ps.pos < len(ps.toks) && strings.EqualFold(ps.toks[ps.pos], op)
+}
+
+func (ps *licenseParser) or() bool {
+	ok := ps.and()
+	for ps.peek("OR") {
+		ps.pos++
+		ok = ps.and() || ok // evaluate both sides to consume them
+	}
+	return ok
+}
+
+func (ps *licenseParser) and() bool {
+	ok := ps.atom()
+	for ps.peek("AND") {
+		ps.pos++
+		ok = ps.atom() && ok
+	}
+	return ok
+}
+
+func (ps *licenseParser) atom() bool {
+	if ps.pos >= len(ps.toks) {
+		return false
+	}
+	if ps.peek("(") {
+		ps.pos++
+		ok := ps.or()
+		if ps.peek(")") {
+			ps.pos++
+		} else {
+			ps.pos = len(ps.toks) + 1 // unbalanced; makes Permits fall back
+		}
+		return ok
+	}
+	id := ps.toks[ps.pos]
+	ps.pos++
+	if ps.peek("WITH") && ps.pos+1 < len(ps.toks) {
+		ps.pos += 2
+	}
+	return ps.permits(id)
+}
+
+// LicenseViolation is a scanned package whose license the policy doesn't
+// permit.
+type LicenseViolation struct {
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	License   string `json:"license"`
+	Dev       bool   `json:"dev,omitempty"`
+}
+
+// CheckLicenses records on each of r's per-lockfile reports the packages
+// whose licenses p doesn't permit. Packages whose lockfile records no
+// license aren't checked.
+func CheckLicenses(r *Report, p LicensePolicy) {
+	for _, rep := range r.Reports() {
+		rep.LicenseViolations = nil
+		seen := map[string]bool{}
+		for _, d := range rep.Packages {
+			key := d.Ecosystem + "\x00" + d.Name + "@" + d.Version
+			if d.License == "" || seen[key] || p.Permits(d.License) {
+				continue
+			}
+			seen[key] = true
+			rep.LicenseViolations = append(rep.LicenseViolations, LicenseViolation{
+				Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version, License: d.License, Dev: d.Dev,
+			})
+		}
+		sort.Slice(rep.LicenseViolations, func(i, j int) bool {
+			return rep.LicenseViolations[i].Package < rep.LicenseViolations[j].Package
+		})
+	}
+}
+
+// LicenseViolationCount counts the license violations across every report.
+func (r *Report) LicenseViolationCount() int {
+	n := 0
+	for _, rep := range r.Reports() {
+		n += len(rep.LicenseViolations)
+	}
+	return n
+}
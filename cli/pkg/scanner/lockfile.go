@@ -0,0 +1,356 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LockfileKind identifies which parser understands a given lockfile.
+type LockfileKind string
+
+const (
+	LockfileNpm  LockfileKind = "npm"
+	LockfileYarn LockfileKind = "yarn"
+	LockfilePnpm LockfileKind = "pnpm"
+	LockfileBun  LockfileKind = "bun"
+	LockfileDeno LockfileKind = "deno"
+	LockfileGo   LockfileKind = "go"
+
+	LockfileRequirements LockfileKind = "requirements"
+	LockfilePoetry       LockfileKind = "poetry"
+	LockfilePipenv       LockfileKind = "pipenv"
+
+	LockfileCargo LockfileKind = "cargo"
+
+	LockfileMaven  LockfileKind = "maven"
+	LockfileGradle LockfileKind = "gradle"
+
+	LockfileBundler LockfileKind = "bundler"
+
+	LockfileComposer LockfileKind = "composer"
+
+	LockfileNuGet LockfileKind = "nuget"
+
+	LockfilePub LockfileKind = "pub"
+
+	LockfileSwift     LockfileKind = "swift"
+	LockfileCocoaPods LockfileKind = "cocoapods"
+
+	LockfileGitHubActions LockfileKind = "github-actions"
+
+	LockfileTerraform LockfileKind = "terraform"
+)
+
+// DetectLockfile picks a parser from the file name first, and falls back to
+// sniffing the contents when the name is not one we recognise (e.g. a lockfile
+// that was renamed or piped through a temp file).
+func DetectLockfile(path string, data []byte) (LockfileKind, error) {
+	if kind, ok := LockfileByName(path); ok {
+		return kind, nil
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte(bunBinaryHeader)),
+		bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"workspaces": {`)):
+		return LockfileBun, nil
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"contentHash"`)),
+		bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"projectFileDependencyGroups"`)),
+		bytes.Contains(data, []byte("<PackageReference ")):
+		return LockfileNuGet, nil
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"pins"`)):
+		return LockfileSwift, nil
+	case bytes.HasPrefix(trimmed, []byte("PODS:")):
+		return LockfileCocoaPods, nil
+	case bytes.Contains(data, []byte(`provider "registry.`)):
+		return LockfileTerraform, nil
+	case bytes.Contains(data, []byte("\njobs:")) && bytes.Contains(data, []byte("uses:")):
+		return LockfileGitHubActions, nil
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"specifiers"`)):
+		return LockfileDeno, nil
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"_meta"`)):
+		return LockfilePipenv, nil
+	case bytes.HasPrefix(trimmed, []byte("{")) && bytes.Contains(data, []byte(`"packages-dev"`)):
+		return LockfileComposer, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return LockfileNpm, nil
+	case bytes.Contains(data, []byte("# yarn lockfile v1")),
+		bytes.Contains(data, []byte("__metadata:")):
+		return LockfileYarn, nil
+	case bytes.HasPrefix(trimmed, []byte("lockfileVersion:")):
+		return LockfilePnpm, nil
+	case bytes.HasPrefix(trimmed, []byte("module ")),
+		bytes.Contains(data, []byte(" h1:")):
+		return LockfileGo, nil
+	case bytes.HasPrefix(trimmed, []byte("<")) && bytes.Contains(data, []byte("<project")):
+		return LockfileMaven, nil
+	case bytes.Contains(data, []byte("Gradle generated file")):
+		return LockfileGradle, nil
+	case bytes.HasPrefix(trimmed, []byte("GEM\n")), bytes.Contains(data, []byte("\nBUNDLED WITH")):
+		return LockfileBundler, nil
+	case bytes.Contains(data, []byte("@generated by Cargo")),
+		bytes.Contains(data, []byte(`source = "registry+`)):
+		return LockfileCargo, nil
+	case bytes.Contains(data, []byte("\n    source: hosted\n")):
+		return LockfilePub, nil
+	case bytes.Contains(data, []byte("[[package]]")):
+		return LockfilePoetry, nil
+	}
+	return "", fmt.Errorf("unrecognised lockfile format: %s", path)
+}
+
+// LockfileByName recognises a lockfile from its well-known file name alone,
+// or the name of one added with RegisterLockfile.
+func LockfileByName(path string) (LockfileKind, bool) {
+	if kind, ok := customLockfileByName(path); ok {
+		return kind, true
+	}
+	base := filepath.Base(path)
+	switch base {
+	case "package-lock.json", "npm-shrinkwrap.json":
+		return LockfileNpm, true
+	case "yarn.lock":
+		return LockfileYarn, true
+	case "pnpm-lock.yaml":
+		return LockfilePnpm, true
+	case "bun.lock", "bun.lockb":
+		return LockfileBun, true
+	case "deno.lock", "deno.json", "deno.jsonc", "import_map.json":
+		return LockfileDeno, true
+	case "go.sum", "go.mod":
+		return LockfileGo, true
+	case "poetry.lock":
+		return LockfilePoetry, true
+	case "Pipfile.lock":
+		return LockfilePipenv, true
+	case "Cargo.lock":
+		return LockfileCargo, true
+	case "pom.xml":
+		return LockfileMaven, true
+	case "gradle.lockfile":
+		return LockfileGradle, true
+	case "Gemfile.lock", "gems.locked":
+		return LockfileBundler, true
+	case "composer.lock":
+		return LockfileComposer, true
+	case "packages.lock.json", "project.assets.json":
+		return LockfileNuGet, true
+	case "pubspec.lock":
+		return LockfilePub, true
+	case "Package.resolved":
+		return LockfileSwift, true
+	case "Podfile.lock":
+		return LockfileCocoaPods, true
+	case ".terraform.lock.hcl":
+		return LockfileTerraform, true
+	}
+	switch filepath.Ext(base) {
+	case ".csproj", ".fsproj", ".vbproj":
+		return LockfileNuGet, true
+	}
+	if isActionsWorkflow(path) {
+		return LockfileGitHubActions, true
+	}
+	// requirements.txt, requirements-dev.txt, requirements/prod.txt, ...
+	if strings.HasSuffix(base, ".txt") &&
+		(strings.HasPrefix(base, "requirements") || filepath.Base(filepath.Dir(path)) == "requirements") {
+		return LockfileRequirements, true
+	}
+	return "", false
+}
+
+// LockfileParser extracts the packages from one kind of lockfile.
+type LockfileParser interface {
+	Parse(path string, data []byte) ([]Package, error)
+}
+
+// ParserFunc adapts an ordinary function to the LockfileParser interface.
+type ParserFunc func(path string, data []byte) ([]Package, error)
+
+// Parse calls f(path, data).
+func (f ParserFunc) Parse(path string, data []byte) ([]Package, error) {
+	return f(path, data)
+}
+
+// parsers holds the parser for each lockfile kind.
+var parsers = map[LockfileKind]LockfileParser{
+	LockfileNpm: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		var lock map[string]any
+		if err := json.Unmarshal(data, &lock); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		// Extract deps from "packages" block (npm lockfile v2/v3), or the
+		// "dependencies" tree of v1.
+		return extractNpmPackages(lock), nil
+	}),
+	LockfileYarn: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractYarnPackages(data), nil
+	}),
+	LockfilePnpm: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPnpmPackages(data), nil
+	}),
+	LockfileBun: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		if bytes.HasPrefix(data, []byte(bunBinaryHeader)) {
+			return extractBunLockb(data)
+		}
+		return extractBunLock(data)
+	}),
+	LockfileDeno: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractDeno(data)
+	}),
+	LockfileGo: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		// go.sum lines always carry an "h1:" hash; go.mod never does.
+		if bytes.Contains(data, []byte(" h1:")) {
+			return extractGoSum(data), nil
+		}
+		return extractGoMod(data), nil
+	}),
+	LockfileRequirements: ParserFunc(func(path string, data []byte) ([]Package, error) {
+		return extractRequirements(path, data, map[string]bool{})
+	}),
+	LockfilePoetry: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPoetryPackages(data), nil
+	}),
+	LockfilePipenv: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPipfileLock(data)
+	}),
+	LockfileCargo: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractCargoPackages(data), nil
+	}),
+	LockfileMaven: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPomDependencies(data)
+	}),
+	LockfileGradle: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractGradleLockfile(data), nil
+	}),
+	LockfileBundler: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractGemfileLock(data), nil
+	}),
+	LockfileComposer: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractComposerLock(data)
+	}),
+	LockfilePub: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPubspecLock(data), nil
+	}),
+	LockfileSwift: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPackageResolved(data)
+	}),
+	LockfileCocoaPods: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractPodfileLock(data), nil
+	}),
+	LockfileGitHubActions: ParserFunc(func(_ string, data []byte) ([]Package, error) {
+		return extractActionsWorkflow(data), nil
+	}),
+	LockfileTerraform: ParserFunc(func(path string, data []byte) ([]Package, error) {
+		return extractTerraformLock(path, data)
+	}),
+	LockfileNuGet: ParserFunc(func(path string, data []byte) ([]Package, error) {
+		trimmed := bytes.TrimSpace(data)
+		switch {
+		case !bytes.HasPrefix(trimmed, []byte("{")):
+			return extractMSBuildProject(path, data)
+		case bytes.Contains(data, []byte(`"projectFileDependencyGroups"`)):
+			return extractNuGetAssets(data)
+		}
+		return extractNuGetLock(data)
+	}),
+}
+
+// RegisterParser makes p the parser for lockfiles of the given kind,
+// replacing the built-in one. It must not be called concurrently with
+// parsing.
+func RegisterParser(kind LockfileKind, p LockfileParser) {
+	parsers[kind] = p
+}
+
+// ParseLockfile detects the lockfile type and extracts its dependencies.
+func ParseLockfile(path string, data []byte) (LockfileKind, []Package, error) {
+	kind, err := DetectLockfile(path, data)
+	if err != nil {
+		return "", nil, err
+	}
+	p, ok := parsers[kind]
+	if !ok {
+		return kind, nil, fmt.Errorf("no parser for lockfile type %q", kind)
+	}
+	deps, err := p.Parse(path, data)
+	return kind, deps, err
+}
+
+// unquote strips one layer of surrounding single or double quotes, if present.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// tomlArrayTables collects the string fields of every [[name]] table in a
+// TOML document. It understands just enough TOML for lockfiles, which are
+// machine-written with one key = "value" pair per line; sub-tables such as
+// [name.dependencies] end the current entry's fields. Arrays of strings,
+// inline or spread over several lines, are returned newline-separated.
+func tomlArrayTables(data []byte, name string) []map[string]string {
+	var (
+		out     []map[string]string
+		current map[string]string
+		array   string   // key of the multi-line array being read
+		items   []string // its strings so far
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if array != "" {
+			if line == "]" {
+				current[array] = strings.Join(items, "\n")
+				array, items = "", nil
+				continue
+			}
+			if item := unquote(strings.TrimSuffix(line, ",")); item != "" {
+				items = append(items, item)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			current = nil
+			if line == "[["+name+"]]" {
+				current = map[string]string{}
+				out = append(out, current)
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case strings.HasPrefix(v, `"`) || strings.HasPrefix(v, "'"):
+			current[k] = unquote(v)
+		case v == "[":
+			array = k
+		case strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]"):
+			var inline []string
+			for _, item := range strings.Split(strings.Trim(v, "[]"), ",") {
+				if item = unquote(item); item != "" {
+					inline = append(inline, item)
+				}
+			}
+			current[k] = strings.Join(inline, "\n")
+		}
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package scanner
+
+import "testing"
+
+// Malformed lockfiles, which fuzzing turned up, must not panic.
+func TestParseLockfileMalformed(t *testing.T) {
+	tests := []struct{ path, data string }{
+		{"yarn.lock", "  dependencies:\n    lodash \"^4.17.0\"\n"},
+		{"Cargo.lock", `[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "",
+ "serde",
+]
+
+[[package]]
+name = "serde"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("%s: panic: %v", tt.path, r)
+				}
+			}()
+			ParseLockfile(tt.path, []byte(tt.data))
+		}()
+	}
+}
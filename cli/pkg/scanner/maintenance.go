@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStaleAfter is how long a package can go without a release before
+// it's reported as a maintenance risk.
+const DefaultStaleAfter = 2 * 365 * 24 * time.Hour
+
+// Maintenance is what's known of the upkeep of a package.
+type Maintenance struct {
+	LastPublished time.Time // zero if the registry dates no release
+	Repository    string    // "owner/repo" on GitHub, if the registry links one
+	Archived      bool      // whether the repository is archived
+}
+
+// FetchMaintenance looks up, for each package in deps of an ecosystem in
+// OutdatedEcosystems, when it was last published and, through gh, whether
+// its GitHub repository is archived, keyed "ecosystem/name". Packages the
+// registry doesn't have are left out; the error is the first failure, the
+// other packages being looked up regardless.
+func FetchMaintenance(ctx context.Context, client *http.Client, regs Registries, gh *GitHub, deps []Package) (map[string]*Maintenance, error) {
+	releases, firstErr := FetchLatestReleases(client, regs, deps)
+	out := map[string]*Maintenance{}
+	repos := map[string][]*Maintenance{}
+	for key, rel := range releases {
+		m := &Maintenance{LastPublished: rel.LastPublished(), Repository: githubRepoName(rel.Repository)}
+		out[key] = m
+		if m.Repository != "" {
+			repos[strings.ToLower(m.Repository)] = append(repos[strings.ToLower(m.Repository)], m)
+		}
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, npmResolveConcurrency)
+	)
+	for _, name := range sortedKeys(repos) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			repo, err := gh.Repo(ctx, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// A moved or deleted repository says nothing either way.
+				if !isStatus(err, http.StatusNotFound) && firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, m := range repos[name] {
+				m.Archived = repo.Archived
+			}
+		}(name)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// MaintenanceRisk is a package that looks unmaintained: its repository is
+// archived, or it hasn't been released for longer than a threshold. It gets
+// no security fixes either way.
+type MaintenanceRisk struct {
+	Ecosystem     string     `json:"ecosystem"`
+	Package       string     `json:"package"`
+	Version       string     `json:"version"`
+	Dev           bool       `json:"dev,omitempty"`
+	Direct        bool       `json:"direct,omitempty"`
+	Archived      bool       `json:"archived,omitempty"`
+	Stale         bool       `json:"stale,omitempty"`
+	LastPublished *time.Time `json:"last_published,omitempty"`
+	Repository    string     `json:"repository,omitempty"`
+	Detail        string     `json:"detail"`
+}
+
+// CheckMaintenance records on each of r's reports the packages that, by
+// info from FetchMaintenance, have an archived repository or have had no
+// release for longer than staleAfter before now.
+func CheckMaintenance(r *Report, info map[string]*Maintenance, staleAfter time.Duration, now time.Time) {
+	for _, rep := range r.Reports() {
+		rep.Maintenance = nil
+		seen := map[string]bool{}
+		for _, d := range rep.Packages {
+			key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+			m := info[d.Ecosystem+"/"+d.Name]
+			if m == nil || seen[key] {
+				continue
+			}
+			seen[key] = true
+			stale := !m.LastPublished.IsZero() && now.Sub(m.LastPublished) > staleAfter
+			if !stale && !m.Archived {
+				continue
+			}
+			risk := MaintenanceRisk{
+				Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version, Dev: d.Dev, Direct: d.Direct,
+				Archived: m.Archived, Stale: stale, Repository: m.Repository,
+			}
+			var why []string
+			if m.Archived {
+				why = append(why, "its repository github.com/"+m.Repository+" is archived")
+			}
+			if !m.LastPublished.IsZero() {
+				last := m.LastPublished
+				risk.LastPublished = &last
+				if stale {
+					why = append(why, fmt.Sprintf("nothing has been released since %s, %.1f years ago", last.Format(time.DateOnly), now.Sub(last).Hours()/(365*24)))
+				}
+			}
+			risk.Detail = strings.Join(why, "; ")
+			rep.Maintenance = append(rep.Maintenance, risk)
+		}
+	}
+}
+
+// MaintenanceCount counts the maintenance risks across r's reports.
+func (r *Report) MaintenanceCount() int {
+	n := 0
+	for _, p := range r.Reports() {
+		n += len(p.Maintenance)
+	}
+	return n
+}
+
+// githubRepoName returns the "owner/repo" of a GitHub repository URL in any
+// of the forms registries record, such as "git+https://github.com/o/r.git",
+// "git@github.com:o/r" or "github:o/r", or "" for anything else.
+func githubRepoName(u string) string {
+	u = strings.TrimPrefix(strings.TrimSpace(u), "git+")
+	if rest, ok := strings.CutPrefix(u, "github:"); ok {
+		u = "github.com/" + rest
+	} else if rest, ok := strings.CutPrefix(u, "git@github.com:"); ok {
+		u = "github.com/" + rest
+	} else if _, rest, ok := strings.Cut(u, "://"); ok {
+		// Drop any user info before the host.
+		if at := strings.Index(rest, "@"); at >= 0 && at < strings.Index(rest+"/", "/") {
+			rest = rest[at+1:]
+		}
+		u = rest
+	}
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(u, "www."), "github.com/")
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(rest, "#?"); i >= 0 {
+		rest = rest[:i]
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 2 || parts[0] == "" || strings.TrimSuffix(parts[1], ".git") == "" {
+		return ""
+	}
+	return parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+}
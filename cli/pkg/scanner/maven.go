@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type pomProject struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Parent     struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+	} `xml:"parent"`
+	Properties struct {
+		Entries []pomProperty `xml:",any"`
+	} `xml:"properties"`
+	DependencyManagement struct {
+		Dependencies []pomDependency `xml:"dependencies>dependency"`
+	} `xml:"dependencyManagement"`
+	Dependencies []pomDependency `xml:"dependencies>dependency"`
+}
+
+type pomProperty struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Scope      string `xml:"scope"`
+}
+
+var pomPropertyRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// extractPomDependencies reads the declared dependencies of a pom.xml, all
+// of them direct.
+//
+// Versions may come from the dependency itself or from the project's
+// <dependencyManagement> section, and may reference ${properties}. Only the
+// single pom is considered: parent poms and imported BOMs aren't fetched, so
+// dependencies whose version can't be resolved locally are skipped.
+func extractPomDependencies(data []byte) ([]Package, error) {
+	var pom pomProject
+	if err := xml.Unmarshal(data, &pom); err != nil {
+		return nil, fmt.Errorf("invalid pom.xml: %w", err)
+	}
+
+	props := map[string]string{
+		"project.version":        firstNonEmpty(pom.Version, pom.Parent.Version),
+		"project.groupId":        firstNonEmpty(pom.GroupID, pom.Parent.GroupID),
+		"project.parent.version": pom.Parent.Version,
+	}
+	for _, p := range pom.Properties.Entries {
+		props[p.XMLName.Local] = strings.TrimSpace(p.Value)
+	}
+	resolve := func(s string) string {
+		// Properties may refer to other properties; a few passes is plenty.
+		for i := 0; i < 5 && strings.Contains(s, "${"); i++ {
+			s = pomPropertyRef.ReplaceAllStringFunc(s, func(ref string) string {
+				if v, ok := props[ref[2:len(ref)-1]]; ok {
+					return v
+				}
+				return ref
+			})
+		}
+		return strings.TrimSpace(s)
+	}
+
+	managed := map[string]string{}
+	for _, d := range pom.DependencyManagement.Dependencies {
+		managed[resolve(d.GroupID)+":"+resolve(d.ArtifactID)] = resolve(d.Version)
+	}
+
+	var out []Package
+	for _, d := range pom.Dependencies {
+		coord := resolve(d.GroupID) + ":" + resolve(d.ArtifactID)
+		version := resolve(d.Version)
+		if version == "" {
+			version = managed[coord]
+		}
+		version = mavenPinnedVersion(version)
+		if version == "" || strings.Contains(version, "${") {
+			continue
+		}
+		out = append(out, Package{Ecosystem: "Maven", Name: coord, Version: version, Direct: true})
+	}
+	return out, nil
+}
+
+// mavenPinnedVersion turns a "hard" requirement such as "[1.2.3]" into its
+// version, and rejects real ranges which have no single version to query.
+func mavenPinnedVersion(v string) string {
+	if strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]") && !strings.Contains(v, ",") {
+		return v[1 : len(v)-1]
+	}
+	if strings.ContainsAny(v, "[](),") {
+		return ""
+	}
+	return v
+}
+
+// extractGradleLockfile reads a gradle.lockfile, whose lines look like
+//
+//	com.google.guava:guava:31.1-jre=compileClasspath,runtimeClasspath
+//
+// The same coordinate is listed once regardless of how many configurations
+// use it, and "empty=" lines name configurations with no dependencies.
+func extractGradleLockfile(data []byte) []Package {
+	var out []Package
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "empty=") {
+			continue
+		}
+		coord, _, _ := strings.Cut(line, "=")
+		parts := strings.Split(coord, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		out = append(out, Package{Ecosystem: "Maven", Name: parts[0] + ":" + parts[1], Version: parts[2]})
+	}
+	return out
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// LockfileMerged is the Lockfile type of a report merged from others.
+const LockfileMerged = "merged"
+
+// ReadReport reads a report written as JSON by scan -o json.
+func ReadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if r.Lockfile == "" {
+		return nil, fmt.Errorf("%s: not a keystone JSON report", path)
+	}
+	if err := checkSchemaVersion(r.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// MergeReports combines reports, each named after the project it came from,
+// into one report whose Projects are all of their per-lockfile reports.
+// Each of those has its Source prefixed with the project's name, since
+// reports from different repositories commonly share sources such as ".".
+func MergeReports(names []string, reports []*Report) *Report {
+	merged := &Report{Source: LockfileMerged, Lockfile: LockfileMerged}
+	for i, r := range reports {
+		merged.Interrupted = merged.Interrupted || r.Interrupted
+		for _, p := range r.Reports() {
+			p.Source = names[i] + ": " + p.Source
+			merged.Scanned += p.Scanned
+			merged.Partial = merged.Partial || p.Partial
+			merged.Projects = append(merged.Projects, p)
+		}
+	}
+	return merged
+}
+
+// ProjectSummary tallies the findings of one project's report.
+type ProjectSummary struct {
+	Name       string         `json:"name"`
+	Lockfiles  int            `json:"lockfiles"`
+	Scanned    int            `json:"packages_scanned"`
+	Vulnerable int            `json:"vulnerable_packages"`
+	Vulns      int            `json:"vulnerabilities"`
+	BySeverity map[string]int `json:"by_severity"`
+}
+
+func (s *ProjectSummary) add(r *Report) {
+	for _, p := range r.Reports() {
+		s.Lockfiles++
+		s.Scanned += p.Scanned
+		s.Vulnerable += len(p.Findings)
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				s.Vulns++
+				s.BySeverity[severityOrUnknown(v.Severity)]++
+			}
+		}
+	}
+}
+
+// AdvisorySpread is an advisory and the projects it affects.
+type AdvisorySpread struct {
+	ID       string   `json:"id"`
+	Severity string   `json:"severity,omitempty"`
+	Summary  string   `json:"summary,omitempty"`
+	Projects []string `json:"projects"`
+}
+
+// MergeSummary is the org-wide view of a set of projects' reports.
+type MergeSummary struct {
+	Projects []ProjectSummary `json:"projects"`
+	Totals   ProjectSummary   `json:"totals"`
+
+	// Advisories lists every advisory found, those affecting the most
+	// projects first, then by severity.
+	Advisories []AdvisorySpread `json:"advisories"`
+}
+
+// SummarizeReports tallies reports, each named after the project it came
+// from, per project and in total.
+func SummarizeReports(names []string, reports []*Report) MergeSummary {
+	s := MergeSummary{Totals: ProjectSummary{Name: "total", BySeverity: map[string]int{}}, Advisories: []AdvisorySpread{}}
+	spread := map[string]*AdvisorySpread{}
+	for i, r := range reports {
+		ps := ProjectSummary{Name: names[i], BySeverity: map[string]int{}}
+		ps.add(r)
+		s.Totals.add(r)
+		s.Projects = append(s.Projects, ps)
+
+		for _, p := range r.Reports() {
+			for _, f := range p.Findings {
+				for _, v := range f.Vulns {
+					a, ok := spread[v.ID]
+					if !ok {
+						a = &AdvisorySpread{ID: v.ID, Severity: severityOrUnknown(v.Severity), Summary: v.Summary}
+						spread[v.ID] = a
+					}
+					if !containsString(a.Projects, names[i]) {
+						a.Projects = append(a.Projects, names[i])
+					}
+				}
+			}
+		}
+	}
+	for _, id := range sortedKeys(spread) {
+		s.Advisories = append(s.Advisories, *spread[id])
+	}
+	sort.SliceStable(s.Advisories, func(i, j int) bool {
+		a, b := s.Advisories[i], s.Advisories[j]
+		if len(a.Projects) != len(b.Projects) {
+			return len(a.Projects) > len(b.Projects)
+		}
+		return severityRank(a.Severity) > severityRank(b.Severity)
+	})
+	return s
+}
+
+func severityOrUnknown(label string) string {
+	if label == "" {
+		return SeverityUnknown
+	}
+	return label
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// MultiSource is a Source combining the advisories of several, such as the
+// OSV API and deps.dev. Each package is affected by the advisories any of
+// them reports, once each by ID, and an advisory's record comes from the
+// first source that has it, so the fullest source goes first. A source
+// that fails is left out with a warning rather than failing the lookup,
+// unless every one of them does.
+type MultiSource []Source
+
+// QueryBatch returns the union of the IDs each source reports for each dep,
+// in the order of the sources. Only the first source reports progress.
+func (m MultiSource) QueryBatch(ctx context.Context, deps []Package) ([][]string, error) {
+	var out [][]string
+	var firstErr error
+	for i, s := range m {
+		ids, err := s.QueryBatch(quietAfterFirst(ctx, i), deps)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			slog.Warn("Advisory source unavailable, looking up without it", "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if out == nil {
+			out = ids
+			continue
+		}
+		for j := range deps {
+			for _, id := range ids[j] {
+				if !containsString(out[j], id) {
+					// Clip so as not to write into a slice the source kept.
+					out[j] = append(slices.Clip(out[j]), id)
+				}
+			}
+		}
+	}
+	if out == nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// FetchVulns asks each source in turn for the records the ones before it
+// couldn't give, failing an ID with the first source's error when none of
+// them has it.
+func (m MultiSource) FetchVulns(ctx context.Context, ids []string) (map[string]OSVVuln, map[string]error) {
+	found := make(map[string]OSVVuln, len(ids))
+	failed := map[string]error{}
+	pending := ids
+	for i, s := range m {
+		if len(pending) == 0 || ctx.Err() != nil {
+			break
+		}
+		got, errs := s.FetchVulns(quietAfterFirst(ctx, i), pending)
+		for id, v := range got {
+			found[id] = v
+			delete(failed, id)
+		}
+		for id, err := range errs {
+			if _, ok := failed[id]; !ok {
+				failed[id] = err
+			}
+		}
+		pending = pending[:0:0]
+		for _, id := range ids {
+			if _, ok := failed[id]; ok {
+				pending = append(pending, id)
+			}
+		}
+	}
+	return found, failed
+}
+
+// quietAfterFirst returns ctx for the first of a MultiSource's sources, and
+// for the others a copy that has them report no progress, which would count
+// the same packages twice.
+func quietAfterFirst(ctx context.Context, i int) context.Context {
+	if i == 0 {
+		return ctx
+	}
+	return WithProgress(ctx, nil)
+}
@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LockfileNodeModules is the Lockfile type of a report on the packages
+// installed under a node_modules directory.
+const LockfileNodeModules = "node_modules"
+
+// ReadNodeModules walks projectDir/node_modules, nested node_modules
+// directories and pnpm's .pnpm store included, and returns each installed
+// package read from its package.json. Packages named in the project's own
+// package.json are marked Direct.
+func ReadNodeModules(projectDir string) ([]Package, error) {
+	root := filepath.Join(projectDir, "node_modules")
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	direct := map[string]bool{}
+	if m, err := readPackageJSON(filepath.Join(projectDir, "package.json")); err == nil {
+		for _, deps := range []map[string]string{m.Dependencies, m.DevDependencies, m.OptionalDependencies} {
+			for n := range deps {
+				direct[n] = true
+			}
+		}
+	}
+
+	var out []Package
+	seen := map[string]bool{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); name == ".bin" || name == ".cache" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != "package.json" || !isInstalledPackageDir(filepath.Dir(path)) {
+			return nil
+		}
+		m, err := readPackageJSON(path)
+		if err != nil || m.Name == "" || m.Version == "" {
+			return nil // not every package.json under node_modules is a package's
+		}
+		key := m.Name + "@" + m.Version
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		out = append(out, Package{
+			Ecosystem:     "npm",
+			Name:          m.Name,
+			Version:       m.Version,
+			License:       m.license(),
+			Direct:        direct[m.Name] && filepath.Dir(filepath.Dir(path)) == root,
+			InstallScript: m.hasInstallScript(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out, nil
+}
+
+// isInstalledPackageDir reports whether dir is where a package is installed:
+// node_modules/<name> or node_modules/@scope/<name>. This skips the
+// package.json files packages ship in their own subdirectories.
+func isInstalledPackageDir(dir string) bool {
+	parent := filepath.Dir(dir)
+	if strings.HasPrefix(filepath.Base(parent), "@") {
+		parent = filepath.Dir(parent)
+	}
+	return filepath.Base(parent) == "node_modules"
+}
+
+type packageJSON struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	License              json.RawMessage   `json:"license"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+	Workspaces           json.RawMessage   `json:"workspaces"`
+	Scripts              map[string]string `json:"scripts"`
+}
+
+func readPackageJSON(path string) (*packageJSON, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m packageJSON
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// hasInstallScript reports whether npm runs a script of the package's when
+// installing it.
+func (m *packageJSON) hasInstallScript() bool {
+	return m.Scripts["preinstall"] != "" || m.Scripts["install"] != "" || m.Scripts["postinstall"] != ""
+}
+
+// license returns the package's SPDX expression, which old packages give as
+// {"type": "MIT", "url": ...}.
+func (m *packageJSON) license() string {
+	var s string
+	if json.Unmarshal(m.License, &s) == nil {
+		return s
+	}
+	var obj struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(m.License, &obj)
+	return obj.Type
+}
+
+// Drift lists the packages whose installed versions differ from those
+// recorded in the lockfile.
+type Drift struct {
+	Lockfile string       `json:"lockfile"`
+	Packages []DriftEntry `json:"packages"`
+}
+
+// DriftEntry is one package whose installed and locked versions differ.
+// Installed is empty for a locked package that isn't installed, and Locked
+// for an installed package the lockfile doesn't know.
+type DriftEntry struct {
+	Package   string   `json:"package"`
+	Installed []string `json:"installed,omitempty"`
+	Locked    []string `json:"locked,omitempty"`
+}
+
+// CompareInstalled compares the installed packages with the locked ones, by
+// name and the set of versions of each. Optional packages for other
+// platforms show up as locked but not installed.
+func CompareInstalled(lockfile string, installed, locked []Package) *Drift {
+	versions := func(pkgs []Package) map[string][]string {
+		out := map[string][]string{}
+		seen := map[string]bool{}
+		for _, p := range pkgs {
+			if p.Version == "" || seen[p.Name+"@"+p.Version] {
+				continue
+			}
+			seen[p.Name+"@"+p.Version] = true
+			out[p.Name] = append(out[p.Name], p.Version)
+		}
+		for _, v := range out {
+			sort.Strings(v)
+		}
+		return out
+	}
+	have, want := versions(installed), versions(locked)
+
+	names := sortedKeys(have)
+	for n := range want {
+		if _, ok := have[n]; !ok {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	d := &Drift{Lockfile: lockfile}
+	for _, n := range names {
+		if strings.Join(have[n], " ") != strings.Join(want[n], " ") {
+			d.Packages = append(d.Packages, DriftEntry{Package: n, Installed: have[n], Locked: want[n]})
+		}
+	}
+	return d
+}
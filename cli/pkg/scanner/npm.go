@@ -0,0 +1,267 @@
+package scanner
+
+import (
+	"sort"
+	"strings"
+)
+
+// extractNpmPackages finds packages in lockfile v2/v3: lock["packages"] is a map
+// where keys are "", "node_modules/lodash", "node_modules/a/node_modules/b",
+// etc. We take the name from the last "node_modules/" segment of the key,
+// unless the entry names the real package (see NpmEntryName), and version
+// from the value's "version". Dependency edges are resolved the way
+// Node does: from the nearest node_modules directory outwards. Lockfiles
+// without a "packages" map are read as v1.
+func extractNpmPackages(lock map[string]any) []Package {
+	packagesAny, ok := lock["packages"]
+	if !ok {
+		// lockfileVersion 1 has only the nested "dependencies" tree.
+		return extractNpmV1Packages(lock)
+	}
+	packages, ok := packagesAny.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(packages))
+	for k := range packages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entry := func(k string) map[string]any {
+		e, _ := packages[k].(map[string]any)
+		return e
+	}
+	requires := func(k string, e map[string]any) []string {
+		var out []string
+		for _, field := range []string{"dependencies", "optionalDependencies"} {
+			names, _ := e[field].(map[string]any)
+			for _, n := range sortedKeys(names) {
+				if rk, ok := NpmResolve(packages, k, n); ok {
+					ver, _ := entry(rk)["version"].(string)
+					out = append(out, NpmEntryName(rk, entry(rk))+"@"+ver)
+				}
+			}
+		}
+		return out
+	}
+
+	// The root entry's dependencies are the project's direct dependencies.
+	direct := map[string]bool{}
+	if root := entry(""); root != nil {
+		for _, field := range []string{"dependencies", "devDependencies", "optionalDependencies"} {
+			names, _ := root[field].(map[string]any)
+			for n := range names {
+				direct["node_modules/"+n] = true
+			}
+		}
+	}
+
+	out := make([]Package, 0, len(packages))
+	for _, k := range keys {
+		e := entry(k)
+		// Root package entry has key "" — skip it (no module name), as well
+		// as workspace folders, which aren't installed under node_modules,
+		// and the links to them that are.
+		if e == nil || !strings.Contains(k, "node_modules/") {
+			continue
+		}
+		if link, _ := e["link"].(bool); link {
+			continue
+		}
+		ver, _ := e["version"].(string)
+		license, _ := e["license"].(string)
+		dev, _ := e["dev"].(bool)
+		script, _ := e["hasInstallScript"].(bool)
+		integrity, _ := e["integrity"].(string)
+
+		out = append(out, Package{
+			Ecosystem:     "npm",
+			Name:          NpmEntryName(k, e),
+			Version:       ver,
+			License:       license,
+			Direct:        direct[k],
+			Dev:           dev,
+			Requires:      requires(k, e),
+			InstallScript: script,
+			Integrity:     integrity,
+		})
+	}
+	return out
+}
+
+// extractNpmV1Packages finds packages in lockfile v1, whose "dependencies"
+// map nests the way node_modules does: each entry may have "dependencies" of
+// its own, installed beneath it, and "requires" ranges, which resolve to the
+// nearest enclosing entry of that name. A name@version installed in several
+// places is listed once, as development-only if every copy of it is.
+func extractNpmV1Packages(lock map[string]any) []Package {
+	top, _ := lock["dependencies"].(map[string]any)
+	var (
+		out   []Package
+		index = map[string]int{} // name@version → position in out
+	)
+	// scopes holds the "dependencies" maps enclosing the entry being
+	// walked, innermost last.
+	var walk func(scopes []map[string]any)
+	walk = func(scopes []map[string]any) {
+		deps := scopes[len(scopes)-1]
+		for _, name := range sortedKeys(deps) {
+			e, _ := deps[name].(map[string]any)
+			ver, _ := e["version"].(string)
+			if e == nil || ver == "" {
+				continue
+			}
+			realName, ver := npmV1Alias(name, ver)
+			nested, _ := e["dependencies"].(map[string]any)
+			inner := scopes
+			if nested != nil {
+				inner = append(scopes[:len(scopes):len(scopes)], nested)
+			}
+
+			var requires []string
+			names, _ := e["requires"].(map[string]any)
+			for _, n := range sortedKeys(names) {
+				if rv, ok := npmV1Resolve(inner, n); ok {
+					rn, rv := npmV1Alias(n, rv)
+					requires = append(requires, rn+"@"+rv)
+				}
+			}
+			dev, _ := e["dev"].(bool)
+			integrity, _ := e["integrity"].(string)
+
+			id := realName + "@" + ver
+			if i, seen := index[id]; seen {
+				out[i].Dev = out[i].Dev && dev
+				for _, r := range requires {
+					if !containsString(out[i].Requires, r) {
+						out[i].Requires = append(out[i].Requires, r)
+					}
+				}
+			} else {
+				index[id] = len(out)
+				out = append(out, Package{
+					Ecosystem: "npm",
+					Name:      realName,
+					Version:   ver,
+					Dev:       dev,
+					Requires:  requires,
+					Integrity: integrity,
+				})
+			}
+			if nested != nil {
+				walk(inner)
+			}
+		}
+	}
+	if top != nil {
+		walk([]map[string]any{top})
+	}
+	return out
+}
+
+// npmV1Resolve finds the version a v1 entry gets when it requires name, from
+// the innermost of scopes outwards.
+func npmV1Resolve(scopes []map[string]any, name string) (string, bool) {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if e, _ := scopes[i][name].(map[string]any); e != nil {
+			if ver, _ := e["version"].(string); ver != "" {
+				return ver, true
+			}
+		}
+	}
+	return "", false
+}
+
+// npmV1Alias returns the real name and version of a v1 entry installed as
+// name, whose version is "npm:real@1.2.3" when name is an alias.
+func npmV1Alias(name, version string) (string, string) {
+	spec, ok := strings.CutPrefix(version, "npm:")
+	if i := strings.LastIndex(spec, "@"); ok && i > 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return name, version
+}
+
+// NpmResolve finds the "packages" key of the package that the one at key
+// "from" gets when it requires name, searching node_modules directories from
+// the nearest outwards the way Node does.
+func NpmResolve(packages map[string]any, from, name string) (string, bool) {
+	for dir := from; ; {
+		k := "node_modules/" + name
+		if dir != "" {
+			k = dir + "/" + k
+		}
+		if e, _ := packages[k].(map[string]any); e != nil {
+			if ver, _ := e["version"].(string); ver != "" {
+				return k, true
+			}
+		}
+		if dir == "" {
+			return "", false
+		}
+		// Step out of the enclosing node_modules directory.
+		i := strings.LastIndex(dir, "/node_modules/")
+		if i < 0 {
+			dir = ""
+		} else {
+			dir = dir[:i]
+		}
+	}
+}
+
+// NpmKeyName returns the package name for a "packages" key:
+// "node_modules/a/node_modules/@scope/b" → "@scope/b".
+func NpmKeyName(key string) string {
+	if i := strings.LastIndex(key, "node_modules/"); i >= 0 {
+		return key[i+len("node_modules/"):]
+	}
+	return key
+}
+
+// NpmEntryName returns the name of the package in the "packages" entry e at
+// key. That is the key's name except for an alias ("my-lodash":
+// "npm:lodash@^4"), whose entry gives the real name in "name", or in older
+// lockfiles only in the registry tarball URL it was "resolved" from.
+func NpmEntryName(key string, e map[string]any) string {
+	if name, _ := e["name"].(string); name != "" {
+		return name
+	}
+	if resolved, _ := e["resolved"].(string); resolved != "" {
+		if name, ok := npmTarballName(resolved); ok {
+			return name
+		}
+	}
+	return NpmKeyName(key)
+}
+
+// npmTarballName reads the package name from a registry tarball URL such as
+// https://registry.npmjs.org/@scope/pkg/-/pkg-1.2.3.tgz.
+func npmTarballName(resolved string) (string, bool) {
+	path, file, ok := strings.Cut(resolved, "/-/")
+	if !ok || !strings.HasSuffix(file, ".tgz") {
+		return "", false
+	}
+	path = strings.NewReplacer("%2f", "/", "%2F", "/").Replace(path)
+	parts := strings.Split(path, "/")
+	n := len(parts)
+	name := parts[n-1]
+	// The file is the unscoped name and the version.
+	if name == "" || !strings.HasPrefix(file, name+"-") {
+		return "", false
+	}
+	if n >= 2 && strings.HasPrefix(parts[n-2], "@") {
+		name = parts[n-2] + "/" + name
+	}
+	return name, true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,191 @@
+package scanner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NpmRangeSatisfies reports whether version falls within an npm semver range
+// such as "^1.2.3", "~1.2", ">=1.0.0 <2", "1.x || 2.0.0 - 2.3" or "*". Ranges
+// that aren't semver (tags, URLs, "file:" or "npm:" specs) are an error.
+func NpmRangeSatisfies(rng, version string) (bool, error) {
+	v, ok := parseSemver(version)
+	if !ok {
+		return false, fmt.Errorf("not a semver version: %q", version)
+	}
+	for _, set := range strings.Split(rng, "||") {
+		comparators, err := parseNpmComparatorSet(set)
+		if err != nil {
+			return false, fmt.Errorf("unsupported range %q: %w", rng, err)
+		}
+		ok := true
+		for _, c := range comparators {
+			if !c.allows(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type npmComparator struct {
+	op string // one of <, <=, >, >=, =
+	v  semver
+}
+
+func (c npmComparator) allows(v semver) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// parseNpmComparatorSet desugars one "||"-separated part of a range into
+// plain comparators.
+func parseNpmComparatorSet(set string) ([]npmComparator, error) {
+	fields := strings.Fields(set)
+	// "1.2.3 - 2.3" is an inclusive hyphen range.
+	if len(fields) == 3 && fields[1] == "-" {
+		lo, err := parseNpmPartial(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parseNpmPartial(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		out := []npmComparator{{">=", lo.floor()}}
+		if hi.n == 3 {
+			out = append(out, npmComparator{"<=", hi.floor()})
+		} else if hi.n > 0 {
+			out = append(out, npmComparator{"<", hi.bump(hi.n - 1)})
+		}
+		return out, nil
+	}
+
+	var out []npmComparator
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		op := ""
+		for _, o := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(f, o) {
+				op, f = o, strings.TrimPrefix(f, o)
+				break
+			}
+		}
+		// Allow a space between operator and version: ">= 1.2.3".
+		if f == "" && op != "" && i+1 < len(fields) {
+			i++
+			f = fields[i]
+		}
+		p, err := parseNpmPartial(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p.comparators(op)...)
+	}
+	return out, nil
+}
+
+// npmPartial is a possibly incomplete version: "1", "1.2", "1.x", "*".
+type npmPartial struct {
+	parts [3]uint64
+	n     int // how many of parts were given
+	pre   []string
+}
+
+func parseNpmPartial(s string) (npmPartial, error) {
+	var p npmPartial
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "=")
+	s, _, _ = strings.Cut(s, "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		p.pre = strings.Split(pre, ".")
+	}
+	if core == "" {
+		return p, nil
+	}
+	for i, part := range strings.Split(core, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		if i >= 3 {
+			return p, fmt.Errorf("too many version components in %q", s)
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("bad version %q", s)
+		}
+		p.parts[i] = n
+		p.n = i + 1
+	}
+	if p.n < 3 {
+		p.pre = nil
+	}
+	return p, nil
+}
+
+// floor is the lowest version the partial stands for: "1.2" → 1.2.0.
+func (p npmPartial) floor() semver {
+	return semver{core: p.parts, pre: p.pre}
+}
+
+// bump increments component i and zeroes the ones after it.
+func (p npmPartial) bump(i int) semver {
+	var s semver
+	copy(s.core[:i], p.parts[:i])
+	s.core[i] = p.parts[i] + 1
+	return s
+}
+
+func (p npmPartial) comparators(op string) []npmComparator {
+	if p.n == 0 {
+		// "*", "x" or "": anything, except that "<*" and ">*" match nothing.
+		if op == "<" || op == ">" {
+			return []npmComparator{{"<", semver{}}}
+		}
+		return nil
+	}
+	switch op {
+	case "^":
+		// Allow changes that don't modify the left-most non-zero component.
+		i := 0
+		for i < p.n-1 && p.parts[i] == 0 {
+			i++
+		}
+		return []npmComparator{{">=", p.floor()}, {"<", p.bump(i)}}
+	case "~":
+		i := 1
+		if p.n == 1 {
+			i = 0
+		}
+		return []npmComparator{{">=", p.floor()}, {"<", p.bump(i)}}
+	case ">":
+		if p.n < 3 {
+			return []npmComparator{{">=", p.bump(p.n - 1)}}
+		}
+	case "<=":
+		if p.n < 3 {
+			return []npmComparator{{"<", p.bump(p.n - 1)}}
+		}
+	case "", "=":
+		if p.n < 3 {
+			return []npmComparator{{">=", p.floor()}, {"<", p.bump(p.n - 1)}}
+		}
+		return []npmComparator{{"=", p.floor()}}
+	}
+	return []npmComparator{{op, p.floor()}}
+}
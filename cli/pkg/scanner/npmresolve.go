@@ -0,0 +1,457 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultNpmRegistry is the public npm registry.
+const DefaultNpmRegistry = "https://registry.npmjs.org"
+
+// LockfilePackageJSON is the Lockfile type of a report on a package.json
+// whose dependency ranges were resolved against the registry.
+const LockfilePackageJSON = "package.json"
+
+// npmResolveConcurrency is how many packuments are fetched at once.
+const npmResolveConcurrency = 8
+
+// Resolution is set on a report whose versions were resolved from a
+// package.json's ranges rather than read from a lockfile: they are what an
+// install would most likely have picked at the time of the scan, not what
+// any install actually has.
+type Resolution struct {
+	Registry string `json:"registry"`
+
+	// Unresolved lists the dependencies left out, as "name@range: why".
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// npmPackument is the abbreviated registry metadata of a package.
+type npmPackument struct {
+	DistTags map[string]string `json:"dist-tags"`
+	Versions map[string]struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	} `json:"versions"`
+}
+
+var (
+	// errNpmNotFound means the registry has no such package.
+	errNpmNotFound = errors.New("not found on the registry")
+	// errNpmUnsatisfied means no published version satisfies a range.
+	errNpmUnsatisfied = errors.New("no published version satisfies the range")
+)
+
+// npmWant is a dependency waiting to be resolved: name at range rng, as
+// required by the package at index from of the resolved list, or by the
+// manifest itself when from is -1.
+type npmWant struct {
+	from      int
+	name, rng string
+	dev       bool
+}
+
+// ResolveNpmManifest resolves the dependencies of a package.json, and theirs
+// in turn, to concrete versions the way a fresh npm install would: the
+// "latest" dist-tag if the range allows it, else the newest release that
+// satisfies it. It's best-effort: it ignores peer dependencies and doesn't
+// dedupe the way npm's tree builder does. Dependencies that can't be
+// resolved, such as git or file specs, are listed in the Resolution rather
+// than failing the whole manifest, but a registry that can't be reached
+// does fail it. A nil client means http.DefaultClient.
+func ResolveNpmManifest(client *http.Client, registry string, data []byte) ([]Package, *Resolution, error) {
+	var m packageJSON
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if registry == "" {
+		registry = DefaultNpmRegistry
+	}
+	registry = strings.TrimSuffix(registry, "/")
+	res := &Resolution{Registry: registry}
+
+	packuments := map[string]*npmPackument{}
+	failed := map[string]error{}
+	// fetchAll fetches the packuments of names not fetched already, in
+	// parallel.
+	fetchAll := func(names []string) {
+		var todo []string
+		for _, name := range names {
+			_, done := packuments[name]
+			_, bad := failed[name]
+			if !done && !bad && !containsString(todo, name) {
+				todo = append(todo, name)
+			}
+		}
+		got := make([]*npmPackument, len(todo))
+		errs := make([]error, len(todo))
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, npmResolveConcurrency)
+		for i, name := range todo {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int, name string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				got[i], errs[i] = fetchNpmPackument(client, registry, name)
+			}(i, name)
+		}
+		wg.Wait()
+		for i, name := range todo {
+			if errs[i] != nil {
+				failed[name] = errs[i]
+			} else {
+				packuments[name] = got[i]
+			}
+		}
+	}
+
+	var (
+		out      []Package
+		index    = map[string]int{}    // name@version → position in out
+		resolved = map[string]string{} // name@range → version, "" if unresolvable
+		queue    []npmWant
+	)
+	for _, field := range []struct {
+		deps map[string]string
+		dev  bool
+	}{{m.Dependencies, false}, {m.OptionalDependencies, false}, {m.DevDependencies, true}} {
+		for _, n := range sortedKeys(field.deps) {
+			queue = append(queue, npmWant{from: -1, name: n, rng: field.deps[n], dev: field.dev})
+		}
+	}
+
+	// Breadth-first, a level at a time, so that each level's packuments
+	// are fetched together.
+	for len(queue) > 0 {
+		level := queue
+		queue = nil
+
+		var names []string
+		for i, w := range level {
+			name, rng, ok := npmRegistrySpec(w.rng)
+			if !ok {
+				key := w.name + "@" + w.rng
+				if _, seen := resolved[key]; !seen {
+					res.Unresolved = append(res.Unresolved, key+": not a registry version range")
+					resolved[key] = ""
+				}
+				level[i].name = ""
+				continue
+			}
+			if name != "" {
+				level[i].name = name // an "npm:" alias
+			}
+			level[i].rng = rng
+			names = append(names, level[i].name)
+		}
+		fetchAll(names)
+
+		for _, w := range level {
+			if w.name == "" {
+				continue
+			}
+			key := w.name + "@" + w.rng
+			ver, seen := resolved[key]
+			if !seen {
+				var err error
+				if ver, err = resolveNpmRange(packuments[w.name], failed[w.name], w.rng); err != nil {
+					if !errors.Is(err, errNpmNotFound) && !errors.Is(err, errNpmUnsatisfied) {
+						return nil, nil, err
+					}
+					res.Unresolved = append(res.Unresolved, fmt.Sprintf("%s: %v", key, err))
+				}
+				resolved[key] = ver
+			}
+			if ver == "" {
+				continue
+			}
+			id := w.name + "@" + ver
+			if w.from >= 0 && !containsString(out[w.from].Requires, id) {
+				out[w.from].Requires = append(out[w.from].Requires, id)
+			}
+
+			if i, ok := index[id]; ok {
+				// Reached again, from a runtime dependency this time: so
+				// are its own dependencies.
+				if out[i].Dev && !w.dev {
+					out[i].Dev = false
+					queue = append(queue, npmDependencies(packuments[w.name], i, ver, false)...)
+				}
+				continue
+			}
+			index[id] = len(out)
+			out = append(out, Package{
+				Ecosystem: "npm",
+				Name:      w.name,
+				Version:   ver,
+				Direct:    w.from < 0,
+				Dev:       w.dev,
+			})
+			queue = append(queue, npmDependencies(packuments[w.name], len(out)-1, ver, w.dev)...)
+		}
+	}
+	sort.Strings(res.Unresolved)
+	return out, res, nil
+}
+
+// npmDependencies lists the dependencies of version ver of p, the package at
+// index from of the resolved list.
+func npmDependencies(p *npmPackument, from int, ver string, dev bool) []npmWant {
+	v := p.Versions[ver]
+	var out []npmWant
+	for _, deps := range []map[string]string{v.Dependencies, v.OptionalDependencies} {
+		for _, n := range sortedKeys(deps) {
+			out = append(out, npmWant{from: from, name: n, rng: deps[n], dev: dev})
+		}
+	}
+	return out
+}
+
+// npmRegistrySpec returns the range of a dependency spec that the registry
+// can resolve, with the real package name if it's an "npm:name@range"
+// alias. Git, file, link, URL and workspace specs aren't resolvable.
+func npmRegistrySpec(spec string) (name, rng string, ok bool) {
+	spec = strings.TrimSpace(spec)
+	if alias, found := strings.CutPrefix(spec, "npm:"); found {
+		i := strings.LastIndex(alias, "@")
+		if i <= 0 {
+			return alias, "latest", true
+		}
+		return alias[:i], alias[i+1:], true
+	}
+	if strings.Contains(spec, ":") || strings.Contains(spec, "/") {
+		return "", "", false
+	}
+	if spec == "" {
+		spec = "*"
+	}
+	return "", spec, true
+}
+
+// resolveNpmRange picks the version of p that npm would install for rng,
+// given the outcome of fetching p.
+func resolveNpmRange(p *npmPackument, fetchErr error, rng string) (string, error) {
+	if fetchErr != nil {
+		return "", fetchErr
+	}
+	if v, ok := p.DistTags[rng]; ok {
+		return v, nil
+	}
+	satisfies := func(v string) bool {
+		ok, err := NpmRangeSatisfies(rng, v)
+		return err == nil && ok
+	}
+	if latest := p.DistTags["latest"]; latest != "" && satisfies(latest) {
+		return latest, nil
+	}
+	// Pre-releases are only picked by ranges that mention one.
+	pre := strings.Contains(rng, "-")
+	best := ""
+	for v := range p.Versions {
+		if !pre && strings.Contains(v, "-") {
+			continue
+		}
+		if satisfies(v) && (best == "" || CompareVersions("npm", v, best) > 0) {
+			best = v
+		}
+	}
+	if best == "" {
+		return "", errNpmUnsatisfied
+	}
+	return best, nil
+}
+
+// fetchNpmPackument fetches the abbreviated metadata of a package.
+func fetchNpmPackument(client *http.Client, registry, name string) (*npmPackument, error) {
+	req, err := http.NewRequest(http.MethodGet, registry+"/"+strings.Replace(name, "/", "%2f", 1), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("npm registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errNpmNotFound
+	default:
+		return nil, fmt.Errorf("npm registry returned %s for %s", resp.Status, name)
+	}
+	var p npmPackument
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, fmt.Errorf("%s: bad registry metadata: %w", name, err)
+	}
+	return &p, nil
+}
+
+// NpmRelease is what the registry records about the publication of one
+// version of a package.
+type NpmRelease struct {
+	Published time.Time
+	Publisher string // npm user who published it
+
+	// NewPublisher is set when Publisher hadn't published any earlier
+	// version of the package, as happens when an account is taken over.
+	NewPublisher bool
+
+	// Deprecated is the maintainers' deprecation message when they've
+	// deprecated the version, usually naming what to use instead.
+	Deprecated string
+
+	// Signature and Provenance are left empty by FetchNpmReleases and set
+	// to a Verification state by VerifyNpmReleases, which explains any
+	// failure in SignatureProblem or ProvenanceProblem.
+	Signature         string
+	SignatureProblem  string
+	Provenance        string
+	ProvenanceProblem string
+
+	dist npmDist
+}
+
+// FetchNpmReleases looks up the publication of each npm package version in
+// deps, from its full registry metadata, keyed "npm/name@version". Packages
+// the registry doesn't have are left out.
+func FetchNpmReleases(client *http.Client, registry string, deps []Package) (map[string]NpmRelease, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if registry == "" {
+		registry = DefaultNpmRegistry
+	}
+	registry = strings.TrimSuffix(registry, "/")
+
+	versions := map[string][]string{}
+	for _, d := range deps {
+		if d.Ecosystem == "npm" && !containsString(versions[d.Name], d.Version) {
+			versions[d.Name] = append(versions[d.Name], d.Version)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, npmResolveConcurrency)
+		out      = map[string]NpmRelease{}
+		firstErr error
+	)
+	for _, name := range sortedKeys(versions) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			doc, err := fetchNpmDocument(client, registry, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if !errors.Is(err, errNpmNotFound) && firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for _, v := range versions[name] {
+				if rel, ok := doc.release(v); ok {
+					out["npm/"+name+"@"+v] = rel
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// npmDocument is the part of a package's full registry metadata that
+// FetchNpmReleases and FetchLatestReleases need.
+type npmDocument struct {
+	DistTags   map[string]string `json:"dist-tags"`
+	Repository json.RawMessage   `json:"repository"`
+	Time       map[string]string `json:"time"`
+	Versions   map[string]struct {
+		NpmUser struct {
+			Name string `json:"name"`
+		} `json:"_npmUser"`
+		Dist       npmDist `json:"dist"`
+		Deprecated string  `json:"deprecated"`
+	} `json:"versions"`
+}
+
+// release describes the publication of version v.
+func (doc *npmDocument) release(v string) (NpmRelease, bool) {
+	published, err := time.Parse(time.RFC3339, doc.Time[v])
+	if err != nil {
+		return NpmRelease{}, false
+	}
+	meta := doc.Versions[v]
+	rel := NpmRelease{Published: published, Publisher: meta.NpmUser.Name, Deprecated: meta.Deprecated, dist: meta.Dist}
+	if rel.Publisher == "" {
+		return rel, true
+	}
+	earlier := false
+	for other, meta := range doc.Versions {
+		t, err := time.Parse(time.RFC3339, doc.Time[other])
+		if err != nil || !t.Before(published) {
+			continue
+		}
+		earlier = true
+		if meta.NpmUser.Name == rel.Publisher {
+			return rel, true
+		}
+	}
+	rel.NewPublisher = earlier
+	return rel, true
+}
+
+// repositoryURL returns the package's repository, which package.json gives
+// as a URL, a "github:user/repo" or "user/repo" shorthand, or an object
+// with a url.
+func (doc *npmDocument) repositoryURL() string {
+	var repo struct {
+		URL string `json:"url"`
+	}
+	var s string
+	if json.Unmarshal(doc.Repository, &s) != nil {
+		if json.Unmarshal(doc.Repository, &repo) != nil {
+			return ""
+		}
+		s = repo.URL
+	}
+	if !strings.Contains(s, ":") && strings.Count(s, "/") == 1 {
+		s = "github:" + s
+	}
+	return s
+}
+
+// fetchNpmDocument fetches a package's full registry metadata.
+func fetchNpmDocument(client *http.Client, registry, name string) (*npmDocument, error) {
+	resp, err := client.Get(registry + "/" + strings.Replace(name, "/", "%2f", 1))
+	if err != nil {
+		return nil, fmt.Errorf("npm registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errNpmNotFound
+	default:
+		return nil, fmt.Errorf("npm registry returned %s for %s", resp.Status, name)
+	}
+	var doc npmDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%s: bad registry metadata: %w", name, err)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,356 @@
+package scanner
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of a signature or provenance check by VerifyNpmReleases.
+const (
+	VerificationOK      = "verified"
+	VerificationMissing = "missing"
+	VerificationFailed  = "failed"
+)
+
+// npmDist is the "dist" metadata of one version of an npm package.
+type npmDist struct {
+	Integrity  string `json:"integrity"`
+	Signatures []struct {
+		KeyID string `json:"keyid"`
+		Sig   string `json:"sig"`
+	} `json:"signatures"`
+	Attestations *struct {
+		URL        string `json:"url"`
+		Provenance struct {
+			PredicateType string `json:"predicateType"`
+		} `json:"provenance"`
+	} `json:"attestations"`
+}
+
+// npmRegistryKey is a public key the registry signs package versions with.
+type npmRegistryKey struct {
+	KeyID   string `json:"keyid"`
+	Key     string `json:"key"`     // base64 DER of the public key
+	Expires string `json:"expires"` // empty if it hasn't expired
+}
+
+// slsaProvenancePrefix starts the predicate type of SLSA provenance.
+const slsaProvenancePrefix = "https://slsa.dev/provenance/"
+
+// VerifyNpmReleases checks, for each of releases (from FetchNpmReleases),
+// the registry's signature over the package's name, version and integrity
+// hash, and the Sigstore provenance attestation saying where and how it was
+// built. A registry that publishes no signing keys, as private ones often
+// don't, has its signatures left unchecked. An integrity hash in deps'
+// lockfiles that differs from the one the registry signed fails the
+// signature.
+//
+// Attestations are checked against their own certificate, package archive
+// and transparency log time; the certificate isn't chained to Sigstore's
+// root, so this catches tampering by the registry's mirrors, not by a
+// compromised registry.
+func VerifyNpmReleases(client *http.Client, registry string, deps []Package, releases map[string]NpmRelease) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if registry == "" {
+		registry = DefaultNpmRegistry
+	}
+	registry = strings.TrimSuffix(registry, "/")
+
+	keys, err := fetchNpmKeys(client, registry)
+	if err != nil && !errors.Is(err, errNpmNotFound) {
+		return err
+	}
+	locked := map[string][]string{}
+	for _, d := range deps {
+		if d.Ecosystem == "npm" && d.Integrity != "" {
+			k := "npm/" + d.Name + "@" + d.Version
+			locked[k] = append(locked[k], d.Integrity)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, npmResolveConcurrency)
+		firstErr error
+	)
+	ids := make([]string, 0, len(releases))
+	for k := range releases {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	for _, k := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(k string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			rel := releases[k]
+			name, version := splitNpmID(strings.TrimPrefix(k, "npm/"))
+
+			if len(keys) > 0 {
+				rel.Signature, rel.SignatureProblem = verifyNpmSignature(keys, name, version, rel, locked[k])
+			}
+			var err error
+			rel.Provenance, rel.ProvenanceProblem, err = verifyNpmProvenance(client, name, version, rel.dist)
+
+			mu.Lock()
+			defer mu.Unlock()
+			releases[k] = rel
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(k)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// splitNpmID splits "name@version", where name may be "@scope/name".
+func splitNpmID(id string) (string, string) {
+	i := strings.LastIndex(id, "@")
+	if i <= 0 {
+		return id, ""
+	}
+	return id[:i], id[i+1:]
+}
+
+// fetchNpmKeys fetches the registry's signing keys, by key ID.
+func fetchNpmKeys(client *http.Client, registry string) (map[string]npmRegistryKey, error) {
+	resp, err := client.Get(registry + "/-/npm/v1/keys")
+	if err != nil {
+		return nil, fmt.Errorf("npm registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errNpmNotFound
+	default:
+		return nil, fmt.Errorf("npm registry returned %s for its signing keys", resp.Status)
+	}
+	var body struct {
+		Keys []npmRegistryKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("bad npm registry signing keys: %w", err)
+	}
+	out := map[string]npmRegistryKey{}
+	for _, k := range body.Keys {
+		out[k.KeyID] = k
+	}
+	return out, nil
+}
+
+// verifyNpmSignature checks the registry signature of name@version, and
+// that the integrity hashes the lockfiles hold are the one it signed.
+func verifyNpmSignature(keys map[string]npmRegistryKey, name, version string, rel NpmRelease, locked []string) (string, string) {
+	integrity := rel.dist.Integrity
+	for _, l := range locked {
+		if integrityConflicts(l, integrity) {
+			return VerificationFailed, "the lockfile's integrity hash isn't the one the registry published"
+		}
+	}
+	if len(rel.dist.Signatures) == 0 {
+		return VerificationMissing, ""
+	}
+
+	digest := sha256.Sum256([]byte(name + "@" + version + ":" + integrity))
+	for _, s := range rel.dist.Signatures {
+		key, ok := keys[s.KeyID]
+		if !ok {
+			return VerificationFailed, fmt.Sprintf("signed with %s, which isn't one of the registry's keys", s.KeyID)
+		}
+		if expires, err := time.Parse(time.RFC3339, key.Expires); err == nil && rel.Published.After(expires) {
+			return VerificationFailed, fmt.Sprintf("signed with %s, which expired before the version was published", s.KeyID)
+		}
+		der, err := base64.StdEncoding.DecodeString(key.Key)
+		if err != nil {
+			return VerificationFailed, fmt.Sprintf("the registry's key %s can't be read", s.KeyID)
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		ecKey, ok := pub.(*ecdsa.PublicKey)
+		if err != nil || !ok {
+			return VerificationFailed, fmt.Sprintf("the registry's key %s isn't an ECDSA key", s.KeyID)
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil || !ecdsa.VerifyASN1(ecKey, digest[:], sig) {
+			return VerificationFailed, fmt.Sprintf("the signature by %s doesn't match the package", s.KeyID)
+		}
+	}
+	return VerificationOK, ""
+}
+
+// integrityConflicts reports whether two Subresource Integrity strings hold
+// different hashes for the same algorithm. Hashes of different algorithms,
+// such as an old lockfile's sha1 against the registry's sha512, can't
+// conflict.
+func integrityConflicts(a, b string) bool {
+	hashes := map[string]string{}
+	for _, h := range strings.Fields(a) {
+		if alg, sum, ok := strings.Cut(h, "-"); ok {
+			hashes[alg] = sum
+		}
+	}
+	for _, h := range strings.Fields(b) {
+		if alg, sum, ok := strings.Cut(h, "-"); ok {
+			if other, found := hashes[alg]; found && other != sum {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sigstoreBundle is the part of a Sigstore bundle needed to check an npm
+// attestation.
+type sigstoreBundle struct {
+	VerificationMaterial struct {
+		Certificate *struct {
+			RawBytes []byte `json:"rawBytes"`
+		} `json:"certificate"`
+		X509CertificateChain *struct {
+			Certificates []struct {
+				RawBytes []byte `json:"rawBytes"`
+			} `json:"certificates"`
+		} `json:"x509CertificateChain"`
+		TlogEntries []struct {
+			IntegratedTime string `json:"integratedTime"`
+		} `json:"tlogEntries"`
+	} `json:"verificationMaterial"`
+	DSSEEnvelope struct {
+		Payload     []byte `json:"payload"`
+		PayloadType string `json:"payloadType"`
+		Signatures  []struct {
+			Sig []byte `json:"sig"`
+		} `json:"signatures"`
+	} `json:"dsseEnvelope"`
+}
+
+// verifyNpmProvenance fetches and checks the provenance attestation of
+// name@version. The error is for attestations that couldn't be fetched,
+// which leaves the check undecided.
+func verifyNpmProvenance(client *http.Client, name, version string, dist npmDist) (string, string, error) {
+	if dist.Attestations == nil || dist.Attestations.URL == "" {
+		return VerificationMissing, "", nil
+	}
+	want := dist.Attestations.Provenance.PredicateType
+
+	resp, err := client.Get(dist.Attestations.URL)
+	if err != nil {
+		return "", "", fmt.Errorf("npm attestation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return VerificationFailed, "the attestations its metadata lists aren't published", nil
+	default:
+		return "", "", fmt.Errorf("npm registry returned %s for the attestations of %s@%s", resp.Status, name, version)
+	}
+	var body struct {
+		Attestations []struct {
+			PredicateType string         `json:"predicateType"`
+			Bundle        sigstoreBundle `json:"bundle"`
+		} `json:"attestations"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return VerificationFailed, "its attestations can't be read", nil
+	}
+	for _, a := range body.Attestations {
+		if a.PredicateType == want || (want == "" && strings.HasPrefix(a.PredicateType, slsaProvenancePrefix)) {
+			if problem := verifySigstoreBundle(a.Bundle, name, version, dist.Integrity); problem != "" {
+				return VerificationFailed, problem, nil
+			}
+			return VerificationOK, "", nil
+		}
+	}
+	return VerificationFailed, "none of its attestations is a provenance attestation", nil
+}
+
+// verifySigstoreBundle checks that a bundle's DSSE envelope is signed by
+// its certificate while that was valid, and that the in-toto statement in
+// it is about the archive of name@version with the given integrity. It
+// returns what is wrong, or "".
+func verifySigstoreBundle(b sigstoreBundle, name, version, integrity string) string {
+	var raw []byte
+	if c := b.VerificationMaterial.Certificate; c != nil {
+		raw = c.RawBytes
+	} else if ch := b.VerificationMaterial.X509CertificateChain; ch != nil && len(ch.Certificates) > 0 {
+		raw = ch.Certificates[0].RawBytes
+	}
+	if raw == nil {
+		return "the attestation has no signing certificate"
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return "the attestation's signing certificate can't be read"
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return "the attestation's signing certificate isn't for an ECDSA key"
+	}
+
+	env := b.DSSEEnvelope
+	digest := sha256.Sum256(PAE(env.PayloadType, env.Payload))
+	signed := false
+	for _, s := range env.Signatures {
+		signed = signed || ecdsa.VerifyASN1(pub, digest[:], s.Sig)
+	}
+	if !signed {
+		return "the attestation's signature doesn't match its certificate"
+	}
+
+	if len(b.VerificationMaterial.TlogEntries) == 0 {
+		return "the attestation isn't recorded in the transparency log"
+	}
+	secs, err := strconv.ParseInt(b.VerificationMaterial.TlogEntries[0].IntegratedTime, 10, 64)
+	if err != nil {
+		return "the attestation's transparency log entry can't be read"
+	}
+	if at := time.Unix(secs, 0); at.Before(cert.NotBefore) || at.After(cert.NotAfter) {
+		return "the attestation was signed outside its certificate's validity"
+	}
+
+	var statement struct {
+		Subject []struct {
+			Name   string            `json:"name"`
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+	}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return "the attestation's statement can't be read"
+	}
+	sha512 := ""
+	for _, h := range strings.Fields(integrity) {
+		if sum, ok := strings.CutPrefix(h, "sha512-"); ok {
+			if b, err := base64.StdEncoding.DecodeString(sum); err == nil {
+				sha512 = hex.EncodeToString(b)
+			}
+		}
+	}
+	if sha512 == "" {
+		return "there's no sha512 integrity hash to check the attestation against"
+	}
+	for _, s := range statement.Subject {
+		p, err := ParsePURL(s.Name)
+		if err == nil && p.Name == name && p.Version == version && strings.EqualFold(s.Digest["sha512"], sha512) {
+			return ""
+		}
+	}
+	return "the attestation is about a different package archive"
+}
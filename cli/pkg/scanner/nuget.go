@@ -0,0 +1,245 @@
+package scanner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// nugetLock is a packages.lock.json: for each target framework (and runtime,
+// as "net8.0/linux-x64"), every package restored for it.
+type nugetLock struct {
+	Dependencies map[string]map[string]nugetLockEntry `json:"dependencies"`
+}
+
+type nugetLockEntry struct {
+	Type         string            `json:"type"` // Direct, Transitive, CentralTransitive or Project
+	Resolved     string            `json:"resolved"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// extractNuGetLock reads a packages.lock.json. A package restored for
+// several target frameworks is listed once, as direct if any framework's
+// project references it; project references are local code and skipped.
+func extractNuGetLock(data []byte) ([]Package, error) {
+	var lock nugetLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	g := newNuGetGraph()
+	for _, target := range sortedKeys(lock.Dependencies) {
+		entries := lock.Dependencies[target]
+		for _, name := range sortedKeys(entries) {
+			e := entries[name]
+			if e.Type == "Project" || e.Resolved == "" {
+				continue
+			}
+			var requires []string
+			for _, dep := range sortedKeys(e.Dependencies) {
+				if d, ok := entries[dep]; ok && d.Type != "Project" && d.Resolved != "" {
+					requires = append(requires, dep+"@"+d.Resolved)
+				}
+			}
+			g.add(name, e.Resolved, e.Type == "Direct", requires)
+		}
+	}
+	return g.pkgs, nil
+}
+
+// nugetAssets is the part of an obj/project.assets.json, the restore
+// output of a project without a lockfile, that lists its packages.
+type nugetAssets struct {
+	Targets map[string]map[string]struct {
+		Type         string            `json:"type"` // package or project
+		Dependencies map[string]string `json:"dependencies"`
+	} `json:"targets"`
+	Project struct {
+		Frameworks map[string]struct {
+			Dependencies map[string]json.RawMessage `json:"dependencies"`
+		} `json:"frameworks"`
+	} `json:"project"`
+}
+
+// extractNuGetAssets reads the packages restored for each target framework
+// of a project.assets.json, keyed "Name/Version". The project's own
+// package references are the direct dependencies.
+func extractNuGetAssets(data []byte) ([]Package, error) {
+	var assets nugetAssets
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	direct := map[string]bool{}
+	for _, fw := range assets.Project.Frameworks {
+		for name := range fw.Dependencies {
+			direct[strings.ToLower(name)] = true
+		}
+	}
+	g := newNuGetGraph()
+	for _, target := range sortedKeys(assets.Targets) {
+		libs := assets.Targets[target]
+		resolved := map[string]string{} // lower-cased name → version
+		for key, lib := range libs {
+			if name, version, ok := strings.Cut(key, "/"); ok && lib.Type == "package" {
+				resolved[strings.ToLower(name)] = version
+			}
+		}
+		for _, key := range sortedKeys(libs) {
+			lib := libs[key]
+			name, version, ok := strings.Cut(key, "/")
+			if !ok || lib.Type != "package" {
+				continue
+			}
+			var requires []string
+			for _, dep := range sortedKeys(lib.Dependencies) {
+				if v, ok := resolved[strings.ToLower(dep)]; ok {
+					requires = append(requires, dep+"@"+v)
+				}
+			}
+			g.add(name, version, direct[strings.ToLower(name)], requires)
+		}
+	}
+	return g.pkgs, nil
+}
+
+// nugetGraph collects a project's packages across target frameworks,
+// merging the entries for the same version.
+type nugetGraph struct {
+	pkgs  []Package
+	index map[string]int // lower-cased name@version → index in pkgs
+}
+
+func newNuGetGraph() *nugetGraph {
+	return &nugetGraph{index: map[string]int{}}
+}
+
+func (g *nugetGraph) add(name, version string, direct bool, requires []string) {
+	id := strings.ToLower(name + "@" + version)
+	if i, ok := g.index[id]; ok {
+		g.pkgs[i].Direct = g.pkgs[i].Direct || direct
+		for _, r := range requires {
+			if !containsString(g.pkgs[i].Requires, r) {
+				g.pkgs[i].Requires = append(g.pkgs[i].Requires, r)
+			}
+		}
+		return
+	}
+	g.index[id] = len(g.pkgs)
+	g.pkgs = append(g.pkgs, Package{Ecosystem: "NuGet", Name: name, Version: version, Direct: direct, Requires: requires})
+}
+
+// msbuildProject is the part of a .csproj, .fsproj, .vbproj or
+// Directory.Packages.props that names packages.
+type msbuildProject struct {
+	PropertyGroups []struct {
+		Entries []pomProperty `xml:",any"`
+	} `xml:"PropertyGroup"`
+	ItemGroups []struct {
+		PackageReferences       []msbuildPackage `xml:"PackageReference"`
+		PackageVersions         []msbuildPackage `xml:"PackageVersion"`
+		GlobalPackageReferences []msbuildPackage `xml:"GlobalPackageReference"`
+	} `xml:"ItemGroup"`
+}
+
+type msbuildPackage struct {
+	Include         string `xml:"Include,attr"`
+	Version         string `xml:"Version,attr"`
+	VersionOverride string `xml:"VersionOverride,attr"`
+	VersionElement  string `xml:"Version"`
+}
+
+func (p msbuildPackage) version() string {
+	return firstNonEmpty(p.VersionOverride, p.Version, strings.TrimSpace(p.VersionElement))
+}
+
+var msbuildPropertyRef = regexp.MustCompile(`\$\(([^)]+)\)`)
+
+// extractMSBuildProject reads the <PackageReference> items of a project
+// file. With central package management, their versions come from the
+// nearest Directory.Packages.props above it, whose
+// <GlobalPackageReference> items every project gets as well. $(Property)
+// references are resolved from either file's properties. References to
+// floating versions or ranges are skipped: restoring picks their version,
+// which packages.lock.json records.
+func extractMSBuildProject(path string, data []byte) ([]Package, error) {
+	var proj msbuildProject
+	if err := xml.Unmarshal(data, &proj); err != nil {
+		return nil, fmt.Errorf("invalid project file: %w", err)
+	}
+	var central msbuildProject
+	if props := findDirectoryPackagesProps(filepath.Dir(path)); props != "" {
+		data, err := os.ReadFile(props)
+		if err == nil {
+			err = xml.Unmarshal(data, &central)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", props, err)
+		}
+	}
+
+	props := map[string]string{}
+	for _, p := range []msbuildProject{central, proj} {
+		for _, g := range p.PropertyGroups {
+			for _, e := range g.Entries {
+				props[e.XMLName.Local] = strings.TrimSpace(e.Value)
+			}
+		}
+	}
+	resolve := func(s string) string {
+		for i := 0; i < 5 && strings.Contains(s, "$("); i++ {
+			s = msbuildPropertyRef.ReplaceAllStringFunc(s, func(ref string) string {
+				if v, ok := props[ref[2:len(ref)-1]]; ok {
+					return v
+				}
+				return ref
+			})
+		}
+		return strings.TrimSpace(s)
+	}
+
+	versions := map[string]string{} // lower-cased name → central version
+	var refs []msbuildPackage
+	for _, g := range central.ItemGroups {
+		for _, p := range g.PackageVersions {
+			versions[strings.ToLower(p.Include)] = p.version()
+		}
+		refs = append(refs, g.GlobalPackageReferences...)
+	}
+	for _, g := range proj.ItemGroups {
+		refs = append(refs, g.PackageReferences...)
+	}
+
+	g := newNuGetGraph()
+	for _, ref := range refs {
+		name := resolve(ref.Include)
+		version := ref.version()
+		if version == "" {
+			version = versions[strings.ToLower(name)]
+		}
+		version = mavenPinnedVersion(resolve(version))
+		if name == "" || version == "" || strings.ContainsAny(version, "*$") {
+			continue
+		}
+		g.add(name, version, true, nil)
+	}
+	return g.pkgs, nil
+}
+
+// findDirectoryPackagesProps returns the Directory.Packages.props MSBuild
+// would import for a project in dir: the first one found walking up.
+func findDirectoryPackagesProps(dir string) string {
+	for {
+		p := filepath.Join(dir, "Directory.Packages.props")
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,212 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+)
+
+const (
+	apkInstalledPath = "lib/apk/db/installed"
+	dpkgStatusPath   = "var/lib/dpkg/status"
+	dpkgStatusDir    = "var/lib/dpkg/status.d/" // distroless images keep one file per package here
+
+	rpmSQLitePath       = "usr/lib/sysimage/rpm/rpmdb.sqlite"
+	rpmSQLiteLegacyPath = "var/lib/rpm/rpmdb.sqlite"
+)
+
+// OSPackages returns the packages installed by the image's OS package
+// manager, with the database they were read from. Images without one, such
+// as scratch or distroless static images, have none. Of RPM databases, only
+// the SQLite one of RHEL 9 and later distributions can be read.
+func (img *Image) OSPackages() (db string, pkgs []Package, err error) {
+	release := parseOSRelease(img.Files["etc/os-release"])
+	if release == nil {
+		release = parseOSRelease(img.Files["usr/lib/os-release"])
+	}
+
+	if data, ok := img.Files[apkInstalledPath]; ok {
+		ver := strings.TrimSpace(string(img.Files["etc/alpine-release"]))
+		if ver == "" {
+			ver = release["VERSION_ID"]
+		}
+		return "/" + apkInstalledPath, extractApkPackages(data, alpineEcosystem(ver)), nil
+	}
+
+	var status [][]byte
+	if data, ok := img.Files[dpkgStatusPath]; ok {
+		status = append(status, data)
+	}
+	for _, name := range sortedKeys(img.Files) {
+		if strings.HasPrefix(name, dpkgStatusDir) {
+			status = append(status, img.Files[name])
+		}
+	}
+	if len(status) > 0 {
+		eco, err := debianEcosystem(release)
+		if err != nil {
+			return "", nil, err
+		}
+		return "/" + dpkgStatusPath, extractDpkgPackages(bytes.Join(status, []byte("\n\n")), eco), nil
+	}
+
+	for _, name := range []string{rpmSQLitePath, rpmSQLiteLegacyPath} {
+		if data, ok := img.Files[name]; ok {
+			eco, err := rpmEcosystem(release)
+			if err != nil {
+				return "", nil, err
+			}
+			pkgs, err := extractRpmPackages(data, eco)
+			return "/" + name, pkgs, err
+		}
+	}
+	for name := range imageOSFiles {
+		if strings.Contains(name, "rpm") && img.Files[name] != nil {
+			return "", nil, errors.New("only SQLite RPM databases can be read, not the Berkeley DB or ndb ones of RHEL 8 and earlier or SUSE")
+		}
+	}
+	return "", nil, nil
+}
+
+// parseOSRelease reads /etc/os-release's KEY=value lines.
+func parseOSRelease(data []byte) map[string]string {
+	if data == nil {
+		return nil
+	}
+	out := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if ok {
+			out[k] = strings.Trim(v, `"'`)
+		}
+	}
+	return out
+}
+
+// alpineEcosystem names OSV's ecosystem for an Alpine release: "3.18.4" is
+// "Alpine:v3.18".
+func alpineEcosystem(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return "Alpine"
+	}
+	return "Alpine:v" + parts[0] + "." + parts[1]
+}
+
+// debianEcosystem names OSV's ecosystem for a dpkg-based distribution, e.g.
+// "Debian:12" or "Ubuntu:22.04:LTS".
+func debianEcosystem(release map[string]string) (string, error) {
+	switch release["ID"] {
+	case "debian":
+		if v := release["VERSION_ID"]; v != "" {
+			return "Debian:" + v, nil
+		}
+		return "Debian", nil // testing/unstable have no version
+	case "ubuntu":
+		eco := "Ubuntu:" + release["VERSION_ID"]
+		if strings.Contains(release["VERSION"], "LTS") {
+			eco += ":LTS"
+		}
+		return eco, nil
+	case "":
+		return "", errors.New("dpkg database found but no os-release to tell which distribution it belongs to")
+	}
+	return "", errors.New("unsupported dpkg-based distribution " + release["ID"])
+}
+
+// rpmEcosystem names OSV's ecosystem for an RPM-based distribution, e.g.
+// "AlmaLinux:9" or "Rocky Linux:9".
+func rpmEcosystem(release map[string]string) (string, error) {
+	major, _, _ := strings.Cut(release["VERSION_ID"], ".")
+	switch release["ID"] {
+	case "almalinux":
+		return "AlmaLinux:" + major, nil
+	case "rocky":
+		return "Rocky Linux:" + major, nil
+	case "opensuse-tumbleweed":
+		return "openSUSE:Tumbleweed", nil
+	case "":
+		return "", errors.New("RPM database found but no os-release to tell which distribution it belongs to")
+	}
+	return "", errors.New("unsupported RPM-based distribution " + release["ID"])
+}
+
+// extractApkPackages reads Alpine's installed database. OSV tracks Alpine
+// advisories by origin (source) package, so subpackages such as libcrypto3
+// are reported under theirs (openssl).
+func extractApkPackages(data []byte, ecosystem string) []Package {
+	var out []Package
+	seen := map[string]bool{}
+	for _, rec := range stanzas(data) {
+		name := rec["o"]
+		if name == "" {
+			name = rec["P"]
+		}
+		if name == "" || rec["V"] == "" || seen[name+"@"+rec["V"]] {
+			continue
+		}
+		seen[name+"@"+rec["V"]] = true
+		out = append(out, Package{Ecosystem: ecosystem, Name: name, Version: rec["V"], License: rec["L"], Direct: true})
+	}
+	return out
+}
+
+// extractDpkgPackages reads a dpkg status file, keeping installed packages
+// under their source package, as OSV's Debian and Ubuntu advisories are.
+func extractDpkgPackages(data []byte, ecosystem string) []Package {
+	var out []Package
+	seen := map[string]bool{}
+	for _, rec := range stanzas(data) {
+		if st := rec["Status"]; st != "" && !strings.HasSuffix(st, " installed") {
+			continue
+		}
+		name, version := rec["Package"], rec["Version"]
+		if src := rec["Source"]; src != "" {
+			// "Source: openssl (3.0.11-1~deb12u2)" when the versions differ.
+			srcName, srcVersion, ok := strings.Cut(src, " (")
+			name = srcName
+			if ok {
+				version = strings.TrimSuffix(srcVersion, ")")
+			}
+		}
+		if name == "" || version == "" || seen[name+"@"+version] {
+			continue
+		}
+		seen[name+"@"+version] = true
+		out = append(out, Package{Ecosystem: ecosystem, Name: name, Version: version, Direct: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// stanzas splits blank-line-separated "Key: value" records, as used by both
+// dpkg's status file and apk's database ("K:value"). Continuation lines are
+// dropped.
+func stanzas(data []byte) []map[string]string {
+	var out []map[string]string
+	cur := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(cur) > 0 {
+				out = append(out, cur)
+				cur = map[string]string{}
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			cur[k] = strings.TrimSpace(v)
+		}
+	}
+	if len(cur) > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
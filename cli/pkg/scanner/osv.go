@@ -0,0 +1,743 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultOSVURL is the public OSV API.
+	DefaultOSVURL = "https://api.osv.dev/v1"
+
+	// osvBatchSize is the maximum number of queries OSV accepts per querybatch call.
+	osvBatchSize = 1000
+
+	osvMaxAttempts = 4
+	osvBackoff     = 500 * time.Millisecond
+
+	// osvMaxRetryAfter caps how long a Retry-After header can make us wait.
+	osvMaxRetryAfter = time.Minute
+
+	// Defaults for the flags that tune OSV access.
+	DefaultConcurrency = 8
+	DefaultRateLimit   = 20
+	DefaultCacheTTL    = 24 * time.Hour
+)
+
+type osvQuery struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Version   string `json:"version"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+// OSVVuln is a full vulnerability record from /v1/vulns/{id}.
+type OSVVuln struct {
+	ID        string        `json:"id"`
+	Summary   string        `json:"summary"`
+	Aliases   []string      `json:"aliases"`
+	Published string        `json:"published"`
+	Modified  string        `json:"modified"`
+	Affected  []OSVAffected `json:"affected"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	References []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"references"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"` // GHSA's CRITICAL/HIGH/MODERATE/LOW rating
+	} `json:"database_specific"`
+}
+
+// OSVAffected is one package an OSV record applies to, with the version
+// ranges and explicit versions it affects.
+type OSVAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string     `json:"type"` // SEMVER, ECOSYSTEM or GIT
+		Events []OSVEvent `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+// OSVEvent is one boundary of an affected range.
+type OSVEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// osvBatchResp only carries IDs: querybatch returns trimmed records, so the
+// details have to be fetched separately for any hits.
+type osvBatchResp struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+		NextPageToken string `json:"next_page_token"`
+	} `json:"results"`
+}
+
+func newOSVQuery(d Package) osvQuery {
+	var q osvQuery
+	q.Package.Ecosystem = d.Ecosystem
+	q.Package.Name = d.Name
+	q.Version = d.Version
+	return q
+}
+
+// Source finds the vulnerabilities affecting dependencies: the OSV API,
+// the GitHub Advisory Database or deps.dev when online, or a downloaded
+// copy of the OSV database when offline. Whatever the source, the records
+// are in OSV's form; a MultiSource combines several.
+type Source interface {
+	// QueryBatch returns the IDs of the vulnerabilities affecting each dep.
+	QueryBatch(ctx context.Context, deps []Package) ([][]string, error)
+	// FetchVulns returns the full records for ids, with per-ID failures.
+	// Once ctx is done, the IDs not yet fetched fail with its error.
+	FetchVulns(ctx context.Context, ids []string) (map[string]OSVVuln, map[string]error)
+}
+
+// Stages of a scan reported to a ProgressFunc.
+const (
+	ProgressPackages   = "packages"   // looking up which advisories affect each package
+	ProgressAdvisories = "advisories" // fetching the details of those advisories
+)
+
+// ProgressFunc is told how far a stage of a lookup has got: done of total
+// packages or advisories. It may be called from several goroutines at once.
+type ProgressFunc func(stage string, done, total int)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that has lookups made with it report
+// to fn instead of their Source's own ProgressFunc, so that scans running
+// side by side on one Source can each report their own progress.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFor returns the ProgressFunc ctx carries, or fallback.
+func progressFor(ctx context.Context, fallback ProgressFunc) ProgressFunc {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		return fn
+	}
+	return fallback
+}
+
+// OSVClient talks to the OSV API, running up to concurrency requests at once
+// and never more than the limiter allows.
+type OSVClient struct {
+	url         string
+	client      *http.Client
+	concurrency int
+	limiter     *rateLimiter
+	cache       *Cache
+	progress    ProgressFunc
+
+	// slots holds a token for each request in flight, bounding them at
+	// concurrency across every call, so that lockfiles scanned side by
+	// side share it rather than each getting as many.
+	slots chan struct{}
+
+	cacheHits, cacheMisses, requests, failures atomic.Int64
+}
+
+// OSVStats counts what an OSVClient has done since it was created.
+type OSVStats struct {
+	// CacheHits and CacheMisses count the package lookups and advisories
+	// answered from the cache and those that had to be asked for; without
+	// a cache, everything misses.
+	CacheHits, CacheMisses int64
+
+	// Requests counts the HTTP requests sent to OSV, retries included, and
+	// Failures those that failed: network errors and error statuses.
+	Requests, Failures int64
+}
+
+// Stats returns the client's counts so far. It is safe to call while
+// lookups are running.
+func (c *OSVClient) Stats() OSVStats {
+	return OSVStats{
+		CacheHits:   c.cacheHits.Load(),
+		CacheMisses: c.cacheMisses.Load(),
+		Requests:    c.requests.Load(),
+		Failures:    c.failures.Load(),
+	}
+}
+
+// OSVClientOptions configures an OSVClient. The zero value talks to the
+// public API one request at a time, without rate limiting or caching.
+type OSVClientOptions struct {
+	// URL is the base URL of an OSV-compatible API, such as a self-hosted
+	// mirror. Empty means DefaultOSVURL.
+	URL string
+
+	// HTTPClient sends the requests. Nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	Concurrency int     // maximum requests in flight
+	RateLimit   float64 // maximum requests per second (0 = unlimited)
+	Cache       *Cache  // may be nil
+
+	// Progress, if set, is told as packages are looked up and advisories
+	// fetched.
+	Progress ProgressFunc
+}
+
+// NewOSVClient returns a client configured by opts.
+func NewOSVClient(opts OSVClientOptions) *OSVClient {
+	c := &OSVClient{
+		url:         strings.TrimSuffix(opts.URL, "/"),
+		client:      opts.HTTPClient,
+		concurrency: max(opts.Concurrency, 1),
+		limiter:     newRateLimiter(opts.RateLimit),
+		cache:       opts.Cache,
+		progress:    opts.Progress,
+	}
+	c.slots = make(chan struct{}, c.concurrency)
+	if c.url == "" {
+		c.url = DefaultOSVURL
+	}
+	if c.client == nil {
+		c.client = http.DefaultClient
+	}
+	return c
+}
+
+// QueryBatch returns the vulnerability IDs affecting each dep (indexed like
+// deps), answering from the cache where possible and asking OSV for the rest.
+func (c *OSVClient) QueryBatch(ctx context.Context, deps []Package) ([][]string, error) {
+	ids := make([][]string, len(deps))
+
+	var misses []Package
+	var missIdx []int
+	for i, d := range deps {
+		if cached, ok := c.cache.queryIDs(c.url, d); ok {
+			ids[i] = cached
+			continue
+		}
+		misses = append(misses, d)
+		missIdx = append(missIdx, i)
+	}
+
+	if c.cache != nil {
+		slog.Debug("OSV cache", "hits", len(deps)-len(misses), "misses", len(misses))
+	}
+	c.cacheHits.Add(int64(len(deps) - len(misses)))
+	c.cacheMisses.Add(int64(len(misses)))
+	done := newProgressCounter(progressFor(ctx, c.progress), ProgressPackages, len(deps))
+	done.add(len(deps) - len(misses))
+	fetched, err := c.queryRemote(ctx, misses, done)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range missIdx {
+		ids[i] = fetched[j]
+		c.cache.putQueryIDs(c.url, deps[i], fetched[j])
+	}
+	return ids, nil
+}
+
+// queryRemote looks up all deps via /v1/querybatch, in chunks of
+// osvBatchSize. Packages with more hits than fit in one page are followed up
+// with their page token until exhausted.
+func (c *OSVClient) queryRemote(ctx context.Context, deps []Package, done *progressCounter) ([][]string, error) {
+	ids := make([][]string, len(deps))
+	chunks := (len(deps) + osvBatchSize - 1) / osvBatchSize
+	errs := make([]error, chunks)
+
+	// Chunks write to disjoint ranges of ids, so they can run in parallel.
+	RunPool(c.concurrency, chunks, func(n int) {
+		start := n * osvBatchSize
+		end := min(start+osvBatchSize, len(deps))
+		errs[n] = c.queryChunk(ctx, deps, start, end, ids)
+		done.add(end - start)
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (c *OSVClient) queryChunk(ctx context.Context, deps []Package, start, end int, ids [][]string) error {
+	// idx[i] is the position in deps that queries[i] was built from.
+	queries := make([]osvQuery, 0, end-start)
+	idx := make([]int, 0, end-start)
+	for i := start; i < end; i++ {
+		queries = append(queries, newOSVQuery(deps[i]))
+		idx = append(idx, i)
+	}
+
+	for len(queries) > 0 {
+		var resp osvBatchResp
+		if err := c.post(ctx, "/querybatch", map[string]any{"queries": queries}, &resp); err != nil {
+			return err
+		}
+		if len(resp.Results) != len(queries) {
+			return fmt.Errorf("OSV returned %d results for %d queries", len(resp.Results), len(queries))
+		}
+
+		// Re-issue only the queries that have another page.
+		var nextQueries []osvQuery
+		var nextIdx []int
+		for i, r := range resp.Results {
+			for _, v := range r.Vulns {
+				ids[idx[i]] = append(ids[idx[i]], v.ID)
+			}
+			if r.NextPageToken != "" {
+				q := queries[i]
+				q.PageToken = r.NextPageToken
+				nextQueries = append(nextQueries, q)
+				nextIdx = append(nextIdx, idx[i])
+			}
+		}
+		queries, idx = nextQueries, nextIdx
+	}
+	return nil
+}
+
+// FetchVulns fetches the full records for ids in parallel. Failures are
+// reported per ID so one bad advisory doesn't hide the rest.
+func (c *OSVClient) FetchVulns(ctx context.Context, ids []string) (map[string]OSVVuln, map[string]error) {
+	vulns := make([]OSVVuln, len(ids))
+	errs := make([]error, len(ids))
+	done := newProgressCounter(progressFor(ctx, c.progress), ProgressAdvisories, len(ids))
+	RunPool(c.concurrency, len(ids), func(i int) {
+		defer done.add(1)
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			return
+		}
+		body, ok := c.cache.get(cacheBucketVulns, c.url+"\x00"+ids[i])
+		if ok {
+			c.cacheHits.Add(1)
+		} else {
+			c.cacheMisses.Add(1)
+			if body, errs[i] = c.doRaw(ctx, http.MethodGet, "/vulns/"+url.PathEscape(ids[i]), nil); errs[i] != nil {
+				return
+			}
+		}
+		if err := json.Unmarshal(body, &vulns[i]); err != nil {
+			errs[i] = fmt.Errorf("bad OSV response: %w", err)
+			return
+		}
+		if !ok {
+			c.cache.put(cacheBucketVulns, c.url+"\x00"+ids[i], body)
+		}
+	})
+
+	found := make(map[string]OSVVuln, len(ids))
+	failed := map[string]error{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			failed[id] = errs[i]
+			continue
+		}
+		found[id] = vulns[i]
+	}
+	return found, failed
+}
+
+func (c *OSVClient) post(ctx context.Context, path string, in, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, path, payload, out)
+}
+
+func (c *OSVClient) do(ctx context.Context, method, path string, payload []byte, out any) error {
+	body, err := c.doRaw(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("bad OSV response: %w", err)
+	}
+	return nil
+}
+
+// doRaw sends a request and returns the response body, retrying with
+// exponential backoff on network errors, rate limiting (429) and server
+// errors (5xx). A Retry-After header on those responses stretches the wait
+// to what the server asked for. It gives up as soon as ctx is done.
+func (c *OSVClient) doRaw(ctx context.Context, method, path string, payload []byte) ([]byte, error) {
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 0; attempt < osvMaxAttempts; attempt++ {
+		if attempt > 0 {
+			slog.Debug("Retrying OSV request", "path", path, "attempt", attempt+1, "err", lastErr)
+			if err := sleep(ctx, max(osvBackoff<<(attempt-1), retryAfter)); err != nil {
+				return nil, err
+			}
+		}
+		retryAfter = 0
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, c.url+path, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		select {
+		case c.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.requests.Add(1)
+		resp, err := c.client.Do(req)
+		if err != nil {
+			<-c.slots
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			c.failures.Add(1)
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		<-c.slots
+		if err != nil {
+			c.failures.Add(1)
+			lastErr = err
+			continue
+		}
+
+		slog.Debug("OSV request", "method", method, "path", path, "status", resp.StatusCode)
+		if resp.StatusCode != http.StatusOK {
+			c.failures.Add(1)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = fmt.Errorf("OSV %s: %s", path, resp.Status)
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("OSV %s: %s", path, resp.Status)
+		}
+		return body, nil
+	}
+	return nil, fmt.Errorf("giving up after %d attempts: %w", osvMaxAttempts, lastErr)
+}
+
+// parseRetryAfter reads a Retry-After value, either delay-seconds or an
+// HTTP date, capped at osvMaxRetryAfter. Missing or malformed values are 0.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	}
+	return min(max(d, 0), osvMaxRetryAfter)
+}
+
+// RunPool calls fn(0..jobs-1) from at most workers goroutines and waits for
+// all of them to finish.
+func RunPool(workers, jobs int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < jobs; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// rateLimiter spaces calls to wait() at least interval apart. A nil limiter
+// (rate <= 0) never blocks.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, time.Until(slot))
+}
+
+// sleep pauses for d, or until ctx is done, in which case it returns ctx's
+// error.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// affects reports whether the record lists d's version as vulnerable, by
+// explicit version or by evaluating its SEMVER/ECOSYSTEM ranges. GIT ranges
+// are keyed by commit and can't be evaluated against a release version.
+func (v OSVVuln) affects(d Package) bool {
+	for _, a := range v.Affected {
+		if !a.matches(d) {
+			continue
+		}
+		for _, ver := range a.Versions {
+			if ver == d.Version {
+				return true
+			}
+		}
+		// An action referenced by a branch or commit rather than a release
+		// has no place in a range.
+		if _, ok := parseSemver(d.Version); !ok && d.Ecosystem == "GitHub Actions" {
+			continue
+		}
+		for _, r := range a.Ranges {
+			if r.Type == "GIT" {
+				continue
+			}
+			if eventsAffect(d.Ecosystem, r.Events, d.Version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fixedVersion returns the lowest version above d's that the advisory lists as
+// fixed and that none of its ranges still cover, or "" if there is none.
+func (v OSVVuln) fixedVersion(d Package) string {
+	best := ""
+	for _, a := range v.Affected {
+		if !a.matches(d) {
+			continue
+		}
+		for _, r := range a.Ranges {
+			if r.Type == "GIT" {
+				continue
+			}
+			for _, e := range r.Events {
+				if e.Fixed == "" || CompareVersions(d.Ecosystem, e.Fixed, d.Version) <= 0 {
+					continue
+				}
+				if best != "" && CompareVersions(d.Ecosystem, e.Fixed, best) >= 0 {
+					continue
+				}
+				candidate := d
+				candidate.Version = e.Fixed
+				if !v.affects(candidate) {
+					best = e.Fixed
+				}
+			}
+		}
+	}
+	return best
+}
+
+// affectedRanges describes the versions of d's package that v affects, one
+// interval per entry, such as ">= 3.7.0, < 4.17.19". Records that list
+// versions instead of ranges get the versions.
+func (v OSVVuln) affectedRanges(d Package) []string {
+	var out []string
+	for _, a := range v.Affected {
+		if !a.matches(d) {
+			continue
+		}
+		ranged := false
+		for _, r := range a.Ranges {
+			if r.Type == "GIT" {
+				continue
+			}
+			ranged = true
+			introduced, open := "", false
+			for _, e := range r.Events {
+				switch {
+				case e.Introduced != "":
+					if open {
+						out = append(out, versionInterval(introduced, ""))
+					}
+					introduced, open = e.Introduced, true
+				case e.Fixed != "":
+					out = append(out, versionInterval(introduced, "< "+e.Fixed))
+					open = false
+				case e.LastAffected != "":
+					out = append(out, versionInterval(introduced, "<= "+e.LastAffected))
+					open = false
+				case e.Limit != "":
+					out = append(out, versionInterval(introduced, "< "+e.Limit))
+					open = false
+				}
+			}
+			if open {
+				out = append(out, versionInterval(introduced, ""))
+			}
+		}
+		if !ranged && len(a.Versions) > 0 {
+			out = append(out, "= "+strings.Join(a.Versions, ", "))
+		}
+	}
+	return out
+}
+
+func versionInterval(introduced, upper string) string {
+	lower := ""
+	if introduced != "" && introduced != "0" {
+		lower = ">= " + introduced
+	}
+	switch {
+	case lower == "" && upper == "":
+		return "all versions"
+	case lower == "":
+		return upper
+	case upper == "":
+		return lower
+	}
+	return lower + ", " + upper
+}
+
+// matches reports whether an affected entry is about d's package. OSV
+// ecosystems may carry a release suffix ("Debian:12"), as do OS packages,
+// and the releases must agree when both have one; PyPI names aren't always
+// normalized.
+func (a OSVAffected) matches(d Package) bool {
+	eco, release, _ := strings.Cut(a.Package.Ecosystem, ":")
+	pkgEco, pkgRelease, _ := strings.Cut(d.Ecosystem, ":")
+	if eco != pkgEco || release != "" && pkgRelease != "" && release != pkgRelease {
+		return false
+	}
+	if d.Ecosystem == "PyPI" {
+		return normalizePyPIName(a.Package.Name) == d.Name
+	}
+	return a.Package.Name == d.Name
+}
+
+// eventsAffect applies the OSV range evaluation algorithm: walking the events
+// in version order, "introduced" opens a vulnerable range and "fixed",
+// "last_affected" or "limit" close it.
+func eventsAffect(ecosystem string, events []OSVEvent, version string) bool {
+	sorted := append([]OSVEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareEventVersions(ecosystem, sorted[i].version(), sorted[j].version()) < 0
+	})
+
+	affected := false
+	for _, e := range sorted {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || CompareVersions(ecosystem, version, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if CompareVersions(ecosystem, version, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if CompareVersions(ecosystem, version, e.LastAffected) > 0 {
+				affected = false
+			}
+		case e.Limit != "":
+			if CompareVersions(ecosystem, version, e.Limit) >= 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func (e OSVEvent) version() string {
+	return e.Introduced + e.Fixed + e.LastAffected + e.Limit // exactly one is set
+}
+
+// compareEventVersions is compareVersions with "0" (the start of time in OSV
+// ranges) sorting before everything.
+func compareEventVersions(ecosystem, a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "0":
+		return -1
+	case b == "0":
+		return 1
+	}
+	return CompareVersions(ecosystem, a, b)
+}
+
+// progressCounter adds up the work finished by concurrent requests and
+// reports the total to a ProgressFunc, which may be nil.
+type progressCounter struct {
+	mu    sync.Mutex
+	fn    ProgressFunc
+	stage string
+	done  int
+	total int
+}
+
+func newProgressCounter(fn ProgressFunc, stage string, total int) *progressCounter {
+	return &progressCounter{fn: fn, stage: stage, total: total}
+}
+
+func (p *progressCounter) add(n int) {
+	if p.fn == nil || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.fn(p.stage, p.done, p.total)
+}
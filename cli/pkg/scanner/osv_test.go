@@ -0,0 +1,46 @@
+package scanner
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// OS packages carry their distribution's release in the ecosystem, as OSV's
+// records for them do.
+func TestOSVVulnMatchesEcosystemRelease(t *testing.T) {
+	var v OSVVuln
+	err := json.Unmarshal([]byte(`{"id": "DSA-1", "affected": [
+		{"package": {"ecosystem": "Debian:12", "name": "openssl"},
+		 "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "3.0.11-1~deb12u2"}]}]},
+		{"package": {"ecosystem": "Debian:11", "name": "openssl"},
+		 "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"fixed": "1.1.1w-0+deb11u1"}]}]}
+	]}`), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ecosystem, version string
+		affected           bool
+		fixed              string
+		ranges             []string
+	}{
+		{"Debian:12", "3.0.11-1~deb12u1", true, "3.0.11-1~deb12u2", []string{"< 3.0.11-1~deb12u2"}},
+		{"Debian:12", "3.0.11-1~deb12u2", false, "", []string{"< 3.0.11-1~deb12u2"}},
+		{"Debian:11", "1.1.1n-0+deb11u5", true, "1.1.1w-0+deb11u1", []string{"< 1.1.1w-0+deb11u1"}},
+		{"Debian:10", "1.1.1n-0+deb10u6", false, "", nil},
+		{"Ubuntu:22.04:LTS", "3.0.2-0ubuntu1", false, "", nil},
+	}
+	for _, tt := range tests {
+		d := Package{Ecosystem: tt.ecosystem, Name: "openssl", Version: tt.version}
+		if got := v.affects(d); got != tt.affected {
+			t.Errorf("%s %s: affects = %v, want %v", tt.ecosystem, tt.version, got, tt.affected)
+		}
+		if got := v.fixedVersion(d); got != tt.fixed {
+			t.Errorf("%s %s: fixedVersion = %q, want %q", tt.ecosystem, tt.version, got, tt.fixed)
+		}
+		if got := v.affectedRanges(d); !slices.Equal(got, tt.ranges) {
+			t.Errorf("%s %s: affectedRanges = %q, want %q", tt.ecosystem, tt.version, got, tt.ranges)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package scanner
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultOSVExportURL serves the full OSV database as one zip per ecosystem.
+const DefaultOSVExportURL = "https://osv-vulnerabilities.storage.googleapis.com"
+
+// DBDir is where downloaded OSV databases are kept, one <ecosystem>.zip each.
+func DBDir() (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "db"), nil
+}
+
+// DownloadOSVDatabase fetches the export for one ecosystem from exportURL
+// (DefaultOSVExportURL when empty) into dir, replacing any previous copy only
+// once the download has completed. A nil client means http.DefaultClient.
+func DownloadOSVDatabase(client *http.Client, exportURL, dir, ecosystem string) (int64, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if exportURL == "" {
+		exportURL = DefaultOSVExportURL
+	}
+	resp, err := client.Get(strings.TrimSuffix(exportURL, "/") + "/" + url.PathEscape(ecosystem) + "/all.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("downloading %s database: %s", ecosystem, resp.Status)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	n, err := io.Copy(tmp, resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n, os.Rename(tmp.Name(), filepath.Join(dir, ecosystem+".zip"))
+}
+
+// LocalDB answers vulnerability queries from downloaded OSV databases.
+type LocalDB struct {
+	vulns     map[string]OSVVuln
+	byPackage map[string][]string // queryCacheKey without version → IDs
+}
+
+// OpenLocalDB loads the advisories relevant to deps from the databases in
+// dir. Only records mentioning one of the deps' packages are kept in memory.
+func OpenLocalDB(dir string, deps []Package) (*LocalDB, error) {
+	db := &LocalDB{vulns: map[string]OSVVuln{}, byPackage: map[string][]string{}}
+
+	wanted := map[string]bool{}
+	ecosystems := map[string]bool{}
+	for _, d := range deps {
+		if UncoveredEcosystems[d.Ecosystem] {
+			continue // never looked up
+		}
+		wanted[packageKey(d)] = true
+		ecosystems[d.Ecosystem] = true
+	}
+
+	for eco := range ecosystems {
+		path := filepath.Join(dir, eco+".zip")
+		zr, err := zip.OpenReader(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no offline database for %s; run 'keystone db download --ecosystem %s'", eco, eco)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %w", path, err)
+		}
+		err = db.load(zr, wanted)
+		zr.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+	return db, nil
+}
+
+func (db *LocalDB) load(zr *zip.ReadCloser, wanted map[string]bool) error {
+	for _, f := range zr.File {
+		if filepath.Ext(f.Name) != ".json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		var v OSVVuln
+		err = json.NewDecoder(rc).Decode(&v)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		for _, a := range v.Affected {
+			eco, _, _ := strings.Cut(a.Package.Ecosystem, ":")
+			d := Package{Ecosystem: eco, Name: a.Package.Name}
+			if d.Ecosystem == "PyPI" {
+				d.Name = normalizePyPIName(d.Name)
+			}
+			if k := packageKey(d); wanted[k] {
+				db.vulns[v.ID] = v
+				db.byPackage[k] = append(db.byPackage[k], v.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func packageKey(d Package) string {
+	return d.Ecosystem + "\x00" + d.Name
+}
+
+// QueryBatch matches deps against the records loaded from the database.
+func (db *LocalDB) QueryBatch(ctx context.Context, deps []Package) ([][]string, error) {
+	ids := make([][]string, len(deps))
+	for i, d := range deps {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, id := range db.byPackage[packageKey(d)] {
+			if !seen[id] && db.vulns[id].affects(d) {
+				seen[id] = true
+				ids[i] = append(ids[i], id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// FetchVulns looks ids up in the loaded records.
+func (db *LocalDB) FetchVulns(_ context.Context, ids []string) (map[string]OSVVuln, map[string]error) {
+	found := make(map[string]OSVVuln, len(ids))
+	failed := map[string]error{}
+	for _, id := range ids {
+		if v, ok := db.vulns[id]; ok {
+			found[id] = v
+		} else {
+			failed[id] = fmt.Errorf("not in offline database")
+		}
+	}
+	return found, failed
+}
@@ -0,0 +1,435 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Default URLs of the package registries FetchLatestReleases reads, besides
+// DefaultNpmRegistry.
+const (
+	DefaultPyPIURL      = "https://pypi.org"
+	DefaultCratesURL    = "https://crates.io"
+	DefaultGoProxyURL   = "https://proxy.golang.org"
+	DefaultRubyGemsURL  = "https://rubygems.org"
+	DefaultPackagistURL = "https://repo.packagist.org"
+)
+
+// Registries are the URLs of the package registries to read releases from;
+// an empty one means the public registry's.
+type Registries struct {
+	Npm       string
+	PyPI      string
+	Crates    string
+	GoProxy   string
+	RubyGems  string
+	Packagist string
+}
+
+// Releases is what a registry says about the versions of one package.
+type Releases struct {
+	Latest    string               // the latest stable version
+	Published map[string]time.Time // when versions were published, where known
+
+	// Repository is the URL of the package's source repository, where the
+	// registry records it.
+	Repository string
+}
+
+// LastPublished is when the newest version the registry dates was
+// published, or zero if it dates none.
+func (r *Releases) LastPublished() time.Time {
+	var last time.Time
+	for _, t := range r.Published {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// releaseFetchers fetch the releases of a package, at least its latest and
+// the given versions, from a registry, returning errNpmNotFound for a
+// package the registry doesn't have.
+var releaseFetchers = map[string]func(client *http.Client, regs Registries, name string, versions []string) (*Releases, error){
+	"npm":       fetchNpmLatest,
+	"PyPI":      fetchPyPILatest,
+	"crates.io": fetchCratesLatest,
+	"Go":        fetchGoLatest,
+	"RubyGems":  fetchRubyGemsLatest,
+	"Packagist": fetchPackagistLatest,
+}
+
+// OutdatedEcosystems are the ecosystems whose registries FetchLatestReleases
+// can read.
+var OutdatedEcosystems = []string{"npm", "PyPI", "crates.io", "Go", "RubyGems", "Packagist"}
+
+// FetchLatestReleases looks up the releases of each package in deps of an
+// ecosystem in OutdatedEcosystems, keyed "ecosystem/name". Packages the
+// registry doesn't have are left out; the error is the first failure, the
+// other packages being looked up regardless.
+func FetchLatestReleases(client *http.Client, regs Registries, deps []Package) (map[string]*Releases, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	type pkg struct{ ecosystem, name string }
+	wanted := map[string]pkg{}
+	versions := map[string][]string{}
+	for _, d := range deps {
+		if releaseFetchers[d.Ecosystem] == nil {
+			continue
+		}
+		key := d.Ecosystem + "/" + d.Name
+		wanted[key] = pkg{d.Ecosystem, d.Name}
+		if !containsString(versions[key], d.Version) {
+			versions[key] = append(versions[key], d.Version)
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, npmResolveConcurrency)
+		out      = map[string]*Releases{}
+		firstErr error
+	)
+	for _, key := range sortedKeys(wanted) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p := wanted[key]
+			rel, err := releaseFetchers[p.ecosystem](client, regs, p.name, versions[key])
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if !errors.Is(err, errNpmNotFound) && firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			out[key] = rel
+		}(key)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// Outdated is a locked package compared to the latest release of it.
+type Outdated struct {
+	Ecosystem string
+	Name      string
+	Version   string
+	Latest    string
+	Direct    bool
+	Dev       bool
+
+	// Published is when Version was published, if the registry says.
+	Published time.Time
+
+	// MajorsBehind is how many major versions Latest is ahead of Version.
+	// Below 1.0.0, where every minor release may break, minor versions
+	// count instead.
+	MajorsBehind int
+}
+
+// IsOutdated reports whether a newer version than the locked one has been
+// released.
+func (o Outdated) IsOutdated() bool {
+	return o.Latest != "" && CompareVersions(o.Ecosystem, o.Version, o.Latest) < 0
+}
+
+// AgeDays is how many whole days before now Version was published, or -1
+// when that isn't known.
+func (o Outdated) AgeDays(now time.Time) int {
+	if o.Published.IsZero() {
+		return -1
+	}
+	return int(now.Sub(o.Published) / (24 * time.Hour))
+}
+
+// CheckOutdated compares each package in deps, once per version, with the
+// latest release of it in releases. Packages without releases are left out.
+func CheckOutdated(deps []Package, releases map[string]*Releases) []Outdated {
+	var out []Outdated
+	seen := map[string]bool{}
+	for _, d := range deps {
+		rel := releases[d.Ecosystem+"/"+d.Name]
+		key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+		if rel == nil || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, Outdated{
+			Ecosystem: d.Ecosystem, Name: d.Name, Version: d.Version, Latest: rel.Latest,
+			Direct: d.Direct, Dev: d.Dev, Published: rel.Published[d.Version],
+			MajorsBehind: majorsBehind(d.Version, rel.Latest),
+		})
+	}
+	return out
+}
+
+// majorsBehind counts the major versions from v to latest; see
+// Outdated.MajorsBehind.
+func majorsBehind(v, latest string) int {
+	a, b := versionNumbers(v), versionNumbers(latest)
+	switch {
+	case b[0] > a[0]:
+		return int(b[0] - a[0])
+	case a[0] == 0 && b[0] == 0 && b[1] > a[1]:
+		return int(b[1] - a[1])
+	}
+	return 0
+}
+
+// versionNumbers returns the major and minor numbers of v, 0 where it has
+// none.
+func versionNumbers(v string) [2]uint64 {
+	var n [2]uint64
+	for i, t := range versionTokens(v) {
+		x, err := strconv.ParseUint(t, 10, 64)
+		if i == len(n) || err != nil {
+			break
+		}
+		n[i] = x
+	}
+	return n
+}
+
+// isPrerelease reports whether v is an alpha, beta, release candidate or
+// development version rather than a stable release.
+func isPrerelease(v string) bool {
+	for _, t := range versionTokens(v) {
+		if !unicode.IsDigit(rune(t[0])) && !postReleaseTags[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// latestStable returns the newest of versions that isn't a pre-release, or
+// "" if there's none.
+func latestStable(ecosystem string, versions []string) string {
+	latest := ""
+	for _, v := range versions {
+		if !isPrerelease(v) && (latest == "" || CompareVersions(ecosystem, v, latest) > 0) {
+			latest = v
+		}
+	}
+	return latest
+}
+
+// getRegistryJSON fetches u from a package registry and decodes it into v.
+func getRegistryJSON(client *http.Client, registry, name, u string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "keystone") // crates.io refuses requests without one
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", registry, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return errNpmNotFound
+	default:
+		return fmt.Errorf("%s returned %s for %s", registry, resp.Status, name)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: bad %s metadata: %w", name, registry, err)
+	}
+	return nil
+}
+
+func registryURL(u, def string) string {
+	if u == "" {
+		u = def
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// fetchNpmLatest reads the "latest" dist-tag of an npm package.
+func fetchNpmLatest(client *http.Client, regs Registries, name string, _ []string) (*Releases, error) {
+	doc, err := fetchNpmDocument(client, registryURL(regs.Npm, DefaultNpmRegistry), name)
+	if err != nil {
+		return nil, err
+	}
+	rel := &Releases{Latest: doc.DistTags["latest"], Published: map[string]time.Time{}, Repository: doc.repositoryURL()}
+	for v, ts := range doc.Time {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil && v != "created" && v != "modified" {
+			rel.Published[v] = t
+		}
+	}
+	if rel.Latest == "" {
+		rel.Latest = latestStable("npm", sortedKeys(rel.Published))
+	}
+	return rel, nil
+}
+
+// fetchPyPILatest reads a project's JSON metadata from PyPI, a version
+// being published when its first file was uploaded.
+func fetchPyPILatest(client *http.Client, regs Registries, name string, _ []string) (*Releases, error) {
+	var doc struct {
+		Info struct {
+			Version     string            `json:"version"`
+			HomePage    string            `json:"home_page"`
+			ProjectURLs map[string]string `json:"project_urls"`
+		} `json:"info"`
+		Releases map[string][]struct {
+			Uploaded time.Time `json:"upload_time_iso_8601"`
+		} `json:"releases"`
+	}
+	if err := getRegistryJSON(client, "PyPI", name, registryURL(regs.PyPI, DefaultPyPIURL)+"/pypi/"+url.PathEscape(name)+"/json", &doc); err != nil {
+		return nil, err
+	}
+	rel := &Releases{Latest: doc.Info.Version, Published: map[string]time.Time{}}
+	// Projects label their links freely; take the first on GitHub.
+	urls := []string{doc.Info.HomePage}
+	for _, label := range sortedKeys(doc.Info.ProjectURLs) {
+		urls = append(urls, doc.Info.ProjectURLs[label])
+	}
+	for _, u := range urls {
+		if rel.Repository == "" && githubRepoName(u) != "" {
+			rel.Repository = u
+		}
+	}
+	for v, files := range doc.Releases {
+		for _, f := range files {
+			if t, ok := rel.Published[v]; !ok || f.Uploaded.Before(t) {
+				rel.Published[v] = f.Uploaded
+			}
+		}
+	}
+	return rel, nil
+}
+
+// fetchCratesLatest reads a crate's metadata from the crates.io API.
+func fetchCratesLatest(client *http.Client, regs Registries, name string, _ []string) (*Releases, error) {
+	var doc struct {
+		Crate struct {
+			MaxStable  string `json:"max_stable_version"`
+			Max        string `json:"max_version"`
+			Repository string `json:"repository"`
+		} `json:"crate"`
+		Versions []struct {
+			Num     string    `json:"num"`
+			Created time.Time `json:"created_at"`
+		} `json:"versions"`
+	}
+	if err := getRegistryJSON(client, "crates.io", name, registryURL(regs.Crates, DefaultCratesURL)+"/api/v1/crates/"+url.PathEscape(name), &doc); err != nil {
+		return nil, err
+	}
+	rel := &Releases{Latest: doc.Crate.MaxStable, Published: map[string]time.Time{}, Repository: doc.Crate.Repository}
+	if rel.Latest == "" {
+		rel.Latest = doc.Crate.Max
+	}
+	for _, v := range doc.Versions {
+		rel.Published[v.Num] = v.Created
+	}
+	return rel, nil
+}
+
+// fetchGoLatest asks a Go module proxy for a module's latest version and
+// for when each locked version was published. Only versions of the same
+// major module path are seen: example.com/m/v2 is another module. Versions
+// are without their "v", as the go.mod parser records them.
+func fetchGoLatest(client *http.Client, regs Registries, name string, versions []string) (*Releases, error) {
+	base := registryURL(regs.GoProxy, DefaultGoProxyURL) + "/" + escapeModulePath(name) + "/@"
+	var info struct {
+		Version string    `json:"Version"`
+		Time    time.Time `json:"Time"`
+	}
+	if err := getRegistryJSON(client, "Go module proxy", name, base+"latest", &info); err != nil {
+		return nil, err
+	}
+	latest := strings.TrimPrefix(info.Version, "v")
+	rel := &Releases{Latest: latest, Published: map[string]time.Time{latest: info.Time}, Repository: "https://" + name}
+	for _, v := range versions {
+		if _, ok := rel.Published[v]; ok {
+			continue
+		}
+		if err := getRegistryJSON(client, "Go module proxy", name, base+"v/"+escapeModulePath("v"+strings.TrimPrefix(v, "v"))+".info", &info); err == nil {
+			rel.Published[v] = info.Time
+		}
+	}
+	return rel, nil
+}
+
+// escapeModulePath escapes a module path or version for a module proxy,
+// which writes each upper-case letter as "!" and its lower case.
+func escapeModulePath(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// fetchRubyGemsLatest reads a gem's versions from the RubyGems API.
+func fetchRubyGemsLatest(client *http.Client, regs Registries, name string, _ []string) (*Releases, error) {
+	var doc []struct {
+		Number     string    `json:"number"`
+		Created    time.Time `json:"created_at"`
+		Prerelease bool      `json:"prerelease"`
+	}
+	if err := getRegistryJSON(client, "RubyGems", name, registryURL(regs.RubyGems, DefaultRubyGemsURL)+"/api/v1/versions/"+url.PathEscape(name)+".json", &doc); err != nil {
+		return nil, err
+	}
+	rel := &Releases{Published: map[string]time.Time{}}
+	for _, v := range doc {
+		// A version built for several platforms is listed once for each.
+		if t, ok := rel.Published[v.Number]; !ok || v.Created.Before(t) {
+			rel.Published[v.Number] = v.Created
+		}
+		if !v.Prerelease && (rel.Latest == "" || CompareVersions("RubyGems", v.Number, rel.Latest) > 0) {
+			rel.Latest = v.Number
+		}
+	}
+	return rel, nil
+}
+
+// fetchPackagistLatest reads a Composer package's tagged releases from
+// Packagist's metadata.
+func fetchPackagistLatest(client *http.Client, regs Registries, name string, _ []string) (*Releases, error) {
+	var doc struct {
+		Packages map[string][]struct {
+			Version string    `json:"version"`
+			Time    time.Time `json:"time"`
+			Source  struct {
+				URL string `json:"url"`
+			} `json:"source"`
+		} `json:"packages"`
+	}
+	if err := getRegistryJSON(client, "Packagist", name, registryURL(regs.Packagist, DefaultPackagistURL)+"/p2/"+name+".json", &doc); err != nil {
+		return nil, err
+	}
+	rel := &Releases{Published: map[string]time.Time{}}
+	for _, v := range doc.Packages[name] {
+		if rel.Repository == "" {
+			rel.Repository = v.Source.URL
+		}
+		rel.Published[v.Version] = v.Time
+		// composer.lock records "v1.2.3" for a tag of that name, as
+		// Packagist does, but OSV and some lockfiles drop the "v".
+		rel.Published[strings.TrimPrefix(v.Version, "v")] = v.Time
+	}
+	rel.Latest = latestStable("Packagist", sortedKeys(rel.Published))
+	return rel, nil
+}
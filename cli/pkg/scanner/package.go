@@ -0,0 +1,28 @@
+package scanner
+
+// Package is one dependency read from a lockfile or SBOM.
+type Package struct {
+	Ecosystem string // OSV ecosystem name, e.g. "npm" or "Go"
+	Name      string
+	Version   string
+
+	// Optional metadata, only filled in by parsers whose lockfile records it.
+	License  string   // SPDX license expression
+	Direct   bool     // declared by the project itself rather than pulled in transitively
+	Dev      bool     // only needed for development, not at runtime
+	Requires []string // "name@version" of the packages this one depends on
+
+	// InstallScript is set for npm packages that run a preinstall, install
+	// or postinstall script when installed.
+	InstallScript bool
+
+	// Unpinned is set for GitHub Actions and Terraform modules referenced
+	// by something that can be moved to different code, such as a tag, a
+	// branch or a version range, rather than by a commit SHA or exact
+	// version.
+	Unpinned bool
+
+	// Integrity is the Subresource Integrity hash of the package's archive,
+	// such as "sha512-…", as recorded by an npm lockfile.
+	Integrity string
+}
@@ -0,0 +1,123 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// customLockfile is a kind of lockfile added with RegisterLockfile.
+type customLockfile struct {
+	kind     LockfileKind
+	patterns []string
+}
+
+// customLockfiles are checked before the built-in file names, so that a
+// plugin can take over one of them too.
+var customLockfiles []customLockfile
+
+// RegisterLockfile adds a kind of lockfile keystone doesn't know, such as a
+// proprietary manifest format: files whose base name matches any of
+// patterns (in filepath.Match syntax, e.g. "*.deps") are that kind and are
+// parsed by p. They are found by directory scans and recognised wherever a
+// lockfile is. Registering a kind again replaces it. It must not be called
+// concurrently with parsing.
+func RegisterLockfile(kind LockfileKind, patterns []string, p LockfileParser) error {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad file name pattern %q for %s lockfiles: %w", pattern, kind, err)
+		}
+	}
+	kept := customLockfiles[:0]
+	for _, c := range customLockfiles {
+		if c.kind != kind {
+			kept = append(kept, c)
+		}
+	}
+	customLockfiles = append(kept, customLockfile{kind: kind, patterns: patterns})
+	parsers[kind] = p
+	return nil
+}
+
+// customLockfileByName returns the registered kind whose patterns match
+// path's base name.
+func customLockfileByName(path string) (LockfileKind, bool) {
+	base := filepath.Base(path)
+	for _, c := range customLockfiles {
+		for _, pattern := range c.patterns {
+			if ok, _ := filepath.Match(pattern, base); ok {
+				return c.kind, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ExecParser is a LockfileParser that runs an external program, so that
+// lockfile formats can be added without rebuilding keystone. The program
+// is given the lockfile's path as its last argument and its contents on
+// stdin, and writes the packages it finds to stdout as JSON:
+//
+//	{"packages": [{"ecosystem": "npm", "name": "lodash", "version": "4.17.21",
+//	  "direct": true, "dev": false, "license": "MIT", "requires": ["..."]}]}
+//
+// Only ecosystem, name and version are required; the ecosystem is OSV's
+// name for it. A non-zero exit status fails the parse with whatever the
+// program wrote to stderr.
+type ExecParser struct {
+	Command []string // the program and any arguments of its own
+}
+
+// execPackage is a Package as ExecParser's protocol writes it.
+type execPackage struct {
+	Ecosystem string   `json:"ecosystem"`
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	License   string   `json:"license"`
+	Direct    bool     `json:"direct"`
+	Dev       bool     `json:"dev"`
+	Requires  []string `json:"requires"`
+}
+
+// Parse runs the program on the lockfile at path.
+func (p ExecParser) Parse(path string, data []byte) ([]Package, error) {
+	if len(p.Command) == 0 {
+		return nil, fmt.Errorf("no parser command given")
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(p.Command[0], append(p.Command[1:], path)...)
+	c.Stdin = bytes.NewReader(data)
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", p.Command[0], msg)
+		}
+		return nil, fmt.Errorf("%s: %w", p.Command[0], err)
+	}
+
+	var out struct {
+		Packages []execPackage `json:"packages"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("%s: bad output: %w", p.Command[0], err)
+	}
+	pkgs := make([]Package, 0, len(out.Packages))
+	for i, x := range out.Packages {
+		if x.Ecosystem == "" || x.Name == "" {
+			return nil, fmt.Errorf("%s: package %d has no ecosystem or name", p.Command[0], i+1)
+		}
+		pkgs = append(pkgs, Package{
+			Ecosystem: x.Ecosystem,
+			Name:      x.Name,
+			Version:   x.Version,
+			License:   x.License,
+			Direct:    x.Direct,
+			Dev:       x.Dev,
+			Requires:  x.Requires,
+		})
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractPnpmPackages reads the "packages" section of a pnpm-lock.yaml.
+//
+// Each entry key encodes the package name and version, with a format that
+// has changed over the lockfile versions:
+//
+//	v5:   /@babel/core/7.12.3_supports-color@8.1.1:
+//	v6:   /@babel/core@7.12.3(supports-color@8.1.1):
+//	v9:   '@babel/core@7.12.3':
+//
+// Peer-dependency suffixes ("_..." in v5, "(...)" from v6) are stripped, so
+// the same package resolved against different peers is only reported once.
+// Entries that carry explicit "name"/"version" fields (tarball and git
+// dependencies) use those instead of the key. Aliased keys of the form
+// "alias@npm:real@1.0.0" are mapped back to the real package name. Lockfiles
+// before v9 flag development-only packages with "dev: true".
+//
+// The project's direct dependencies are those its importers list (or, in
+// lockfiles of a single project before v9, the top-level "dependencies",
+// "devDependencies" and "optionalDependencies"), as either
+//
+//	lodash: 4.17.15               (v5)
+//	lodash:                       (v6+)
+//	  specifier: ^4.17.15
+//	  version: 4.17.15
+func extractPnpmPackages(data []byte) []Package {
+	var (
+		out       []Package
+		seen      = map[string]int{} // name@version → index in out
+		legacy    bool               // lockfileVersion 5.x: "/name/version" keys
+		section   string
+		key       string
+		name, ver string
+		dev       bool
+
+		direct   = map[string]bool{} // name@version of direct dependencies
+		depBlock bool                // in a list of direct dependencies
+		depName  string              // direct dependency whose fields are being read
+	)
+
+	addDirect := func(name, version string) {
+		version = unquote(strings.TrimSpace(version))
+		version, _, _ = strings.Cut(version, "(")
+		if legacy {
+			version, _, _ = strings.Cut(version, "_")
+		}
+		// Aliases give the real package: "/real/1.0.0" (v5) or "real@1.0.0".
+		if version != "" && (version[0] < '0' || version[0] > '9') {
+			name, version = pnpmKeyNameVersion(version, strings.HasPrefix(version, "/"))
+		}
+		if name != "" && version != "" && version[0] >= '0' && version[0] <= '9' {
+			direct[name+"@"+version] = true
+		}
+	}
+
+	flush := func() {
+		if key == "" {
+			return
+		}
+		n, v := name, ver
+		if n == "" || v == "" {
+			kn, kv := pnpmKeyNameVersion(key, legacy)
+			if n == "" {
+				n = kn
+			}
+			if v == "" {
+				v = kv
+			}
+		}
+		// Skip link:, file:, URLs and anything else that isn't a plain version.
+		if n == "" || v == "" || v[0] < '0' || v[0] > '9' {
+			return
+		}
+		id := n + "@" + v
+		if i, ok := seen[id]; ok {
+			// Needed at runtime if any of its peer variants is.
+			out[i].Dev = out[i].Dev && dev
+			return
+		}
+		seen[id] = len(out)
+		out = append(out, Package{Ecosystem: "npm", Name: n, Version: v, Dev: dev})
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if indent == 0 {
+			flush()
+			key = ""
+			section = strings.TrimSuffix(trimmed, ":")
+			if v, ok := strings.CutPrefix(trimmed, "lockfileVersion:"); ok {
+				legacy = strings.HasPrefix(unquote(v), "5")
+			}
+			depBlock = pnpmDependencyList(section)
+			continue
+		}
+		if section == "importers" || pnpmDependencyList(section) {
+			// Dependency names sit at 2 spaces at the top level, and at 6
+			// under an importer's path and list.
+			base := 2
+			if section == "importers" {
+				base = 6
+				switch indent {
+				case 2:
+					depBlock = false
+					continue
+				case 4:
+					depBlock = pnpmDependencyList(strings.TrimSuffix(trimmed, ":"))
+					continue
+				}
+			}
+			k, v, _ := strings.Cut(trimmed, ":")
+			switch {
+			case !depBlock:
+			case indent == base:
+				depName = unquote(k)
+				if strings.TrimSpace(v) != "" {
+					addDirect(depName, v)
+				}
+			case indent == base+2 && k == "version":
+				addDirect(depName, v)
+			}
+			continue
+		}
+		if section != "packages" {
+			continue
+		}
+
+		switch indent {
+		case 2:
+			flush()
+			key = unquote(strings.TrimSuffix(strings.TrimSuffix(trimmed, " {}"), ":"))
+			name, ver, dev = "", "", false
+		case 4:
+			k, v, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				continue
+			}
+			switch k {
+			case "name":
+				name = unquote(v)
+			case "version":
+				ver = unquote(v)
+			case "dev":
+				dev = strings.TrimSpace(v) == "true"
+			}
+		}
+	}
+	flush()
+
+	for i := range out {
+		out[i].Direct = direct[out[i].Name+"@"+out[i].Version]
+	}
+	return out
+}
+
+// pnpmDependencyList reports whether a pnpm-lock.yaml key names a list of
+// the project's own dependencies.
+func pnpmDependencyList(key string) bool {
+	return key == "dependencies" || key == "devDependencies" || key == "optionalDependencies"
+}
+
+// pnpmKeyNameVersion splits a "packages" key into name and version.
+func pnpmKeyNameVersion(key string, legacy bool) (name, version string) {
+	key = strings.TrimPrefix(unquote(key), "/")
+
+	if legacy {
+		// "name/version_peers" or "@scope/name/version_peers".
+		segs := strings.Split(key, "/")
+		n := 1
+		if strings.HasPrefix(key, "@") {
+			n = 2
+		}
+		if len(segs) != n+1 {
+			return "", ""
+		}
+		version, _, _ = strings.Cut(segs[n], "_")
+		return strings.Join(segs[:n], "/"), version
+	}
+
+	key, _, _ = strings.Cut(key, "(")
+	i := strings.LastIndex(key, "@")
+	if i <= 0 {
+		return "", ""
+	}
+	name, version = key[:i], key[i+1:]
+	if _, real, ok := strings.Cut(name, "@npm:"); ok {
+		name = real
+	}
+	return name, version
+}
@@ -0,0 +1,699 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Policy rule actions: a deny rule fails the scan, a warn rule only reports.
+const (
+	PolicyDeny = "deny"
+	PolicyWarn = "warn"
+)
+
+// Policy rule subjects: a vuln rule is evaluated against every advisory
+// affecting a package, a package rule against every scanned package, and a
+// maintenance rule against every package CheckMaintenance reports.
+const (
+	PolicyVuln        = "vuln"
+	PolicyPackage     = "package"
+	PolicyMaintenance = "maintenance"
+)
+
+// policyFields lists what a rule's expression can refer to, per subject.
+var policyFields = map[string]map[string]string{
+	PolicyVuln: {
+		"id":             "advisory ID, e.g. \"GHSA-p6mc-m468-83gw\"",
+		"aliases":        "other IDs of the advisory, e.g. its CVE",
+		"summary":        "advisory summary",
+		"severity":       "CRITICAL, HIGH, MEDIUM, LOW or UNKNOWN; compares by rank",
+		"cvss":           "CVSS base score",
+		"fixed":          "whether a fixed version is known",
+		"fixed_version":  "version that fixes the advisory",
+		"age":            "time since the advisory was published",
+		"epss":           "EPSS probability of exploitation (0-1), with --epss",
+		"kev":            "whether CISA's KEV catalog lists it, with --kev",
+		"package":        "affected package name",
+		"version":        "affected package version",
+		"ecosystem":      "OSV ecosystem of the package",
+		"dev":            "whether the package is a development dependency",
+		"workspaces":     "workspaces that depend on the package",
+		"dependency_len": "length of the dependency path to the package; 0 for direct or unknown",
+	},
+	PolicyPackage: {
+		"name":        "package name",
+		"version":     "package version",
+		"ecosystem":   "OSV ecosystem of the package",
+		"license":     "SPDX license expression, if the lockfile records it",
+		"dev":         "whether the package is a development dependency",
+		"direct":      "whether the project declares the package itself",
+		"vulnerable":  "whether any advisory affects the package",
+		"release_age": "time since the version was published (npm only)",
+		"deprecated":  "whether the maintainers have deprecated the version (npm only)",
+		"scorecard":   "OpenSSF Scorecard score (0-10) of the package's GitHub repository, for direct dependencies",
+		"dependents":  "number of packages depending on the version, by deps.dev, for direct dependencies",
+	},
+	PolicyMaintenance: {
+		"name":             "package name",
+		"version":          "package version",
+		"ecosystem":        "OSV ecosystem of the package",
+		"dev":              "whether the package is a development dependency",
+		"direct":           "whether the project declares the package itself",
+		"archived":         "whether the package's GitHub repository is archived",
+		"stale":            "whether nothing has been released for longer than --stale-years",
+		"last_publish_age": "time since the package's latest release",
+		"repository":       "the package's GitHub repository, as \"owner/repo\"",
+	},
+}
+
+// PolicyFields returns the fields a rule about subject can refer to, with a
+// description of each.
+func PolicyFields(subject string) map[string]string {
+	return policyFields[subject]
+}
+
+// Policy is a set of rules, each a boolean expression evaluated against a
+// scan report's advisories or packages:
+//
+//	# Block critical advisories left unfixed for a month.
+//	deny vuln "stale-critical": severity >= "critical" && age > 30d && fixed
+//	# Hold back releases too new to have been vetted.
+//	deny package "too-fresh": release_age < 48h
+//	warn package "copyleft": license matches "^(A|L)?GPL"
+//	deny maintenance "abandoned": archived && direct
+//	deny package "weak-practices": direct && scorecard < 4
+//
+// Expressions combine fields (see PolicyFields) and literals — numbers,
+// "strings", true, false, durations such as 30d, 48h or 15m, and [lists] —
+// with == != < <= > >= in matches, && || ! and parentheses. A comparison
+// with a field whose value isn't known, such as the age of an advisory
+// with no publication date, is false.
+type Policy struct {
+	Rules []PolicyRule
+}
+
+// PolicyRule is one line of a policy.
+type PolicyRule struct {
+	Action  string // PolicyDeny or PolicyWarn
+	Subject string // PolicyVuln, PolicyPackage or PolicyMaintenance
+	Name    string
+	Expr    string
+	Line    int
+
+	expr policyExpr
+}
+
+// PolicyViolation is a rule matching an advisory or package.
+type PolicyViolation struct {
+	Rule      string `json:"rule"`
+	Action    string `json:"action"`
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	ID        string `json:"id,omitempty"` // the advisory, for vuln rules
+}
+
+// LoadPolicy reads a policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePolicy(path, f)
+}
+
+// policyRuleLine matches `action subject "name": expression`.
+var policyRuleLine = regexp.MustCompile(`^(\w+)\s+(\w+)\s+"([^"]+)"\s*:\s*(.+)$`)
+
+// ParsePolicy parses policy rules, one per line; blank lines and lines
+// starting with # are skipped. name is used in error messages.
+func ParsePolicy(name string, r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := policyRuleLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("%s:%d: expected `deny|warn vuln|package|maintenance \"name\": expression`", name, n)
+		}
+		rule := PolicyRule{Action: m[1], Subject: m[2], Name: m[3], Expr: m[4], Line: n}
+		if rule.Action != PolicyDeny && rule.Action != PolicyWarn {
+			return nil, fmt.Errorf("%s:%d: unknown action %q (want deny or warn)", name, n, rule.Action)
+		}
+		if policyFields[rule.Subject] == nil {
+			return nil, fmt.Errorf("%s:%d: unknown subject %q (want vuln, package or maintenance)", name, n, rule.Subject)
+		}
+		expr, err := parsePolicyExpr(rule.Expr, policyFields[rule.Subject])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		rule.expr = expr
+		p.Rules = append(p.Rules, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Uses reports whether any rule refers to field, so that data only some
+// policies need, like release dates, is only fetched for them.
+func (p *Policy) Uses(field string) bool {
+	for _, r := range p.Rules {
+		if r.expr.uses(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether any rule is about subject, so that, like Uses, the
+// data only rules about it need is only fetched for them.
+func (p *Policy) Covers(subject string) bool {
+	for _, r := range p.Rules {
+		if r.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluatePolicy records on each of r's reports the violations of p's rules
+// by its unsuppressed advisories, its packages and its maintenance risks.
+// releases, from FetchNpmReleases, give release_age and deprecated, and
+// Scorecards recorded by ApplyScorecards give scorecard and insights
+// recorded by ApplyInsights dependents; now is the time ages are measured
+// to.
+func EvaluatePolicy(r *Report, p *Policy, releases map[string]NpmRelease, now time.Time) {
+	for _, rep := range r.Reports() {
+		rep.PolicyViolations = nil
+		vulnerable := map[string]bool{}
+		for _, f := range rep.Findings {
+			vulnerable[f.Ecosystem+"/"+f.Package+"@"+f.Version] = true
+			for _, v := range f.Vulns {
+				subject := vulnPolicySubject(f, v, now)
+				for _, rule := range p.Rules {
+					if rule.Subject == PolicyVuln && truthy(rule.expr.eval(subject)) {
+						rep.PolicyViolations = append(rep.PolicyViolations, PolicyViolation{
+							Rule: rule.Name, Action: rule.Action, Ecosystem: f.Ecosystem, Package: f.Package, Version: f.Version, ID: v.ID,
+						})
+					}
+				}
+			}
+		}
+		seen := map[string]bool{}
+		scores := scorecardByPackage(rep)
+		dependents := dependentsByPackage(rep)
+		for _, d := range rep.Packages {
+			key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			subject := map[string]any{
+				"name": d.Name, "version": d.Version, "ecosystem": d.Ecosystem, "license": d.License,
+				"dev": d.Dev, "direct": d.Direct, "vulnerable": vulnerable[key],
+			}
+			if rel, ok := releases[key]; ok {
+				subject["release_age"] = now.Sub(rel.Published)
+				subject["deprecated"] = rel.Deprecated != ""
+			}
+			if score, ok := scores[key]; ok {
+				subject["scorecard"] = score
+			}
+			if n, ok := dependents[key]; ok {
+				subject["dependents"] = float64(n)
+			}
+			for _, rule := range p.Rules {
+				if rule.Subject == PolicyPackage && truthy(rule.expr.eval(subject)) {
+					rep.PolicyViolations = append(rep.PolicyViolations, PolicyViolation{
+						Rule: rule.Name, Action: rule.Action, Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version,
+					})
+				}
+			}
+		}
+		for _, m := range rep.Maintenance {
+			subject := map[string]any{
+				"name": m.Package, "version": m.Version, "ecosystem": m.Ecosystem, "dev": m.Dev, "direct": m.Direct,
+				"archived": m.Archived, "stale": m.Stale, "repository": m.Repository,
+			}
+			if m.LastPublished != nil {
+				subject["last_publish_age"] = now.Sub(*m.LastPublished)
+			}
+			for _, rule := range p.Rules {
+				if rule.Subject == PolicyMaintenance && truthy(rule.expr.eval(subject)) {
+					rep.PolicyViolations = append(rep.PolicyViolations, PolicyViolation{
+						Rule: rule.Name, Action: rule.Action, Ecosystem: m.Ecosystem, Package: m.Package, Version: m.Version,
+					})
+				}
+			}
+		}
+	}
+}
+
+func vulnPolicySubject(f Finding, v Vulnerability, now time.Time) map[string]any {
+	subject := map[string]any{
+		"id": v.ID, "aliases": stringList(v.Aliases), "summary": v.Summary,
+		"severity": policySeverity(v.Severity), "fixed": v.Fixed != "", "fixed_version": v.Fixed,
+		"kev": v.KEV != nil, "package": f.Package, "version": f.Version, "ecosystem": f.Ecosystem,
+		"dev": f.Dev, "workspaces": stringList(f.Workspaces), "dependency_len": float64(len(f.Path)),
+	}
+	if v.Score > 0 {
+		subject["cvss"] = v.Score
+	}
+	if v.EPSS != nil {
+		subject["epss"] = v.EPSS.Score
+	}
+	if t, err := time.Parse(time.RFC3339, v.Published); err == nil {
+		subject["age"] = now.Sub(t)
+	}
+	return subject
+}
+
+func stringList(ss []string) []any {
+	out := make([]any, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
+
+// PolicyDenials counts the violations of deny rules.
+func (r *Report) PolicyDenials() int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, v := range p.PolicyViolations {
+			if v.Action == PolicyDeny {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+/********** expressions **********/
+
+// policySeverity is a severity label, which compares with others, and with
+// strings, by rank.
+type policySeverity string
+
+// policyExpr is a node of a parsed expression. eval returns a bool,
+// float64, string, policySeverity, time.Duration, []any, or nil for a field
+// whose value isn't known.
+type policyExpr interface {
+	eval(subject map[string]any) any
+	uses(field string) bool
+}
+
+type policyLiteral struct{ v any }
+
+func (e policyLiteral) eval(map[string]any) any { return e.v }
+func (e policyLiteral) uses(string) bool        { return false }
+
+type policyField struct{ name string }
+
+func (e policyField) eval(s map[string]any) any { return s[e.name] }
+func (e policyField) uses(f string) bool        { return e.name == f }
+
+type policyList struct{ items []policyExpr }
+
+func (e policyList) eval(s map[string]any) any {
+	out := make([]any, len(e.items))
+	for i, it := range e.items {
+		out[i] = it.eval(s)
+	}
+	return out
+}
+
+func (e policyList) uses(f string) bool {
+	for _, it := range e.items {
+		if it.uses(f) {
+			return true
+		}
+	}
+	return false
+}
+
+type policyNot struct{ x policyExpr }
+
+func (e policyNot) eval(s map[string]any) any { return !truthy(e.x.eval(s)) }
+func (e policyNot) uses(f string) bool        { return e.x.uses(f) }
+
+type policyBinary struct {
+	op   string
+	l, r policyExpr
+	re   *regexp.Regexp // for "matches" with a literal pattern
+}
+
+func (e policyBinary) uses(f string) bool { return e.l.uses(f) || e.r.uses(f) }
+
+func (e policyBinary) eval(s map[string]any) any {
+	switch e.op {
+	case "&&":
+		return truthy(e.l.eval(s)) && truthy(e.r.eval(s))
+	case "||":
+		return truthy(e.l.eval(s)) || truthy(e.r.eval(s))
+	}
+	l, r := e.l.eval(s), e.r.eval(s)
+	if l == nil || r == nil {
+		return e.op == "!=" && (l != nil || r != nil)
+	}
+	switch e.op {
+	case "in":
+		list, _ := r.([]any)
+		for _, item := range list {
+			if c, ok := comparePolicyValues(l, item); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	case "matches":
+		str, ok := l.(string)
+		if !ok {
+			if sev, isSev := l.(policySeverity); isSev {
+				str, ok = string(sev), true
+			}
+		}
+		if !ok {
+			return false
+		}
+		re := e.re
+		if re == nil {
+			pattern, _ := r.(string)
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(str)
+	}
+	c, ok := comparePolicyValues(l, r)
+	if !ok {
+		return e.op == "!="
+	}
+	switch e.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// comparePolicyValues orders two values of the same type; ok is false for
+// values that don't compare.
+func comparePolicyValues(a, b any) (c int, ok bool) {
+	// A severity compares with a string naming one.
+	if _, ok := a.(policySeverity); ok {
+		if str, ok := b.(string); ok {
+			b = policySeverity(strings.ToUpper(str))
+		}
+	}
+	if _, ok := b.(policySeverity); ok {
+		if str, ok := a.(string); ok {
+			a = policySeverity(strings.ToUpper(str))
+		}
+	}
+	switch a := a.(type) {
+	case policySeverity:
+		b, ok := b.(policySeverity)
+		return cmpInt(severityRank(normalizePolicySeverity(a)), severityRank(normalizePolicySeverity(b))), ok
+	case string:
+		b, ok := b.(string)
+		return strings.Compare(a, b), ok
+	case float64:
+		b, ok := b.(float64)
+		return cmpFloat(a, b), ok
+	case time.Duration:
+		b, ok := b.(time.Duration)
+		return cmpInt(int(a/time.Second), int(b/time.Second)), ok
+	case bool:
+		b, ok := b.(bool)
+		if a == b {
+			return 0, ok
+		}
+		return 1, ok
+	}
+	return 0, false
+}
+
+// normalizePolicySeverity reads GHSA's MODERATE as MEDIUM.
+func normalizePolicySeverity(s policySeverity) string {
+	if s == "MODERATE" {
+		return SeverityMedium
+	}
+	return string(s)
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func truthy(v any) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+/********** parser **********/
+
+// policyToken is a lexical token of an expression: an operator or
+// punctuation, an identifier, or a literal with its value.
+type policyToken struct {
+	text string
+	lit  any // set for literals
+}
+
+// policyDuration matches duration literals: a number and d, h, m or s.
+var policyDuration = regexp.MustCompile(`^(\d+(?:\.\d+)?)([dhms])$`)
+
+func lexPolicy(src string) ([]policyToken, error) {
+	var toks []policyToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, policyToken{text: src[i : j+1], lit: b.String()})
+			i = j + 1
+		case strings.ContainsRune("()[],!<>=&|", rune(c)):
+			op := string(c)
+			if i+1 < len(src) {
+				if two := src[i : i+2]; two == "&&" || two == "||" || two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					op = two
+				}
+			}
+			if op == "&" || op == "|" || op == "=" {
+				return nil, fmt.Errorf("unexpected %q (did you mean %q?)", op, op+op)
+			}
+			toks = append(toks, policyToken{text: op})
+			i += len(op)
+		default:
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_' || src[j] == '.') {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", string(c))
+			}
+			word := src[i:j]
+			tok := policyToken{text: word}
+			switch {
+			case word == "true" || word == "false":
+				tok.lit = word == "true"
+			case unicode.IsDigit(rune(word[0])):
+				if m := policyDuration.FindStringSubmatch(word); m != nil {
+					n, _ := strconv.ParseFloat(m[1], 64)
+					unit := map[string]time.Duration{"d": 24 * time.Hour, "h": time.Hour, "m": time.Minute, "s": time.Second}[m[2]]
+					tok.lit = time.Duration(n * float64(unit))
+				} else if n, err := strconv.ParseFloat(word, 64); err == nil {
+					tok.lit = n
+				} else {
+					return nil, fmt.Errorf("bad number %q", word)
+				}
+			}
+			toks = append(toks, tok)
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// policyParser is a recursive-descent parser:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | compare
+//	compare = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" | "matches" ) operand ]
+//	operand = literal | field | "(" or ")" | "[" [ operand { "," operand } ] "]"
+type policyParser struct {
+	toks   []policyToken
+	pos    int
+	fields map[string]string
+}
+
+func parsePolicyExpr(src string, fields map[string]string) (policyExpr, error) {
+	toks, err := lexPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &policyParser{toks: toks, fields: fields}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return e, nil
+}
+
+func (p *policyParser) peek() string {
+	if p.pos < len(p.toks) && p.toks[p.pos].lit == nil {
+		return p.toks[p.pos].text
+	}
+	return ""
+}
+
+func (p *policyParser) or() (policyExpr, error) {
+	l, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var r policyExpr
+		if r, err = p.and(); err == nil {
+			l = policyBinary{op: "||", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *policyParser) and() (policyExpr, error) {
+	l, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var r policyExpr
+		if r, err = p.unary(); err == nil {
+			l = policyBinary{op: "&&", l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *policyParser) unary() (policyExpr, error) {
+	if p.peek() == "!" {
+		p.pos++
+		x, err := p.unary()
+		return policyNot{x}, err
+	}
+	return p.compare()
+}
+
+func (p *policyParser) compare() (policyExpr, error) {
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=", "<", "<=", ">", ">=", "in", "matches":
+		p.pos++
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		e := policyBinary{op: op, l: l, r: r}
+		if lit, ok := r.(policyLiteral); ok && op == "matches" {
+			pattern, isStr := lit.v.(string)
+			if !isStr {
+				return nil, fmt.Errorf("matches needs a \"pattern\"")
+			}
+			if e.re, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+			}
+		}
+		return e, nil
+	}
+	return l, nil
+}
+
+func (p *policyParser) operand() (policyExpr, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.toks[p.pos]
+	p.pos++
+	if tok.lit != nil {
+		return policyLiteral{tok.lit}, nil
+	}
+	switch tok.text {
+	case "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, nil
+	case "[":
+		var list policyList
+		for p.peek() != "]" {
+			if len(list.items) > 0 {
+				if p.peek() != "," {
+					return nil, fmt.Errorf("expected , or ] in list")
+				}
+				p.pos++
+			}
+			item, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+		}
+		p.pos++
+		return list, nil
+	}
+	if _, ok := p.fields[tok.text]; ok {
+		return policyField{tok.text}, nil
+	}
+	if tok.text != "" && (unicode.IsLetter(rune(tok.text[0])) || tok.text[0] == '_') {
+		return nil, fmt.Errorf("unknown field %q", tok.text)
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
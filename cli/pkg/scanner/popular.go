@@ -0,0 +1,50 @@
+package scanner
+
+// popularPackages are among the most downloaded packages of each ecosystem,
+// the names typosquatters imitate. The lists needn't be complete: a package
+// one edit away from one of them is worth a look, whatever else it's near.
+// Popular packages that happen to be one edit from others ("preact" and
+// "react", "scapy" and "scipy") are listed so as not to be taken for
+// typosquats.
+var popularPackages = map[string][]string{
+	"npm": {
+		"@babel/core", "@types/node", "@types/react", "angular", "async", "axios",
+		"babel-core", "bcrypt", "bluebird", "body-parser", "boxen", "chalk",
+		"cheerio", "chokidar", "classnames", "color", "colors", "commander", "compression",
+		"concurrently", "cookie-parser", "cors", "cross-env", "cross-spawn",
+		"crypto-js", "date-fns", "dayjs", "debug", "dotenv", "ejs", "electron",
+		"eslint", "esbuild", "event-stream", "eventemitter3", "execa", "express",
+		"fast-glob", "fs-extra", "glob", "got", "graphql", "gulp", "handlebars",
+		"helmet", "husky", "immer", "inquirer", "jest", "jquery", "js-yaml",
+		"jsonwebtoken", "knex", "lodash", "lodash.merge", "minimatch", "minimist",
+		"mkdirp", "mocha", "moment", "mongodb", "mongoose", "morgan", "multer",
+		"mysql", "mysql2", "nanoid", "next", "node-fetch", "node-sass", "nodemailer",
+		"nodemon", "npm", "nuxt", "ora", "passport", "pg", "postcss", "preact", "prettier",
+		"prop-types", "puppeteer", "qs", "ramda", "react", "react-dom",
+		"react-redux", "react-router", "react-router-dom", "redis", "redux",
+		"request", "rimraf", "rollup", "rxjs", "sass", "semver", "sequelize",
+		"sharp", "socket.io", "styled-components", "supertest", "svelte",
+		"tailwindcss", "through", "through2", "tslib", "tslint", "typescript", "ua-parser-js",
+		"underscore", "uuid", "validator", "vite", "vue", "webpack", "webpack-cli",
+		"winston", "ws", "yargs", "zod",
+	},
+	"PyPI": {
+		"aiohttp", "attrs", "beautifulsoup4", "boto", "boto3", "botocore", "certifi",
+		"cffi", "charset-normalizer", "click", "colorama", "cryptography",
+		"django", "fastapi", "flask", "grpcio", "idna", "jinja", "jinja2", "jmespath",
+		"lxml", "markupsafe", "matplotlib", "numpy", "packaging", "pandas",
+		"pillow", "pip", "protobuf", "psycopg2", "pyasn1", "pycparser",
+		"pydantic", "pyjwt", "pytest", "python-dateutil", "pytz", "pyaml", "pyyaml",
+		"requests", "rsa", "s3transfer", "scapy", "scikit-learn", "scipy", "setuptools",
+		"six", "sqlalchemy", "tensorflow", "torch", "tqdm", "typing-extensions",
+		"urllib3", "wheel",
+	},
+	"RubyGems": {
+		"activesupport", "bundler", "devise", "json", "nokogiri", "rack", "rails",
+		"rake", "rspec", "sidekiq",
+	},
+	"crates.io": {
+		"anyhow", "clap", "rand", "regex", "reqwest", "serde", "serde_json",
+		"syn", "tokio",
+	},
+}
@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractPubspecLock reads the "packages" map of a Dart or Flutter
+// pubspec.lock:
+//
+//	packages:
+//	  http:
+//	    dependency: "direct main"
+//	    description:
+//	      name: http
+//	      url: "https://pub.dev"
+//	    source: hosted
+//	    version: "1.1.0"
+//
+// Only hosted packages are kept: SDK packages such as flutter ship with the
+// SDK, and git and path packages have no published version to look up.
+// "direct dev" packages are marked Dev; the lockfile doesn't record which
+// packages depend on which, so transitive ones aren't.
+func extractPubspecLock(data []byte) []Package {
+	var (
+		out     []Package
+		inPkgs  bool
+		name    string
+		fields  map[string]string
+		flushed = map[string]bool{}
+	)
+	flush := func() {
+		if name == "" || fields["source"] != "hosted" || fields["version"] == "" {
+			return
+		}
+		n := firstNonEmpty(fields["name"], name)
+		if flushed[n+"@"+fields["version"]] {
+			return
+		}
+		flushed[n+"@"+fields["version"]] = true
+		dep := fields["dependency"]
+		out = append(out, Package{
+			Ecosystem: "Pub",
+			Name:      n,
+			Version:   fields["version"],
+			Direct:    strings.HasPrefix(dep, "direct"),
+			Dev:       dep == "direct dev",
+		})
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent == 0 {
+			flush()
+			name = ""
+			inPkgs = trimmed == "packages:"
+			continue
+		}
+		if !inPkgs || (indent > 2 && name == "") {
+			continue
+		}
+		key, value, _ := strings.Cut(trimmed, ":")
+		value = unquote(strings.TrimSpace(value))
+		switch indent {
+		case 2:
+			flush()
+			name, fields = unquote(key), map[string]string{}
+		case 4:
+			fields[key] = value
+		case 6:
+			if key == "name" { // description.name of a hosted package
+				fields["name"] = value
+			}
+		}
+	}
+	flush()
+	return out
+}
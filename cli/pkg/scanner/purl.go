@@ -0,0 +1,109 @@
+package scanner
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// purlTypes maps OSV ecosystems to package-url types.
+var purlTypes = map[string]string{
+	"npm":       "npm",
+	"Go":        "golang",
+	"PyPI":      "pypi",
+	"crates.io": "cargo",
+	"Maven":     "maven",
+	"RubyGems":  "gem",
+	"Packagist": "composer",
+	"NuGet":     "nuget",
+	"Pub":       "pub",
+	"JSR":       "jsr",
+	"SwiftURL":  "swift",
+
+	"GitHub Actions": "githubactions",
+}
+
+// Ecosystems returns the OSV ecosystems keystone supports, sorted.
+func Ecosystems() []string {
+	out := make([]string, 0, len(purlTypes))
+	for eco := range purlTypes {
+		out = append(out, eco)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PURL returns the package URL (https://github.com/package-url/purl-spec)
+// for a dependency, e.g. "pkg:npm/%40babel/core@7.12.3".
+func PURL(d Package) string {
+	typ, ok := purlTypes[d.Ecosystem]
+	if !ok {
+		typ = strings.ToLower(d.Ecosystem)
+	}
+
+	version := d.Version
+	if typ == "golang" {
+		version = "v" + version // Go module versions keep their "v" in purls
+	}
+
+	// Namespace segments are separated by "/"; Maven uses "group:artifact".
+	segments := strings.Split(strings.Replace(d.Name, ":", "/", 1), "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+	}
+	return "pkg:" + typ + "/" + strings.Join(segments, "/") + "@" + url.PathEscape(version)
+}
+
+// ParsePURL turns a package URL back into a dependency. Qualifiers and
+// subpaths are ignored, and purl types without an OSV ecosystem are rejected.
+func ParsePURL(p string) (Package, error) {
+	rest, ok := strings.CutPrefix(p, "pkg:")
+	if !ok {
+		return Package{}, fmt.Errorf("not a package URL: %q", p)
+	}
+	rest, _, _ = strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+
+	typ, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return Package{}, fmt.Errorf("package URL has no name: %q", p)
+	}
+	typ = strings.ToLower(typ)
+	ecosystem := ""
+	for eco, t := range purlTypes {
+		if t == typ {
+			ecosystem = eco
+		}
+	}
+	if ecosystem == "" {
+		return Package{}, fmt.Errorf("unsupported package URL type %q", typ)
+	}
+
+	path, version, _ := strings.Cut(path, "@")
+	if version, ok = unescapePURL(version); !ok {
+		return Package{}, fmt.Errorf("malformed package URL: %q", p)
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if segments[i], ok = unescapePURL(s); !ok {
+			return Package{}, fmt.Errorf("malformed package URL: %q", p)
+		}
+	}
+
+	name := strings.Join(segments, "/")
+	switch ecosystem {
+	case "Maven":
+		name = strings.Join(segments, ":")
+	case "Go":
+		version = strings.TrimPrefix(version, "v")
+	case "PyPI":
+		return pypiDep(name, version), nil
+	}
+	return Package{Ecosystem: ecosystem, Name: name, Version: version}, nil
+}
+
+func unescapePURL(s string) (string, bool) {
+	u, err := url.PathUnescape(s)
+	return u, err == nil
+}
@@ -0,0 +1,260 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// extractRequirements reads pinned ("==" / "===") requirements from a pip
+// requirements file, following "-r"/"-c" includes relative to the file.
+// Unpinned requirements are skipped: without a resolver there's no concrete
+// version to ask OSV about.
+//
+// Files compiled by pip-compile say what pulled each requirement in, in
+// "# via" comments; those via a requirements file ("-r requirements.in") or
+// the project's own metadata ("myapp (pyproject.toml)") are direct. Other
+// files don't say, and nothing is marked direct.
+func extractRequirements(path string, data []byte, seen map[string]bool) ([]Package, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		if seen[abs] {
+			return nil, nil
+		}
+		seen[abs] = true
+	}
+
+	var out []Package
+	last, via := -1, false // the requirement "# via" comments are about, and whether in one
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var logical strings.Builder
+	for sc.Scan() {
+		// Join backslash-continued lines (common with --hash pins).
+		line := sc.Text()
+		if strings.HasSuffix(line, `\`) {
+			logical.WriteString(strings.TrimSuffix(line, `\`) + " ")
+			continue
+		}
+		logical.WriteString(line)
+		line = logical.String()
+		logical.Reset()
+
+		comment := ""
+		if i := strings.Index(line, " #"); i >= 0 {
+			line, comment = line[:i], line[i+2:]
+		}
+		line = strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(line, "#"); ok {
+			line, comment = "", rest
+		}
+		if line == "" {
+			via = markViaProject(out, last, via, comment)
+			continue
+		}
+		last, via = -1, false
+
+		if fields := strings.Fields(line); fields[0] == "-r" || fields[0] == "--requirement" ||
+			fields[0] == "-c" || fields[0] == "--constraint" {
+			if len(fields) < 2 {
+				continue
+			}
+			inc := fields[1]
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(path), inc)
+			}
+			incData, err := os.ReadFile(inc)
+			if err != nil {
+				return nil, fmt.Errorf("reading included requirements %s: %w", inc, err)
+			}
+			nested, err := extractRequirements(inc, incData, seen)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, nested...)
+			continue
+		}
+		if strings.HasPrefix(line, "-") {
+			continue // other pip options (--index-url, -e, ...)
+		}
+
+		if d, ok := parseRequirement(line); ok {
+			out = append(out, d)
+			last = len(out) - 1
+			via = markViaProject(out, last, false, comment)
+		}
+	}
+	return out, nil
+}
+
+// markViaProject reads a comment after out[last], a requirement pip-compile
+// wrote, marking the requirement direct if the comment says it's there for
+// the project itself: "via -r requirements.in", or under a "via" line, an
+// indented entry such as "myapp (pyproject.toml)". via is whether an
+// earlier comment line opened a list of entries; so is the result.
+func markViaProject(out []Package, last int, via bool, comment string) bool {
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(comment), "via"); ok && (rest == "" || rest[0] == ' ') {
+		via, comment = true, rest
+	} else if !strings.HasPrefix(comment, "  ") {
+		return false
+	}
+	if !via || last < 0 {
+		return via
+	}
+	for _, from := range strings.Split(comment, ",") {
+		from = strings.TrimSpace(from)
+		if strings.HasPrefix(from, "-r ") || strings.HasSuffix(from, "(pyproject.toml)") ||
+			strings.HasSuffix(from, "(setup.py)") || strings.HasSuffix(from, "(setup.cfg)") {
+			out[last].Direct = true
+		}
+	}
+	return via
+}
+
+// requirementPin matches "name[extras] == version" ahead of any environment
+// marker or pip option.
+var requirementPin = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*===?\s*([^\s;,]+)`)
+
+func parseRequirement(line string) (Package, bool) {
+	m := requirementPin.FindStringSubmatch(line)
+	if m == nil || strings.Contains(m[2], "*") {
+		return Package{}, false
+	}
+	return pypiDep(m[1], m[2]), true
+}
+
+// extractPoetryPackages reads the [[package]] tables of a poetry.lock.
+func extractPoetryPackages(data []byte) []Package {
+	var out []Package
+	for _, pkg := range tomlArrayTables(data, "package") {
+		if pkg["name"] == "" || pkg["version"] == "" {
+			continue
+		}
+		d := pypiDep(pkg["name"], pkg["version"])
+		d.Dev = pkg["category"] == "dev" // Poetry < 1.5; later lockfiles don't say
+		out = append(out, d)
+	}
+	return out
+}
+
+type pipfileLock struct {
+	Default map[string]pipfileEntry `json:"default"`
+	Develop map[string]pipfileEntry `json:"develop"`
+}
+
+type pipfileEntry struct {
+	Version string `json:"version"`
+}
+
+// extractPipfileLock reads both the "default" and "develop" sections of a
+// Pipfile.lock, marking the latter as dev dependencies. Entries without a
+// version are VCS/path installs.
+func extractPipfileLock(data []byte) ([]Package, error) {
+	var lock pipfileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	out := make([]Package, 0, len(lock.Default)+len(lock.Develop))
+	for i, section := range []map[string]pipfileEntry{lock.Default, lock.Develop} {
+		for name, e := range section {
+			if v := strings.TrimLeft(e.Version, "="); v != "" {
+				d := pypiDep(name, v)
+				d.Dev = i == 1
+				out = append(out, d)
+			}
+		}
+	}
+	return out, nil
+}
+
+// pypiDep builds a PyPI dependency with a PEP 503 normalized name and a
+// PEP 440 normalized version, the forms OSV records use.
+func pypiDep(name, version string) Package {
+	return Package{
+		Ecosystem: "PyPI",
+		Name:      normalizePyPIName(name),
+		Version:   normalizePEP440(version),
+	}
+}
+
+var pypiNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+func normalizePyPIName(name string) string {
+	return strings.ToLower(pypiNameSeparators.ReplaceAllString(name, "-"))
+}
+
+// pep440Version mirrors the VERSION_PATTERN regex from pypa/packaging.
+var pep440Version = regexp.MustCompile(`^v?` +
+	`(?:(\d+)!)?` + // epoch
+	`(\d+(?:\.\d+)*)` + // release
+	`(?:[-_.]?(a|b|c|rc|alpha|beta|pre|preview)[-_.]?(\d+)?)?` + // pre-release
+	`(?:-(\d+)|[-_.]?(post|rev|r)[-_.]?(\d+)?)?` + // post-release
+	`(?:[-_.]?(dev)[-_.]?(\d+)?)?` + // dev release
+	`(?:\+([a-z0-9]+(?:[-_.][a-z0-9]+)*))?$`) // local version
+
+// normalizePEP440 rewrites a version into its PEP 440 canonical form, e.g.
+// "1.0-Alpha.1" → "1.0a1", "2.01-r2" → "2.1.post2". Versions that don't
+// parse are returned unchanged (lowercased and trimmed).
+func normalizePEP440(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	m := pep440Version.FindStringSubmatch(v)
+	if m == nil {
+		return v
+	}
+
+	var b strings.Builder
+	if m[1] != "" && m[1] != "0" {
+		b.WriteString(trimLeadingZeros(m[1]) + "!")
+	}
+	parts := strings.Split(m[2], ".")
+	for i, p := range parts {
+		parts[i] = trimLeadingZeros(p)
+	}
+	b.WriteString(strings.Join(parts, "."))
+
+	if m[3] != "" {
+		switch m[3] {
+		case "alpha":
+			b.WriteString("a")
+		case "beta":
+			b.WriteString("b")
+		case "c", "pre", "preview":
+			b.WriteString("rc")
+		default:
+			b.WriteString(m[3])
+		}
+		b.WriteString(numOrZero(m[4]))
+	}
+	switch {
+	case m[5] != "":
+		b.WriteString(".post" + trimLeadingZeros(m[5]))
+	case m[6] != "":
+		b.WriteString(".post" + numOrZero(m[7]))
+	}
+	if m[8] != "" {
+		b.WriteString(".dev" + numOrZero(m[9]))
+	}
+	if m[10] != "" {
+		b.WriteString("+" + strings.NewReplacer("-", ".", "_", ".").Replace(m[10]))
+	}
+	return b.String()
+}
+
+func trimLeadingZeros(s string) string {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return s
+}
+
+func numOrZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return trimLeadingZeros(s)
+}
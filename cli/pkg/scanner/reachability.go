@@ -0,0 +1,344 @@
+package scanner
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Reachability values of a Finding.
+const (
+	Reachable    = "reachable"
+	NotReachable = "not reachable"
+)
+
+// unreachableReason is the reason recorded on findings HideUnreachable
+// suppresses.
+const unreachableReason = "not reachable from the project's sources"
+
+// jsSourceExts are the extensions of the files whose imports are read.
+// Vue and Svelte components are included for their script blocks.
+var jsSourceExts = map[string]bool{
+	".js": true, ".mjs": true, ".cjs": true, ".jsx": true,
+	".ts": true, ".mts": true, ".cts": true, ".tsx": true,
+	".vue": true, ".svelte": true,
+}
+
+// maxJSSourceSize skips files too large to be hand-written, such as bundles,
+// whose imports say little about the project's own.
+const maxJSSourceSize = 4 << 20
+
+var (
+	// import x from "m", import {a, b} from "m", import "m"; group 1 is
+	// set for TypeScript's type-only imports, which are erased at runtime.
+	jsImportPattern = regexp.MustCompile(`\bimport\s+(type\s+)?(?:[\w$*{}\s,]+?\s+from\s*)?["']([^"'\n]+)["']`)
+	// export * from "m", export {a} from "m"
+	jsExportPattern = regexp.MustCompile(`\bexport\s+(type\s+)?(?:\*(?:\s+as\s+[\w$]+)?|\{[^}]*\})\s*from\s*["']([^"'\n]+)["']`)
+	// require("m"), import("m")
+	jsRequirePattern = regexp.MustCompile(`\b(?:require|import)\s*\(\s*["']([^"'\n]+)["']\s*\)`)
+)
+
+// ImportGraph records which npm packages a project's JavaScript and
+// TypeScript sources import, directly and, through the packages installed
+// under node_modules, transitively.
+type ImportGraph struct {
+	// Imports maps each package the project's own sources import to the
+	// first file, relative to the project, that imports it.
+	Imports map[string]string
+
+	// Installed is set when node_modules was there to follow imports
+	// through; Reached is then every "name@version" installed package
+	// whose code the sources can load, mapped to the file the chain of
+	// imports starts in.
+	Installed bool
+	Reached   map[string]string
+}
+
+// BuildImportGraph reads the imports of the JavaScript and TypeScript
+// sources under projectDir, outside node_modules and hidden directories,
+// then follows them through the packages installed under node_modules the
+// way Node resolves them. Only imports of string literals are seen: a
+// require of a computed name is missed.
+func BuildImportGraph(projectDir string) (*ImportGraph, error) {
+	g := &ImportGraph{Imports: map[string]string{}, Reached: map[string]string{}}
+	err := filepath.WalkDir(projectDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != projectDir && (name == "node_modules" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isJSSource(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(projectDir, path)
+		for _, name := range jsSourceImports(path) {
+			if _, ok := g.Imports[name]; !ok {
+				g.Imports[name] = filepath.ToSlash(rel)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filepath.Join(projectDir, "node_modules")); err != nil {
+		return g, nil
+	}
+	g.Installed = true
+
+	// Breadth first, so each package is credited to the source file with
+	// the shortest chain of imports to it.
+	type step struct{ dir, from string }
+	var queue []step
+	for _, name := range sortedKeys(g.Imports) {
+		if dir := resolveNodePackage(projectDir, name); dir != "" {
+			queue = append(queue, step{dir, g.Imports[name]})
+		}
+	}
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if visited[s.dir] {
+			continue
+		}
+		visited[s.dir] = true
+		m, err := readPackageJSON(filepath.Join(s.dir, "package.json"))
+		if err != nil || m.Name == "" {
+			continue
+		}
+		if key := m.Name + "@" + m.Version; g.Reached[key] == "" {
+			g.Reached[key] = s.from
+		}
+		for _, name := range packageImports(s.dir) {
+			if name == m.Name {
+				continue
+			}
+			if dir := resolveNodePackage(s.dir, name); dir != "" && !visited[dir] {
+				queue = append(queue, step{dir, s.from})
+			}
+		}
+	}
+	return g, nil
+}
+
+// ApplyReachability marks each of r's npm findings Reachable or
+// NotReachable from the sources g was built from. With node_modules
+// installed a package is reachable when a chain of imports leads to it.
+// Without, a package is taken to be reachable when the direct dependency
+// that pulls it in is imported; findings whose direct dependency the
+// lockfile doesn't record are left unmarked.
+func ApplyReachability(r *Report, g *ImportGraph) {
+	direct := map[string]bool{}
+	for _, p := range r.Packages {
+		if p.Direct {
+			direct[p.Name] = true
+		}
+	}
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		if f.Ecosystem != "npm" {
+			continue
+		}
+		if g.Installed {
+			f.Reachability, f.ImportedFrom = NotReachable, g.Reached[f.Package+"@"+f.Version]
+		} else {
+			head := f.Package
+			if len(f.Path) > 0 {
+				head = packageOfPathEntry(f.Path[0])
+			} else if !direct[f.Package] {
+				continue
+			}
+			f.Reachability, f.ImportedFrom = NotReachable, g.Imports[head]
+		}
+		if f.ImportedFrom != "" {
+			f.Reachability = Reachable
+		}
+	}
+}
+
+// HideUnreachable moves the advisories of findings ApplyReachability marked
+// NotReachable to r.Suppressed.
+func HideUnreachable(r *Report) {
+	kept := r.Findings[:0]
+	for _, f := range r.Findings {
+		if f.Reachability != NotReachable {
+			kept = append(kept, f)
+			continue
+		}
+		for _, v := range f.Vulns {
+			r.Suppressed = append(r.Suppressed, Suppression{
+				Ecosystem: f.Ecosystem,
+				Package:   f.Package,
+				Version:   f.Version,
+				ID:        v.ID,
+				Severity:  v.Severity,
+				Rule:      IgnoreRule{Pattern: v.ID, Reason: unreachableReason},
+			})
+		}
+	}
+	r.Findings = kept
+}
+
+func isJSSource(path string) bool {
+	if strings.HasSuffix(path, ".d.ts") || strings.HasSuffix(path, ".d.mts") || strings.HasSuffix(path, ".d.cts") {
+		return false // declarations only
+	}
+	return jsSourceExts[filepath.Ext(path)]
+}
+
+// packageImports returns the packages the files of the installed package
+// at dir import, leaving out its own node_modules.
+func packageImports(dir string) []string {
+	seen := map[string]bool{}
+	var out []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isJSSource(path) {
+			return nil
+		}
+		for _, name := range jsSourceImports(path) {
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+		return nil
+	})
+	return out
+}
+
+// jsSourceImports returns the npm packages a source file imports, once
+// each, leaving out relative imports, Node built-ins given with "node:" and
+// type-only imports.
+func jsSourceImports(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxJSSourceSize {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	src := stripJSComments(data)
+
+	seen := map[string]bool{}
+	var out []string
+	add := func(specifier string) {
+		if name := importedPackage(specifier); name != "" && !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	for _, p := range []*regexp.Regexp{jsImportPattern, jsExportPattern} {
+		for _, m := range p.FindAllSubmatch(src, -1) {
+			if len(m[1]) == 0 {
+				add(string(m[2]))
+			}
+		}
+	}
+	for _, m := range jsRequirePattern.FindAllSubmatch(src, -1) {
+		add(string(m[1]))
+	}
+	return out
+}
+
+// importedPackage returns the package an import specifier loads from, such
+// as "lodash" for "lodash/fp" or "@babel/core" for "@babel/core/lib/x", or
+// "" for relative paths, URLs and "node:" built-ins.
+func importedPackage(specifier string) string {
+	if specifier == "" || strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") || strings.Contains(specifier, ":") {
+		return ""
+	}
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") {
+		if len(parts) < 2 {
+			return ""
+		}
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// packageOfPathEntry returns the name of a Finding.Path entry, "name@version".
+func packageOfPathEntry(entry string) string {
+	if i := strings.LastIndex(entry, "@"); i > 0 {
+		return entry[:i]
+	}
+	return entry
+}
+
+// resolveNodePackage finds the directory Node loads the package name from
+// for a file in dir: node_modules/name in dir or the nearest ancestor that
+// has it. Symlinks, as pnpm and workspaces install packages with, are
+// followed, so that the package's own dependencies resolve from where it
+// really is. It returns "" when the package isn't installed.
+func resolveNodePackage(dir, name string) string {
+	for {
+		candidate := filepath.Join(dir, "node_modules", filepath.FromSlash(name))
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			if real, err := filepath.EvalSymlinks(candidate); err == nil {
+				return real
+			}
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// stripJSComments blanks out the // and /* */ comments of JavaScript source
+// so that commented-out imports aren't counted, leaving string and template
+// literals alone. Regular expression literals aren't recognised, which can
+// only cost an import on the same line as one containing a quote.
+func stripJSComments(src []byte) []byte {
+	out := bytes.Clone(src)
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"' || c == '\'' || c == '`':
+			for i++; i < len(out) && out[i] != c; i++ {
+				if out[i] == '\\' {
+					i++
+				} else if out[i] == '\n' && c != '`' {
+					break // unterminated; don't let it swallow the file
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end >= 0 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// imageRef is a parsed image reference such as "nginx:1.25",
+// "ghcr.io/org/app@sha256:…" or "localhost:5000/app".
+type imageRef struct {
+	registry, repository, reference string
+}
+
+func parseImageRef(ref string) (imageRef, error) {
+	r := imageRef{registry: "registry-1.docker.io", reference: "latest"}
+	name := ref
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, r.reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, r.reference = name[:i], name[i+1:]
+	}
+
+	// The first component is a registry if it looks like a host.
+	if first, rest, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.registry, name = first, rest
+	} else if !strings.Contains(name, "/") {
+		name = "library/" + name // Docker Hub's official images
+	}
+	if name == "" || r.reference == "" {
+		return imageRef{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+	if r.registry == "docker.io" || r.registry == "index.docker.io" {
+		r.registry = "registry-1.docker.io"
+	}
+	r.repository = name
+	return r, nil
+}
+
+// registryClient pulls from one repository of an OCI distribution registry,
+// authenticating anonymously with a bearer token when the registry asks.
+type registryClient struct {
+	http  *http.Client
+	base  string // scheme://host/v2/repository
+	ref   imageRef
+	token string
+}
+
+var manifestMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}, ", ")
+
+// newRegistryClient returns a client for r's repository. Registries on
+// localhost are reached over plain HTTP. A nil client means
+// http.DefaultClient.
+func newRegistryClient(client *http.Client, r imageRef) *registryClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	scheme := "https"
+	if host := strings.Split(r.registry, ":")[0]; host == "localhost" || host == "127.0.0.1" {
+		scheme = "http"
+	}
+	return &registryClient{http: client, base: scheme + "://" + r.registry + "/v2/" + r.repository, ref: r}
+}
+
+// PullImage fetches an image from its registry, reading only the wanted files
+// from each layer. Registries on localhost are reached over plain HTTP. A nil
+// client means http.DefaultClient.
+func PullImage(client *http.Client, ref string) (*Image, error) {
+	r, err := parseImageRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	c := newRegistryClient(client, r)
+	fetch := func(reference string, v any) error {
+		body, err := c.get("/manifests/"+reference, manifestMediaTypes)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		if err := json.NewDecoder(body).Decode(v); err != nil {
+			return fmt.Errorf("bad manifest: %w", err)
+		}
+		return nil
+	}
+	var top ociDocument
+	if err := fetch(r.reference, &top); err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	m, err := resolveOCIManifest(top, fetch)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+
+	var openers []layerOpener
+	for _, l := range m.Layers {
+		digest := l.Digest
+		openers = append(openers, func() (io.ReadCloser, error) {
+			return c.get("/blobs/"+digest, "")
+		})
+	}
+	files, err := flattenLayers(openers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return &Image{Ref: ref, Files: files}, nil
+}
+
+// ImageDigest returns the digest of the manifest ref names, the
+// "sha256:…" that pins it: for a multi-platform image, that of its index.
+func ImageDigest(client *http.Client, ref string) (string, error) {
+	r, err := parseImageRef(ref)
+	if err != nil {
+		return "", err
+	}
+	resp, err := newRegistryClient(client, r).do("/manifests/"+r.reference, manifestMediaTypes)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+	if d := resp.Header.Get("Docker-Content-Digest"); strings.HasPrefix(d, "sha256:") {
+		return d, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ImageTags lists the tags of ref's repository, following the registry's
+// pagination.
+func ImageTags(client *http.Client, ref string) ([]string, error) {
+	r, err := parseImageRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	c := newRegistryClient(client, r)
+	var tags []string
+	path := "/tags/list?n=1000"
+	for page := 0; path != "" && page < 100; page++ {
+		resp, err := c.do(path, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		var list struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad tag list: %w", ref, err)
+		}
+		tags = append(tags, list.Tags...)
+
+		// Link: </v2/library/node/tags/list?last=…&n=1000>; rel="next"
+		path = ""
+		if link := resp.Header.Get("Link"); strings.Contains(link, `rel="next"`) {
+			target, _, _ := strings.Cut(strings.TrimPrefix(link, "<"), ">")
+			path = strings.TrimPrefix(target, "/v2/"+r.repository)
+		}
+	}
+	return tags, nil
+}
+
+// get fetches path under the repository; see do.
+func (c *registryClient) get(path, accept string) (io.ReadCloser, error) {
+	resp, err := c.do(path, accept)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do fetches path under the repository, fetching a token first if the
+// registry answers 401 with a bearer challenge.
+func (c *registryClient) do(path, accept string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if c.token, err = c.fetchToken(challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry %s%s: %s", c.ref.repository, path, resp.Status)
+		}
+		return resp, nil
+	}
+}
+
+// fetchToken answers a `Bearer realm="…",service="…",scope="…"` challenge
+// with an anonymous pull token.
+func (c *registryClient) fetchToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("registry %s requires %s authentication, which isn't supported", c.ref.registry, scheme)
+	}
+	fields := map[string]string{}
+	for _, p := range strings.Split(params, ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok {
+			fields[k] = strings.Trim(v, `"`)
+		}
+	}
+	if fields["realm"] == "" {
+		return "", fmt.Errorf("registry %s sent an authentication challenge without a realm", c.ref.registry)
+	}
+	q := url.Values{}
+	if fields["service"] != "" {
+		q.Set("service", fields["service"])
+	}
+	scope := fields["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.repository + ":pull"
+	}
+	q.Set("scope", scope)
+
+	resp, err := c.http.Get(fields["realm"] + "?" + q.Encode())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching registry token: %s", resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("bad registry token response: %w", err)
+	}
+	if tok.Token == "" {
+		tok.Token = tok.AccessToken
+	}
+	return tok.Token, nil
+}
@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HostedRepo is a repository on a code host such as GitHub.
+type HostedRepo struct {
+	FullName      string // e.g. "acme/web"
+	DefaultBranch string // "" where the host reads the default branch itself
+	Archived      bool
+	Fork          bool
+}
+
+// RepoProvider lists the repositories of an organization on a code host and
+// reads their files through its API, without cloning them.
+type RepoProvider interface {
+	// Name is the host's name, for messages.
+	Name() string
+
+	// OrgRepos lists the repositories of org (an organization, group,
+	// workspace or project, as the host calls it) that the token can see.
+	OrgRepos(ctx context.Context, org string) ([]HostedRepo, error)
+
+	// RepoFiles lists the slash-separated paths of the files on repo's
+	// default branch: none for an empty repository. complete is false when
+	// the host couldn't list them all.
+	RepoFiles(ctx context.Context, repo HostedRepo) (files []string, complete bool, err error)
+
+	// ReadFile fetches the file at path on repo's default branch.
+	ReadFile(ctx context.Context, repo HostedRepo, path string) ([]byte, error)
+}
+
+// hostAPI makes the requests of a RepoProvider to its host's REST API.
+type hostAPI struct {
+	name   string
+	client *http.Client // nil means http.DefaultClient
+	base   string
+	auth   func(req *http.Request)
+}
+
+// tokenAuth authenticates with token as a bearer token, or as a user name
+// and password, such as a Bitbucket app password, when it's "user:secret".
+func tokenAuth(token string) func(req *http.Request) {
+	return func(req *http.Request) {
+		if user, password, ok := strings.Cut(token, ":"); ok {
+			req.SetBasicAuth(user, password)
+		} else if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// get fetches u, a URL or a path under the API's base, returning an error
+// for anything but 200 OK, and saying when the rate limit resets when it's
+// been used up.
+func (a *hostAPI) get(ctx context.Context, u, accept string) (*http.Response, error) {
+	if strings.HasPrefix(u, "/") {
+		u = a.base + u
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if a.auth != nil {
+		a.auth(req)
+	}
+	client := a.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	// GitHub says X-RateLimit-*, GitLab RateLimit-*; both reset at a Unix
+	// time.
+	remaining, reset := resp.Header.Get("X-RateLimit-Remaining"), resp.Header.Get("X-RateLimit-Reset")
+	if remaining == "" {
+		remaining, reset = resp.Header.Get("RateLimit-Remaining"), resp.Header.Get("RateLimit-Reset")
+	}
+	if remaining == "0" || resp.StatusCode == http.StatusTooManyRequests {
+		if sec, err := strconv.ParseInt(reset, 10, 64); err == nil {
+			return nil, fmt.Errorf("%s: API rate limit exceeded until %s", a.name, time.Unix(sec, 0).Local().Format(time.DateTime))
+		}
+		return nil, fmt.Errorf("%s: API rate limit exceeded", a.name)
+	}
+	return nil, fmt.Errorf("%s: %s: %w", a.name, strings.TrimPrefix(u, a.base), apiStatus(resp.StatusCode))
+}
+
+// getJSON fetches u as get does and decodes it into v, returning the
+// response, whose body is closed, for its headers.
+func (a *hostAPI) getJSON(ctx context.Context, u string, v any) (*http.Response, error) {
+	resp, err := a.get(ctx, u, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("%s: bad response to %s: %w", a.name, strings.TrimPrefix(u, a.base), err)
+	}
+	return resp, nil
+}
+
+// apiStatus is the status of an API response other than 200 OK.
+type apiStatus int
+
+func (s apiStatus) Error() string {
+	return fmt.Sprintf("%d %s", int(s), http.StatusText(int(s)))
+}
+
+// isStatus reports whether err is from a response with status code.
+func isStatus(err error, code int) bool {
+	var status apiStatus
+	return errors.As(err, &status) && int(status) == code
+}
+
+// nextLink returns the URL of the next page from a Link header, as GitHub
+// and GitLab paginate:
+//
+//	<https://api.github.com/organizations/1/repos?page=2>; rel="next", <…>; rel="last"
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// pathEscape escapes each segment of a slash-separated path.
+func pathEscape(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,268 @@
+package scanner
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Report is the result of scanning one lockfile, independent of how it
+// is rendered. A directory scan produces a report whose Projects hold one
+// report per discovered lockfile.
+type Report struct {
+	// SchemaVersion is ReportSchemaVersion on a report written as JSON, and
+	// empty on the reports of its projects.
+	SchemaVersion string `json:"schema_version,omitempty"`
+
+	Source   string    `json:"source"`
+	Lockfile string    `json:"lockfile_type"`
+	Scanned  int       `json:"packages_scanned"`
+	Findings []Finding `json:"findings"`
+
+	// Packages are all the packages that were scanned, vulnerable or not.
+	Packages []Package `json:"-"`
+
+	// Suppressed lists findings hidden by .keystoneignore rules.
+	Suppressed []Suppression `json:"suppressed,omitempty"`
+
+	// Partial is set when some advisories' details couldn't be fetched, so
+	// their severity and fix are unknown; see Vulnerability.Error.
+	Partial bool `json:"partial,omitempty"`
+
+	// Unchecked counts, by ecosystem, the packages that weren't looked up
+	// because OSV has no advisories for their ecosystem; see
+	// UncoveredEcosystems.
+	Unchecked map[string]int `json:"unchecked,omitempty"`
+
+	// Interrupted is set when the scan was cut short, by Ctrl-C or a
+	// timeout, so it covers only the lockfiles scanned by then.
+	Interrupted bool `json:"interrupted,omitempty"`
+
+	// Started and Finished are when the scan that wrote the report began
+	// and ended; keystone attest takes the scan's time from them.
+	Started  *time.Time `json:"started_at,omitempty"`
+	Finished *time.Time `json:"finished_at,omitempty"`
+
+	// Drift is set on a scan of installed packages when a lockfile sits
+	// beside node_modules, and lists where the two disagree.
+	Drift *Drift `json:"drift,omitempty"`
+
+	// Resolution is set on a scan of a package.json without a lockfile,
+	// whose versions were resolved from its ranges, not locked.
+	Resolution *Resolution `json:"resolution,omitempty"`
+
+	// Workspaces lists the npm, yarn or pnpm workspaces of the project;
+	// see AttributeWorkspaces.
+	Workspaces []Workspace `json:"workspaces,omitempty"`
+
+	// LicenseViolations lists the packages whose licenses a LicensePolicy
+	// doesn't permit; see CheckLicenses.
+	LicenseViolations []LicenseViolation `json:"license_violations,omitempty"`
+
+	// PolicyViolations lists the advisories and packages that rules of a
+	// Policy match; see EvaluatePolicy.
+	PolicyViolations []PolicyViolation `json:"policy_violations,omitempty"`
+
+	// SupplyChain lists the packages that look like they could be
+	// malicious; see CheckSupplyChain.
+	SupplyChain []SupplyChainWarning `json:"supply_chain,omitempty"`
+
+	// Maintenance lists the packages that look unmaintained; see
+	// CheckMaintenance.
+	Maintenance []MaintenanceRisk `json:"maintenance,omitempty"`
+
+	// Scorecards lists the OpenSSF Scorecards of the direct dependencies;
+	// see ApplyScorecards.
+	Scorecards []Scorecard `json:"scorecards,omitempty"`
+
+	// Insights lists what deps.dev knows of the direct dependencies; see
+	// ApplyInsights.
+	Insights []Insight `json:"insights,omitempty"`
+
+	Projects []*Report `json:"projects,omitempty"`
+}
+
+// LockfileDirectory is the Lockfile type of a directory scan's report.
+const LockfileDirectory = "directory"
+
+// Reports returns r's per-lockfile reports: its projects, or r itself.
+func (r *Report) Reports() []*Report {
+	if len(r.Projects) > 0 {
+		return r.Projects
+	}
+	return []*Report{r}
+}
+
+// WithoutDirect returns the sources of r's reports whose lockfiles don't
+// record which dependencies are direct, such as a yarn v1 yarn.lock or a
+// go.sum: none of their packages is marked Direct.
+func (r *Report) WithoutDirect() []string {
+	var out []string
+	for _, p := range r.Reports() {
+		if len(p.Packages) > 0 && !slices.ContainsFunc(p.Packages, func(d Package) bool { return d.Direct }) {
+			out = append(out, p.Source)
+		}
+	}
+	return out
+}
+
+// Finding is a dependency with at least one known vulnerability.
+type Finding struct {
+	Ecosystem string          `json:"ecosystem"`
+	Package   string          `json:"package"`
+	Version   string          `json:"version"`
+	Dev       bool            `json:"dev,omitempty"` // a development-only dependency
+	Vulns     []Vulnerability `json:"vulnerabilities"`
+
+	// Path is the chain of "name@version" packages through which a transitive
+	// dependency is pulled in, starting at a direct dependency and ending
+	// with this one. It is empty for direct dependencies and for lockfiles
+	// that don't record the dependency graph.
+	Path []string `json:"path,omitempty"`
+
+	// Workspaces names the workspaces of a monorepo that depend on the
+	// package.
+	Workspaces []string `json:"workspaces,omitempty"`
+
+	// FixedIn is the lowest version that fixes every advisory with a known
+	// fix, i.e. the minimum safe upgrade.
+	FixedIn string `json:"fixed_in,omitempty"`
+
+	// Reachability is Reachable or NotReachable when ApplyReachability has
+	// looked for the package in the project's imports. ImportedFrom is the
+	// project file whose imports lead to it.
+	Reachability string `json:"reachability,omitempty"`
+	ImportedFrom string `json:"imported_from,omitempty"`
+}
+
+// Vulnerability is one advisory affecting a Finding's package.
+type Vulnerability struct {
+	ID         string   `json:"id"`
+	Summary    string   `json:"summary,omitempty"`
+	Severity   string   `json:"severity,omitempty"`
+	Score      float64  `json:"cvss_score,omitempty"`
+	CVSS       string   `json:"cvss_vector,omitempty"`
+	References []string `json:"references,omitempty"`
+	Fixed      string   `json:"fixed_version,omitempty"`
+	Aliases    []string `json:"aliases,omitempty"` // e.g. the CVE a GHSA advisory is about
+	Published  string   `json:"published,omitempty"`
+	Affected   []string `json:"affected_ranges,omitempty"` // versions of the package it affects, e.g. ">= 3.7.0, < 4.17.19"
+
+	// SeveritySource is set when OSV's record had no CVSS vector and
+	// FillSeverity found the severity elsewhere: "ghsa" or "nvd".
+	SeveritySource string `json:"severity_source,omitempty"`
+
+	// Environmental is set by ApplyCVSSEnvironment, which rates Severity
+	// by its score.
+	Environmental *CVSSEnvironmental `json:"cvss_environmental,omitempty"`
+
+	// EPSS is set by ApplyEPSS for advisories with a scored CVE.
+	EPSS *EPSSScore `json:"epss,omitempty"`
+
+	// KEV is set by ApplyKEV for advisories whose CVE CISA lists as known
+	// to be exploited.
+	KEV *KEVEntry `json:"kev,omitempty"`
+
+	// Error is set when the advisory's details couldn't be fetched.
+	Error string `json:"error,omitempty"`
+}
+
+// CVSSEnvironmental is an advisory's CVSS score adjusted for the deployment
+// it affects.
+type CVSSEnvironmental struct {
+	Score        float64 `json:"score"`
+	Vector       string  `json:"vector"`        // the base vector with the environmental metrics
+	BaseSeverity string  `json:"base_severity"` // the severity before adjustment
+}
+
+func newVulnerability(v OSVVuln, d Package) Vulnerability {
+	out := Vulnerability{ID: v.ID, Summary: strings.TrimSpace(v.Summary), Fixed: v.fixedVersion(d), Aliases: v.Aliases, Published: v.Published, Affected: v.affectedRanges(d)}
+	out.Severity, out.Score, out.CVSS = assessSeverity(v)
+	for _, r := range v.References {
+		out.References = append(out.References, r.URL)
+	}
+	return out
+}
+
+// VulnCount counts the vulnerabilities across every finding.
+func (r *Report) VulnCount() int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			n += len(f.Vulns)
+		}
+	}
+	return n
+}
+
+// OnlyVulns drops every advisory from r that isn't one of ids, by its own
+// ID or an alias such as a CVE, and the findings left without any.
+func OnlyVulns(r *Report, ids []string) {
+	for _, p := range r.Reports() {
+		kept := p.Findings[:0]
+		for _, f := range p.Findings {
+			vulns := f.Vulns[:0]
+			for _, v := range f.Vulns {
+				if containsString(ids, v.ID) || anyContained(ids, v.Aliases) {
+					vulns = append(vulns, v)
+				}
+			}
+			if len(vulns) > 0 {
+				f.Vulns = vulns
+				kept = append(kept, f)
+			}
+		}
+		p.Findings = kept
+	}
+}
+
+func anyContained(list, candidates []string) bool {
+	for _, c := range candidates {
+		if containsString(list, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unfetched counts the vulnerabilities whose details couldn't be fetched.
+func (r *Report) Unfetched() int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if v.Error != "" {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+// UncheckedByEcosystem totals the packages r's lockfiles have in each ecosystem
+// OSV doesn't cover.
+func (r *Report) UncheckedByEcosystem() map[string]int {
+	out := map[string]int{}
+	for _, p := range r.Reports() {
+		for eco, n := range p.Unchecked {
+			out[eco] += n
+		}
+	}
+	return out
+}
+
+// Failing counts the vulnerabilities at or above the --fail-on level.
+func (r *Report) Failing(level string) int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, f := range p.Findings {
+			for _, v := range f.Vulns {
+				if MeetsThreshold(v.Severity, level) {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
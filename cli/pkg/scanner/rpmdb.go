@@ -0,0 +1,363 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// RPM header tags read from installed packages.
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagLicense = 1014
+)
+
+// extractRpmPackages reads the installed packages of an rpmdb.sqlite, the
+// RPM database of RHEL 9 and its rebuilds, Fedora 33 and later and
+// openSUSE Tumbleweed. Versions are "[epoch:]version-release", as OSV's
+// RPM-based ecosystems write them. gpg-pubkey entries are imported signing
+// keys, not packages, and are skipped.
+func extractRpmPackages(data []byte, ecosystem string) ([]Package, error) {
+	blobs, err := sqliteTableBlobs(data, "Packages", 1)
+	if err != nil {
+		return nil, fmt.Errorf("reading rpmdb.sqlite: %w", err)
+	}
+	var out []Package
+	seen := map[string]bool{}
+	for _, blob := range blobs {
+		h, err := parseRpmHeader(blob)
+		if err != nil {
+			return nil, fmt.Errorf("reading rpmdb.sqlite: %w", err)
+		}
+		name, version := h.str(rpmTagName), h.str(rpmTagVersion)
+		if name == "" || version == "" || name == "gpg-pubkey" {
+			continue
+		}
+		if release := h.str(rpmTagRelease); release != "" {
+			version += "-" + release
+		}
+		if epoch, ok := h.int(rpmTagEpoch); ok && epoch != 0 {
+			version = strconv.Itoa(epoch) + ":" + version
+		}
+		if seen[name+"@"+version] {
+			continue // the same package for another architecture
+		}
+		seen[name+"@"+version] = true
+		out = append(out, Package{Ecosystem: ecosystem, Name: name, Version: version, License: h.str(rpmTagLicense), Direct: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// rpmHeader is the index of an RPM header and its data store.
+type rpmHeader struct {
+	entries map[int32]rpmHeaderEntry
+	store   []byte
+}
+
+type rpmHeaderEntry struct {
+	typ    uint32
+	offset int32
+}
+
+// parseRpmHeader reads a header as the rpmdb stores it: entry and data
+// lengths, then 16-byte index entries (tag, type, offset, count), then the
+// data they point into.
+func parseRpmHeader(blob []byte) (*rpmHeader, error) {
+	if len(blob) < 8 {
+		return nil, errors.New("truncated package header")
+	}
+	il, dl := binary.BigEndian.Uint32(blob), binary.BigEndian.Uint32(blob[4:])
+	start := 8 + 16*uint64(il)
+	if start+uint64(dl) > uint64(len(blob)) {
+		return nil, errors.New("truncated package header")
+	}
+	h := &rpmHeader{entries: map[int32]rpmHeaderEntry{}, store: blob[start : start+uint64(dl)]}
+	for i := uint64(0); i < uint64(il); i++ {
+		e := blob[8+16*i:]
+		tag := int32(binary.BigEndian.Uint32(e))
+		h.entries[tag] = rpmHeaderEntry{typ: binary.BigEndian.Uint32(e[4:]), offset: int32(binary.BigEndian.Uint32(e[8:]))}
+	}
+	return h, nil
+}
+
+// str returns a STRING or I18NSTRING tag's (first) value.
+func (h *rpmHeader) str(tag int32) string {
+	e, ok := h.entries[tag]
+	if !ok || (e.typ != 6 && e.typ != 9) || e.offset < 0 || int(e.offset) >= len(h.store) {
+		return ""
+	}
+	s := h.store[e.offset:]
+	if i := bytes.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return string(s)
+}
+
+// int returns an INT32 tag's (first) value.
+func (h *rpmHeader) int(tag int32) (int, bool) {
+	e, ok := h.entries[tag]
+	if !ok || e.typ != 4 || e.offset < 0 || int(e.offset)+4 > len(h.store) {
+		return 0, false
+	}
+	return int(int32(binary.BigEndian.Uint32(h.store[e.offset:]))), true
+}
+
+/********** SQLite **********/
+
+// sqliteTableBlobs returns column col of every row of a table in a SQLite
+// database file, read directly from its B-tree pages. It reads only what
+// the rpmdb needs: a rowid table whose column holds a blob or text.
+func sqliteTableBlobs(db []byte, table string, col int) ([][]byte, error) {
+	if len(db) < 100 || string(db[:16]) != "SQLite format 3\x00" {
+		return nil, errors.New("not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(db[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("bad page size %d", pageSize)
+	}
+	// The file format requires at least 480 usable bytes a page, which the
+	// payload arithmetic below relies on.
+	f := &sqliteFile{data: db, pageSize: pageSize, usable: pageSize - int(db[20])}
+	if f.usable < 480 {
+		return nil, fmt.Errorf("bad reserved space %d", db[20])
+	}
+
+	// The schema table, on page 1, gives the table's root page.
+	root := 0
+	err := f.walk(1, func(record []byte) error {
+		values, err := sqliteRecord(record)
+		if err != nil {
+			return err
+		}
+		if len(values) >= 4 && string(values[0]) == "table" && string(values[1]) == table {
+			root, _ = strconv.Atoi(string(values[3]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if root == 0 {
+		return nil, fmt.Errorf("no %s table", table)
+	}
+
+	var out [][]byte
+	err = f.walk(root, func(record []byte) error {
+		values, err := sqliteRecord(record)
+		if err != nil {
+			return err
+		}
+		if col < len(values) {
+			out = append(out, values[col])
+		}
+		return nil
+	})
+	return out, err
+}
+
+type sqliteFile struct {
+	data             []byte
+	pageSize, usable int
+}
+
+func (f *sqliteFile) page(n int) ([]byte, error) {
+	if n < 1 || n*f.pageSize > len(f.data) {
+		return nil, fmt.Errorf("page %d out of range", n)
+	}
+	return f.data[(n-1)*f.pageSize : n*f.pageSize], nil
+}
+
+// walk calls fn with the payload of every cell of the table B-tree rooted
+// at page n, in rowid order.
+func (f *sqliteFile) walk(n int, fn func(record []byte) error) error {
+	return f.walkDepth(n, fn, 0, map[int]bool{})
+}
+
+// walkDepth walks the B-tree at page n, depth pages down from its root.
+// visited holds the pages walked so far, B-tree and overflow pages both,
+// since a page belongs to one place in a table: a corrupt file that links
+// one twice would otherwise be walked over and over.
+func (f *sqliteFile) walkDepth(n int, fn func(record []byte) error, depth int, visited map[int]bool) error {
+	if depth > 64 {
+		return errors.New("B-tree too deep")
+	}
+	if visited[n] {
+		return fmt.Errorf("page %d is linked twice", n)
+	}
+	visited[n] = true
+	page, err := f.page(n)
+	if err != nil {
+		return err
+	}
+	hdr := page
+	if n == 1 {
+		hdr = page[100:] // after the file header
+	}
+	hdrSize := 8
+	if hdr[0] == 0x05 {
+		hdrSize = 12
+	}
+	cells := int(binary.BigEndian.Uint16(hdr[3:]))
+	if hdrSize+2*cells > len(hdr) {
+		return fmt.Errorf("page %d: bad cell count %d", n, cells)
+	}
+	switch hdr[0] {
+	case 0x05: // interior table page
+		for i := 0; i < cells; i++ {
+			off := int(binary.BigEndian.Uint16(hdr[12+2*i:]))
+			if off+4 > len(page) {
+				return errors.New("bad cell pointer")
+			}
+			if err := f.walkDepth(int(binary.BigEndian.Uint32(page[off:])), fn, depth+1, visited); err != nil {
+				return err
+			}
+		}
+		return f.walkDepth(int(binary.BigEndian.Uint32(hdr[8:])), fn, depth+1, visited)
+	case 0x0d: // leaf table page
+		for i := 0; i < cells; i++ {
+			off := int(binary.BigEndian.Uint16(hdr[8+2*i:]))
+			if off >= len(page) {
+				return errors.New("bad cell pointer")
+			}
+			payload, err := f.cellPayload(page[off:], visited)
+			if err != nil {
+				return err
+			}
+			if err := fn(payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("page %d isn't a table B-tree page", n)
+}
+
+// cellPayload reads a table leaf cell's payload, following its overflow
+// pages, which it adds to visited, if it doesn't fit in the page.
+func (f *sqliteFile) cellPayload(cell []byte, visited map[int]bool) ([]byte, error) {
+	size, n := sqliteVarint(cell)
+	if size > uint64(len(f.data)) {
+		return nil, errors.New("bad payload size")
+	}
+	_, m := sqliteVarint(cell[n:]) // rowid
+	cell = cell[n+m:]
+
+	u := f.usable
+	maxLocal := u - 35
+	local := int(size)
+	if local > maxLocal {
+		minLocal := (u-12)*32/255 - 23
+		local = minLocal + (int(size)-minLocal)%(u-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if local > len(cell) {
+		return nil, errors.New("truncated cell")
+	}
+	payload := append([]byte(nil), cell[:local]...)
+	if local == int(size) {
+		return payload, nil
+	}
+	if local+4 > len(cell) {
+		return nil, errors.New("truncated cell")
+	}
+	next := int(binary.BigEndian.Uint32(cell[local:]))
+	for len(payload) < int(size) {
+		if visited[next] {
+			return nil, fmt.Errorf("page %d is linked twice", next)
+		}
+		visited[next] = true
+		page, err := f.page(next)
+		if err != nil {
+			return nil, err
+		}
+		chunk := page[4:u]
+		if rest := int(size) - len(payload); len(chunk) > rest {
+			chunk = chunk[:rest]
+		}
+		payload = append(payload, chunk...)
+		next = int(binary.BigEndian.Uint32(page))
+	}
+	return payload, nil
+}
+
+// sqliteRecord decodes a record's values: integers as decimal text, text
+// and blobs as their bytes, NULLs and floats as nil.
+func sqliteRecord(rec []byte) ([][]byte, error) {
+	hdrSize, n := sqliteVarint(rec)
+	if hdrSize > uint64(len(rec)) || int(hdrSize) < n {
+		return nil, errors.New("bad record header")
+	}
+	var types []uint64
+	for p := n; p < int(hdrSize); {
+		t, m := sqliteVarint(rec[p:int(hdrSize)])
+		types = append(types, t)
+		p += m
+	}
+	var out [][]byte
+	body := rec[hdrSize:]
+	for _, t := range types {
+		var size uint64
+		switch {
+		case t >= 12:
+			size = (t - 12) / 2
+		case t >= 1 && t <= 4:
+			size = t
+		case t == 5:
+			size = 6
+		case t == 6 || t == 7:
+			size = 8
+		}
+		if size > uint64(len(body)) {
+			return nil, errors.New("truncated record")
+		}
+		v := body[:size]
+		body = body[size:]
+		switch {
+		case t >= 12:
+			out = append(out, v)
+		case t >= 1 && t <= 6:
+			var x int64
+			for i, b := range v {
+				if i == 0 {
+					x = int64(int8(b))
+				} else {
+					x = x<<8 | int64(b)
+				}
+			}
+			out = append(out, []byte(strconv.FormatInt(x, 10)))
+		case t == 8 || t == 9:
+			out = append(out, []byte(strconv.Itoa(int(t-8))))
+		default:
+			out = append(out, nil)
+		}
+	}
+	return out, nil
+}
+
+// sqliteVarint decodes a SQLite varint: big-endian groups of 7 bits, the
+// ninth byte contributing all 8.
+func sqliteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, len(b)
+}
@@ -0,0 +1,107 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testSQLiteVarint encodes v as a SQLite varint of at most eight bytes.
+func testSQLiteVarint(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// testSQLiteRecord encodes a record of text and blob values.
+func testSQLiteRecord(values ...any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			types = append(types, testSQLiteVarint(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, testSQLiteVarint(uint64(12+2*len(v)))...)
+			body = append(body, v...)
+		case int:
+			types = append(types, 1)
+			body = append(body, byte(v))
+		}
+	}
+	return append(append([]byte{byte(1 + len(types))}, types...), body...)
+}
+
+// testSQLiteLeaf writes a leaf table page holding record as row 1 into
+// page, whose B-tree header is at hdr.
+func testSQLiteLeaf(page []byte, hdr int, record []byte) {
+	cell := append(append(testSQLiteVarint(uint64(len(record))), 1), record...)
+	off := len(page) - len(cell)
+	copy(page[off:], cell)
+	page[hdr] = 0x0d
+	binary.BigEndian.PutUint16(page[hdr+3:], 1)
+	binary.BigEndian.PutUint16(page[hdr+5:], uint16(off))
+	binary.BigEndian.PutUint16(page[hdr+8:], uint16(off))
+}
+
+// testSQLite returns a two-page database whose Packages table, on page 2,
+// holds record.
+func testSQLite(record []byte) []byte {
+	const pageSize = 512
+	db := make([]byte, 2*pageSize)
+	copy(db, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(db[16:], pageSize)
+	testSQLiteLeaf(db[:pageSize], 100, testSQLiteRecord("table", "Packages", "Packages", 2,
+		"CREATE TABLE Packages (hnum INTEGER PRIMARY KEY, blob BLOB NOT NULL)"))
+	testSQLiteLeaf(db[pageSize:], 0, record)
+	return db
+}
+
+// Installed packages come from container images, so a crafted rpmdb must be
+// an error, not a panic or a walk without end.
+func TestSQLiteTableBlobsRejectsCorruptDatabases(t *testing.T) {
+	blob := []byte("package header")
+	if got, err := sqliteTableBlobs(testSQLite(testSQLiteRecord(0, blob)), "Packages", 1); err != nil {
+		t.Fatalf("valid database: %v", err)
+	} else if len(got) != 1 || !bytes.Equal(got[0], blob) {
+		t.Fatalf("valid database: got %q, want [%q]", got, blob)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(db []byte) []byte
+	}{
+		{"page size below 512", func(db []byte) []byte { binary.BigEndian.PutUint16(db[16:], 64); return db }},
+		{"page size 0", func(db []byte) []byte { binary.BigEndian.PutUint16(db[16:], 0); return db }},
+		{"page size not a power of two", func(db []byte) []byte { binary.BigEndian.PutUint16(db[16:], 1000); return db }},
+		{"too little usable space", func(db []byte) []byte { db[20] = 40; return db }},
+		{"truncated file", func(db []byte) []byte { return db[:600] }},
+		{"cell count beyond the page", func(db []byte) []byte { binary.BigEndian.PutUint16(db[512+3:], 0xffff); return db }},
+		{"cell pointer beyond the page", func(db []byte) []byte { binary.BigEndian.PutUint16(db[512+8:], 0xffff); return db }},
+		{"record header larger than the record", func(db []byte) []byte {
+			return testSQLite(append(testSQLiteVarint(1<<40), 0))
+		}},
+		{"serial type of 2^64-1", func(db []byte) []byte {
+			return testSQLite(append([]byte{10}, bytes.Repeat([]byte{0xff}, 9)...))
+		}},
+		{"interior page linking to itself", func(db []byte) []byte {
+			page := db[512:]
+			page[0] = 0x05
+			binary.BigEndian.PutUint16(page[3:], 3)
+			binary.BigEndian.PutUint32(page[8:], 2)
+			for i := 0; i < 3; i++ {
+				binary.BigEndian.PutUint16(page[12+2*i:], 100)
+			}
+			binary.BigEndian.PutUint32(page[100:], 2)
+			return db
+		}},
+	}
+	for _, tt := range tests {
+		db := tt.corrupt(testSQLite(testSQLiteRecord(0, blob)))
+		if _, err := sqliteTableBlobs(db, "Packages", 1); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
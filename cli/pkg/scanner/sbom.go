@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SBOM formats, as returned by ParseSBOM.
+const (
+	SBOMCycloneDX = "cyclonedx"
+	SBOMSPDX      = "spdx"
+)
+
+// sbomInput is the subset of a CycloneDX or SPDX JSON document that's needed
+// to find its packages.
+type sbomInput struct {
+	BOMFormat   string `json:"bomFormat"`
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// ParseSBOM extracts dependencies from a CycloneDX or SPDX JSON SBOM using
+// the package URL recorded for each component. Components without a purl,
+// or whose purl type OSV doesn't cover, are skipped.
+func ParseSBOM(data []byte) (string, []Package, error) {
+	var in sbomInput
+	if err := json.Unmarshal(data, &in); err != nil {
+		return "", nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	var format string
+	var purls []string
+	switch {
+	case in.BOMFormat == "CycloneDX":
+		format = SBOMCycloneDX
+		purls = cdxPURLs(data)
+	case in.SPDXVersion != "":
+		format = SBOMSPDX
+		for _, p := range in.Packages {
+			for _, ref := range p.ExternalRefs {
+				if ref.ReferenceType == "purl" {
+					purls = append(purls, ref.ReferenceLocator)
+				}
+			}
+		}
+	default:
+		return "", nil, fmt.Errorf("not a CycloneDX or SPDX JSON document")
+	}
+
+	var deps []Package
+	for _, p := range purls {
+		if d, err := ParsePURL(p); err == nil {
+			deps = append(deps, d)
+		}
+	}
+	return format, deps, nil
+}
+
+// cdxPURLs collects purls from CycloneDX components, including components
+// nested inside other components.
+func cdxPURLs(data []byte) []string {
+	type component struct {
+		PURL       string      `json:"purl"`
+		Components []component `json:"components"`
+	}
+	var bom struct {
+		Components []component `json:"components"`
+	}
+	_ = json.Unmarshal(data, &bom)
+
+	var out []string
+	var walk func([]component)
+	walk = func(cs []component) {
+		for _, c := range cs {
+			if c.PURL != "" {
+				out = append(out, c.PURL)
+			}
+			walk(c.Components)
+		}
+	}
+	walk(bom.Components)
+	return out
+}
@@ -0,0 +1,152 @@
+// Package scanner finds known vulnerabilities in a project's dependencies.
+// It parses lockfiles and SBOMs into Packages, matches them against OSV (the
+// online API or a downloaded copy of its database) and collects the results
+// into a Report.
+//
+// Scanning a lockfile takes a Source and one call:
+//
+//	s := scanner.New(scanner.NewOSVClient(scanner.OSVClientOptions{Concurrency: scanner.DefaultConcurrency}))
+//	report, err := s.ScanFile(ctx, "package-lock.json")
+//
+// Lookups stop when ctx is done; see Scan for what is reported then.
+package scanner
+
+import (
+	"context"
+	"os"
+)
+
+// Scanner matches packages against a vulnerability Source.
+type Scanner struct {
+	Source Source
+
+	// ProdOnly leaves development dependencies out of scans.
+	ProdOnly bool
+}
+
+// New returns a Scanner that looks packages up in source.
+func New(source Source) *Scanner {
+	return &Scanner{Source: source}
+}
+
+// UncoveredEcosystems are the ecosystems keystone reads lockfiles of but OSV
+// has no advisories for. Scan doesn't look their packages up; reports count
+// them in Unchecked instead, so that a clean result isn't taken for coverage.
+var UncoveredEcosystems = map[string]bool{
+	"CocoaPods": true,
+	"Terraform": true,
+}
+
+// Scannable returns the packages that a scan looks up: those with a name and
+// version, less development dependencies when ProdOnly is set. Lockfile root
+// entries and unpinned requirements have no version and are dropped.
+func (s *Scanner) Scannable(pkgs []Package) []Package {
+	out := make([]Package, 0, len(pkgs))
+	for _, p := range pkgs {
+		if p.Name != "" && p.Version != "" && !(s.ProdOnly && p.Dev) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Scan looks up pkgs, which were read from source (a lockfile of the given
+// kind), and reports those with known vulnerabilities. If ctx is done
+// before every package has been looked up, Scan fails with its error; once
+// they have, it returns the findings so far, with the details of the
+// advisories it hadn't fetched yet missing as when they fail to fetch.
+func (s *Scanner) Scan(ctx context.Context, source, kind string, pkgs []Package) (*Report, error) {
+	ctx, span := StartSpan(ctx, "scan", "keystone.lockfile", source, "keystone.lockfile.kind", kind)
+	defer span.Finish()
+	pkgs = s.Scannable(pkgs)
+	var covered []Package
+	var unchecked map[string]int
+	for _, p := range pkgs {
+		if !UncoveredEcosystems[p.Ecosystem] {
+			covered = append(covered, p)
+			continue
+		}
+		if unchecked == nil {
+			unchecked = map[string]int{}
+		}
+		unchecked[p.Ecosystem]++
+	}
+	findings, err := s.findings(ctx, covered)
+	if err != nil {
+		span.Fail(err)
+		return nil, err
+	}
+	r := &Report{Source: source, Lockfile: kind, Scanned: len(covered), Findings: findings, Packages: pkgs, Unchecked: unchecked}
+	r.Partial = r.Unfetched() > 0
+	return r, nil
+}
+
+// ScanFile parses the lockfile at path and scans its packages.
+func (s *Scanner) ScanFile(ctx context.Context, path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kind, pkgs, err := ParseLockfile(path, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.Scan(ctx, path, string(kind), pkgs)
+}
+
+// findings queries the source for deps and pairs every vulnerable dep with
+// the details of its advisories, the lowest version that fixes them and, for
+// transitive deps, the path they are pulled in through.
+func (s *Scanner) findings(ctx context.Context, deps []Package) ([]Finding, error) {
+	qctx, span := StartSpan(ctx, "query", "keystone.packages", len(deps))
+	ids, err := s.Source.QueryBatch(qctx, deps)
+	span.Fail(err)
+	span.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	// Batch results only carry IDs; fetch each advisory's details once.
+	_, span = StartSpan(ctx, "dedupe")
+	var unique []string
+	seen := map[string]bool{}
+	for _, vids := range ids {
+		for _, id := range vids {
+			if !seen[id] {
+				seen[id] = true
+				unique = append(unique, id)
+			}
+		}
+	}
+	span.SetAttrs("keystone.advisories", len(unique))
+	span.Finish()
+	fctx, span := StartSpan(ctx, "fetch", "keystone.advisories", len(unique))
+	details, failed := s.Source.FetchVulns(fctx, unique)
+	span.SetAttrs("keystone.advisories.failed", len(failed))
+	span.Finish()
+	paths := dependencyPaths(deps)
+
+	var findings []Finding
+	for i, d := range deps {
+		if len(ids[i]) == 0 {
+			continue
+		}
+		f := Finding{Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version, Dev: d.Dev}
+		if path := paths[d.Name+"@"+d.Version]; len(path) > 1 {
+			f.Path = path
+		}
+		for _, id := range ids[i] {
+			if err := failed[id]; err != nil {
+				f.Vulns = append(f.Vulns, Vulnerability{ID: id, Error: err.Error()})
+				continue
+			}
+			v := newVulnerability(details[id], d)
+			if v.Fixed != "" && (f.FixedIn == "" || CompareVersions(d.Ecosystem, v.Fixed, f.FixedIn) > 0) {
+				f.FixedIn = v.Fixed
+			}
+			f.Vulns = append(f.Vulns, v)
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
@@ -0,0 +1,110 @@
+package scanner
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReportSchemaVersion is the version of the JSON report's schema, which
+// reports carry as their schema_version. New fields may appear in any
+// release without changing its major version, so consumers should ignore
+// fields they don't know; removing or renaming a field, or changing what
+// it means, takes a new major version.
+const ReportSchemaVersion = "1.0"
+
+// ReportSchema returns the JSON Schema (draft 2020-12) of the JSON report,
+// derived from the Report type so that the two can't drift apart. Fields
+// that are left out when empty aren't required.
+func ReportSchema() map[string]any {
+	defs := map[string]any{}
+	root := jsonSchemaOf(reflect.TypeOf(Report{}), defs)
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "keystone report " + ReportSchemaVersion,
+		"$ref":    root["$ref"],
+		"$defs":   defs,
+	}
+}
+
+// jsonSchemaOf describes how encoding/json writes values of type t. Structs
+// are described once, in defs, and referred to by name, since a Report's
+// projects are Reports themselves.
+func jsonSchemaOf(t reflect.Type, defs map[string]any) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem(), defs)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": []string{"array", "null"}, "items": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": jsonSchemaOf(t.Elem(), defs)}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		def := map[string]any{"type": "object"}
+		defs[t.Name()] = def // before the fields, which may refer back to it
+		props := map[string]any{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			s := jsonSchemaOf(f.Type, defs)
+			if strings.Contains(","+opts+",", ",string,") {
+				s = map[string]any{"type": "string"}
+			}
+			props[name] = s
+			if !strings.Contains(","+opts+",", ",omitempty,") {
+				required = append(required, name)
+			}
+		}
+		def["properties"] = props
+		if len(required) > 0 {
+			def["required"] = required
+		}
+		return ref
+	}
+	panic(fmt.Sprintf("no JSON Schema for %s", t))
+}
+
+// checkSchemaVersion rejects a report written to a later major version of
+// the schema than this build of keystone reads. Reports from before
+// schema_version was written have none, and are read as version 1.
+func checkSchemaVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	major, _, _ := strings.Cut(version, ".")
+	ours, _, _ := strings.Cut(ReportSchemaVersion, ".")
+	m, err := strconv.Atoi(major)
+	if err != nil {
+		return fmt.Errorf("invalid schema_version %q", version)
+	}
+	if n, _ := strconv.Atoi(ours); m > n {
+		return fmt.Errorf("the report's schema_version %s is newer than this keystone reads (%s): upgrade keystone", version, ReportSchemaVersion)
+	}
+	return nil
+}
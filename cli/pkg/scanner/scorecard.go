@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultScorecardURL is the public API of OpenSSF Scorecard results.
+const DefaultScorecardURL = "https://api.securityscorecards.dev"
+
+// Scorecard is the OpenSSF Scorecard of a package's source repository: how
+// well it follows security practices such as code review, branch
+// protection and pinned dependencies, scored 0 to 10.
+type Scorecard struct {
+	Ecosystem  string           `json:"ecosystem"`
+	Package    string           `json:"package"`
+	Version    string           `json:"version"`
+	Repository string           `json:"repository"` // "owner/repo" on GitHub
+	Score      float64          `json:"score"`
+	Date       string           `json:"date,omitempty"` // when the repository was last scored
+	Checks     []ScorecardCheck `json:"checks,omitempty"`
+}
+
+// ScorecardCheck is the result of one of a Scorecard's checks.
+type ScorecardCheck struct {
+	Name  string `json:"name"`
+	Score int    `json:"score"` // 0-10, or -1 when the check was inconclusive
+}
+
+// FetchScorecards looks up the Scorecards of the GitHub repositories of the
+// direct dependencies in deps, which the registries of OutdatedEcosystems
+// link, keyed "ecosystem/name". Repositories Scorecard hasn't scored are
+// left out; the error is the first failure, the other packages being looked
+// up regardless. The Scorecards are of repositories; ApplyScorecards
+// attributes them to packages.
+func FetchScorecards(ctx context.Context, client *http.Client, apiURL string, regs Registries, deps []Package) (map[string]*Scorecard, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var direct []Package
+	for _, d := range deps {
+		if d.Direct {
+			direct = append(direct, d)
+		}
+	}
+	releases, firstErr := FetchLatestReleases(client, regs, direct)
+	packages := map[string][]string{} // repository → packages
+	for key, rel := range releases {
+		if repo := githubRepoName(rel.Repository); repo != "" {
+			packages[strings.ToLower(repo)] = append(packages[strings.ToLower(repo)], key)
+		}
+	}
+
+	api := &hostAPI{name: "Scorecard", client: client, base: registryURL(apiURL, DefaultScorecardURL)}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, npmResolveConcurrency)
+		out = map[string]*Scorecard{}
+	)
+	for _, repo := range sortedKeys(packages) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(repo string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			var card struct {
+				Repo struct {
+					Name string `json:"name"`
+				} `json:"repo"`
+				Score  float64          `json:"score"`
+				Date   string           `json:"date"`
+				Checks []ScorecardCheck `json:"checks"`
+			}
+			_, err := api.getJSON(ctx, "/projects/github.com/"+repo, &card)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if !isStatus(err, http.StatusNotFound) && firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			s := &Scorecard{Repository: repo, Score: card.Score, Date: card.Date, Checks: card.Checks}
+			if name, ok := strings.CutPrefix(card.Repo.Name, "github.com/"); ok {
+				s.Repository = name
+			}
+			for _, key := range packages[repo] {
+				out[key] = s
+			}
+		}(repo)
+	}
+	wg.Wait()
+	return out, firstErr
+}
+
+// ApplyScorecards records on each of r's reports the Scorecards, from
+// FetchScorecards, of its direct dependencies, lowest first.
+func ApplyScorecards(r *Report, cards map[string]*Scorecard) {
+	for _, rep := range r.Reports() {
+		rep.Scorecards = nil
+		seen := map[string]bool{}
+		for _, d := range rep.Packages {
+			key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+			card := cards[d.Ecosystem+"/"+d.Name]
+			if !d.Direct || card == nil || seen[key] {
+				continue
+			}
+			seen[key] = true
+			s := *card
+			s.Ecosystem, s.Package, s.Version = d.Ecosystem, d.Name, d.Version
+			rep.Scorecards = append(rep.Scorecards, s)
+		}
+		sort.SliceStable(rep.Scorecards, func(i, j int) bool { return rep.Scorecards[i].Score < rep.Scorecards[j].Score })
+	}
+}
+
+// WeakestChecks returns the names and scores of the checks s scored lowest,
+// at most n of them and none that scored full marks or were inconclusive.
+func (s Scorecard) WeakestChecks(n int) []string {
+	checks := make([]ScorecardCheck, 0, len(s.Checks))
+	for _, c := range s.Checks {
+		if c.Score >= 0 && c.Score < 10 {
+			checks = append(checks, c)
+		}
+	}
+	sort.SliceStable(checks, func(i, j int) bool { return checks[i].Score < checks[j].Score })
+	var out []string
+	for i := 0; i < len(checks) && i < n; i++ {
+		out = append(out, fmt.Sprintf("%s %d", checks[i].Name, checks[i].Score))
+	}
+	return out
+}
+
+// scorecardByPackage indexes the Scorecards of r by "ecosystem/name@version".
+func scorecardByPackage(r *Report) map[string]float64 {
+	out := map[string]float64{}
+	for _, s := range r.Scorecards {
+		out[s.Ecosystem+"/"+s.Package+"@"+s.Version] = s.Score
+	}
+	return out
+}
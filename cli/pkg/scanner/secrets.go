@@ -0,0 +1,238 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SecretAllowMarker on a line stops it from being reported, for test
+// fixtures and examples that look like credentials but aren't.
+const SecretAllowMarker = "keystone:allow"
+
+// maxSecretFileSize bounds the files searched for secrets; larger ones are
+// almost always generated or data files.
+const maxSecretFileSize = 2 << 20
+
+// SecretRule describes one kind of hard-coded credential. Rules files hold a
+// JSON array of them:
+//
+//	[{"id": "internal-token", "description": "Internal API token",
+//	  "regex": "itk_([A-Za-z0-9]{32})", "group": 1, "severity": "high"}]
+type SecretRule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Regex       string `json:"regex"`
+	Severity    string `json:"severity"`
+
+	// Group is the capture group holding the secret itself; 0 is the
+	// whole match.
+	Group int `json:"group,omitempty"`
+
+	// Entropy is the minimum Shannon entropy, in bits per character, the
+	// secret must have, to tell random keys from placeholders such as
+	// "changeme". 0 disables the check.
+	Entropy float64 `json:"entropy,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (r *SecretRule) compile() error {
+	if r.ID == "" {
+		return fmt.Errorf("secret rule without an id")
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("secret rule %s: %w", r.ID, err)
+	}
+	if r.Group < 0 || r.Group > re.NumSubexp() {
+		return fmt.Errorf("secret rule %s: regex has no group %d", r.ID, r.Group)
+	}
+	r.re = re
+	r.Severity = normalizeSeverity(r.Severity)
+	return nil
+}
+
+// DefaultSecretRules returns the built-in rules: cloud provider keys, private
+// keys, and the tokens of common services.
+func DefaultSecretRules() []SecretRule {
+	rules := []SecretRule{
+		{ID: "aws-access-key-id", Description: "AWS access key ID", Severity: SeverityHigh,
+			Regex: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, Group: 1},
+		{ID: "aws-secret-access-key", Description: "AWS secret access key", Severity: SeverityCritical,
+			Regex: `(?i)aws.{0,20}(?:secret|private).{0,20}['"]([0-9a-zA-Z/+]{40})['"]`, Group: 1, Entropy: 4},
+		{ID: "private-key", Description: "Private key", Severity: SeverityCritical,
+			Regex: `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`},
+		{ID: "github-token", Description: "GitHub token", Severity: SeverityHigh,
+			Regex: `\b(gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`, Group: 1},
+		{ID: "gitlab-token", Description: "GitLab personal access token", Severity: SeverityHigh,
+			Regex: `\b(glpat-[A-Za-z0-9_-]{20})\b`, Group: 1},
+		{ID: "slack-token", Description: "Slack token", Severity: SeverityHigh,
+			Regex: `\b(xox[baprs]-[A-Za-z0-9-]{10,})\b`, Group: 1},
+		{ID: "slack-webhook", Description: "Slack incoming webhook URL", Severity: SeverityMedium,
+			Regex: `https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`},
+		{ID: "stripe-secret-key", Description: "Stripe live secret key", Severity: SeverityCritical,
+			Regex: `\b((?:sk|rk)_live_[0-9a-zA-Z]{24,})\b`, Group: 1},
+		{ID: "google-api-key", Description: "Google API key", Severity: SeverityMedium,
+			Regex: `\b(AIza[0-9A-Za-z_-]{35})\b`, Group: 1},
+		{ID: "npm-token", Description: "npm access token", Severity: SeverityHigh,
+			Regex: `\b(npm_[A-Za-z0-9]{36})\b`, Group: 1},
+		{ID: "jwt", Description: "JSON Web Token", Severity: SeverityLow,
+			Regex: `\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`, Group: 1},
+		{ID: "generic-secret", Description: "Hard-coded password or API key", Severity: SeverityMedium,
+			Regex: `(?i)(?:password|passwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token)["']?\s*[:=]\s*["']([^"'\s]{12,})["']`, Group: 1, Entropy: 3.5},
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return rules
+}
+
+// LoadSecretRules reads a JSON rules file.
+func LoadSecretRules(path string) ([]SecretRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []SecretRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return rules, nil
+}
+
+// SecretFinding is one suspected credential.
+type SecretFinding struct {
+	RuleID      string  `json:"rule_id"`
+	Description string  `json:"description"`
+	Severity    string  `json:"severity"`
+	File        string  `json:"file"`
+	Line        int     `json:"line"`
+	Column      int     `json:"column"`
+	Secret      string  `json:"secret"` // redacted
+	Entropy     float64 `json:"entropy,omitempty"`
+}
+
+// ScanSecrets searches the file or directory tree at root for matches of
+// rules. Like DiscoverLockfiles it skips node_modules, vendor and .git, and
+// it skips lockfiles, whose integrity hashes look like keys, as well as
+// binary and very large files.
+func ScanSecrets(root string, rules []SecretRule) ([]SecretFinding, error) {
+	var out []SecretFinding
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && skippedDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if _, ok := LockfileByName(path); ok && path != root {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxSecretFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		out = append(out, findSecrets(path, data, rules)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out, nil
+}
+
+func findSecrets(path string, data []byte, rules []SecretRule) []SecretFinding {
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil // binary
+	}
+	var out []SecretFinding
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), maxSecretFileSize)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if strings.Contains(line, SecretAllowMarker) {
+			continue
+		}
+		for _, r := range rules {
+			for _, m := range r.re.FindAllStringSubmatchIndex(line, -1) {
+				start, end := m[2*r.Group], m[2*r.Group+1]
+				if start < 0 {
+					continue
+				}
+				secret := line[start:end]
+				entropy := shannonEntropy(secret)
+				if entropy < r.Entropy {
+					continue
+				}
+				f := SecretFinding{
+					RuleID: r.ID, Description: r.Description, Severity: r.Severity,
+					File: path, Line: n, Column: start + 1, Secret: redactSecret(secret),
+				}
+				if r.Entropy > 0 {
+					f.Entropy = math.Round(entropy*100) / 100
+				}
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
+
+// shannonEntropy is s's entropy in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	h := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// redactSecret keeps just enough of a secret to recognise it by.
+func redactSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + strings.Repeat("*", min(len(s)-4, 12))
+}
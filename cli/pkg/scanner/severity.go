@@ -0,0 +1,96 @@
+package scanner
+
+import "strings"
+
+// Severity labels, from most to least severe. Findings without CVSS data or
+// an advisory rating are UNKNOWN.
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+// SeverityOrder lists the severity labels from most to least severe.
+var SeverityOrder = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityUnknown}
+
+// severityRank orders labels so that higher is more severe; unrecognised
+// labels rank with UNKNOWN.
+func severityRank(label string) int {
+	for i, s := range SeverityOrder {
+		if s == label {
+			return len(SeverityOrder) - i
+		}
+	}
+	return 1
+}
+
+// severityFromScore maps a CVSS base score onto the qualitative scale. CVSS v2
+// has no "critical" band, so v2 scores top out at HIGH.
+func severityFromScore(score float64, version int) string {
+	switch {
+	case score >= 9 && version >= 3:
+		return SeverityCritical
+	case score >= 7:
+		return SeverityHigh
+	case score >= 4:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// normalizeSeverity maps advisory-database ratings (GHSA uses MODERATE) onto
+// our labels.
+func normalizeSeverity(s string) string {
+	switch s = strings.ToUpper(strings.TrimSpace(s)); s {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow:
+		return s
+	case "MODERATE":
+		return SeverityMedium
+	}
+	return SeverityUnknown
+}
+
+// assessSeverity scores an OSV record. A computable CVSS vector wins (v3 over
+// v2); otherwise the advisory database's own rating is used.
+func assessSeverity(v OSVVuln) (label string, score float64, vector string) {
+	best := 0
+	for _, s := range v.Severity {
+		sc, version, err := cvssBaseScore(s.Score)
+		if err != nil || version <= best {
+			continue
+		}
+		best = version
+		label, score, vector = severityFromScore(sc, version), sc, s.Score
+	}
+	if best > 0 {
+		return label, score, vector
+	}
+	return normalizeSeverity(v.DatabaseSpecific.Severity), 0, ""
+}
+
+// FailOnLevels lists the values accepted by --fail-on.
+var FailOnLevels = []string{"critical", "high", "medium", "low", "any"}
+
+// ValidFailOn reports whether level is one of FailOnLevels.
+func ValidFailOn(level string) bool {
+	for _, l := range FailOnLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// MeetsThreshold reports whether a finding with the given severity label
+// should fail the scan under --fail-on=level. "any" matches everything,
+// including findings whose severity is unknown.
+func MeetsThreshold(label, level string) bool {
+	if level == "any" {
+		return true
+	}
+	return severityRank(label) >= severityRank(strings.ToUpper(level))
+}
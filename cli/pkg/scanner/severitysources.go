@@ -0,0 +1,266 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Default endpoints of the severity sources FillSeverity falls back to.
+const (
+	DefaultGHSAURL = "https://api.github.com/advisories"
+	DefaultNVDURL  = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+)
+
+// Severity sources, as recorded in Vulnerability.SeveritySource.
+const (
+	SeveritySourceGHSA = "ghsa"
+	SeveritySourceNVD  = "nvd"
+)
+
+const cacheBucketSeverity = "severity" // CVSS vectors per source and advisory ID
+
+// SeverityOptions configures FillSeverity.
+type SeverityOptions struct {
+	Client *http.Client // nil means http.DefaultClient
+	Cache  *Cache       // nil caches nothing
+
+	GHSAURL     string // DefaultGHSAURL if empty
+	GitHubToken string // optional; raises GitHub's rate limit
+	NVDURL      string // DefaultNVDURL if empty
+	NVDAPIKey   string // optional; raises NVD's rate limit
+}
+
+// severityRecord is what a source says about an advisory: a CVSS vector,
+// or failing that a qualitative rating. Both are empty when it has
+// nothing, which is cached too.
+type severityRecord struct {
+	Vector   string `json:"vector,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// errNoRecord is returned by a source that doesn't know the advisory.
+var errNoRecord = errors.New("no such advisory")
+
+// FillSeverity looks up a CVSS vector for each of r's advisories whose OSV
+// record has none: first in the GitHub Advisory Database, by the advisory's
+// GHSA ID, then in the NVD, by its CVE. The first vector found scores the
+// advisory and sets its Severity and SeveritySource; failing one, a GHSA
+// rating replaces an UNKNOWN severity. Lookups are cached. It returns how
+// many advisories it scored and the first error, carrying on past errors
+// until ctx is done.
+func FillSeverity(ctx context.Context, r *Report, opts SeverityOptions) (int, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.GHSAURL == "" {
+		opts.GHSAURL = DefaultGHSAURL
+	}
+	if opts.NVDURL == "" {
+		opts.NVDURL = DefaultNVDURL
+	}
+
+	filled := 0
+	var firstErr error
+	for _, p := range r.Reports() {
+		for i := range p.Findings {
+			for j := range p.Findings[i].Vulns {
+				v := &p.Findings[i].Vulns[j]
+				if v.CVSS != "" || v.Error != "" {
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					return filled, err
+				}
+				ok, err := fillVulnSeverity(ctx, v, opts)
+				if ok {
+					filled++
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+	}
+	return filled, firstErr
+}
+
+func fillVulnSeverity(ctx context.Context, v *Vulnerability, opts SeverityOptions) (bool, error) {
+	var firstErr error
+	rating := ""
+	for _, id := range append([]string{v.ID}, v.Aliases...) {
+		if !strings.HasPrefix(id, "GHSA-") {
+			continue
+		}
+		rec, err := lookupSeverity(ctx, opts, SeveritySourceGHSA, id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if scoreFromSource(v, rec.Vector, SeveritySourceGHSA) {
+			return true, nil
+		}
+		if rating == "" {
+			rating = normalizeSeverity(rec.Severity)
+		}
+	}
+	for _, id := range v.CVEs() {
+		rec, err := lookupSeverity(ctx, opts, SeveritySourceNVD, id)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if scoreFromSource(v, rec.Vector, SeveritySourceNVD) {
+			return true, nil
+		}
+	}
+	if rating != "" && rating != SeverityUnknown && (v.Severity == "" || v.Severity == SeverityUnknown) {
+		v.Severity, v.SeveritySource = rating, SeveritySourceGHSA
+		return true, firstErr
+	}
+	return false, firstErr
+}
+
+// scoreFromSource scores v with vector from source, if it can be computed.
+func scoreFromSource(v *Vulnerability, vector, source string) bool {
+	if vector == "" {
+		return false
+	}
+	score, version, err := cvssBaseScore(vector)
+	if err != nil {
+		return false
+	}
+	v.Severity, v.Score, v.CVSS, v.SeveritySource = severityFromScore(score, version), score, vector, source
+	return true
+}
+
+// lookupSeverity returns what source says about id, from the cache when it
+// can. An advisory the source doesn't know is an empty record, not an error.
+func lookupSeverity(ctx context.Context, opts SeverityOptions, source, id string) (severityRecord, error) {
+	key := source + "\x00" + id
+	var rec severityRecord
+	if data, ok := opts.Cache.get(cacheBucketSeverity, key); ok && json.Unmarshal(data, &rec) == nil {
+		return rec, nil
+	}
+	var err error
+	if source == SeveritySourceGHSA {
+		rec, err = fetchGHSASeverity(ctx, opts, id)
+	} else {
+		rec, err = fetchNVDSeverity(ctx, opts, id)
+	}
+	if errors.Is(err, errNoRecord) {
+		rec, err = severityRecord{}, nil
+	}
+	if err != nil {
+		return rec, err
+	}
+	if data, err := json.Marshal(rec); err == nil {
+		opts.Cache.put(cacheBucketSeverity, key, data)
+	}
+	return rec, nil
+}
+
+func fetchGHSASeverity(ctx context.Context, opts SeverityOptions, id string) (severityRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(opts.GHSAURL, "/")+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return severityRecord{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if opts.GitHubToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.GitHubToken)
+	}
+	var doc struct {
+		Severity       string `json:"severity"`
+		CVSSSeverities struct {
+			V3 struct {
+				Vector string `json:"vector_string"`
+			} `json:"cvss_v3"`
+		} `json:"cvss_severities"`
+		CVSS struct {
+			Vector string `json:"vector_string"`
+		} `json:"cvss"`
+	}
+	if err := getSeverityJSON(opts.Client, req, "GitHub Advisory Database", &doc); err != nil {
+		return severityRecord{}, err
+	}
+	rec := severityRecord{Vector: doc.CVSSSeverities.V3.Vector, Severity: doc.Severity}
+	if rec.Vector == "" {
+		rec.Vector = doc.CVSS.Vector
+	}
+	return rec, nil
+}
+
+func fetchNVDSeverity(ctx context.Context, opts SeverityOptions, cve string) (severityRecord, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, opts.NVDURL+"?"+url.Values{"cveId": {cve}}.Encode(), nil)
+	if err != nil {
+		return severityRecord{}, err
+	}
+	if opts.NVDAPIKey != "" {
+		req.Header.Set("apiKey", opts.NVDAPIKey)
+	}
+	type metric struct {
+		Type     string `json:"type"`
+		CVSSData struct {
+			Vector string `json:"vectorString"`
+		} `json:"cvssData"`
+	}
+	var doc struct {
+		Vulnerabilities []struct {
+			CVE struct {
+				Metrics struct {
+					V31 []metric `json:"cvssMetricV31"`
+					V30 []metric `json:"cvssMetricV30"`
+					V2  []metric `json:"cvssMetricV2"`
+				} `json:"metrics"`
+			} `json:"cve"`
+		} `json:"vulnerabilities"`
+	}
+	if err := getSeverityJSON(opts.Client, req, "NVD", &doc); err != nil {
+		return severityRecord{}, err
+	}
+	if len(doc.Vulnerabilities) == 0 {
+		return severityRecord{}, errNoRecord
+	}
+	// NVD's own ("Primary") assessment over a CNA's, newer CVSS over older.
+	m := doc.Vulnerabilities[0].CVE.Metrics
+	for _, metrics := range [][]metric{m.V31, m.V30, m.V2} {
+		vector := ""
+		for _, x := range metrics {
+			if vector == "" || x.Type == "Primary" {
+				vector = x.CVSSData.Vector
+			}
+		}
+		if vector != "" {
+			return severityRecord{Vector: vector}, nil
+		}
+	}
+	return severityRecord{}, nil
+}
+
+// getSeverityJSON sends req and decodes the JSON response into v. A 404 is
+// errNoRecord.
+func getSeverityJSON(client *http.Client, req *http.Request, what string, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", what, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNoRecord
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%s returned %s", what, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("bad %s response: %w", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of supply-chain warning.
+const (
+	SupplyChainTyposquat     = "typosquat"
+	SupplyChainNewPublisher  = "new-publisher"
+	SupplyChainNewRelease    = "new-release"
+	SupplyChainInstallScript = "install-script"
+	SupplyChainUnsigned      = "unsigned"
+	SupplyChainBadSignature  = "bad-signature"
+	SupplyChainNoProvenance  = "no-provenance"
+	SupplyChainBadProvenance = "bad-provenance"
+	SupplyChainUnpinned      = "unpinned"
+	SupplyChainDeprecated    = "deprecated"
+)
+
+// supplyChainSeverity is how much each kind of warning should worry a
+// reviewer: a name imitating a popular package is rarely innocent, while
+// install scripts are common in legitimate native packages, and most
+// packages are still published without provenance. A deprecated package
+// isn't malicious, but no longer gets security fixes.
+var supplyChainSeverity = map[string]string{
+	SupplyChainTyposquat:     SeverityHigh,
+	SupplyChainNewPublisher:  SeverityMedium,
+	SupplyChainNewRelease:    SeverityLow,
+	SupplyChainInstallScript: SeverityLow,
+	SupplyChainUnsigned:      SeverityMedium,
+	SupplyChainBadSignature:  SeverityHigh,
+	SupplyChainNoProvenance:  SeverityLow,
+	SupplyChainBadProvenance: SeverityHigh,
+	SupplyChainUnpinned:      SeverityMedium,
+	SupplyChainDeprecated:    SeverityMedium,
+}
+
+// DefaultNewReleaseAge is how recent a release has to be to be warned about.
+// Malicious versions tend to be caught and unpublished within days.
+const DefaultNewReleaseAge = 72 * time.Hour
+
+// SupplyChainWarning is a sign that a package may be malicious, or left
+// unmaintained, rather than a known vulnerability.
+type SupplyChainWarning struct {
+	Kind      string `json:"kind"`
+	Severity  string `json:"severity"`
+	Ecosystem string `json:"ecosystem"`
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	Detail    string `json:"detail"`
+}
+
+// CheckSupplyChain records on each of r's reports the packages that look
+// suspicious: named one typo away from a popular package, running install
+// scripts, or, given releases from FetchNpmReleases, published less than
+// newRelease ago, by someone who hadn't published the package before, or
+// deprecated by its maintainers.
+// Releases checked by VerifyNpmReleases are also warned about when their
+// signature or provenance is missing or doesn't verify, and GitHub Actions
+// and Terraform modules when they aren't pinned.
+func CheckSupplyChain(r *Report, releases map[string]NpmRelease, newRelease time.Duration, now time.Time) {
+	for _, rep := range r.Reports() {
+		rep.SupplyChain = nil
+		seen := map[string]bool{}
+		for _, d := range rep.Packages {
+			key := d.Ecosystem + "/" + d.Name + "@" + d.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			warn := func(kind, detail string) {
+				rep.SupplyChain = append(rep.SupplyChain, SupplyChainWarning{
+					Kind: kind, Severity: supplyChainSeverity[kind], Ecosystem: d.Ecosystem, Package: d.Name, Version: d.Version, Detail: detail,
+				})
+			}
+
+			if popular, ok := typosquatTarget(d.Ecosystem, d.Name); ok {
+				warn(SupplyChainTyposquat, fmt.Sprintf("name is one typo away from the popular package %q", popular))
+			}
+			if rel, ok := releases[key]; ok {
+				if rel.NewPublisher {
+					warn(SupplyChainNewPublisher, fmt.Sprintf("published by %s, who hadn't published an earlier version", rel.Publisher))
+				}
+				if age := now.Sub(rel.Published); age < newRelease {
+					warn(SupplyChainNewRelease, fmt.Sprintf("published %s ago", age.Round(time.Hour)))
+				}
+				if rel.Deprecated != "" {
+					warn(SupplyChainDeprecated, fmt.Sprintf("its maintainers say: %q", rel.Deprecated))
+				}
+				switch rel.Signature {
+				case VerificationMissing:
+					warn(SupplyChainUnsigned, "the registry signs its packages, but not this version")
+				case VerificationFailed:
+					warn(SupplyChainBadSignature, rel.SignatureProblem)
+				}
+				switch rel.Provenance {
+				case VerificationMissing:
+					warn(SupplyChainNoProvenance, "published without a provenance attestation")
+				case VerificationFailed:
+					warn(SupplyChainBadProvenance, rel.ProvenanceProblem)
+				}
+			}
+			if d.InstallScript {
+				warn(SupplyChainInstallScript, "runs a script when installed")
+			}
+			if d.Unpinned {
+				if d.Ecosystem == "Terraform" {
+					warn(SupplyChainUnpinned, "referenced by a version range or a Git tag or branch, which can change to other code, rather than an exact version or commit SHA")
+				} else {
+					warn(SupplyChainUnpinned, "referenced by a tag or branch, which can be moved to other code, rather than a full commit SHA")
+				}
+			}
+		}
+	}
+}
+
+// SupplyChainCount counts the warnings at or above level, a --fail-on level.
+func (r *Report) SupplyChainCount(level string) int {
+	n := 0
+	for _, p := range r.Reports() {
+		for _, w := range p.SupplyChain {
+			if MeetsThreshold(w.Severity, level) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// typosquatTarget returns the popular package of ecosystem that name
+// imitates: one edit (an insertion, deletion, substitution or swap of
+// neighbouring characters) away, or the same but for separators, as in
+// "cross_env" for "cross-env". Short names are too close to each other by
+// chance to judge.
+func typosquatTarget(ecosystem, name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, p := range popularPackages[ecosystem] {
+		if strings.EqualFold(p, name) {
+			return "", false // it is the popular package
+		}
+	}
+	for _, p := range popularPackages[ecosystem] {
+		if len(p) < 5 {
+			continue
+		}
+		if stripSeparators(lower) == stripSeparators(p) || editDistanceOne(lower, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func stripSeparators(s string) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "").Replace(s)
+}
+
+// editDistanceOne reports whether a and b differ by exactly one insertion,
+// deletion, substitution or transposition of adjacent characters.
+func editDistanceOne(a, b string) bool {
+	if a == b {
+		return false
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(b)-len(a) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(a) && a[i] == b[i] {
+		i++
+	}
+	if len(a) == len(b) {
+		if a[i+1:] == b[i+1:] {
+			return true // substitution
+		}
+		return i+1 < len(a) && a[i] == b[i+1] && a[i+1] == b[i] && a[i+2:] == b[i+2:]
+	}
+	return a[i:] == b[i+1:] // insertion
+}
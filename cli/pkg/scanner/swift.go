@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// packageResolved is a SwiftPM Package.resolved: version 1 nests its pins
+// under "object", versions 2 and 3 list them at the top level.
+type packageResolved struct {
+	Pins   []swiftPin `json:"pins"`
+	Object struct {
+		Pins []swiftPin `json:"pins"`
+	} `json:"object"`
+}
+
+type swiftPin struct {
+	Location      string `json:"location"`      // v2 and later
+	RepositoryURL string `json:"repositoryURL"` // v1
+	Kind          string `json:"kind"`
+	State         struct {
+		Version string `json:"version"`
+	} `json:"state"`
+}
+
+// extractPackageResolved reads the pins of a Package.resolved, named the
+// way OSV's SwiftURL ecosystem names packages: by their repository URL
+// without the scheme or ".git", e.g. "github.com/apple/swift-nio". Pins to
+// a branch or revision have no version to look up and are skipped, as are
+// local packages and registry ones, which have no repository URL.
+func extractPackageResolved(data []byte) ([]Package, error) {
+	var resolved packageResolved
+	if err := json.Unmarshal(data, &resolved); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	var out []Package
+	for _, pin := range append(resolved.Pins, resolved.Object.Pins...) {
+		if pin.Kind == "registry" || pin.Kind == "localSourceControl" || pin.State.Version == "" {
+			continue
+		}
+		name := swiftURLName(firstNonEmpty(pin.Location, pin.RepositoryURL))
+		if name == "" {
+			continue
+		}
+		out = append(out, Package{Ecosystem: "SwiftURL", Name: name, Version: pin.State.Version})
+	}
+	return out, nil
+}
+
+// swiftURLName turns a repository URL, such as
+// "https://github.com/apple/swift-nio.git" or
+// "git@github.com:apple/swift-nio.git", into "github.com/apple/swift-nio".
+func swiftURLName(url string) string {
+	url = strings.TrimSpace(url)
+	if _, rest, ok := strings.Cut(url, "://"); ok {
+		url = rest
+	} else if host, path, ok := strings.Cut(url, ":"); ok && strings.Contains(host, "@") {
+		url = host + "/" + path
+	}
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	host, path, ok := strings.Cut(url, "/")
+	if !ok || host == "" || path == "" {
+		return ""
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:] // user info
+	}
+	return strings.ToLower(host) + "/" + path
+}
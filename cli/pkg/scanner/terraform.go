@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// terraformRegistries are the hosts of the public provider and module
+// registries, whose providers are published from GitHub repositories named
+// terraform-provider-<type>.
+var terraformRegistries = map[string]bool{
+	"registry.terraform.io": true,
+	"registry.opentofu.org": true,
+}
+
+// terraformExactVersion is a module version constraint that allows a single
+// version, "5.1.0" or "= 5.1.0".
+var terraformExactVersion = regexp.MustCompile(`^=?\s*v?(\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?)$`)
+
+// extractTerraformLock reads a .terraform.lock.hcl and the module blocks of
+// the configuration beside it.
+//
+//	provider "registry.terraform.io/hashicorp/aws" {
+//	  version     = "5.31.0"
+//	  constraints = "~> 5.0"
+//	  ...
+//	}
+//
+// Providers are Go programs, and a registry provider is looked up as the Go
+// module of its repository, github.com/hashicorp/terraform-provider-aws.
+// Providers from other registries are skipped. Modules are listed under the
+// Terraform ecosystem, which OSV has no advisories for, so that unpinned
+// ones can be warned about; see extractTerraformModules.
+func extractTerraformLock(path string, data []byte) ([]Package, error) {
+	var out []Package
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var provider string
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if addr, ok := strings.CutPrefix(line, "provider "); ok {
+			provider = unquote(strings.TrimSuffix(addr, "{"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || provider == "" || strings.TrimSpace(key) != "version" {
+			continue
+		}
+		parts := strings.Split(provider, "/")
+		if len(parts) == 3 && terraformRegistries[parts[0]] {
+			p := goDep("github.com/"+parts[1]+"/terraform-provider-"+parts[2], unquote(value))
+			p.Direct = true
+			out = append(out, p)
+		}
+		provider = ""
+	}
+
+	modules, err := extractTerraformModules(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, modules...), nil
+}
+
+// terraformModulesJSON is .terraform/modules/modules.json, where
+// "terraform init" records the modules it installed.
+type terraformModulesJSON struct {
+	Modules []struct {
+		Key     string `json:"Key"`
+		Version string `json:"Version"`
+	} `json:"Modules"`
+}
+
+// extractTerraformModules reads the module blocks of the .tf files in dir.
+// A registry module ("terraform-aws-modules/vpc/aws") is pinned by a
+// version constraint allowing a single version; one with a range, or none,
+// gets whichever version is newest when "terraform init" runs, which is the
+// version reported if .terraform/modules/modules.json records it. A Git
+// module ("git::https://github.com/org/repo.git?ref=v1.2.0") is pinned by a
+// commit SHA ref; tags and branches can be moved. Local paths and archives
+// are skipped.
+func extractTerraformModules(dir string) ([]Package, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]string{} // module name → installed version
+	if data, err := os.ReadFile(filepath.Join(dir, ".terraform", "modules", "modules.json")); err == nil {
+		var m terraformModulesJSON
+		if json.Unmarshal(data, &m) == nil {
+			for _, mod := range m.Modules {
+				installed[mod.Key] = mod.Version
+			}
+		}
+	}
+
+	var out []Package
+	seen := map[string]bool{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range terraformModuleBlocks(data) {
+			p, ok := terraformModule(b.source, b.version, installed[b.name])
+			if !ok || seen[p.Name+"@"+p.Version] {
+				continue
+			}
+			seen[p.Name+"@"+p.Version] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// terraformModule turns a module's source and version constraint into a
+// package, given the version "terraform init" installed, if known.
+func terraformModule(source, constraint, installed string) (Package, bool) {
+	p := Package{Ecosystem: "Terraform", Direct: true}
+	switch {
+	case strings.HasPrefix(source, "./"), strings.HasPrefix(source, "../"):
+		return p, false
+	case strings.HasPrefix(source, "git::"), strings.HasPrefix(source, "github.com/"),
+		strings.HasPrefix(source, "bitbucket.org/"), strings.HasPrefix(source, "git@"):
+		repo, query, _ := strings.Cut(strings.TrimPrefix(source, "git::"), "?")
+		for _, prefix := range []string{"https://", "http://", "ssh://", "git@"} {
+			repo = strings.TrimPrefix(repo, prefix)
+		}
+		repo, _, _ = strings.Cut(repo, "//") // a module in a subdirectory
+		p.Name = strings.TrimSuffix(strings.Replace(repo, ":", "/", 1), ".git")
+		p.Version = "HEAD"
+		for _, param := range strings.Split(query, "&") {
+			if ref, ok := strings.CutPrefix(param, "ref="); ok {
+				p.Version = ref
+			}
+		}
+		p.Unpinned = !actionsCommitSHA.MatchString(p.Version)
+		return p, true
+	}
+
+	// A registry module: [host/]namespace/name/system[//subdir].
+	addr, _, _ := strings.Cut(source, "//")
+	parts := strings.Split(addr, "/")
+	if len(parts) == 4 && terraformRegistries[parts[0]] {
+		parts = parts[1:]
+	}
+	if (len(parts) != 3 && len(parts) != 4) || strings.Contains(addr, "::") || strings.Contains(parts[0], ":") {
+		return p, false // an archive, bucket or other non-registry source
+	}
+	p.Name = strings.Join(parts, "/")
+	if m := terraformExactVersion.FindStringSubmatch(strings.TrimSpace(constraint)); m != nil {
+		p.Version = m[1]
+		return p, true
+	}
+	p.Unpinned = true
+	p.Version = firstNonEmpty(installed, strings.TrimSpace(constraint), "latest")
+	return p, true
+}
+
+type terraformModuleBlock struct {
+	name, source, version string
+}
+
+// terraformModuleBlocks finds the top-level module blocks of a .tf file and
+// their source and version arguments. It reads the HCL line by line, which
+// is how terraform fmt lays it out.
+func terraformModuleBlocks(data []byte) []terraformModuleBlock {
+	var (
+		out   []terraformModuleBlock
+		depth int
+		block *terraformModuleBlock
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if depth == 0 {
+			if rest, ok := strings.CutPrefix(line, "module "); ok && strings.HasSuffix(line, "{") {
+				out = append(out, terraformModuleBlock{name: unquote(strings.TrimSpace(strings.TrimSuffix(rest, "{")))})
+				block = &out[len(out)-1]
+				depth = 1
+				continue
+			}
+			block = nil
+		}
+		if depth == 1 && block != nil {
+			if key, value, ok := strings.Cut(line, "="); ok {
+				switch strings.TrimSpace(key) {
+				case "source":
+					block.source = unquote(value)
+				case "version":
+					block.version = unquote(value)
+				}
+			}
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth < 0 {
+			depth = 0
+		}
+	}
+	return out
+}
@@ -0,0 +1,222 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Tracer collects the spans of a scan, which ExportOTLP sends to an
+// OpenTelemetry collector. Scanning records spans only with a Tracer in its
+// context, put there by WithTracer; without one, StartSpan does nothing.
+type Tracer struct {
+	mu      sync.Mutex
+	traceID [16]byte
+	parent  [8]byte // of the span that started the trace elsewhere, if any
+	spans   []*Span
+}
+
+// NewTracer returns a Tracer for a new trace or, given a W3C traceparent
+// such as CI sets in $TRACEPARENT, one continuing that trace, whose spans
+// the scan's root span is then a child of.
+func NewTracer(traceparent string) *Tracer {
+	t := &Tracer{}
+	if m := traceparentRe.FindStringSubmatch(traceparent); m != nil {
+		hex.Decode(t.traceID[:], []byte(m[1]))
+		hex.Decode(t.parent[:], []byte(m[2]))
+	} else {
+		rand.Read(t.traceID[:])
+	}
+	return t
+}
+
+var traceparentRe = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// Spans returns the spans ended so far.
+func (t *Tracer) Spans() []*Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Span(nil), t.spans...)
+}
+
+// Span is a timed step of a scan. A nil *Span, as StartSpan returns without
+// a Tracer, ignores every call.
+type Span struct {
+	tracer *Tracer
+	id     [8]byte
+	parent [8]byte
+
+	Name  string
+	Start time.Time
+	End   time.Time
+	Attrs map[string]any // strings, bools, ints and float64s
+	Error string
+}
+
+type (
+	tracerKey struct{}
+	spanKey   struct{}
+)
+
+// WithTracer returns a copy of ctx that spans are recorded to t in.
+func WithTracer(ctx context.Context, t *Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, t)
+}
+
+// StartSpan starts a span named name, a child of the span ctx carries, and
+// returns a copy of ctx carrying it. attrs are key, value pairs.
+func StartSpan(ctx context.Context, name string, attrs ...any) (context.Context, *Span) {
+	t, _ := ctx.Value(tracerKey{}).(*Tracer)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, Name: name, Start: time.Now(), parent: t.parent, Attrs: map[string]any{}}
+	if p, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.parent = p.id
+	}
+	rand.Read(s.id[:])
+	s.SetAttrs(attrs...)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttrs sets attributes of the span from key, value pairs.
+func (s *Span) SetAttrs(attrs ...any) {
+	if s == nil {
+		return
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		if k, ok := attrs[i].(string); ok {
+			s.Attrs[k] = attrs[i+1]
+		}
+	}
+}
+
+// Fail marks the span as failed with err, if it isn't nil.
+func (s *Span) Fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Error = err.Error()
+}
+
+// Finish ends the span and records it in its Tracer.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
+
+// ExportOTLP sends the spans t has recorded to an OpenTelemetry collector's
+// OTLP/HTTP traces endpoint (http://collector:4318/v1/traces), encoded as
+// JSON, with headers such as an API key added to the request.
+func ExportOTLP(ctx context.Context, client *http.Client, endpoint, service string, headers map[string]string, t *Tracer) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var spans []otlpSpan
+	for _, s := range t.Spans() {
+		o := otlpSpan{
+			TraceID: hex.EncodeToString(t.traceID[:]),
+			SpanID:  hex.EncodeToString(s.id[:]),
+			Name:    s.Name,
+			Kind:    1, // internal
+			Start:   strconv.FormatInt(s.Start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, k := range sortedKeys(s.Attrs) {
+			o.Attributes = append(o.Attributes, otlpAttr(k, s.Attrs[k]))
+		}
+		if s.Error != "" {
+			o.Status = &otlpStatus{Code: 2, Message: s.Error}
+		}
+		spans = append(spans, o)
+	}
+	payload, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpKeyValue{otlpAttr("service.name", service)}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "keystone"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s %s", endpoint, resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// otlpSpan is a span in OTLP's JSON encoding, which writes IDs in hex and
+// 64-bit integers as strings.
+type otlpSpan struct {
+	TraceID      string         `json:"traceId"`
+	SpanID       string         `json:"spanId"`
+	ParentSpanID string         `json:"parentSpanId,omitempty"`
+	Name         string         `json:"name"`
+	Kind         int            `json:"kind"`
+	Start        string         `json:"startTimeUnixNano"`
+	End          string         `json:"endTimeUnixNano"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	Status       *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2: error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func otlpAttr(key string, v any) otlpKeyValue {
+	var value map[string]any
+	switch v := v.(type) {
+	case bool:
+		value = map[string]any{"boolValue": v}
+	case int:
+		value = map[string]any{"intValue": strconv.Itoa(v)}
+	case int64:
+		value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		value = map[string]any{"doubleValue": v}
+	default:
+		value = map[string]any{"stringValue": fmt.Sprint(v)}
+	}
+	return otlpKeyValue{Key: key, Value: value}
+}
@@ -0,0 +1,360 @@
+package scanner
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of install integrity problem.
+const (
+	IntegrityTarball  = "tarball"  // the archive doesn't hash to the lockfile's integrity
+	IntegrityModified = "modified" // an installed file differs from the archive's
+	IntegrityMissing  = "missing"  // a file of the archive isn't installed
+	IntegrityAdded    = "added"    // an installed file isn't in the archive
+)
+
+// IntegrityProblem is a difference between what a lockfile says a package
+// is and what is installed.
+type IntegrityProblem struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Path    string `json:"path"` // install directory, e.g. "node_modules/lodash"
+	Kind    string `json:"kind"`
+	File    string `json:"file,omitempty"` // within the install directory
+	Detail  string `json:"detail,omitempty"`
+}
+
+// InstallVerification is the result of VerifyInstall.
+type InstallVerification struct {
+	Lockfile string             `json:"lockfile"`
+	Verified int                `json:"verified"` // packages installed exactly as archived
+	Problems []IntegrityProblem `json:"problems,omitempty"`
+
+	// Unchecked lists the packages that couldn't be verified, as
+	// "path: why".
+	Unchecked []string `json:"unchecked,omitempty"`
+}
+
+// VerifyOptions configures VerifyInstall.
+type VerifyOptions struct {
+	Client   *http.Client // for archives missing from the cache; nil means http.DefaultClient
+	CacheDir string       // npm's cache; DefaultNpmCacheDir() if empty
+	Offline  bool         // check only archives found in the cache
+}
+
+// DefaultNpmCacheDir returns where npm keeps its cache: $npm_config_cache,
+// or ~/.npm (%LocalAppData%\npm-cache on Windows).
+func DefaultNpmCacheDir() string {
+	if dir := os.Getenv("npm_config_cache"); dir != "" {
+		return dir
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("LocalAppData"), "npm-cache")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".npm")
+}
+
+// VerifyInstall checks every package of projectDir's package-lock.json (or
+// npm-shrinkwrap.json) against what is installed under node_modules. Each
+// package's archive, from npm's cache or else downloaded from the
+// lockfile's "resolved" URL, must hash to the lockfile's integrity, and the
+// installed files must be the archive's: none changed, none missing, and,
+// for packages without install scripts, which may build files of their own,
+// none added.
+func VerifyInstall(projectDir string, opts VerifyOptions) (*InstallVerification, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = DefaultNpmCacheDir()
+	}
+
+	var (
+		lockPath string
+		data     []byte
+		err      error
+	)
+	for _, name := range []string{"npm-shrinkwrap.json", "package-lock.json"} {
+		lockPath = filepath.Join(projectDir, name)
+		if data, err = os.ReadFile(lockPath); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no package-lock.json or npm-shrinkwrap.json in %s", projectDir)
+	}
+	var lock struct {
+		Packages map[string]map[string]any `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("%s: %w", lockPath, err)
+	}
+	if lock.Packages == nil {
+		return nil, fmt.Errorf("%s is a v1 lockfile, which doesn't record where packages are installed; run npm install with npm 7 or later", lockPath)
+	}
+
+	out := &InstallVerification{Lockfile: lockPath}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, npmResolveConcurrency)
+	)
+	for _, k := range sortedKeys(lock.Packages) {
+		e := lock.Packages[k]
+		if !strings.Contains(k, "node_modules/") {
+			continue
+		}
+		if link, _ := e["link"].(bool); link {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(k string, e map[string]any) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			problems, why := verifyInstalledPackage(projectDir, k, e, opts)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case why != "":
+				out.Unchecked = append(out.Unchecked, k+": "+why)
+			case len(problems) > 0:
+				out.Problems = append(out.Problems, problems...)
+			default:
+				out.Verified++
+			}
+		}(k, e)
+	}
+	wg.Wait()
+
+	sort.Strings(out.Unchecked)
+	sort.Slice(out.Problems, func(i, j int) bool {
+		a, b := out.Problems[i], out.Problems[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.File < b.File
+	})
+	return out, nil
+}
+
+// verifyInstalledPackage compares the package installed at lockfile key k
+// with its archive, or says why it can't.
+func verifyInstalledPackage(projectDir, k string, e map[string]any, opts VerifyOptions) ([]IntegrityProblem, string) {
+	dir := filepath.Join(projectDir, filepath.FromSlash(k))
+	if _, err := os.Stat(dir); err != nil {
+		if optional, _ := e["optional"].(bool); optional {
+			return nil, "optional, not installed"
+		}
+		return nil, "not installed"
+	}
+	if bundled, _ := e["inBundle"].(bool); bundled {
+		return nil, "bundled with the package that depends on it"
+	}
+	integrity, _ := e["integrity"].(string)
+	if integrity == "" {
+		return nil, "no integrity hash in the lockfile"
+	}
+	resolved, _ := e["resolved"].(string)
+	version, _ := e["version"].(string)
+	problem := func(kind, file, detail string) IntegrityProblem {
+		return IntegrityProblem{Package: NpmEntryName(k, e), Version: version, Path: k, Kind: kind, File: file, Detail: detail}
+	}
+
+	archive, source, err := npmArchive(integrity, resolved, opts)
+	if err != nil {
+		return nil, err.Error()
+	}
+	ok, err := integrityMatches(integrity, archive)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if !ok {
+		return []IntegrityProblem{problem(IntegrityTarball, "", "the archive from "+source+" doesn't match the lockfile's integrity hash")}, ""
+	}
+	files, err := archiveFiles(archive)
+	if err != nil {
+		return nil, "unreadable archive: " + err.Error()
+	}
+
+	var problems []IntegrityProblem
+	for _, name := range sortedKeys(files) {
+		installed, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, problem(IntegrityMissing, name, ""))
+		case err != nil:
+			return nil, err.Error()
+		case sha256.Sum256(installed) != files[name]:
+			problems = append(problems, problem(IntegrityModified, name, ""))
+		}
+	}
+	if script, _ := e["hasInstallScript"].(bool); !script {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if d.Name() == "node_modules" {
+					return filepath.SkipDir // its own dependencies
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err == nil {
+				if _, ok := files[filepath.ToSlash(rel)]; !ok {
+					problems = append(problems, problem(IntegrityAdded, filepath.ToSlash(rel), ""))
+				}
+			}
+			return nil
+		})
+	}
+	return problems, ""
+}
+
+// sriHashes maps the Subresource Integrity algorithms npm uses to their
+// hashes, weakest first.
+var sriHashes = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha1", sha1.New},
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// strongestIntegrity returns the algorithm and digests of the strongest
+// algorithm in a Subresource Integrity string, which is the one to check.
+func strongestIntegrity(integrity string) (string, [][]byte) {
+	best, rank := "", -1
+	var digests [][]byte
+	for _, h := range strings.Fields(integrity) {
+		alg, sum, ok := strings.Cut(h, "-")
+		if !ok {
+			continue
+		}
+		digest, err := base64.StdEncoding.DecodeString(sum)
+		if err != nil {
+			continue
+		}
+		for i, s := range sriHashes {
+			if s.name != alg || i < rank {
+				continue
+			}
+			if i > rank {
+				best, rank, digests = alg, i, nil
+			}
+			digests = append(digests, digest)
+		}
+	}
+	return best, digests
+}
+
+// integrityMatches reports whether data has one of the digests of
+// integrity's strongest algorithm.
+func integrityMatches(integrity string, data []byte) (bool, error) {
+	alg, digests := strongestIntegrity(integrity)
+	for _, s := range sriHashes {
+		if s.name != alg {
+			continue
+		}
+		h := s.new()
+		h.Write(data)
+		sum := h.Sum(nil)
+		for _, d := range digests {
+			if bytes.Equal(d, sum) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported integrity hash %q", integrity)
+}
+
+// npmArchive finds a package's archive in npm's content-addressed cache,
+// or else downloads it, and says where it came from.
+func npmArchive(integrity, resolved string, opts VerifyOptions) ([]byte, string, error) {
+	alg, digests := strongestIntegrity(integrity)
+	for _, d := range digests {
+		sum := hex.EncodeToString(d)
+		p := filepath.Join(opts.CacheDir, "_cacache", "content-v2", alg, sum[:2], sum[2:4], sum[4:])
+		if data, err := os.ReadFile(p); err == nil {
+			return data, "npm's cache", nil
+		}
+	}
+	if !strings.HasPrefix(resolved, "http://") && !strings.HasPrefix(resolved, "https://") {
+		return nil, "", errors.New("archive not in npm's cache and the lockfile has no URL to download it from")
+	}
+	if opts.Offline {
+		return nil, "", errors.New("archive not in npm's cache")
+	}
+	resp, err := opts.Client.Get(resolved)
+	if err != nil {
+		return nil, "", fmt.Errorf("archive download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("archive download returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("archive download failed: %w", err)
+	}
+	return data, resolved, nil
+}
+
+// archiveFiles returns the SHA-256 of each regular file in an npm package
+// archive, by its path below the archive's top directory ("package/").
+func archiveFiles(archive []byte) (map[string][32]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	out := map[string][32]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		_, name, ok := strings.Cut(path.Clean(hdr.Name), "/")
+		if !ok || strings.HasPrefix(name, "../") {
+			continue
+		}
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return nil, err
+		}
+		var sum [32]byte
+		copy(sum[:], h.Sum(nil))
+		out[name] = sum
+	}
+}
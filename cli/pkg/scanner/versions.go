@@ -0,0 +1,272 @@
+package scanner
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions orders two versions of a package in the given ecosystem,
+// returning -1, 0 or +1. Semver ecosystems follow semver 2.0 precedence;
+// everything else uses a best-effort comparison that handles the common
+// conventions (numeric segments, pre-release tags sorting before the
+// release, post-release tags after it). Debian and Ubuntu versions, with or
+// without a release ("Debian:12"), follow dpkg's ordering.
+func CompareVersions(ecosystem, a, b string) int {
+	ecosystem, _, _ = strings.Cut(ecosystem, ":")
+	switch ecosystem {
+	case "Debian", "Ubuntu":
+		return compareDebian(a, b)
+	case "npm", "crates.io", "Go", "SEMVER", "Pub", "NuGet", "JSR", "GitHub Actions":
+		if sa, ok := parseSemver(a); ok {
+			if sb, ok := parseSemver(b); ok {
+				return sa.compare(sb)
+			}
+		}
+	}
+	return compareGeneric(a, b)
+}
+
+type semver struct {
+	core [3]uint64
+	pre  []string
+}
+
+func parseSemver(v string) (semver, bool) {
+	var s semver
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+") // build metadata never affects precedence
+	core, pre, hasPre := strings.Cut(v, "-")
+	if hasPre {
+		s.pre = strings.Split(pre, ".")
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return s, false
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return s, false
+		}
+		s.core[i] = n
+	}
+	return s, true
+}
+
+func (a semver) compare(b semver) int {
+	for i := range a.core {
+		if c := cmpUint(a.core[i], b.core[i]); c != 0 {
+			return c
+		}
+	}
+	// A pre-release sorts before the release itself.
+	switch {
+	case len(a.pre) == 0 && len(b.pre) == 0:
+		return 0
+	case len(a.pre) == 0:
+		return 1
+	case len(b.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.pre) && i < len(b.pre); i++ {
+		if c := comparePreIdent(a.pre[i], b.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return cmpInt(len(a.pre), len(b.pre))
+}
+
+// comparePreIdent compares semver pre-release identifiers: numeric ones
+// numerically and below alphanumeric ones, which compare as strings.
+func comparePreIdent(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmpUint(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Tags that mark a version as coming after the release it's attached to
+// ("1.0.post1", "1.0-p2"), or as being the release itself ("1.0.Final").
+var (
+	postReleaseTags = map[string]bool{"post": true, "p": true, "pl": true, "patch": true, "r": true, "rev": true, "sp": true}
+	releaseTags     = map[string]bool{"final": true, "ga": true, "release": true}
+)
+
+// compareGeneric splits versions into runs of digits and letters and compares
+// them piecewise, numbers numerically. Missing segments count as zeros, so
+// that "1.0" == "1.0.0" yet "2.0rc1" < "2.0".
+func compareGeneric(a, b string) int {
+	ta, tb := versionTokens(a), versionTokens(b)
+	for i := 0; i < len(ta) || i < len(tb); i++ {
+		switch {
+		case i >= len(ta):
+			if tb[i] == "0" {
+				continue
+			}
+			return -trailingTokenSign(tb[i])
+		case i >= len(tb):
+			if ta[i] == "0" {
+				continue
+			}
+			return trailingTokenSign(ta[i])
+		}
+		x, y := ta[i], tb[i]
+		nx, errX := strconv.ParseUint(x, 10, 64)
+		ny, errY := strconv.ParseUint(y, 10, 64)
+		switch {
+		case errX == nil && errY == nil:
+			if c := cmpUint(nx, ny); c != 0 {
+				return c
+			}
+		case errX == nil: // number vs tag: "1.0.1" > "1.0.rc1" unless it's a post tag
+			return -trailingTokenSign(y)
+		case errY == nil:
+			return trailingTokenSign(x)
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// trailingTokenSign says whether a version that has token t where the other
+// version has ended is newer (+1) or older (-1).
+func trailingTokenSign(t string) int {
+	if _, err := strconv.ParseUint(t, 10, 64); err == nil || postReleaseTags[t] {
+		return 1
+	}
+	return -1
+}
+
+func versionTokens(v string) []string {
+	var out []string
+	var cur strings.Builder
+	var curDigit bool
+	flush := func() {
+		if cur.Len() > 0 {
+			t := cur.String()
+			if !releaseTags[t] {
+				out = append(out, t)
+			}
+			cur.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(strings.TrimPrefix(v, "v")) {
+		switch {
+		case unicode.IsDigit(r):
+			if cur.Len() > 0 && !curDigit {
+				flush()
+			}
+			curDigit = true
+			cur.WriteRune(r)
+		case unicode.IsLetter(r):
+			if cur.Len() > 0 && curDigit {
+				flush()
+			}
+			curDigit = false
+			cur.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// compareDebian orders Debian package versions, "[epoch:]upstream[-revision]",
+// as dpkg does.
+func compareDebian(a, b string) int {
+	ea, ua, ra := splitDebianVersion(a)
+	eb, ub, rb := splitDebianVersion(b)
+	if c := cmpUint(ea, eb); c != 0 {
+		return c
+	}
+	if c := compareDpkg(ua, ub); c != 0 {
+		return c
+	}
+	return compareDpkg(ra, rb)
+}
+
+func splitDebianVersion(v string) (epoch uint64, upstream, revision string) {
+	if e, rest, ok := strings.Cut(v, ":"); ok {
+		if n, err := strconv.ParseUint(e, 10, 64); err == nil {
+			epoch, v = n, rest
+		}
+	}
+	if i := strings.LastIndexByte(v, '-'); i >= 0 {
+		return epoch, v[:i], v[i+1:]
+	}
+	return epoch, v, ""
+}
+
+// compareDpkg compares upstream versions or revisions the way dpkg's
+// verrevcmp does: alternately runs of non-digits, where letters sort before
+// other characters and "~" before anything, even the end ("1.0~rc1" <
+// "1.0"), and runs of digits, numerically.
+func compareDpkg(a, b string) int {
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			if c := cmp.Compare(dpkgOrder(a), dpkgOrder(b)); c != 0 {
+				return c
+			}
+			a, b = a[1:], b[1:] // neither is at its end, which orders 0
+		}
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		first := 0
+		for ; a != "" && isDigit(a[0]) && b != "" && isDigit(b[0]); a, b = a[1:], b[1:] {
+			if first == 0 {
+				first = cmp.Compare(a[0], b[0])
+			}
+		}
+		switch {
+		case a != "" && isDigit(a[0]):
+			return 1
+		case b != "" && isDigit(b[0]):
+			return -1
+		case first != 0:
+			return first
+		}
+	}
+	return 0
+}
+
+// dpkgOrder is the weight of the first character of s in a run of
+// non-digits: 0 at the end of s or at a digit.
+func dpkgOrder(s string) int {
+	switch {
+	case s == "" || isDigit(s[0]):
+		return 0
+	case s[0] == '~':
+		return -1
+	case s[0] >= 'A' && s[0] <= 'Z' || s[0] >= 'a' && s[0] <= 'z':
+		return int(s[0])
+	}
+	return int(s[0]) + 256
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpInt(a, b int) int {
+	return cmpUint(uint64(a), uint64(b))
+}
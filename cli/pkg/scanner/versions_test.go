@@ -0,0 +1,40 @@
+package scanner
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		ecosystem, a, b string
+		want            int
+	}{
+		// Pre-releases sort before their release, even after a zero segment.
+		{"PyPI", "2.0rc1", "2.0", -1},
+		{"PyPI", "2.0", "2.0.0", 0},
+		{"PyPI", "2.0.post1", "2.0", 1},
+		{"PyPI", "2.0.1", "2.0rc1", 1},
+		{"Maven", "1.0.0-RC1", "1.0.0", -1},
+		{"Maven", "1.0.0.Final", "1.0.0", 0},
+		{"RubyGems", "1.0.0.pre", "1.0.0", -1},
+		{"Packagist", "1.0.0-beta1", "1.0.0", -1},
+		{"Packagist", "1.0.0", "1.0", 0},
+		{"Packagist", "1.0.1", "1.0", 1},
+		{"npm", "1.0.0-rc.1", "1.0.0", -1},
+
+		// Debian and Ubuntu go by dpkg.
+		{"Debian", "1.2-1", "1.2-1+deb11u1", -1},
+		{"Debian:12", "3.0.11-1~deb12u2", "3.0.11-1", -1},
+		{"Debian", "1.0~rc1-1", "1.0-1", -1},
+		{"Debian", "1:0.9-1", "2.0-1", 1},
+		{"Debian", "1.10-1", "1.9-1", 1},
+		{"Debian", "1.0a-1", "1.0+b-1", -1},
+		{"Ubuntu:22.04:LTS", "2.35-0ubuntu3.1", "2.35-0ubuntu3", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.ecosystem, tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q, %q) = %d, want %d", tt.ecosystem, tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.ecosystem, tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q, %q) = %d, want %d", tt.ecosystem, tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
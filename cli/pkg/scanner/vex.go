@@ -0,0 +1,395 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// VEX statuses, as OpenVEX spells them. CSAF's product_status lists map onto
+// them.
+const (
+	VEXNotAffected        = "not_affected"
+	VEXAffected           = "affected"
+	VEXFixed              = "fixed"
+	VEXUnderInvestigation = "under_investigation"
+)
+
+// VEXJustifications lists OpenVEX's justifications for not_affected.
+var VEXJustifications = []string{
+	"component_not_present",
+	"vulnerable_code_not_present",
+	"vulnerable_code_not_in_execute_path",
+	"vulnerable_code_cannot_be_controlled_by_adversary",
+	"inline_mitigations_already_exist",
+}
+
+// VEXStatement is one statement of a VEX document: the status of a
+// vulnerability in some packages, identified by package URL. A statement
+// naming no packages keystone can match applies to the vulnerability in
+// every package.
+type VEXStatement struct {
+	Vulnerability string
+	Aliases       []string
+	Packages      []Package // from purls; an empty Version matches any version
+	Status        string
+	Justification string
+	Impact        string
+}
+
+// LoadVEX reads an OpenVEX or CSAF VEX document.
+func LoadVEX(path string) ([]VEXStatement, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Statements json.RawMessage `json:"statements"`
+		Document   json.RawMessage `json:"document"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", path, err)
+	}
+	var stmts []VEXStatement
+	switch {
+	case probe.Statements != nil:
+		stmts, err = parseOpenVEX(data)
+	case probe.Document != nil:
+		stmts, err = parseCSAFVEX(data)
+	default:
+		err = fmt.Errorf("neither an OpenVEX nor a CSAF document")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return stmts, nil
+}
+
+// OpenVEX is an OpenVEX (https://openvex.dev) document.
+type OpenVEX struct {
+	Context    string             `json:"@context"`
+	ID         string             `json:"@id"`
+	Author     string             `json:"author"`
+	Timestamp  string             `json:"timestamp"`
+	Version    int                `json:"version"`
+	Tooling    string             `json:"tooling,omitempty"`
+	Statements []OpenVEXStatement `json:"statements"`
+}
+
+// OpenVEXStatement is one statement of an OpenVEX document.
+type OpenVEXStatement struct {
+	Vulnerability   OpenVEXVulnerability `json:"vulnerability"`
+	Products        []OpenVEXProduct     `json:"products"`
+	Status          string               `json:"status"`
+	Justification   string               `json:"justification,omitempty"`
+	ImpactStatement string               `json:"impact_statement,omitempty"`
+	ActionStatement string               `json:"action_statement,omitempty"`
+}
+
+// OpenVEXVulnerability names a vulnerability. Documents from before OpenVEX
+// 0.2 give just the name as a string.
+type OpenVEXVulnerability struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+func (v *OpenVEXVulnerability) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &v.Name)
+	}
+	type plain OpenVEXVulnerability
+	return json.Unmarshal(data, (*plain)(v))
+}
+
+// OpenVEXProduct is a product, identified by package URL, with the
+// subcomponents the statement is about. Documents from before OpenVEX 0.2
+// give products as plain strings.
+type OpenVEXProduct struct {
+	ID            string           `json:"@id"`
+	Subcomponents []OpenVEXProduct `json:"subcomponents,omitempty"`
+}
+
+func (p *OpenVEXProduct) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &p.ID)
+	}
+	type plain OpenVEXProduct
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// OpenVEXContext is the @context of the OpenVEX version keystone writes.
+const OpenVEXContext = "https://openvex.dev/ns/v0.2.0"
+
+// NewOpenVEX returns an empty document by author, timestamped now.
+func NewOpenVEX(author string, now time.Time) *OpenVEX {
+	ts := now.UTC().Format(time.RFC3339)
+	return &OpenVEX{
+		Context:    OpenVEXContext,
+		ID:         "https://openvex.dev/docs/public/keystone-" + now.UTC().Format("20060102T150405Z"),
+		Author:     author,
+		Timestamp:  ts,
+		Version:    1,
+		Tooling:    "keystone",
+		Statements: []OpenVEXStatement{},
+	}
+}
+
+func parseOpenVEX(data []byte) ([]VEXStatement, error) {
+	var doc OpenVEX
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("bad OpenVEX document: %w", err)
+	}
+	var out []VEXStatement
+	for _, s := range doc.Statements {
+		st := VEXStatement{
+			Vulnerability: s.Vulnerability.Name,
+			Aliases:       s.Vulnerability.Aliases,
+			Status:        s.Status,
+			Justification: s.Justification,
+			Impact:        s.ImpactStatement,
+		}
+		var ids []string
+		for _, p := range s.Products {
+			ids = append(ids, p.ID)
+			for _, sub := range p.Subcomponents {
+				ids = append(ids, sub.ID)
+			}
+		}
+		st.Packages = vexPackages(ids)
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// csafProduct is a node of a CSAF product tree, where products can sit in
+// nested branches, in full_product_names or in relationships.
+type csafProduct struct {
+	ProductID string `json:"product_id"`
+	Helper    struct {
+		PURL string `json:"purl"`
+	} `json:"product_identification_helper"`
+}
+
+type csafBranch struct {
+	Product  *csafProduct `json:"product"`
+	Branches []csafBranch `json:"branches"`
+}
+
+func parseCSAFVEX(data []byte) ([]VEXStatement, error) {
+	var doc struct {
+		Document struct {
+			Category string `json:"category"`
+		} `json:"document"`
+		ProductTree struct {
+			Branches         []csafBranch  `json:"branches"`
+			FullProductNames []csafProduct `json:"full_product_names"`
+			Relationships    []struct {
+				FullProductName csafProduct `json:"full_product_name"`
+				ProductRef      string      `json:"product_reference"`
+			} `json:"relationships"`
+		} `json:"product_tree"`
+		Vulnerabilities []struct {
+			CVE string `json:"cve"`
+			IDs []struct {
+				Text string `json:"text"`
+			} `json:"ids"`
+			ProductStatus map[string][]string `json:"product_status"`
+			Flags         []struct {
+				Label      string   `json:"label"`
+				ProductIDs []string `json:"product_ids"`
+			} `json:"flags"`
+			Threats []struct {
+				Category   string   `json:"category"`
+				Details    string   `json:"details"`
+				ProductIDs []string `json:"product_ids"`
+			} `json:"threats"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("bad CSAF document: %w", err)
+	}
+	if doc.Document.Category != "csaf_vex" {
+		return nil, fmt.Errorf("CSAF document of category %q, not csaf_vex", doc.Document.Category)
+	}
+
+	purls := map[string]string{}
+	var walk func([]csafBranch)
+	walk = func(bs []csafBranch) {
+		for _, b := range bs {
+			if b.Product != nil {
+				purls[b.Product.ProductID] = b.Product.Helper.PURL
+			}
+			walk(b.Branches)
+		}
+	}
+	walk(doc.ProductTree.Branches)
+	for _, p := range doc.ProductTree.FullProductNames {
+		purls[p.ProductID] = p.Helper.PURL
+	}
+	for _, r := range doc.ProductTree.Relationships {
+		// A component installed in a product is the component itself here.
+		p := r.FullProductName
+		if p.Helper.PURL == "" {
+			p.Helper.PURL = purls[r.ProductRef]
+		}
+		purls[p.ProductID] = p.Helper.PURL
+	}
+
+	statuses := map[string]string{
+		"known_not_affected":  VEXNotAffected,
+		"known_affected":      VEXAffected,
+		"fixed":               VEXFixed,
+		"under_investigation": VEXUnderInvestigation,
+	}
+	var out []VEXStatement
+	for _, v := range doc.Vulnerabilities {
+		name := v.CVE
+		var aliases []string
+		for _, id := range v.IDs {
+			if name == "" {
+				name = id.Text
+			} else {
+				aliases = append(aliases, id.Text)
+			}
+		}
+		for _, key := range sortedKeys(v.ProductStatus) {
+			status, ok := statuses[key]
+			if !ok {
+				continue
+			}
+			for _, pid := range v.ProductStatus[key] {
+				st := VEXStatement{Vulnerability: name, Aliases: aliases, Status: status, Packages: vexPackages([]string{purls[pid]})}
+				for _, f := range v.Flags {
+					if containsString(f.ProductIDs, pid) {
+						st.Justification = f.Label
+					}
+				}
+				for _, t := range v.Threats {
+					if t.Category == "impact" && containsString(t.ProductIDs, pid) {
+						st.Impact = t.Details
+					}
+				}
+				out = append(out, st)
+			}
+		}
+	}
+	return out, nil
+}
+
+// vexPackages keeps the package URLs among ids that name packages of an OSV
+// ecosystem; others identify the product as a whole.
+func vexPackages(ids []string) []Package {
+	var out []Package
+	for _, id := range ids {
+		if p, err := ParsePURL(id); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s VEXStatement) matches(f Finding, v Vulnerability) bool {
+	ids := append([]string{v.ID}, v.Aliases...)
+	named := false
+	for _, id := range append([]string{s.Vulnerability}, s.Aliases...) {
+		if containsString(ids, id) {
+			named = true
+			break
+		}
+	}
+	if !named {
+		return false
+	}
+	if len(s.Packages) == 0 {
+		return true
+	}
+	for _, p := range s.Packages {
+		if p.Ecosystem == f.Ecosystem && p.Name == f.Package && (p.Version == "" || p.Version == f.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyVEX moves the vulnerabilities the statements declare not_affected or
+// fixed from r.Findings into r.Suppressed, like ApplyIgnores does for ignore
+// rules. Where several statements cover the same vulnerability the last one
+// wins, as later statements in a VEX document supersede earlier ones.
+func ApplyVEX(r *Report, stmts []VEXStatement) {
+	kept := r.Findings[:0]
+	for _, f := range r.Findings {
+		vulns := f.Vulns[:0]
+		for _, v := range f.Vulns {
+			var last *VEXStatement
+			for i := range stmts {
+				if stmts[i].matches(f, v) {
+					last = &stmts[i]
+				}
+			}
+			if last == nil || (last.Status != VEXNotAffected && last.Status != VEXFixed) {
+				vulns = append(vulns, v)
+				continue
+			}
+			reason := "VEX: " + last.Status
+			if last.Justification != "" {
+				reason += " (" + last.Justification + ")"
+			}
+			if last.Impact != "" {
+				reason += ": " + last.Impact
+			}
+			r.Suppressed = append(r.Suppressed, Suppression{
+				Ecosystem: f.Ecosystem,
+				Package:   f.Package,
+				Version:   f.Version,
+				ID:        v.ID,
+				Severity:  v.Severity,
+				Rule:      IgnoreRule{Pattern: v.ID, Reason: reason},
+			})
+		}
+		if len(vulns) > 0 {
+			f.Vulns = vulns
+			kept = append(kept, f)
+		}
+	}
+	r.Findings = kept
+}
+
+// VEXFromSuppressions records r's suppressed findings as OpenVEX statements.
+// Ignore rules are triage decisions that a vulnerability doesn't affect the
+// project, so they become not_affected, justified by the rule's reason when
+// it is one of VEXJustifications and explained by it otherwise. Baseline
+// entries were accepted without triage and become affected.
+func VEXFromSuppressions(r *Report, doc *OpenVEX) {
+	for _, rep := range r.Reports() {
+		for _, s := range rep.Suppressed {
+			st := OpenVEXStatement{
+				Vulnerability: OpenVEXVulnerability{Name: s.ID},
+				Products:      []OpenVEXProduct{{ID: PURL(Package{Ecosystem: s.Ecosystem, Name: s.Package, Version: s.Version})}},
+			}
+			switch {
+			case s.Rule.Reason == baselineReason:
+				st.Status = VEXAffected
+				st.ActionStatement = "Accepted in the project's baseline; remediation pending."
+			case containsString(VEXJustifications, s.Rule.Reason):
+				st.Status, st.Justification = VEXNotAffected, s.Rule.Reason
+			default:
+				st.Status = VEXNotAffected
+				st.ImpactStatement = s.Rule.Reason
+				if st.ImpactStatement == "" {
+					st.ImpactStatement = "Suppressed by ignore rule " + s.Rule.Pattern + "."
+				}
+			}
+			doc.Statements = append(doc.Statements, st)
+		}
+	}
+}
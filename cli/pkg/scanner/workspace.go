@@ -0,0 +1,290 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Workspace is one package of an npm, yarn or pnpm workspace.
+type Workspace struct {
+	Name string `json:"name"`
+	Path string `json:"path"` // of its directory, relative to the project's
+
+	deps map[string]string // declared dependency name → range
+}
+
+// FindWorkspaces returns the workspaces declared by projectDir's package.json
+// "workspaces" field or its pnpm-workspace.yaml, or nil if it declares none.
+// Workspace directories are given as globs, "*" matching one directory level
+// and "**" any number; a leading "!" excludes the directories it matches.
+func FindWorkspaces(projectDir string) ([]Workspace, error) {
+	var patterns []string
+	if m, err := readPackageJSON(filepath.Join(projectDir, "package.json")); err == nil {
+		patterns = m.workspaces()
+	}
+	if data, err := os.ReadFile(filepath.Join(projectDir, "pnpm-workspace.yaml")); err == nil {
+		patterns = append(patterns, pnpmWorkspacePatterns(data)...)
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	dirs, err := expandWorkspacePatterns(projectDir, patterns)
+	if err != nil {
+		return nil, err
+	}
+	var out []Workspace
+	for _, dir := range dirs {
+		m, err := readPackageJSON(filepath.Join(projectDir, dir, "package.json"))
+		if err != nil || m.Name == "" {
+			continue // not a package, e.g. a docs folder matched by "*"
+		}
+		ws := Workspace{Name: m.Name, Path: dir, deps: map[string]string{}}
+		for _, deps := range []map[string]string{m.Dependencies, m.DevDependencies, m.OptionalDependencies} {
+			for n, r := range deps {
+				ws.deps[n] = r
+			}
+		}
+		out = append(out, ws)
+	}
+	return out, nil
+}
+
+// workspaces returns the patterns of a package.json "workspaces" field, which
+// is either a list or, in yarn's extended form, {"packages": [...]}.
+func (m *packageJSON) workspaces() []string {
+	var list []string
+	if json.Unmarshal(m.Workspaces, &list) == nil {
+		return list
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	json.Unmarshal(m.Workspaces, &obj)
+	return obj.Packages
+}
+
+// pnpmWorkspacePatterns reads the "packages" list of a pnpm-workspace.yaml.
+func pnpmWorkspacePatterns(data []byte) []string {
+	var (
+		out     []string
+		section string
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '-' {
+			section = strings.TrimSuffix(trimmed, ":")
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok && section == "packages" {
+			out = append(out, unquote(item))
+		}
+	}
+	return out
+}
+
+// expandWorkspacePatterns returns the slash-separated directories under root
+// that patterns match, in lexical order.
+func expandWorkspacePatterns(root string, patterns []string) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == root {
+			return nil
+		}
+		if skippedDirs[d.Name()] {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		dirs = append(dirs, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, dir := range dirs {
+		included := false
+		for _, p := range patterns {
+			if neg, ok := strings.CutPrefix(p, "!"); ok {
+				if workspaceGlobMatch(neg, dir) {
+					included = false
+				}
+			} else if workspaceGlobMatch(p, dir) {
+				included = true
+			}
+		}
+		if included {
+			out = append(out, dir)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// workspaceGlobMatch matches a slash-separated directory against a workspace
+// glob, where "**" stands for any number of directories.
+func workspaceGlobMatch(pattern, dir string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+	return globSegments(strings.Split(pattern, "/"), strings.Split(dir, "/"))
+}
+
+func globSegments(pattern, dir []string) bool {
+	if len(pattern) == 0 {
+		return len(dir) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(dir); i++ {
+			if globSegments(pattern[1:], dir[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(dir) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], dir[0])
+	return err == nil && ok && globSegments(pattern[1:], dir[1:])
+}
+
+// workspaceReach works out, for each workspace, the "name@version" IDs of
+// the packages it depends on: the locked versions of its declared
+// dependencies that satisfy their ranges, everything those require in the
+// lockfile's graph, and the same for any workspaces it depends on. For a
+// lockfile that doesn't record the graph, that is only the declared
+// dependencies.
+func workspaceReach(deps []Package, workspaces []Workspace) map[string]map[string]bool {
+	byName := map[string][]Package{}
+	byID := map[string]Package{}
+	for _, d := range deps {
+		byName[d.Name] = append(byName[d.Name], d)
+		byID[d.Name+"@"+d.Version] = d
+	}
+	isWorkspace := map[string]bool{}
+	for _, ws := range workspaces {
+		isWorkspace[ws.Name] = true
+	}
+
+	own := map[string]map[string]bool{}
+	for _, ws := range workspaces {
+		seen := map[string]bool{}
+		var queue []string
+		for n, rng := range ws.deps {
+			if isWorkspace[n] {
+				continue
+			}
+			candidates := byName[n]
+			var matching []Package
+			for _, c := range candidates {
+				if ok, err := NpmRangeSatisfies(rng, c.Version); err != nil || ok {
+					matching = append(matching, c)
+				}
+			}
+			if len(matching) == 0 {
+				matching = candidates // a range we can't read, or an alias
+			}
+			for _, c := range matching {
+				queue = append(queue, c.Name+"@"+c.Version)
+			}
+		}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			queue = append(queue, byID[id].Requires...)
+		}
+		own[ws.Name] = seen
+	}
+
+	// Fold in the packages of the workspaces each one depends on.
+	reach := map[string]map[string]bool{}
+	var visit func(name string, into map[string]bool, visiting map[string]bool)
+	visit = func(name string, into map[string]bool, visiting map[string]bool) {
+		if visiting[name] {
+			return
+		}
+		visiting[name] = true
+		for id := range own[name] {
+			into[id] = true
+		}
+		for _, ws := range workspaces {
+			if ws.Name != name {
+				continue
+			}
+			for n := range ws.deps {
+				if isWorkspace[n] {
+					visit(n, into, visiting)
+				}
+			}
+		}
+	}
+	for _, ws := range workspaces {
+		into := map[string]bool{}
+		visit(ws.Name, into, map[string]bool{})
+		reach[ws.Name] = into
+	}
+	return reach
+}
+
+// WorkspacePackages narrows deps down to the packages the workspace named
+// name depends on.
+func WorkspacePackages(deps []Package, workspaces []Workspace, name string) ([]Package, error) {
+	found := false
+	for _, ws := range workspaces {
+		found = found || ws.Name == name
+	}
+	if !found {
+		return nil, fmt.Errorf("no workspace named %q", name)
+	}
+	reach := workspaceReach(deps, workspaces)[name]
+	var out []Package
+	for _, d := range deps {
+		if reach[d.Name+"@"+d.Version] {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// AttributeWorkspaces records the workspaces of r's project, and on each
+// finding the names of those that depend on its package. Findings only the
+// project root's own dependencies lead to are attributed to none.
+func AttributeWorkspaces(r *Report, workspaces []Workspace) {
+	if len(workspaces) == 0 {
+		return
+	}
+	r.Workspaces = workspaces
+	reach := workspaceReach(r.Packages, workspaces)
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		f.Workspaces = nil
+		for _, ws := range workspaces {
+			if reach[ws.Name][f.Package+"@"+f.Version] {
+				f.Workspaces = append(f.Workspaces, ws.Name)
+			}
+		}
+	}
+}
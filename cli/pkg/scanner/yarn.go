@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+)
+
+// extractYarnPackages parses both classic (v1) and Berry (v2+) yarn.lock files.
+//
+// The two formats share the same overall shape — an unindented header listing
+// one or more "name@range" specifiers, followed by indented fields — so a
+// single line-based pass handles both:
+//
+//	v1:    lodash@^4.17.15:                 Berry: "lodash@npm:^4.17.15":
+//	         version "4.17.15"                       version: 4.17.15
+//	                                                 resolution: "lodash@npm:4.17.15"
+//
+// Berry's "resolution" field is preferred when present since it carries the
+// real package name and protocol; non-registry protocols (workspace:, link:,
+// patch:, ...) are skipped because OSV has nothing to say about them.
+//
+// Each entry's "dependencies" are resolved through the specifiers of the
+// other entries, and those of Berry's workspace entries are the project's
+// direct dependencies.
+func extractYarnPackages(data []byte) []Package {
+	var (
+		out        []Package
+		header     string
+		version    string
+		resolution string
+		block      string                // nested block being read, e.g. "dependencies"
+		wants      = map[string]string{} // name → range from the entry's dependencies
+		resolved   = map[string]string{}
+		edges      [][]string // per entry in out: "name@range" specifiers it needs
+		direct     []string
+	)
+
+	flush := func() {
+		if header == "" || header == "__metadata" {
+			return
+		}
+		var specs []string
+		for _, n := range sortedKeys(wants) {
+			specs = append(specs, n+"@"+wants[n])
+		}
+		if strings.Contains(header, "@workspace:") {
+			direct = append(direct, specs...)
+		}
+		name, ok := yarnEntryName(header, resolution)
+		if !ok || version == "" {
+			return
+		}
+		for _, s := range strings.Split(unquote(header), ",") {
+			resolved[unquote(strings.TrimSpace(s))] = name + "@" + version
+		}
+		out = append(out, Package{Ecosystem: "npm", Name: name, Version: version})
+		edges = append(edges, specs)
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Unindented line ending in ":" starts a new entry.
+		if line[0] != ' ' && strings.HasSuffix(trimmed, ":") {
+			flush()
+			header = strings.TrimSuffix(trimmed, ":")
+			version, resolution, block, wants = "", "", "", map[string]string{}
+			continue
+		}
+
+		// Fields of the entry have a two-space indent; the members of nested
+		// blocks such as "dependencies:" are indented further.
+		key, val := yarnField(trimmed)
+		if strings.HasPrefix(line, "    ") {
+			if block == "dependencies" || block == "optionalDependencies" {
+				wants[unquote(key)] = unquote(val)
+			}
+			continue
+		}
+		block = ""
+		switch key {
+		case "version":
+			version = unquote(val)
+		case "resolution":
+			resolution = unquote(val)
+		case "dependencies", "optionalDependencies":
+			block = key
+		}
+	}
+	flush()
+
+	// Berry spells the protocol out in specifiers but not in dependency ranges.
+	lookup := func(spec string) (string, bool) {
+		if id, ok := resolved[spec]; ok {
+			return id, true
+		}
+		name, rng := splitNameRange(spec)
+		id, ok := resolved[name+"@npm:"+rng]
+		return id, ok
+	}
+	for i, specs := range edges {
+		for _, s := range specs {
+			if id, ok := lookup(s); ok {
+				out[i].Requires = append(out[i].Requires, id)
+			}
+		}
+	}
+	isDirect := map[string]bool{}
+	for _, s := range direct {
+		if id, ok := lookup(s); ok {
+			isDirect[id] = true
+		}
+	}
+	for i := range out {
+		out[i].Direct = isDirect[out[i].Name+"@"+out[i].Version]
+	}
+	return out
+}
+
+// yarnField splits "version \"1.0.0\"" (v1) or "version: 1.0.0" (Berry).
+func yarnField(s string) (key, val string) {
+	if i := strings.IndexAny(s, ": "); i > 0 {
+		key = s[:i]
+		val = strings.TrimSpace(strings.TrimPrefix(s[i:], ":"))
+	}
+	return key, val
+}
+
+// yarnEntryName works out the registry package name for an entry, returning
+// false for entries that don't come from the npm registry.
+func yarnEntryName(header, resolution string) (string, bool) {
+	spec := resolution
+	if spec == "" {
+		// Headers may list several comma-separated specifiers, each optionally
+		// quoted; they all resolve to the same package so the first one will do.
+		spec = unquote(strings.SplitN(unquote(header), ",", 2)[0])
+	}
+
+	name, rng := splitNameRange(spec)
+	if name == "" {
+		return "", false
+	}
+
+	// Protocols: Berry always spells them out; v1 only uses them for aliases
+	// such as "string-width-cjs@npm:string-width@^4.2.0".
+	if i := strings.Index(rng, ":"); i >= 0 {
+		protocol, rest := rng[:i], rng[i+1:]
+		if protocol != "npm" {
+			return "", false
+		}
+		// Aliased dependency: the real package name follows the protocol.
+		if len(rest) > 1 && strings.Contains(rest[1:], "@") {
+			real, _ := splitNameRange(rest)
+			name = real
+		}
+	}
+	return name, true
+}
+
+// splitNameRange splits "name@range" on the last "@" that isn't the leading
+// scope marker, so "@babel/core@^7.0.0" yields ("@babel/core", "^7.0.0").
+func splitNameRange(spec string) (name, rng string) {
+	i := strings.LastIndex(spec, "@")
+	if i <= 0 {
+		return spec, ""
+	}
+	// For "name@npm:real@range" the last "@" belongs to the alias target;
+	// the entry name ends at the first "@" after any scope prefix.
+	if j := strings.Index(spec[1:], "@"); j >= 0 && j+1 < i {
+		i = j + 1
+	}
+	return spec[:i], spec[i+1:]
+}